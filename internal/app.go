@@ -42,10 +42,13 @@ import (
 	"go.uber.org/zap"
 	"gopkg.in/ProtocolONE/rabbitmq.v1/pkg"
 	mongodb "gopkg.in/paysuper/paysuper-database-mongo.v1"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -60,6 +63,9 @@ type Application struct {
 	logger     *zap.Logger
 	svc        *service.Service
 	CliArgs    goConfig.Config
+
+	keyDaemonCancel context.CancelFunc
+	keyDaemonDone   chan struct{}
 }
 
 type appHealthCheck struct{}
@@ -149,6 +155,36 @@ func (app *Application) Init() {
 				Value: "",
 				Usage: "task context date, i.e. 2006-01-02T15:04:05Z07:00",
 			},
+			cli.StringFlag{
+				Name:  "file",
+				Value: "",
+				Usage: "path to task input or output file",
+			},
+			cli.StringFlag{
+				Name:  "payment_system",
+				Value: "",
+				Usage: "payment system identifier of task",
+			},
+			cli.StringFlag{
+				Name:  "date_to",
+				Value: "",
+				Usage: "task context end date of period, i.e. 2006-01-02",
+			},
+			cli.StringFlag{
+				Name:  "operating_company",
+				Value: "",
+				Usage: "operating company identifier of task",
+			},
+			cli.StringFlag{
+				Name:  "merchant",
+				Value: "",
+				Usage: "merchant identifier of task",
+			},
+			cli.StringFlag{
+				Name:  "format",
+				Value: "",
+				Usage: "format of task output file",
+			},
 		),
 	}
 
@@ -314,6 +350,12 @@ func (app *Application) Stop() {
 		app.logger.Info("Http server stopped")
 	}
 
+	if app.keyDaemonCancel != nil {
+		app.keyDaemonCancel()
+		<-app.keyDaemonDone
+		app.logger.Info("Key daemon stopped")
+	}
+
 	_ = app.database.Close()
 	app.logger.Info("Database connection closed")
 
@@ -359,6 +401,10 @@ func (app *Application) TaskAutoAcceptRoyaltyReports() error {
 	return app.svc.AutoAcceptRoyaltyReports(context.TODO(), &grpc.EmptyRequest{}, &grpc.EmptyResponse{})
 }
 
+func (app *Application) TaskAutoEscalateRoyaltyReportDisputes() error {
+	return app.svc.AutoEscalateRoyaltyReportDisputes(context.TODO(), &grpc.EmptyRequest{}, &grpc.EmptyResponse{})
+}
+
 func (app *Application) TaskAutoCreatePayouts() error {
 	return app.svc.AutoCreatePayoutDocuments(context.TODO(), &grpc.EmptyRequest{}, &grpc.EmptyResponse{})
 }
@@ -367,34 +413,247 @@ func (app *Application) TaskRebuildOrderView() error {
 	return app.svc.RebuildOrderView(context.TODO())
 }
 
+func (app *Application) TaskBackfillLedger() error {
+	return app.svc.BackfillLedger(context.TODO())
+}
+
 func (app *Application) TaskMerchantsMigrate() error {
 	return app.svc.MerchantsMigrate(context.TODO())
 }
 
+func (app *Application) TaskVoidExpiredPaymentAuthorizations() error {
+	return app.svc.VoidExpiredPaymentAuthorizations(context.TODO())
+}
+
+func (app *Application) TaskExpireOrders() error {
+	return app.svc.ExpireOrders(context.TODO())
+}
+
+func (app *Application) TaskReconcilePaymentStatuses() error {
+	return app.svc.ReconcilePaymentStatuses(context.TODO())
+}
+
+func (app *Application) TaskProcessSubscriptions() error {
+	return app.svc.ProcessSubscriptions(context.TODO())
+}
+
+func (app *Application) TaskImportSettlementFile(file, paymentSystemId, date string) error {
+	content, err := ioutil.ReadFile(file)
+
+	if err != nil {
+		return err
+	}
+
+	from, err := time.Parse("2006-01-02", date)
+
+	if err != nil {
+		return err
+	}
+
+	req := &grpc.ImportSettlementFileRequest{
+		PaymentSystemId: paymentSystemId,
+		FileType:        strings.TrimPrefix(strings.ToLower(filepath.Ext(file)), "."),
+		File:            content,
+		PeriodFrom:      from.Unix(),
+		PeriodTo:        from.AddDate(0, 0, 1).Unix(),
+	}
+	rsp := &grpc.ImportSettlementFileResponse{}
+	err = app.svc.ImportSettlementFile(context.TODO(), req, rsp)
+
+	if err != nil {
+		return err
+	}
+
+	if rsp.Status != pkg.ResponseStatusOk {
+		return rsp.Message
+	}
+
+	zap.L().Info(
+		"Settlement file imported",
+		zap.String("report_id", rsp.Item.Id),
+		zap.Any("totals", rsp.Item.Totals),
+	)
+
+	return nil
+}
+
+// TaskExportAccountingEntries exports accounting entries of operating company and merchant created in period
+// from the start of date to the end of dateTo into the file, entries are requested from service by pages.
+func (app *Application) TaskExportAccountingEntries(file, format, operatingCompanyId, merchantId, date, dateTo string) error {
+	from, err := time.Parse("2006-01-02", date)
+
+	if err != nil {
+		return err
+	}
+
+	to, err := time.Parse("2006-01-02", dateTo)
+
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(file)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	req := &grpc.ExportAccountingEntriesRequest{
+		Format:             format,
+		OperatingCompanyId: operatingCompanyId,
+		MerchantId:         merchantId,
+		DateFrom:           from.Unix(),
+		DateTo:             to.AddDate(0, 0, 1).Unix() - 1,
+	}
+	count := int32(0)
+
+	for {
+		rsp := &grpc.ExportAccountingEntriesResponse{}
+		err = app.svc.ExportAccountingEntries(context.TODO(), req, rsp)
+
+		if err != nil {
+			return err
+		}
+
+		if rsp.Status != pkg.ResponseStatusOk {
+			return rsp.Message
+		}
+
+		if _, err = f.Write(rsp.File); err != nil {
+			return err
+		}
+
+		count += rsp.Count
+
+		if rsp.NextCursor == "" {
+			break
+		}
+
+		req.Cursor = rsp.NextCursor
+	}
+
+	zap.L().Info("Accounting entries exported", zap.String("file", file), zap.Int32("count", count))
+
+	return nil
+}
+
+// KeyDaemonStart starts release of expired key reservations. Reservations are released at the time of their
+// expiry by schedule, the expired reservations missed by schedule are released by reconciliation
+// once per KeyDaemonRestartInterval.
 func (app *Application) KeyDaemonStart() {
-	zap.L().Info("Key daemon started", zap.Int64("RestartInterval", app.cfg.KeyDaemonRestartInterval))
+	zap.L().Info(
+		"Key daemon started",
+		zap.Int64("RestartInterval", app.cfg.KeyDaemonRestartInterval),
+		zap.Int64("CheckInterval", app.cfg.KeyReservationCheckInterval),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	app.keyDaemonCancel = cancel
+	app.keyDaemonDone = make(chan struct{})
 
 	go func() {
-		interval := time.Duration(app.cfg.KeyDaemonRestartInterval) * time.Second
 		shutdown := make(chan os.Signal, 1)
 		signal.Notify(shutdown, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
 
+		select {
+		case <-shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	go func() {
+		defer close(app.keyDaemonDone)
+
+		checkInterval := app.cfg.GetKeyReservationCheckInterval()
+		reconciliation := time.NewTicker(time.Duration(app.cfg.KeyDaemonRestartInterval) * time.Second)
+		defer reconciliation.Stop()
+
 		for {
 			zap.S().Debug("Key daemon working")
 
+			count, err := app.svc.ReleaseExpiredKeys(ctx)
+			if err != nil && ctx.Err() == nil {
+				zap.L().Error("Release of expired keys failed", zap.Error(err))
+			}
+
+			zap.S().Debugw("Key daemon job finished", "count", count)
+
+			wait := checkInterval
+			if next := app.svc.GetNextKeyReservationExpiry(); !next.IsZero() && time.Until(next) < wait {
+				wait = time.Until(next)
+			}
+
+			timer := time.NewTimer(wait)
+
 			select {
-			case <-shutdown:
+			case <-ctx.Done():
+				timer.Stop()
 				zap.S().Info("Key daemon stopping")
 				return
-			default:
-				count, err := app.svc.KeyDaemonProcess(context.TODO())
+			case <-reconciliation.C:
+				timer.Stop()
+				count, err := app.svc.KeyDaemonProcess(ctx)
 				if err != nil {
 					zap.L().Error("Key daemon process failed", zap.Error(err))
 				}
 
-				zap.S().Debugf("Key daemon job finished", "count", count)
-				time.Sleep(interval)
+				if count > 0 {
+					zap.L().Warn("Key daemon released leaked key reservations", zap.Int("count", count))
+				}
+			case <-timer.C:
 			}
 		}
 	}()
 }
+
+// startDaemon runs process once per restartInterval seconds until the application receives a shutdown signal.
+func (app *Application) startDaemon(name string, restartInterval int64, process func(ctx context.Context) (int, error)) {
+	zap.L().Info(name+" daemon started", zap.Int64("RestartInterval", restartInterval))
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		shutdown := make(chan os.Signal, 1)
+		signal.Notify(shutdown, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
+		<-shutdown
+		cancel()
+	}()
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(restartInterval) * time.Second)
+		defer ticker.Stop()
+
+		for {
+			zap.L().Debug(name + " daemon working")
+
+			count, err := process(ctx)
+			if err != nil && ctx.Err() == nil {
+				zap.L().Error(name+" daemon process failed", zap.Error(err))
+			}
+
+			zap.L().Debug(name+" daemon job finished", zap.Int("count", count))
+
+			select {
+			case <-ctx.Done():
+				zap.L().Info(name + " daemon stopping")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (app *Application) WebhookDaemonStart() {
+	app.startDaemon("Webhook", app.cfg.WebhookDaemonRestartInterval, app.svc.ProcessWebhookEvents)
+}
+
+func (app *Application) BulkRefundDaemonStart() {
+	app.startDaemon("Bulk refund", app.cfg.BulkRefundDaemonRestartInterval, app.svc.ProcessBulkRefundJobs)
+}
+
+func (app *Application) GiftDaemonStart() {
+	app.startDaemon("Gift", app.cfg.GiftDaemonRestartInterval, app.svc.ProcessGiftDeliveries)
+}
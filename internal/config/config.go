@@ -11,11 +11,22 @@ import (
 	"time"
 )
 
+// PaymentSystemConfig defines the settings common for all payment system providers.
 type PaymentSystemConfig struct {
-	CardPayApiUrl        string `envconfig:"CARD_PAY_API_URL" required:"true"`
-	CardPayApiSandboxUrl string `envconfig:"CARD_PAY_API_SANDBOX_URL" required:"true"`
-	RedirectUrlSuccess   string `envconfig:"REDIRECT_URL_SUCCESS" default:"https://order.pay.super.com/?result=success"`
-	RedirectUrlFail      string `envconfig:"REDIRECT_URL_FAIL" default:"https://order.pay.super.com/?result=fail"`
+	RedirectUrlSuccess string `envconfig:"REDIRECT_URL_SUCCESS" default:"https://order.pay.super.com/?result=success"`
+	RedirectUrlFail    string `envconfig:"REDIRECT_URL_FAIL" default:"https://order.pay.super.com/?result=fail"`
+}
+
+// PaymentSystemProviderConfig defines the own config section of a payment system provider.
+type PaymentSystemProviderConfig interface {
+	// GetApiUrl returns url of the provider API for production or sandbox mode.
+	GetApiUrl(isProduction bool) string
+}
+
+// CardPayConfig defines the config section of the CardPay payment system provider.
+type CardPayConfig struct {
+	ApiUrl        string `envconfig:"CARD_PAY_API_URL" required:"true"`
+	ApiSandboxUrl string `envconfig:"CARD_PAY_API_SANDBOX_URL" required:"true"`
 }
 
 type CustomerTokenConfig struct {
@@ -52,6 +63,10 @@ type EmailTemplates struct {
 	OnboardingVerificationAdmin    string `envconfig:"EMAIL_ADMIN_NEW_ONBOARDING_REQUEST_TEMPLATE" default:"p1_email_admin_new_onboarding_request_template"`
 	OnboardingCompleted            string `envconfig:"EMAIL_MERCHANT_ONBOARDING_REQUEST_COMPLETE_TEMPLATE" default:"p1_email_merchant_onboarding_request_complete_template"`
 	UserInvite                     string `envconfig:"EMAIL_INVITE_TEMPLATE" default:"code-your-own"`
+	SubscriptionPaymentFailed      string `envconfig:"EMAIL_SUBSCRIPTION_PAYMENT_FAILED_TEMPLATE" default:"p1_subscription_payment_failed"`
+	SubscriptionCanceled           string `envconfig:"EMAIL_SUBSCRIPTION_CANCELED_TEMPLATE" default:"p1_subscription_canceled"`
+	KeyProductLowStock             string `envconfig:"EMAIL_KEY_PRODUCT_LOW_STOCK_TEMPLATE" default:"p1_key_product_low_stock"`
+	GiftGameKey                    string `envconfig:"EMAIL_GIFT_CODE_TEMPLATE" default:"p1_gift_code"`
 }
 
 type Config struct {
@@ -72,9 +87,9 @@ type Config struct {
 
 	MicroRegistry string `envconfig:"MICRO_REGISTRY" required:"false"`
 
-	RoyaltyReportPeriod        int64  `envconfig:"ROYALTY_REPORT_PERIOD" default:"604800"`
 	RoyaltyReportTimeZone      string `envconfig:"ROYALTY_REPORT_TIMEZONE" default:"Europe/Moscow"`
 	RoyaltyReportAcceptTimeout int64  `envconfig:"ROYALTY_REPORT_TIMEZONE" default:"432000"`
+	RoyaltyReportBackfillDays  int64  `envconfig:"ROYALTY_REPORT_BACKFILL_DAYS" default:"60"`
 	// moved to config for testing purposes, to prevent royalty reports tests crash on mondays before 18:00
 	// must not be changed on normal app running, because it will broke royalty reports calculations
 	RoyaltyReportPeriodEndHour int64 `default:"18"`
@@ -91,7 +106,60 @@ type Config struct {
 	HelloSignDefaultTemplate   string `envconfig:"HELLO_SIGN_DEFAULT_TEMPLATE" required:"true"`
 	HelloSignAgreementClientId string `envconfig:"HELLO_SIGN_AGREEMENT_CLIENT_ID" required:"true"`
 
-	KeyDaemonRestartInterval int64 `envconfig:"KEY_DAEMON_RESTART_INTERVAL" default:"60"`
+	// interval in seconds of reconciliation of expired key reservations missed by reservation schedule
+	KeyDaemonRestartInterval int64 `envconfig:"KEY_DAEMON_RESTART_INTERVAL" default:"3600"`
+	// maximal interval in seconds between checks of schedule of key reservations expiry
+	KeyReservationCheckInterval int64 `envconfig:"KEY_RESERVATION_CHECK_INTERVAL" default:"5"`
+
+	// lifetime of payment authorization in seconds, after which not captured authorization will be voided
+	PaymentAuthorizationLifetime int64 `envconfig:"PAYMENT_AUTHORIZATION_LIFETIME" default:"604800"`
+
+	// timeout in seconds after which not paid order will be canceled, can be overridden in project settings
+	OrderExpiryTimeout int64 `envconfig:"ORDER_EXPIRY_TIMEOUT" default:"86400"`
+
+	// total score of triggered risk rules from which payment is sent to manual review
+	RiskReviewScore int32 `envconfig:"RISK_REVIEW_SCORE" default:"50"`
+
+	// total score of triggered risk rules from which payment is denied
+	RiskDenyScore int32 `envconfig:"RISK_DENY_SCORE" default:"100"`
+
+	// time in seconds after which failed subscription renewal charge is retried
+	SubscriptionRetryInterval int64 `envconfig:"SUBSCRIPTION_RETRY_INTERVAL" default:"86400"`
+
+	// maximal count of failed subscription renewal charges in a row, after which subscription is canceled
+	SubscriptionMaxChargeAttempts int32 `envconfig:"SUBSCRIPTION_MAX_CHARGE_ATTEMPTS" default:"4"`
+
+	// time in seconds after which renewal order with payment still in progress is considered as failed charge
+	SubscriptionRenewalTimeout int64 `envconfig:"SUBSCRIPTION_RENEWAL_TIMEOUT" default:"86400"`
+
+	// time in seconds given to merchant to respond to dispute if payment system didn't set deadline
+	DisputeResponseTimeout int64 `envconfig:"DISPUTE_RESPONSE_TIMEOUT" default:"604800"`
+
+	// time in seconds after which payment status of order in payment system create status is requested from payment system
+	PaymentStatusReconciliationThreshold int64 `envconfig:"PAYMENT_STATUS_RECONCILIATION_THRESHOLD" default:"3600"`
+
+	// lifetime of idempotency key in seconds, during which repeated request with the key returns the original response
+	IdempotencyKeyLifetime int64 `envconfig:"IDEMPOTENCY_KEY_LIFETIME" default:"86400"`
+	// lifetime of idempotency key in seconds while request with the key is in progress,
+	// after it the key is released if the request processing was interrupted
+	IdempotencyKeyLockLifetime int64 `envconfig:"IDEMPOTENCY_KEY_LOCK_LIFETIME" default:"60"`
+
+	WebhookDaemonRestartInterval int64 `envconfig:"WEBHOOK_DAEMON_RESTART_INTERVAL" default:"10"`
+	// maximal count of webhook delivery attempts, after which webhook event marked as dead
+	WebhookMaxAttempts int32 `envconfig:"WEBHOOK_MAX_ATTEMPTS" default:"10"`
+	// delay in seconds before the first retry of webhook delivery, every next retry delay is doubled
+	WebhookRetryDelay int64 `envconfig:"WEBHOOK_RETRY_DELAY" default:"30"`
+
+	BulkRefundDaemonRestartInterval int64 `envconfig:"BULK_REFUND_DAEMON_RESTART_INTERVAL" default:"10"`
+	// maximal count of refunds per second sent to payment system by bulk refund jobs of all instances of service
+	BulkRefundRateLimit int64 `envconfig:"BULK_REFUND_RATE_LIMIT" default:"5"`
+
+	GiftDaemonRestartInterval int64 `envconfig:"GIFT_DAEMON_RESTART_INTERVAL" default:"60"`
+
+	// timeout in seconds of request to project check account url
+	CheckAccountTimeout int64 `envconfig:"CHECK_ACCOUNT_TIMEOUT" default:"5"`
+	// allow payment if project check account url is unavailable or returned unexpected response
+	CheckAccountFailOpen bool `envconfig:"CHECK_ACCOUNT_FAIL_OPEN" default:"true"`
 
 	PaylinkMinProducts int `envconfig:"PAYLINK_MIN_PRODUCTS" required:"false" default:"1"`
 	PaylinkMaxProducts int `envconfig:"PAYLINK_MAX_PRODUCTS" required:"false" default:"8"`
@@ -101,7 +169,14 @@ type Config struct {
 	UserInviteTokenSecret  string `envconfig:"USER_INVITE_TOKEN_SECRET" required:"true"`
 	UserInviteTokenTimeout int64  `envconfig:"USER_INVITE_TOKEN_TIMEOUT" default:"48"`
 
+	// secret key for encryption of codes of key products at rest
+	KeyCodeEncryptionSecret string `envconfig:"KEY_CODE_ENCRYPTION_SECRET" required:"true"`
+
+	// secret key for hashing of bank card numbers used by risk rules
+	RiskCardTokenSecret string `envconfig:"RISK_CARD_TOKEN_SECRET" required:"true"`
+
 	*PaymentSystemConfig
+	CardPay *CardPayConfig
 	*CustomerTokenConfig
 	*CacheRedis
 	*EmailTemplates
@@ -167,6 +242,14 @@ func NewConfig() (*Config, error) {
 	return cfg, err
 }
 
+func (cfg *CardPayConfig) GetApiUrl(isProduction bool) string {
+	if isProduction {
+		return cfg.ApiUrl
+	}
+
+	return cfg.ApiSandboxUrl
+}
+
 func (cfg *Config) GetCustomerTokenLength() int {
 	return cfg.CustomerTokenConfig.Length
 }
@@ -179,6 +262,59 @@ func (cfg *Config) GetEmailConfirmTokenLifetime() time.Duration {
 	return time.Second * time.Duration(cfg.EmailConfirmTokenLifetime)
 }
 
+func (cfg *Config) GetPaymentAuthorizationLifetime() time.Duration {
+	return time.Second * time.Duration(cfg.PaymentAuthorizationLifetime)
+}
+
+func (cfg *Config) GetOrderExpiryTimeout() time.Duration {
+	return time.Second * time.Duration(cfg.OrderExpiryTimeout)
+}
+
+func (cfg *Config) GetSubscriptionRetryInterval() time.Duration {
+	return time.Second * time.Duration(cfg.SubscriptionRetryInterval)
+}
+
+func (cfg *Config) GetSubscriptionRenewalTimeout() time.Duration {
+	return time.Second * time.Duration(cfg.SubscriptionRenewalTimeout)
+}
+
+func (cfg *Config) GetDisputeResponseTimeout() time.Duration {
+	return time.Second * time.Duration(cfg.DisputeResponseTimeout)
+}
+
+func (cfg *Config) GetPaymentStatusReconciliationThreshold() time.Duration {
+	return time.Second * time.Duration(cfg.PaymentStatusReconciliationThreshold)
+}
+
+func (cfg *Config) GetIdempotencyKeyLifetime() time.Duration {
+	return time.Second * time.Duration(cfg.IdempotencyKeyLifetime)
+}
+
+func (cfg *Config) GetIdempotencyKeyLockLifetime() time.Duration {
+	return time.Second * time.Duration(cfg.IdempotencyKeyLockLifetime)
+}
+
+func (cfg *Config) GetWebhookRetryDelay() time.Duration {
+	return time.Second * time.Duration(cfg.WebhookRetryDelay)
+}
+
+// GetBulkRefundDelay returns minimal delay between two refunds of bulk refund job
+func (cfg *Config) GetBulkRefundDelay() time.Duration {
+	if cfg.BulkRefundRateLimit <= 0 {
+		return 0
+	}
+
+	return time.Second / time.Duration(cfg.BulkRefundRateLimit)
+}
+
+func (cfg *Config) GetKeyReservationCheckInterval() time.Duration {
+	return time.Second * time.Duration(cfg.KeyReservationCheckInterval)
+}
+
+func (cfg *Config) GetCheckAccountTimeout() time.Duration {
+	return time.Second * time.Duration(cfg.CheckAccountTimeout)
+}
+
 func (cfg *Config) GetUserConfirmEmailUrl(params map[string]string) string {
 	query := cfg.EmailConfirmUrlParsed.Query()
 
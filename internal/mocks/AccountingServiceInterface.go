@@ -36,6 +36,29 @@ func (_m *AccountingServiceInterface) GetCorrectionsForRoyaltyReport(ctx context
 	return r0, r1
 }
 
+// GetForExport provides a mock function with given fields: ctx, operatingCompanyId, merchantId, from, to, cursor, limit
+func (_m *AccountingServiceInterface) GetForExport(ctx context.Context, operatingCompanyId string, merchantId string, from time.Time, to time.Time, cursor string, limit int64) ([]*billing.AccountingEntry, error) {
+	ret := _m.Called(ctx, operatingCompanyId, merchantId, from, to, cursor, limit)
+
+	var r0 []*billing.AccountingEntry
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time, time.Time, string, int64) []*billing.AccountingEntry); ok {
+		r0 = rf(ctx, operatingCompanyId, merchantId, from, to, cursor, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*billing.AccountingEntry)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, time.Time, time.Time, string, int64) error); ok {
+		r1 = rf(ctx, operatingCompanyId, merchantId, from, to, cursor, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetRollingReservesForRoyaltyReport provides a mock function with given fields: ctx, merchantId, operatingCompanyId, currency, from, to
 func (_m *AccountingServiceInterface) GetRollingReservesForRoyaltyReport(ctx context.Context, merchantId string, operatingCompanyId string, currency string, from time.Time, to time.Time) ([]*billing.AccountingEntry, error) {
 	ret := _m.Called(ctx, merchantId, operatingCompanyId, currency, from, to)
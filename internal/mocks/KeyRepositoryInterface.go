@@ -34,6 +34,29 @@ func (_m *KeyRepositoryInterface) CancelById(_a0 context.Context, _a1 string) (*
 	return r0, r1
 }
 
+// CancelExpiredById provides a mock function with given fields: _a0, _a1
+func (_m *KeyRepositoryInterface) CancelExpiredById(_a0 context.Context, _a1 string) (*billing.Key, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *billing.Key
+	if rf, ok := ret.Get(0).(func(context.Context, string) *billing.Key); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*billing.Key)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CountKeysByProductPlatform provides a mock function with given fields: _a0, _a1, _a2
 func (_m *KeyRepositoryInterface) CountKeysByProductPlatform(_a0 context.Context, _a1 string, _a2 string) (int64, error) {
 	ret := _m.Called(_a0, _a1, _a2)
@@ -55,6 +78,52 @@ func (_m *KeyRepositoryInterface) CountKeysByProductPlatform(_a0 context.Context
 	return r0, r1
 }
 
+// FindByCodeHashes provides a mock function with given fields: _a0, _a1, _a2, _a3
+func (_m *KeyRepositoryInterface) FindByCodeHashes(_a0 context.Context, _a1 string, _a2 []string, _a3 []string) ([]*billing.Key, error) {
+	ret := _m.Called(_a0, _a1, _a2, _a3)
+
+	var r0 []*billing.Key
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string, []string) []*billing.Key); ok {
+		r0 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*billing.Key)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, []string, []string) error); ok {
+		r1 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindByOrderId provides a mock function with given fields: _a0, _a1
+func (_m *KeyRepositoryInterface) FindByOrderId(_a0 context.Context, _a1 string) ([]*billing.Key, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 []*billing.Key
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*billing.Key); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*billing.Key)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // FindUnfinished provides a mock function with given fields: _a0
 func (_m *KeyRepositoryInterface) FindUnfinished(_a0 context.Context) ([]*billing.Key, error) {
 	ret := _m.Called(_a0)
@@ -160,3 +229,26 @@ func (_m *KeyRepositoryInterface) ReserveKey(_a0 context.Context, _a1 string, _a
 
 	return r0, r1
 }
+
+// RevokeById provides a mock function with given fields: _a0, _a1, _a2
+func (_m *KeyRepositoryInterface) RevokeById(_a0 context.Context, _a1 string, _a2 string) (*billing.Key, error) {
+	ret := _m.Called(_a0, _a1, _a2)
+
+	var r0 *billing.Key
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *billing.Key); ok {
+		r0 = rf(_a0, _a1, _a2)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*billing.Key)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(_a0, _a1, _a2)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
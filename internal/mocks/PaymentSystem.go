@@ -11,6 +11,20 @@ type PaymentSystem struct {
 	mock.Mock
 }
 
+// CapturePayment provides a mock function with given fields: order, amount
+func (_m *PaymentSystem) CapturePayment(order *billing.Order, amount float64) error {
+	ret := _m.Called(order, amount)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*billing.Order, float64) error); ok {
+		r0 = rf(order, amount)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // CreatePayment provides a mock function with given fields: order, successUrl, failUrl, requisites
 func (_m *PaymentSystem) CreatePayment(order *billing.Order, successUrl string, failUrl string, requisites map[string]string) (string, error) {
 	ret := _m.Called(order, successUrl, failUrl, requisites)
@@ -46,6 +60,29 @@ func (_m *PaymentSystem) CreateRefund(order *billing.Order, refund *billing.Refu
 	return r0
 }
 
+// GetPaymentStatus provides a mock function with given fields: order
+func (_m *PaymentSystem) GetPaymentStatus(order *billing.Order) (proto.Message, error) {
+	ret := _m.Called(order)
+
+	var r0 proto.Message
+	if rf, ok := ret.Get(0).(func(*billing.Order) proto.Message); ok {
+		r0 = rf(order)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(proto.Message)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*billing.Order) error); ok {
+		r1 = rf(order)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetRecurringId provides a mock function with given fields: request
 func (_m *PaymentSystem) GetRecurringId(request proto.Message) string {
 	ret := _m.Called(request)
@@ -101,3 +138,17 @@ func (_m *PaymentSystem) ProcessRefund(order *billing.Order, refund *billing.Ref
 
 	return r0
 }
+
+// VoidPayment provides a mock function with given fields: order
+func (_m *PaymentSystem) VoidPayment(order *billing.Order) error {
+	ret := _m.Called(order)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*billing.Order) error); ok {
+		r0 = rf(order)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -101,6 +101,29 @@ func (_m *RoyaltyReportServiceInterface) GetByPayoutId(ctx context.Context, payo
 	return r0, r1
 }
 
+// GetLastByMerchantId provides a mock function with given fields: ctx, merchantId
+func (_m *RoyaltyReportServiceInterface) GetLastByMerchantId(ctx context.Context, merchantId string) (*billing.RoyaltyReport, error) {
+	ret := _m.Called(ctx, merchantId)
+
+	var r0 *billing.RoyaltyReport
+	if rf, ok := ret.Get(0).(func(context.Context, string) *billing.RoyaltyReport); ok {
+		r0 = rf(ctx, merchantId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*billing.RoyaltyReport)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, merchantId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetNonPayoutReports provides a mock function with given fields: ctx, merchantId, operatingCompanyId, currency
 func (_m *RoyaltyReportServiceInterface) GetNonPayoutReports(ctx context.Context, merchantId string, operatingCompanyId string, currency string) ([]*billing.RoyaltyReport, error) {
 	ret := _m.Called(ctx, merchantId, operatingCompanyId, currency)
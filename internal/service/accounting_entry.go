@@ -29,6 +29,13 @@ const (
 	accountingEventTypePayment          = "payment"
 	accountingEventTypeRefund           = "refund"
 	accountingEventTypeManualCorrection = "manual-correction"
+	accountingEventTypeDisputeWon       = "dispute-won"
+
+	accountingEventTypeStoreCreditIssue   = "store-credit-issue"
+	accountingEventTypeStoreCreditPayment = "store-credit-payment"
+	accountingEventTypeStoreCreditRefund  = "store-credit-refund"
+
+	accountingEntryReasonDisputeWon = "reversal of chargeback by won dispute"
 )
 
 var (
@@ -98,12 +105,16 @@ var (
 		pkg.AccountingEntryTypeMerchantRollingReserveCreate:        true,
 		pkg.AccountingEntryTypeMerchantRollingReserveRelease:       true,
 		pkg.AccountingEntryTypeMerchantRoyaltyCorrection:           true,
+		pkg.AccountingEntryTypeMerchantStoreCreditIssue:            true,
+		pkg.AccountingEntryTypeMerchantStoreCreditRedemption:       true,
+		pkg.AccountingEntryTypeMerchantStoreCreditRefund:           true,
 	}
 
 	availableAccountingEntriesSourceTypes = map[string]bool{
 		collectionOrder:    true,
 		collectionRefund:   true,
 		collectionMerchant: true,
+		collectionDispute:  true,
 	}
 
 	rollingReserveAccountingEntries = map[string]bool{
@@ -124,15 +135,19 @@ type accountingEntry struct {
 	order             *billing.Order
 	refund            *billing.Refund
 	refundOrder       *billing.Order
+	dispute           *billing.Dispute
 	merchant          *billing.Merchant
 	country           *billing.Country
+	storeCredit       *billing.StoreCreditTransaction
 	accountingEntries []interface{}
+	ledgerEntries     []*billing.AccountingEntry
 	req               *grpc.CreateAccountingEntryRequest
 }
 
 type AccountingServiceInterface interface {
 	GetCorrectionsForRoyaltyReport(ctx context.Context, merchantId, operatingCompanyId, currency string, from, to time.Time) (items []*billing.AccountingEntry, err error)
 	GetRollingReservesForRoyaltyReport(ctx context.Context, merchantId, operatingCompanyId, currency string, from, to time.Time) (items []*billing.AccountingEntry, err error)
+	GetForExport(ctx context.Context, operatingCompanyId, merchantId string, from, to time.Time, cursor string, limit int64) (items []*billing.AccountingEntry, err error)
 }
 
 func newAccounting(svc *Service) AccountingServiceInterface {
@@ -245,6 +260,11 @@ func (s *Service) CreateAccountingEntry(
 		rsp.Status = pkg.ResponseStatusSystemError
 		rsp.Message = accountingEntryErrorUnknown
 
+		if err == ledgerErrorUnbalanced {
+			rsp.Status = pkg.ResponseStatusBadData
+			rsp.Message = ledgerErrorUnbalanced
+		}
+
 		return nil
 	}
 
@@ -283,6 +303,10 @@ func (s *Service) onPaymentNotify(ctx context.Context, order *billing.Order) err
 		merchant: merchant,
 	}
 
+	if order.IsPaidByStoreCredit() {
+		return s.processEvent(handler, accountingEventTypeStoreCreditPayment)
+	}
+
 	return s.processEvent(handler, accountingEventTypePayment)
 }
 
@@ -314,9 +338,52 @@ func (s *Service) onRefundNotify(ctx context.Context, refund *billing.Refund, or
 		merchant:    merchant,
 	}
 
+	if order.IsPaidByStoreCredit() {
+		return s.processEvent(handler, accountingEventTypeStoreCreditRefund)
+	}
+
 	return s.processEvent(handler, accountingEventTypeRefund)
 }
 
+func (s *Service) onStoreCreditIssue(
+	ctx context.Context,
+	merchant *billing.Merchant,
+	transaction *billing.StoreCreditTransaction,
+) error {
+	handler := &accountingEntry{
+		Service:     s,
+		ctx:         ctx,
+		merchant:    merchant,
+		storeCredit: transaction,
+	}
+
+	return s.processEvent(handler, accountingEventTypeStoreCreditIssue)
+}
+
+func (s *Service) onDisputeWon(
+	ctx context.Context,
+	dispute *billing.Dispute,
+	refund *billing.Refund,
+	order *billing.Order,
+) error {
+	country, err := s.country.GetByIsoCodeA2(ctx, order.GetCountry())
+
+	if err != nil {
+		return err
+	}
+
+	handler := &accountingEntry{
+		Service: s,
+		dispute: dispute,
+		refund:  refund,
+		order:   order,
+		ctx:     ctx,
+		country: country,
+	}
+
+	return s.processEvent(handler, accountingEventTypeDisputeWon)
+}
+
 func (s *Service) processEvent(handler *accountingEntry, eventType string) error {
 	var err error
 
@@ -333,6 +400,22 @@ func (s *Service) processEvent(handler *accountingEntry, eventType string) error
 		err = handler.processManualCorrectionEvent()
 		break
 
+	case accountingEventTypeDisputeWon:
+		err = handler.processDisputeWonEvent()
+		break
+
+	case accountingEventTypeStoreCreditIssue:
+		err = handler.processStoreCreditIssueEvent()
+		break
+
+	case accountingEventTypeStoreCreditPayment:
+		err = handler.processStoreCreditPaymentEvent()
+		break
+
+	case accountingEventTypeStoreCreditRefund:
+		err = handler.processStoreCreditRefundEvent()
+		break
+
 	default:
 		return accountingEntryUnknownEvent
 	}
@@ -372,6 +455,207 @@ func (h *accountingEntry) processManualCorrectionEvent() error {
 	return nil
 }
 
+// processDisputeWonEvent creates entries reversing all entries of chargeback refund
+func (h *accountingEntry) processDisputeWonEvent() error {
+	disputeOid, _ := primitive.ObjectIDFromHex(h.dispute.Id)
+	query := bson.M{"source.id": disputeOid, "source.type": collectionDispute}
+	count, err := h.db.Collection(collectionAccountingEntry).CountDocuments(h.ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionAccountingEntry),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationCount),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return err
+	}
+
+	if count > 0 {
+		return accountingEntryAlreadyCreated
+	}
+
+	sourceOid, _ := primitive.ObjectIDFromHex(h.refund.CreatedOrderId)
+	query = bson.M{
+		"object":      pkg.ObjectTypeBalanceTransaction,
+		"source.id":   sourceOid,
+		"source.type": collectionRefund,
+	}
+	cursor, err := h.db.Collection(collectionAccountingEntry).Find(h.ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionAccountingEntry),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return err
+	}
+
+	var entries []*billing.AccountingEntry
+	err = cursor.All(h.ctx, &entries)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionAccountingEntry),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return err
+	}
+
+	if len(entries) <= 0 {
+		return accountingEntryErrorRefundNotFound
+	}
+
+	var reversed []*billing.AccountingEntry
+
+	for _, v := range entries {
+		entry := &billing.AccountingEntry{
+			Id:     primitive.NewObjectID().Hex(),
+			Object: v.Object,
+			Type:   v.Type,
+			Source: &billing.AccountingEntrySource{
+				Id:   h.dispute.Id,
+				Type: collectionDispute,
+			},
+			MerchantId:         v.MerchantId,
+			Amount:             -v.Amount,
+			Currency:           v.Currency,
+			Reason:             accountingEntryReasonDisputeWon,
+			Status:             v.Status,
+			Country:            v.Country,
+			OriginalAmount:     -v.OriginalAmount,
+			OriginalCurrency:   v.OriginalCurrency,
+			LocalAmount:        -v.LocalAmount,
+			LocalCurrency:      v.LocalCurrency,
+			CreatedAt:          ptypes.TimestampNow(),
+			OperatingCompanyId: v.OperatingCompanyId,
+		}
+
+		if err = h.addEntry(entry); err != nil {
+			return err
+		}
+
+		reversed = append(reversed, entry)
+	}
+
+	for _, entry := range getLedgerCalculatedEntries(reversed) {
+		h.addLedgerEntry(entry)
+	}
+
+	return nil
+}
+
+// processStoreCreditIssueEvent creates entry of store credit issued by merchant to customer. Issued credit
+// doesn't change balance of merchant, it's spent by customer for payment of orders of merchant later.
+// Amount of credit is converted to payout currency of merchant like amounts of other entries of merchant.
+func (h *accountingEntry) processStoreCreditIssueEvent() error {
+	entry := h.newEntry(pkg.AccountingEntryTypeMerchantStoreCreditIssue)
+	entry.Amount = h.storeCredit.Amount
+	entry.OriginalAmount = h.storeCredit.Amount
+	entry.OriginalCurrency = h.storeCredit.Currency
+	entry.Reason = h.storeCredit.Reason
+
+	if entry.Currency != h.storeCredit.Currency {
+		var err error
+
+		entry.Amount, err = h.GetExchangeCurrentMerchant(&currencies.ExchangeCurrencyCurrentForMerchantRequest{
+			From:              h.storeCredit.Currency,
+			To:                entry.Currency,
+			RateType:          curPkg.RateTypePaysuper,
+			ExchangeDirection: curPkg.ExchangeDirectionBuy,
+			MerchantId:        h.merchant.Id,
+			Amount:            h.storeCredit.Amount,
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return h.addEntry(entry)
+}
+
+// processStoreCreditPaymentEvent creates entry of order paid with store credit. Such payment doesn't bring
+// money to payment system, so instead of revenue and fees entries it records redemption of credit of merchant.
+func (h *accountingEntry) processStoreCreditPaymentEvent() error {
+	if err := h.checkSourceEntriesNotCreated(h.order.Id, collectionOrder); err != nil {
+		return err
+	}
+
+	var err error
+
+	entry := h.newEntry(pkg.AccountingEntryTypeMerchantStoreCreditRedemption)
+	entry.Amount, err = h.GetExchangePsCurrentMerchant(h.order.ChargeCurrency, h.order.ChargeAmount)
+
+	if err != nil {
+		return err
+	}
+
+	entry.OriginalAmount = h.order.ChargeAmount
+	entry.OriginalCurrency = h.order.ChargeCurrency
+
+	return h.addEntry(entry)
+}
+
+// processStoreCreditRefundEvent creates entry of refund of order paid with store credit, refunded amount
+// returns to store credit of customer.
+func (h *accountingEntry) processStoreCreditRefundEvent() error {
+	if err := h.checkSourceEntriesNotCreated(h.refund.CreatedOrderId, collectionRefund); err != nil {
+		return err
+	}
+
+	if h.refund.Amount > h.order.ChargeAmount {
+		return accountingEntryErrorRefundExceedsOrderAmount
+	}
+
+	var err error
+
+	entry := h.newEntry(pkg.AccountingEntryTypeMerchantStoreCreditRefund)
+	entry.Amount, err = h.GetExchangePsCurrentMerchant(h.refund.Currency, h.refund.Amount)
+
+	if err != nil {
+		return err
+	}
+
+	entry.OriginalAmount = h.refund.Amount
+	entry.OriginalCurrency = h.refund.Currency
+	entry.Reason = h.refund.Reason
+
+	return h.addEntry(entry)
+}
+
+func (h *accountingEntry) checkSourceEntriesNotCreated(sourceId, sourceType string) error {
+	oid, _ := primitive.ObjectIDFromHex(sourceId)
+	query := bson.M{
+		"object":      pkg.ObjectTypeBalanceTransaction,
+		"source.id":   oid,
+		"source.type": sourceType,
+	}
+	count, err := h.db.Collection(collectionAccountingEntry).CountDocuments(h.ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionAccountingEntry),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationCount),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return err
+	}
+
+	if count > 0 {
+		return accountingEntryAlreadyCreated
+	}
+
+	return nil
+}
+
 func (h *accountingEntry) processPaymentEvent() error {
 	var (
 		amount float64
@@ -464,7 +748,8 @@ func (h *accountingEntry) processPaymentEvent() error {
 	// 8. merchantGrossRevenue
 	merchantGrossRevenue := h.newEntry(pkg.AccountingEntryTypeMerchantGrossRevenue)
 	merchantGrossRevenue.Amount = realGrossRevenue.Amount - psGrossRevenueFx.Amount
-	// not store in DB - calculated in order_view, but used further in the method code
+	// not store in DB - calculated in order_view, but used further in the method code and posted to ledger
+	h.addLedgerEntry(merchantGrossRevenue)
 
 	// 9. merchantTaxFeeCostValue
 	merchantTaxFeeCostValue := h.newEntry(pkg.AccountingEntryTypeMerchantTaxFeeCostValue)
@@ -744,7 +1029,10 @@ func (h *accountingEntry) processRefundEvent() error {
 	}
 
 	// 6. psMerchantRefundFx
-	// calculated in order_view
+	// calculated in order_view, posted to ledger only
+	psMerchantRefundFx := h.newEntry(pkg.AccountingEntryTypePsMerchantRefundFx)
+	psMerchantRefundFx.Amount = merchantRefund.Amount - realRefund.Amount
+	h.addLedgerEntry(psMerchantRefundFx)
 
 	// 7. merchantRefundFee
 	merchantRefundFee := h.newEntry(pkg.AccountingEntryTypeMerchantRefundFee)
@@ -1062,6 +1350,14 @@ func (h *accountingEntry) addEntry(entry *billing.AccountingEntry) error {
 	return nil
 }
 
+// addLedgerEntry adds entry which isn't stored in DB, because it's calculated in order_view, but is posted to ledger
+func (h *accountingEntry) addLedgerEntry(entry *billing.AccountingEntry) {
+	entry.Amount = tools.ToPrecise(entry.Amount)
+	h.ledgerEntries = append(h.ledgerEntries, entry)
+}
+
+// saveAccountingEntries inserts entries and posts them to ledger in the same step, entries are removed
+// if their postings can't be created or postings of their sources don't net to zero
 func (h *accountingEntry) saveAccountingEntries() error {
 	_, err := h.db.Collection(collectionAccountingEntry).InsertMany(h.ctx, h.accountingEntries)
 
@@ -1075,6 +1371,22 @@ func (h *accountingEntry) saveAccountingEntries() error {
 		return err
 	}
 
+	entries := make([]*billing.AccountingEntry, 0, len(h.accountingEntries)+len(h.ledgerEntries))
+
+	for _, v := range h.accountingEntries {
+		entries = append(entries, v.(*billing.AccountingEntry))
+	}
+
+	entries = append(entries, h.ledgerEntries...)
+
+	if err = h.Service.postLedgerEntries(h.ctx, entries); err != nil {
+		if err1 := h.rollbackAccountingEntries(entries); err1 != nil {
+			return err1
+		}
+
+		return err
+	}
+
 	var ids []string
 	var paylinks = map[string]string{}
 	if h.order != nil {
@@ -1110,6 +1422,38 @@ func (h *accountingEntry) saveAccountingEntries() error {
 	return nil
 }
 
+// rollbackAccountingEntries removes entries which weren't posted to ledger and their postings
+func (h *accountingEntry) rollbackAccountingEntries(entries []*billing.AccountingEntry) error {
+	var ids []string
+	var oids []primitive.ObjectID
+
+	for _, entry := range entries {
+		oid, _ := primitive.ObjectIDFromHex(entry.Id)
+		ids = append(ids, entry.Id)
+		oids = append(oids, oid)
+	}
+
+	query := bson.M{"_id": bson.M{"$in": oids}}
+	_, err := h.db.Collection(collectionAccountingEntry).DeleteMany(h.ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionAccountingEntry),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationDelete),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return ledgerErrorRollbackFailed
+	}
+
+	if err = h.Service.ledger.Delete(h.ctx, ids); err != nil {
+		return ledgerErrorRollbackFailed
+	}
+
+	return nil
+}
+
 func (h *accountingEntry) newEntry(entryType string) *billing.AccountingEntry {
 
 	var (
@@ -1386,3 +1730,63 @@ func (a Accounting) GetRollingReservesForRoyaltyReport(
 
 	return
 }
+
+// GetForExport returns page of accounting entries created in period sorted by identifier,
+// the page starts after entry with identifier specified by cursor
+func (a *Accounting) GetForExport(
+	ctx context.Context,
+	operatingCompanyId, merchantId string,
+	from, to time.Time,
+	cursor string,
+	limit int64,
+) ([]*billing.AccountingEntry, error) {
+	query := bson.M{
+		"operating_company_id": operatingCompanyId,
+		"created_at":           bson.M{"$gte": from, "$lte": to},
+	}
+
+	if merchantId != "" {
+		query["merchant_id"], _ = primitive.ObjectIDFromHex(merchantId)
+	}
+
+	if cursor != "" {
+		oid, err := primitive.ObjectIDFromHex(cursor)
+
+		if err != nil {
+			return nil, err
+		}
+
+		query["_id"] = bson.M{"$gt": oid}
+	}
+
+	sorts := bson.M{"_id": 1}
+	opts := options.Find().SetSort(sorts).SetLimit(limit)
+	res, err := a.svc.db.Collection(collectionAccountingEntry).Find(ctx, query, opts)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionAccountingEntry),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+			zap.Any(pkg.ErrorDatabaseFieldSorts, sorts),
+		)
+		return nil, err
+	}
+
+	var items []*billing.AccountingEntry
+	err = res.All(ctx, &items)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionAccountingEntry),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+			zap.Any(pkg.ErrorDatabaseFieldSorts, sorts),
+		)
+		return nil, err
+	}
+
+	return items, nil
+}
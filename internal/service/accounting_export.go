@@ -0,0 +1,358 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"go.uber.org/zap"
+	"strconv"
+	"time"
+)
+
+const (
+	accountingExportFormatCsv  = "csv"
+	accountingExportFormatSaft = "saft"
+
+	accountingExportDefaultLimit = 1000
+	accountingExportFileName     = "accounting_entries_%s_%s_%s.%s"
+
+	accountingExportSaftVersion   = "2.00"
+	accountingExportSaftSoftware  = "paysuper-billing-server"
+	accountingExportSaftOpening   = "<AuditFile>\n"
+	accountingExportSaftJournal   = "<GeneralLedgerEntries>\n<Journal>\n<JournalID>accounting_entries</JournalID>\n"
+	accountingExportSaftFooter    = "</Journal>\n</GeneralLedgerEntries>\n</AuditFile>\n"
+	accountingExportDateFormat    = "2006-01-02"
+	accountingExportRatePrecision = 6
+)
+
+var (
+	accountingExportErrorOperatingCompanyNotFound = newBillingServerErrorMsg("aex00001", "operating company not found")
+	accountingExportErrorFormatInvalid            = newBillingServerErrorMsg("aex00002", "export format is invalid")
+	accountingExportErrorUnknown                  = newBillingServerErrorMsg("aex00003", "unknown error. try request later")
+
+	accountingExportContentTypes = map[string]string{
+		accountingExportFormatCsv:  "text/csv",
+		accountingExportFormatSaft: "application/xml",
+	}
+	accountingExportFileExtensions = map[string]string{
+		accountingExportFormatCsv:  "csv",
+		accountingExportFormatSaft: "xml",
+	}
+
+	accountingExportCsvColumns = []string{
+		"id", "created_at", "type", "status", "source_type", "source_id", "operating_company_id", "merchant_id",
+		"country", "amount", "currency", "original_amount", "original_currency", "exchange_rate", "local_amount",
+		"local_currency", "local_exchange_rate", "debit_account", "credit_account", "reason",
+	}
+)
+
+type accountingExportSaftHeader struct {
+	XMLName              xml.Name                             `xml:"Header"`
+	AuditFileVersion     string                               `xml:"AuditFileVersion"`
+	AuditFileCountry     string                               `xml:"AuditFileCountry"`
+	AuditFileDateCreated string                               `xml:"AuditFileDateCreated"`
+	SoftwareID           string                               `xml:"SoftwareID"`
+	SoftwareVersion      string                               `xml:"SoftwareVersion"`
+	Company              *accountingExportSaftCompany         `xml:"Company"`
+	SelectionCriteria    *accountingExportSaftSelectionPeriod `xml:"SelectionCriteria"`
+}
+
+type accountingExportSaftCompany struct {
+	RegistrationNumber    string `xml:"RegistrationNumber"`
+	Name                  string `xml:"Name"`
+	Address               string `xml:"Address>FullAddress"`
+	TaxRegistrationNumber string `xml:"TaxRegistration>TaxRegistrationNumber"`
+}
+
+type accountingExportSaftSelectionPeriod struct {
+	SelectionStartDate string `xml:"SelectionStartDate"`
+	SelectionEndDate   string `xml:"SelectionEndDate"`
+	MerchantID         string `xml:"MerchantID,omitempty"`
+}
+
+type accountingExportSaftTransaction struct {
+	XMLName         xml.Name                    `xml:"Transaction"`
+	TransactionID   string                      `xml:"TransactionID"`
+	TransactionDate string                      `xml:"TransactionDate"`
+	TransactionType string                      `xml:"TransactionType"`
+	Description     string                      `xml:"Description"`
+	SystemEntryDate string                      `xml:"SystemEntryDate"`
+	SourceType      string                      `xml:"SourceType"`
+	SourceID        string                      `xml:"SourceID"`
+	MerchantID      string                      `xml:"MerchantID"`
+	Lines           []*accountingExportSaftLine `xml:"Line"`
+}
+
+type accountingExportSaftLine struct {
+	RecordID     string                      `xml:"RecordID"`
+	AccountID    string                      `xml:"AccountID"`
+	DebitAmount  *accountingExportSaftAmount `xml:"DebitAmount,omitempty"`
+	CreditAmount *accountingExportSaftAmount `xml:"CreditAmount,omitempty"`
+}
+
+type accountingExportSaftAmount struct {
+	Amount         string `xml:"Amount"`
+	CurrencyCode   string `xml:"CurrencyCode"`
+	CurrencyAmount string `xml:"CurrencyAmount"`
+	ExchangeRate   string `xml:"ExchangeRate"`
+}
+
+// ExportAccountingEntries exports accounting entries created in period for operating company and merchant.
+// Entries are exported by pages, file of the first page contains header and file of the last page contains footer
+// of export format, so the files of all pages must be concatenated in order to get complete export.
+func (s *Service) ExportAccountingEntries(
+	ctx context.Context,
+	req *grpc.ExportAccountingEntriesRequest,
+	rsp *grpc.ExportAccountingEntriesResponse,
+) error {
+	contentType, ok := accountingExportContentTypes[req.Format]
+
+	if !ok {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = accountingExportErrorFormatInvalid
+		return nil
+	}
+
+	oc, err := s.operatingCompany.GetById(ctx, req.OperatingCompanyId)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusNotFound
+		rsp.Message = accountingExportErrorOperatingCompanyNotFound
+		return nil
+	}
+
+	limit := int64(req.Limit)
+
+	if limit <= 0 {
+		limit = accountingExportDefaultLimit
+	}
+
+	from := time.Unix(req.DateFrom, 0)
+	to := time.Unix(req.DateTo, 0)
+
+	// one extra entry is requested to find out whether the page is the last
+	entries, err := s.accounting.GetForExport(ctx, oc.Id, req.MerchantId, from, to, req.Cursor, limit+1)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = accountingExportErrorUnknown
+		return nil
+	}
+
+	if int64(len(entries)) > limit {
+		entries = entries[:limit]
+		rsp.NextCursor = entries[len(entries)-1].Id
+	}
+
+	isFirstPage, isLastPage := req.Cursor == "", rsp.NextCursor == ""
+
+	if req.Format == accountingExportFormatSaft {
+		rsp.File, err = getAccountingEntriesSaft(oc, req, entries, isFirstPage, isLastPage)
+	} else {
+		rsp.File, err = getAccountingEntriesCsv(entries, isFirstPage)
+	}
+
+	if err != nil {
+		zap.L().Error(
+			"Accounting entries export failed",
+			zap.Error(err),
+			zap.String("format", req.Format),
+			zap.String("operating_company_id", req.OperatingCompanyId),
+			zap.String("cursor", req.Cursor),
+		)
+
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = accountingExportErrorUnknown
+		rsp.NextCursor = ""
+		return nil
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.ContentType = contentType
+	rsp.Count = int32(len(entries))
+	rsp.FileName = fmt.Sprintf(
+		accountingExportFileName,
+		oc.Id,
+		from.Format(accountingExportDateFormat),
+		to.Format(accountingExportDateFormat),
+		accountingExportFileExtensions[req.Format],
+	)
+
+	return nil
+}
+
+func getAccountingEntriesCsv(entries []*billing.AccountingEntry, withHeader bool) ([]byte, error) {
+	b := new(bytes.Buffer)
+	w := csv.NewWriter(b)
+
+	if withHeader {
+		if err := w.Write(accountingExportCsvColumns); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, entry := range entries {
+		debit, credit, _, err := getLedgerEntryAccounts(entry)
+
+		if err != nil {
+			return nil, err
+		}
+
+		createdAt, err := ptypes.Timestamp(entry.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		record := []string{
+			entry.Id,
+			createdAt.Format(time.RFC3339),
+			entry.Type,
+			entry.Status,
+			entry.Source.Type,
+			entry.Source.Id,
+			entry.OperatingCompanyId,
+			entry.MerchantId,
+			entry.Country,
+			strconv.FormatFloat(entry.Amount, 'f', 2, 64),
+			entry.Currency,
+			strconv.FormatFloat(entry.OriginalAmount, 'f', 2, 64),
+			entry.OriginalCurrency,
+			getAccountingExportRate(entry.Amount, entry.OriginalAmount),
+			strconv.FormatFloat(entry.LocalAmount, 'f', 2, 64),
+			entry.LocalCurrency,
+			getAccountingExportRate(entry.LocalAmount, entry.OriginalAmount),
+			debit,
+			credit,
+			entry.Reason,
+		}
+
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+
+	return b.Bytes(), w.Error()
+}
+
+func getAccountingEntriesSaft(
+	oc *billing.OperatingCompany,
+	req *grpc.ExportAccountingEntriesRequest,
+	entries []*billing.AccountingEntry,
+	withHeader, withFooter bool,
+) ([]byte, error) {
+	b := new(bytes.Buffer)
+	enc := xml.NewEncoder(b)
+	enc.Indent("", "  ")
+
+	if withHeader {
+		header := &accountingExportSaftHeader{
+			AuditFileVersion:     accountingExportSaftVersion,
+			AuditFileCountry:     oc.Country,
+			AuditFileDateCreated: time.Now().Format(accountingExportDateFormat),
+			SoftwareID:           accountingExportSaftSoftware,
+			SoftwareVersion:      pkg.ServiceVersion,
+			Company: &accountingExportSaftCompany{
+				RegistrationNumber:    oc.RegistrationNumber,
+				Name:                  oc.Name,
+				Address:               oc.Address,
+				TaxRegistrationNumber: oc.VatNumber,
+			},
+			SelectionCriteria: &accountingExportSaftSelectionPeriod{
+				SelectionStartDate: time.Unix(req.DateFrom, 0).Format(accountingExportDateFormat),
+				SelectionEndDate:   time.Unix(req.DateTo, 0).Format(accountingExportDateFormat),
+				MerchantID:         req.MerchantId,
+			},
+		}
+
+		b.WriteString(xml.Header)
+		b.WriteString(accountingExportSaftOpening)
+
+		if err := enc.Encode(header); err != nil {
+			return nil, err
+		}
+
+		b.WriteString("\n")
+		b.WriteString(accountingExportSaftJournal)
+	}
+
+	for _, entry := range entries {
+		transaction, err := getAccountingEntrySaftTransaction(entry)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err = enc.Encode(transaction); err != nil {
+			return nil, err
+		}
+
+		b.WriteString("\n")
+	}
+
+	if withFooter {
+		b.WriteString(accountingExportSaftFooter)
+	}
+
+	return b.Bytes(), nil
+}
+
+func getAccountingEntrySaftTransaction(entry *billing.AccountingEntry) (*accountingExportSaftTransaction, error) {
+	debit, credit, amount, err := getLedgerEntryAccounts(entry)
+
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt, err := ptypes.Timestamp(entry.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// amounts of lines are positive, sign of original amount is reversed with accounts
+	originalAmount := entry.OriginalAmount
+
+	if entry.Amount < 0 {
+		originalAmount = -originalAmount
+	}
+
+	lineAmount := &accountingExportSaftAmount{
+		Amount:         strconv.FormatFloat(amount, 'f', 2, 64),
+		CurrencyCode:   entry.OriginalCurrency,
+		CurrencyAmount: strconv.FormatFloat(originalAmount, 'f', 2, 64),
+		ExchangeRate:   getAccountingExportRate(entry.Amount, entry.OriginalAmount),
+	}
+
+	return &accountingExportSaftTransaction{
+		TransactionID:   entry.Id,
+		TransactionDate: createdAt.Format(accountingExportDateFormat),
+		TransactionType: entry.Type,
+		Description:     entry.Reason,
+		SystemEntryDate: createdAt.Format(time.RFC3339),
+		SourceType:      entry.Source.Type,
+		SourceID:        entry.Source.Id,
+		MerchantID:      entry.MerchantId,
+		Lines: []*accountingExportSaftLine{
+			{RecordID: entry.Id + "-1", AccountID: debit, DebitAmount: lineAmount},
+			{RecordID: entry.Id + "-2", AccountID: credit, CreditAmount: lineAmount},
+		},
+	}, nil
+}
+
+// getAccountingExportRate returns rate of exchange of original amount to amount
+func getAccountingExportRate(amount, originalAmount float64) string {
+	if originalAmount == 0 {
+		return ""
+	}
+
+	return strconv.FormatFloat(amount/originalAmount, 'f', accountingExportRatePrecision, 64)
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"github.com/go-redis/redis"
+	"github.com/golang-migrate/migrate/v4"
+	casbinMocks "github.com/paysuper/casbin-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/internal/config"
+	"github.com/paysuper/paysuper-billing-server/internal/database"
+	"github.com/paysuper/paysuper-billing-server/internal/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	reportingMocks "github.com/paysuper/paysuper-reporter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	rabbitmq "gopkg.in/ProtocolONE/rabbitmq.v1/pkg"
+	mongodb "gopkg.in/paysuper/paysuper-database-mongo.v1"
+	"testing"
+	"time"
+)
+
+type AccountingExportTestSuite struct {
+	suite.Suite
+	service *Service
+	cache   CacheInterface
+
+	project       *billing.Project
+	paymentMethod *billing.PaymentMethod
+}
+
+func Test_AccountingExport(t *testing.T) {
+	suite.Run(t, new(AccountingExportTestSuite))
+}
+
+func (suite *AccountingExportTestSuite) SetupTest() {
+	cfg, err := config.NewConfig()
+	if err != nil {
+		suite.FailNow("Config load failed", "%v", err)
+	}
+
+	m, err := migrate.New(
+		"file://../../migrations/tests",
+		cfg.MongoDsn)
+	assert.NoError(suite.T(), err, "Migrate init failed")
+
+	err = m.Up()
+	if err != nil && err.Error() != "no change" {
+		suite.FailNow("Migrations failed", "%v", err)
+	}
+
+	db, err := mongodb.NewDatabase()
+	if err != nil {
+		suite.FailNow("Database connection failed", "%v", err)
+	}
+
+	broker, err := rabbitmq.NewBroker(cfg.BrokerAddress)
+
+	if err != nil {
+		suite.FailNow("Creating RabbitMQ publisher failed", "%v", err)
+	}
+
+	redisClient := database.NewRedis(
+		&redis.Options{
+			Addr:     cfg.RedisHost,
+			Password: cfg.RedisPassword,
+		},
+	)
+
+	redisdb := mocks.NewTestRedis()
+	suite.cache, err = NewCacheRedis(redisdb, "cache")
+	suite.service = NewBillingService(
+		db,
+		cfg,
+		mocks.NewGeoIpServiceTestOk(),
+		mocks.NewRepositoryServiceOk(),
+		mocks.NewTaxServiceOkMock(),
+		broker,
+		redisClient,
+		suite.cache,
+		mocks.NewCurrencyServiceMockOk(),
+		mocks.NewDocumentSignerMockOk(),
+		&reportingMocks.ReporterService{},
+		mocks.NewFormatterOK(),
+		broker,
+		&casbinMocks.CasbinService{},
+	)
+
+	if err := suite.service.Init(); err != nil {
+		suite.FailNow("Billing service initialization failed", "%v", err)
+	}
+
+	_, suite.project, suite.paymentMethod, _ = helperCreateEntitiesForTests(suite.Suite, suite.service)
+}
+
+func (suite *AccountingExportTestSuite) TearDownTest() {
+	if err := suite.service.db.Drop(); err != nil {
+		suite.FailNow("Database deletion failed", "%v", err)
+	}
+
+	if err := suite.service.db.Close(); err != nil {
+		suite.FailNow("Database close failed", "%v", err)
+	}
+}
+
+func (suite *AccountingExportTestSuite) export(req *grpc.ExportAccountingEntriesRequest) ([]byte, int) {
+	var file []byte
+	pages := 0
+
+	for {
+		rsp := &grpc.ExportAccountingEntriesResponse{}
+		err := suite.service.ExportAccountingEntries(context.TODO(), req, rsp)
+		assert.NoError(suite.T(), err)
+		assert.Equalf(suite.T(), pkg.ResponseStatusOk, rsp.Status, "%v", rsp.Message)
+
+		file = append(file, rsp.File...)
+		pages++
+
+		if rsp.NextCursor == "" || rsp.Status != pkg.ResponseStatusOk {
+			break
+		}
+
+		req.Cursor = rsp.NextCursor
+	}
+
+	return file, pages
+}
+
+func (suite *AccountingExportTestSuite) TestAccountingExport_Csv_Ok() {
+	order := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+	assert.NotNil(suite.T(), order)
+
+	query := bson.M{"operating_company_id": order.OperatingCompanyId}
+	count, err := suite.service.db.Collection(collectionAccountingEntry).CountDocuments(context.TODO(), query)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), count > 5)
+
+	req := &grpc.ExportAccountingEntriesRequest{
+		Format:             accountingExportFormatCsv,
+		OperatingCompanyId: order.OperatingCompanyId,
+		MerchantId:         order.GetMerchantId(),
+		DateFrom:           time.Now().Add(-time.Hour).Unix(),
+		DateTo:             time.Now().Add(time.Hour).Unix(),
+		Limit:              5,
+	}
+	file, pages := suite.export(req)
+	assert.EqualValues(suite.T(), (count+4)/5, pages)
+
+	records, err := csv.NewReader(bytes.NewReader(file)).ReadAll()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), records, int(count)+1)
+	assert.Equal(suite.T(), accountingExportCsvColumns, records[0])
+
+	ids := make(map[string]bool)
+
+	for _, record := range records[1:] {
+		assert.False(suite.T(), ids[record[0]])
+		ids[record[0]] = true
+
+		assert.Equal(suite.T(), collectionOrder, record[4])
+		assert.Equal(suite.T(), order.Id, record[5])
+		assert.NotEmpty(suite.T(), record[17])
+		assert.NotEmpty(suite.T(), record[18])
+	}
+}
+
+func (suite *AccountingExportTestSuite) TestAccountingExport_Saft_Ok() {
+	order := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+	assert.NotNil(suite.T(), order)
+
+	req := &grpc.ExportAccountingEntriesRequest{
+		Format:             accountingExportFormatSaft,
+		OperatingCompanyId: order.OperatingCompanyId,
+		DateFrom:           time.Now().Add(-time.Hour).Unix(),
+		DateTo:             time.Now().Add(time.Hour).Unix(),
+		Limit:              3,
+	}
+	file, pages := suite.export(req)
+	assert.True(suite.T(), pages > 1)
+
+	audit := &struct {
+		Header       *accountingExportSaftHeader        `xml:"Header"`
+		Transactions []*accountingExportSaftTransaction `xml:"GeneralLedgerEntries>Journal>Transaction"`
+	}{}
+	err := xml.Unmarshal(file, audit)
+	assert.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), audit.Header)
+	assert.NotEmpty(suite.T(), audit.Transactions)
+
+	for _, transaction := range audit.Transactions {
+		assert.Equal(suite.T(), order.Id, transaction.SourceID)
+		assert.Len(suite.T(), transaction.Lines, 2)
+		assert.NotNil(suite.T(), transaction.Lines[0].DebitAmount)
+		assert.NotNil(suite.T(), transaction.Lines[1].CreditAmount)
+		assert.Equal(suite.T(), transaction.Lines[0].DebitAmount.Amount, transaction.Lines[1].CreditAmount.Amount)
+	}
+}
+
+func (suite *AccountingExportTestSuite) TestAccountingExport_Error_OperatingCompanyNotFound() {
+	req := &grpc.ExportAccountingEntriesRequest{
+		Format:             accountingExportFormatCsv,
+		OperatingCompanyId: "ffffffffffffffffffffffff",
+		DateFrom:           time.Now().Add(-time.Hour).Unix(),
+		DateTo:             time.Now().Unix(),
+	}
+	rsp := &grpc.ExportAccountingEntriesResponse{}
+	err := suite.service.ExportAccountingEntries(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusNotFound, rsp.Status)
+	assert.Equal(suite.T(), accountingExportErrorOperatingCompanyNotFound, rsp.Message)
+}
@@ -0,0 +1,443 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/constant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	collectionBulkRefundJob = "bulk_refund_job"
+
+	// maximal count of orders which can be refunded by one bulk refund job
+	bulkRefundMaxOrders = 1000
+	// time during which bulk refund job locked by daemon for processing
+	bulkRefundLockTimeout = 5 * time.Minute
+	// maximal count of bulk refund jobs processed by one run of daemon
+	bulkRefundJobBatchSize = 10
+
+	// counter of refunds sent by bulk refund jobs of all instances of service in the second
+	bulkRefundRateLimitStorageKey = "bulk_refund:rate_limit:%d"
+	// lock which allows to process bulk refund jobs by one instance of service at the same time
+	bulkRefundDaemonLockStorageKey = "bulk_refund:daemon_lock"
+)
+
+var (
+	bulkRefundErrorNotFound       = newBillingServerErrorMsg("brf00001", "bulk refund job not found")
+	bulkRefundErrorOrdersRequired = newBillingServerErrorMsg("brf00002", "order identifiers or orders filter required")
+	bulkRefundErrorOrdersNotFound = newBillingServerErrorMsg("brf00003", "orders to refund not found")
+	bulkRefundErrorTooManyOrders  = newBillingServerErrorMsg("brf00004", "too many orders to refund by one job")
+	bulkRefundErrorOrderNotFound  = newBillingServerErrorMsg("brf00005", "order not found")
+)
+
+type BulkRefundJobRepositoryInterface interface {
+	Insert(ctx context.Context, job *billing.BulkRefundJob) error
+	Update(ctx context.Context, job *billing.BulkRefundJob) error
+	GetById(ctx context.Context, id string) (*billing.BulkRefundJob, error)
+	// LockForProcessing finds not completed bulk refund job which isn't locked by other daemon,
+	// locks it for processing on bulkRefundLockTimeout and returns it. Returns nil if job not found.
+	LockForProcessing(ctx context.Context) (*billing.BulkRefundJob, error)
+}
+
+func newBulkRefundJobRepository(svc *Service) BulkRefundJobRepositoryInterface {
+	s := &BulkRefundJob{svc: svc}
+	return s
+}
+
+func (s *Service) CreateBulkRefund(
+	ctx context.Context,
+	req *grpc.CreateBulkRefundRequest,
+	rsp *grpc.BulkRefundJobResponse,
+) error {
+	orderIds := req.OrderIds
+
+	if len(orderIds) <= 0 {
+		if req.Filter == nil {
+			rsp.Status = pkg.ResponseStatusBadData
+			rsp.Message = bulkRefundErrorOrdersRequired
+			return nil
+		}
+
+		var err error
+		orderIds, err = s.getBulkRefundOrderIds(ctx, req.MerchantId, req.Filter)
+
+		if err != nil {
+			rsp.Status = pkg.ResponseStatusSystemError
+			rsp.Message = reportErrorUnknown
+
+			if e, ok := err.(*grpc.ResponseErrorMessage); ok {
+				rsp.Status = pkg.ResponseStatusBadData
+				rsp.Message = e
+			}
+
+			return nil
+		}
+	}
+
+	job := &billing.BulkRefundJob{
+		Id:         primitive.NewObjectID().Hex(),
+		MerchantId: req.MerchantId,
+		CreatorId:  req.CreatorId,
+		Reason:     req.Reason,
+		Status:     pkg.BulkRefundJobStatusPending,
+		CreatedAt:  ptypes.TimestampNow(),
+		UpdatedAt:  ptypes.TimestampNow(),
+	}
+
+	added := make(map[string]bool)
+
+	for _, v := range orderIds {
+		if added[v] {
+			continue
+		}
+
+		added[v] = true
+		job.Items = append(job.Items, &billing.BulkRefundJobItem{
+			OrderId: v,
+			Status:  pkg.BulkRefundJobItemStatusPending,
+		})
+	}
+
+	if len(job.Items) <= 0 {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = bulkRefundErrorOrdersNotFound
+		return nil
+	}
+
+	job.Total = int32(len(job.Items))
+
+	if err := s.bulkRefundJob.Insert(ctx, job); err != nil {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = refundErrorUnknown
+		return nil
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = job
+
+	return nil
+}
+
+func (s *Service) GetBulkRefundJob(
+	ctx context.Context,
+	req *grpc.GetBulkRefundJobRequest,
+	rsp *grpc.BulkRefundJobResponse,
+) error {
+	job, err := s.bulkRefundJob.GetById(ctx, req.JobId)
+
+	if err != nil || job.MerchantId != req.MerchantId {
+		rsp.Status = pkg.ResponseStatusNotFound
+		rsp.Message = bulkRefundErrorNotFound
+		return nil
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = job
+
+	return nil
+}
+
+// ProcessBulkRefundJobs creates refunds of orders of not completed bulk refund jobs
+// and returns count of processed jobs. Jobs are processed by one instance of service at the same time.
+func (s *Service) ProcessBulkRefundJobs(ctx context.Context) (int, error) {
+	count := 0
+
+	if s.redis != nil {
+		locked, err := s.redis.SetNX(bulkRefundDaemonLockStorageKey, time.Now().Unix(), bulkRefundLockTimeout).Result()
+
+		if err != nil {
+			return count, err
+		}
+
+		if !locked {
+			return count, nil
+		}
+
+		defer s.redis.Del(bulkRefundDaemonLockStorageKey)
+	}
+
+	for count < bulkRefundJobBatchSize {
+		job, err := s.bulkRefundJob.LockForProcessing(ctx)
+
+		if err != nil {
+			return count, err
+		}
+
+		if job == nil {
+			break
+		}
+
+		if err = s.processBulkRefundJob(ctx, job); err != nil {
+			return count, err
+		}
+
+		count++
+	}
+
+	return count, nil
+}
+
+// getBulkRefundOrderIds returns uuids of merchant orders from order_view selected by filter,
+// only processed orders are selected if filter hasn't statuses.
+func (s *Service) getBulkRefundOrderIds(
+	ctx context.Context,
+	merchantId string,
+	filter *grpc.ListOrdersRequest,
+) ([]string, error) {
+	filter.Merchant = []string{merchantId}
+	filter.Limit = bulkRefundMaxOrders
+	filter.Offset = 0
+
+	if len(filter.Status) <= 0 {
+		filter.Status = []string{constant.OrderPublicStatusProcessed}
+	}
+
+	count, orders, err := s.getOrdersList(ctx, filter, collectionOrderView, make([]*billing.OrderViewPublic, 1))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if count > bulkRefundMaxOrders {
+		return nil, bulkRefundErrorTooManyOrders
+	}
+
+	var orderIds []string
+
+	for _, v := range orders.([]*billing.OrderViewPublic) {
+		orderIds = append(orderIds, v.Uuid)
+	}
+
+	return orderIds, nil
+}
+
+// processBulkRefundJob creates refunds of not processed orders of bulk refund job with saving of progress
+// after every order, refunds are sent to payment system not more often than allowed by rate limit.
+func (s *Service) processBulkRefundJob(ctx context.Context, job *billing.BulkRefundJob) error {
+	for _, item := range job.Items {
+		if item.Status != pkg.BulkRefundJobItemStatusPending {
+			continue
+		}
+
+		if err := s.waitBulkRefundRateLimit(ctx); err != nil {
+			return err
+		}
+
+		s.processBulkRefundJobItem(ctx, job, item)
+
+		job.Processed++
+
+		if item.Status == pkg.BulkRefundJobItemStatusCreated {
+			job.Succeeded++
+		} else {
+			job.Failed++
+		}
+
+		job.LockedUntil, _ = ptypes.TimestampProto(time.Now().Add(bulkRefundLockTimeout))
+		job.UpdatedAt = ptypes.TimestampNow()
+
+		if err := s.bulkRefundJob.Update(ctx, job); err != nil {
+			return err
+		}
+
+		if s.redis != nil {
+			s.redis.Expire(bulkRefundDaemonLockStorageKey, bulkRefundLockTimeout)
+		}
+	}
+
+	job.Status = pkg.BulkRefundJobStatusCompleted
+	job.LockedUntil = nil
+	job.CompletedAt = ptypes.TimestampNow()
+	job.UpdatedAt = ptypes.TimestampNow()
+
+	return s.bulkRefundJob.Update(ctx, job)
+}
+
+// waitBulkRefundRateLimit waits until refund can be sent to payment system without exceeding of rate limit.
+// Rate limit is shared by all instances of service and counted in redis per second.
+func (s *Service) waitBulkRefundRateLimit(ctx context.Context) error {
+	if s.cfg.BulkRefundRateLimit <= 0 {
+		return nil
+	}
+
+	if s.redis == nil {
+		time.Sleep(s.cfg.GetBulkRefundDelay())
+		return nil
+	}
+
+	for {
+		now := time.Now()
+		key := fmt.Sprintf(bulkRefundRateLimitStorageKey, now.Unix())
+		count, err := s.redis.Incr(key).Result()
+
+		if err != nil {
+			return err
+		}
+
+		if count == 1 {
+			s.redis.Expire(key, 2*time.Second)
+		}
+
+		if count <= s.cfg.BulkRefundRateLimit {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(now.Truncate(time.Second).Add(time.Second).Sub(now)):
+		}
+	}
+}
+
+func (s *Service) processBulkRefundJobItem(ctx context.Context, job *billing.BulkRefundJob, item *billing.BulkRefundJobItem) {
+	item.ProcessedAt = ptypes.TimestampNow()
+	item.Status = pkg.BulkRefundJobItemStatusFailed
+
+	order, err := s.getOrderByUuid(ctx, item.OrderId)
+
+	if err != nil || order.GetMerchantId() != job.MerchantId {
+		item.Error = bulkRefundErrorOrderNotFound.Message
+		return
+	}
+
+	item.Amount = order.ChargeAmount
+	item.Currency = order.ChargeCurrency
+
+	req := &grpc.CreateRefundRequest{
+		OrderId:    order.Uuid,
+		Amount:     order.ChargeAmount,
+		CreatorId:  job.CreatorId,
+		Reason:     job.Reason,
+		MerchantId: job.MerchantId,
+	}
+	rsp := &grpc.CreateRefundResponse{}
+	err = s.createRefund(ctx, req, rsp)
+
+	if err != nil {
+		zap.L().Error(
+			"bulk refund of order failed",
+			zap.Error(err),
+			zap.String("job_id", job.Id),
+			zap.String("order_uuid", item.OrderId),
+		)
+		item.Error = refundErrorUnknown.Message
+		return
+	}
+
+	if rsp.Status != pkg.ResponseStatusOk {
+		item.Error = rsp.Message.Message
+		return
+	}
+
+	item.Status = pkg.BulkRefundJobItemStatusCreated
+	item.RefundId = rsp.Item.Id
+	item.Error = ""
+}
+
+func (h *BulkRefundJob) Insert(ctx context.Context, job *billing.BulkRefundJob) error {
+	_, err := h.svc.db.Collection(collectionBulkRefundJob).InsertOne(ctx, job)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionBulkRefundJob),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationInsert),
+			zap.Any(pkg.ErrorDatabaseFieldDocument, job),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func (h *BulkRefundJob) Update(ctx context.Context, job *billing.BulkRefundJob) error {
+	oid, _ := primitive.ObjectIDFromHex(job.Id)
+	filter := bson.M{"_id": oid}
+	_, err := h.svc.db.Collection(collectionBulkRefundJob).ReplaceOne(ctx, filter, job)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionBulkRefundJob),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationUpdate),
+			zap.Any(pkg.ErrorDatabaseFieldDocument, job),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func (h *BulkRefundJob) GetById(ctx context.Context, id string) (*billing.BulkRefundJob, error) {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	filter := bson.M{"_id": oid}
+	job := &billing.BulkRefundJob{}
+	err := h.svc.db.Collection(collectionBulkRefundJob).FindOne(ctx, filter).Decode(job)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, bulkRefundErrorNotFound
+		}
+
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionBulkRefundJob),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, filter),
+		)
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (h *BulkRefundJob) LockForProcessing(ctx context.Context) (*billing.BulkRefundJob, error) {
+	query := bson.M{
+		"status":       bson.M{"$in": []string{pkg.BulkRefundJobStatusPending, pkg.BulkRefundJobStatusProcessing}},
+		"locked_until": bson.M{"$lte": time.Now()},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":       pkg.BulkRefundJobStatusProcessing,
+			"locked_until": time.Now().Add(bulkRefundLockTimeout),
+			"updated_at":   time.Now(),
+		},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.M{"created_at": 1}).
+		SetReturnDocument(options.After)
+
+	job := &billing.BulkRefundJob{}
+	err := h.svc.db.Collection(collectionBulkRefundJob).FindOneAndUpdate(ctx, query, update, opts).Decode(job)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionBulkRefundJob),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationUpdate),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+			zap.Any(pkg.ErrorDatabaseFieldSet, update),
+		)
+		return nil, err
+	}
+
+	return job, nil
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"github.com/go-redis/redis"
+	"github.com/golang-migrate/migrate/v4"
+	casbinMocks "github.com/paysuper/casbin-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/internal/config"
+	"github.com/paysuper/paysuper-billing-server/internal/database"
+	"github.com/paysuper/paysuper-billing-server/internal/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	reportingMocks "github.com/paysuper/paysuper-reporter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	rabbitmq "gopkg.in/ProtocolONE/rabbitmq.v1/pkg"
+	mongodb "gopkg.in/paysuper/paysuper-database-mongo.v1"
+	"testing"
+	"time"
+)
+
+type BulkRefundTestSuite struct {
+	suite.Suite
+	service *Service
+	cache   CacheInterface
+
+	project       *billing.Project
+	paymentMethod *billing.PaymentMethod
+	userId        string
+}
+
+func Test_BulkRefund(t *testing.T) {
+	suite.Run(t, new(BulkRefundTestSuite))
+}
+
+func (suite *BulkRefundTestSuite) SetupTest() {
+	cfg, err := config.NewConfig()
+	if err != nil {
+		suite.FailNow("Config load failed", "%v", err)
+	}
+	cfg.CardPay.ApiUrl = "https://sandbox.cardpay.com"
+	cfg.BulkRefundRateLimit = 1000
+
+	m, err := migrate.New(
+		"file://../../migrations/tests",
+		cfg.MongoDsn)
+	assert.NoError(suite.T(), err, "Migrate init failed")
+
+	err = m.Up()
+	if err != nil && err.Error() != "no change" {
+		suite.FailNow("Migrations failed", "%v", err)
+	}
+
+	db, err := mongodb.NewDatabase()
+	if err != nil {
+		suite.FailNow("Database connection failed", "%v", err)
+	}
+
+	broker, err := rabbitmq.NewBroker(cfg.BrokerAddress)
+
+	if err != nil {
+		suite.FailNow("Creating RabbitMQ publisher failed", "%v", err)
+	}
+
+	redisClient := database.NewRedis(
+		&redis.Options{
+			Addr:     cfg.RedisHost,
+			Password: cfg.RedisPassword,
+		},
+	)
+
+	redisdb := mocks.NewTestRedis()
+	suite.cache, err = NewCacheRedis(redisdb, "cache")
+	suite.service = NewBillingService(
+		db,
+		cfg,
+		mocks.NewGeoIpServiceTestOk(),
+		mocks.NewRepositoryServiceOk(),
+		mocks.NewTaxServiceOkMock(),
+		broker,
+		redisClient,
+		suite.cache,
+		mocks.NewCurrencyServiceMockOk(),
+		mocks.NewDocumentSignerMockOk(),
+		&reportingMocks.ReporterService{},
+		mocks.NewFormatterOK(),
+		broker,
+		&casbinMocks.CasbinService{},
+	)
+
+	if err := suite.service.Init(); err != nil {
+		suite.FailNow("Billing service initialization failed", "%v", err)
+	}
+
+	_, suite.project, suite.paymentMethod, _ = helperCreateEntitiesForTests(suite.Suite, suite.service)
+	suite.userId = primitive.NewObjectID().Hex()
+}
+
+func (suite *BulkRefundTestSuite) TearDownTest() {
+	if err := suite.service.db.Drop(); err != nil {
+		suite.FailNow("Database deletion failed", "%v", err)
+	}
+
+	if err := suite.service.db.Close(); err != nil {
+		suite.FailNow("Database close failed", "%v", err)
+	}
+}
+
+func (suite *BulkRefundTestSuite) TestBulkRefund_CreateBulkRefund_ByOrderIds_Ok() {
+	order1 := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+	order2 := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+	orderIds := []string{order1.Uuid, order2.Uuid, order1.Uuid, "1cfb7b33-0d3e-4d2c-a7bd-5f7c0a7d2d1b"}
+
+	job := suite.createBulkRefund(&grpc.CreateBulkRefundRequest{OrderIds: orderIds})
+	assert.Equal(suite.T(), pkg.BulkRefundJobStatusPending, job.Status)
+	assert.EqualValues(suite.T(), 3, job.Total)
+	assert.Len(suite.T(), job.Items, 3)
+
+	count, err := suite.service.ProcessBulkRefundJobs(context.TODO())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, count)
+
+	job = suite.getBulkRefundJob(job.Id)
+	assert.Equal(suite.T(), pkg.BulkRefundJobStatusCompleted, job.Status)
+	assert.NotNil(suite.T(), job.CompletedAt)
+	assert.EqualValues(suite.T(), 3, job.Processed)
+	assert.EqualValues(suite.T(), 2, job.Succeeded)
+	assert.EqualValues(suite.T(), 1, job.Failed)
+
+	for _, v := range job.Items[:2] {
+		assert.Equal(suite.T(), pkg.BulkRefundJobItemStatusCreated, v.Status)
+		assert.NotEmpty(suite.T(), v.RefundId)
+		assert.Empty(suite.T(), v.Error)
+
+		refund, err := suite.service.getRefundById(context.TODO(), v.RefundId)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), v.OrderId, refund.OriginalOrder.Uuid)
+		assert.Equal(suite.T(), v.Amount, refund.Amount)
+		assert.Equal(suite.T(), "broken key batch", refund.Reason)
+	}
+
+	assert.Equal(suite.T(), pkg.BulkRefundJobItemStatusFailed, job.Items[2].Status)
+	assert.Equal(suite.T(), bulkRefundErrorOrderNotFound.Message, job.Items[2].Error)
+
+	count, err = suite.service.ProcessBulkRefundJobs(context.TODO())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count)
+}
+
+func (suite *BulkRefundTestSuite) TestBulkRefund_CreateBulkRefund_ByFilter_Ok() {
+	order1 := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+	order2 := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+
+	job := suite.createBulkRefund(&grpc.CreateBulkRefundRequest{
+		Filter: &grpc.ListOrdersRequest{Project: []string{suite.project.Id}},
+	})
+	assert.EqualValues(suite.T(), 2, job.Total)
+
+	var orderIds []string
+
+	for _, v := range job.Items {
+		orderIds = append(orderIds, v.OrderId)
+	}
+
+	assert.Contains(suite.T(), orderIds, order1.Uuid)
+	assert.Contains(suite.T(), orderIds, order2.Uuid)
+
+	_, err := suite.service.ProcessBulkRefundJobs(context.TODO())
+	assert.NoError(suite.T(), err)
+
+	job = suite.getBulkRefundJob(job.Id)
+	assert.EqualValues(suite.T(), 2, job.Succeeded)
+	assert.EqualValues(suite.T(), 0, job.Failed)
+}
+
+func (suite *BulkRefundTestSuite) TestBulkRefund_CreateBulkRefund_OrdersRequired_Error() {
+	req := &grpc.CreateBulkRefundRequest{
+		MerchantId: suite.project.MerchantId,
+		CreatorId:  suite.userId,
+		Reason:     "broken key batch",
+	}
+	rsp := &grpc.BulkRefundJobResponse{}
+	err := suite.service.CreateBulkRefund(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), bulkRefundErrorOrdersRequired, rsp.Message)
+
+	req.Filter = &grpc.ListOrdersRequest{Project: []string{suite.project.Id}}
+	err = suite.service.CreateBulkRefund(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), bulkRefundErrorOrdersNotFound, rsp.Message)
+}
+
+func (suite *BulkRefundTestSuite) TestBulkRefund_GetBulkRefundJob_OtherMerchant_Error() {
+	order := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+	job := suite.createBulkRefund(&grpc.CreateBulkRefundRequest{OrderIds: []string{order.Uuid}})
+
+	req := &grpc.GetBulkRefundJobRequest{
+		MerchantId: primitive.NewObjectID().Hex(),
+		JobId:      job.Id,
+	}
+	rsp := &grpc.BulkRefundJobResponse{}
+	err := suite.service.GetBulkRefundJob(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusNotFound, rsp.Status)
+	assert.Equal(suite.T(), bulkRefundErrorNotFound, rsp.Message)
+}
+
+func (suite *BulkRefundTestSuite) TestBulkRefund_waitBulkRefundRateLimit_Ok() {
+	suite.service.cfg.BulkRefundRateLimit = 2
+	start := time.Now()
+
+	for i := 0; i < 3; i++ {
+		err := suite.service.waitBulkRefundRateLimit(context.TODO())
+		assert.NoError(suite.T(), err)
+	}
+
+	assert.True(suite.T(), time.Now().Unix() > start.Unix())
+}
+
+func (suite *BulkRefundTestSuite) TestBulkRefund_ProcessBulkRefundJobs_Locked() {
+	err := suite.service.redis.Set(bulkRefundDaemonLockStorageKey, 1, time.Minute).Err()
+	assert.NoError(suite.T(), err)
+
+	order := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+	job := suite.createBulkRefund(&grpc.CreateBulkRefundRequest{OrderIds: []string{order.Uuid}})
+
+	count, err := suite.service.ProcessBulkRefundJobs(context.TODO())
+	assert.NoError(suite.T(), err)
+	assert.Zero(suite.T(), count)
+	assert.Equal(suite.T(), pkg.BulkRefundJobStatusPending, suite.getBulkRefundJob(job.Id).Status)
+
+	err = suite.service.redis.Del(bulkRefundDaemonLockStorageKey).Err()
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *BulkRefundTestSuite) createBulkRefund(req *grpc.CreateBulkRefundRequest) *billing.BulkRefundJob {
+	req.MerchantId = suite.project.MerchantId
+	req.CreatorId = suite.userId
+	req.Reason = "broken key batch"
+
+	rsp := &grpc.BulkRefundJobResponse{}
+	err := suite.service.CreateBulkRefund(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	return rsp.Item
+}
+
+func (suite *BulkRefundTestSuite) getBulkRefundJob(id string) *billing.BulkRefundJob {
+	req := &grpc.GetBulkRefundJobRequest{
+		MerchantId: suite.project.MerchantId,
+		JobId:      id,
+	}
+	rsp := &grpc.BulkRefundJobResponse{}
+	err := suite.service.GetBulkRefundJob(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	return rsp.Item
+}
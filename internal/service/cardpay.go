@@ -7,13 +7,14 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/paysuper/paysuper-billing-server/pkg"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
-	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
 	"github.com/paysuper/paysuper-recurring-repository/pkg/constant"
 	"github.com/paysuper/paysuper-recurring-repository/tools"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
 	"io/ioutil"
 	"net/http"
@@ -30,14 +31,20 @@ const (
 	cardPayRequestFieldPassword     = "password"
 	cardPayRequestFieldRefreshToken = "refresh_token"
 
+	cardPayRequestFieldRequestId       = "request_id"
+	cardPayRequestFieldMerchantOrderId = "merchant_order_id"
+
 	cardPayGrantTypePassword     = "password"
 	cardPayGrantTypeRefreshToken = "refresh_token"
 
 	cardPayDateFormat          = "2006-01-02T15:04:05Z"
 	cardPayInitiatorCardholder = "cit"
+	cardPayInitiatorMerchant   = "mit"
 
 	cardPayMaxItemNameLength        = 50
 	cardPayMaxItemDescriptionLength = 200
+
+	cardPayOperationChangeStatus = "CHANGE_STATUS"
 )
 
 var (
@@ -95,6 +102,7 @@ type CardPayPaymentData struct {
 	Amount     float64 `json:"amount"`
 	Descriptor string  `json:"dynamic_descriptor"`
 	Note       string  `json:"note"`
+	Preauth    bool    `json:"preauth,omitempty"`
 }
 
 type CardPayRecurringData struct {
@@ -104,6 +112,7 @@ type CardPayRecurringData struct {
 	Descriptor string                      `json:"dynamic_descriptor"`
 	Note       string                      `json:"note"`
 	Initiator  string                      `json:"initiator"`
+	Preauth    bool                        `json:"preauth,omitempty"`
 }
 
 type CardPayCustomer struct {
@@ -232,6 +241,34 @@ type CardPayRefundResponse struct {
 	EwalletAccount interface{}                       `json:"ewallet_account,omitempty"`
 }
 
+type CardPayChangeStatusPaymentData struct {
+	StatusTo string  `json:"status_to"`
+	Amount   float64 `json:"amount,omitempty"`
+}
+
+type CardPayChangeStatusRequest struct {
+	Request     *CardPayRequest                 `json:"request"`
+	Operation   string                          `json:"operation"`
+	PaymentData *CardPayChangeStatusPaymentData `json:"payment_data"`
+}
+
+type CardPayChangeStatusResponsePaymentData struct {
+	Id              string  `json:"id"`
+	Status          string  `json:"status"`
+	RemainingAmount float64 `json:"remaining_amount"`
+}
+
+type CardPayChangeStatusResponse struct {
+	IsExecuted  bool                                    `json:"is_executed"`
+	Details     string                                  `json:"details"`
+	PaymentData *CardPayChangeStatusResponsePaymentData `json:"payment_data"`
+}
+
+type CardPayPaymentListResponse struct {
+	Data    []*billing.CardPayPaymentCallback `json:"data"`
+	HasMore bool                              `json:"has_more"`
+}
+
 func (m *CardPayRefundResponse) IsSuccessStatus() bool {
 	v, ok := successRefundResponseStatuses[m.RefundData.Status]
 	return ok && v == true
@@ -246,6 +283,30 @@ func newCardPayHandler() PaymentSystem {
 	}
 }
 
+func parseCardPayPaymentCallback(raw []byte) (proto.Message, error) {
+	data := &billing.CardPayPaymentCallback{}
+
+	if err := json.Unmarshal(raw, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func parseCardPayRefundCallback(raw []byte) (proto.Message, string, error) {
+	data := &billing.CardPayRefundCallback{}
+
+	if err := json.Unmarshal(raw, data); err != nil {
+		return nil, "", err
+	}
+
+	if data.RefundData == nil || data.MerchantOrder == nil {
+		return nil, "", errors.New(callbackRequestIncorrect)
+	}
+
+	return data, data.MerchantOrder.Id, nil
+}
+
 func (h *cardPay) CreatePayment(
 	order *billing.Order,
 	successUrl, failUrl string,
@@ -381,13 +442,13 @@ func (h *cardPay) CreatePayment(
 
 func (h *cardPay) ProcessPayment(order *billing.Order, message proto.Message, raw, signature string) error {
 	req := message.(*billing.CardPayPaymentCallback)
-	order.PrivateStatus = constant.OrderStatusPaymentSystemReject
-	err := h.checkCallbackRequestSignature(order, raw, signature)
 
-	if err != nil {
-		return err
+	if req.IsPaymentPendingStatus() {
+		return newBillingServerResponseError(pkg.StatusTemporary, paymentSystemErrorRequestTemporarySkipped)
 	}
 
+	order.PrivateStatus = constant.OrderStatusPaymentSystemReject
+
 	if !req.IsPaymentAllowedStatus() {
 		return newBillingServerResponseError(pkg.StatusErrorValidation, paymentSystemErrorRequestStatusIsInvalid)
 	}
@@ -414,7 +475,7 @@ func (h *cardPay) ProcessPayment(order *billing.Order, message proto.Message, ra
 
 	reqAmount := req.GetAmount()
 
-	if reqAmount != order.ChargeAmount ||
+	if reqAmount != order.GetPaymentNotificationAmount() ||
 		req.GetCurrency() != order.ChargeCurrency {
 		return newBillingServerResponseError(pkg.StatusErrorValidation, paymentSystemErrorRequestAmountOrCurrencyIsInvalid)
 	}
@@ -442,7 +503,7 @@ func (h *cardPay) ProcessPayment(order *billing.Order, message proto.Message, ra
 	case pkg.CardPayPaymentResponseStatusDeclined:
 		order.PrivateStatus = constant.OrderStatusPaymentSystemDeclined
 		break
-	case pkg.CardPayPaymentResponseStatusCancelled:
+	case pkg.CardPayPaymentResponseStatusCancelled, pkg.CardPayPaymentResponseStatusVoided:
 		order.PrivateStatus = constant.OrderStatusPaymentSystemCanceled
 		order.CanceledAt = ptypes.TimestampNow()
 		break
@@ -450,11 +511,20 @@ func (h *cardPay) ProcessPayment(order *billing.Order, message proto.Message, ra
 		order.PrivateStatus = constant.OrderStatusPaymentSystemComplete
 		order.IsRefundAllowed = order.PaymentMethod.RefundAllowed
 		break
+	case pkg.CardPayPaymentResponseStatusAuthorized:
+		if !order.IsPaymentAuthorizeOnly() {
+			return newBillingServerResponseError(pkg.StatusTemporary, paymentSystemErrorRequestTemporarySkipped)
+		}
+
+		order.PrivateStatus = pkg.OrderStatusPaymentSystemAuthorized
+		order.AuthorizedAmount = reqAmount
+		break
 	default:
 		return newBillingServerResponseError(pkg.StatusTemporary, paymentSystemErrorRequestTemporarySkipped)
 	}
 
-	if status == pkg.CardPayPaymentResponseStatusDeclined || status == pkg.CardPayPaymentResponseStatusCancelled {
+	if status == pkg.CardPayPaymentResponseStatusDeclined || status == pkg.CardPayPaymentResponseStatusCancelled ||
+		status == pkg.CardPayPaymentResponseStatusVoided {
 		declineCode, hasDeclineCode := order.PaymentMethodTxnParams[pkg.TxnParamsFieldDeclineCode]
 		declineReason, hasDeclineReason := order.PaymentMethodTxnParams[pkg.TxnParamsFieldDeclineReason]
 
@@ -610,7 +680,7 @@ func (h *cardPay) refresh(order *billing.Order) error {
 	return nil
 }
 
-func (h *cardPay) getUrl(apiUrl, action string) (string, error) {
+func (h *cardPay) getUrl(apiUrl, action string, args ...interface{}) (string, error) {
 	u, err := url.ParseRequestURI(apiUrl)
 
 	if err != nil {
@@ -624,6 +694,10 @@ func (h *cardPay) getUrl(apiUrl, action string) (string, error) {
 
 	u.Path = pkg.CardPayPaths[action].Path
 
+	if len(args) > 0 {
+		u.Path = fmt.Sprintf(u.Path, args...)
+	}
+
 	return u.String(), nil
 }
 
@@ -731,6 +805,11 @@ func (h *cardPay) getCardPayOrder(
 			Currency:  order.ChargeCurrency,
 			Amount:    order.ChargeAmount,
 			Initiator: cardPayInitiatorCardholder,
+			Preauth:   order.IsPaymentAuthorizeOnly(),
+		}
+
+		if order.IsMerchantInitiated {
+			cardPayOrder.RecurringData.Initiator = cardPayInitiatorMerchant
 		}
 
 		if okRecurringId == true && recurringId != "" {
@@ -744,6 +823,7 @@ func (h *cardPay) getCardPayOrder(
 		cardPayOrder.PaymentData = &CardPayPaymentData{
 			Currency: order.ChargeCurrency,
 			Amount:   order.ChargeAmount,
+			Preauth:  order.IsPaymentAuthorizeOnly(),
 		}
 	}
 
@@ -796,7 +876,7 @@ func (h *cardPay) getCryptoCurrencyCardPayOrder(cpo *CardPayOrder, requisites ma
 	}
 }
 
-func (h *cardPay) checkCallbackRequestSignature(order *billing.Order, raw, signature string) error {
+func checkCardPayCallbackSignature(order *billing.Order, raw, signature string) error {
 	hash := sha512.New()
 	hash.Write([]byte(raw + order.PaymentMethod.Params.SecretCallback))
 
@@ -1012,13 +1092,6 @@ func (h *cardPay) ProcessRefund(
 	req := message.(*billing.CardPayRefundCallback)
 	refund.Status = pkg.RefundStatusRejected
 
-	err := h.checkCallbackRequestSignature(order, raw, signature)
-
-	if err != nil {
-		err.(*grpc.ResponseError).Status = pkg.ResponseStatusBadData
-		return err
-	}
-
 	if !req.IsRefundAllowedStatus() {
 		return newBillingServerResponseError(pkg.ResponseStatusBadData, paymentSystemErrorRequestStatusIsInvalid)
 	}
@@ -1064,6 +1137,269 @@ func (h *cardPay) ProcessRefund(
 	return nil
 }
 
+func (h *cardPay) CapturePayment(order *billing.Order, amount float64) error {
+	data := &CardPayChangeStatusPaymentData{StatusTo: pkg.CardPayPaymentStatusToComplete}
+
+	if amount < order.AuthorizedAmount {
+		data.Amount = amount
+	}
+
+	rsp, err := h.changePaymentStatus(order, data)
+
+	if err != nil {
+		return paymentSystemErrorCaptureRequestFailed
+	}
+
+	if !rsp.IsExecuted {
+		return paymentSystemErrorCaptureRequestRejected
+	}
+
+	order.CapturedAmount = amount
+	order.CapturedAt = ptypes.TimestampNow()
+
+	return nil
+}
+
+func (h *cardPay) VoidPayment(order *billing.Order) error {
+	data := &CardPayChangeStatusPaymentData{StatusTo: pkg.CardPayPaymentStatusToReverse}
+	rsp, err := h.changePaymentStatus(order, data)
+
+	if err != nil {
+		return paymentSystemErrorVoidRequestFailed
+	}
+
+	if !rsp.IsExecuted {
+		return paymentSystemErrorVoidRequestRejected
+	}
+
+	order.VoidedAt = ptypes.TimestampNow()
+
+	return nil
+}
+
+// GetPaymentStatus requests payment by transaction of order, if order hasn't transaction yet
+// then the last payment created for order will be requested.
+func (h *cardPay) GetPaymentStatus(order *billing.Order) (proto.Message, error) {
+	err := h.auth(order)
+
+	if err != nil {
+		return nil, err
+	}
+
+	action := pkg.PaymentSystemActionPaymentStatus
+	u, err := h.getUrl(order.GetPaymentSystemApiUrl(), action, order.Transaction)
+
+	if order.Transaction == "" {
+		action = pkg.PaymentSystemActionPaymentList
+		u, err = h.getUrl(order.GetPaymentSystemApiUrl(), action)
+		query := url.Values{
+			cardPayRequestFieldRequestId:       []string{primitive.NewObjectID().Hex()},
+			cardPayRequestFieldMerchantOrderId: []string{order.Id},
+		}
+		u += "?" + query.Encode()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(pkg.CardPayPaths[action].Method, u, nil)
+
+	if err != nil {
+		zap.L().Error(
+			"cardpay API: create payment status request failed",
+			zap.Error(err),
+			zap.String("method", pkg.CardPayPaths[action].Method),
+			zap.String("url", u),
+			zap.String(pkg.LogFieldHandler, pkg.PaymentSystemHandlerCardPay),
+		)
+		return nil, err
+	}
+
+	token := h.getToken(order)
+	auth := strings.Title(token.TokenType) + " " + token.AccessToken
+
+	req.Header.Add(HeaderContentType, MIMEApplicationJSON)
+	req.Header.Add(HeaderAuthorization, auth)
+
+	resp, err := h.httpClient.Do(req)
+
+	if err != nil {
+		zap.L().Error(
+			"cardpay API: send payment status request failed",
+			zap.Error(err),
+			zap.String("method", pkg.CardPayPaths[action].Method),
+			zap.String("url", u),
+			zap.String(pkg.LogFieldHandler, pkg.PaymentSystemHandlerCardPay),
+		)
+		return nil, paymentSystemErrorPaymentStatusRequestFailed
+	}
+
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		zap.L().Error(
+			"cardpay API: payment status response body can't be read",
+			zap.Error(err),
+			zap.String("url", u),
+			zap.String(pkg.LogFieldHandler, pkg.PaymentSystemHandlerCardPay),
+		)
+		return nil, paymentSystemErrorPaymentStatusRequestFailed
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, paymentSystemErrorPaymentNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		zap.L().Error(
+			"cardpay API: payment status response returned with bad http status",
+			zap.Int("status", resp.StatusCode),
+			zap.String("url", u),
+			zap.String(pkg.LogFieldHandler, pkg.PaymentSystemHandlerCardPay),
+			zap.ByteString(pkg.LogFieldResponse, b),
+		)
+		return nil, paymentSystemErrorPaymentStatusRequestFailed
+	}
+
+	payment := &billing.CardPayPaymentCallback{}
+
+	if action == pkg.PaymentSystemActionPaymentList {
+		list := &CardPayPaymentListResponse{}
+		err = json.Unmarshal(b, list)
+
+		if err == nil && len(list.Data) <= 0 {
+			return nil, paymentSystemErrorPaymentNotFound
+		}
+
+		if err == nil {
+			payment = list.Data[len(list.Data)-1]
+		}
+	} else {
+		err = json.Unmarshal(b, payment)
+	}
+
+	if err != nil {
+		zap.L().Error(
+			"cardpay API: payment status response contain invalid json",
+			zap.Error(err),
+			zap.String("url", u),
+			zap.String(pkg.LogFieldHandler, pkg.PaymentSystemHandlerCardPay),
+			zap.ByteString(pkg.LogFieldResponse, b),
+		)
+		return nil, paymentSystemErrorPaymentStatusRequestFailed
+	}
+
+	payment.CallbackTime = time.Now().UTC().Format(cardPayDateFormat)
+
+	return payment, nil
+}
+
+func (h *cardPay) changePaymentStatus(
+	order *billing.Order,
+	paymentData *CardPayChangeStatusPaymentData,
+) (*CardPayChangeStatusResponse, error) {
+	err := h.auth(order)
+
+	if err != nil {
+		return nil, err
+	}
+
+	action := pkg.PaymentSystemActionChangeStatus
+	u, err := h.getUrl(order.GetPaymentSystemApiUrl(), action, order.Transaction)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data := &CardPayChangeStatusRequest{
+		Request: &CardPayRequest{
+			Id:   primitive.NewObjectID().Hex(),
+			Time: time.Now().UTC().Format(cardPayDateFormat),
+		},
+		Operation:   cardPayOperationChangeStatus,
+		PaymentData: paymentData,
+	}
+
+	b, _ := json.Marshal(data)
+	req, err := http.NewRequest(pkg.CardPayPaths[action].Method, u, bytes.NewBuffer(b))
+
+	if err != nil {
+		zap.L().Error(
+			"cardpay API: create change payment status request failed",
+			zap.Error(err),
+			zap.String("method", pkg.CardPayPaths[action].Method),
+			zap.String("url", u),
+			zap.String(pkg.LogFieldHandler, pkg.PaymentSystemHandlerCardPay),
+			zap.ByteString(pkg.LogFieldRequest, b),
+		)
+		return nil, err
+	}
+
+	token := h.getToken(order)
+	auth := strings.Title(token.TokenType) + " " + token.AccessToken
+
+	req.Header.Add(HeaderContentType, MIMEApplicationJSON)
+	req.Header.Add(HeaderAuthorization, auth)
+
+	resp, err := h.httpClient.Do(req)
+
+	if err != nil {
+		zap.L().Error(
+			"cardpay API: send change payment status request failed",
+			zap.Error(err),
+			zap.String("method", pkg.CardPayPaths[action].Method),
+			zap.String("url", u),
+			zap.String(pkg.LogFieldHandler, pkg.PaymentSystemHandlerCardPay),
+			zap.ByteString(pkg.LogFieldRequest, b),
+		)
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	b, err = ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		zap.L().Error(
+			"cardpay API: change payment status response body can't be read",
+			zap.Error(err),
+			zap.String("url", u),
+			zap.String(pkg.LogFieldHandler, pkg.PaymentSystemHandlerCardPay),
+		)
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		zap.L().Error(
+			"cardpay API: change payment status response returned with bad http status",
+			zap.Int("status", resp.StatusCode),
+			zap.String("url", u),
+			zap.String(pkg.LogFieldHandler, pkg.PaymentSystemHandlerCardPay),
+			zap.ByteString(pkg.LogFieldResponse, b),
+		)
+		return nil, errors.New(http.StatusText(resp.StatusCode))
+	}
+
+	rsp := &CardPayChangeStatusResponse{}
+	err = json.Unmarshal(b, rsp)
+
+	if err != nil {
+		zap.L().Error(
+			"cardpay API: change payment status response contain invalid json",
+			zap.Error(err),
+			zap.String("url", u),
+			zap.String(pkg.LogFieldHandler, pkg.PaymentSystemHandlerCardPay),
+			zap.ByteString(pkg.LogFieldResponse, b),
+		)
+		return nil, err
+	}
+
+	return rsp, nil
+}
+
 func (h *CardPayOrderRecurringResponse) IsSuccessStatus() bool {
 	if h.RecurringData == nil {
 		return false
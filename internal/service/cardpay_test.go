@@ -2,6 +2,7 @@ package service
 
 import (
 	"fmt"
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/paysuper/paysuper-billing-server/internal/config"
 	"github.com/paysuper/paysuper-billing-server/internal/mocks"
@@ -14,6 +15,7 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest/observer"
+	"net/http"
 	"testing"
 )
 
@@ -134,3 +136,79 @@ func (suite *CardPayTestSuite) TestCardPay_CreatePayment_Mock_Ok() {
 	assert.NoError(suite.T(), err)
 	assert.NotEmpty(suite.T(), url)
 }
+
+func (suite *CardPayTestSuite) TestCardPay_GetCardPayOrder_PreAuthorization_Ok() {
+	order := proto.Clone(orderSimpleBankCard).(*billing.Order)
+	order.IsPreAuthorization = true
+
+	res, err := suite.handler.getCardPayOrder(
+		order,
+		suite.cfg.GetRedirectUrlSuccess(nil),
+		suite.cfg.GetRedirectUrlFail(nil),
+		bankCardRequisites,
+	)
+	assert.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), res.PaymentData)
+	assert.True(suite.T(), res.PaymentData.Preauth)
+}
+
+func (suite *CardPayTestSuite) TestCardPay_GetCardPayOrder_MerchantInitiated_Ok() {
+	order := proto.Clone(orderSimpleBankCard).(*billing.Order)
+	order.IsMerchantInitiated = true
+	requisites := map[string]string{pkg.PaymentCreateFieldRecurringId: "0987654321"}
+
+	res, err := suite.handler.getCardPayOrder(
+		order,
+		suite.cfg.GetRedirectUrlSuccess(nil),
+		suite.cfg.GetRedirectUrlFail(nil),
+		requisites,
+	)
+	assert.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), res.RecurringData)
+	assert.Equal(suite.T(), cardPayInitiatorMerchant, res.RecurringData.Initiator)
+	assert.NotNil(suite.T(), res.RecurringData.Filing)
+	assert.Equal(suite.T(), "0987654321", res.RecurringData.Filing.Id)
+
+	order.IsMerchantInitiated = false
+	res, err = suite.handler.getCardPayOrder(
+		order,
+		suite.cfg.GetRedirectUrlSuccess(nil),
+		suite.cfg.GetRedirectUrlFail(nil),
+		requisites,
+	)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), cardPayInitiatorCardholder, res.RecurringData.Initiator)
+}
+
+func (suite *CardPayTestSuite) TestCardPay_CapturePayment_Mock_Ok() {
+	order := proto.Clone(orderSimpleBankCard).(*billing.Order)
+	order.Transaction = "123"
+	order.AuthorizedAmount = 10.2
+
+	suite.handler.httpClient = mocks.NewCardPayHttpClientStatusOk()
+	err := suite.handler.CapturePayment(order, 5.1)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 5.1, order.CapturedAmount)
+	assert.NotNil(suite.T(), order.CapturedAt)
+}
+
+func (suite *CardPayTestSuite) TestCardPay_CapturePayment_BadHttpStatus_Error() {
+	order := proto.Clone(orderSimpleBankCard).(*billing.Order)
+	order.AuthorizedAmount = 10.2
+
+	suite.handler.httpClient = &http.Client{Transport: &mocks.TransportStatusError{}}
+	err := suite.handler.CapturePayment(order, 10.2)
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), paymentSystemErrorCaptureRequestFailed, err)
+	assert.Zero(suite.T(), order.CapturedAmount)
+}
+
+func (suite *CardPayTestSuite) TestCardPay_VoidPayment_Mock_Ok() {
+	order := proto.Clone(orderSimpleBankCard).(*billing.Order)
+	order.Transaction = "123"
+
+	suite.handler.httpClient = mocks.NewCardPayHttpClientStatusOk()
+	err := suite.handler.VoidPayment(order)
+	assert.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), order.VoidedAt)
+}
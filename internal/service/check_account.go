@@ -0,0 +1,100 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"go.uber.org/zap"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+type checkAccountRequest struct {
+	OrderId        string  `json:"order_id"`
+	ProjectId      string  `json:"project_id"`
+	ProjectAccount string  `json:"project_account"`
+	Amount         float64 `json:"amount"`
+	Currency       string  `json:"currency"`
+}
+
+// checkProjectAccount requests project check account url to allow payment of order for project account.
+// Project allows payment with 2xx http status and rejects payment with 4xx http status of response.
+// If project url is unavailable or returned other http status then payment allowed or rejected by
+// fail policy from configuration.
+func (s *Service) checkProjectAccount(ctx context.Context, order *billing.Order) *grpc.ResponseErrorMessage {
+	if order.Project == nil || order.Project.UrlCheckAccount == "" ||
+		order.Project.CallbackProtocol == pkg.ProjectCallbackProtocolEmpty {
+		return nil
+	}
+
+	allowed, err := s.sendCheckAccountRequest(ctx, order)
+
+	if err != nil {
+		zap.L().Error(
+			"project account check failed",
+			zap.Error(err),
+			zap.String("order_uuid", order.Uuid),
+			zap.String("url", order.Project.UrlCheckAccount),
+			zap.Bool("fail_open", s.cfg.CheckAccountFailOpen),
+		)
+
+		if s.cfg.CheckAccountFailOpen {
+			return nil
+		}
+
+		return orderErrorAccountCheckFailed
+	}
+
+	if !allowed {
+		return orderErrorAccountCheckRejected
+	}
+
+	return nil
+}
+
+func (s *Service) sendCheckAccountRequest(ctx context.Context, order *billing.Order) (bool, error) {
+	b, err := json.Marshal(&checkAccountRequest{
+		OrderId:        order.Uuid,
+		ProjectId:      order.GetProjectId(),
+		ProjectAccount: order.ProjectAccount,
+		Amount:         order.TotalPaymentAmount,
+		Currency:       order.Currency,
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, order.Project.UrlCheckAccount, bytes.NewBuffer(b))
+
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+	req.Header.Set(pkg.WebhookHeaderSignature, getWebhookSignature(string(b), order.Project.SecretKey))
+
+	rsp, err := s.checkAccountHttpClient.Do(req.WithContext(ctx))
+
+	if err != nil {
+		return false, err
+	}
+
+	defer rsp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, rsp.Body)
+
+	if rsp.StatusCode >= http.StatusOK && rsp.StatusCode < http.StatusMultipleChoices {
+		return true, nil
+	}
+
+	if rsp.StatusCode >= http.StatusBadRequest && rsp.StatusCode < http.StatusInternalServerError {
+		return false, nil
+	}
+
+	return false, fmt.Errorf("project check account url returned unexpected http status %d", rsp.StatusCode)
+}
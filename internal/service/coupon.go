@@ -0,0 +1,737 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/constant"
+	"github.com/paysuper/paysuper-recurring-repository/tools"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+const (
+	collectionCoupon            = "coupon"
+	collectionCouponCustomerUse = "coupon_customer_use"
+)
+
+var (
+	errorCouponNotFound                   = newBillingServerErrorMsg("cpn00001", "coupon not found")
+	errorCouponProjectInvalid             = newBillingServerErrorMsg("cpn00002", "project of coupon not found or belongs to another merchant")
+	errorCouponProductInvalid             = newBillingServerErrorMsg("cpn00003", "product of coupon not found or belongs to another project")
+	errorCouponCurrencyInvalid            = newBillingServerErrorMsg("cpn00004", "currency of coupon is not supported")
+	errorCouponDiscountInvalid            = newBillingServerErrorMsg("cpn00005", "percent coupon must have percent of discount and fixed coupon must have discount amounts")
+	errorCouponPeriodInvalid              = newBillingServerErrorMsg("cpn00006", "coupon expiry date must be after its start date")
+	errorCouponCodeDuplicate              = newBillingServerErrorMsg("cpn00007", "coupon with same code already exists in project")
+	errorCouponInactive                   = newBillingServerErrorMsg("cpn00008", "coupon is inactive")
+	errorCouponNotStarted                 = newBillingServerErrorMsg("cpn00009", "coupon is not valid yet")
+	errorCouponExpired                    = newBillingServerErrorMsg("cpn00010", "coupon is expired")
+	errorCouponUsageLimitReached          = newBillingServerErrorMsg("cpn00011", "coupon usage limit reached")
+	errorCouponCustomerUsageLimitReached  = newBillingServerErrorMsg("cpn00012", "coupon usage limit for customer reached")
+	errorCouponCustomerRequired           = newBillingServerErrorMsg("cpn00013", "coupon can be used by identified customer only")
+	errorCouponOrderCurrencyNotApplicable = newBillingServerErrorMsg("cpn00014", "coupon is not applicable to order currency")
+	errorCouponOrderAmountTooLow          = newBillingServerErrorMsg("cpn00015", "order amount is less than minimal amount of coupon")
+	errorCouponOrderProductsInvalid       = newBillingServerErrorMsg("cpn00016", "coupon is not applicable to products of order")
+	errorCouponOrderTypeInvalid           = newBillingServerErrorMsg("cpn00017", "coupon can be applied to order with products only")
+	errorCouponDiscountExceedsAmount      = newBillingServerErrorMsg("cpn00018", "coupon discount can't cover whole order amount")
+)
+
+type CouponRepositoryInterface interface {
+	Upsert(ctx context.Context, coupon *billing.Coupon) error
+	GetById(ctx context.Context, id string) (*billing.Coupon, error)
+	GetByCode(ctx context.Context, projectId, code string) (*billing.Coupon, error)
+	Find(ctx context.Context, merchantId, projectId string) ([]*billing.Coupon, error)
+	// Redeem counts use of coupon by customer if usage limits of coupon aren't reached yet,
+	// otherwise returns errorCouponUsageLimitReached or errorCouponCustomerUsageLimitReached
+	Redeem(ctx context.Context, coupon *billing.Coupon, customerId string) error
+	// Release cancels use of coupon by customer counted by Redeem
+	Release(ctx context.Context, id, customerId string) error
+	// CountCustomerUses returns count of uses of coupon by customer
+	CountCustomerUses(ctx context.Context, id, customerId string) (int64, error)
+}
+
+func newCouponRepository(svc *Service) CouponRepositoryInterface {
+	s := &Coupon{svc: svc}
+	return s
+}
+
+func (s *Service) SetCoupon(
+	ctx context.Context,
+	req *billing.Coupon,
+	rsp *grpc.CouponResponse,
+) error {
+	coupon := &billing.Coupon{
+		Id:         primitive.NewObjectID().Hex(),
+		MerchantId: req.MerchantId,
+		ProjectId:  req.ProjectId,
+		CreatedAt:  ptypes.TimestampNow(),
+	}
+
+	if req.Id != "" {
+		var err error
+		coupon, err = s.coupon.GetById(ctx, req.Id)
+
+		if err != nil || coupon.MerchantId != req.MerchantId {
+			rsp.Status = pkg.ResponseStatusNotFound
+			rsp.Message = errorCouponNotFound
+			return nil
+		}
+	}
+
+	// project of coupon can't be changed because used count of coupon is counted by orders of project
+	coupon.Code = normalizeCouponCode(req.Code)
+	coupon.DiscountType = req.DiscountType
+	coupon.Percent = req.Percent
+	coupon.Amounts = req.Amounts
+	coupon.MinOrderAmounts = req.MinOrderAmounts
+	coupon.Currencies = req.Currencies
+	coupon.ProductIds = req.ProductIds
+	coupon.MaxUses = req.MaxUses
+	coupon.MaxUsesPerCustomer = req.MaxUsesPerCustomer
+	coupon.StartsAt = req.StartsAt
+	coupon.ExpiresAt = req.ExpiresAt
+	coupon.IsActive = req.IsActive
+	coupon.UpdatedAt = ptypes.TimestampNow()
+
+	if msg := s.checkCouponParams(ctx, coupon); msg != nil {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = msg
+		return nil
+	}
+
+	if err := s.coupon.Upsert(ctx, coupon); err != nil {
+		return err
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = coupon
+
+	return nil
+}
+
+func (s *Service) GetCoupons(
+	ctx context.Context,
+	req *grpc.GetCouponsRequest,
+	rsp *grpc.GetCouponsResponse,
+) (err error) {
+	rsp.Items, err = s.coupon.Find(ctx, req.MerchantId, req.ProjectId)
+
+	if err != nil {
+		return err
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	return nil
+}
+
+// ApplyOrderCoupon applies coupon entered by customer on payment form to order, VAT and charge amount of order
+// are recalculated from discounted amount. Coupon applied to order before is replaced or removed if code is empty.
+func (s *Service) ApplyOrderCoupon(
+	ctx context.Context,
+	req *grpc.ApplyOrderCouponRequest,
+	rsp *grpc.ApplyOrderCouponResponse,
+) error {
+	order, err := s.getOrderByUuidToForm(ctx, req.OrderId)
+
+	if err != nil {
+		zap.S().Errorw(pkg.MethodFinishedWithError, "err", err.Error())
+		if e, ok := err.(*grpc.ResponseErrorMessage); ok {
+			rsp.Status = pkg.ResponseStatusBadData
+			rsp.Message = e
+			return nil
+		}
+		return err
+	}
+
+	if order.ProductType != billing.OrderType_product && order.ProductType != billing.OrderType_key {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = errorCouponOrderTypeInvalid
+		return nil
+	}
+
+	var coupon *billing.Coupon
+
+	if req.Code != "" {
+		coupon, err = s.coupon.GetByCode(ctx, order.GetProjectId(), normalizeCouponCode(req.Code))
+
+		if err != nil {
+			rsp.Status = pkg.ResponseStatusNotFound
+			rsp.Message = errorCouponNotFound
+			return nil
+		}
+	}
+
+	// amounts of order are recalculated without discount of coupon applied before
+	s.releaseOrderCoupon(ctx, order)
+	order.Coupon = nil
+
+	if order.ProductType == billing.OrderType_product {
+		err = s.ProcessOrderProducts(ctx, order)
+	} else {
+		_, err = s.ProcessOrderKeyProducts(ctx, order)
+	}
+
+	if err != nil {
+		zap.S().Errorw(pkg.MethodFinishedWithError, "err", err.Error())
+		if e, ok := err.(*grpc.ResponseErrorMessage); ok {
+			rsp.Status = pkg.ResponseStatusBadData
+			rsp.Message = e
+			return nil
+		}
+		return err
+	}
+
+	if coupon != nil {
+		msg := s.checkOrderCoupon(ctx, order, coupon)
+
+		if msg == nil {
+			msg = applyOrderCouponDiscount(order, coupon)
+		}
+
+		if msg != nil {
+			rsp.Status = pkg.ResponseStatusBadData
+			rsp.Message = msg
+			return nil
+		}
+	}
+
+	processor := &OrderCreateRequestProcessor{Service: s, ctx: ctx}
+	err = processor.processOrderVat(order)
+
+	if err == nil {
+		err = s.setOrderChargeAmountAndCurrency(ctx, order)
+	}
+
+	if err == nil {
+		err = s.updateOrder(ctx, order)
+	}
+
+	if err != nil {
+		zap.S().Errorw(pkg.MethodFinishedWithError, "err", err.Error())
+		if e, ok := err.(*grpc.ResponseErrorMessage); ok {
+			rsp.Status = pkg.ResponseStatusSystemError
+			rsp.Message = e
+			return nil
+		}
+		return err
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = getOrderAmountsResponseItem(order)
+
+	return nil
+}
+
+// processOrderCoupon applies discount of coupon to amounts of order recalculated after change
+// of country or currency. Coupon which isn't applicable to order anymore is removed from order.
+func (s *Service) processOrderCoupon(ctx context.Context, order *billing.Order) {
+	if order.Coupon == nil {
+		return
+	}
+
+	coupon, err := s.coupon.GetById(ctx, order.Coupon.Id)
+	redeemed := order.Coupon.IsRedeemed
+	msg := errorCouponNotFound
+
+	if err == nil {
+		msg = s.checkOrderCoupon(ctx, order, coupon)
+
+		if msg == nil {
+			msg = applyOrderCouponDiscount(order, coupon)
+		}
+	}
+
+	if msg == nil {
+		order.Coupon.IsRedeemed = redeemed
+		return
+	}
+
+	zap.L().Info(
+		"Coupon removed from order",
+		zap.String("order_id", order.Id),
+		zap.String("coupon_id", order.Coupon.Id),
+		zap.Any("reason", msg),
+	)
+	s.releaseOrderCoupon(ctx, order)
+	order.Coupon = nil
+}
+
+// redeemOrderCoupon counts use of coupon by order before payment of order. Usage limits of coupon
+// are checked on redeem atomically, so limits can't be exceeded by concurrent payments.
+func (s *Service) redeemOrderCoupon(ctx context.Context, order *billing.Order) *grpc.ResponseErrorMessage {
+	if order.Coupon == nil || order.Coupon.IsRedeemed {
+		return nil
+	}
+
+	coupon, err := s.coupon.GetById(ctx, order.Coupon.Id)
+
+	if err != nil {
+		return errorCouponNotFound
+	}
+
+	err = s.coupon.Redeem(ctx, coupon, order.GetUser().GetId())
+
+	if err != nil {
+		if e, ok := err.(*grpc.ResponseErrorMessage); ok {
+			return e
+		}
+
+		return orderErrorUnknown
+	}
+
+	order.Coupon.IsRedeemed = true
+
+	return nil
+}
+
+// releaseOrderCoupon cancels use of coupon by order when payment of order was declined or canceled
+// or coupon was removed from order
+func (s *Service) releaseOrderCoupon(ctx context.Context, order *billing.Order) {
+	if order.Coupon == nil || !order.Coupon.IsRedeemed {
+		return
+	}
+
+	if err := s.coupon.Release(ctx, order.Coupon.Id, order.GetUser().GetId()); err != nil {
+		zap.L().Error(
+			"Cancel of coupon use failed",
+			zap.Error(err),
+			zap.String("order_id", order.Id),
+			zap.String("coupon_id", order.Coupon.Id),
+		)
+		return
+	}
+
+	order.Coupon.IsRedeemed = false
+}
+
+func (s *Service) checkCouponParams(ctx context.Context, coupon *billing.Coupon) *grpc.ResponseErrorMessage {
+	project, err := s.project.GetById(ctx, coupon.ProjectId)
+
+	if err != nil || project.MerchantId != coupon.MerchantId {
+		return errorCouponProjectInvalid
+	}
+
+	for _, id := range coupon.ProductIds {
+		if product, err := s.productService.GetById(ctx, id); err == nil && product.ProjectId == coupon.ProjectId {
+			continue
+		}
+
+		if product, err := s.keyProductRepository.GetById(ctx, id); err == nil && product.ProjectId == coupon.ProjectId {
+			continue
+		}
+
+		return errorCouponProductInvalid
+	}
+
+	if (coupon.DiscountType == pkg.CouponDiscountTypePercent && coupon.Percent <= 0) ||
+		(coupon.DiscountType == pkg.CouponDiscountTypeFixed && len(coupon.Amounts) == 0) {
+		return errorCouponDiscountInvalid
+	}
+
+	currencies := coupon.Currencies
+
+	for _, v := range append(coupon.Amounts, coupon.MinOrderAmounts...) {
+		currencies = append(currencies, v.Currency)
+	}
+
+	for _, v := range currencies {
+		if !contains(s.supportedCurrencies, v) {
+			return errorCouponCurrencyInvalid
+		}
+	}
+
+	if coupon.StartsAt != nil && coupon.ExpiresAt != nil && coupon.ExpiresAt.Seconds <= coupon.StartsAt.Seconds {
+		return errorCouponPeriodInvalid
+	}
+
+	existing, err := s.coupon.GetByCode(ctx, coupon.ProjectId, coupon.Code)
+
+	if err == nil && existing.Id != coupon.Id {
+		return errorCouponCodeDuplicate
+	}
+
+	return nil
+}
+
+// checkOrderCoupon checks that coupon can be applied to order with amounts calculated without discount
+func (s *Service) checkOrderCoupon(
+	ctx context.Context,
+	order *billing.Order,
+	coupon *billing.Coupon,
+) *grpc.ResponseErrorMessage {
+	if coupon.ProjectId != order.GetProjectId() {
+		return errorCouponNotFound
+	}
+
+	if !coupon.IsActive {
+		return errorCouponInactive
+	}
+
+	now := time.Now()
+
+	if coupon.StartsAt != nil && coupon.StartsAt.Seconds > now.Unix() {
+		return errorCouponNotStarted
+	}
+
+	if coupon.ExpiresAt != nil && coupon.ExpiresAt.Seconds <= now.Unix() {
+		return errorCouponExpired
+	}
+
+	if coupon.MaxUses > 0 && coupon.UsedCount >= coupon.MaxUses {
+		return errorCouponUsageLimitReached
+	}
+
+	if len(coupon.Currencies) > 0 && !contains(coupon.Currencies, order.Currency) {
+		return errorCouponOrderCurrencyNotApplicable
+	}
+
+	if len(coupon.MinOrderAmounts) > 0 {
+		minAmount, ok := getCouponAmount(coupon.MinOrderAmounts, order.Currency)
+
+		if !ok {
+			return errorCouponOrderCurrencyNotApplicable
+		}
+
+		if order.OrderAmount < minAmount {
+			return errorCouponOrderAmountTooLow
+		}
+	}
+
+	if coupon.MaxUsesPerCustomer > 0 {
+		if order.User == nil || order.User.Id == "" {
+			return errorCouponCustomerRequired
+		}
+
+		count, err := s.coupon.CountCustomerUses(ctx, coupon.Id, order.User.Id)
+
+		if err != nil {
+			return orderErrorUnknown
+		}
+
+		if count >= int64(coupon.MaxUsesPerCustomer) {
+			return errorCouponCustomerUsageLimitReached
+		}
+	}
+
+	return nil
+}
+
+// applyOrderCouponDiscount subtracts discount of coupon from amount of order and stores breakdown
+// of discount by products of order. Discount is distributed between products in proportion to their amounts
+// and subtracted from amounts of order items, so refunds of items are calculated from discounted amounts.
+func applyOrderCouponDiscount(order *billing.Order, coupon *billing.Coupon) *grpc.ResponseErrorMessage {
+	var (
+		items []*billing.OrderItem
+		base  float64
+	)
+
+	for _, item := range order.Items {
+		if len(coupon.ProductIds) == 0 || contains(coupon.ProductIds, item.Id) {
+			items = append(items, item)
+			base += item.Amount
+		}
+	}
+
+	if len(items) == 0 || base <= 0 {
+		return errorCouponOrderProductsInvalid
+	}
+
+	discount := base * coupon.Percent / 100
+
+	if coupon.DiscountType == pkg.CouponDiscountTypeFixed {
+		amount, ok := getCouponAmount(coupon.Amounts, order.Currency)
+
+		if !ok {
+			return errorCouponOrderCurrencyNotApplicable
+		}
+
+		discount = amount
+	}
+
+	if discount > base {
+		discount = base
+	}
+
+	discount = tools.FormatAmount(discount)
+
+	if discount >= order.OrderAmount {
+		return errorCouponDiscountExceedsAmount
+	}
+
+	orderCoupon := &billing.OrderCoupon{
+		Id:                   coupon.Id,
+		Code:                 coupon.Code,
+		DiscountType:         coupon.DiscountType,
+		Percent:              coupon.Percent,
+		Amount:               discount,
+		AmountBeforeDiscount: order.OrderAmount,
+	}
+
+	rest := discount
+
+	for i, item := range items {
+		amount := tools.FormatAmount(discount * item.Amount / base)
+
+		if i == len(items)-1 {
+			amount = tools.FormatAmount(rest)
+		}
+
+		rest -= amount
+		orderCoupon.Items = append(orderCoupon.Items, &billing.OrderCouponItem{ProductId: item.Id, Amount: amount})
+		item.Amount = tools.FormatAmount(item.Amount - amount)
+	}
+
+	order.Coupon = orderCoupon
+	order.OrderAmount = tools.FormatAmount(order.OrderAmount - discount)
+	order.TotalPaymentAmount = order.OrderAmount
+	order.ChargeAmount = order.TotalPaymentAmount
+
+	return nil
+}
+
+func getCouponAmount(amounts []*billing.CouponAmount, currency string) (float64, bool) {
+	for _, v := range amounts {
+		if v.Currency == currency {
+			return v.Amount, true
+		}
+	}
+
+	return 0, false
+}
+
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func (h *Coupon) Upsert(ctx context.Context, coupon *billing.Coupon) error {
+	oid, _ := primitive.ObjectIDFromHex(coupon.Id)
+	filter := bson.M{"_id": oid}
+	opts := options.Replace().SetUpsert(true)
+	_, err := h.svc.db.Collection(collectionCoupon).ReplaceOne(ctx, filter, coupon, opts)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionCoupon),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationUpsert),
+			zap.Any(pkg.ErrorDatabaseFieldDocument, coupon),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func (h *Coupon) GetById(ctx context.Context, id string) (*billing.Coupon, error) {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	return h.findOne(ctx, bson.M{"_id": oid})
+}
+
+func (h *Coupon) GetByCode(ctx context.Context, projectId, code string) (*billing.Coupon, error) {
+	oid, _ := primitive.ObjectIDFromHex(projectId)
+	return h.findOne(ctx, bson.M{"project_id": oid, "code": code})
+}
+
+func (h *Coupon) findOne(ctx context.Context, filter bson.M) (*billing.Coupon, error) {
+	coupon := &billing.Coupon{}
+	err := h.svc.db.Collection(collectionCoupon).FindOne(ctx, filter).Decode(coupon)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errorCouponNotFound
+		}
+
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionCoupon),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, filter),
+		)
+		return nil, err
+	}
+
+	return coupon, nil
+}
+
+func (h *Coupon) Find(ctx context.Context, merchantId, projectId string) ([]*billing.Coupon, error) {
+	oid, _ := primitive.ObjectIDFromHex(merchantId)
+	query := bson.M{"merchant_id": oid}
+
+	if projectId != "" {
+		query["project_id"], _ = primitive.ObjectIDFromHex(projectId)
+	}
+
+	cursor, err := h.svc.db.Collection(collectionCoupon).Find(ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionCoupon),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	var coupons []*billing.Coupon
+	err = cursor.All(ctx, &coupons)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionCoupon),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	return coupons, nil
+}
+
+func (h *Coupon) Redeem(ctx context.Context, coupon *billing.Coupon, customerId string) error {
+	oid, _ := primitive.ObjectIDFromHex(coupon.Id)
+	filter := bson.M{
+		"_id": oid,
+		"$or": []bson.M{
+			{"max_uses": 0},
+			{"$expr": bson.M{"$lt": []string{"$used_count", "$max_uses"}}},
+		},
+	}
+	res, err := h.svc.db.Collection(collectionCoupon).UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"used_count": 1}})
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionCoupon),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationUpdate),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, filter),
+		)
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return errorCouponUsageLimitReached
+	}
+
+	if customerId == "" {
+		return nil
+	}
+
+	err = h.incrementCustomerUses(ctx, oid, customerId, coupon.MaxUsesPerCustomer)
+
+	if err != nil {
+		// use of coupon isn't counted for customer, so it mustn't be counted for coupon too
+		_ = h.decrementUses(ctx, collectionCoupon, bson.M{"_id": oid})
+		return err
+	}
+
+	return nil
+}
+
+func (h *Coupon) Release(ctx context.Context, id, customerId string) error {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	err := h.decrementUses(ctx, collectionCoupon, bson.M{"_id": oid})
+
+	if err != nil || customerId == "" {
+		return err
+	}
+
+	return h.decrementUses(ctx, collectionCouponCustomerUse, bson.M{"coupon_id": oid, "customer_id": customerId})
+}
+
+// incrementCustomerUses counts use of coupon by customer, uses are counted for every customer
+// to apply limit of uses per customer changed after start of coupon
+func (h *Coupon) incrementCustomerUses(ctx context.Context, oid primitive.ObjectID, customerId string, limit int32) error {
+	filter := bson.M{"coupon_id": oid, "customer_id": customerId}
+	opts := options.Update().SetUpsert(true)
+	_, err := h.svc.db.Collection(collectionCouponCustomerUse).
+		UpdateOne(ctx, filter, bson.M{"$setOnInsert": bson.M{"used_count": 0}}, opts)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionCouponCustomerUse),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationUpsert),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, filter),
+		)
+		return err
+	}
+
+	if limit > 0 {
+		filter["used_count"] = bson.M{"$lt": limit}
+	}
+
+	res, err := h.svc.db.Collection(collectionCouponCustomerUse).UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"used_count": 1}})
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionCouponCustomerUse),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationUpdate),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, filter),
+		)
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return errorCouponCustomerUsageLimitReached
+	}
+
+	return nil
+}
+
+func (h *Coupon) decrementUses(ctx context.Context, collection string, filter bson.M) error {
+	filter["used_count"] = bson.M{"$gt": 0}
+	_, err := h.svc.db.Collection(collection).UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"used_count": -1}})
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collection),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationUpdate),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, filter),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func (h *Coupon) CountCustomerUses(ctx context.Context, id, customerId string) (int64, error) {
+	var use struct {
+		UsedCount int64 `bson:"used_count"`
+	}
+
+	oid, _ := primitive.ObjectIDFromHex(id)
+	query := bson.M{"coupon_id": oid, "customer_id": customerId}
+	err := h.svc.db.Collection(collectionCouponCustomerUse).FindOne(ctx, query).Decode(&use)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, nil
+		}
+
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionCouponCustomerUse),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return 0, err
+	}
+
+	return use.UsedCount, nil
+}
@@ -0,0 +1,381 @@
+package service
+
+import (
+	"context"
+	"github.com/go-redis/redis"
+	"github.com/golang-migrate/migrate/v4"
+	casbinMocks "github.com/paysuper/casbin-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/internal/config"
+	"github.com/paysuper/paysuper-billing-server/internal/database"
+	"github.com/paysuper/paysuper-billing-server/internal/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-recurring-repository/tools"
+	reportingMocks "github.com/paysuper/paysuper-reporter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	rabbitmq "gopkg.in/ProtocolONE/rabbitmq.v1/pkg"
+	mongodb "gopkg.in/paysuper/paysuper-database-mongo.v1"
+	"testing"
+)
+
+type CouponTestSuite struct {
+	suite.Suite
+	service *Service
+	cache   CacheInterface
+
+	project       *billing.Project
+	paymentMethod *billing.PaymentMethod
+	productIds    []string
+}
+
+func Test_Coupon(t *testing.T) {
+	suite.Run(t, new(CouponTestSuite))
+}
+
+func (suite *CouponTestSuite) SetupTest() {
+	cfg, err := config.NewConfig()
+	if err != nil {
+		suite.FailNow("Config load failed", "%v", err)
+	}
+
+	m, err := migrate.New(
+		"file://../../migrations/tests",
+		cfg.MongoDsn)
+	assert.NoError(suite.T(), err, "Migrate init failed")
+
+	err = m.Up()
+	if err != nil && err.Error() != "no change" {
+		suite.FailNow("Migrations failed", "%v", err)
+	}
+
+	db, err := mongodb.NewDatabase()
+	if err != nil {
+		suite.FailNow("Database connection failed", "%v", err)
+	}
+
+	broker, err := rabbitmq.NewBroker(cfg.BrokerAddress)
+
+	if err != nil {
+		suite.FailNow("Creating RabbitMQ publisher failed", "%v", err)
+	}
+
+	redisClient := database.NewRedis(
+		&redis.Options{
+			Addr:     cfg.RedisHost,
+			Password: cfg.RedisPassword,
+		},
+	)
+
+	redisdb := mocks.NewTestRedis()
+	suite.cache, err = NewCacheRedis(redisdb, "cache")
+	suite.service = NewBillingService(
+		db,
+		cfg,
+		mocks.NewGeoIpServiceTestOk(),
+		mocks.NewRepositoryServiceOk(),
+		mocks.NewTaxServiceOkMock(),
+		broker,
+		redisClient,
+		suite.cache,
+		mocks.NewCurrencyServiceMockOk(),
+		mocks.NewDocumentSignerMockOk(),
+		&reportingMocks.ReporterService{},
+		mocks.NewFormatterOK(),
+		broker,
+		&casbinMocks.CasbinService{},
+	)
+
+	if err := suite.service.Init(); err != nil {
+		suite.FailNow("Billing service initialization failed", "%v", err)
+	}
+
+	_, suite.project, suite.paymentMethod, _ = helperCreateEntitiesForTests(suite.Suite, suite.service)
+
+	suite.productIds = []string{}
+	for _, product := range createProductsForProject(suite.Suite, suite.service, suite.project, 2) {
+		suite.productIds = append(suite.productIds, product.Id)
+	}
+}
+
+func (suite *CouponTestSuite) TearDownTest() {
+	if err := suite.service.db.Drop(); err != nil {
+		suite.FailNow("Database deletion failed", "%v", err)
+	}
+
+	if err := suite.service.db.Close(); err != nil {
+		suite.FailNow("Database close failed", "%v", err)
+	}
+}
+
+func (suite *CouponTestSuite) createOrder(customerId string) *billing.Order {
+	req := &billing.OrderCreateRequest{
+		Type:        billing.OrderType_product,
+		ProjectId:   suite.project.Id,
+		Products:    suite.productIds,
+		Account:     "unit test",
+		Description: "unit test",
+		OrderId:     primitive.NewObjectID().Hex(),
+		User: &billing.OrderUser{
+			Id:    customerId,
+			Email: customerId + "@unit.unit",
+			Ip:    "127.0.0.1",
+			Address: &billing.OrderBillingAddress{
+				Country: "RU",
+			},
+		},
+	}
+	rsp := &grpc.OrderCreateProcessResponse{}
+	err := suite.service.OrderCreateProcess(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	return rsp.Item
+}
+
+func (suite *CouponTestSuite) setCoupon(req *billing.Coupon) *billing.Coupon {
+	req.MerchantId = suite.project.MerchantId
+	req.ProjectId = suite.project.Id
+	req.IsActive = true
+
+	rsp := &grpc.CouponResponse{}
+	err := suite.service.SetCoupon(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	return rsp.Item
+}
+
+func (suite *CouponTestSuite) TestCoupon_SetCoupon_Ok() {
+	coupon := suite.setCoupon(&billing.Coupon{
+		Code:         " spring-sale ",
+		DiscountType: pkg.CouponDiscountTypePercent,
+		Percent:      15,
+	})
+	assert.Equal(suite.T(), "SPRING-SALE", coupon.Code)
+
+	rsp := &grpc.GetCouponsResponse{}
+	err := suite.service.GetCoupons(context.TODO(), &grpc.GetCouponsRequest{MerchantId: suite.project.MerchantId}, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Len(suite.T(), rsp.Items, 1)
+	assert.Equal(suite.T(), coupon.Id, rsp.Items[0].Id)
+}
+
+func (suite *CouponTestSuite) TestCoupon_SetCoupon_Error_CodeDuplicate() {
+	suite.setCoupon(&billing.Coupon{Code: "SALE", DiscountType: pkg.CouponDiscountTypePercent, Percent: 10})
+
+	req := &billing.Coupon{
+		MerchantId:   suite.project.MerchantId,
+		ProjectId:    suite.project.Id,
+		Code:         "sale",
+		DiscountType: pkg.CouponDiscountTypePercent,
+		Percent:      20,
+	}
+	rsp := &grpc.CouponResponse{}
+	err := suite.service.SetCoupon(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), errorCouponCodeDuplicate, rsp.Message)
+}
+
+func (suite *CouponTestSuite) TestCoupon_SetCoupon_Error_ProductInvalid() {
+	req := &billing.Coupon{
+		MerchantId:   suite.project.MerchantId,
+		ProjectId:    suite.project.Id,
+		Code:         "SALE",
+		DiscountType: pkg.CouponDiscountTypePercent,
+		Percent:      10,
+		ProductIds:   []string{primitive.NewObjectID().Hex()},
+	}
+	rsp := &grpc.CouponResponse{}
+	err := suite.service.SetCoupon(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), errorCouponProductInvalid, rsp.Message)
+}
+
+func (suite *CouponTestSuite) TestCoupon_ApplyOrderCoupon_Percent_Ok() {
+	suite.setCoupon(&billing.Coupon{Code: "SALE", DiscountType: pkg.CouponDiscountTypePercent, Percent: 10})
+	order := suite.createOrder(primitive.NewObjectID().Hex())
+	amount := order.OrderAmount
+
+	rsp := &grpc.ApplyOrderCouponResponse{}
+	err := suite.service.ApplyOrderCoupon(context.TODO(), &grpc.ApplyOrderCouponRequest{OrderId: order.Uuid, Code: "sale"}, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.NotNil(suite.T(), rsp.Item.Coupon)
+
+	order, err = suite.service.getOrderByUuid(context.TODO(), order.Uuid)
+	assert.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), order.Coupon)
+	assert.Equal(suite.T(), amount, order.Coupon.AmountBeforeDiscount)
+	assert.Equal(suite.T(), tools.FormatAmount(amount*0.1), order.Coupon.Amount)
+	assert.Equal(suite.T(), tools.FormatAmount(amount-order.Coupon.Amount), order.OrderAmount)
+	assert.Equal(suite.T(), tools.FormatAmount(order.OrderAmount*order.Tax.Rate), order.Tax.Amount)
+	assert.Len(suite.T(), order.Coupon.Items, len(suite.productIds))
+
+	itemsDiscount := 0.0
+	for _, item := range order.Coupon.Items {
+		itemsDiscount += item.Amount
+	}
+	assert.Equal(suite.T(), order.Coupon.Amount, tools.FormatAmount(itemsDiscount))
+
+	itemsAmount := 0.0
+	for _, item := range order.Items {
+		itemsAmount += item.Amount
+	}
+	assert.Equal(suite.T(), order.OrderAmount, tools.FormatAmount(itemsAmount))
+
+	err = suite.service.ApplyOrderCoupon(context.TODO(), &grpc.ApplyOrderCouponRequest{OrderId: order.Uuid}, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Nil(suite.T(), rsp.Item.Coupon)
+	assert.Equal(suite.T(), amount, rsp.Item.Amount)
+}
+
+func (suite *CouponTestSuite) TestCoupon_ApplyOrderCoupon_ProductScope_Ok() {
+	suite.setCoupon(&billing.Coupon{
+		Code:         "SALE",
+		DiscountType: pkg.CouponDiscountTypePercent,
+		Percent:      50,
+		ProductIds:   suite.productIds[1:],
+	})
+	order := suite.createOrder(primitive.NewObjectID().Hex())
+
+	rsp := &grpc.ApplyOrderCouponResponse{}
+	err := suite.service.ApplyOrderCoupon(context.TODO(), &grpc.ApplyOrderCouponRequest{OrderId: order.Uuid, Code: "SALE"}, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Len(suite.T(), rsp.Item.Coupon.Items, 1)
+	assert.Equal(suite.T(), suite.productIds[1], rsp.Item.Coupon.Items[0].ProductId)
+
+	for _, item := range order.Items {
+		if item.Id == suite.productIds[1] {
+			assert.Equal(suite.T(), tools.FormatAmount(item.Amount*0.5), rsp.Item.Coupon.Amount)
+		}
+	}
+}
+
+func (suite *CouponTestSuite) TestCoupon_ApplyOrderCoupon_Error_CurrencyNotApplicable() {
+	order := suite.createOrder(primitive.NewObjectID().Hex())
+
+	currency := "USD"
+	if order.Currency == currency {
+		currency = "EUR"
+	}
+
+	suite.setCoupon(&billing.Coupon{
+		Code:         "SALE",
+		DiscountType: pkg.CouponDiscountTypeFixed,
+		Amounts:      []*billing.CouponAmount{{Currency: currency, Amount: 1}},
+	})
+
+	rsp := &grpc.ApplyOrderCouponResponse{}
+	err := suite.service.ApplyOrderCoupon(context.TODO(), &grpc.ApplyOrderCouponRequest{OrderId: order.Uuid, Code: "SALE"}, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), errorCouponOrderCurrencyNotApplicable, rsp.Message)
+}
+
+func (suite *CouponTestSuite) TestCoupon_ApplyOrderCoupon_Error_NotFound() {
+	order := suite.createOrder(primitive.NewObjectID().Hex())
+
+	rsp := &grpc.ApplyOrderCouponResponse{}
+	err := suite.service.ApplyOrderCoupon(context.TODO(), &grpc.ApplyOrderCouponRequest{OrderId: order.Uuid, Code: "UNKNOWN"}, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusNotFound, rsp.Status)
+	assert.Equal(suite.T(), errorCouponNotFound, rsp.Message)
+}
+
+func (suite *CouponTestSuite) TestCoupon_ApplyOrderCoupon_Error_UsageLimitReached() {
+	coupon := suite.setCoupon(&billing.Coupon{
+		Code:               "SALE",
+		DiscountType:       pkg.CouponDiscountTypePercent,
+		Percent:            10,
+		MaxUsesPerCustomer: 1,
+	})
+	customerId := primitive.NewObjectID().Hex()
+	order := suite.createOrder(customerId)
+
+	rsp := &grpc.ApplyOrderCouponResponse{}
+	err := suite.service.ApplyOrderCoupon(context.TODO(), &grpc.ApplyOrderCouponRequest{OrderId: order.Uuid, Code: "SALE"}, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	order, err = suite.service.getOrderByUuid(context.TODO(), order.Uuid)
+	assert.NoError(suite.T(), err)
+	order = helperPayOrder(suite.Suite, suite.service, order, suite.paymentMethod, "RU")
+	assert.NotNil(suite.T(), order.Coupon)
+
+	coupon, err = suite.service.coupon.GetById(context.TODO(), coupon.Id)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, coupon.UsedCount)
+
+	order = suite.createOrder(customerId)
+	err = suite.service.ApplyOrderCoupon(context.TODO(), &grpc.ApplyOrderCouponRequest{OrderId: order.Uuid, Code: "SALE"}, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), errorCouponCustomerUsageLimitReached, rsp.Message)
+
+	order = suite.createOrder(primitive.NewObjectID().Hex())
+	err = suite.service.ApplyOrderCoupon(context.TODO(), &grpc.ApplyOrderCouponRequest{OrderId: order.Uuid, Code: "SALE"}, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+}
+
+func (suite *CouponTestSuite) TestCoupon_RedeemOrderCoupon_UsageLimitReached() {
+	coupon := suite.setCoupon(&billing.Coupon{
+		Code:         "SALE",
+		DiscountType: pkg.CouponDiscountTypePercent,
+		Percent:      10,
+		MaxUses:      1,
+	})
+	order1 := suite.createOrder(primitive.NewObjectID().Hex())
+	order1.Coupon = &billing.OrderCoupon{Id: coupon.Id}
+	order2 := suite.createOrder(primitive.NewObjectID().Hex())
+	order2.Coupon = &billing.OrderCoupon{Id: coupon.Id}
+
+	assert.Nil(suite.T(), suite.service.redeemOrderCoupon(context.TODO(), order1))
+	assert.True(suite.T(), order1.Coupon.IsRedeemed)
+	assert.Nil(suite.T(), suite.service.redeemOrderCoupon(context.TODO(), order1))
+
+	assert.Equal(suite.T(), errorCouponUsageLimitReached, suite.service.redeemOrderCoupon(context.TODO(), order2))
+	assert.False(suite.T(), order2.Coupon.IsRedeemed)
+
+	suite.service.releaseOrderCoupon(context.TODO(), order1)
+	assert.False(suite.T(), order1.Coupon.IsRedeemed)
+
+	assert.Nil(suite.T(), suite.service.redeemOrderCoupon(context.TODO(), order2))
+
+	coupon, err := suite.service.coupon.GetById(context.TODO(), coupon.Id)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, coupon.UsedCount)
+}
+
+func (suite *CouponTestSuite) TestCoupon_RedeemOrderCoupon_CustomerUsageLimitReached() {
+	coupon := suite.setCoupon(&billing.Coupon{
+		Code:               "SALE",
+		DiscountType:       pkg.CouponDiscountTypePercent,
+		Percent:            10,
+		MaxUsesPerCustomer: 1,
+	})
+	customerId := primitive.NewObjectID().Hex()
+	order1 := suite.createOrder(customerId)
+	order1.Coupon = &billing.OrderCoupon{Id: coupon.Id}
+	order2 := suite.createOrder(customerId)
+	order2.Coupon = &billing.OrderCoupon{Id: coupon.Id}
+
+	assert.Nil(suite.T(), suite.service.redeemOrderCoupon(context.TODO(), order1))
+	assert.Equal(suite.T(), errorCouponCustomerUsageLimitReached, suite.service.redeemOrderCoupon(context.TODO(), order2))
+
+	coupon, err := suite.service.coupon.GetById(context.TODO(), coupon.Id)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, coupon.UsedCount)
+
+	count, err := suite.service.coupon.CountCustomerUses(context.TODO(), coupon.Id, customerId)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, count)
+}
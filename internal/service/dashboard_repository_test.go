@@ -105,7 +105,7 @@ func (suite *DashboardRepositoryTestSuite) SetupTest() {
 	if err != nil {
 		suite.FailNow("Config load failed", "%v", err)
 	}
-	cfg.CardPayApiUrl = "https://sandbox.cardpay.com"
+	cfg.CardPay.ApiUrl = "https://sandbox.cardpay.com"
 
 	m, err := migrate.New(
 		"file://../../migrations/tests",
@@ -139,7 +139,7 @@ func (suite *DashboardRepositoryTestSuite) SetupTest() {
 		mocks.NewRepositoryServiceOk(),
 		mocks.NewTaxServiceOkMock(),
 		mocks.NewBrokerMockOk(),
-		nil,
+		redisdb,
 		suite.cache,
 		mocks.NewCurrencyServiceMockOk(),
 		mocks.NewDocumentSignerMockOk(),
@@ -0,0 +1,668 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/constant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"net"
+	"time"
+)
+
+const (
+	collectionDispute = "dispute"
+
+	disputeChargebackReasonMask = "Chargeback by dispute #%s"
+)
+
+var (
+	disputeErrorNotFound                = newBillingServerErrorMsg("dsp00001", "dispute not found")
+	disputeErrorOrderNotFound           = newBillingServerErrorMsg("dsp00002", "order of dispute not found")
+	disputeErrorOrderNotAllowed         = newBillingServerErrorMsg("dsp00003", "dispute can't be opened for order with current status")
+	disputeErrorAlreadyOpened           = newBillingServerErrorMsg("dsp00004", "order already has open dispute")
+	disputeErrorStatusChangeNotAllowed  = newBillingServerErrorMsg("dsp00005", "dispute can't be moved from current status to requested status")
+	disputeErrorClosed                  = newBillingServerErrorMsg("dsp00006", "dispute is closed")
+	disputeErrorDeadlineExpired         = newBillingServerErrorMsg("dsp00007", "deadline to respond to dispute expired")
+	disputeErrorChargebackNotProcessed  = newBillingServerErrorMsg("dsp00008", "chargeback of dispute isn't processed by payment system yet")
+	disputeErrorChargebackReverseFailed = newBillingServerErrorMsg("dsp00009", "reversal of chargeback of dispute failed")
+
+	// statuses to which dispute can be moved from the status
+	disputeAllowedTransitions = map[string][]string{
+		pkg.DisputeStatusInquiry:       {pkg.DisputeStatusChargeback, pkg.DisputeStatusWon},
+		pkg.DisputeStatusChargeback:    {pkg.DisputeStatusRepresentment, pkg.DisputeStatusLost},
+		pkg.DisputeStatusRepresentment: {pkg.DisputeStatusWon, pkg.DisputeStatusLost},
+	}
+
+	disputeClosedStatuses = []string{pkg.DisputeStatusWon, pkg.DisputeStatusLost}
+
+	disputeWebhookEventTypes = map[string]string{
+		pkg.DisputeStatusInquiry:       pkg.WebhookEventTypeDisputeInquiry,
+		pkg.DisputeStatusChargeback:    pkg.WebhookEventTypeDisputeChargeback,
+		pkg.DisputeStatusRepresentment: pkg.WebhookEventTypeDisputeRepresentment,
+		pkg.DisputeStatusWon:           pkg.WebhookEventTypeDisputeWon,
+		pkg.DisputeStatusLost:          pkg.WebhookEventTypeDisputeLost,
+	}
+)
+
+type DisputeRepositoryInterface interface {
+	Insert(ctx context.Context, dispute *billing.Dispute) error
+	Update(ctx context.Context, dispute *billing.Dispute) error
+	GetById(ctx context.Context, id string) (*billing.Dispute, error)
+	// GetOpenByOrderId returns not closed dispute of order, returns disputeErrorNotFound if order hasn't open dispute
+	GetOpenByOrderId(ctx context.Context, orderId string) (*billing.Dispute, error)
+	Find(ctx context.Context, query bson.M, offset, limit int64) ([]*billing.Dispute, error)
+	Count(ctx context.Context, query bson.M) (int64, error)
+}
+
+func newDisputeRepository(svc *Service) DisputeRepositoryInterface {
+	s := &Dispute{svc: svc}
+	return s
+}
+
+type disputeKeyActivation struct {
+	KeyId      string    `json:"key_id"`
+	PlatformId string    `json:"platform_id"`
+	RedeemedAt time.Time `json:"redeemed_at"`
+}
+
+type disputeIpHistoryItem struct {
+	Ip        string    `json:"ip"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (s *Service) OpenDispute(
+	ctx context.Context,
+	req *grpc.OpenDisputeRequest,
+	rsp *grpc.DisputeResponse,
+) error {
+	order, err := s.getOrderByUuid(ctx, req.OrderId)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusNotFound
+		rsp.Message = disputeErrorOrderNotFound
+		return nil
+	}
+
+	if !order.RefundAllowed() {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = disputeErrorOrderNotAllowed
+		return nil
+	}
+
+	_, err = s.dispute.GetOpenByOrderId(ctx, order.Id)
+
+	if err != disputeErrorNotFound {
+		if err != nil {
+			return err
+		}
+
+		rsp.Status = pkg.ResponseStatusConflict
+		rsp.Message = disputeErrorAlreadyOpened
+		return nil
+	}
+
+	dispute := &billing.Dispute{
+		Id:         primitive.NewObjectID().Hex(),
+		OrderId:    order.Id,
+		OrderUuid:  order.Uuid,
+		MerchantId: order.GetMerchantId(),
+		ProjectId:  order.GetProjectId(),
+		ExternalId: req.ExternalId,
+		ReasonCode: req.ReasonCode,
+		Amount:     order.ChargeAmount,
+		Currency:   order.ChargeCurrency,
+		Evidence:   s.collectDisputeEvidence(ctx, order),
+		CreatedAt:  ptypes.TimestampNow(),
+	}
+
+	// dispute is saved as inquiry before creation of chargeback refund, so chargeback refund always
+	// belongs to saved dispute and failed chargeback can be repeated by change of dispute status
+	if err = s.setDisputeStatus(ctx, dispute, order, pkg.DisputeStatusInquiry, req.UserId, "", req.DueAt); err != nil {
+		return err
+	}
+
+	if err = s.dispute.Insert(ctx, dispute); err != nil {
+		return err
+	}
+
+	if _, err = s.addDisputeWebhookEvent(ctx, dispute, order); err != nil {
+		return err
+	}
+
+	if req.Status != pkg.DisputeStatusInquiry {
+		err = s.setDisputeStatus(ctx, dispute, order, req.Status, req.UserId, "", req.DueAt)
+
+		if err != nil {
+			if e, ok := err.(*grpc.ResponseError); ok {
+				rsp.Status = e.Status
+				rsp.Message = e.Message
+				return nil
+			}
+
+			return err
+		}
+
+		if err = s.dispute.Update(ctx, dispute); err != nil {
+			return err
+		}
+
+		if _, err = s.addDisputeWebhookEvent(ctx, dispute, order); err != nil {
+			return err
+		}
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = dispute
+
+	return nil
+}
+
+func (s *Service) ChangeDisputeStatus(
+	ctx context.Context,
+	req *grpc.ChangeDisputeStatusRequest,
+	rsp *grpc.DisputeResponse,
+) error {
+	dispute, err := s.dispute.GetById(ctx, req.DisputeId)
+
+	if err != nil || dispute.MerchantId != req.MerchantId {
+		rsp.Status = pkg.ResponseStatusNotFound
+		rsp.Message = disputeErrorNotFound
+		return nil
+	}
+
+	if !contains(disputeAllowedTransitions[dispute.Status], req.Status) {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = disputeErrorStatusChangeNotAllowed
+		return nil
+	}
+
+	order, err := s.getOrderById(ctx, dispute.OrderId)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusNotFound
+		rsp.Message = disputeErrorOrderNotFound
+		return nil
+	}
+
+	err = s.setDisputeStatus(ctx, dispute, order, req.Status, req.UserId, req.Comment, req.DueAt)
+
+	if err != nil {
+		if e, ok := err.(*grpc.ResponseError); ok {
+			rsp.Status = e.Status
+			rsp.Message = e.Message
+			return nil
+		}
+
+		return err
+	}
+
+	if err = s.dispute.Update(ctx, dispute); err != nil {
+		return err
+	}
+
+	if _, err = s.addDisputeWebhookEvent(ctx, dispute, order); err != nil {
+		return err
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = dispute
+
+	return nil
+}
+
+func (s *Service) AddDisputeEvidence(
+	ctx context.Context,
+	req *grpc.AddDisputeEvidenceRequest,
+	rsp *grpc.DisputeResponse,
+) error {
+	dispute, err := s.dispute.GetById(ctx, req.DisputeId)
+
+	if err != nil || dispute.MerchantId != req.MerchantId {
+		rsp.Status = pkg.ResponseStatusNotFound
+		rsp.Message = disputeErrorNotFound
+		return nil
+	}
+
+	if contains(disputeClosedStatuses, dispute.Status) {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = disputeErrorClosed
+		return nil
+	}
+
+	if dispute.DueAt != nil {
+		dueAt, err := ptypes.Timestamp(dispute.DueAt)
+
+		if err != nil {
+			return err
+		}
+
+		if time.Now().After(dueAt) {
+			rsp.Status = pkg.ResponseStatusBadData
+			rsp.Message = disputeErrorDeadlineExpired
+			return nil
+		}
+	}
+
+	dispute.Evidence = append(dispute.Evidence, &billing.DisputeEvidence{
+		Id:          primitive.NewObjectID().Hex(),
+		Type:        pkg.DisputeEvidenceTypeDocument,
+		Description: req.Description,
+		Content:     req.Content,
+		UserId:      req.UserId,
+		CreatedAt:   ptypes.TimestampNow(),
+	})
+	dispute.UpdatedAt = ptypes.TimestampNow()
+
+	if err = s.dispute.Update(ctx, dispute); err != nil {
+		return err
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = dispute
+
+	return nil
+}
+
+func (s *Service) GetDispute(
+	ctx context.Context,
+	req *grpc.GetDisputeRequest,
+	rsp *grpc.DisputeResponse,
+) error {
+	dispute, err := s.dispute.GetById(ctx, req.DisputeId)
+
+	if err != nil || dispute.MerchantId != req.MerchantId {
+		rsp.Status = pkg.ResponseStatusNotFound
+		rsp.Message = disputeErrorNotFound
+		return nil
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = dispute
+
+	return nil
+}
+
+func (s *Service) ListDisputes(
+	ctx context.Context,
+	req *grpc.ListDisputesRequest,
+	rsp *grpc.ListDisputesResponse,
+) (err error) {
+	merchantOid, _ := primitive.ObjectIDFromHex(req.MerchantId)
+	query := bson.M{"merchant_id": merchantOid}
+
+	if req.Status != "" {
+		query["status"] = req.Status
+	}
+
+	if req.DueBefore > 0 {
+		query["due_at"] = bson.M{"$lte": time.Unix(req.DueBefore, 0)}
+
+		if req.Status == "" {
+			query["status"] = bson.M{"$nin": disputeClosedStatuses}
+		}
+	}
+
+	rsp.Count, err = s.dispute.Count(ctx, query)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = orderErrorUnknown
+		return nil
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+
+	if rsp.Count <= 0 {
+		return nil
+	}
+
+	limit := req.Limit
+
+	if limit <= 0 {
+		limit = pkg.DatabaseRequestDefaultLimit
+	}
+
+	rsp.Items, err = s.dispute.Find(ctx, query, req.Offset, limit)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = orderErrorUnknown
+		rsp.Count = 0
+	}
+
+	return nil
+}
+
+// setDisputeStatus moves dispute to new status with processing of money movements required by the status:
+// chargeback refund is created on chargeback and reversed when dispute is won after chargeback
+func (s *Service) setDisputeStatus(
+	ctx context.Context,
+	dispute *billing.Dispute,
+	order *billing.Order,
+	status, userId, comment string,
+	dueAt int64,
+) error {
+	switch status {
+	case pkg.DisputeStatusChargeback:
+		req := &grpc.CreateRefundRequest{
+			OrderId:      order.Uuid,
+			Amount:       order.ChargeAmount,
+			CreatorId:    userId,
+			Reason:       fmt.Sprintf(disputeChargebackReasonMask, dispute.Id),
+			IsChargeback: true,
+			MerchantId:   order.GetMerchantId(),
+		}
+		rsp := &grpc.CreateRefundResponse{}
+		err := s.createRefund(ctx, req, rsp)
+
+		if err != nil {
+			return err
+		}
+
+		if rsp.Status != pkg.ResponseStatusOk {
+			return newBillingServerResponseError(rsp.Status, rsp.Message)
+		}
+
+		dispute.RefundId = rsp.Item.Id
+
+	case pkg.DisputeStatusWon:
+		if dispute.RefundId == "" {
+			break
+		}
+
+		if err := s.reverseDisputeChargeback(ctx, dispute, order); err != nil {
+			return err
+		}
+	}
+
+	dispute.Status = status
+	dispute.History = append(dispute.History, &billing.DisputeStatusChange{
+		Status:    status,
+		UserId:    userId,
+		Comment:   comment,
+		CreatedAt: ptypes.TimestampNow(),
+	})
+	dispute.UpdatedAt = ptypes.TimestampNow()
+
+	if contains(disputeClosedStatuses, status) {
+		dispute.DueAt = nil
+		dispute.ClosedAt = ptypes.TimestampNow()
+		return nil
+	}
+
+	t := time.Now().Add(s.cfg.GetDisputeResponseTimeout())
+
+	if dueAt > 0 {
+		t = time.Unix(dueAt, 0)
+	}
+
+	dispute.DueAt, _ = ptypes.TimestampProto(t)
+
+	return nil
+}
+
+// reverseDisputeChargeback creates accounting entries reversing chargeback of won dispute
+// and returns order from chargeback to processed status
+func (s *Service) reverseDisputeChargeback(ctx context.Context, dispute *billing.Dispute, order *billing.Order) error {
+	refund, err := s.getRefundById(ctx, dispute.RefundId)
+
+	if err != nil {
+		return newBillingServerResponseError(pkg.ResponseStatusSystemError, disputeErrorChargebackReverseFailed)
+	}
+
+	if refund.CreatedOrderId == "" {
+		return newBillingServerResponseError(pkg.ResponseStatusBadData, disputeErrorChargebackNotProcessed)
+	}
+
+	err = s.onDisputeWon(ctx, dispute, refund, order)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.MethodFinishedWithError,
+			zap.String("method", "onDisputeWon"),
+			zap.Error(err),
+			zap.String("dispute_id", dispute.Id),
+			zap.String("refund_id", refund.Id),
+		)
+		return newBillingServerResponseError(pkg.ResponseStatusSystemError, disputeErrorChargebackReverseFailed)
+	}
+
+	if order.PrivateStatus != constant.OrderStatusChargeback {
+		return nil
+	}
+
+	order.PrivateStatus = constant.OrderStatusProjectComplete
+	order.Status = constant.OrderPublicStatusProcessed
+	order.Refunded = false
+	order.RefundedAt = nil
+	order.Refund = nil
+	order.UpdatedAt = ptypes.TimestampNow()
+
+	return s.updateOrder(ctx, order)
+}
+
+// collectDisputeEvidence collects evidence of payment: receipt, activations of keys bought by order
+// and history of customer ip addresses
+func (s *Service) collectDisputeEvidence(ctx context.Context, order *billing.Order) []*billing.DisputeEvidence {
+	var evidence []*billing.DisputeEvidence
+
+	add := func(evidenceType, description string, content interface{}) {
+		var b []byte
+
+		if str, ok := content.(string); ok {
+			b = []byte(str)
+		} else {
+			var err error
+			b, err = json.Marshal(content)
+
+			if err != nil {
+				zap.L().Error(
+					"dispute evidence marshaling failed",
+					zap.Error(err),
+					zap.String("order_id", order.Id),
+					zap.String("type", evidenceType),
+				)
+				return
+			}
+		}
+
+		evidence = append(evidence, &billing.DisputeEvidence{
+			Id:          primitive.NewObjectID().Hex(),
+			Type:        evidenceType,
+			Description: description,
+			Content:     string(b),
+			CreatedAt:   ptypes.TimestampNow(),
+		})
+	}
+
+	if order.ReceiptUrl != "" {
+		add(pkg.DisputeEvidenceTypeReceipt, "Payment receipt", order.ReceiptUrl)
+	}
+
+	keys, err := s.keyRepository.FindByOrderId(ctx, order.Id)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionKey),
+			zap.String("order_id", order.Id),
+		)
+	}
+
+	var activations []*disputeKeyActivation
+
+	for _, key := range keys {
+		if key.RedeemedAt == nil {
+			continue
+		}
+
+		redeemedAt, err := ptypes.Timestamp(key.RedeemedAt)
+
+		if err != nil || redeemedAt.IsZero() || redeemedAt.Unix() <= 0 {
+			continue
+		}
+
+		activations = append(activations, &disputeKeyActivation{
+			KeyId:      key.Id,
+			PlatformId: key.PlatformId,
+			RedeemedAt: redeemedAt,
+		})
+	}
+
+	if len(activations) > 0 {
+		add(pkg.DisputeEvidenceTypeKeyActivation, "Activation of keys bought by customer", activations)
+	}
+
+	if order.User == nil || order.User.Id == "" {
+		return evidence
+	}
+
+	customer, err := s.getCustomerById(ctx, order.User.Id)
+
+	if err != nil || len(customer.IpHistory) <= 0 {
+		return evidence
+	}
+
+	history := make([]*disputeIpHistoryItem, 0, len(customer.IpHistory))
+
+	for _, v := range customer.IpHistory {
+		item := &disputeIpHistoryItem{Ip: net.IP(v.Ip).String()}
+
+		if v.CreatedAt != nil {
+			item.CreatedAt, _ = ptypes.Timestamp(v.CreatedAt)
+		}
+
+		history = append(history, item)
+	}
+
+	add(pkg.DisputeEvidenceTypeIpHistory, "History of customer ip addresses", history)
+
+	return evidence
+}
+
+func (h *Dispute) Insert(ctx context.Context, dispute *billing.Dispute) error {
+	_, err := h.svc.db.Collection(collectionDispute).InsertOne(ctx, dispute)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionDispute),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationInsert),
+			zap.Any(pkg.ErrorDatabaseFieldDocument, dispute),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func (h *Dispute) Update(ctx context.Context, dispute *billing.Dispute) error {
+	oid, _ := primitive.ObjectIDFromHex(dispute.Id)
+	filter := bson.M{"_id": oid}
+	_, err := h.svc.db.Collection(collectionDispute).ReplaceOne(ctx, filter, dispute)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionDispute),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationUpdate),
+			zap.Any(pkg.ErrorDatabaseFieldDocument, dispute),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func (h *Dispute) GetById(ctx context.Context, id string) (*billing.Dispute, error) {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	return h.findOne(ctx, bson.M{"_id": oid})
+}
+
+func (h *Dispute) GetOpenByOrderId(ctx context.Context, orderId string) (*billing.Dispute, error) {
+	oid, _ := primitive.ObjectIDFromHex(orderId)
+	return h.findOne(ctx, bson.M{"order_id": oid, "status": bson.M{"$nin": disputeClosedStatuses}})
+}
+
+func (h *Dispute) Find(ctx context.Context, query bson.M, offset, limit int64) ([]*billing.Dispute, error) {
+	opts := options.Find().
+		SetSort(bson.M{"created_at": -1}).
+		SetSkip(offset).
+		SetLimit(limit)
+	cursor, err := h.svc.db.Collection(collectionDispute).Find(ctx, query, opts)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionDispute),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	var result []*billing.Dispute
+	err = cursor.All(ctx, &result)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionDispute),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (h *Dispute) Count(ctx context.Context, query bson.M) (int64, error) {
+	count, err := h.svc.db.Collection(collectionDispute).CountDocuments(ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionDispute),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationCount),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (h *Dispute) findOne(ctx context.Context, filter bson.M) (*billing.Dispute, error) {
+	dispute := &billing.Dispute{}
+	err := h.svc.db.Collection(collectionDispute).FindOne(ctx, filter).Decode(dispute)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, disputeErrorNotFound
+		}
+
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionDispute),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, filter),
+		)
+		return nil, err
+	}
+
+	return dispute, nil
+}
@@ -0,0 +1,401 @@
+package service
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/go-redis/redis"
+	"github.com/golang-migrate/migrate/v4"
+	casbinMocks "github.com/paysuper/casbin-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/internal/config"
+	"github.com/paysuper/paysuper-billing-server/internal/database"
+	"github.com/paysuper/paysuper-billing-server/internal/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/constant"
+	reportingMocks "github.com/paysuper/paysuper-reporter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	rabbitmq "gopkg.in/ProtocolONE/rabbitmq.v1/pkg"
+	mongodb "gopkg.in/paysuper/paysuper-database-mongo.v1"
+	"testing"
+	"time"
+)
+
+type DisputeTestSuite struct {
+	suite.Suite
+	service *Service
+	cache   CacheInterface
+
+	project       *billing.Project
+	paymentMethod *billing.PaymentMethod
+	userId        string
+}
+
+func Test_Dispute(t *testing.T) {
+	suite.Run(t, new(DisputeTestSuite))
+}
+
+func (suite *DisputeTestSuite) SetupTest() {
+	cfg, err := config.NewConfig()
+	if err != nil {
+		suite.FailNow("Config load failed", "%v", err)
+	}
+	cfg.CardPay.ApiUrl = "https://sandbox.cardpay.com"
+
+	m, err := migrate.New(
+		"file://../../migrations/tests",
+		cfg.MongoDsn)
+	assert.NoError(suite.T(), err, "Migrate init failed")
+
+	err = m.Up()
+	if err != nil && err.Error() != "no change" {
+		suite.FailNow("Migrations failed", "%v", err)
+	}
+
+	db, err := mongodb.NewDatabase()
+	if err != nil {
+		suite.FailNow("Database connection failed", "%v", err)
+	}
+
+	broker, err := rabbitmq.NewBroker(cfg.BrokerAddress)
+
+	if err != nil {
+		suite.FailNow("Creating RabbitMQ publisher failed", "%v", err)
+	}
+
+	redisClient := database.NewRedis(
+		&redis.Options{
+			Addr:     cfg.RedisHost,
+			Password: cfg.RedisPassword,
+		},
+	)
+
+	redisdb := mocks.NewTestRedis()
+	suite.cache, err = NewCacheRedis(redisdb, "cache")
+	suite.service = NewBillingService(
+		db,
+		cfg,
+		mocks.NewGeoIpServiceTestOk(),
+		mocks.NewRepositoryServiceOk(),
+		mocks.NewTaxServiceOkMock(),
+		broker,
+		redisClient,
+		suite.cache,
+		mocks.NewCurrencyServiceMockOk(),
+		mocks.NewDocumentSignerMockOk(),
+		&reportingMocks.ReporterService{},
+		mocks.NewFormatterOK(),
+		broker,
+		&casbinMocks.CasbinService{},
+	)
+
+	if err := suite.service.Init(); err != nil {
+		suite.FailNow("Billing service initialization failed", "%v", err)
+	}
+
+	_, suite.project, suite.paymentMethod, _ = helperCreateEntitiesForTests(suite.Suite, suite.service)
+	suite.userId = primitive.NewObjectID().Hex()
+}
+
+func (suite *DisputeTestSuite) TearDownTest() {
+	if err := suite.service.db.Drop(); err != nil {
+		suite.FailNow("Database deletion failed", "%v", err)
+	}
+
+	if err := suite.service.db.Close(); err != nil {
+		suite.FailNow("Database close failed", "%v", err)
+	}
+}
+
+func (suite *DisputeTestSuite) TestDispute_OpenDispute_Ok() {
+	order := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+	dispute := suite.openDispute(order, pkg.DisputeStatusInquiry)
+	assert.Equal(suite.T(), order.Id, dispute.OrderId)
+	assert.Equal(suite.T(), suite.project.MerchantId, dispute.MerchantId)
+	assert.Equal(suite.T(), order.ChargeAmount, dispute.Amount)
+	assert.Empty(suite.T(), dispute.RefundId)
+	assert.NotNil(suite.T(), dispute.DueAt)
+	assert.Nil(suite.T(), dispute.ClosedAt)
+	assert.Len(suite.T(), dispute.History, 1)
+	assert.Equal(suite.T(), suite.userId, dispute.History[0].UserId)
+
+	req := &grpc.OpenDisputeRequest{
+		UserId:  suite.userId,
+		OrderId: order.Uuid,
+		Status:  pkg.DisputeStatusInquiry,
+	}
+	rsp := &grpc.DisputeResponse{}
+	err := suite.service.OpenDispute(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusConflict, rsp.Status)
+	assert.Equal(suite.T(), disputeErrorAlreadyOpened, rsp.Message)
+
+	rsp1 := &grpc.ListDisputesResponse{}
+	err = suite.service.ListDisputes(
+		context.TODO(),
+		&grpc.ListDisputesRequest{MerchantId: suite.project.MerchantId, DueBefore: time.Now().AddDate(1, 0, 0).Unix()},
+		rsp1,
+	)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp1.Status)
+	assert.EqualValues(suite.T(), 1, rsp1.Count)
+	assert.Equal(suite.T(), dispute.Id, rsp1.Items[0].Id)
+}
+
+func (suite *DisputeTestSuite) TestDispute_OpenDispute_OrderNotFound_Error() {
+	req := &grpc.OpenDisputeRequest{
+		UserId:  suite.userId,
+		OrderId: "1cfb7b33-0d3e-4d2c-a7bd-5f7c0a7d2d1b",
+		Status:  pkg.DisputeStatusInquiry,
+	}
+	rsp := &grpc.DisputeResponse{}
+	err := suite.service.OpenDispute(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusNotFound, rsp.Status)
+	assert.Equal(suite.T(), disputeErrorOrderNotFound, rsp.Message)
+}
+
+func (suite *DisputeTestSuite) TestDispute_ChangeDisputeStatus_NotAllowed_Error() {
+	order := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+	dispute := suite.openDispute(order, pkg.DisputeStatusInquiry)
+
+	rsp := suite.changeDisputeStatus(dispute.Id, pkg.DisputeStatusLost)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), disputeErrorStatusChangeNotAllowed, rsp.Message)
+
+	rsp = suite.changeDisputeStatus(dispute.Id, pkg.DisputeStatusWon)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Equal(suite.T(), pkg.DisputeStatusWon, rsp.Item.Status)
+	assert.NotNil(suite.T(), rsp.Item.ClosedAt)
+	assert.Nil(suite.T(), rsp.Item.DueAt)
+	assert.Len(suite.T(), rsp.Item.History, 2)
+
+	rsp = suite.changeDisputeStatus(dispute.Id, pkg.DisputeStatusLost)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), disputeErrorStatusChangeNotAllowed, rsp.Message)
+}
+
+func (suite *DisputeTestSuite) TestDispute_ChangeDisputeStatus_OtherMerchant_Error() {
+	order := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+	dispute := suite.openDispute(order, pkg.DisputeStatusInquiry)
+
+	req := &grpc.ChangeDisputeStatusRequest{
+		UserId:     suite.userId,
+		MerchantId: primitive.NewObjectID().Hex(),
+		DisputeId:  dispute.Id,
+		Status:     pkg.DisputeStatusWon,
+	}
+	rsp := &grpc.DisputeResponse{}
+	err := suite.service.ChangeDisputeStatus(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusNotFound, rsp.Status)
+	assert.Equal(suite.T(), disputeErrorNotFound, rsp.Message)
+
+	dispute, err = suite.service.dispute.GetById(context.TODO(), dispute.Id)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.DisputeStatusInquiry, dispute.Status)
+}
+
+func (suite *DisputeTestSuite) TestDispute_OpenDispute_ChargebackFailed_DisputeSaved() {
+	order := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+
+	ps, err := suite.service.paymentSystem.GetById(context.TODO(), suite.paymentMethod.PaymentSystemId)
+	assert.NoError(suite.T(), err)
+
+	ps.Handler = paymentSystemHandlerMockError
+	err = suite.service.paymentSystem.Update(context.TODO(), ps)
+	assert.NoError(suite.T(), err)
+
+	req := &grpc.OpenDisputeRequest{
+		UserId:  suite.userId,
+		OrderId: order.Uuid,
+		Status:  pkg.DisputeStatusChargeback,
+	}
+	rsp := &grpc.DisputeResponse{}
+	err = suite.service.OpenDispute(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.NotEqual(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	dispute, err := suite.service.dispute.GetOpenByOrderId(context.TODO(), order.Id)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.DisputeStatusInquiry, dispute.Status)
+	assert.Empty(suite.T(), dispute.RefundId)
+}
+
+func (suite *DisputeTestSuite) TestDispute_AddDisputeEvidence_Ok() {
+	order := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+	dispute := suite.openDispute(order, pkg.DisputeStatusInquiry)
+	evidenceCount := len(dispute.Evidence)
+
+	req := &grpc.AddDisputeEvidenceRequest{
+		UserId:      suite.userId,
+		MerchantId:  suite.project.MerchantId,
+		DisputeId:   dispute.Id,
+		Description: "Chat with customer",
+		Content:     "https://unit.test/chat.pdf",
+	}
+	rsp := &grpc.DisputeResponse{}
+	err := suite.service.AddDisputeEvidence(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Len(suite.T(), rsp.Item.Evidence, evidenceCount+1)
+
+	rsp1 := &grpc.DisputeResponse{}
+	err = suite.service.GetDispute(
+		context.TODO(),
+		&grpc.GetDisputeRequest{MerchantId: suite.project.MerchantId, DisputeId: dispute.Id},
+		rsp1,
+	)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp1.Status)
+	evidence := rsp1.Item.Evidence[evidenceCount]
+	assert.Equal(suite.T(), pkg.DisputeEvidenceTypeDocument, evidence.Type)
+	assert.Equal(suite.T(), req.Content, evidence.Content)
+	assert.Equal(suite.T(), suite.userId, evidence.UserId)
+
+	req.MerchantId = primitive.NewObjectID().Hex()
+	rsp = &grpc.DisputeResponse{}
+	err = suite.service.AddDisputeEvidence(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusNotFound, rsp.Status)
+	assert.Equal(suite.T(), disputeErrorNotFound, rsp.Message)
+}
+
+func (suite *DisputeTestSuite) TestDispute_Won_ReversesChargeback() {
+	order := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+	dispute := suite.openDispute(order, pkg.DisputeStatusChargeback)
+	assert.NotEmpty(suite.T(), dispute.RefundId)
+
+	rsp := suite.changeDisputeStatus(dispute.Id, pkg.DisputeStatusRepresentment)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	rsp = suite.changeDisputeStatus(dispute.Id, pkg.DisputeStatusWon)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), disputeErrorChargebackNotProcessed, rsp.Message)
+
+	refund := suite.completeChargeback(order, dispute.RefundId)
+	order, err := suite.service.getOrderById(context.TODO(), order.Id)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), constant.OrderStatusChargeback, order.PrivateStatus)
+
+	rsp = suite.changeDisputeStatus(dispute.Id, pkg.DisputeStatusWon)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Equal(suite.T(), pkg.DisputeStatusWon, rsp.Item.Status)
+
+	chargebackEntries := suite.getAccountingEntries(refund.CreatedOrderId, collectionRefund)
+	reversalEntries := suite.getAccountingEntries(dispute.Id, collectionDispute)
+	assert.NotEmpty(suite.T(), reversalEntries)
+	assert.Len(suite.T(), reversalEntries, len(chargebackEntries))
+
+	for i, entry := range reversalEntries {
+		assert.Equal(suite.T(), chargebackEntries[i].Type, entry.Type)
+		assert.Equal(suite.T(), -chargebackEntries[i].Amount, entry.Amount)
+		assert.Equal(suite.T(), accountingEntryReasonDisputeWon, entry.Reason)
+	}
+
+	order, err = suite.service.getOrderById(context.TODO(), order.Id)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), constant.OrderStatusProjectComplete, order.PrivateStatus)
+	assert.False(suite.T(), order.Refunded)
+}
+
+func (suite *DisputeTestSuite) openDispute(order *billing.Order, status string) *billing.Dispute {
+	req := &grpc.OpenDisputeRequest{
+		UserId:     suite.userId,
+		OrderId:    order.Uuid,
+		Status:     status,
+		ReasonCode: "10.4",
+		ExternalId: primitive.NewObjectID().Hex(),
+	}
+	rsp := &grpc.DisputeResponse{}
+	err := suite.service.OpenDispute(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Equal(suite.T(), status, rsp.Item.Status)
+
+	return rsp.Item
+}
+
+func (suite *DisputeTestSuite) changeDisputeStatus(disputeId, status string) *grpc.DisputeResponse {
+	req := &grpc.ChangeDisputeStatusRequest{
+		UserId:     suite.userId,
+		MerchantId: suite.project.MerchantId,
+		DisputeId:  disputeId,
+		Status:     status,
+		Comment:    "unit test",
+	}
+	rsp := &grpc.DisputeResponse{}
+	err := suite.service.ChangeDisputeStatus(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+
+	return rsp
+}
+
+func (suite *DisputeTestSuite) completeChargeback(order *billing.Order, refundId string) *billing.Refund {
+	refundReq := &billing.CardPayRefundCallback{
+		MerchantOrder: &billing.CardPayMerchantOrder{
+			Id: refundId,
+		},
+		PaymentMethod: order.PaymentMethod.Group,
+		PaymentData: &billing.CardPayRefundCallbackPaymentData{
+			Id:              refundId,
+			RemainingAmount: 0,
+		},
+		RefundData: &billing.CardPayRefundCallbackRefundData{
+			Amount:   order.ChargeAmount,
+			Created:  time.Now().Format(cardPayDateFormat),
+			Id:       primitive.NewObjectID().Hex(),
+			Currency: order.Currency,
+			Status:   pkg.CardPayPaymentResponseStatusCompleted,
+			AuthCode: primitive.NewObjectID().Hex(),
+			Is_3D:    true,
+			Rrn:      primitive.NewObjectID().Hex(),
+		},
+		CallbackTime: time.Now().Format(cardPayDateFormat),
+		Customer: &billing.CardPayCustomer{
+			Email: order.User.Email,
+			Id:    order.User.Email,
+		},
+	}
+
+	b, err := json.Marshal(refundReq)
+	assert.NoError(suite.T(), err)
+
+	hash := sha512.New()
+	hash.Write([]byte(string(b) + order.PaymentMethod.Params.SecretCallback))
+
+	req := &grpc.CallbackRequest{
+		Handler:   pkg.PaymentSystemHandlerCardPay,
+		Body:      b,
+		Signature: hex.EncodeToString(hash.Sum(nil)),
+	}
+	rsp := &grpc.PaymentNotifyResponse{}
+	err = suite.service.ProcessRefundCallback(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	refund, err := suite.service.getRefundById(context.TODO(), refundId)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.RefundStatusCompleted, refund.Status)
+	assert.True(suite.T(), refund.IsChargeback)
+
+	return refund
+}
+
+func (suite *DisputeTestSuite) getAccountingEntries(sourceId, sourceType string) []*billing.AccountingEntry {
+	oid, _ := primitive.ObjectIDFromHex(sourceId)
+	query := bson.M{"source.id": oid, "source.type": sourceType}
+	cursor, err := suite.service.db.Collection(collectionAccountingEntry).Find(context.TODO(), query)
+	assert.NoError(suite.T(), err)
+
+	var entries []*billing.AccountingEntry
+	err = cursor.All(context.TODO(), &entries)
+	assert.NoError(suite.T(), err)
+
+	return entries
+}
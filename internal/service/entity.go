@@ -34,12 +34,25 @@ type MoneyBackCostSystem Entity
 type MoneyBackCostMerchant Entity
 type Paylink Entity
 type OperatingCompany Entity
+type PaymentRoutingRule Entity
+type WebhookEvent Entity
+type SettlementReport Entity
+type RiskRule Entity
+type SubscriptionPlan Entity
+type Subscription Entity
+type RefundApprovalThreshold Entity
+type Dispute Entity
+type BulkRefundJob Entity
+type Coupon Entity
+type StoreCredit Entity
+type Ledger Entity
 type PaymentMinLimitSystem Entity
 type PayoutCostSystem Entity
 type PriceTable Entity
 type Product Entity
 type Turnover Entity
 type Key Entity
+type KeyReservationSchedule Entity
 
 type Repository struct {
 	svc *Service
@@ -75,6 +88,15 @@ func contains(s []string, e string) bool {
 	return false
 }
 
+func containsInt32(s []int32, e int32) bool {
+	for _, a := range s {
+		if a == e {
+			return true
+		}
+	}
+	return false
+}
+
 func timeTrack(start time.Time, name string) {
 	elapsed := time.Since(start)
 	zap.L().Info(
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/constant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	// count of orders which gifts are delivered by one pass of gift daemon
+	giftDeliveryBatchSize = 100
+	// maximal delay of gift delivery after purchase
+	giftMaxDeliveryDelay = 365 * 24 * time.Hour
+	// delay of next attempt of gift delivery if sending of keys failed
+	giftDeliveryLockTimeout = 5 * time.Minute
+)
+
+var (
+	errorGiftOrderTypeInvalid = newBillingServerErrorMsg("gft00001", "gift can be purchased for order with key products only")
+	errorGiftDeliverAtInvalid = newBillingServerErrorMsg("gft00002", "gift can't be delivered later than a year after purchase")
+)
+
+// SetOrderGift makes order with key products a gift, keys of order are held after payment and delivered to
+// recipient of gift at the delivery date. Gift is removed from order if recipient is empty.
+func (s *Service) SetOrderGift(
+	ctx context.Context,
+	req *grpc.SetOrderGiftRequest,
+	rsp *grpc.SetOrderGiftResponse,
+) error {
+	order, err := s.getOrderByUuidToForm(ctx, req.OrderId)
+
+	if err != nil {
+		zap.S().Errorw(pkg.MethodFinishedWithError, "err", err.Error())
+		if e, ok := err.(*grpc.ResponseErrorMessage); ok {
+			rsp.Status = pkg.ResponseStatusBadData
+			rsp.Message = e
+			return nil
+		}
+		return err
+	}
+
+	if order.ProductType != billing.OrderType_key {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = errorGiftOrderTypeInvalid
+		return nil
+	}
+
+	order.Gift = nil
+
+	if req.RecipientEmail != "" {
+		if req.DeliverAt != nil && req.DeliverAt.Seconds > time.Now().Add(giftMaxDeliveryDelay).Unix() {
+			rsp.Status = pkg.ResponseStatusBadData
+			rsp.Message = errorGiftDeliverAtInvalid
+			return nil
+		}
+
+		order.Gift = &billing.OrderGift{
+			RecipientEmail: req.RecipientEmail,
+			Message:        req.Message,
+			DeliverAt:      req.DeliverAt,
+		}
+
+		if order.User != nil {
+			order.Gift.SenderName = order.User.Name
+		}
+	}
+
+	if err = s.updateOrder(ctx, order); err != nil {
+		zap.S().Errorw(pkg.MethodFinishedWithError, "err", err.Error())
+		if e, ok := err.(*grpc.ResponseErrorMessage); ok {
+			rsp.Status = pkg.ResponseStatusSystemError
+			rsp.Message = e
+			return nil
+		}
+		return err
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = order.Gift
+
+	return nil
+}
+
+// ProcessGiftDeliveries delivers keys of paid gifts which delivery date came
+func (s *Service) ProcessGiftDeliveries(ctx context.Context) (int, error) {
+	query := bson.M{
+		"gift.deliver_at":   bson.M{"$lte": time.Now()},
+		"gift.delivered_at": time.Time{},
+		"gift.locked_until": bson.M{"$lte": time.Now()},
+		"status":            constant.OrderPublicStatusProcessed,
+	}
+	opts := options.Find().SetLimit(giftDeliveryBatchSize)
+	cursor, err := s.db.Collection(collectionOrder).Find(ctx, query, opts)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionOrder),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return 0, err
+	}
+
+	var orders []*billing.Order
+	err = cursor.All(ctx, &orders)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionOrder),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return 0, err
+	}
+
+	counter := 0
+
+	for _, order := range orders {
+		if err = s.deliverOrderGift(ctx, order); err != nil {
+			continue
+		}
+
+		counter++
+	}
+
+	return counter, nil
+}
+
+// deliverOrderGift sends held keys of order to recipient of gift. Gift is locked for delivery before sending,
+// so keys are sent by one instance of service only, and marked as delivered after all keys were sent.
+// If sending failed gift delivery is retried by gift daemon after lock expired. Keys of pre-ordered products
+// which aren't uploaded yet are sent to recipient after upload.
+func (s *Service) deliverOrderGift(ctx context.Context, order *billing.Order) error {
+	now := time.Now()
+	oid, _ := primitive.ObjectIDFromHex(order.Id)
+	filter := bson.M{
+		"_id":               oid,
+		"gift.delivered_at": time.Time{},
+		"gift.locked_until": bson.M{"$lte": now},
+	}
+	update := bson.M{"$set": bson.M{"gift.locked_until": now.Add(giftDeliveryLockTimeout)}}
+	res, err := s.db.Collection(collectionOrder).UpdateOne(ctx, filter, update)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionOrder),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationUpdate),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, filter),
+		)
+		return err
+	}
+
+	// gift is delivered by another instance of service
+	if res.MatchedCount == 0 {
+		return nil
+	}
+
+	order.Gift.LockedUntil, _ = ptypes.TimestampProto(now.Add(giftDeliveryLockTimeout))
+	// keys of gift are sent by mail only when gift is delivered
+	order.Gift.DeliveredAt, _ = ptypes.TimestampProto(now)
+
+	for _, id := range order.Keys {
+		if id == "" {
+			continue
+		}
+
+		key, err := s.keyRepository.GetById(ctx, id)
+
+		if err == nil {
+			err = s.sendMailWithCode(ctx, order, key)
+		}
+
+		if err != nil {
+			zap.L().Error(
+				"Delivery of gift key failed",
+				zap.Error(err),
+				zap.String("order_id", order.Id),
+				zap.String("key_id", id),
+			)
+			order.Gift.DeliveredAt = nil
+			return err
+		}
+	}
+
+	filter = bson.M{"_id": oid}
+	update = bson.M{"$set": bson.M{"gift.delivered_at": now}}
+	_, err = s.db.Collection(collectionOrder).UpdateOne(ctx, filter, update)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionOrder),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationUpdate),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, filter),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func isOrderGiftDue(gift *billing.OrderGift) bool {
+	return gift.DeliveredAt == nil && (gift.DeliverAt == nil || gift.DeliverAt.Seconds <= time.Now().Unix())
+}
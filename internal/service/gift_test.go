@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang/protobuf/ptypes"
+	casbinMocks "github.com/paysuper/casbin-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/internal/config"
+	"github.com/paysuper/paysuper-billing-server/internal/database"
+	"github.com/paysuper/paysuper-billing-server/internal/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	reportingMocks "github.com/paysuper/paysuper-reporter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	mock2 "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	rabbitmq "gopkg.in/ProtocolONE/rabbitmq.v1/pkg"
+	mongodb "gopkg.in/paysuper/paysuper-database-mongo.v1"
+	"testing"
+	"time"
+)
+
+type GiftTestSuite struct {
+	suite.Suite
+	service *Service
+	cache   CacheInterface
+
+	project       *billing.Project
+	paymentMethod *billing.PaymentMethod
+}
+
+func Test_Gift(t *testing.T) {
+	suite.Run(t, new(GiftTestSuite))
+}
+
+func (suite *GiftTestSuite) SetupTest() {
+	cfg, err := config.NewConfig()
+	if err != nil {
+		suite.FailNow("Config load failed", "%v", err)
+	}
+
+	m, err := migrate.New(
+		"file://../../migrations/tests",
+		cfg.MongoDsn)
+	assert.NoError(suite.T(), err, "Migrate init failed")
+
+	err = m.Up()
+	if err != nil && err.Error() != "no change" {
+		suite.FailNow("Migrations failed", "%v", err)
+	}
+
+	db, err := mongodb.NewDatabase()
+	if err != nil {
+		suite.FailNow("Database connection failed", "%v", err)
+	}
+
+	broker, err := rabbitmq.NewBroker(cfg.BrokerAddress)
+
+	if err != nil {
+		suite.FailNow("Creating RabbitMQ publisher failed", "%v", err)
+	}
+
+	redisClient := database.NewRedis(
+		&redis.Options{
+			Addr:     cfg.RedisHost,
+			Password: cfg.RedisPassword,
+		},
+	)
+
+	redisdb := mocks.NewTestRedis()
+	suite.cache, err = NewCacheRedis(redisdb, "cache")
+	suite.service = NewBillingService(
+		db,
+		cfg,
+		mocks.NewGeoIpServiceTestOk(),
+		mocks.NewRepositoryServiceOk(),
+		mocks.NewTaxServiceOkMock(),
+		broker,
+		redisClient,
+		suite.cache,
+		mocks.NewCurrencyServiceMockOk(),
+		mocks.NewDocumentSignerMockOk(),
+		&reportingMocks.ReporterService{},
+		mocks.NewFormatterOK(),
+		broker,
+		&casbinMocks.CasbinService{},
+	)
+
+	if err := suite.service.Init(); err != nil {
+		suite.FailNow("Billing service initialization failed", "%v", err)
+	}
+
+	_, suite.project, suite.paymentMethod, _ = helperCreateEntitiesForTests(suite.Suite, suite.service)
+}
+
+func (suite *GiftTestSuite) TearDownTest() {
+	if err := suite.service.db.Drop(); err != nil {
+		suite.FailNow("Database deletion failed", "%v", err)
+	}
+
+	if err := suite.service.db.Close(); err != nil {
+		suite.FailNow("Database close failed", "%v", err)
+	}
+}
+
+func (suite *GiftTestSuite) createPaidGiftOrder(deliverAt time.Time) *billing.Order {
+	order := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+	assert.NotNil(suite.T(), order)
+
+	deliverAtProto, err := ptypes.TimestampProto(deliverAt)
+	assert.NoError(suite.T(), err)
+
+	order.Gift = &billing.OrderGift{
+		RecipientEmail: "recipient@unit.test",
+		Message:        "unit test",
+		DeliverAt:      deliverAtProto,
+	}
+	err = suite.service.updateOrder(context.TODO(), order)
+	assert.NoError(suite.T(), err)
+
+	return order
+}
+
+func (suite *GiftTestSuite) TestGift_ProcessGiftDeliveries_Ok() {
+	order := suite.createPaidGiftOrder(time.Now().Add(-time.Hour))
+
+	count, err := suite.service.ProcessGiftDeliveries(context.TODO())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, count)
+
+	order, err = suite.service.getOrderById(context.TODO(), order.Id)
+	assert.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), order.Gift)
+	assert.NotNil(suite.T(), order.Gift.DeliveredAt)
+
+	count, err = suite.service.ProcessGiftDeliveries(context.TODO())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count)
+}
+
+func (suite *GiftTestSuite) TestGift_ProcessGiftDeliveries_SendFailed_Retried() {
+	order := suite.createPaidGiftOrder(time.Now().Add(-time.Hour))
+	order.Keys = []string{primitive.NewObjectID().Hex()}
+	err := suite.service.updateOrder(context.TODO(), order)
+	assert.NoError(suite.T(), err)
+
+	keyRepository := &mocks.KeyRepositoryInterface{}
+	keyRepository.On("GetById", mock2.Anything, mock2.Anything).Return(nil, errors.New("key not found"))
+	suite.service.keyRepository = keyRepository
+
+	count, err := suite.service.ProcessGiftDeliveries(context.TODO())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count)
+
+	order, err = suite.service.getOrderById(context.TODO(), order.Id)
+	assert.NoError(suite.T(), err)
+	assert.Nil(suite.T(), order.Gift.DeliveredAt)
+	assert.NotNil(suite.T(), order.Gift.LockedUntil)
+
+	// delivery is retried after expiration of lock only
+	count, err = suite.service.ProcessGiftDeliveries(context.TODO())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count)
+	keyRepository.AssertNumberOfCalls(suite.T(), "GetById", 1)
+}
+
+func (suite *GiftTestSuite) TestGift_ProcessGiftDeliveries_NotDue() {
+	order := suite.createPaidGiftOrder(time.Now().Add(24 * time.Hour))
+
+	count, err := suite.service.ProcessGiftDeliveries(context.TODO())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count)
+
+	order, err = suite.service.getOrderById(context.TODO(), order.Id)
+	assert.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), order.Gift)
+	assert.Nil(suite.T(), order.Gift.DeliveredAt)
+}
+
+func (suite *GiftTestSuite) TestGift_SetOrderGift_Error_OrderTypeInvalid() {
+	req := &billing.OrderCreateRequest{
+		Type:        billing.OrderType_simple,
+		ProjectId:   suite.project.Id,
+		Currency:    "RUB",
+		Amount:      100,
+		Account:     "unit test",
+		Description: "unit test",
+		OrderId:     primitive.NewObjectID().Hex(),
+		User: &billing.OrderUser{
+			Email: "test@unit.unit",
+			Ip:    "127.0.0.1",
+		},
+	}
+	rsp := &grpc.OrderCreateProcessResponse{}
+	err := suite.service.OrderCreateProcess(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	req1 := &grpc.SetOrderGiftRequest{
+		OrderId:        rsp.Item.Uuid,
+		RecipientEmail: "recipient@unit.test",
+	}
+	rsp1 := &grpc.SetOrderGiftResponse{}
+	err = suite.service.SetOrderGift(context.TODO(), req1, rsp1)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp1.Status)
+	assert.Equal(suite.T(), errorGiftOrderTypeInvalid, rsp1.Message)
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis"
+	"github.com/golang/protobuf/proto"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	idempotencyStorageKey = "idempotency:%s:%s:%s"
+
+	idempotencyMethodOrderCreate   = "order_create"
+	idempotencyMethodPaymentCreate = "payment_create"
+	idempotencyMethodRefundCreate  = "refund_create"
+)
+
+var (
+	idempotencyErrorKeyConflict       = newBillingServerErrorMsg("ik000001", "idempotency key already used for request with different payload")
+	idempotencyErrorRequestInProgress = newBillingServerErrorMsg("ik000002", "request with same idempotency key is in progress. try request later")
+	idempotencyErrorStorageNotFound   = newBillingServerErrorMsg("ik000003", "storage of idempotency keys not configured")
+)
+
+type idempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	Completed   bool   `json:"completed"`
+	Response    []byte `json:"response,omitempty"`
+}
+
+type idempotentResponse interface {
+	proto.Message
+	GetStatus() int32
+}
+
+// processIdempotentRequest executes the request handler only once for the idempotency key of the request.
+// Repeated request with the same key and payload receives the response of the original request, repeated
+// request with the same key and different payload receives the conflict error. Responses with system error
+// aren't stored to allow retry of the request. While the request is in progress the key lives for the short
+// lock lifetime only and is prolonged until the request is finished, so the key of interrupted request doesn't
+// block retries for the whole key lifetime and the key of slow request isn't released before its end.
+func (s *Service) processIdempotentRequest(
+	method, scope, key string,
+	req proto.Message,
+	rsp idempotentResponse,
+	onConflict func(message *grpc.ResponseErrorMessage),
+	handler func() error,
+) error {
+	if key == "" {
+		return handler()
+	}
+
+	// request with idempotency key must not be processed without check of the key
+	if s.redis == nil {
+		zap.L().Error("idempotency key can't be checked, redis client not configured", zap.String("method", method))
+		return idempotencyErrorStorageNotFound
+	}
+
+	fingerprint, err := getIdempotencyFingerprint(req)
+
+	if err != nil {
+		return err
+	}
+
+	storageKey := fmt.Sprintf(idempotencyStorageKey, method, scope, key)
+	record := &idempotencyRecord{Fingerprint: fingerprint}
+	b, err := json.Marshal(record)
+
+	if err != nil {
+		return err
+	}
+
+	ok, err := s.redis.SetNX(storageKey, b, s.cfg.GetIdempotencyKeyLockLifetime()).Result()
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorCacheQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorCacheFieldCmd, "SETNX"),
+			zap.String(pkg.ErrorCacheFieldKey, storageKey),
+		)
+		return err
+	}
+
+	if !ok {
+		return s.replayIdempotentRequest(storageKey, fingerprint, rsp, onConflict)
+	}
+
+	stopLock := s.prolongIdempotencyKeyLock(storageKey)
+	err = handler()
+	stopLock()
+
+	if err != nil || rsp.GetStatus() == pkg.ResponseStatusSystemError {
+		if err1 := s.redis.Del(storageKey).Err(); err1 != nil {
+			zap.L().Error(
+				pkg.ErrorCacheQueryFailed,
+				zap.Error(err1),
+				zap.String(pkg.ErrorCacheFieldCmd, "DEL"),
+				zap.String(pkg.ErrorCacheFieldKey, storageKey),
+			)
+		}
+
+		return err
+	}
+
+	record.Completed = true
+	record.Response, err = proto.Marshal(rsp)
+
+	if err == nil {
+		b, err = json.Marshal(record)
+	}
+
+	if err == nil {
+		err = s.redis.Set(storageKey, b, s.cfg.GetIdempotencyKeyLifetime()).Err()
+	}
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorCacheQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorCacheFieldCmd, "SET"),
+			zap.String(pkg.ErrorCacheFieldKey, storageKey),
+		)
+	}
+
+	return nil
+}
+
+// prolongIdempotencyKeyLock extends lifetime of the lock of idempotency key until returned function is called
+func (s *Service) prolongIdempotencyKeyLock(storageKey string) func() {
+	lifetime := s.cfg.GetIdempotencyKeyLockLifetime()
+
+	if lifetime <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(lifetime / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := s.redis.Expire(storageKey, lifetime).Err(); err != nil {
+					zap.L().Error(
+						pkg.ErrorCacheQueryFailed,
+						zap.Error(err),
+						zap.String(pkg.ErrorCacheFieldCmd, "EXPIRE"),
+						zap.String(pkg.ErrorCacheFieldKey, storageKey),
+					)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func (s *Service) replayIdempotentRequest(
+	storageKey, fingerprint string,
+	rsp idempotentResponse,
+	onConflict func(message *grpc.ResponseErrorMessage),
+) error {
+	b, err := s.redis.Get(storageKey).Bytes()
+
+	if err != nil {
+		if err == redis.Nil {
+			onConflict(idempotencyErrorRequestInProgress)
+			return nil
+		}
+
+		zap.L().Error(
+			pkg.ErrorCacheQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorCacheFieldCmd, "GET"),
+			zap.String(pkg.ErrorCacheFieldKey, storageKey),
+		)
+		return err
+	}
+
+	record := &idempotencyRecord{}
+
+	if err = json.Unmarshal(b, record); err != nil {
+		return err
+	}
+
+	if record.Fingerprint != fingerprint {
+		onConflict(idempotencyErrorKeyConflict)
+		return nil
+	}
+
+	if !record.Completed {
+		onConflict(idempotencyErrorRequestInProgress)
+		return nil
+	}
+
+	return proto.Unmarshal(record.Response, rsp)
+}
+
+func getIdempotencyFingerprint(req proto.Message) (string, error) {
+	buf := proto.NewBuffer(nil)
+	buf.SetDeterministic(true)
+
+	if err := buf.Marshal(req); err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -13,6 +13,8 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -35,22 +37,49 @@ func (s *Service) UploadKeysFile(
 
 	res.TotalCount = int32(count)
 
-	// Process key by line
+	var (
+		keys   []*billing.Key
+		codes  []string
+		lines  []int32
+		line   int32
+		hashes = make(map[string]bool)
+	)
+
+	// Validate key by line
 	for scanner.Scan() {
-		key := &billing.Key{
-			Id:           primitive.NewObjectID().Hex(),
-			Code:         scanner.Text(),
-			KeyProductId: req.KeyProductId,
-			PlatformId:   req.PlatformId,
+		line++
+		code := strings.TrimSpace(scanner.Text())
+
+		if reason := validateKeyCode(req.PlatformId, code); reason != "" {
+			rejectKeysFileLine(res, line, reason)
+			continue
 		}
 
-		if err := s.keyRepository.Insert(ctx, key); err != nil {
-			zap.S().Errorf(errors.KeyErrorFailedToInsert.Message, "err", err, "key", key)
+		hash := s.getKeyCodeHash(code)
+
+		if hashes[hash] {
+			rejectKeysFileLine(res, line, pkg.KeyRejectReasonDuplicateInFile)
 			continue
 		}
 
-		res.TotalCount++
-		res.KeysProcessed++
+		hashes[hash] = true
+		encrypted, err := s.encryptKeyCode(code)
+
+		if err != nil {
+			zap.L().Error("Key code encryption failed", zap.Error(err), zap.Int32("line", line))
+			rejectKeysFileLine(res, line, pkg.KeyRejectReasonInsertFailed)
+			continue
+		}
+
+		keys = append(keys, &billing.Key{
+			Id:           primitive.NewObjectID().Hex(),
+			Code:         encrypted,
+			CodeHash:     hash,
+			KeyProductId: req.KeyProductId,
+			PlatformId:   req.PlatformId,
+		})
+		codes = append(codes, code)
+		lines = append(lines, line)
 	}
 
 	// tell about errors
@@ -61,11 +90,90 @@ func (s *Service) UploadKeysFile(
 		return nil
 	}
 
+	existing, err := s.getExistingKeyCodeHashes(ctx, req.PlatformId, keys, codes)
+
+	if err != nil {
+		res.Message = errors.KeyErrorDuplicates
+		res.Status = pkg.ResponseStatusSystemError
+		return nil
+	}
+
+	for i, key := range keys {
+		if existing[key.CodeHash] {
+			rejectKeysFileLine(res, lines[i], pkg.KeyRejectReasonAlreadyExists)
+			continue
+		}
+
+		if err := s.keyRepository.Insert(ctx, key); err != nil {
+			zap.S().Errorf(errors.KeyErrorFailedToInsert.Message, "err", err, "key_id", key.Id)
+			rejectKeysFileLine(res, lines[i], pkg.KeyRejectReasonInsertFailed)
+			continue
+		}
+
+		res.TotalCount++
+		res.KeysProcessed++
+	}
+
+	sort.Slice(res.RejectedLines, func(i, j int) bool {
+		return res.RejectedLines[i].Line < res.RejectedLines[j].Line
+	})
+
+	if res.KeysProcessed > 0 {
+		s.fulfilPreOrders(ctx, req.KeyProductId, req.PlatformId)
+		s.checkKeyProductStock(ctx, req.KeyProductId, req.PlatformId)
+	}
+
 	res.Status = pkg.ResponseStatusOk
 
 	return nil
 }
 
+// getExistingKeyCodeHashes returns hashes of codes of keys which already uploaded for platform
+func (s *Service) getExistingKeyCodeHashes(
+	ctx context.Context,
+	platformId string,
+	keys []*billing.Key,
+	codes []string,
+) (map[string]bool, error) {
+	existing := make(map[string]bool)
+
+	for i := 0; i < len(keys); i += keyCodeDuplicatesBatchSize {
+		end := i + keyCodeDuplicatesBatchSize
+
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		hashes := make([]string, 0, end-i)
+
+		for _, key := range keys[i:end] {
+			hashes = append(hashes, key.CodeHash)
+		}
+
+		found, err := s.keyRepository.FindByCodeHashes(ctx, platformId, hashes, codes[i:end])
+
+		if err != nil {
+			zap.L().Error(
+				pkg.ErrorDatabaseQueryFailed,
+				zap.Error(err),
+				zap.String(pkg.ErrorDatabaseFieldCollection, collectionKey),
+				zap.String("platform_id", platformId),
+			)
+			return nil, err
+		}
+
+		for _, key := range found {
+			if key.CodeHash == "" {
+				key.CodeHash = s.getKeyCodeHash(key.Code)
+			}
+
+			existing[key.CodeHash] = true
+		}
+	}
+
+	return existing, nil
+}
+
 func (s *Service) GetAvailableKeysCount(
 	ctx context.Context,
 	req *grpc.GetPlatformKeyCountRequest,
@@ -137,12 +245,15 @@ func (s *Service) ReserveKeyForOrder(
 			"orderId", req.OrderId,
 			"ttl", req.Ttl,
 		)
+		s.checkKeyProductStockOnReserve(ctx, req.KeyProductId, req.PlatformId)
 		res.Status = pkg.ResponseStatusBadData
 		res.Message = errors.KeyErrorReserve
 		return nil
 	}
 
 	zap.S().Infow("[ReserveKeyForOrder] reserved key", "req.order_id", req.OrderId, "key.order_id", key.OrderId, "key.id", key.Id, "key.RedeemedAt", key.RedeemedAt, "key.KeyProductId", key.KeyProductId)
+	s.scheduleKeyReservationExpiry(key)
+	s.checkKeyProductStockOnReserve(ctx, req.KeyProductId, req.PlatformId)
 
 	res.KeyId = key.Id
 	res.Status = pkg.ResponseStatusOk
@@ -164,6 +275,8 @@ func (s *Service) FinishRedeemKeyForOrder(
 		return nil
 	}
 
+	s.unscheduleKeyReservationExpiry(req.KeyId)
+
 	res.Key = key
 	res.Status = pkg.ResponseStatusOk
 
@@ -184,11 +297,16 @@ func (s *Service) CancelRedeemKeyForOrder(
 		return nil
 	}
 
+	s.unscheduleKeyReservationExpiry(req.KeyId)
+	keysReleasedCounter.WithLabelValues(keyReleaseReasonCanceled).Inc()
+
 	res.Status = pkg.ResponseStatusOk
 
 	return nil
 }
 
+// KeyDaemonProcess releases keys which reservations expired but were not released by reservation schedule,
+// e.g. reserved while redis was unavailable.
 func (s *Service) KeyDaemonProcess(ctx context.Context) (int, error) {
 	counter := 0
 	keys, err := s.keyRepository.FindUnfinished(ctx)
@@ -205,6 +323,9 @@ func (s *Service) KeyDaemonProcess(ctx context.Context) (int, error) {
 			continue
 		}
 
+		s.unscheduleKeyReservationExpiry(key.Id)
+		keysLeakedCounter.Inc()
+		keysReleasedCounter.WithLabelValues(keyReleaseReasonExpired).Inc()
 		counter++
 	}
 
@@ -216,9 +337,17 @@ type KeyRepositoryInterface interface {
 	GetById(context.Context, string) (*billing.Key, error)
 	ReserveKey(context.Context, string, string, string, int32) (*billing.Key, error)
 	CancelById(context.Context, string) (*billing.Key, error)
+	// CancelExpiredById releases key with specified identifier only if its reservation expired
+	CancelExpiredById(context.Context, string) (*billing.Key, error)
 	FinishRedeemById(context.Context, string) (*billing.Key, error)
 	CountKeysByProductPlatform(context.Context, string, string) (int64, error)
 	FindUnfinished(context.Context) ([]*billing.Key, error)
+	FindByOrderId(context.Context, string) ([]*billing.Key, error)
+	// RevokeById marks key as returned by refund with specified identifier
+	RevokeById(context.Context, string, string) (*billing.Key, error)
+	// FindByCodeHashes returns keys of platform with specified hashes of codes,
+	// keys uploaded before encryption of codes are found by plain codes
+	FindByCodeHashes(context.Context, string, []string, []string) ([]*billing.Key, error)
 }
 
 func newKeyRepository(svc *Service) *Key {
@@ -295,6 +424,38 @@ func (h *Key) CancelById(ctx context.Context, id string) (*billing.Key, error) {
 	var key *billing.Key
 	oid, _ := primitive.ObjectIDFromHex(id)
 	query := bson.M{"_id": oid}
+	update := bson.M{
+		"$set": bson.M{
+			"reserved_to": time.Time{},
+			"redeemed_at": time.Time{},
+			"order_id":    nil,
+		},
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := h.svc.db.Collection(collectionKey).FindOneAndUpdate(ctx, query, update, opts).Decode(&key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if key == nil {
+		return nil, errors.KeyErrorNotFound
+	}
+
+	return key, nil
+}
+
+func (h *Key) CancelExpiredById(ctx context.Context, id string) (*billing.Key, error) {
+	var key *billing.Key
+	oid, _ := primitive.ObjectIDFromHex(id)
+	query := bson.M{
+		"_id": oid,
+		"reserved_to": bson.M{
+			"$gt":  time.Time{},
+			"$lte": time.Now().UTC(),
+		},
+	}
 	update := bson.M{
 		"$set": bson.M{
 			"reserved_to": time.Time{},
@@ -376,3 +537,78 @@ func (h *Key) FindUnfinished(ctx context.Context) ([]*billing.Key, error) {
 
 	return keys, nil
 }
+
+func (h *Key) FindByOrderId(ctx context.Context, orderId string) ([]*billing.Key, error) {
+	var keys []*billing.Key
+
+	oid, _ := primitive.ObjectIDFromHex(orderId)
+	query := bson.M{"order_id": oid}
+	cursor, err := h.svc.db.Collection(collectionKey).Find(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = cursor.All(ctx, &keys)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (h *Key) FindByCodeHashes(
+	ctx context.Context,
+	platformId string,
+	hashes []string,
+	codes []string,
+) ([]*billing.Key, error) {
+	var keys []*billing.Key
+
+	query := bson.M{
+		"platform_id": platformId,
+		"$or": []bson.M{
+			{"code_hash": bson.M{"$in": hashes}},
+			{"code_hash": bson.M{"$exists": false}, "code": bson.M{"$in": codes}},
+		},
+	}
+	cursor, err := h.svc.db.Collection(collectionKey).Find(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = cursor.All(ctx, &keys)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (h *Key) RevokeById(ctx context.Context, id string, refundId string) (*billing.Key, error) {
+	var key *billing.Key
+	oid, _ := primitive.ObjectIDFromHex(id)
+	query := bson.M{"_id": oid}
+	update := bson.M{
+		"$set": bson.M{
+			"revoked_at": time.Now().UTC(),
+			"refund_id":  refundId,
+		},
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := h.svc.db.Collection(collectionKey).FindOneAndUpdate(ctx, query, update, opts).Decode(&key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if key == nil {
+		return nil, errors.KeyErrorNotFound
+	}
+
+	return key, nil
+}
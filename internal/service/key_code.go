@@ -0,0 +1,120 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	cryptoRand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"io"
+	"regexp"
+)
+
+const (
+	keyCodeMaxLength = 50
+	// count of keys checked for duplicates in database by one query
+	keyCodeDuplicatesBatchSize = 1000
+)
+
+var (
+	keyCodeDefaultPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9_\-]*[A-Za-z0-9])?$`)
+
+	errorKeyCodeCiphertextInvalid = errors.New("encrypted key code is invalid")
+)
+
+// validateKeyCode returns reason of rejection of key code or empty string if code is valid for platform
+func validateKeyCode(platformId, code string) string {
+	if code == "" {
+		return pkg.KeyRejectReasonEmpty
+	}
+
+	pattern, ok := platformKeyCodePatterns[platformId]
+
+	if !ok {
+		pattern = keyCodeDefaultPattern
+	}
+
+	if len(code) > keyCodeMaxLength || !pattern.MatchString(code) {
+		return pkg.KeyRejectReasonInvalidFormat
+	}
+
+	return ""
+}
+
+func rejectKeysFileLine(res *grpc.PlatformKeysFileResponse, line int32, reason string) {
+	res.RejectedLines = append(res.RejectedLines, &grpc.PlatformKeysFileRejectedLine{Line: line, Reason: reason})
+	res.KeysRejected++
+}
+
+// getKeyCodeHash returns keyed hash of code, it used to find duplicates of codes
+// because encrypted code is different on each encryption.
+func (s *Service) getKeyCodeHash(code string) string {
+	mac := hmac.New(sha256.New, []byte(s.cfg.KeyCodeEncryptionSecret))
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Service) getKeyCodeCipher() (cipher.AEAD, error) {
+	secret := sha256.Sum256([]byte(s.cfg.KeyCodeEncryptionSecret))
+	block, err := aes.NewCipher(secret[:])
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func (s *Service) encryptKeyCode(code string) (string, error) {
+	gcm, err := s.getKeyCodeCipher()
+
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+
+	if _, err = io.ReadFull(cryptoRand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(code), nil)), nil
+}
+
+// decryptKeyCode returns plain code of key. Keys uploaded before encryption of codes
+// have no code hash and stored as is.
+func (s *Service) decryptKeyCode(key *billing.Key) (string, error) {
+	if key.CodeHash == "" {
+		return key.Code, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(key.Code)
+
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := s.getKeyCodeCipher()
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return "", errorKeyCodeCiphertextInvalid
+	}
+
+	code, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(code), nil
+}
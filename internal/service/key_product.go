@@ -14,6 +14,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
 	"net/http"
+	"regexp"
 	"sort"
 	"strings"
 )
@@ -59,6 +60,18 @@ var availablePlatforms = map[string]*grpc.Platform{
 	"egs":      {Id: "egs", Name: "Epic Games Store", Icon: "https://cdn.pay.super.com/img/logo-platforms/logo-epic.png", Order: 9},
 }
 
+// Format of activation codes of available platforms, codes of user defined platforms checked by keyCodeDefaultPattern
+var platformKeyCodePatterns = map[string]*regexp.Regexp{
+	"steam":    regexp.MustCompile(`(?i)^[A-Z0-9]{4,5}(-[A-Z0-9]{4,5}){2,4}$`),
+	"gog":      regexp.MustCompile(`(?i)^[A-Z0-9]{4,5}(-?[A-Z0-9]{3,5}){2,4}$`),
+	"uplay":    regexp.MustCompile(`(?i)^[A-Z0-9]{3,4}(-[A-Z0-9]{4}){3,4}$`),
+	"origin":   regexp.MustCompile(`(?i)^[A-Z0-9]{4}(-[A-Z0-9]{4}){4}$`),
+	"psn":      regexp.MustCompile(`(?i)^[A-Z0-9]{4}(-[A-Z0-9]{4}){2}$`),
+	"xbox":     regexp.MustCompile(`(?i)^[A-Z0-9]{5}(-[A-Z0-9]{5}){4}$`),
+	"nintendo": regexp.MustCompile(`(?i)^[A-Z0-9]{16}$`),
+	"egs":      regexp.MustCompile(`(?i)^[A-Z0-9]{5}(-[A-Z0-9]{5}){3}$`),
+}
+
 func (s *Service) CreateOrUpdateKeyProduct(
 	ctx context.Context,
 	req *grpc.CreateOrUpdateKeyProductRequest,
@@ -273,8 +286,14 @@ func (s *Service) CreateOrUpdateKeyProduct(
 	product.Cover = req.Cover
 	product.Url = req.Url
 	product.Pricing = req.Pricing
+	product.PreOrderEnabled = req.PreOrderEnabled
 	product.UpdatedAt = now
 
+	if product.LowStockThreshold != req.LowStockThreshold {
+		product.LowStockThreshold = req.LowStockThreshold
+		product.LowStockNotifiedPlatforms = nil
+	}
+
 	oid, _ := primitive.ObjectIDFromHex(product.Id)
 	opts := options.Replace().SetUpsert(true)
 	filter := bson.M{"_id": oid}
@@ -10,8 +10,10 @@ import (
 	"github.com/paysuper/paysuper-billing-server/pkg"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/constant"
 	reportingMocks "github.com/paysuper/paysuper-reporter/pkg/mocks"
 	"github.com/stretchr/testify/assert"
+	mock2 "github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -94,7 +96,7 @@ func (suite *KeyProductTestSuite) SetupTest() {
 		mocks.NewRepositoryServiceOk(),
 		mocks.NewTaxServiceOkMock(),
 		broker,
-		nil,
+		redisdb,
 		suite.cache,
 		mocks.NewCurrencyServiceMockOk(),
 		mocks.NewDocumentSignerMockOk(),
@@ -800,3 +802,228 @@ func (suite *KeyProductTestSuite) Test_UnPublishKeyProduct() {
 	shouldBe.EqualValues(400, res.Status)
 	shouldBe.Equal(keyProductNotFound, res.Message)
 }
+
+func (suite *KeyProductTestSuite) Test_KeyProductLowStock_NotifiedOnceUntilRefill() {
+	shouldBe := require.New(suite.T())
+
+	centrifugoMock := &mocks.CentrifugoInterface{}
+	centrifugoMock.On("Publish", mock2.Anything, mock2.Anything, mock2.Anything).Return(nil)
+	suite.service.centrifugo = centrifugoMock
+
+	product := suite.createKeyProduct()
+	product.LowStockThreshold = 1
+	shouldBe.NoError(suite.service.keyProductRepository.Update(context.TODO(), product))
+
+	keysRsp := &grpc.PlatformKeysFileResponse{}
+	keysReq := &grpc.PlatformKeysFileRequest{
+		KeyProductId: product.Id,
+		PlatformId:   "steam",
+		MerchantId:   product.MerchantId,
+		File:         []byte("AAAAA-BBBBB-CCCC1\nAAAAA-BBBBB-CCCC2"),
+	}
+	shouldBe.NoError(suite.service.UploadKeysFile(context.TODO(), keysReq, keysRsp))
+	shouldBe.EqualValues(2, keysRsp.KeysProcessed)
+	centrifugoMock.AssertNumberOfCalls(suite.T(), "Publish", 0)
+
+	for i := 0; i < 2; i++ {
+		reserveRsp := &grpc.PlatformKeyReserveResponse{}
+		reserveReq := &grpc.PlatformKeyReserveRequest{
+			PlatformId:   "steam",
+			MerchantId:   product.MerchantId,
+			OrderId:      primitive.NewObjectID().Hex(),
+			KeyProductId: product.Id,
+			Ttl:          oneDayTtl,
+		}
+		shouldBe.NoError(suite.service.ReserveKeyForOrder(context.TODO(), reserveReq, reserveRsp))
+		shouldBe.EqualValues(pkg.ResponseStatusOk, reserveRsp.Status)
+	}
+
+	centrifugoMock.AssertNumberOfCalls(suite.T(), "Publish", 1)
+
+	product, err := suite.service.keyProductRepository.GetById(context.TODO(), product.Id)
+	shouldBe.NoError(err)
+	shouldBe.True(product.IsLowStockNotified("steam"))
+
+	keysRsp = &grpc.PlatformKeysFileResponse{}
+	keysReq.File = []byte("AAAAA-BBBBB-CCCC3\nAAAAA-BBBBB-CCCC4")
+	shouldBe.NoError(suite.service.UploadKeysFile(context.TODO(), keysReq, keysRsp))
+	shouldBe.EqualValues(2, keysRsp.KeysProcessed)
+
+	product, err = suite.service.keyProductRepository.GetById(context.TODO(), product.Id)
+	shouldBe.NoError(err)
+	shouldBe.False(product.IsLowStockNotified("steam"))
+	centrifugoMock.AssertNumberOfCalls(suite.T(), "Publish", 1)
+}
+
+func (suite *KeyProductTestSuite) Test_KeyProductPreOrder_FulfilledAfterUpload() {
+	shouldBe := require.New(suite.T())
+
+	product := suite.createKeyProduct()
+	product.PreOrderEnabled = true
+	shouldBe.NoError(suite.service.keyProductRepository.Update(context.TODO(), product))
+	shouldBe.True(suite.service.isKeyProductPreOrderEnabled(context.TODO(), product.Id))
+
+	var orders []*billing.Order
+
+	for i := 0; i < 2; i++ {
+		order := &billing.Order{
+			Id:            primitive.NewObjectID().Hex(),
+			Uuid:          primitive.NewObjectID().Hex(),
+			Project:       &billing.ProjectOrder{Id: projectId, MerchantId: merchantId},
+			PrivateStatus: constant.OrderStatusProjectComplete,
+			ProductType:   billing.OrderType_key,
+			PlatformId:    "steam",
+			Products:      []string{product.Id},
+			Keys:          []string{""},
+			IsPreOrder:    true,
+			Items:         []*billing.OrderItem{{Id: product.Id, Name: initialName}},
+			User:          &billing.OrderUser{Email: "test@unit.test"},
+		}
+		_, err := suite.service.db.Collection(collectionOrder).InsertOne(context.TODO(), order)
+		shouldBe.NoError(err)
+
+		orders = append(orders, order)
+	}
+
+	keysRsp := &grpc.PlatformKeysFileResponse{}
+	keysReq := &grpc.PlatformKeysFileRequest{
+		KeyProductId: product.Id,
+		PlatformId:   "steam",
+		MerchantId:   product.MerchantId,
+		File:         []byte("PREOR-DERED-CODE1"),
+	}
+	shouldBe.NoError(suite.service.UploadKeysFile(context.TODO(), keysReq, keysRsp))
+	shouldBe.EqualValues(1, keysRsp.KeysProcessed)
+
+	order, err := suite.service.getOrderById(context.TODO(), orders[0].Id)
+	shouldBe.NoError(err)
+	shouldBe.False(order.IsPreOrder)
+	shouldBe.NotEmpty(order.Keys[0])
+
+	key, err := suite.service.keyRepository.GetById(context.TODO(), order.Keys[0])
+	shouldBe.NoError(err)
+	code, err := suite.service.decryptKeyCode(key)
+	shouldBe.NoError(err)
+	shouldBe.Equal("PREOR-DERED-CODE1", code)
+	shouldBe.Equal(order.Id, key.OrderId)
+	shouldBe.NotNil(key.RedeemedAt)
+
+	order, err = suite.service.getOrderById(context.TODO(), orders[1].Id)
+	shouldBe.NoError(err)
+	shouldBe.True(order.IsPreOrder)
+	shouldBe.Empty(order.Keys[0])
+}
+
+func (suite *KeyProductTestSuite) Test_UploadKey_RejectedLinesReport() {
+	shouldBe := require.New(suite.T())
+	product := suite.createKeyProduct()
+
+	legacyKey := &billing.Key{
+		Id:           primitive.NewObjectID().Hex(),
+		Code:         "LEGAC-YKEYC-ODE01",
+		KeyProductId: product.Id,
+		PlatformId:   "steam",
+	}
+	shouldBe.NoError(suite.service.keyRepository.Insert(context.TODO(), legacyKey))
+
+	keysRsp := &grpc.PlatformKeysFileResponse{}
+	keysReq := &grpc.PlatformKeysFileRequest{
+		KeyProductId: product.Id,
+		PlatformId:   "steam",
+		MerchantId:   product.MerchantId,
+		File:         []byte("AAAAA-BBBBB-CCCCC\n\ninvalid code\nAAAAA-BBBBB-CCCCC\nDDDDD-EEEEE-FFFFF\nLEGAC-YKEYC-ODE01"),
+	}
+	shouldBe.NoError(suite.service.UploadKeysFile(context.TODO(), keysReq, keysRsp))
+	shouldBe.EqualValues(pkg.ResponseStatusOk, keysRsp.Status)
+	shouldBe.EqualValues(2, keysRsp.KeysProcessed)
+	shouldBe.EqualValues(3, keysRsp.TotalCount)
+	shouldBe.EqualValues(4, keysRsp.KeysRejected)
+	shouldBe.Equal([]*grpc.PlatformKeysFileRejectedLine{
+		{Line: 2, Reason: pkg.KeyRejectReasonEmpty},
+		{Line: 3, Reason: pkg.KeyRejectReasonInvalidFormat},
+		{Line: 4, Reason: pkg.KeyRejectReasonDuplicateInFile},
+		{Line: 6, Reason: pkg.KeyRejectReasonAlreadyExists},
+	}, keysRsp.RejectedLines)
+
+	keysRsp = &grpc.PlatformKeysFileResponse{}
+	keysReq.File = []byte("DDDDD-EEEEE-FFFFF\nGGGGG-HHHHH-IIIII")
+	shouldBe.NoError(suite.service.UploadKeysFile(context.TODO(), keysReq, keysRsp))
+	shouldBe.EqualValues(pkg.ResponseStatusOk, keysRsp.Status)
+	shouldBe.EqualValues(1, keysRsp.KeysProcessed)
+	shouldBe.Equal([]*grpc.PlatformKeysFileRejectedLine{{Line: 1, Reason: pkg.KeyRejectReasonAlreadyExists}}, keysRsp.RejectedLines)
+}
+
+func (suite *KeyProductTestSuite) Test_UploadKey_CodeEncryptedAtRest() {
+	shouldBe := require.New(suite.T())
+	product := suite.createKeyProduct()
+
+	keysRsp := &grpc.PlatformKeysFileResponse{}
+	keysReq := &grpc.PlatformKeysFileRequest{
+		KeyProductId: product.Id,
+		PlatformId:   "steam",
+		MerchantId:   product.MerchantId,
+		File:         []byte("AAAAA-BBBBB-CCCCC"),
+	}
+	shouldBe.NoError(suite.service.UploadKeysFile(context.TODO(), keysReq, keysRsp))
+	shouldBe.EqualValues(1, keysRsp.KeysProcessed)
+
+	reserveRsp := &grpc.PlatformKeyReserveResponse{}
+	reserveReq := &grpc.PlatformKeyReserveRequest{
+		PlatformId:   "steam",
+		MerchantId:   product.MerchantId,
+		OrderId:      primitive.NewObjectID().Hex(),
+		KeyProductId: product.Id,
+		Ttl:          oneDayTtl,
+	}
+	shouldBe.NoError(suite.service.ReserveKeyForOrder(context.TODO(), reserveReq, reserveRsp))
+	shouldBe.EqualValues(pkg.ResponseStatusOk, reserveRsp.Status)
+
+	key, err := suite.service.keyRepository.GetById(context.TODO(), reserveRsp.KeyId)
+	shouldBe.NoError(err)
+	shouldBe.NotEqual("AAAAA-BBBBB-CCCCC", key.Code)
+	shouldBe.Equal(suite.service.getKeyCodeHash("AAAAA-BBBBB-CCCCC"), key.CodeHash)
+
+	code, err := suite.service.decryptKeyCode(key)
+	shouldBe.NoError(err)
+	shouldBe.Equal("AAAAA-BBBBB-CCCCC", code)
+}
+
+func (suite *KeyProductTestSuite) Test_KeyProductPreOrder_StaleOrder_NotFulfilledTwice() {
+	shouldBe := require.New(suite.T())
+
+	product := suite.createKeyProduct()
+	order := &billing.Order{
+		Id:            primitive.NewObjectID().Hex(),
+		Uuid:          primitive.NewObjectID().Hex(),
+		Project:       &billing.ProjectOrder{Id: projectId, MerchantId: merchantId},
+		PrivateStatus: constant.OrderStatusProjectComplete,
+		ProductType:   billing.OrderType_key,
+		PlatformId:    "steam",
+		Products:      []string{product.Id},
+		Keys:          []string{""},
+		IsPreOrder:    true,
+		Items:         []*billing.OrderItem{{Id: product.Id, Name: initialName}},
+		User:          &billing.OrderUser{Email: "test@unit.test"},
+	}
+	_, err := suite.service.db.Collection(collectionOrder).InsertOne(context.TODO(), order)
+	shouldBe.NoError(err)
+
+	keysRsp := &grpc.PlatformKeysFileResponse{}
+	keysReq := &grpc.PlatformKeysFileRequest{
+		KeyProductId: product.Id,
+		PlatformId:   "steam",
+		MerchantId:   product.MerchantId,
+		File:         []byte("code-1\ncode-2"),
+	}
+	shouldBe.NoError(suite.service.UploadKeysFile(context.TODO(), keysReq, keysRsp))
+	shouldBe.EqualValues(2, keysRsp.KeysProcessed)
+
+	// order was loaded before fulfilment on upload of keys
+	shouldBe.True(suite.service.fulfilPreOrderKeys(context.TODO(), order))
+	shouldBe.False(order.IsPreOrder)
+	shouldBe.NotEmpty(order.Keys[0])
+
+	count, err := suite.service.keyRepository.CountKeysByProductPlatform(context.TODO(), product.Id, "steam")
+	shouldBe.NoError(err)
+	shouldBe.EqualValues(1, count)
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"github.com/go-redis/redis"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+	"strconv"
+	"time"
+)
+
+const (
+	keyReservationScheduleStorageKey = "key:reservation:expiry"
+	// count of expired key reservations released by one pass of key daemon
+	keyReservationReleaseBatchSize = 100
+	// time after order expiry during which key stays reserved, the order is canceled
+	// by order expiry and releases key before reservation expired
+	keyReservationExpiryGrace = 15 * time.Minute
+	// minimal lifetime of key reservation, it used for orders which expiry time already passed
+	keyReservationMinTtl = 5 * time.Minute
+
+	keyReleaseReasonCanceled = "canceled"
+	keyReleaseReasonExpired  = "expired"
+)
+
+var (
+	keysReservedCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "billing_keys_reserved_total",
+		Help: "Total count of keys reserved for orders",
+	})
+	keysReleasedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "billing_keys_released_total",
+		Help: "Total count of key reservations released, by reason of release",
+	}, []string{"reason"})
+	keysLeakedCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "billing_keys_leaked_total",
+		Help: "Total count of expired key reservations missed by reservation schedule and released by reconciliation",
+	})
+)
+
+type KeyReservationScheduleInterface interface {
+	// Add schedules release of reservation of key at specified time
+	Add(string, time.Time) error
+	// Remove removes key from schedule, returns false if key was not scheduled
+	Remove(string) (bool, error)
+	// FindExpired returns identifiers of keys which reservations expired to specified time
+	FindExpired(time.Time, int64) ([]string, error)
+	// GetNextExpiry returns time of the nearest expiry of reservation or zero time if schedule is empty
+	GetNextExpiry() (time.Time, error)
+}
+
+func newKeyReservationSchedule(svc *Service) KeyReservationScheduleInterface {
+	s := &KeyReservationSchedule{svc: svc}
+	return s
+}
+
+func (h *KeyReservationSchedule) Add(keyId string, expireAt time.Time) error {
+	member := redis.Z{Score: float64(expireAt.Unix()), Member: keyId}
+	return h.svc.redis.ZAdd(keyReservationScheduleStorageKey, member).Err()
+}
+
+func (h *KeyReservationSchedule) Remove(keyId string) (bool, error) {
+	count, err := h.svc.redis.ZRem(keyReservationScheduleStorageKey, keyId).Result()
+
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (h *KeyReservationSchedule) FindExpired(date time.Time, limit int64) ([]string, error) {
+	opt := redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(date.Unix(), 10),
+		Count: limit,
+	}
+	return h.svc.redis.ZRangeByScore(keyReservationScheduleStorageKey, opt).Result()
+}
+
+func (h *KeyReservationSchedule) GetNextExpiry() (time.Time, error) {
+	items, err := h.svc.redis.ZRangeWithScores(keyReservationScheduleStorageKey, 0, 0).Result()
+
+	if err != nil || len(items) == 0 {
+		return time.Time{}, err
+	}
+
+	return time.Unix(int64(items[0].Score), 0), nil
+}
+
+// getOrderKeyReservationTtl returns lifetime in seconds of reservation of keys for order. Reservation
+// lives while order can be paid, so keys of unpaid order are released by order expiry or cancellation
+// and the reservation expiry is a fallback for orders missed by them.
+func (s *Service) getOrderKeyReservationTtl(ctx context.Context, order *billing.Order) int32 {
+	timeout := s.cfg.GetOrderExpiryTimeout()
+
+	if order.Project != nil {
+		project, err := s.project.GetById(ctx, order.Project.Id)
+
+		if err == nil && project.OrderExpiryTimeout > 0 {
+			timeout = time.Duration(project.OrderExpiryTimeout) * time.Second
+		}
+	}
+
+	createdAt, err := ptypes.Timestamp(order.CreatedAt)
+
+	if err != nil {
+		createdAt = time.Now()
+	}
+
+	ttl := time.Until(createdAt.Add(timeout + keyReservationExpiryGrace))
+
+	if ttl < keyReservationMinTtl {
+		ttl = keyReservationMinTtl
+	}
+
+	return int32(ttl / time.Second)
+}
+
+func (s *Service) scheduleKeyReservationExpiry(key *billing.Key) {
+	keysReservedCounter.Inc()
+
+	expireAt, err := ptypes.Timestamp(key.ReservedTo)
+
+	if err != nil {
+		zap.L().Error("Key reserved without expiry time", zap.Error(err), zap.String("key_id", key.Id))
+		return
+	}
+
+	err = s.keyReservationSchedule.Add(key.Id, expireAt)
+
+	if err != nil {
+		zap.L().Error(
+			"Scheduling of key reservation expiry failed, key will be released by reconciliation",
+			zap.Error(err),
+			zap.String("key_id", key.Id),
+		)
+	}
+}
+
+func (s *Service) unscheduleKeyReservationExpiry(keyId string) {
+	_, err := s.keyReservationSchedule.Remove(keyId)
+
+	if err != nil {
+		zap.L().Error("Removing of key reservation from expiry schedule failed", zap.Error(err), zap.String("key_id", keyId))
+	}
+}
+
+// ReleaseExpiredKeys releases keys which reservations expired according to reservation schedule.
+// The key is claimed by removing it from schedule, so every key is released by one instance of service only.
+func (s *Service) ReleaseExpiredKeys(ctx context.Context) (int, error) {
+	counter := 0
+	ids, err := s.keyReservationSchedule.FindExpired(time.Now(), keyReservationReleaseBatchSize)
+
+	if err != nil {
+		return counter, err
+	}
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return counter, ctx.Err()
+		}
+
+		claimed, err := s.keyReservationSchedule.Remove(id)
+
+		if err != nil || !claimed {
+			continue
+		}
+
+		_, err = s.keyRepository.CancelExpiredById(ctx, id)
+
+		if err != nil {
+			// key was redeemed or released before its reservation expired
+			if err != mongo.ErrNoDocuments {
+				zap.L().Error("Release of expired key reservation failed", zap.Error(err), zap.String("key_id", id))
+			}
+			continue
+		}
+
+		keysReleasedCounter.WithLabelValues(keyReleaseReasonExpired).Inc()
+		counter++
+	}
+
+	return counter, nil
+}
+
+// GetNextKeyReservationExpiry returns time of the nearest expiry of key reservation or zero time if there
+// are no scheduled reservations.
+func (s *Service) GetNextKeyReservationExpiry() time.Time {
+	date, err := s.keyReservationSchedule.GetNextExpiry()
+
+	if err != nil {
+		zap.L().Error("Getting of the nearest key reservation expiry failed", zap.Error(err))
+	}
+
+	return date
+}
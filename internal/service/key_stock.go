@@ -0,0 +1,320 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/constant"
+	postmarkSdrPkg "github.com/paysuper/postmark-sender/pkg"
+	"github.com/streadway/amqp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"strconv"
+	"time"
+)
+
+const (
+	keyProductLowStockCode    = "kps00001"
+	keyProductLowStockMessage = "key product stock reached low-stock threshold"
+
+	keyProductStockCheckStorageKey   = "key_product:stock_check:%s:%s"
+	keyProductStockCheckInterval     = time.Minute
+	preOrderFulfilmentLockStorageKey = "order:pre_order_fulfilment:%s"
+	preOrderFulfilmentLockTimeout    = 5 * time.Minute
+)
+
+var (
+	// order statuses in which pre-ordered keys can be delivered to customer
+	preOrderFulfilmentStatuses = []int32{
+		constant.OrderStatusPaymentSystemComplete,
+		constant.OrderStatusProjectComplete,
+		constant.OrderStatusItemReplaced,
+	}
+)
+
+// checkKeyProductStock sends notification to merchant when count of available keys of key product
+// for platform reached low-stock threshold. Merchant is notified only once until stock is refilled.
+func (s *Service) checkKeyProductStock(ctx context.Context, keyProductId, platformId string) {
+	keyProduct, err := s.keyProductRepository.GetById(ctx, keyProductId)
+
+	if err != nil || keyProduct.LowStockThreshold <= 0 {
+		return
+	}
+
+	count, err := s.keyRepository.CountKeysByProductPlatform(ctx, keyProductId, platformId)
+
+	if err != nil {
+		zap.L().Error(
+			"Count of available keys failed",
+			zap.Error(err),
+			zap.String("key_product_id", keyProductId),
+			zap.String("platform_id", platformId),
+		)
+		return
+	}
+
+	notified := keyProduct.IsLowStockNotified(platformId)
+
+	if count > int64(keyProduct.LowStockThreshold) {
+		if !notified {
+			return
+		}
+
+		var platforms []string
+
+		for _, v := range keyProduct.LowStockNotifiedPlatforms {
+			if v != platformId {
+				platforms = append(platforms, v)
+			}
+		}
+
+		keyProduct.LowStockNotifiedPlatforms = platforms
+	} else {
+		if notified {
+			return
+		}
+
+		keyProduct.LowStockNotifiedPlatforms = append(keyProduct.LowStockNotifiedPlatforms, platformId)
+	}
+
+	err = s.keyProductRepository.Update(ctx, keyProduct)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionKeyProduct),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationUpdate),
+			zap.Any(pkg.ErrorDatabaseFieldDocument, keyProduct),
+		)
+		return
+	}
+
+	if !notified {
+		s.notifyKeyProductLowStock(ctx, keyProduct, platformId, count)
+	}
+}
+
+// checkKeyProductStockOnReserve checks stock of key product for platform not often than once per
+// keyProductStockCheckInterval to not add queries to each reservation of key.
+func (s *Service) checkKeyProductStockOnReserve(ctx context.Context, keyProductId, platformId string) {
+	if s.redis != nil {
+		key := fmt.Sprintf(keyProductStockCheckStorageKey, keyProductId, platformId)
+		ok, err := s.redis.SetNX(key, time.Now().Unix(), keyProductStockCheckInterval).Result()
+
+		if err == nil && !ok {
+			return
+		}
+	}
+
+	s.checkKeyProductStock(ctx, keyProductId, platformId)
+}
+
+func (s *Service) notifyKeyProductLowStock(ctx context.Context, keyProduct *grpc.KeyProduct, platformId string, count int64) {
+	msg := map[string]interface{}{
+		"code":                keyProductLowStockCode,
+		"message":             keyProductLowStockMessage,
+		"key_product_id":      keyProduct.Id,
+		"platform_id":         platformId,
+		"count":               count,
+		"low_stock_threshold": keyProduct.LowStockThreshold,
+	}
+	err := s.centrifugo.Publish(ctx, s.getMerchantCentrifugoChannel(keyProduct.MerchantId), msg)
+
+	if err != nil {
+		zap.L().Error(
+			"[Centrifugo] Send merchant notification about low stock of key product failed",
+			zap.Error(err),
+			zap.Any("msg", msg),
+		)
+	}
+
+	merchant, err := s.merchant.GetById(ctx, keyProduct.MerchantId)
+
+	if err != nil {
+		zap.L().Error("Merchant not found", zap.Error(err), zap.String("merchant_id", keyProduct.MerchantId))
+		return
+	}
+
+	platformName := platformId
+
+	for _, platform := range keyProduct.Platforms {
+		if platform.Id == platformId && platform.Name != "" {
+			platformName = platform.Name
+		}
+	}
+
+	payload := &postmarkSdrPkg.Payload{
+		TemplateAlias: s.cfg.EmailTemplates.KeyProductLowStock,
+		TemplateModel: map[string]string{
+			"merchant_id":         merchant.Id,
+			"merchant_greeting":   merchant.GetAuthorizedName(),
+			"key_product_id":      keyProduct.Id,
+			"key_product_sku":     keyProduct.Sku,
+			"key_product_name":    keyProduct.Name[DefaultLanguage],
+			"platform_name":       platformName,
+			"count":               strconv.FormatInt(count, 10),
+			"low_stock_threshold": strconv.Itoa(int(keyProduct.LowStockThreshold)),
+		},
+		To: merchant.GetAuthorizedEmail(),
+	}
+	err = s.postmarkBroker.Publish(postmarkSdrPkg.PostmarkSenderTopicName, payload, amqp.Table{})
+
+	if err != nil {
+		zap.L().Error(
+			"Publication message about low stock of key product to queue failed",
+			zap.Error(err),
+			zap.String("key_product_id", keyProduct.Id),
+			zap.String("platform_id", platformId),
+		)
+	}
+}
+
+func (s *Service) isKeyProductPreOrderEnabled(ctx context.Context, keyProductId string) bool {
+	keyProduct, err := s.keyProductRepository.GetById(ctx, keyProductId)
+	return err == nil && keyProduct.PreOrderEnabled
+}
+
+// fulfilPreOrders delivers uploaded keys to paid pre-orders of key product in order of their creation
+// while keys for platform are available.
+func (s *Service) fulfilPreOrders(ctx context.Context, keyProductId, platformId string) {
+	query := bson.M{
+		"is_pre_order":   true,
+		"products":       keyProductId,
+		"platform_id":    platformId,
+		"private_status": bson.M{"$in": preOrderFulfilmentStatuses},
+	}
+	opts := options.Find().SetSort(bson.M{"created_at": 1})
+	cursor, err := s.db.Collection(collectionOrder).Find(ctx, query, opts)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionOrder),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return
+	}
+
+	var orders []*billing.Order
+	err = cursor.All(ctx, &orders)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionOrder),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return
+	}
+
+	for _, order := range orders {
+		// pre-order is fulfilled by other process at the moment
+		if !s.fulfilPreOrderKeys(ctx, order) {
+			continue
+		}
+
+		// keys of platform are over, rest of pre-orders will wait for next upload
+		if order.IsPreOrder {
+			return
+		}
+	}
+}
+
+// fulfilPreOrderKeys reserves and redeems keys for products of order which were sold without keys in stock
+// and sends them to customer. Order stays pre-order while at least one of its products have no key.
+// Order is locked during fulfilment and reloaded from database, so concurrent fulfilments of same order
+// can't deliver keys twice. Returns false if order is locked by other fulfilment.
+func (s *Service) fulfilPreOrderKeys(ctx context.Context, order *billing.Order) bool {
+	if s.redis != nil {
+		key := fmt.Sprintf(preOrderFulfilmentLockStorageKey, order.Id)
+		locked, err := s.redis.SetNX(key, time.Now().Unix(), preOrderFulfilmentLockTimeout).Result()
+
+		if err != nil || !locked {
+			return false
+		}
+
+		defer s.redis.Del(key)
+	}
+
+	actual, err := s.getOrderById(ctx, order.Id)
+
+	if err != nil {
+		return false
+	}
+
+	if !actual.IsPreOrder {
+		order.Keys = actual.Keys
+		order.IsPreOrder = false
+		return true
+	}
+
+	pending := false
+	changed := false
+
+	for i, productId := range actual.Products {
+		if i >= len(actual.Keys) || actual.Keys[i] != "" {
+			continue
+		}
+
+		reserveRsp := &grpc.PlatformKeyReserveResponse{}
+		reserveReq := &grpc.PlatformKeyReserveRequest{
+			PlatformId:   actual.PlatformId,
+			MerchantId:   actual.GetMerchantId(),
+			OrderId:      actual.Id,
+			KeyProductId: productId,
+			Ttl:          oneDayTtl,
+		}
+		err := s.ReserveKeyForOrder(ctx, reserveReq, reserveRsp)
+
+		if err != nil || reserveRsp.Status != pkg.ResponseStatusOk {
+			pending = true
+			continue
+		}
+
+		rsp := &grpc.GetKeyForOrderRequestResponse{}
+		err = s.FinishRedeemKeyForOrder(ctx, &grpc.KeyForOrderRequest{KeyId: reserveRsp.KeyId}, rsp)
+
+		if err != nil || rsp.Status != pkg.ResponseStatusOk {
+			zap.L().Error(
+				"could not finish reservation for pre-ordered key",
+				zap.Error(err),
+				zap.String("order_id", actual.Id),
+				zap.String("key_id", reserveRsp.KeyId),
+				zap.Any("message", rsp.Message),
+			)
+
+			_ = s.CancelRedeemKeyForOrder(ctx, &grpc.KeyForOrderRequest{KeyId: reserveRsp.KeyId}, &grpc.EmptyResponseWithStatus{})
+			pending = true
+			continue
+		}
+
+		actual.Keys[i] = rsp.Key.Id
+		changed = true
+
+		s.sendMailWithCode(ctx, actual, rsp.Key)
+	}
+
+	if !changed {
+		order.IsPreOrder = actual.IsPreOrder
+		return true
+	}
+
+	// keys of paid order were already processed, fulfilment mustn't process them again on update
+	actual.IsKeyProductNotified = true
+	actual.IsPreOrder = pending
+
+	if err = s.updateOrder(ctx, actual); err != nil {
+		zap.L().Error("Update of pre-order after fulfilment failed", zap.Error(err), zap.String("order_id", actual.Id))
+	}
+
+	order.Keys = actual.Keys
+	order.IsPreOrder = actual.IsPreOrder
+
+	return true
+}
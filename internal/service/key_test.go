@@ -62,7 +62,7 @@ func (suite *KeyTestSuite) SetupTest() {
 		mocks.NewRepositoryServiceOk(),
 		mocks.NewTaxServiceOkMock(),
 		nil,
-		nil,
+		redisdb,
 		suite.cache,
 		mocks.NewCurrencyServiceMockOk(),
 		mocks.NewDocumentSignerMockOk(),
@@ -627,3 +627,93 @@ func (suite *KeyTestSuite) TestKey_FindUnfinished_Ok() {
 	assert.Len(suite.T(), keys, 1)
 	assert.Equal(suite.T(), keyReserveExpire.Id, keys[0].Id)
 }
+
+func (suite *KeyTestSuite) TestKey_ReserveKeyForOrder_ScheduleReservationExpiry() {
+	key := &billing.Key{
+		Id:           primitive.NewObjectID().Hex(),
+		PlatformId:   "steam",
+		KeyProductId: primitive.NewObjectID().Hex(),
+		Code:         "code1",
+	}
+	assert.NoError(suite.T(), suite.service.keyRepository.Insert(ctx, key))
+
+	req := &grpc.PlatformKeyReserveRequest{
+		PlatformId:   key.PlatformId,
+		KeyProductId: key.KeyProductId,
+		OrderId:      primitive.NewObjectID().Hex(),
+		Ttl:          60,
+	}
+	res := &grpc.PlatformKeyReserveResponse{}
+	err := suite.service.ReserveKeyForOrder(ctx, req, res)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, res.Status)
+
+	ids, err := suite.service.keyReservationSchedule.FindExpired(time.Now(), keyReservationReleaseBatchSize)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), ids)
+
+	ids, err = suite.service.keyReservationSchedule.FindExpired(time.Now().Add(2*time.Minute), keyReservationReleaseBatchSize)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{key.Id}, ids)
+
+	next := suite.service.GetNextKeyReservationExpiry()
+	assert.WithinDuration(suite.T(), time.Now().Add(time.Minute), next, 2*time.Second)
+
+	finishRes := &grpc.GetKeyForOrderRequestResponse{}
+	err = suite.service.FinishRedeemKeyForOrder(ctx, &grpc.KeyForOrderRequest{KeyId: key.Id}, finishRes)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, finishRes.Status)
+	assert.True(suite.T(), suite.service.GetNextKeyReservationExpiry().IsZero())
+}
+
+func (suite *KeyTestSuite) TestKey_ReleaseExpiredKeys_Ok() {
+	reservedTo, err := ptypes.TimestampProto(time.Now().Add(-time.Minute))
+	assert.NoError(suite.T(), err)
+
+	key := &billing.Key{
+		Id:           primitive.NewObjectID().Hex(),
+		PlatformId:   "steam",
+		KeyProductId: primitive.NewObjectID().Hex(),
+		OrderId:      primitive.NewObjectID().Hex(),
+		Code:         "code1",
+		ReservedTo:   reservedTo,
+	}
+	assert.NoError(suite.T(), suite.service.keyRepository.Insert(ctx, key))
+	assert.NoError(suite.T(), suite.service.keyReservationSchedule.Add(key.Id, time.Now().Add(-time.Minute)))
+
+	count, err := suite.service.ReleaseExpiredKeys(ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, count)
+	assert.True(suite.T(), suite.service.GetNextKeyReservationExpiry().IsZero())
+
+	key, err = suite.service.keyRepository.GetById(ctx, key.Id)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), key.OrderId)
+
+	count, err = suite.service.KeyDaemonProcess(ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count)
+}
+
+func (suite *KeyTestSuite) TestKey_ReleaseExpiredKeys_RedeemedKeyNotReleased() {
+	orderId := primitive.NewObjectID().Hex()
+	key := &billing.Key{
+		Id:           primitive.NewObjectID().Hex(),
+		PlatformId:   "steam",
+		KeyProductId: primitive.NewObjectID().Hex(),
+		OrderId:      orderId,
+		Code:         "code1",
+		RedeemedAt:   ptypes.TimestampNow(),
+	}
+	assert.NoError(suite.T(), suite.service.keyRepository.Insert(ctx, key))
+	assert.NoError(suite.T(), suite.service.keyReservationSchedule.Add(key.Id, time.Now().Add(-time.Minute)))
+
+	count, err := suite.service.ReleaseExpiredKeys(ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count)
+	assert.True(suite.T(), suite.service.GetNextKeyReservationExpiry().IsZero())
+
+	key, err = suite.service.keyRepository.GetById(ctx, key.Id)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), orderId, key.OrderId)
+}
@@ -0,0 +1,778 @@
+package service
+
+import (
+	"context"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-recurring-repository/tools"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	collectionLedgerPosting = "ledger_posting"
+
+	// maximal difference between debit and credit of source which is considered as rounding error
+	ledgerBalanceTolerance = 0.001
+
+	// count of accounting entries read from DB by ledger backfill in one batch
+	ledgerBackfillBatchSize = 1000
+)
+
+var (
+	ledgerErrorUnbalanced        = newBillingServerErrorMsg("ldg00001", "ledger postings of source don't net to zero")
+	ledgerErrorAccountsNotMapped = newBillingServerErrorMsg("ldg00002", "ledger accounts for accounting entry type not found")
+	ledgerErrorUnknown           = newBillingServerErrorMsg("ldg00003", "unknown error. try request later")
+	ledgerErrorRollbackFailed    = newBillingServerErrorMsg("ldg00004", "accounting entries which weren't posted to ledger can't be removed")
+
+	ledgerUnbalancedCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "billing_ledger_unbalanced_sources_total",
+		Help: "Total count of orders, refunds and other sources of accounting entries which ledger postings don't net to zero",
+	})
+
+	// chart of accounts of general ledger
+	ledgerAccounts = map[string]string{
+		pkg.LedgerAccountPaymentSystemReceivable: pkg.LedgerAccountTypeAsset,
+		pkg.LedgerAccountSalesClearing:           pkg.LedgerAccountTypeAsset,
+		pkg.LedgerAccountMerchantPayable:         pkg.LedgerAccountTypeLiability,
+		pkg.LedgerAccountRollingReserve:          pkg.LedgerAccountTypeLiability,
+		pkg.LedgerAccountTaxPayable:              pkg.LedgerAccountTypeLiability,
+		pkg.LedgerAccountStoreCreditLiability:    pkg.LedgerAccountTypeLiability,
+		pkg.LedgerAccountFeeRevenue:              pkg.LedgerAccountTypeIncome,
+		pkg.LedgerAccountFxRevenue:               pkg.LedgerAccountTypeIncome,
+		pkg.LedgerAccountPaymentSystemExpense:    pkg.LedgerAccountTypeExpense,
+		pkg.LedgerAccountMerchantAdjustment:      pkg.LedgerAccountTypeExpense,
+		pkg.LedgerAccountStoreCreditFunding:      pkg.LedgerAccountTypeAsset,
+		pkg.LedgerAccountMemorandum:              pkg.LedgerAccountTypeMemorandum,
+		pkg.LedgerAccountMemorandumContra:        pkg.LedgerAccountTypeMemorandum,
+	}
+
+	// ledgerMemorandum is used for accounting entries which are calculated for statistics and reports only
+	// and don't move money, e.g. cost values, markups and totals
+	ledgerMemorandum = &ledgerAccountPair{debit: pkg.LedgerAccountMemorandum, credit: pkg.LedgerAccountMemorandumContra}
+
+	// sources of payments, sales clearing account must net to zero by all postings of each of them,
+	// manual corrections of merchants may move sales clearing account intentionally
+	ledgerSalesClearingSources = []string{collectionOrder, collectionRefund, collectionDispute}
+
+	// ledgerCalculatedEntries are accounting entries which aren't stored, because they're calculated in order_view,
+	// but move money out of sales clearing account. Amount of entry is sum of amounts of stored entries
+	// of the same source and currency multiplied by factors of their types
+	ledgerCalculatedEntries = map[string]map[string]float64{
+		pkg.AccountingEntryTypeMerchantGrossRevenue: {
+			pkg.AccountingEntryTypeRealGrossRevenue: 1,
+			pkg.AccountingEntryTypePsGrossRevenueFx: -1,
+		},
+		pkg.AccountingEntryTypePsMerchantRefundFx: {
+			pkg.AccountingEntryTypeMerchantRefund: 1,
+			pkg.AccountingEntryTypeRealRefund:     -1,
+		},
+	}
+
+	// accounts debited and credited by positive amount of accounting entry of type,
+	// negative amount reverses the accounts
+	ledgerEntryAccounts = map[string]*ledgerAccountPair{
+		pkg.AccountingEntryTypeRealGrossRevenue: {
+			debit:  pkg.LedgerAccountPaymentSystemReceivable,
+			credit: pkg.LedgerAccountSalesClearing,
+		},
+		pkg.AccountingEntryTypeRealTaxFee:        ledgerMemorandum,
+		pkg.AccountingEntryTypeCentralBankTaxFee: ledgerMemorandum,
+		pkg.AccountingEntryTypeRealTaxFeeTotal:   ledgerMemorandum,
+		pkg.AccountingEntryTypePsGrossRevenueFx: {
+			debit:  pkg.LedgerAccountSalesClearing,
+			credit: pkg.LedgerAccountFxRevenue,
+		},
+		pkg.AccountingEntryTypePsGrossRevenueFxTaxFee: ledgerMemorandum,
+		pkg.AccountingEntryTypePsGrossRevenueFxProfit: ledgerMemorandum,
+		pkg.AccountingEntryTypeMerchantGrossRevenue: {
+			debit:  pkg.LedgerAccountSalesClearing,
+			credit: pkg.LedgerAccountMerchantPayable,
+		},
+		pkg.AccountingEntryTypeMerchantTaxFeeCostValue:     ledgerMemorandum,
+		pkg.AccountingEntryTypeMerchantTaxFeeCentralBankFx: ledgerMemorandum,
+		pkg.AccountingEntryTypeMerchantTaxFee: {
+			debit:  pkg.LedgerAccountMerchantPayable,
+			credit: pkg.LedgerAccountTaxPayable,
+		},
+		pkg.AccountingEntryTypePsMethodFee: {
+			debit:  pkg.LedgerAccountPaymentSystemExpense,
+			credit: pkg.LedgerAccountPaymentSystemReceivable,
+		},
+		pkg.AccountingEntryTypeMerchantMethodFee: {
+			debit:  pkg.LedgerAccountMerchantPayable,
+			credit: pkg.LedgerAccountFeeRevenue,
+		},
+		pkg.AccountingEntryTypeMerchantMethodFeeCostValue: ledgerMemorandum,
+		pkg.AccountingEntryTypePsMarkupMerchantMethodFee:  ledgerMemorandum,
+		pkg.AccountingEntryTypeMerchantMethodFixedFee: {
+			debit:  pkg.LedgerAccountMerchantPayable,
+			credit: pkg.LedgerAccountFeeRevenue,
+		},
+		pkg.AccountingEntryTypeRealMerchantMethodFixedFee:     ledgerMemorandum,
+		pkg.AccountingEntryTypeMarkupMerchantMethodFixedFeeFx: ledgerMemorandum,
+		pkg.AccountingEntryTypeRealMerchantMethodFixedFeeCostValue: {
+			debit:  pkg.LedgerAccountPaymentSystemExpense,
+			credit: pkg.LedgerAccountPaymentSystemReceivable,
+		},
+		pkg.AccountingEntryTypePsMethodFixedFeeProfit: ledgerMemorandum,
+		pkg.AccountingEntryTypeMerchantPsFixedFee: {
+			debit:  pkg.LedgerAccountMerchantPayable,
+			credit: pkg.LedgerAccountFeeRevenue,
+		},
+		pkg.AccountingEntryTypeRealMerchantPsFixedFee:   ledgerMemorandum,
+		pkg.AccountingEntryTypeMarkupMerchantPsFixedFee: ledgerMemorandum,
+		pkg.AccountingEntryTypePsMethodProfit:           ledgerMemorandum,
+		pkg.AccountingEntryTypeMerchantNetRevenue:       ledgerMemorandum,
+		pkg.AccountingEntryTypePsProfitTotal:            ledgerMemorandum,
+		pkg.AccountingEntryTypeRealRefund: {
+			debit:  pkg.LedgerAccountSalesClearing,
+			credit: pkg.LedgerAccountPaymentSystemReceivable,
+		},
+		pkg.AccountingEntryTypeRealRefundTaxFee: ledgerMemorandum,
+		pkg.AccountingEntryTypeRealRefundFee: {
+			debit:  pkg.LedgerAccountPaymentSystemExpense,
+			credit: pkg.LedgerAccountPaymentSystemReceivable,
+		},
+		pkg.AccountingEntryTypeRealRefundFixedFee: {
+			debit:  pkg.LedgerAccountPaymentSystemExpense,
+			credit: pkg.LedgerAccountPaymentSystemReceivable,
+		},
+		pkg.AccountingEntryTypeMerchantRefund: {
+			debit:  pkg.LedgerAccountMerchantPayable,
+			credit: pkg.LedgerAccountSalesClearing,
+		},
+		pkg.AccountingEntryTypePsMerchantRefundFx: {
+			debit:  pkg.LedgerAccountSalesClearing,
+			credit: pkg.LedgerAccountFxRevenue,
+		},
+		pkg.AccountingEntryTypeMerchantRefundFee: {
+			debit:  pkg.LedgerAccountMerchantPayable,
+			credit: pkg.LedgerAccountFeeRevenue,
+		},
+		pkg.AccountingEntryTypePsMarkupMerchantRefundFee:       ledgerMemorandum,
+		pkg.AccountingEntryTypeMerchantRefundFixedFeeCostValue: ledgerMemorandum,
+		pkg.AccountingEntryTypeMerchantRefundFixedFee: {
+			debit:  pkg.LedgerAccountMerchantPayable,
+			credit: pkg.LedgerAccountFeeRevenue,
+		},
+		pkg.AccountingEntryTypePsMerchantRefundFixedFeeFx:     ledgerMemorandum,
+		pkg.AccountingEntryTypePsMerchantRefundFixedFeeProfit: ledgerMemorandum,
+		pkg.AccountingEntryTypeReverseTaxFee:                  ledgerMemorandum,
+		pkg.AccountingEntryTypeReverseTaxFeeDelta:             ledgerMemorandum,
+		pkg.AccountingEntryTypePsReverseTaxFeeDelta:           ledgerMemorandum,
+		pkg.AccountingEntryTypeMerchantReverseTaxFee: {
+			debit:  pkg.LedgerAccountTaxPayable,
+			credit: pkg.LedgerAccountMerchantPayable,
+		},
+		pkg.AccountingEntryTypeMerchantReverseRevenue: ledgerMemorandum,
+		pkg.AccountingEntryTypePsRefundProfit:         ledgerMemorandum,
+		pkg.AccountingEntryTypeMerchantRollingReserveCreate: {
+			debit:  pkg.LedgerAccountMerchantPayable,
+			credit: pkg.LedgerAccountRollingReserve,
+		},
+		pkg.AccountingEntryTypeMerchantRollingReserveRelease: {
+			debit:  pkg.LedgerAccountRollingReserve,
+			credit: pkg.LedgerAccountMerchantPayable,
+		},
+		pkg.AccountingEntryTypeMerchantRoyaltyCorrection: {
+			debit:  pkg.LedgerAccountMerchantAdjustment,
+			credit: pkg.LedgerAccountMerchantPayable,
+		},
+		pkg.AccountingEntryTypeMerchantStoreCreditIssue: {
+			debit:  pkg.LedgerAccountStoreCreditFunding,
+			credit: pkg.LedgerAccountStoreCreditLiability,
+		},
+		pkg.AccountingEntryTypeMerchantStoreCreditRedemption: {
+			debit:  pkg.LedgerAccountStoreCreditLiability,
+			credit: pkg.LedgerAccountStoreCreditFunding,
+		},
+		pkg.AccountingEntryTypeMerchantStoreCreditRefund: {
+			debit:  pkg.LedgerAccountStoreCreditFunding,
+			credit: pkg.LedgerAccountStoreCreditLiability,
+		},
+	}
+)
+
+type ledgerAccountPair struct {
+	debit  string
+	credit string
+}
+
+// ledgerUnbalancedSource is result of aggregation of postings by source, source id is object id in database
+type ledgerUnbalancedSource struct {
+	Id struct {
+		SourceId   primitive.ObjectID `bson:"source_id"`
+		SourceType string             `bson:"source_type"`
+		Currency   string             `bson:"currency"`
+	} `bson:"_id"`
+	Debit         float64 `bson:"debit"`
+	Credit        float64 `bson:"credit"`
+	SalesClearing float64 `bson:"sales_clearing"`
+}
+
+// LedgerRepositoryInterface is the general ledger of double-entry postings created from accounting entries.
+// Postings are immutable, mistakes are corrected by new accounting entries.
+type LedgerRepositoryInterface interface {
+	// Post creates debit and credit postings for each accounting entry
+	Post(ctx context.Context, entries []*billing.AccountingEntry) error
+	// GetTrialBalance returns totals of debit and credit postings by accounts
+	GetTrialBalance(ctx context.Context, req *grpc.GetLedgerTrialBalanceRequest) ([]*billing.LedgerTrialBalanceItem, error)
+	// FindUnbalanced returns sources which postings or sales clearing account of payments don't net to zero
+	// in any currency, all sources are checked if ids is empty
+	FindUnbalanced(ctx context.Context, sourceIds []string) ([]*billing.LedgerUnbalancedSource, error)
+	// Delete removes postings of accounting entries, it's used only to rollback entries which weren't saved
+	Delete(ctx context.Context, entryIds []string) error
+}
+
+func newLedgerRepository(svc *Service) LedgerRepositoryInterface {
+	s := &Ledger{svc: svc}
+	return s
+}
+
+func (s *Service) GetLedgerTrialBalance(
+	ctx context.Context,
+	req *grpc.GetLedgerTrialBalanceRequest,
+	rsp *grpc.GetLedgerTrialBalanceResponse,
+) error {
+	items, err := s.ledger.GetTrialBalance(ctx, req)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = ledgerErrorUnknown
+		return nil
+	}
+
+	for _, item := range items {
+		item.AccountType = ledgerAccounts[item.Account]
+		item.Debit = tools.FormatAmount(item.Debit)
+		item.Credit = tools.FormatAmount(item.Credit)
+		item.Balance = tools.FormatAmount(item.Debit - item.Credit)
+
+		rsp.TotalDebit += item.Debit
+		rsp.TotalCredit += item.Credit
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Items = items
+	rsp.TotalDebit = tools.FormatAmount(rsp.TotalDebit)
+	rsp.TotalCredit = tools.FormatAmount(rsp.TotalCredit)
+
+	return nil
+}
+
+func (s *Service) CheckLedgerBalance(
+	ctx context.Context,
+	req *grpc.CheckLedgerBalanceRequest,
+	rsp *grpc.CheckLedgerBalanceResponse,
+) error {
+	var sourceIds []string
+
+	if req.SourceId != "" {
+		sourceIds = append(sourceIds, req.SourceId)
+	}
+
+	items, err := s.checkLedgerBalance(ctx, sourceIds)
+
+	if err != nil && err != ledgerErrorUnbalanced {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = ledgerErrorUnknown
+		return nil
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Items = items
+
+	if err == ledgerErrorUnbalanced {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = ledgerErrorUnbalanced
+	}
+
+	return nil
+}
+
+// BackfillLedger posts accounting entries which were created before ledger or weren't posted because of errors,
+// entries are posted by sources together with entries calculated in order_view
+func (s *Service) BackfillLedger(ctx context.Context) error {
+	zap.L().Info("start ledger backfill")
+
+	query := []bson.M{
+		{
+			"$lookup": bson.M{
+				"from":         collectionLedgerPosting,
+				"localField":   "_id",
+				"foreignField": "entry_id",
+				"as":           "postings",
+			},
+		},
+		{"$match": bson.M{"postings": bson.M{"$size": 0}}},
+		{"$project": bson.M{"postings": 0}},
+		{"$sort": bson.M{"source.id": 1, "_id": 1}},
+	}
+	opts := options.Aggregate().SetAllowDiskUse(true).SetBatchSize(ledgerBackfillBatchSize)
+	cursor, err := s.db.Collection(collectionAccountingEntry).Aggregate(ctx, query, opts)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionAccountingEntry),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return err
+	}
+
+	defer cursor.Close(ctx)
+
+	var (
+		entries  []*billing.AccountingEntry
+		count    int
+		failed   int
+		sourceId string
+	)
+
+	post := func() {
+		if len(entries) <= 0 {
+			return
+		}
+
+		entries = append(entries, getLedgerCalculatedEntries(entries)...)
+
+		if err := s.postLedgerEntries(ctx, entries); err != nil && err != ledgerErrorUnbalanced {
+			failed++
+		} else {
+			count += len(entries)
+		}
+
+		entries = nil
+	}
+
+	for cursor.Next(ctx) {
+		entry := &billing.AccountingEntry{}
+
+		if err = cursor.Decode(entry); err != nil {
+			zap.L().Error(
+				pkg.ErrorQueryCursorExecutionFailed,
+				zap.Error(err),
+				zap.String(pkg.ErrorDatabaseFieldCollection, collectionAccountingEntry),
+				zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+			)
+			return err
+		}
+
+		if entry.Source.Id != sourceId {
+			post()
+			sourceId = entry.Source.Id
+		}
+
+		entries = append(entries, entry)
+	}
+
+	post()
+
+	if err = cursor.Err(); err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionAccountingEntry),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return err
+	}
+
+	zap.L().Info("ledger backfill finished", zap.Int("posted_entries", count), zap.Int("failed_sources", failed))
+
+	return nil
+}
+
+// postLedgerEntries creates ledger postings of accounting entries and checks postings of their sources are balanced
+func (s *Service) postLedgerEntries(ctx context.Context, entries []*billing.AccountingEntry) error {
+	if err := s.ledger.Post(ctx, entries); err != nil {
+		return err
+	}
+
+	var sourceIds []string
+	sources := make(map[string]bool)
+
+	for _, entry := range entries {
+		if sources[entry.Source.Id] {
+			continue
+		}
+
+		sources[entry.Source.Id] = true
+		sourceIds = append(sourceIds, entry.Source.Id)
+	}
+
+	_, err := s.checkLedgerBalance(ctx, sourceIds)
+
+	return err
+}
+
+func (s *Service) checkLedgerBalance(ctx context.Context, sourceIds []string) ([]*billing.LedgerUnbalancedSource, error) {
+	items, err := s.ledger.FindUnbalanced(ctx, sourceIds)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(items) <= 0 {
+		return items, nil
+	}
+
+	for _, item := range items {
+		zap.L().Error(
+			"Ledger postings don't net to zero",
+			zap.String("source_id", item.Source.Id),
+			zap.String("source_type", item.Source.Type),
+			zap.String("currency", item.Currency),
+			zap.Float64("debit", item.Debit),
+			zap.Float64("credit", item.Credit),
+			zap.Float64("sales_clearing_balance", item.SalesClearingBalance),
+		)
+	}
+
+	ledgerUnbalancedCounter.Add(float64(len(items)))
+
+	return items, ledgerErrorUnbalanced
+}
+
+func (h *Ledger) Post(ctx context.Context, entries []*billing.AccountingEntry) error {
+	var postings []interface{}
+
+	for _, entry := range entries {
+		debit, credit, amount, err := getLedgerEntryAccounts(entry)
+
+		if err != nil {
+			zap.L().Error(
+				ledgerErrorAccountsNotMapped.Message,
+				zap.String("entry_id", entry.Id),
+				zap.String("entry_type", entry.Type),
+			)
+			return err
+		}
+
+		if amount == 0 {
+			continue
+		}
+
+		postings = append(
+			postings,
+			h.newPosting(entry, debit, amount, 0),
+			h.newPosting(entry, credit, 0, amount),
+		)
+	}
+
+	if len(postings) <= 0 {
+		return nil
+	}
+
+	_, err := h.svc.db.Collection(collectionLedgerPosting).InsertMany(ctx, postings)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionLedgerPosting),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationInsert),
+			zap.Any(pkg.ErrorDatabaseFieldDocument, postings),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func (h *Ledger) newPosting(entry *billing.AccountingEntry, account string, debit, credit float64) *billing.LedgerPosting {
+	return &billing.LedgerPosting{
+		Id:                 primitive.NewObjectID().Hex(),
+		EntryId:            entry.Id,
+		EntryType:          entry.Type,
+		Source:             entry.Source,
+		MerchantId:         entry.MerchantId,
+		OperatingCompanyId: entry.OperatingCompanyId,
+		Account:            account,
+		Debit:              debit,
+		Credit:             credit,
+		Currency:           entry.Currency,
+		CreatedAt:          entry.CreatedAt,
+	}
+}
+
+func (h *Ledger) GetTrialBalance(
+	ctx context.Context,
+	req *grpc.GetLedgerTrialBalanceRequest,
+) ([]*billing.LedgerTrialBalanceItem, error) {
+	match := bson.M{"currency": req.Currency}
+
+	if req.OperatingCompanyId != "" {
+		match["operating_company_id"] = req.OperatingCompanyId
+	}
+
+	if req.MerchantId != "" {
+		match["merchant_id"], _ = primitive.ObjectIDFromHex(req.MerchantId)
+	}
+
+	if req.DateFrom > 0 || req.DateTo > 0 {
+		date := bson.M{}
+
+		if req.DateFrom > 0 {
+			date["$gte"] = time.Unix(req.DateFrom, 0)
+		}
+
+		if req.DateTo > 0 {
+			date["$lte"] = time.Unix(req.DateTo, 0)
+		}
+
+		match["created_at"] = date
+	}
+
+	query := []bson.M{
+		{"$match": match},
+		{
+			"$group": bson.M{
+				"_id":    "$account",
+				"debit":  bson.M{"$sum": "$debit"},
+				"credit": bson.M{"$sum": "$credit"},
+			},
+		},
+		{"$sort": bson.M{"_id": 1}},
+	}
+	cursor, err := h.svc.db.Collection(collectionLedgerPosting).Aggregate(ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionLedgerPosting),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	var items []*billing.LedgerTrialBalanceItem
+	err = cursor.All(ctx, &items)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionLedgerPosting),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func (h *Ledger) FindUnbalanced(ctx context.Context, sourceIds []string) ([]*billing.LedgerUnbalancedSource, error) {
+	var query []bson.M
+
+	if len(sourceIds) > 0 {
+		var oids []primitive.ObjectID
+
+		for _, id := range sourceIds {
+			oid, err := primitive.ObjectIDFromHex(id)
+
+			if err != nil {
+				return nil, accountingEntryErrorInvalidSourceId
+			}
+
+			oids = append(oids, oid)
+		}
+
+		query = append(query, bson.M{"$match": bson.M{"source.id": bson.M{"$in": oids}}})
+	}
+
+	query = append(
+		query,
+		bson.M{
+			"$group": bson.M{
+				"_id": bson.M{
+					"source_id":   "$source.id",
+					"source_type": "$source.type",
+					"currency":    "$currency",
+				},
+				"debit":  bson.M{"$sum": "$debit"},
+				"credit": bson.M{"$sum": "$credit"},
+				"sales_clearing": bson.M{
+					"$sum": bson.M{
+						"$cond": []interface{}{
+							bson.M{
+								"$and": []bson.M{
+									{"$eq": []string{"$account", pkg.LedgerAccountSalesClearing}},
+									{"$in": []interface{}{"$source.type", ledgerSalesClearingSources}},
+								},
+							},
+							bson.M{"$subtract": []string{"$debit", "$credit"}},
+							0,
+						},
+					},
+				},
+			},
+		},
+		bson.M{
+			"$match": bson.M{
+				"$expr": bson.M{
+					"$or": []bson.M{
+						{
+							"$gt": []interface{}{
+								bson.M{"$abs": bson.M{"$subtract": []string{"$debit", "$credit"}}},
+								ledgerBalanceTolerance,
+							},
+						},
+						{
+							"$gt": []interface{}{
+								bson.M{"$abs": "$sales_clearing"},
+								ledgerBalanceTolerance,
+							},
+						},
+					},
+				},
+			},
+		},
+	)
+	cursor, err := h.svc.db.Collection(collectionLedgerPosting).Aggregate(ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionLedgerPosting),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	var res []*ledgerUnbalancedSource
+	err = cursor.All(ctx, &res)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionLedgerPosting),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	items := make([]*billing.LedgerUnbalancedSource, 0, len(res))
+
+	for _, v := range res {
+		items = append(items, &billing.LedgerUnbalancedSource{
+			Source: &billing.AccountingEntrySource{
+				Id:   v.Id.SourceId.Hex(),
+				Type: v.Id.SourceType,
+			},
+			Currency:             v.Id.Currency,
+			Debit:                tools.FormatAmount(v.Debit),
+			Credit:               tools.FormatAmount(v.Credit),
+			SalesClearingBalance: tools.FormatAmount(v.SalesClearing),
+		})
+	}
+
+	return items, nil
+}
+
+func (h *Ledger) Delete(ctx context.Context, entryIds []string) error {
+	var oids []primitive.ObjectID
+
+	for _, id := range entryIds {
+		oid, err := primitive.ObjectIDFromHex(id)
+
+		if err != nil {
+			return accountingEntryErrorInvalidSourceId
+		}
+
+		oids = append(oids, oid)
+	}
+
+	query := bson.M{"entry_id": bson.M{"$in": oids}}
+	_, err := h.svc.db.Collection(collectionLedgerPosting).DeleteMany(ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionLedgerPosting),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationDelete),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return err
+	}
+
+	return nil
+}
+
+// getLedgerCalculatedEntries returns entries calculated in order_view, which are posted to ledger together with
+// stored accounting entries of the same source, amounts are calculated separately for each currency
+func getLedgerCalculatedEntries(entries []*billing.AccountingEntry) []*billing.AccountingEntry {
+	var items []*billing.AccountingEntry
+
+	for entryType, factors := range ledgerCalculatedEntries {
+		var currencies []string
+		calculated := make(map[string]*billing.AccountingEntry)
+
+		for _, entry := range entries {
+			factor, ok := factors[entry.Type]
+
+			if !ok {
+				continue
+			}
+
+			item, ok := calculated[entry.Currency]
+
+			if !ok {
+				item = &billing.AccountingEntry{
+					Id:                 primitive.NewObjectID().Hex(),
+					Object:             entry.Object,
+					Type:               entryType,
+					Source:             entry.Source,
+					MerchantId:         entry.MerchantId,
+					Currency:           entry.Currency,
+					Status:             entry.Status,
+					Country:            entry.Country,
+					CreatedAt:          entry.CreatedAt,
+					OperatingCompanyId: entry.OperatingCompanyId,
+				}
+				calculated[entry.Currency] = item
+				currencies = append(currencies, entry.Currency)
+			}
+
+			item.Amount += entry.Amount * factor
+		}
+
+		for _, currency := range currencies {
+			item := calculated[currency]
+			item.Amount = tools.ToPrecise(item.Amount)
+
+			if item.Amount != 0 {
+				items = append(items, item)
+			}
+		}
+	}
+
+	return items
+}
+
+// getLedgerEntryAccounts returns accounts debited and credited by accounting entry and positive amount of postings
+func getLedgerEntryAccounts(entry *billing.AccountingEntry) (string, string, float64, error) {
+	accounts, ok := ledgerEntryAccounts[entry.Type]
+
+	if !ok {
+		return "", "", 0, ledgerErrorAccountsNotMapped
+	}
+
+	if entry.Amount < 0 {
+		return accounts.credit, accounts.debit, -entry.Amount, nil
+	}
+
+	return accounts.debit, accounts.credit, entry.Amount, nil
+}
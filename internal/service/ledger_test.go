@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"github.com/go-redis/redis"
+	"github.com/golang-migrate/migrate/v4"
+	casbinMocks "github.com/paysuper/casbin-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/internal/config"
+	"github.com/paysuper/paysuper-billing-server/internal/database"
+	"github.com/paysuper/paysuper-billing-server/internal/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	reportingMocks "github.com/paysuper/paysuper-reporter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	rabbitmq "gopkg.in/ProtocolONE/rabbitmq.v1/pkg"
+	mongodb "gopkg.in/paysuper/paysuper-database-mongo.v1"
+	"testing"
+	"time"
+)
+
+type LedgerTestSuite struct {
+	suite.Suite
+	service *Service
+	cache   CacheInterface
+
+	project       *billing.Project
+	paymentMethod *billing.PaymentMethod
+}
+
+func Test_Ledger(t *testing.T) {
+	suite.Run(t, new(LedgerTestSuite))
+}
+
+func (suite *LedgerTestSuite) SetupTest() {
+	cfg, err := config.NewConfig()
+	if err != nil {
+		suite.FailNow("Config load failed", "%v", err)
+	}
+
+	m, err := migrate.New(
+		"file://../../migrations/tests",
+		cfg.MongoDsn)
+	assert.NoError(suite.T(), err, "Migrate init failed")
+
+	err = m.Up()
+	if err != nil && err.Error() != "no change" {
+		suite.FailNow("Migrations failed", "%v", err)
+	}
+
+	db, err := mongodb.NewDatabase()
+	if err != nil {
+		suite.FailNow("Database connection failed", "%v", err)
+	}
+
+	broker, err := rabbitmq.NewBroker(cfg.BrokerAddress)
+
+	if err != nil {
+		suite.FailNow("Creating RabbitMQ publisher failed", "%v", err)
+	}
+
+	redisClient := database.NewRedis(
+		&redis.Options{
+			Addr:     cfg.RedisHost,
+			Password: cfg.RedisPassword,
+		},
+	)
+
+	redisdb := mocks.NewTestRedis()
+	suite.cache, err = NewCacheRedis(redisdb, "cache")
+	suite.service = NewBillingService(
+		db,
+		cfg,
+		mocks.NewGeoIpServiceTestOk(),
+		mocks.NewRepositoryServiceOk(),
+		mocks.NewTaxServiceOkMock(),
+		broker,
+		redisClient,
+		suite.cache,
+		mocks.NewCurrencyServiceMockOk(),
+		mocks.NewDocumentSignerMockOk(),
+		&reportingMocks.ReporterService{},
+		mocks.NewFormatterOK(),
+		broker,
+		&casbinMocks.CasbinService{},
+	)
+
+	if err := suite.service.Init(); err != nil {
+		suite.FailNow("Billing service initialization failed", "%v", err)
+	}
+
+	_, suite.project, suite.paymentMethod, _ = helperCreateEntitiesForTests(suite.Suite, suite.service)
+}
+
+func (suite *LedgerTestSuite) TearDownTest() {
+	if err := suite.service.db.Drop(); err != nil {
+		suite.FailNow("Database deletion failed", "%v", err)
+	}
+
+	if err := suite.service.db.Close(); err != nil {
+		suite.FailNow("Database close failed", "%v", err)
+	}
+}
+
+func (suite *LedgerTestSuite) TestLedger_EntryAccounts_Ok() {
+	for entryType := range availableAccountingEntries {
+		accounts, ok := ledgerEntryAccounts[entryType]
+		assert.Truef(suite.T(), ok, "accounts of entry type %s not found", entryType)
+
+		if !ok {
+			continue
+		}
+
+		assert.Contains(suite.T(), ledgerAccounts, accounts.debit)
+		assert.Contains(suite.T(), ledgerAccounts, accounts.credit)
+		assert.NotEqual(suite.T(), accounts.debit, accounts.credit)
+	}
+}
+
+func (suite *LedgerTestSuite) TestLedger_PaymentPosted_Ok() {
+	order := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+	assert.NotNil(suite.T(), order)
+
+	oid, _ := primitive.ObjectIDFromHex(order.Id)
+	count, err := suite.service.db.Collection(collectionLedgerPosting).CountDocuments(context.TODO(), bson.M{"source.id": oid})
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), count > 0)
+	assert.EqualValues(suite.T(), 0, count%2)
+
+	req := &grpc.CheckLedgerBalanceRequest{SourceId: order.Id}
+	rsp := &grpc.CheckLedgerBalanceResponse{}
+	err = suite.service.CheckLedgerBalance(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Empty(suite.T(), rsp.Items)
+
+	req1 := &grpc.GetLedgerTrialBalanceRequest{
+		Currency:           order.GetMerchantRoyaltyCurrency(),
+		OperatingCompanyId: order.OperatingCompanyId,
+		MerchantId:         order.GetMerchantId(),
+	}
+	rsp1 := &grpc.GetLedgerTrialBalanceResponse{}
+	err = suite.service.GetLedgerTrialBalance(context.TODO(), req1, rsp1)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp1.Status)
+	assert.NotEmpty(suite.T(), rsp1.Items)
+	assert.True(suite.T(), rsp1.TotalDebit > 0)
+	assert.InDelta(suite.T(), rsp1.TotalDebit, rsp1.TotalCredit, 0.01)
+
+	for _, item := range rsp1.Items {
+		assert.NotEmpty(suite.T(), item.AccountType)
+		assert.InDelta(suite.T(), item.Debit-item.Credit, item.Balance, 0.01)
+	}
+}
+
+func (suite *LedgerTestSuite) TestLedger_CheckLedgerBalance_Unbalanced() {
+	order := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+	assert.NotNil(suite.T(), order)
+
+	oid, _ := primitive.ObjectIDFromHex(order.Id)
+	query := bson.M{"source.id": oid, "type": pkg.AccountingEntryTypeRealGrossRevenue}
+	entry := &billing.AccountingEntry{}
+	err := suite.service.db.Collection(collectionAccountingEntry).FindOne(context.TODO(), query).Decode(entry)
+	assert.NoError(suite.T(), err)
+
+	// gross revenue of payment posted twice, debit and credit of postings are equal, but sales clearing isn't zero
+	entry.Id = primitive.NewObjectID().Hex()
+	err = suite.service.ledger.Post(context.TODO(), []*billing.AccountingEntry{entry})
+	assert.NoError(suite.T(), err)
+
+	rsp := &grpc.CheckLedgerBalanceResponse{}
+	err = suite.service.CheckLedgerBalance(context.TODO(), &grpc.CheckLedgerBalanceRequest{}, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), ledgerErrorUnbalanced, rsp.Message)
+	assert.Len(suite.T(), rsp.Items, 1)
+	assert.Equal(suite.T(), order.Id, rsp.Items[0].Source.Id)
+	assert.Equal(suite.T(), entry.Currency, rsp.Items[0].Currency)
+	assert.Equal(suite.T(), rsp.Items[0].Debit, rsp.Items[0].Credit)
+	assert.Equal(suite.T(), -entry.Amount, rsp.Items[0].SalesClearingBalance)
+}
+
+func (suite *LedgerTestSuite) TestLedger_CreateAccountingEntry_Unbalanced_RolledBack() {
+	order := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+	assert.NotNil(suite.T(), order)
+
+	req := &grpc.CreateAccountingEntryRequest{
+		Type:     pkg.AccountingEntryTypeRealGrossRevenue,
+		OrderId:  order.Id,
+		Amount:   10,
+		Currency: order.GetMerchantRoyaltyCurrency(),
+		Status:   pkg.BalanceTransactionStatusAvailable,
+		Date:     time.Now().Unix(),
+		Reason:   "unit test",
+	}
+	rsp := &grpc.CreateAccountingEntryResponse{}
+	err := suite.service.CreateAccountingEntry(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), ledgerErrorUnbalanced, rsp.Message)
+
+	count, err := suite.service.db.Collection(collectionAccountingEntry).CountDocuments(context.TODO(), bson.M{"reason": req.Reason})
+	assert.NoError(suite.T(), err)
+	assert.Zero(suite.T(), count)
+
+	rsp1 := &grpc.CheckLedgerBalanceResponse{}
+	err = suite.service.CheckLedgerBalance(context.TODO(), &grpc.CheckLedgerBalanceRequest{SourceId: order.Id}, rsp1)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp1.Status)
+	assert.Empty(suite.T(), rsp1.Items)
+}
+
+func (suite *LedgerTestSuite) TestLedger_BackfillLedger_Ok() {
+	order := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+	assert.NotNil(suite.T(), order)
+
+	oid, _ := primitive.ObjectIDFromHex(order.Id)
+	query := bson.M{"source.id": oid}
+	count, err := suite.service.db.Collection(collectionLedgerPosting).CountDocuments(context.TODO(), query)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), count > 0)
+
+	// entries created before ledger
+	_, err = suite.service.db.Collection(collectionLedgerPosting).DeleteMany(context.TODO(), bson.M{})
+	assert.NoError(suite.T(), err)
+
+	err = suite.service.BackfillLedger(context.TODO())
+	assert.NoError(suite.T(), err)
+
+	count1, err := suite.service.db.Collection(collectionLedgerPosting).CountDocuments(context.TODO(), query)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), count, count1)
+
+	err = suite.service.BackfillLedger(context.TODO())
+	assert.NoError(suite.T(), err)
+
+	count1, err = suite.service.db.Collection(collectionLedgerPosting).CountDocuments(context.TODO(), query)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), count, count1)
+
+	rsp := &grpc.CheckLedgerBalanceResponse{}
+	err = suite.service.CheckLedgerBalance(context.TODO(), &grpc.CheckLedgerBalanceRequest{}, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Empty(suite.T(), rsp.Items)
+}
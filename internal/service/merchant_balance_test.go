@@ -53,7 +53,7 @@ func (suite *MerchantBalanceTestSuite) SetupTest() {
 	if err != nil {
 		suite.FailNow("Config load failed", "%v", err)
 	}
-	cfg.CardPayApiUrl = "https://sandbox.cardpay.com"
+	cfg.CardPay.ApiUrl = "https://sandbox.cardpay.com"
 
 	m, err := migrate.New(
 		"file://../../migrations/tests",
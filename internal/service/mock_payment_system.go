@@ -16,6 +16,12 @@ import (
 type PaymentSystemMockOk struct{}
 type PaymentSystemMockError struct{}
 
+// CardPayPendingMock processes payments with cardpay handler, but payment system
+// answers on payment status request that payment is still pending.
+type CardPayPendingMock struct {
+	PaymentSystem
+}
+
 func NewPaymentSystemMockOk() PaymentSystem {
 	return &PaymentSystemMockOk{}
 }
@@ -24,6 +30,10 @@ func NewPaymentSystemMockError() PaymentSystem {
 	return &PaymentSystemMockError{}
 }
 
+func NewCardPayPendingMock() PaymentSystem {
+	return &CardPayPendingMock{PaymentSystem: newCardPayHandler()}
+}
+
 func NewCardPayMock() PaymentSystem {
 	cpMock := &mocks.PaymentSystem{}
 	cpMock.On("CreatePayment", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
@@ -91,6 +101,40 @@ func NewCardPayMock() PaymentSystem {
 			},
 			nil,
 		)
+	cpMock.On("CapturePayment", mock.Anything, mock.Anything).
+		Return(
+			func(order *billing.Order, amount float64) error {
+				order.CapturedAmount = amount
+				order.CapturedAt = ptypes.TimestampNow()
+				return nil
+			},
+			nil,
+		)
+	cpMock.On("VoidPayment", mock.Anything).
+		Return(
+			func(order *billing.Order) error {
+				order.VoidedAt = ptypes.TimestampNow()
+				return nil
+			},
+			nil,
+		)
+	cpMock.On("GetPaymentStatus", mock.Anything).
+		Return(
+			func(order *billing.Order) proto.Message {
+				return &billing.CardPayPaymentCallback{
+					PaymentMethod: order.PaymentMethod.ExternalId,
+					CallbackTime:  time.Now().UTC().Format(cardPayDateFormat),
+					CardAccount:   &billing.CallbackCardPayBankCardAccount{MaskedPan: "400000******0002"},
+					PaymentData: &billing.CallbackCardPayPaymentData{
+						Id:       "0987654321",
+						Amount:   order.GetPaymentNotificationAmount(),
+						Currency: order.ChargeCurrency,
+						Status:   pkg.CardPayPaymentResponseStatusCompleted,
+					},
+				}
+			},
+			nil,
+		)
 	return cpMock
 }
 
@@ -124,6 +168,23 @@ func (m *PaymentSystemMockOk) ProcessRefund(order *billing.Order, refund *billin
 	return nil
 }
 
+func (m *PaymentSystemMockOk) CapturePayment(order *billing.Order, amount float64) error {
+	order.CapturedAmount = amount
+	order.CapturedAt = ptypes.TimestampNow()
+
+	return nil
+}
+
+func (m *PaymentSystemMockOk) VoidPayment(order *billing.Order) error {
+	order.VoidedAt = ptypes.TimestampNow()
+
+	return nil
+}
+
+func (m *PaymentSystemMockOk) GetPaymentStatus(order *billing.Order) (proto.Message, error) {
+	return &billing.CardPayPaymentCallback{}, nil
+}
+
 func (m *PaymentSystemMockError) CreatePayment(order *billing.Order, successUrl, failUrl string, requisites map[string]string) (string, error) {
 	return "", nil
 }
@@ -148,3 +209,28 @@ func (m *PaymentSystemMockError) CreateRefund(order *billing.Order, refund *bill
 func (m *PaymentSystemMockError) ProcessRefund(order *billing.Order, refund *billing.Refund, message proto.Message, raw, signature string) error {
 	return newBillingServerResponseError(pkg.ResponseStatusBadData, paymentSystemErrorRefundRequestAmountOrCurrencyIsInvalid)
 }
+
+func (m *PaymentSystemMockError) CapturePayment(order *billing.Order, amount float64) error {
+	return paymentSystemErrorCaptureRequestFailed
+}
+
+func (m *PaymentSystemMockError) VoidPayment(order *billing.Order) error {
+	return paymentSystemErrorVoidRequestFailed
+}
+
+func (m *PaymentSystemMockError) GetPaymentStatus(order *billing.Order) (proto.Message, error) {
+	return nil, paymentSystemErrorPaymentStatusRequestFailed
+}
+
+func (m *CardPayPendingMock) GetPaymentStatus(order *billing.Order) (proto.Message, error) {
+	return &billing.CardPayPaymentCallback{
+		PaymentMethod: order.PaymentMethod.ExternalId,
+		CallbackTime:  time.Now().UTC().Format(cardPayDateFormat),
+		PaymentData: &billing.CallbackCardPayPaymentData{
+			Id:       "0987654321",
+			Amount:   order.GetPaymentNotificationAmount(),
+			Currency: order.ChargeCurrency,
+			Status:   pkg.CardPayPaymentResponseStatusPending,
+		},
+	}, nil
+}
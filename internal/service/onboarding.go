@@ -694,6 +694,49 @@ func (s *Service) ChangeMerchantManualPayouts(
 	return nil
 }
 
+func (s *Service) ChangeMerchantRoyaltyReportPeriod(
+	ctx context.Context,
+	req *grpc.ChangeMerchantRoyaltyReportPeriodRequest,
+	rsp *grpc.ChangeMerchantManualPayoutsResponse,
+) error {
+	merchant, err := s.merchant.GetById(ctx, req.MerchantId)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusNotFound
+		rsp.Message = err.(*grpc.ResponseErrorMessage)
+
+		return nil
+	}
+
+	if _, err = time.LoadLocation(req.Timezone); err != nil {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = royaltyReportErrorTimezoneIncorrect
+
+		return nil
+	}
+
+	if merchant.RoyaltyReportPeriod == req.Period && merchant.RoyaltyReportTimezone == req.Timezone {
+		rsp.Status = pkg.ResponseStatusNotModified
+		return nil
+	}
+
+	merchant.RoyaltyReportPeriod = req.Period
+	merchant.RoyaltyReportTimezone = req.Timezone
+
+	err = s.merchant.Update(ctx, merchant)
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = merchantErrorUnknown
+
+		return nil
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = merchant
+
+	return nil
+}
+
 func (s *Service) SetMerchantS3Agreement(
 	ctx context.Context,
 	req *grpc.SetMerchantS3AgreementRequest,
@@ -9,7 +9,7 @@ import (
 	"fmt"
 	geoip "github.com/ProtocolONE/geoip-service/pkg/proto"
 	"github.com/dgrijalva/jwt-go"
-	protobuf "github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
 	structpb "github.com/golang/protobuf/ptypes/struct"
 	"github.com/google/uuid"
@@ -41,11 +41,10 @@ import (
 )
 
 const (
-	paymentRequestIncorrect             = "payment request has incorrect format"
-	callbackRequestIncorrect            = "callback request has incorrect format"
-	callbackHandlerIncorrect            = "unknown callback type"
-	orderErrorPublishNotificationFailed = "publish order notification failed"
-	orderErrorUpdateOrderDataFailed     = "update order data failed"
+	paymentRequestIncorrect         = "payment request has incorrect format"
+	callbackRequestIncorrect        = "callback request has incorrect format"
+	callbackHandlerIncorrect        = "unknown callback type"
+	orderErrorUpdateOrderDataFailed = "update order data failed"
 
 	orderDefaultDescription = "Payment by order # %s"
 
@@ -134,6 +133,9 @@ var (
 	orderErrorWrongPrivateStatus                              = newBillingServerErrorMsg("fm000075", "order has wrong private status and cannot be recreated")
 	orderCountryChangeRestrictedError                         = newBillingServerErrorMsg("fm000076", "change country is not allowed")
 	orderErrorIncompatibleProject                             = newBillingServerErrorMsg("fm000077", "this is not product project")
+	orderErrorAccountCheckRejected                            = newBillingServerErrorMsg("fm000078", "payment for this account rejected by project")
+	orderErrorAccountCheckFailed                              = newBillingServerErrorMsg("fm000079", "project account check failed. try request later")
+	orderErrorPaymentDeniedByRisk                             = newBillingServerErrorMsg("fm000080", "payment declined by risk checks")
 
 	virtualCurrencyPayoutCurrencyMissed = newBillingServerErrorMsg("vc000001", "virtual currency don't have price in merchant payout currency")
 
@@ -262,7 +264,27 @@ func (s *Service) OrderCreateProcess(
 	req *billing.OrderCreateRequest,
 	rsp *grpc.OrderCreateProcessResponse,
 ) error {
+	return s.processIdempotentRequest(
+		idempotencyMethodOrderCreate,
+		req.ProjectId,
+		req.IdempotencyKey,
+		req,
+		rsp,
+		func(message *grpc.ResponseErrorMessage) {
+			rsp.Status = pkg.ResponseStatusConflict
+			rsp.Message = message
+		},
+		func() error {
+			return s.orderCreateProcess(ctx, req, rsp)
+		},
+	)
+}
 
+func (s *Service) orderCreateProcess(
+	ctx context.Context,
+	req *billing.OrderCreateRequest,
+	rsp *grpc.OrderCreateProcessResponse,
+) error {
 	rsp.Status = pkg.ResponseStatusOk
 
 	processor := &OrderCreateRequestProcessor{
@@ -894,6 +916,27 @@ func (s *Service) PaymentCreateProcess(
 	ctx context.Context,
 	req *grpc.PaymentCreateRequest,
 	rsp *grpc.PaymentCreateResponse,
+) error {
+	return s.processIdempotentRequest(
+		idempotencyMethodPaymentCreate,
+		req.Data[pkg.PaymentCreateFieldOrderId],
+		req.IdempotencyKey,
+		req,
+		rsp,
+		func(message *grpc.ResponseErrorMessage) {
+			rsp.Status = pkg.ResponseStatusConflict
+			rsp.Message = message
+		},
+		func() error {
+			return s.paymentCreateProcess(ctx, req, rsp)
+		},
+	)
+}
+
+func (s *Service) paymentCreateProcess(
+	ctx context.Context,
+	req *grpc.PaymentCreateRequest,
+	rsp *grpc.PaymentCreateResponse,
 ) error {
 	processor := &PaymentCreateProcessor{
 		service:        s,
@@ -976,7 +1019,49 @@ func (s *Service) PaymentCreateProcess(
 		return err
 	}
 
-	ps, err := s.paymentSystem.GetById(ctx, processor.checked.paymentMethod.PaymentSystemId)
+	if msg := s.checkProjectAccount(ctx, order); msg != nil {
+		s.releaseOrderReservations(ctx, order)
+
+		if err = s.updateOrder(ctx, order); err != nil {
+			zap.L().Error("s.updateOrder Method failed", zap.Error(err), zap.String("order_uuid", order.Uuid))
+		}
+
+		rsp.Status = pkg.ResponseStatusBadData
+		if msg == orderErrorAccountCheckRejected {
+			rsp.Status = pkg.ResponseStatusForbidden
+		}
+		rsp.Message = msg
+		return nil
+	}
+
+	if err = s.scoreOrderRisk(ctx, order, req.Data, req.Ip); err != nil {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = orderErrorUnknown
+		return nil
+	}
+
+	if order.Risk.Outcome == pkg.RiskOutcomeReview {
+		// payment held for review can be only authorized, so review of payment which can't be authorized is denial
+		if processor.checked.paymentMethod.IsBankCard() {
+			holdOrderPaymentForRiskReview(order)
+		} else {
+			order.Risk.Outcome = pkg.RiskOutcomeDeny
+		}
+	}
+
+	if order.Risk.Outcome == pkg.RiskOutcomeDeny {
+		s.releaseOrderReservations(ctx, order)
+
+		if err = s.updateOrder(ctx, order); err != nil {
+			zap.L().Error("s.updateOrder Method failed", zap.Error(err), zap.String("order_uuid", order.Uuid))
+		}
+
+		rsp.Status = pkg.ResponseStatusForbidden
+		rsp.Message = orderErrorPaymentDeniedByRisk
+		return nil
+	}
+
+	routes, err := s.getPaymentRoutes(ctx, processor.checked.paymentMethod, order.MccCode, order.GetCountry())
 	if err != nil {
 		rsp.Message = orderErrorPaymentSystemInactive
 		rsp.Status = pkg.ResponseStatusBadData
@@ -984,6 +1069,8 @@ func (s *Service) PaymentCreateProcess(
 		return nil
 	}
 
+	ps := routes[0]
+
 	order.PaymentMethod = &billing.PaymentMethodOrder{
 		Id:              processor.checked.paymentMethod.Id,
 		Name:            processor.checked.paymentMethod.Name,
@@ -1005,8 +1092,17 @@ func (s *Service) PaymentCreateProcess(
 		return err
 	}
 
+	routePm, err := s.getRoutePaymentMethod(ctx, processor.checked.paymentMethod, ps)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = orderErrorPaymentMethodNotFound
+		return nil
+	}
+
+	order.PaymentMethod.Id = routePm.Id
 	order.PaymentMethod.Params, err = s.paymentMethod.GetPaymentSettings(
-		processor.checked.paymentMethod,
+		routePm,
 		order.ChargeCurrency,
 		order.MccCode,
 		order.OperatingCompanyId,
@@ -1046,6 +1142,12 @@ func (s *Service) PaymentCreateProcess(
 		return err
 	}
 
+	if msg := s.redeemOrderCoupon(ctx, order); msg != nil {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = msg
+		return nil
+	}
+
 	err = s.updateOrder(ctx, order)
 
 	if err != nil {
@@ -1065,32 +1167,34 @@ func (s *Service) PaymentCreateProcess(
 		return nil
 	}
 
-	if !s.hasPaymentCosts(ctx, order) {
+	// payment with store credit doesn't bring money through payment system, so it has no costs
+	if !order.IsPaidByStoreCredit() && !s.hasPaymentCosts(ctx, order) {
 		rsp.Status = pkg.ResponseStatusBadData
 		rsp.Message = orderErrorCostsRatesNotFound
 		return nil
 	}
 
-	h, err := s.NewPaymentSystem(ctx, s.cfg.PaymentSystemConfig, order)
-
-	if err != nil {
-		zap.S().Errorw(pkg.MethodFinishedWithError, "err", err.Error())
-		if e, ok := err.(*grpc.ResponseErrorMessage); ok {
-			rsp.Status = pkg.ResponseStatusSystemError
-			rsp.Message = e
-			return nil
-		}
-		return err
-	}
-
-	url, err := h.CreatePayment(order, s.cfg.GetRedirectUrlSuccess(nil), s.cfg.GetRedirectUrlFail(nil), req.Data)
+	url, err := s.createPaymentWithFailover(
+		ctx,
+		order,
+		processor.checked.paymentMethod,
+		routes,
+		processor.checked.project,
+		methodName,
+		req.Data,
+	)
 
 	if err != nil {
 		zap.L().Error(
-			"h.CreatePayment Method failed",
+			"s.createPaymentWithFailover Method failed",
 			zap.Error(err),
 			zap.Any("order", order),
 		)
+
+		if e := s.updateOrder(ctx, order); e != nil {
+			zap.L().Error("s.updateOrder Method failed", zap.Error(e), zap.String("order_uuid", order.Uuid))
+		}
+
 		if e, ok := err.(*grpc.ResponseErrorMessage); ok {
 			rsp.Status = pkg.ResponseStatusSystemError
 			rsp.Message = e
@@ -1102,7 +1206,13 @@ func (s *Service) PaymentCreateProcess(
 		return nil
 	}
 
-	err = s.updateOrder(ctx, order)
+	if order.IsPaidByStoreCredit() {
+		// payment with store credit is completed at once, so it's processed without callback of payment system
+		err = s.completeStoreCreditPayment(ctx, order)
+	} else {
+		err = s.updateOrder(ctx, order)
+	}
+
 	if err != nil {
 		zap.S().Errorf("Order create in payment system failed", "err", err.Error(), "order", order)
 
@@ -1123,6 +1233,10 @@ func (s *Service) PaymentCreateProcess(
 		rsp.NeedRedirect = false
 	}
 
+	if order.IsPaidByStoreCredit() {
+		rsp.NeedRedirect = false
+	}
+
 	return nil
 }
 
@@ -1153,34 +1267,48 @@ func (s *Service) PaymentCallbackProcess(
 		return orderErrorNotFound
 	}
 
-	var data protobuf.Message
+	provider, err := s.getPaymentSystemProvider(ctx, order.PaymentMethod.PaymentSystemId)
 
-	ps, err := s.paymentSystem.GetById(ctx, order.PaymentMethod.PaymentSystemId)
 	if err != nil {
+		if err == paymentSystemErrorHandlerNotFound {
+			return orderErrorPaymentMethodNotFound
+		}
 		return orderErrorPaymentSystemInactive
 	}
 
-	switch ps.Handler {
-	case pkg.PaymentSystemHandlerCardPay, paymentSystemHandlerCardPayMock:
-		data = &billing.CardPayPaymentCallback{}
-		err := json.Unmarshal(req.Request, data)
-
-		if err != nil {
-			return errors.New(paymentRequestIncorrect)
-		}
-		break
-	default:
+	if provider.ParsePaymentCallback == nil || provider.CheckCallbackSignature == nil {
 		return orderErrorPaymentMethodNotFound
 	}
 
-	h, err := s.NewPaymentSystem(ctx, s.cfg.PaymentSystemConfig, order)
+	data, err := provider.ParsePaymentCallback(req.Request)
 
 	if err != nil {
-		return err
+		return errors.New(paymentRequestIncorrect)
 	}
 
-	pErr := h.ProcessPayment(order, data, string(req.Request), req.Signature)
+	h := provider.New()
+
+	pErr := provider.CheckCallbackSignature(order, string(req.Request), req.Signature)
 
+	if pErr != nil {
+		order.PrivateStatus = constant.OrderStatusPaymentSystemReject
+	} else {
+		pErr = h.ProcessPayment(order, data, string(req.Request), req.Signature)
+	}
+
+	return s.processPaymentNotification(ctx, order, h, data, pErr, rsp)
+}
+
+// processPaymentNotification saves order after processing of payment notification by PaymentSystem.ProcessPayment
+// and runs post processing of the order according to the new status of the order.
+func (s *Service) processPaymentNotification(
+	ctx context.Context,
+	order *billing.Order,
+	h PaymentSystem,
+	data proto.Message,
+	pErr error,
+	rsp *grpc.PaymentNotifyResponse,
+) error {
 	if pErr != nil {
 		pErr, _ := pErr.(*grpc.ResponseError)
 
@@ -1192,6 +1320,16 @@ func (s *Service) PaymentCallbackProcess(
 		}
 	}
 
+	if pErr == nil {
+		if order.IsPaymentAuthorized() {
+			s.setPaymentAuthorizationExpireAt(order)
+		}
+
+		if order.PrivateStatus == constant.OrderStatusPaymentSystemComplete {
+			order.ApplyCapturedAmount()
+		}
+	}
+
 	switch order.PaymentMethod.ExternalId {
 	case constant.PaymentSystemGroupAliasBankCard:
 		if err := s.fillPaymentDataCard(order); err != nil {
@@ -1213,7 +1351,7 @@ func (s *Service) PaymentCallbackProcess(
 		break
 	}
 
-	err = s.updateOrder(ctx, order)
+	err := s.updateOrder(ctx, order)
 
 	if err != nil {
 		zap.S().Errorw(pkg.MethodFinishedWithError, "err", err.Error())
@@ -1226,6 +1364,25 @@ func (s *Service) PaymentCallbackProcess(
 	}
 
 	if pErr == nil {
+		if order.IsPaymentAuthorized() {
+			if order.ProductType == billing.OrderType_key {
+				err = s.processKeyProductsPaymentAuthorization(ctx, order)
+
+				if err != nil {
+					zap.L().Error(
+						pkg.MethodFinishedWithError,
+						zap.String("Method", "processKeyProductsPaymentAuthorization"),
+						zap.Error(err),
+						zap.String("orderId", order.Id),
+						zap.String("orderUuid", order.Uuid),
+					)
+				}
+			}
+
+			rsp.Status = pkg.StatusOK
+			return nil
+		}
+
 		if order.PrivateStatus == constant.OrderStatusPaymentSystemComplete {
 			err = s.paymentSystemPaymentCallbackComplete(ctx, order)
 
@@ -1364,7 +1521,7 @@ func (s *Service) PaymentFormPaymentAccountChanged(
 		return nil
 	}
 
-	ps, err := s.paymentSystem.GetById(ctx, pm.PaymentSystemId)
+	routes, err := s.getPaymentRoutes(ctx, pm, order.MccCode, order.GetCountry())
 	if err != nil {
 		rsp.Message = orderErrorPaymentSystemInactive
 		rsp.Status = pkg.ResponseStatusBadData
@@ -1372,6 +1529,8 @@ func (s *Service) PaymentFormPaymentAccountChanged(
 		return nil
 	}
 
+	ps := routes[0]
+
 	regex := pm.AccountRegexp
 
 	if pm.ExternalId == constant.PaymentSystemGroupAliasBankCard {
@@ -1435,8 +1594,16 @@ func (s *Service) PaymentFormPaymentAccountChanged(
 		return err
 	}
 
+	routePm, err := s.getRoutePaymentMethod(ctx, pm, ps)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = orderErrorPaymentMethodNotFound
+		return nil
+	}
+
 	order.PaymentMethod = &billing.PaymentMethodOrder{
-		Id:              pm.Id,
+		Id:              routePm.Id,
 		Name:            pm.Name,
 		PaymentSystemId: ps.Id,
 		Group:           pm.Group,
@@ -1457,7 +1624,7 @@ func (s *Service) PaymentFormPaymentAccountChanged(
 	}
 
 	order.PaymentMethod.Params, err = s.paymentMethod.GetPaymentSettings(
-		pm,
+		routePm,
 		order.ChargeCurrency,
 		order.MccCode,
 		order.OperatingCompanyId,
@@ -1653,7 +1820,13 @@ func (s *Service) ProcessBillingAddress(
 
 	rsp.Status = pkg.ResponseStatusOk
 	rsp.Cookie = cookie
-	rsp.Item = &grpc.ProcessBillingAddressResponseItem{
+	rsp.Item = getOrderAmountsResponseItem(order)
+
+	return nil
+}
+
+func getOrderAmountsResponseItem(order *billing.Order) *grpc.ProcessBillingAddressResponseItem {
+	return &grpc.ProcessBillingAddressResponseItem{
 		HasVat:               order.Tax.Rate > 0,
 		VatRate:              tools.ToPrecise(order.Tax.Rate),
 		Vat:                  tools.FormatAmount(order.Tax.Amount),
@@ -1665,9 +1838,8 @@ func (s *Service) ProcessBillingAddress(
 		ChargeAmount:         tools.FormatAmount(order.ChargeAmount),
 		Items:                order.Items,
 		CountryChangeAllowed: order.CountryChangeAllowed(),
+		Coupon:               order.Coupon,
 	}
-
-	return nil
 }
 
 func (s *Service) saveRecurringCard(ctx context.Context, order *billing.Order, recurringId string) {
@@ -1730,12 +1902,25 @@ func (s *Service) updateOrder(ctx context.Context, order *billing.Order) error {
 		}
 	}
 
+	if statusChanged && (ps == constant.OrderPublicStatusCanceled || ps == constant.OrderPublicStatusRejected) {
+		s.releaseOrderCoupon(ctx, order)
+	}
+
+	pendingWebhookEvents := order.PendingWebhookEvents
+
+	if statusChanged && order.NeedCallbackNotification() {
+		_, err := s.addOrderWebhookEvent(order)
+		order.SetNotificationStatus(ps, err == nil)
+	}
+
 	oid, _ := primitive.ObjectIDFromHex(order.Id)
 	filter := bson.M{"_id": oid}
 	_, err := s.db.Collection(collectionOrder).ReplaceOne(ctx, filter, order)
 
 	if err != nil {
 		s.logError(orderErrorUpdateOrderDataFailed, []interface{}{"error", err.Error(), "order", order})
+		order.PendingWebhookEvents = pendingWebhookEvents
+
 		if err == mongo.ErrNoDocuments {
 			return orderErrorNotFound
 		}
@@ -1748,8 +1933,8 @@ func (s *Service) updateOrder(ctx context.Context, order *billing.Order) error {
 		s.orderNotifyKeyProducts(context.TODO(), order)
 	}
 
-	if statusChanged && order.NeedCallbackNotification() {
-		s.orderNotifyMerchant(ctx, order)
+	if err = s.moveOrderWebhookEvents(ctx, order); err != nil {
+		zap.S().Debug("[updateOrder] move of webhook events to outbox failed", "order_id", order.Id)
 	}
 
 	return nil
@@ -1767,6 +1952,11 @@ func (s *Service) orderNotifyKeyProducts(ctx context.Context, order *billing.Ord
 	switch order.GetPublicStatus() {
 	case constant.OrderPublicStatusCanceled, constant.OrderPublicStatusRejected:
 		for _, key := range keys {
+			// key for pre-ordered product was not reserved yet
+			if key == "" {
+				continue
+			}
+
 			zap.S().Infow("[orderNotifyKeyProducts] trying to cancel reserving key", "order_id", order.Id, "key", key)
 			rsp := &grpc.EmptyResponseWithStatus{}
 			err = s.CancelRedeemKeyForOrder(ctx, &grpc.KeyForOrderRequest{KeyId: key}, rsp)
@@ -1783,6 +1973,10 @@ func (s *Service) orderNotifyKeyProducts(ctx context.Context, order *billing.Ord
 		break
 	case constant.OrderPublicStatusProcessed:
 		for _, key := range keys {
+			if key == "" {
+				continue
+			}
+
 			zap.S().Infow("[orderNotifyKeyProducts] trying to finish reserving key", "order_id", order.Id, "key", key)
 			rsp := &grpc.GetKeyForOrderRequestResponse{}
 			err = s.FinishRedeemKeyForOrder(ctx, &grpc.KeyForOrderRequest{KeyId: key}, rsp)
@@ -1798,6 +1992,15 @@ func (s *Service) orderNotifyKeyProducts(ctx context.Context, order *billing.Ord
 			s.sendMailWithCode(ctx, order, rsp.Key)
 		}
 		order.IsKeyProductNotified = true
+
+		if order.Gift != nil && isOrderGiftDue(order.Gift) {
+			_ = s.deliverOrderGift(ctx, order)
+		}
+
+		// keys may be uploaded while pre-order was waiting for payment
+		if order.IsPreOrder {
+			s.fulfilPreOrderKeys(ctx, order)
+		}
 		break
 	}
 }
@@ -1925,66 +2128,97 @@ func (s *Service) getPayloadForReceipt(ctx context.Context, order *billing.Order
 	return payload
 }
 
-func (s *Service) sendMailWithCode(ctx context.Context, order *billing.Order, key *billing.Key) {
+func (s *Service) sendMailWithCode(ctx context.Context, order *billing.Order, key *billing.Key) error {
+	// keys of gift are held until delivery of gift and sent to recipient of gift
+	if order.Gift != nil && order.Gift.DeliveredAt == nil {
+		zap.S().Infow("Mail with code held until delivery of gift", "order_id", order.Id, "key_id", key.Id)
+		return nil
+	}
+
 	var platformIconUrl = ""
 	if platform, ok := availablePlatforms[order.PlatformId]; ok {
 		platformIconUrl = platform.Icon
 	}
 
+	code, err := s.decryptKeyCode(key)
+
+	if err != nil {
+		zap.S().Errorw("Mail not sent because decryption of key code failed", "err", err, "order_id", order.Id, "key_id", key.Id)
+		return err
+	}
+
+	template, email := s.cfg.EmailTemplates.ActivationGameKey, order.ReceiptEmail
+
+	if order.Gift != nil {
+		template, email = s.cfg.EmailTemplates.GiftGameKey, order.Gift.RecipientEmail
+	}
+
 	for _, item := range order.Items {
 		if item.Id == key.KeyProductId {
 			item.Code = key.Code
 			payload := &postmarkSdrPkg.Payload{
-				TemplateAlias: s.cfg.EmailTemplates.ActivationGameKey,
+				TemplateAlias: template,
 				TemplateModel: map[string]string{
-					"code":          key.Code,
+					"code":          code,
 					"platform_icon": platformIconUrl,
 					"product_name":  item.Name,
 				},
-				To: order.ReceiptEmail,
+				To: email,
 			}
 
 			if len(item.Images) > 0 {
 				payload.TemplateModel["product_image"] = item.Images[0]
 			}
 
-			err := s.postmarkBroker.Publish(postmarkSdrPkg.PostmarkSenderTopicName, payload, amqp.Table{})
+			if order.Gift != nil {
+				payload.TemplateModel["gift_message"] = order.Gift.Message
+				payload.TemplateModel["sender_name"] = order.Gift.SenderName
+			}
+
+			err = s.postmarkBroker.Publish(postmarkSdrPkg.PostmarkSenderTopicName, payload, amqp.Table{})
 			if err != nil {
 				zap.S().Errorw(
 					"Publication activation code to user email queue is failed",
-					"err", err, "email", order.ReceiptEmail, "order_id", order.Id, "key_id", key.Id)
+					"err", err, "email", email, "order_id", order.Id, "key_id", key.Id)
 
 			} else {
-				zap.S().Infow("Sent payload to broker", "email", order.ReceiptEmail, "order_id", order.Id, "key_id", key.Id, "topic", postmarkSdrPkg.PostmarkSenderTopicName)
+				zap.S().Infow("Sent payload to broker", "email", email, "order_id", order.Id, "key_id", key.Id, "topic", postmarkSdrPkg.PostmarkSenderTopicName)
 			}
-			return
+			return err
 		}
 	}
 
-	zap.S().Errorw("Mail not sent because no items found for key", "order_id", order.Id, "key_id", key.Id, "email", order.ReceiptEmail)
+	zap.S().Errorw("Mail not sent because no items found for key", "order_id", order.Id, "key_id", key.Id, "email", email)
+	return orderErrorProductsInvalid
 }
 
+// orderNotifyMerchant notifies merchant about current status of order which was already saved,
+// e.g. after replacement of key which doesn't change public status of order
 func (s *Service) orderNotifyMerchant(ctx context.Context, order *billing.Order) {
-	zap.S().Debug("[orderNotifyMerchant] try to send notify merchant to rmq", "order_id", order.Id, "status", order.GetPublicStatus())
+	zap.S().Debug("[orderNotifyMerchant] try to add notify merchant webhook event", "order_id", order.Id, "status", order.GetPublicStatus())
 
-	err := s.broker.Publish(constant.PayOneTopicNotifyPaymentName, order, amqp.Table{"x-retry-count": int32(0)})
+	pendingWebhookEvents := order.PendingWebhookEvents
+	_, err := s.addOrderWebhookEvent(order)
 	if err != nil {
-		zap.S().Debug("[orderNotifyMerchant] send notify merchant to rmq failed", "order_id", order.Id)
-		s.logError(orderErrorPublishNotificationFailed, []interface{}{
-			"err", err.Error(), "order", order, "topic", constant.PayOneTopicNotifyPaymentName,
-		})
-	} else {
-		zap.S().Debug("[orderNotifyMerchant] send notify merchant to rmq failed", "order_id", order.Id)
+		zap.S().Debug("[orderNotifyMerchant] add notify merchant webhook event failed", "order_id", order.Id)
+		return
 	}
-	order.SetNotificationStatus(order.GetPublicStatus(), err == nil)
+
+	order.SetNotificationStatus(order.GetPublicStatus(), true)
 	oid, _ := primitive.ObjectIDFromHex(order.Id)
 	filter := bson.M{"_id": oid}
 	_, err = s.db.Collection(collectionOrder).ReplaceOne(ctx, filter, order)
 	if err != nil {
 		zap.S().Debug("[orderNotifyMerchant] notification status update failed", "order_id", order.Id)
 		s.logError(orderErrorUpdateOrderDataFailed, []interface{}{"error", err.Error(), "order", order})
-	} else {
-		zap.S().Debug("[orderNotifyMerchant] notification status updated succesfully", "order_id", order.Id)
+		order.PendingWebhookEvents = pendingWebhookEvents
+		return
+	}
+
+	zap.S().Debug("[orderNotifyMerchant] notification status updated succesfully", "order_id", order.Id)
+
+	if err = s.moveOrderWebhookEvents(ctx, order); err != nil {
+		zap.S().Debug("[orderNotifyMerchant] move of webhook events to outbox failed", "order_id", order.Id)
 	}
 }
 
@@ -2135,6 +2369,7 @@ func (v *OrderCreateRequestProcessor) prepareOrder() (*billing.Order, error) {
 		OperatingCompanyId:      v.checked.merchant.OperatingCompanyId,
 		IsHighRisk:              v.checked.merchant.IsHighRisk(),
 		IsCurrencyPredefined:    v.checked.isCurrencyPredefined,
+		IsPreAuthorization:      v.request.IsPreAuthorization || v.isKeyOrderPreAuthorization(),
 	}
 
 	if v.checked.virtualAmount > 0 {
@@ -2427,11 +2662,32 @@ func (v *OrderCreateRequestProcessor) processPaymentMethod(pm *billing.PaymentMe
 		return orderErrorPaymentMethodInactive
 	}
 
-	if _, err := v.paymentSystem.GetById(v.ctx, pm.PaymentSystemId); err != nil {
+	country := ""
+
+	if v.checked.user != nil && v.checked.user.Address != nil {
+		country = v.checked.user.Address.Country
+	}
+
+	routes, err := v.getPaymentRoutes(v.ctx, pm, v.checked.mccCode, country)
+
+	if err != nil {
 		return orderErrorPaymentSystemInactive
 	}
 
-	_, err := v.Service.paymentMethod.GetPaymentSettings(pm, v.checked.currency, v.checked.mccCode, v.checked.operatingCompanyId, "", v.checked.project)
+	routePm, err := v.getRoutePaymentMethod(v.ctx, pm, routes[0])
+
+	if err != nil {
+		return err
+	}
+
+	_, err = v.Service.paymentMethod.GetPaymentSettings(
+		routePm,
+		v.checked.currency,
+		v.checked.mccCode,
+		v.checked.operatingCompanyId,
+		"",
+		v.checked.project,
+	)
 
 	if err != nil {
 		return err
@@ -2504,6 +2760,12 @@ func (v *OrderCreateRequestProcessor) processLimitAmounts() (err error) {
 	return
 }
 
+// isKeyOrderPreAuthorization checks that payment of key products order must be only authorized
+// until keys of order are redeemed, it's enabled by the project setting
+func (v *OrderCreateRequestProcessor) isKeyOrderPreAuthorization() bool {
+	return v.request.Type == billing.OrderType_key && v.checked.project.PreAuthorizeKeyOrders
+}
+
 func (v *OrderCreateRequestProcessor) processSignature() error {
 	var hashString string
 
@@ -2790,6 +3052,7 @@ func (v *PaymentCreateProcessor) reserveKeysForOrder(ctx context.Context, order
 	if len(order.Keys) == 0 {
 		zap.S().Infow("[ProcessOrderKeyProducts] reserving keys", "order_id", order.Id)
 		keys := make([]string, len(order.Products))
+		ttl := v.service.getOrderKeyReservationTtl(ctx, order)
 		for i, productId := range order.Products {
 			reserveRes := &grpc.PlatformKeyReserveResponse{}
 			reserveReq := &grpc.PlatformKeyReserveRequest{
@@ -2797,7 +3060,7 @@ func (v *PaymentCreateProcessor) reserveKeysForOrder(ctx context.Context, order
 				MerchantId:   order.Project.MerchantId,
 				OrderId:      order.Id,
 				KeyProductId: productId,
-				Ttl:          oneDayTtl,
+				Ttl:          ttl,
 			}
 
 			err := v.service.ReserveKeyForOrder(ctx, reserveReq, reserveRes)
@@ -2812,6 +3075,13 @@ func (v *PaymentCreateProcessor) reserveKeysForOrder(ctx context.Context, order
 			}
 
 			if reserveRes.Status != pkg.ResponseStatusOk {
+				// key product sold as pre-order, key will be delivered after upload of new keys
+				if v.service.isKeyProductPreOrderEnabled(ctx, productId) {
+					zap.S().Infow("[ProcessOrderKeyProducts] no keys in stock, product is pre-ordered", "product", productId, "order_id", order.Id)
+					order.IsPreOrder = true
+					continue
+				}
+
 				zap.S().Errorw("[ProcessOrderKeyProducts] can't reserve key. Cancelling reserved before", "message", reserveRes.Message, "order_id", order.Id)
 
 				// we should cancel reservation for keys reserved before
@@ -2846,6 +3116,32 @@ func (v *PaymentCreateProcessor) reserveKeysForOrder(ctx context.Context, order
 	return nil
 }
 
+// releaseOrderReservations returns to stock keys reserved for order and cancels use of coupon by order
+// when payment of order can't be created, so they aren't held until expiration of reservation.
+func (s *Service) releaseOrderReservations(ctx context.Context, order *billing.Order) {
+	for _, key := range order.Keys {
+		// key for pre-ordered product was not reserved
+		if key == "" {
+			continue
+		}
+
+		rsp := &grpc.EmptyResponseWithStatus{}
+		err := s.CancelRedeemKeyForOrder(ctx, &grpc.KeyForOrderRequest{KeyId: key}, rsp)
+
+		if err != nil || rsp.Status != pkg.ResponseStatusOk {
+			zap.L().Error(
+				"cancel of key reservation failed",
+				zap.Error(err),
+				zap.String("order_id", order.Id),
+				zap.String("key_id", key),
+			)
+		}
+	}
+
+	order.Keys = nil
+	s.releaseOrderCoupon(ctx, order)
+}
+
 // Validate data received from payment form and write validated data to order
 func (v *PaymentCreateProcessor) processPaymentFormData(ctx context.Context) error {
 	if _, ok := v.data[pkg.PaymentCreateFieldOrderId]; !ok ||
@@ -2939,11 +3235,13 @@ func (v *PaymentCreateProcessor) processPaymentFormData(ctx context.Context) err
 		return orderErrorPaymentMethodInactive
 	}
 
-	ps, err := v.service.paymentSystem.GetById(ctx, pm.PaymentSystemId)
+	routes, err := v.service.getPaymentRoutes(ctx, pm, order.MccCode, order.GetCountry())
 	if err != nil {
 		return orderErrorPaymentSystemInactive
 	}
 
+	ps := routes[0]
+
 	if err := processor.processLimitAmounts(); err != nil {
 		if e, ok := err.(*grpc.ResponseErrorMessage); ok {
 			return e
@@ -3102,9 +3400,15 @@ func (v *PaymentCreateProcessor) processPaymentFormData(ctx context.Context) err
 		order.PaymentRequisites = v.data
 	}
 
+	routePm, err := v.service.getRoutePaymentMethod(ctx, pm, ps)
+
+	if err != nil {
+		return err
+	}
+
 	if order.PaymentMethod == nil {
 		order.PaymentMethod = &billing.PaymentMethodOrder{
-			Id:              pm.Id,
+			Id:              routePm.Id,
 			Name:            pm.Name,
 			PaymentSystemId: ps.Id,
 			Group:           pm.Group,
@@ -3117,7 +3421,7 @@ func (v *PaymentCreateProcessor) processPaymentFormData(ctx context.Context) err
 	methodName, err := order.GetCostPaymentMethodName()
 	if err == nil {
 		order.PaymentMethod.Params, err = v.service.paymentMethod.GetPaymentSettings(
-			pm,
+			routePm,
 			processor.checked.currency,
 			processor.checked.mccCode,
 			processor.checked.operatingCompanyId,
@@ -3516,6 +3820,7 @@ func (s *Service) ProcessOrderKeyProducts(ctx context.Context, order *billing.Or
 	order.ChargeCurrency = order.Currency
 
 	order.Items = items
+	s.processOrderCoupon(ctx, order)
 
 	return platforms, nil
 }
@@ -3562,6 +3867,7 @@ func (s *Service) ProcessOrderProducts(ctx context.Context, order *billing.Order
 	order.ChargeCurrency = order.Currency
 
 	order.Items = items
+	s.processOrderCoupon(ctx, order)
 
 	return nil
 }
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/constant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"time"
+)
+
+var (
+	orderExpiryStatuses = []int32{
+		constant.OrderStatusNew,
+		constant.OrderStatusPaymentSystemCreate,
+	}
+)
+
+// ExpireOrders cancels orders which weren't paid during order expiry timeout. Timeout can be set in
+// project settings, otherwise default timeout from configuration used. Orders which payment was already created
+// in payment system are canceled only if payment system hasn't payment for them. Reserved keys of canceled orders
+// are released and merchants are notified about cancellation by updateOrder.
+func (s *Service) ExpireOrders(ctx context.Context) error {
+	projects, err := s.project.GetWithOrderExpiryTimeout(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	projectOids := make([]primitive.ObjectID, 0, len(projects))
+
+	for _, project := range projects {
+		oid, _ := primitive.ObjectIDFromHex(project.Id)
+		projectOids = append(projectOids, oid)
+
+		query := bson.M{
+			"project._id":    oid,
+			"private_status": bson.M{"$in": orderExpiryStatuses},
+			"created_at":     bson.M{"$lte": now.Add(-time.Duration(project.OrderExpiryTimeout) * time.Second)},
+		}
+
+		if err = s.expireOrders(ctx, query); err != nil {
+			return err
+		}
+	}
+
+	query := bson.M{
+		"project._id":    bson.M{"$nin": projectOids},
+		"private_status": bson.M{"$in": orderExpiryStatuses},
+		"created_at":     bson.M{"$lte": now.Add(-s.cfg.GetOrderExpiryTimeout())},
+	}
+
+	return s.expireOrders(ctx, query)
+}
+
+func (s *Service) expireOrders(ctx context.Context, query bson.M) error {
+	cursor, err := s.db.Collection(collectionOrder).Find(ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionOrder),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return err
+	}
+
+	var orders []*billing.Order
+	err = cursor.All(ctx, &orders)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionOrder),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return err
+	}
+
+	for _, order := range orders {
+		if order.PrivateStatus == constant.OrderStatusPaymentSystemCreate && !s.isOrderPaymentAbsent(ctx, order) {
+			continue
+		}
+
+		order.PrivateStatus = constant.OrderStatusPaymentSystemCanceled
+		order.Canceled = true
+		order.CanceledAt = ptypes.TimestampNow()
+
+		if err = s.updateOrder(ctx, order); err != nil {
+			zap.L().Error(
+				"cancel of expired order failed",
+				zap.Error(err),
+				zap.String("order_uuid", order.Uuid),
+			)
+		}
+	}
+
+	return nil
+}
+
+// isOrderPaymentAbsent requests payment status of order from payment system before cancellation of order.
+// Order can be canceled only if payment system hasn't payment for it, otherwise payment status is processed
+// the same way as on reconciliation and order stays in its status until payment system notification.
+func (s *Service) isOrderPaymentAbsent(ctx context.Context, order *billing.Order) bool {
+	err := s.reconcilePaymentStatus(ctx, order)
+
+	if err == paymentSystemErrorPaymentNotFound {
+		return true
+	}
+
+	if err != nil {
+		zap.L().Error(
+			"payment status request of expired order failed",
+			zap.Error(err),
+			zap.String("order_uuid", order.Uuid),
+		)
+	}
+
+	return false
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/paylink"
 	"github.com/paysuper/paysuper-recurring-repository/pkg/constant"
+	"github.com/paysuper/paysuper-recurring-repository/tools"
 	postmarkSdrPkg "github.com/paysuper/postmark-sender/pkg"
 	"github.com/stoewer/go-strcase"
 	"github.com/streadway/amqp"
@@ -34,7 +35,10 @@ import (
 	"go.uber.org/zap/zaptest/observer"
 	"gopkg.in/ProtocolONE/rabbitmq.v1/pkg"
 	mongodb "gopkg.in/paysuper/paysuper-database-mongo.v1"
+	"io/ioutil"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"sort"
 	"strconv"
 	"strings"
@@ -88,7 +92,7 @@ func (suite *OrderTestSuite) SetupTest() {
 	if err != nil {
 		suite.FailNow("Config load failed", "%v", err)
 	}
-	cfg.CardPayApiUrl = "https://sandbox.cardpay.com"
+	cfg.CardPay.ApiUrl = "https://sandbox.cardpay.com"
 
 	db, err := mongodb.NewDatabase()
 	if err != nil {
@@ -3459,6 +3463,156 @@ func (suite *OrderTestSuite) TestOrder_OrderCreateProcess_Ok() {
 	assert.Equal(suite.T(), pkg.OrderTypeOrder, rsp.Item.Type)
 }
 
+func (suite *OrderTestSuite) TestOrder_OrderCreateProcess_IdempotencyKey_Ok() {
+	req := &billing.OrderCreateRequest{
+		Type:           billing.OrderType_simple,
+		ProjectId:      suite.project.Id,
+		PaymentMethod:  suite.paymentMethod.Group,
+		Currency:       "RUB",
+		Amount:         100,
+		Account:        "unit test",
+		Description:    "unit test",
+		OrderId:        primitive.NewObjectID().Hex(),
+		IdempotencyKey: primitive.NewObjectID().Hex(),
+		User: &billing.OrderUser{
+			Email: "test@unit.unit",
+			Ip:    "127.0.0.1",
+		},
+	}
+
+	rsp := &grpc.OrderCreateProcessResponse{}
+	err := suite.service.OrderCreateProcess(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.NotEmpty(suite.T(), rsp.Item.Uuid)
+
+	rsp1 := &grpc.OrderCreateProcessResponse{}
+	err = suite.service.OrderCreateProcess(context.TODO(), req, rsp1)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp1.Status)
+	assert.Equal(suite.T(), rsp.Item.Id, rsp1.Item.Id)
+	assert.Equal(suite.T(), rsp.Item.Uuid, rsp1.Item.Uuid)
+
+	oid, err := primitive.ObjectIDFromHex(suite.project.Id)
+	assert.NoError(suite.T(), err)
+	count, err := suite.service.db.Collection(collectionOrder).
+		CountDocuments(context.TODO(), bson.M{"project._id": oid, "project_order_id": req.OrderId})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, count)
+}
+
+func (suite *OrderTestSuite) TestOrder_OrderCreateProcess_IdempotencyKey_PayloadMismatch_Error() {
+	req := &billing.OrderCreateRequest{
+		Type:           billing.OrderType_simple,
+		ProjectId:      suite.project.Id,
+		PaymentMethod:  suite.paymentMethod.Group,
+		Currency:       "RUB",
+		Amount:         100,
+		Account:        "unit test",
+		Description:    "unit test",
+		OrderId:        primitive.NewObjectID().Hex(),
+		IdempotencyKey: primitive.NewObjectID().Hex(),
+		User: &billing.OrderUser{
+			Email: "test@unit.unit",
+			Ip:    "127.0.0.1",
+		},
+	}
+
+	rsp := &grpc.OrderCreateProcessResponse{}
+	err := suite.service.OrderCreateProcess(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	req.Amount = 200
+	rsp1 := &grpc.OrderCreateProcessResponse{}
+	err = suite.service.OrderCreateProcess(context.TODO(), req, rsp1)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusConflict, rsp1.Status)
+	assert.Equal(suite.T(), idempotencyErrorKeyConflict, rsp1.Message)
+	assert.Nil(suite.T(), rsp1.Item)
+}
+
+func (suite *OrderTestSuite) TestOrder_processIdempotentRequest_LockLifetime() {
+	key := primitive.NewObjectID().Hex()
+	storageKey := fmt.Sprintf(idempotencyStorageKey, idempotencyMethodOrderCreate, suite.project.Id, key)
+	req := &billing.OrderCreateRequest{OrderId: key}
+	rsp := &grpc.OrderCreateProcessResponse{}
+
+	err := suite.service.processIdempotentRequest(
+		idempotencyMethodOrderCreate,
+		suite.project.Id,
+		key,
+		req,
+		rsp,
+		func(message *grpc.ResponseErrorMessage) {},
+		func() error {
+			ttl, err := suite.service.redis.TTL(storageKey).Result()
+			assert.NoError(suite.T(), err)
+			assert.True(suite.T(), ttl <= suite.service.cfg.GetIdempotencyKeyLockLifetime())
+
+			rsp.Status = pkg.ResponseStatusOk
+			return nil
+		},
+	)
+	assert.NoError(suite.T(), err)
+
+	ttl, err := suite.service.redis.TTL(storageKey).Result()
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), ttl > suite.service.cfg.GetIdempotencyKeyLockLifetime())
+}
+
+func (suite *OrderTestSuite) TestOrder_processIdempotentRequest_LockProlonged() {
+	lockLifetime := suite.service.cfg.IdempotencyKeyLockLifetime
+	suite.service.cfg.IdempotencyKeyLockLifetime = 1
+	defer func() { suite.service.cfg.IdempotencyKeyLockLifetime = lockLifetime }()
+
+	key := primitive.NewObjectID().Hex()
+	storageKey := fmt.Sprintf(idempotencyStorageKey, idempotencyMethodOrderCreate, suite.project.Id, key)
+	rsp := &grpc.OrderCreateProcessResponse{}
+
+	err := suite.service.processIdempotentRequest(
+		idempotencyMethodOrderCreate,
+		suite.project.Id,
+		key,
+		&billing.OrderCreateRequest{OrderId: key},
+		rsp,
+		func(message *grpc.ResponseErrorMessage) {},
+		func() error {
+			// request takes longer than lifetime of the lock
+			time.Sleep(2 * time.Second)
+
+			exists, err := suite.service.redis.Exists(storageKey).Result()
+			assert.NoError(suite.T(), err)
+			assert.EqualValues(suite.T(), 1, exists)
+
+			rsp.Status = pkg.ResponseStatusOk
+			return nil
+		},
+	)
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *OrderTestSuite) TestOrder_processIdempotentRequest_RedisNotConfigured_Error() {
+	service := &Service{cfg: suite.service.cfg}
+	calls := 0
+
+	rsp := &grpc.OrderCreateProcessResponse{}
+	err := service.processIdempotentRequest(
+		idempotencyMethodOrderCreate,
+		suite.project.Id,
+		primitive.NewObjectID().Hex(),
+		&billing.OrderCreateRequest{},
+		rsp,
+		func(message *grpc.ResponseErrorMessage) {},
+		func() error {
+			calls++
+			return nil
+		},
+	)
+	assert.Equal(suite.T(), idempotencyErrorStorageNotFound, err)
+	assert.Equal(suite.T(), 0, calls)
+}
+
 func (suite *OrderTestSuite) TestOrder_OrderCreateProcess_ProjectInactive_Error() {
 	req := &billing.OrderCreateRequest{
 		Type:          billing.OrderType_simple,
@@ -4821,6 +4975,109 @@ func (suite *OrderTestSuite) TestOrder_PaymentCreateProcess_Ok() {
 	assert.NotNil(suite.T(), order1)
 }
 
+func (suite *OrderTestSuite) createOrderWithCheckAccountUrl(url string) (*billing.Order, *grpc.PaymentCreateRequest) {
+	req := &billing.OrderCreateRequest{
+		Type:        billing.OrderType_simple,
+		ProjectId:   suite.project.Id,
+		Currency:    "RUB",
+		Amount:      100,
+		Account:     "unit test",
+		Description: "unit test",
+		OrderId:     primitive.NewObjectID().Hex(),
+		User: &billing.OrderUser{
+			Email: "test@unit.unit",
+			Ip:    "127.0.0.1",
+		},
+	}
+
+	rsp := &grpc.OrderCreateProcessResponse{}
+	err := suite.service.OrderCreateProcess(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	order := rsp.Item
+	order.Project.UrlCheckAccount = url
+	err = suite.service.updateOrder(context.TODO(), order)
+	assert.NoError(suite.T(), err)
+
+	createPaymentRequest := &grpc.PaymentCreateRequest{
+		Data: map[string]string{
+			pkg.PaymentCreateFieldOrderId:         order.Uuid,
+			pkg.PaymentCreateFieldPaymentMethodId: suite.paymentMethod.Id,
+			pkg.PaymentCreateFieldEmail:           "test@unit.unit",
+			pkg.PaymentCreateFieldPan:             "4000000000000002",
+			pkg.PaymentCreateFieldCvv:             "123",
+			pkg.PaymentCreateFieldMonth:           "02",
+			pkg.PaymentCreateFieldYear:            time.Now().AddDate(1, 0, 0).Format("2006"),
+			pkg.PaymentCreateFieldHolder:          "Mr. Card Holder",
+		},
+		Ip: "127.0.0.1",
+	}
+
+	return order, createPaymentRequest
+}
+
+func (suite *OrderTestSuite) TestOrder_PaymentCreateProcess_CheckAccount_Ok() {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		_ = json.Unmarshal(b, &body)
+		assert.Equal(suite.T(), getWebhookSignature(string(b), suite.project.SecretKey), r.Header.Get(pkg.WebhookHeaderSignature))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	order, req := suite.createOrderWithCheckAccountUrl(server.URL)
+
+	rsp := &grpc.PaymentCreateResponse{}
+	err := suite.service.PaymentCreateProcess(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Equal(suite.T(), order.Uuid, body["order_id"])
+	assert.Equal(suite.T(), order.ProjectAccount, body["project_account"])
+	assert.Equal(suite.T(), order.Currency, body["currency"])
+}
+
+func (suite *OrderTestSuite) TestOrder_PaymentCreateProcess_CheckAccount_Rejected() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	_, req := suite.createOrderWithCheckAccountUrl(server.URL)
+
+	rsp := &grpc.PaymentCreateResponse{}
+	err := suite.service.PaymentCreateProcess(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusForbidden, rsp.Status)
+	assert.Equal(suite.T(), orderErrorAccountCheckRejected, rsp.Message)
+}
+
+func (suite *OrderTestSuite) TestOrder_PaymentCreateProcess_CheckAccount_Unavailable() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	_, req := suite.createOrderWithCheckAccountUrl(server.URL)
+
+	suite.service.cfg.CheckAccountFailOpen = false
+	defer func() { suite.service.cfg.CheckAccountFailOpen = true }()
+
+	rsp := &grpc.PaymentCreateResponse{}
+	err := suite.service.PaymentCreateProcess(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), orderErrorAccountCheckFailed, rsp.Message)
+
+	suite.service.cfg.CheckAccountFailOpen = true
+
+	rsp = &grpc.PaymentCreateResponse{}
+	err = suite.service.PaymentCreateProcess(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+}
+
 func (suite *OrderTestSuite) TestOrder_PaymentCreateProcess_ProcessValidation_Error() {
 	req := &billing.OrderCreateRequest{
 		Type:        billing.OrderType_simple,
@@ -6765,10 +7022,10 @@ func (suite *OrderTestSuite) TestOrder_GetNotificationStatus() {
 	assert.True(suite.T(), ns)
 }
 
-func (suite *OrderTestSuite) TestOrder_orderNotifyMerchant_Ok() {
+func (suite *OrderTestSuite) createOrderForExpiry(project *billing.Project, createdAt time.Time) *billing.Order {
 	req := &billing.OrderCreateRequest{
 		Type:        billing.OrderType_simple,
-		ProjectId:   suite.project.Id,
+		ProjectId:   project.Id,
 		Currency:    "RUB",
 		Amount:      100,
 		Account:     "unit test",
@@ -6780,108 +7037,384 @@ func (suite *OrderTestSuite) TestOrder_orderNotifyMerchant_Ok() {
 		},
 	}
 
-	rsp0 := &grpc.OrderCreateProcessResponse{}
-	err := suite.service.OrderCreateProcess(context.TODO(), req, rsp0)
+	rsp := &grpc.OrderCreateProcessResponse{}
+	err := suite.service.OrderCreateProcess(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
 
-	assert.Nil(suite.T(), err)
-	assert.Equal(suite.T(), rsp0.Status, pkg.ResponseStatusOk)
-	order := rsp0.Item
+	order := rsp.Item
+	order.CreatedAt, _ = ptypes.TimestampProto(createdAt)
+	err = suite.service.updateOrder(context.TODO(), order)
+	assert.NoError(suite.T(), err)
 
-	ps := order.GetPublicStatus()
-	assert.Equal(suite.T(), ps, constant.OrderPublicStatusCreated)
-	nS := order.GetNotificationStatus(ps)
-	assert.False(suite.T(), nS)
-	assert.False(suite.T(), order.GetNotificationStatus(constant.OrderPublicStatusProcessed))
-	assert.Equal(suite.T(), len(order.IsNotificationsSent), 0)
+	return order
+}
 
-	order.PrivateStatus = constant.OrderStatusProjectComplete
-	err = suite.service.updateOrder(context.TODO(), order)
+func (suite *OrderTestSuite) TestOrder_ExpireOrders_Ok() {
+	expired := suite.createOrderForExpiry(suite.project, time.Now().Add(-suite.service.cfg.GetOrderExpiryTimeout()-time.Minute))
+	notExpired := suite.createOrderForExpiry(suite.project, time.Now())
+
+	err := suite.service.ExpireOrders(context.TODO())
 	assert.NoError(suite.T(), err)
 
-	ps = order.GetPublicStatus()
-	assert.Equal(suite.T(), ps, constant.OrderPublicStatusProcessed)
-	nS = order.GetNotificationStatus(ps)
-	assert.True(suite.T(), nS)
-	assert.Equal(suite.T(), len(order.IsNotificationsSent), 1)
+	order, err := suite.service.getOrderById(context.TODO(), expired.Id)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), constant.OrderStatusPaymentSystemCanceled, order.PrivateStatus)
+	assert.True(suite.T(), order.Canceled)
+	assert.NotNil(suite.T(), order.CanceledAt)
+	assert.True(suite.T(), order.GetNotificationStatus(constant.OrderPublicStatusCanceled))
+
+	count, err := suite.service.webhookEvent.Count(context.TODO(), bson.M{"order_uuid": order.Uuid})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, count)
+
+	order, err = suite.service.getOrderById(context.TODO(), notExpired.Id)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), constant.OrderStatusNew, order.PrivateStatus)
 }
 
-func (suite *OrderTestSuite) TestCardpay_fillPaymentDataCrypto() {
-	var (
-		name    = "Bitcoin"
-		address = "1ByR2GSfDMuFGVoUzh4a5pzgrVuoTdr8wU"
-	)
+func (suite *OrderTestSuite) TestOrder_ExpireOrders_ProjectTimeout_Ok() {
+	project := suite.project
+	project.OrderExpiryTimeout = 60
+	err := suite.service.project.Update(context.TODO(), project)
+	assert.NoError(suite.T(), err)
+
+	expired := suite.createOrderForExpiry(project, time.Now().Add(-2*time.Minute))
+	notExpired := suite.createOrderForExpiry(project, time.Now().Add(-30*time.Second))
+
+	err = suite.service.ExpireOrders(context.TODO())
+	assert.NoError(suite.T(), err)
+
+	order, err := suite.service.getOrderById(context.TODO(), expired.Id)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), constant.OrderStatusPaymentSystemCanceled, order.PrivateStatus)
+
+	order, err = suite.service.getOrderById(context.TODO(), notExpired.Id)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), constant.OrderStatusNew, order.PrivateStatus)
+}
 
+func (suite *OrderTestSuite) createOrderWithPayment() *billing.Order {
 	req := &billing.OrderCreateRequest{
-		Type:        billing.OrderType_simple,
-		ProjectId:   suite.project.Id,
+		ProjectId:   suite.projectWithProducts.Id,
 		Currency:    "RUB",
-		Amount:      100,
 		Account:     "unit test",
 		Description: "unit test",
 		OrderId:     primitive.NewObjectID().Hex(),
+		Products:    suite.productIds,
 		User: &billing.OrderUser{
 			Email: "test@unit.unit",
 			Ip:    "127.0.0.1",
 		},
+		Type: billing.OrderType_product,
 	}
 
-	rsp0 := &grpc.OrderCreateProcessResponse{}
-	err := suite.service.OrderCreateProcess(context.TODO(), req, rsp0)
-
-	assert.Nil(suite.T(), err)
-	assert.Equal(suite.T(), rsp0.Status, pkg.ResponseStatusOk)
-	order := rsp0.Item
+	rsp1 := &grpc.OrderCreateProcessResponse{}
+	err := suite.service.OrderCreateProcess(context.TODO(), req, rsp1)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp1.Status)
 
-	order.PaymentMethod = &billing.PaymentMethodOrder{
-		Name: name,
+	createPaymentRequest := &grpc.PaymentCreateRequest{
+		Data: map[string]string{
+			pkg.PaymentCreateFieldOrderId:         rsp1.Item.Uuid,
+			pkg.PaymentCreateFieldPaymentMethodId: suite.paymentMethod.Id,
+			pkg.PaymentCreateFieldEmail:           "test@unit.unit",
+			pkg.PaymentCreateFieldPan:             "4000000000000002",
+			pkg.PaymentCreateFieldCvv:             "123",
+			pkg.PaymentCreateFieldMonth:           "02",
+			pkg.PaymentCreateFieldYear:            time.Now().AddDate(1, 0, 0).Format("2006"),
+			pkg.PaymentCreateFieldHolder:          "Mr. Card Holder",
+		},
+		Ip: "127.0.0.1",
 	}
-	order.PaymentMethodTxnParams = make(map[string]string)
-	order.PaymentMethodTxnParams[pkg.PaymentCreateFieldCrypto] = address
-	order.PaymentMethodTxnParams[pkg.TxnParamsFieldCryptoTransactionId] = "7d8c131c-092c-4a5b-83ed-5137ecb9b083"
-	order.PaymentMethodTxnParams[pkg.TxnParamsFieldCryptoAmount] = "0.0001"
-	order.PaymentMethodTxnParams[pkg.TxnParamsFieldCryptoCurrency] = "BTC"
 
-	err = suite.service.fillPaymentDataCrypto(order)
+	rsp2 := &grpc.PaymentCreateResponse{}
+	err = suite.service.PaymentCreateProcess(context.TODO(), createPaymentRequest, rsp2)
 	assert.NoError(suite.T(), err)
-	assert.Equal(suite.T(), order.PaymentMethodPayerAccount, address)
-	assert.NotNil(suite.T(), order.PaymentMethod.CryptoCurrency)
-	assert.Equal(suite.T(), order.PaymentMethod.CryptoCurrency.Brand, name)
-	assert.Equal(suite.T(), order.PaymentMethod.CryptoCurrency.Address, address)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp2.Status)
+
+	order, err := suite.service.getOrderById(context.TODO(), rsp1.Item.Id)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), constant.OrderStatusPaymentSystemCreate, order.PrivateStatus)
+
+	return order
 }
 
-func (suite *OrderTestSuite) TestCardpay_fillPaymentDataEwallet() {
-	var (
-		name    = "yamoney"
-		account = "41001811131268"
-	)
+func (suite *OrderTestSuite) TestOrder_ExpireOrders_PaymentExists_NotCanceled() {
+	order := suite.createOrderWithPayment()
+
+	order.CreatedAt, _ = ptypes.TimestampProto(time.Now().Add(-suite.service.cfg.GetOrderExpiryTimeout() - time.Minute))
+	err := suite.service.updateOrder(context.TODO(), order)
+	assert.NoError(suite.T(), err)
+
+	err = suite.service.ExpireOrders(context.TODO())
+	assert.NoError(suite.T(), err)
+
+	order, err = suite.service.getOrderById(context.TODO(), order.Id)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), constant.OrderStatusPaymentSystemComplete, order.PrivateStatus)
+	assert.False(suite.T(), order.Canceled)
+}
+
+func (suite *OrderTestSuite) TestOrder_ReconcilePaymentStatuses_Ok() {
 	req := &billing.OrderCreateRequest{
-		ProjectId:   suite.project.Id,
+		ProjectId:   suite.projectWithProducts.Id,
 		Currency:    "RUB",
-		Amount:      100,
 		Account:     "unit test",
 		Description: "unit test",
 		OrderId:     primitive.NewObjectID().Hex(),
+		Products:    suite.productIds,
 		User: &billing.OrderUser{
 			Email: "test@unit.unit",
 			Ip:    "127.0.0.1",
 		},
-		Type: billing.OrderType_simple,
-	}
-
-	rsp0 := &grpc.OrderCreateProcessResponse{}
-	err := suite.service.OrderCreateProcess(context.TODO(), req, rsp0)
-
-	assert.Nil(suite.T(), err)
-	assert.Equal(suite.T(), rsp0.Status, pkg.ResponseStatusOk)
-	order := rsp0.Item
-
-	order.PaymentMethod = &billing.PaymentMethodOrder{
-		Name: name,
+		Type: billing.OrderType_product,
 	}
-	order.PaymentMethodTxnParams = make(map[string]string)
-	order.PaymentMethodTxnParams[pkg.PaymentCreateFieldEWallet] = account
 
-	err = suite.service.fillPaymentDataEwallet(order)
+	rsp1 := &grpc.OrderCreateProcessResponse{}
+	err := suite.service.OrderCreateProcess(context.TODO(), req, rsp1)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp1.Status)
+
+	createPaymentRequest := &grpc.PaymentCreateRequest{
+		Data: map[string]string{
+			pkg.PaymentCreateFieldOrderId:         rsp1.Item.Uuid,
+			pkg.PaymentCreateFieldPaymentMethodId: suite.paymentMethod.Id,
+			pkg.PaymentCreateFieldEmail:           "test@unit.unit",
+			pkg.PaymentCreateFieldPan:             "4000000000000002",
+			pkg.PaymentCreateFieldCvv:             "123",
+			pkg.PaymentCreateFieldMonth:           "02",
+			pkg.PaymentCreateFieldYear:            time.Now().AddDate(1, 0, 0).Format("2006"),
+			pkg.PaymentCreateFieldHolder:          "Mr. Card Holder",
+		},
+		Ip: "127.0.0.1",
+	}
+
+	rsp2 := &grpc.PaymentCreateResponse{}
+	err = suite.service.PaymentCreateProcess(context.TODO(), createPaymentRequest, rsp2)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp2.Status)
+
+	order, err := suite.service.getOrderById(context.TODO(), rsp1.Item.Id)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), constant.OrderStatusPaymentSystemCreate, order.PrivateStatus)
+
+	err = suite.service.ReconcilePaymentStatuses(context.TODO())
+	assert.NoError(suite.T(), err)
+
+	order, err = suite.service.getOrderById(context.TODO(), rsp1.Item.Id)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), constant.OrderStatusPaymentSystemCreate, order.PrivateStatus)
+
+	order.CreatedAt, _ = ptypes.TimestampProto(time.Now().Add(-suite.service.cfg.GetPaymentStatusReconciliationThreshold() - time.Minute))
+	err = suite.service.updateOrder(context.TODO(), order)
+	assert.NoError(suite.T(), err)
+
+	err = suite.service.ReconcilePaymentStatuses(context.TODO())
+	assert.NoError(suite.T(), err)
+
+	order, err = suite.service.getOrderById(context.TODO(), rsp1.Item.Id)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), constant.OrderStatusPaymentSystemComplete, order.PrivateStatus)
+	assert.Equal(suite.T(), "0987654321", order.Transaction)
+
+	var discrepancy *billing.PaymentStatusDiscrepancy
+	err = suite.service.db.Collection(collectionPaymentStatusDiscrepancy).
+		FindOne(context.TODO(), bson.M{"order_uuid": order.Uuid}).
+		Decode(&discrepancy)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.PaymentStatusReconciliationResultChanged, discrepancy.Result)
+	assert.EqualValues(suite.T(), constant.OrderStatusPaymentSystemCreate, discrepancy.PreviousPrivateStatus)
+	assert.EqualValues(suite.T(), constant.OrderStatusPaymentSystemComplete, discrepancy.PrivateStatus)
+	assert.Equal(suite.T(), order.Transaction, discrepancy.Transaction)
+	assert.NotEmpty(suite.T(), discrepancy.ProviderResponse)
+}
+
+func (suite *OrderTestSuite) TestOrder_reconcilePaymentStatus_ProviderError_AuditWritten() {
+	order := suite.createOrderWithPayment()
+
+	ps, err := suite.service.paymentSystem.GetById(context.TODO(), order.PaymentMethod.PaymentSystemId)
+	assert.NoError(suite.T(), err)
+
+	ps.Handler = paymentSystemHandlerMockError
+	err = suite.service.paymentSystem.Update(context.TODO(), ps)
+	assert.NoError(suite.T(), err)
+
+	err = suite.service.reconcilePaymentStatus(context.TODO(), order)
+	assert.Equal(suite.T(), paymentSystemErrorPaymentStatusRequestFailed, err)
+
+	var discrepancy *billing.PaymentStatusDiscrepancy
+	err = suite.service.db.Collection(collectionPaymentStatusDiscrepancy).
+		FindOne(context.TODO(), bson.M{"order_uuid": order.Uuid}).
+		Decode(&discrepancy)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.PaymentStatusReconciliationResultError, discrepancy.Result)
+	assert.EqualValues(suite.T(), constant.OrderStatusPaymentSystemCreate, discrepancy.PreviousPrivateStatus)
+	assert.EqualValues(suite.T(), constant.OrderStatusPaymentSystemCreate, discrepancy.PrivateStatus)
+	assert.Equal(suite.T(), paymentSystemErrorPaymentStatusRequestFailed.Error(), discrepancy.Error)
+	assert.Empty(suite.T(), discrepancy.ProviderResponse)
+}
+
+func (suite *OrderTestSuite) setPaymentSystemHandler(order *billing.Order, handler string) {
+	ps, err := suite.service.paymentSystem.GetById(context.TODO(), order.PaymentMethod.PaymentSystemId)
+	assert.NoError(suite.T(), err)
+
+	ps.Handler = handler
+	err = suite.service.paymentSystem.Update(context.TODO(), ps)
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *OrderTestSuite) TestOrder_reconcilePaymentStatus_PaymentPending_NotChanged() {
+	order := suite.createOrderWithPayment()
+	suite.setPaymentSystemHandler(order, paymentSystemHandlerCardPayPendingMock)
+
+	err := suite.service.reconcilePaymentStatus(context.TODO(), order)
+	assert.NoError(suite.T(), err)
+
+	order, err = suite.service.getOrderById(context.TODO(), order.Id)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), constant.OrderStatusPaymentSystemCreate, order.PrivateStatus)
+
+	count, err := suite.service.db.Collection(collectionPaymentStatusDiscrepancy).
+		CountDocuments(context.TODO(), bson.M{"order_uuid": order.Uuid})
+	assert.NoError(suite.T(), err)
+	assert.Zero(suite.T(), count)
+}
+
+func (suite *OrderTestSuite) TestOrder_ExpireOrders_PaymentPending_NotCanceled() {
+	order := suite.createOrderWithPayment()
+	suite.setPaymentSystemHandler(order, paymentSystemHandlerCardPayPendingMock)
+
+	order.CreatedAt, _ = ptypes.TimestampProto(time.Now().Add(-suite.service.cfg.GetOrderExpiryTimeout() - time.Minute))
+	err := suite.service.updateOrder(context.TODO(), order)
+	assert.NoError(suite.T(), err)
+
+	err = suite.service.ExpireOrders(context.TODO())
+	assert.NoError(suite.T(), err)
+
+	order, err = suite.service.getOrderById(context.TODO(), order.Id)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), constant.OrderStatusPaymentSystemCreate, order.PrivateStatus)
+	assert.False(suite.T(), order.Canceled)
+}
+
+func (suite *OrderTestSuite) TestOrder_orderNotifyMerchant_Ok() {
+	req := &billing.OrderCreateRequest{
+		Type:        billing.OrderType_simple,
+		ProjectId:   suite.project.Id,
+		Currency:    "RUB",
+		Amount:      100,
+		Account:     "unit test",
+		Description: "unit test",
+		OrderId:     primitive.NewObjectID().Hex(),
+		User: &billing.OrderUser{
+			Email: "test@unit.unit",
+			Ip:    "127.0.0.1",
+		},
+	}
+
+	rsp0 := &grpc.OrderCreateProcessResponse{}
+	err := suite.service.OrderCreateProcess(context.TODO(), req, rsp0)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), rsp0.Status, pkg.ResponseStatusOk)
+	order := rsp0.Item
+
+	ps := order.GetPublicStatus()
+	assert.Equal(suite.T(), ps, constant.OrderPublicStatusCreated)
+	nS := order.GetNotificationStatus(ps)
+	assert.False(suite.T(), nS)
+	assert.False(suite.T(), order.GetNotificationStatus(constant.OrderPublicStatusProcessed))
+	assert.Equal(suite.T(), len(order.IsNotificationsSent), 0)
+
+	order.PrivateStatus = constant.OrderStatusProjectComplete
+	err = suite.service.updateOrder(context.TODO(), order)
+	assert.NoError(suite.T(), err)
+
+	ps = order.GetPublicStatus()
+	assert.Equal(suite.T(), ps, constant.OrderPublicStatusProcessed)
+	nS = order.GetNotificationStatus(ps)
+	assert.True(suite.T(), nS)
+	assert.Equal(suite.T(), len(order.IsNotificationsSent), 1)
+}
+
+func (suite *OrderTestSuite) TestCardpay_fillPaymentDataCrypto() {
+	var (
+		name    = "Bitcoin"
+		address = "1ByR2GSfDMuFGVoUzh4a5pzgrVuoTdr8wU"
+	)
+
+	req := &billing.OrderCreateRequest{
+		Type:        billing.OrderType_simple,
+		ProjectId:   suite.project.Id,
+		Currency:    "RUB",
+		Amount:      100,
+		Account:     "unit test",
+		Description: "unit test",
+		OrderId:     primitive.NewObjectID().Hex(),
+		User: &billing.OrderUser{
+			Email: "test@unit.unit",
+			Ip:    "127.0.0.1",
+		},
+	}
+
+	rsp0 := &grpc.OrderCreateProcessResponse{}
+	err := suite.service.OrderCreateProcess(context.TODO(), req, rsp0)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), rsp0.Status, pkg.ResponseStatusOk)
+	order := rsp0.Item
+
+	order.PaymentMethod = &billing.PaymentMethodOrder{
+		Name: name,
+	}
+	order.PaymentMethodTxnParams = make(map[string]string)
+	order.PaymentMethodTxnParams[pkg.PaymentCreateFieldCrypto] = address
+	order.PaymentMethodTxnParams[pkg.TxnParamsFieldCryptoTransactionId] = "7d8c131c-092c-4a5b-83ed-5137ecb9b083"
+	order.PaymentMethodTxnParams[pkg.TxnParamsFieldCryptoAmount] = "0.0001"
+	order.PaymentMethodTxnParams[pkg.TxnParamsFieldCryptoCurrency] = "BTC"
+
+	err = suite.service.fillPaymentDataCrypto(order)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), order.PaymentMethodPayerAccount, address)
+	assert.NotNil(suite.T(), order.PaymentMethod.CryptoCurrency)
+	assert.Equal(suite.T(), order.PaymentMethod.CryptoCurrency.Brand, name)
+	assert.Equal(suite.T(), order.PaymentMethod.CryptoCurrency.Address, address)
+}
+
+func (suite *OrderTestSuite) TestCardpay_fillPaymentDataEwallet() {
+	var (
+		name    = "yamoney"
+		account = "41001811131268"
+	)
+	req := &billing.OrderCreateRequest{
+		ProjectId:   suite.project.Id,
+		Currency:    "RUB",
+		Amount:      100,
+		Account:     "unit test",
+		Description: "unit test",
+		OrderId:     primitive.NewObjectID().Hex(),
+		User: &billing.OrderUser{
+			Email: "test@unit.unit",
+			Ip:    "127.0.0.1",
+		},
+		Type: billing.OrderType_simple,
+	}
+
+	rsp0 := &grpc.OrderCreateProcessResponse{}
+	err := suite.service.OrderCreateProcess(context.TODO(), req, rsp0)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), rsp0.Status, pkg.ResponseStatusOk)
+	order := rsp0.Item
+
+	order.PaymentMethod = &billing.PaymentMethodOrder{
+		Name: name,
+	}
+	order.PaymentMethodTxnParams = make(map[string]string)
+	order.PaymentMethodTxnParams[pkg.PaymentCreateFieldEWallet] = account
+
+	err = suite.service.fillPaymentDataEwallet(order)
 	assert.NoError(suite.T(), err)
 	assert.Equal(suite.T(), order.PaymentMethodPayerAccount, account)
 	assert.NotNil(suite.T(), order.PaymentMethod.Wallet)
@@ -7767,6 +8300,46 @@ func (suite *OrderTestSuite) TestOrder_ProcessPaymentFormData_KeyProductReservat
 	shouldBe.EqualValues(len(suite.keyProductIds), len(order.Keys))
 }
 
+func (suite *OrderTestSuite) TestOrder_releaseOrderReservations_Ok() {
+	shouldBe := require.New(suite.T())
+
+	req := &billing.OrderCreateRequest{
+		Type:        billing.OrderType_key,
+		ProjectId:   suite.projectWithKeyProducts.Id,
+		Currency:    "RUB",
+		Account:     "unit test",
+		Description: "unit test",
+		OrderId:     primitive.NewObjectID().Hex(),
+		User: &billing.OrderUser{
+			Email: "test@unit.unit",
+			Ip:    "127.0.0.1",
+		},
+		Products:   suite.keyProductIds,
+		PlatformId: "steam",
+	}
+
+	rsp1 := &grpc.OrderCreateProcessResponse{}
+	err := suite.service.OrderCreateProcess(context.TODO(), req, rsp1)
+	shouldBe.Nil(err)
+	shouldBe.Equal(pkg.ResponseStatusOk, rsp1.Status)
+	order := rsp1.Item
+
+	processor := &PaymentCreateProcessor{service: suite.service}
+	err = processor.reserveKeysForOrder(context.TODO(), order)
+	shouldBe.Nil(err)
+	shouldBe.NotEmpty(order.Keys)
+	keys := order.Keys
+
+	suite.service.releaseOrderReservations(context.TODO(), order)
+	shouldBe.Empty(order.Keys)
+
+	for _, id := range keys {
+		key, err := suite.service.keyRepository.GetById(context.TODO(), id)
+		shouldBe.Nil(err)
+		shouldBe.Empty(key.OrderId)
+	}
+}
+
 func (suite *OrderTestSuite) TestOrder_PaymentFormJsonDataProcess_AllPaymentMethods() {
 	req := &billing.OrderCreateRequest{
 		Type:          billing.OrderType_simple,
@@ -8716,3 +9289,346 @@ func (suite *OrderTestSuite) TestOrder_ReCreateOrder_Error() {
 	shouldBe.EqualValues(400, rsp1.Status)
 	shouldBe.NotNil(rsp1.Message)
 }
+
+func (suite *OrderTestSuite) createAuthorizedOrder() *billing.Order {
+	req := &billing.OrderCreateRequest{
+		Type:               billing.OrderType_simple,
+		ProjectId:          suite.project.Id,
+		Currency:           "RUB",
+		Amount:             100,
+		Account:            "unit test",
+		Description:        "unit test",
+		OrderId:            primitive.NewObjectID().Hex(),
+		IsPreAuthorization: true,
+		User: &billing.OrderUser{
+			Email: "test@unit.unit",
+			Ip:    "127.0.0.1",
+		},
+	}
+
+	rsp := &grpc.OrderCreateProcessResponse{}
+	err := suite.service.OrderCreateProcess(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.True(suite.T(), rsp.Item.IsPreAuthorization)
+
+	expireYear := time.Now().AddDate(1, 0, 0)
+	createPaymentRequest := &grpc.PaymentCreateRequest{
+		Data: map[string]string{
+			pkg.PaymentCreateFieldOrderId:         rsp.Item.Uuid,
+			pkg.PaymentCreateFieldPaymentMethodId: suite.paymentMethod.Id,
+			pkg.PaymentCreateFieldEmail:           "test@unit.unit",
+			pkg.PaymentCreateFieldPan:             "4000000000000002",
+			pkg.PaymentCreateFieldCvv:             "123",
+			pkg.PaymentCreateFieldMonth:           "02",
+			pkg.PaymentCreateFieldYear:            expireYear.Format("2006"),
+			pkg.PaymentCreateFieldHolder:          "Mr. Card Holder",
+		},
+		Ip: "127.0.0.1",
+	}
+
+	rsp1 := &grpc.PaymentCreateResponse{}
+	err = suite.service.PaymentCreateProcess(context.TODO(), createPaymentRequest, rsp1)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp1.Status)
+
+	order, err := suite.service.getOrderByUuid(context.TODO(), rsp.Item.Uuid)
+	assert.NoError(suite.T(), err)
+
+	order.PrivateStatus = pkg.OrderStatusPaymentSystemAuthorized
+	order.AuthorizedAmount = order.ChargeAmount
+	suite.service.setPaymentAuthorizationExpireAt(order)
+	err = suite.service.updateOrder(context.TODO(), order)
+	assert.NoError(suite.T(), err)
+
+	return order
+}
+
+func (suite *OrderTestSuite) TestOrder_CapturePayment_Ok() {
+	order := suite.createAuthorizedOrder()
+
+	req := &grpc.CapturePaymentRequest{
+		OrderId:    order.Uuid,
+		MerchantId: order.GetMerchantId(),
+	}
+	rsp := &grpc.CapturePaymentResponse{}
+	err := suite.service.CapturePayment(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Empty(suite.T(), rsp.Message)
+
+	order1, err := suite.service.getOrderByUuid(context.TODO(), order.Uuid)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.OrderStatusPaymentSystemAuthorized, order1.PrivateStatus)
+	assert.Equal(suite.T(), order.AuthorizedAmount, order1.CapturedAmount)
+	assert.NotNil(suite.T(), order1.CapturedAt)
+	assert.Nil(suite.T(), order1.VoidedAt)
+	assert.False(suite.T(), order1.CanBeCaptured())
+}
+
+func (suite *OrderTestSuite) TestOrder_CapturePayment_AmountInvalid_Error() {
+	order := suite.createAuthorizedOrder()
+
+	req := &grpc.CapturePaymentRequest{
+		OrderId:    order.Uuid,
+		MerchantId: order.GetMerchantId(),
+		Amount:     order.AuthorizedAmount + 1,
+	}
+	rsp := &grpc.CapturePaymentResponse{}
+	err := suite.service.CapturePayment(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), paymentAuthorizationErrorAmountInvalid, rsp.Message)
+}
+
+func (suite *OrderTestSuite) TestOrder_CapturePayment_MerchantNotMatch_Error() {
+	order := suite.createAuthorizedOrder()
+
+	req := &grpc.CapturePaymentRequest{
+		OrderId:    order.Uuid,
+		MerchantId: primitive.NewObjectID().Hex(),
+	}
+	rsp := &grpc.CapturePaymentResponse{}
+	err := suite.service.CapturePayment(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusNotFound, rsp.Status)
+	assert.Equal(suite.T(), paymentAuthorizationErrorOrderNotFound, rsp.Message)
+}
+
+func (suite *OrderTestSuite) TestOrder_CapturePayment_AlreadyCaptured_Error() {
+	order := suite.createAuthorizedOrder()
+
+	req := &grpc.CapturePaymentRequest{
+		OrderId:    order.Uuid,
+		MerchantId: order.GetMerchantId(),
+		Amount:     order.AuthorizedAmount / 2,
+	}
+	rsp := &grpc.CapturePaymentResponse{}
+	err := suite.service.CapturePayment(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	err = suite.service.CapturePayment(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), paymentAuthorizationErrorNotAllowed, rsp.Message)
+}
+
+func (suite *OrderTestSuite) TestOrder_CapturePayment_PartialCaptureCallback_Ok() {
+	order := suite.createAuthorizedOrder()
+	amount := tools.FormatAmount(order.AuthorizedAmount / 2)
+
+	req := &grpc.CapturePaymentRequest{
+		OrderId:    order.Uuid,
+		MerchantId: order.GetMerchantId(),
+		Amount:     amount,
+	}
+	rsp := &grpc.CapturePaymentResponse{}
+	err := suite.service.CapturePayment(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	callbackRequest := &billing.CardPayPaymentCallback{
+		PaymentMethod: suite.paymentMethod.ExternalId,
+		CallbackTime:  time.Now().Format("2006-01-02T15:04:05Z"),
+		MerchantOrder: &billing.CardPayMerchantOrder{
+			Id:          order.Id,
+			Description: order.Description,
+		},
+		CardAccount: &billing.CallbackCardPayBankCardAccount{
+			Holder:             order.PaymentRequisites[pkg.PaymentCreateFieldHolder],
+			IssuingCountryCode: "RU",
+			MaskedPan:          order.PaymentRequisites[pkg.PaymentCreateFieldPan],
+			Token:              primitive.NewObjectID().Hex(),
+		},
+		Customer: &billing.CardPayCustomer{
+			Email:  order.User.Email,
+			Ip:     order.User.Ip,
+			Id:     order.ProjectAccount,
+			Locale: "Europe/Moscow",
+		},
+		PaymentData: &billing.CallbackCardPayPaymentData{
+			Id:          primitive.NewObjectID().Hex(),
+			Amount:      amount,
+			Currency:    order.ChargeCurrency,
+			Description: order.Description,
+			Is_3D:       true,
+			Rrn:         primitive.NewObjectID().Hex(),
+			Status:      pkg.CardPayPaymentResponseStatusCompleted,
+		},
+	}
+
+	buf, err := json.Marshal(callbackRequest)
+	assert.NoError(suite.T(), err)
+
+	hash := sha512.New()
+	hash.Write([]byte(string(buf) + order.PaymentMethod.Params.SecretCallback))
+
+	callbackData := &grpc.PaymentNotifyRequest{
+		OrderId:   order.Id,
+		Request:   buf,
+		Signature: hex.EncodeToString(hash.Sum(nil)),
+	}
+
+	callbackResponse := &grpc.PaymentNotifyResponse{}
+	err = suite.service.PaymentCallbackProcess(context.TODO(), callbackData, callbackResponse)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.StatusOK, callbackResponse.Status)
+
+	order1, err := suite.service.getOrderByUuid(context.TODO(), order.Uuid)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int32(constant.OrderStatusPaymentSystemComplete), order1.PrivateStatus)
+	assert.Equal(suite.T(), amount, order1.ChargeAmount)
+	assert.Equal(suite.T(), amount, order1.CapturedAmount)
+	assert.True(suite.T(), order1.TotalPaymentAmount < order.TotalPaymentAmount)
+}
+
+func (suite *OrderTestSuite) TestOrder_VoidPayment_Ok() {
+	order := suite.createAuthorizedOrder()
+
+	req := &grpc.VoidPaymentRequest{
+		OrderId:    order.Uuid,
+		MerchantId: order.GetMerchantId(),
+	}
+	rsp := &grpc.VoidPaymentResponse{}
+	err := suite.service.VoidPayment(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	order1, err := suite.service.getOrderByUuid(context.TODO(), order.Uuid)
+	assert.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), order1.VoidedAt)
+	assert.Zero(suite.T(), order1.CapturedAmount)
+
+	rsp1 := &grpc.CapturePaymentResponse{}
+	err = suite.service.CapturePayment(context.TODO(), &grpc.CapturePaymentRequest{OrderId: order.Uuid, MerchantId: order.GetMerchantId()}, rsp1)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp1.Status)
+	assert.Equal(suite.T(), paymentAuthorizationErrorNotAllowed, rsp1.Message)
+}
+
+func (suite *OrderTestSuite) TestOrder_VoidPayment_NotAuthorized_Error() {
+	order := suite.createAuthorizedOrder()
+	order.PrivateStatus = constant.OrderStatusPaymentSystemComplete
+	err := suite.service.updateOrder(context.TODO(), order)
+	assert.NoError(suite.T(), err)
+
+	req := &grpc.VoidPaymentRequest{
+		OrderId:    order.Uuid,
+		MerchantId: order.GetMerchantId(),
+	}
+	rsp := &grpc.VoidPaymentResponse{}
+	err = suite.service.VoidPayment(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), paymentAuthorizationErrorNotAllowed, rsp.Message)
+}
+
+func (suite *OrderTestSuite) TestOrder_processKeyProductsPaymentAuthorization_FinishFailed_KeysCanceled() {
+	order := suite.createAuthorizedOrder()
+	order.ProductType = billing.OrderType_key
+
+	key := &billing.Key{
+		Id:           primitive.NewObjectID().Hex(),
+		Code:         "code-1",
+		KeyProductId: primitive.NewObjectID().Hex(),
+		PlatformId:   "steam",
+		OrderId:      order.Id,
+		ReservedTo:   ptypes.TimestampNow(),
+	}
+	err := suite.service.keyRepository.Insert(context.TODO(), key)
+	assert.NoError(suite.T(), err)
+
+	// the second key doesn't exist, so redeem of it fails after the first key is redeemed
+	order.Keys = []string{key.Id, primitive.NewObjectID().Hex()}
+	err = suite.service.updateOrder(context.TODO(), order)
+	assert.NoError(suite.T(), err)
+
+	err = suite.service.processKeyProductsPaymentAuthorization(context.TODO(), order)
+	assert.NoError(suite.T(), err)
+
+	key, err = suite.service.keyRepository.GetById(context.TODO(), key.Id)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), key.OrderId)
+	redeemedAt, err := ptypes.Timestamp(key.RedeemedAt)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), redeemedAt.IsZero())
+
+	order1, err := suite.service.getOrderByUuid(context.TODO(), order.Uuid)
+	assert.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), order1.VoidedAt)
+	assert.Empty(suite.T(), order1.Keys)
+}
+
+func (suite *OrderTestSuite) TestOrder_KeyOrderPreAuthorization_ProjectSetting() {
+	req := &billing.OrderCreateRequest{
+		Type:        billing.OrderType_key,
+		ProjectId:   suite.projectWithKeyProducts.Id,
+		Currency:    "RUB",
+		Account:     "unit test",
+		Description: "unit test",
+		OrderId:     primitive.NewObjectID().Hex(),
+		User: &billing.OrderUser{
+			Email: "test@unit.unit",
+			Ip:    "127.0.0.1",
+		},
+		Products:   suite.keyProductIds,
+		PlatformId: "steam",
+	}
+
+	rsp := &grpc.OrderCreateProcessResponse{}
+	err := suite.service.OrderCreateProcess(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.False(suite.T(), rsp.Item.IsPreAuthorization)
+
+	project, err := suite.service.project.GetById(context.TODO(), suite.projectWithKeyProducts.Id)
+	assert.NoError(suite.T(), err)
+	project.PreAuthorizeKeyOrders = true
+	err = suite.service.project.Update(context.TODO(), project)
+	assert.NoError(suite.T(), err)
+
+	req.OrderId = primitive.NewObjectID().Hex()
+	rsp = &grpc.OrderCreateProcessResponse{}
+	err = suite.service.OrderCreateProcess(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.True(suite.T(), rsp.Item.IsPreAuthorization)
+}
+
+func (suite *OrderTestSuite) TestOrder_VoidPayment_AlreadyVoided_Error() {
+	order := suite.createAuthorizedOrder()
+
+	req := &grpc.VoidPaymentRequest{OrderId: order.Uuid, MerchantId: order.GetMerchantId()}
+	rsp := &grpc.VoidPaymentResponse{}
+	err := suite.service.VoidPayment(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	rsp = &grpc.VoidPaymentResponse{}
+	err = suite.service.VoidPayment(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), paymentAuthorizationErrorNotAllowed, rsp.Message)
+}
+
+func (suite *OrderTestSuite) TestOrder_VoidExpiredPaymentAuthorizations_Ok() {
+	order := suite.createAuthorizedOrder()
+	order.AuthorizationExpireAt, _ = ptypes.TimestampProto(time.Now().Add(-time.Hour))
+	err := suite.service.updateOrder(context.TODO(), order)
+	assert.NoError(suite.T(), err)
+
+	order1 := suite.createAuthorizedOrder()
+
+	err = suite.service.VoidExpiredPaymentAuthorizations(context.TODO())
+	assert.NoError(suite.T(), err)
+
+	order2, err := suite.service.getOrderByUuid(context.TODO(), order.Uuid)
+	assert.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), order2.VoidedAt)
+
+	order3, err := suite.service.getOrderByUuid(context.TODO(), order1.Uuid)
+	assert.NoError(suite.T(), err)
+	assert.Nil(suite.T(), order3.VoidedAt)
+	assert.True(suite.T(), order3.CanBeVoided())
+}
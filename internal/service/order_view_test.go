@@ -53,7 +53,7 @@ func (suite *OrderViewTestSuite) SetupTest() {
 		suite.FailNow("Config load failed", "%v", err)
 	}
 	cfg.RoyaltyReportPeriodEndHour = 0
-	cfg.CardPayApiUrl = "https://sandbox.cardpay.com"
+	cfg.CardPay.ApiUrl = "https://sandbox.cardpay.com"
 	cfg.OrderViewUpdateBatchSize = 20
 
 	m, err := migrate.New(
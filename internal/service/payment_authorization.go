@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-recurring-repository/tools"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.uber.org/zap"
+	"time"
+)
+
+var (
+	paymentAuthorizationErrorOrderNotFound = newBillingServerErrorMsg("pa000001", "order with specified data not found")
+	paymentAuthorizationErrorNotAllowed    = newBillingServerErrorMsg("pa000002", "payment of order not authorized or authorization already captured or voided")
+	paymentAuthorizationErrorAmountInvalid = newBillingServerErrorMsg("pa000003", "capture amount can't be greater than authorized amount")
+	paymentAuthorizationErrorRiskReview    = newBillingServerErrorMsg("pa000004", "payment of order is held for risk review")
+)
+
+// CapturePayment charges the whole or partial amount of authorized payment of the order.
+// Order will be completed after notification about completed payment from payment system.
+func (s *Service) CapturePayment(
+	ctx context.Context,
+	req *grpc.CapturePaymentRequest,
+	rsp *grpc.CapturePaymentResponse,
+) error {
+	order, err := s.getMerchantOrder(ctx, req.OrderId, req.MerchantId)
+
+	if err != nil {
+		rsp.Status = err.(*grpc.ResponseError).Status
+		rsp.Message = err.(*grpc.ResponseError).Message
+
+		return nil
+	}
+
+	if !order.CanBeCaptured() {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = paymentAuthorizationErrorNotAllowed
+
+		return nil
+	}
+
+	if order.IsRiskReviewPending() || (order.Risk != nil && order.Risk.ReviewStatus == pkg.RiskReviewStatusRejected) {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = paymentAuthorizationErrorRiskReview
+
+		return nil
+	}
+
+	amount := order.AuthorizedAmount
+
+	if req.Amount > 0 {
+		amount = tools.FormatAmount(req.Amount)
+	}
+
+	if amount > order.AuthorizedAmount {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = paymentAuthorizationErrorAmountInvalid
+
+		return nil
+	}
+
+	err = s.capturePayment(ctx, order, amount)
+
+	if err != nil {
+		zap.L().Error(pkg.MethodFinishedWithError, zap.Error(err), zap.String("order_uuid", order.Uuid))
+
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = orderErrorUnknown
+
+		if e, ok := err.(*grpc.ResponseErrorMessage); ok {
+			rsp.Status = pkg.ResponseStatusBadData
+			rsp.Message = e
+		}
+
+		return nil
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = order
+
+	return nil
+}
+
+// VoidPayment releases authorized payment of the order without charging.
+// Order will be canceled after notification about voided payment from payment system.
+func (s *Service) VoidPayment(
+	ctx context.Context,
+	req *grpc.VoidPaymentRequest,
+	rsp *grpc.VoidPaymentResponse,
+) error {
+	order, err := s.getMerchantOrder(ctx, req.OrderId, req.MerchantId)
+
+	if err != nil {
+		rsp.Status = err.(*grpc.ResponseError).Status
+		rsp.Message = err.(*grpc.ResponseError).Message
+
+		return nil
+	}
+
+	if !order.CanBeVoided() {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = paymentAuthorizationErrorNotAllowed
+
+		return nil
+	}
+
+	err = s.voidPayment(ctx, order)
+
+	if err != nil {
+		zap.L().Error(pkg.MethodFinishedWithError, zap.Error(err), zap.String("order_uuid", order.Uuid))
+
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = orderErrorUnknown
+
+		if e, ok := err.(*grpc.ResponseErrorMessage); ok {
+			rsp.Status = pkg.ResponseStatusBadData
+			rsp.Message = e
+		}
+
+		return nil
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = order
+
+	return nil
+}
+
+// VoidExpiredPaymentAuthorizations voids all payment authorizations which weren't captured before expiration.
+func (s *Service) VoidExpiredPaymentAuthorizations(ctx context.Context) error {
+	query := bson.M{
+		"private_status":          pkg.OrderStatusPaymentSystemAuthorized,
+		"authorization_expire_at": bson.M{"$lte": time.Now()},
+		"captured_amount":         0,
+		"voided_at":               time.Time{},
+	}
+
+	cursor, err := s.db.Collection(collectionOrder).Find(ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionOrder),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return err
+	}
+
+	var orders []*billing.Order
+	err = cursor.All(ctx, &orders)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionOrder),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return err
+	}
+
+	for _, order := range orders {
+		err = s.voidPayment(ctx, order)
+
+		if err != nil {
+			zap.L().Error(
+				"void of expired payment authorization failed",
+				zap.Error(err),
+				zap.String("order_uuid", order.Uuid),
+			)
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) getMerchantOrder(ctx context.Context, uuid, merchantId string) (*billing.Order, error) {
+	order, err := s.getOrderByUuid(ctx, uuid)
+
+	if err != nil || order.GetMerchantId() != merchantId {
+		return nil, newBillingServerResponseError(pkg.ResponseStatusNotFound, paymentAuthorizationErrorOrderNotFound)
+	}
+
+	return order, nil
+}
+
+func (s *Service) capturePayment(ctx context.Context, order *billing.Order, amount float64) error {
+	h, err := s.NewPaymentSystem(ctx, order)
+
+	if err != nil {
+		return err
+	}
+
+	err = h.CapturePayment(order, amount)
+
+	if err != nil {
+		return err
+	}
+
+	return s.updateOrder(ctx, order)
+}
+
+func (s *Service) voidPayment(ctx context.Context, order *billing.Order) error {
+	h, err := s.NewPaymentSystem(ctx, order)
+
+	if err != nil {
+		return err
+	}
+
+	err = h.VoidPayment(order)
+
+	if err != nil {
+		return err
+	}
+
+	return s.updateOrder(ctx, order)
+}
+
+func (s *Service) setPaymentAuthorizationExpireAt(order *billing.Order) {
+	if order.AuthorizationExpireAt != nil {
+		return
+	}
+
+	order.AuthorizationExpireAt, _ = ptypes.TimestampProto(time.Now().Add(s.cfg.GetPaymentAuthorizationLifetime()))
+}
+
+// Payment of key products order is charged only after all reserved keys of order were redeemed,
+// otherwise payment authorization of order will be voided and already redeemed keys will be returned to stock.
+func (s *Service) processKeyProductsPaymentAuthorization(ctx context.Context, order *billing.Order) error {
+	var redeemed []string
+
+	for _, key := range order.Keys {
+		// pre-ordered keys will be redeemed after upload of new keys
+		if key == "" {
+			continue
+		}
+
+		rsp := &grpc.GetKeyForOrderRequestResponse{}
+		err := s.FinishRedeemKeyForOrder(ctx, &grpc.KeyForOrderRequest{KeyId: key}, rsp)
+
+		if err != nil || rsp.Status != pkg.ResponseStatusOk {
+			zap.L().Error(
+				"could not finish reservation for key, payment authorization will be voided",
+				zap.Error(err),
+				zap.String("order_uuid", order.Uuid),
+				zap.String("key", key),
+				zap.Any("message", rsp.Message),
+			)
+
+			s.cancelRedeemedOrderKeys(ctx, order, redeemed)
+			return s.voidPayment(ctx, order)
+		}
+
+		redeemed = append(redeemed, key)
+	}
+
+	err := s.capturePayment(ctx, order, order.AuthorizedAmount)
+
+	if err != nil {
+		zap.L().Error(
+			"capture of key products payment failed, payment authorization will be voided",
+			zap.Error(err),
+			zap.String("order_uuid", order.Uuid),
+		)
+
+		s.cancelRedeemedOrderKeys(ctx, order, redeemed)
+
+		if vErr := s.voidPayment(ctx, order); vErr != nil {
+			zap.L().Error(
+				"void of key products payment failed",
+				zap.Error(vErr),
+				zap.String("order_uuid", order.Uuid),
+			)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// cancelRedeemedOrderKeys returns redeemed keys of not charged order to stock, reservations of other keys
+// of order are released too
+func (s *Service) cancelRedeemedOrderKeys(ctx context.Context, order *billing.Order, redeemed []string) {
+	if len(redeemed) > 0 {
+		zap.L().Warn(
+			"redeemed keys of not charged order will be returned to stock",
+			zap.String("order_uuid", order.Uuid),
+			zap.Strings("keys", redeemed),
+		)
+	}
+
+	s.releaseOrderReservations(ctx, order)
+}
@@ -593,14 +593,19 @@ func (h *PaymentMethod) GetPaymentSettings(
 		return nil, orderErrorPaymentMethodEmptySettings
 	}
 
-	setting.Currency = currency
+	provider, err := h.svc.getPaymentSystemProvider(context.TODO(), paymentMethod.PaymentSystemId)
 
-	if project.IsProduction() == true {
-		setting.ApiUrl = h.svc.cfg.CardPayApiUrl
-	} else {
-		setting.ApiUrl = h.svc.cfg.CardPayApiSandboxUrl
+	if err != nil {
+		if _, ok := err.(*grpc.ResponseErrorMessage); !ok {
+			err = orderErrorPaymentSystemInactive
+		}
+
+		return nil, err
 	}
 
+	setting.Currency = currency
+	setting.ApiUrl = provider.GetApiUrl(project.IsProduction())
+
 	return setting, nil
 }
 
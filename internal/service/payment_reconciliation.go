@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/constant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	collectionPaymentStatusDiscrepancy = "payment_status_discrepancies"
+)
+
+// ReconcilePaymentStatuses requests payment status from payment system for orders which are stuck in
+// payment system create status longer than reconciliation threshold from configuration. Payment status is
+// processed the same way as payment notification. Each changed order status, payment which wasn't found
+// in payment system and failed payment status request are written to audit collection.
+func (s *Service) ReconcilePaymentStatuses(ctx context.Context) error {
+	query := bson.M{
+		"private_status": constant.OrderStatusPaymentSystemCreate,
+		"created_at":     bson.M{"$lte": time.Now().Add(-s.cfg.GetPaymentStatusReconciliationThreshold())},
+	}
+	cursor, err := s.db.Collection(collectionOrder).Find(ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionOrder),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return err
+	}
+
+	var orders []*billing.Order
+	err = cursor.All(ctx, &orders)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionOrder),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return err
+	}
+
+	for _, order := range orders {
+		if err = s.reconcilePaymentStatus(ctx, order); err != nil {
+			zap.L().Error(
+				"payment status reconciliation of order failed",
+				zap.Error(err),
+				zap.String("order_uuid", order.Uuid),
+			)
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) reconcilePaymentStatus(ctx context.Context, order *billing.Order) error {
+	h, err := s.NewPaymentSystem(ctx, order)
+
+	if err != nil {
+		return err
+	}
+
+	data, err := h.GetPaymentStatus(order)
+
+	if err != nil {
+		result := pkg.PaymentStatusReconciliationResultError
+
+		if err == paymentSystemErrorPaymentNotFound {
+			result = pkg.PaymentStatusReconciliationResultNotFound
+		}
+
+		if err1 := s.insertPaymentStatusDiscrepancy(ctx, order, order.PrivateStatus, result, nil, err.Error()); err1 != nil {
+			return err1
+		}
+
+		return err
+	}
+
+	previousStatus := order.PrivateStatus
+	pErr := h.ProcessPayment(order, data, "", "")
+	rsp := &grpc.PaymentNotifyResponse{}
+	err = s.processPaymentNotification(ctx, order, h, data, pErr, rsp)
+
+	if err != nil {
+		return err
+	}
+
+	if rsp.Status == pkg.StatusTemporary || order.PrivateStatus == previousStatus {
+		return nil
+	}
+
+	return s.insertPaymentStatusDiscrepancy(
+		ctx,
+		order,
+		previousStatus,
+		pkg.PaymentStatusReconciliationResultChanged,
+		data,
+		rsp.Error,
+	)
+}
+
+func (s *Service) insertPaymentStatusDiscrepancy(
+	ctx context.Context,
+	order *billing.Order,
+	previousStatus int32,
+	result string,
+	data proto.Message,
+	errMsg string,
+) error {
+	discrepancy := &billing.PaymentStatusDiscrepancy{
+		Id:                    primitive.NewObjectID().Hex(),
+		OrderId:               order.Id,
+		OrderUuid:             order.Uuid,
+		PaymentSystemId:       order.PaymentMethod.PaymentSystemId,
+		Transaction:           order.Transaction,
+		Result:                result,
+		PreviousPrivateStatus: previousStatus,
+		PrivateStatus:         order.PrivateStatus,
+		Error:                 errMsg,
+		CreatedAt:             ptypes.TimestampNow(),
+	}
+
+	if data != nil {
+		b, err := json.Marshal(data)
+
+		if err != nil {
+			return err
+		}
+
+		discrepancy.ProviderResponse = string(b)
+	}
+
+	_, err := s.db.Collection(collectionPaymentStatusDiscrepancy).InsertOne(ctx, discrepancy)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionPaymentStatusDiscrepancy),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationInsert),
+			zap.Any(pkg.ErrorDatabaseFieldDocument, discrepancy),
+		)
+		return err
+	}
+
+	return nil
+}
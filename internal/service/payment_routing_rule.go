@@ -0,0 +1,550 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"math/rand"
+)
+
+const (
+	collectionPaymentRoutingRules = "payment_routing_rules"
+
+	cacheKeyPaymentRoutingRulesByPaymentMethod = "payment_routing_rule:payment_method_id:%s"
+)
+
+var (
+	errorPaymentRoutingRuleNotFound              = newBillingServerErrorMsg("prr00001", "payment routing rule not found")
+	errorPaymentRoutingRuleAlreadyExists         = newBillingServerErrorMsg("prr00002", "payment routing rule for specified payment method, mcc code and country already exists")
+	errorPaymentRoutingRulePaymentMethodNotFound = newBillingServerErrorMsg("prr00003", "payment method of payment routing rule not found")
+	errorPaymentRoutingRulePaymentMethodChanged  = newBillingServerErrorMsg("prr00004", "payment method of existing payment routing rule can't be changed")
+	errorPaymentRoutingRuleMccCodeUnknown        = newBillingServerErrorMsg("prr00005", "payment routing rule mcc code unknown")
+	errorPaymentRoutingRuleCountryUnknown        = newBillingServerErrorMsg("prr00006", "payment routing rule country unknown")
+	errorPaymentRoutingRuleRoutesEmpty           = newBillingServerErrorMsg("prr00007", "payment routing rule must contain at least one route")
+	errorPaymentRoutingRuleRouteDuplicated       = newBillingServerErrorMsg("prr00008", "payment system can't be used in payment routing rule more than once")
+	errorPaymentRoutingRulePaymentSystemNotFound = newBillingServerErrorMsg("prr00009", "payment system of payment routing rule not found or inactive")
+)
+
+type PaymentRoutingRuleServiceInterface interface {
+	GetById(ctx context.Context, id string) (*billing.PaymentRoutingRule, error)
+	GetAll(ctx context.Context) ([]*billing.PaymentRoutingRule, error)
+	GetByPaymentMethod(ctx context.Context, paymentMethodId string) ([]*billing.PaymentRoutingRule, error)
+	Upsert(ctx context.Context, rule *billing.PaymentRoutingRule) error
+	Delete(ctx context.Context, rule *billing.PaymentRoutingRule) error
+}
+
+func newPaymentRoutingRuleService(svc *Service) PaymentRoutingRuleServiceInterface {
+	s := &PaymentRoutingRule{svc: svc}
+	return s
+}
+
+func (s *Service) GetPaymentRoutingRules(
+	ctx context.Context,
+	req *grpc.GetPaymentRoutingRulesRequest,
+	rsp *grpc.GetPaymentRoutingRulesResponse,
+) (err error) {
+	if req.PaymentMethodId != "" {
+		rsp.Items, err = s.paymentRoutingRule.GetByPaymentMethod(ctx, req.PaymentMethodId)
+	} else {
+		rsp.Items, err = s.paymentRoutingRule.GetAll(ctx)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	return nil
+}
+
+func (s *Service) SetPaymentRoutingRule(
+	ctx context.Context,
+	req *billing.PaymentRoutingRule,
+	rsp *grpc.SetPaymentRoutingRuleResponse,
+) (err error) {
+	rule := &billing.PaymentRoutingRule{
+		Id:              primitive.NewObjectID().Hex(),
+		PaymentMethodId: req.PaymentMethodId,
+		CreatedAt:       ptypes.TimestampNow(),
+	}
+
+	if req.Id != "" {
+		rule, err = s.paymentRoutingRule.GetById(ctx, req.Id)
+
+		if err != nil {
+			rsp.Status = pkg.ResponseStatusNotFound
+			rsp.Message = errorPaymentRoutingRuleNotFound
+			return nil
+		}
+
+		if rule.PaymentMethodId != req.PaymentMethodId {
+			rsp.Status = pkg.ResponseStatusBadData
+			rsp.Message = errorPaymentRoutingRulePaymentMethodChanged
+			return nil
+		}
+	}
+
+	if err = s.validatePaymentRoutingRule(ctx, rule.Id, req); err != nil {
+		if e, ok := err.(*grpc.ResponseErrorMessage); ok {
+			rsp.Status = pkg.ResponseStatusBadData
+			rsp.Message = e
+			return nil
+		}
+		return err
+	}
+
+	rule.MccCode = req.MccCode
+	rule.Country = req.Country
+	rule.Routes = req.Routes
+	rule.UpdatedAt = ptypes.TimestampNow()
+
+	if err = s.paymentRoutingRule.Upsert(ctx, rule); err != nil {
+		return err
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = rule
+
+	return nil
+}
+
+func (s *Service) DeletePaymentRoutingRule(
+	ctx context.Context,
+	req *grpc.DeletePaymentRoutingRuleRequest,
+	rsp *grpc.EmptyResponseWithStatus,
+) error {
+	rule, err := s.paymentRoutingRule.GetById(ctx, req.Id)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusNotFound
+		rsp.Message = errorPaymentRoutingRuleNotFound
+		return nil
+	}
+
+	if err = s.paymentRoutingRule.Delete(ctx, rule); err != nil {
+		return err
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	return nil
+}
+
+func (s *Service) validatePaymentRoutingRule(ctx context.Context, id string, req *billing.PaymentRoutingRule) error {
+	if _, err := s.paymentMethod.GetById(ctx, req.PaymentMethodId); err != nil {
+		return errorPaymentRoutingRulePaymentMethodNotFound
+	}
+
+	if req.MccCode != "" && !contains(pkg.SupportedMccCodes, req.MccCode) {
+		return errorPaymentRoutingRuleMccCodeUnknown
+	}
+
+	if req.Country != "" {
+		if _, err := s.country.GetByIsoCodeA2(ctx, req.Country); err != nil {
+			return errorPaymentRoutingRuleCountryUnknown
+		}
+	}
+
+	if len(req.Routes) <= 0 {
+		return errorPaymentRoutingRuleRoutesEmpty
+	}
+
+	paymentSystems := make(map[string]bool)
+
+	for _, route := range req.Routes {
+		if _, ok := paymentSystems[route.PaymentSystemId]; ok {
+			return errorPaymentRoutingRuleRouteDuplicated
+		}
+
+		if _, err := s.paymentSystem.GetById(ctx, route.PaymentSystemId); err != nil {
+			return errorPaymentRoutingRulePaymentSystemNotFound
+		}
+
+		paymentSystems[route.PaymentSystemId] = true
+	}
+
+	rules, err := s.paymentRoutingRule.GetByPaymentMethod(ctx, req.PaymentMethodId)
+
+	if err != nil {
+		return err
+	}
+
+	for _, v := range rules {
+		if v.Id != id && v.MccCode == req.MccCode && v.Country == req.Country {
+			return errorPaymentRoutingRuleAlreadyExists
+		}
+	}
+
+	return nil
+}
+
+// getPaymentRoutes returns active payment systems which can process payments by the payment method
+// in the order they must be tried. Routing rule is searched from the most specific to the most common one,
+// if the payment method has no routing rules then the payment system of the payment method is the only route.
+func (s *Service) getPaymentRoutes(
+	ctx context.Context,
+	pm *billing.PaymentMethod,
+	mccCode, country string,
+) ([]*billing.PaymentSystem, error) {
+	routes := []*billing.PaymentRoute{{PaymentSystemId: pm.PaymentSystemId}}
+	rule, err := s.findPaymentRoutingRule(ctx, pm.Id, mccCode, country)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if rule != nil {
+		routes = sortPaymentRoutes(rule.Routes)
+	}
+
+	var result []*billing.PaymentSystem
+
+	for _, route := range routes {
+		ps, err := s.paymentSystem.GetById(ctx, route.PaymentSystemId)
+
+		if err != nil {
+			continue
+		}
+
+		result = append(result, ps)
+	}
+
+	if len(result) <= 0 {
+		return nil, orderErrorPaymentSystemInactive
+	}
+
+	return result, nil
+}
+
+func (s *Service) findPaymentRoutingRule(
+	ctx context.Context,
+	paymentMethodId, mccCode, country string,
+) (*billing.PaymentRoutingRule, error) {
+	rules, err := s.paymentRoutingRule.GetByPaymentMethod(ctx, paymentMethodId)
+
+	if err != nil || len(rules) <= 0 {
+		return nil, err
+	}
+
+	criteria := [][2]string{{mccCode, country}, {mccCode, ""}, {"", country}, {"", ""}}
+
+	for _, v := range criteria {
+		for _, rule := range rules {
+			if rule.MccCode == v[0] && rule.Country == v[1] {
+				return rule, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// sortPaymentRoutes moves the route chosen randomly in proportion to the routes weights to the head of list.
+// Other routes keep their configured order and are used for failover only.
+func sortPaymentRoutes(routes []*billing.PaymentRoute) []*billing.PaymentRoute {
+	var total int32
+
+	for _, route := range routes {
+		if route.Weight > 0 {
+			total += route.Weight
+		}
+	}
+
+	if total <= 0 {
+		return routes
+	}
+
+	n := rand.Int31n(total)
+	index := 0
+
+	for i, route := range routes {
+		if route.Weight <= 0 {
+			continue
+		}
+
+		if n < route.Weight {
+			index = i
+			break
+		}
+
+		n -= route.Weight
+	}
+
+	result := make([]*billing.PaymentRoute, 0, len(routes))
+	result = append(result, routes[index])
+	result = append(result, routes[:index]...)
+	result = append(result, routes[index+1:]...)
+
+	return result
+}
+
+// createPaymentWithFailover creates payment of the order in the first available payment system of routes.
+// Next payment system is tried only if create payment request to previous one failed, all tried routes
+// are recorded to the order.
+func (s *Service) createPaymentWithFailover(
+	ctx context.Context,
+	order *billing.Order,
+	pm *billing.PaymentMethod,
+	routes []*billing.PaymentSystem,
+	project *billing.Project,
+	methodName string,
+	requisites map[string]string,
+) (string, error) {
+	var err error
+
+	for _, ps := range routes {
+		route := &billing.OrderPaymentRoute{
+			PaymentSystemId: ps.Id,
+			Handler:         ps.Handler,
+			Status:          pkg.OrderPaymentRouteStatusFailed,
+		}
+		order.PaymentRoutes = append(order.PaymentRoutes, route)
+
+		var provider *PaymentSystemProvider
+		provider, err = s.paymentSystemProviders.Get(ps.Handler)
+
+		if err != nil {
+			route.ErrorCode = paymentSystemErrorHandlerNotFound.Code
+			continue
+		}
+
+		// terminal settings of each payment system are loaded from its own payment method,
+		// so credentials of one payment system are never sent to another one
+		var routePm *billing.PaymentMethod
+		var params *billing.PaymentMethodParams
+		routePm, err = s.getRoutePaymentMethod(ctx, pm, ps)
+
+		if err == nil {
+			params, err = s.paymentMethod.GetPaymentSettings(
+				routePm,
+				order.ChargeCurrency,
+				order.MccCode,
+				order.OperatingCompanyId,
+				methodName,
+				project,
+			)
+		}
+
+		if err != nil {
+			if e, ok := err.(*grpc.ResponseErrorMessage); ok {
+				route.ErrorCode = e.Code
+			}
+			continue
+		}
+
+		order.PaymentMethod.Id = routePm.Id
+		order.PaymentMethod.PaymentSystemId = ps.Id
+		order.PaymentMethod.Handler = ps.Handler
+		order.PaymentMethod.Params = params
+
+		var url string
+		url, err = provider.New().CreatePayment(
+			order,
+			s.cfg.GetRedirectUrlSuccess(nil),
+			s.cfg.GetRedirectUrlFail(nil),
+			requisites,
+		)
+
+		if err == nil {
+			route.Status = pkg.OrderPaymentRouteStatusOk
+			return url, nil
+		}
+
+		if e, ok := err.(*grpc.ResponseErrorMessage); ok {
+			route.ErrorCode = e.Code
+		}
+
+		zap.L().Error(
+			"create payment in payment system failed",
+			zap.Error(err),
+			zap.String("order_uuid", order.Uuid),
+			zap.String("payment_system_id", ps.Id),
+			zap.String("handler", ps.Handler),
+		)
+
+		if err != paymentSystemErrorCreateRequestFailed {
+			return "", err
+		}
+	}
+
+	return "", err
+}
+
+func (h *PaymentRoutingRule) GetById(ctx context.Context, id string) (*billing.PaymentRoutingRule, error) {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	filter := bson.M{"_id": oid}
+
+	rule := &billing.PaymentRoutingRule{}
+	err := h.svc.db.Collection(collectionPaymentRoutingRules).FindOne(ctx, filter).Decode(rule)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errorPaymentRoutingRuleNotFound
+		}
+
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionPaymentRoutingRules),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, filter),
+		)
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+func (h *PaymentRoutingRule) GetAll(ctx context.Context) ([]*billing.PaymentRoutingRule, error) {
+	return h.find(ctx, bson.M{})
+}
+
+func (h *PaymentRoutingRule) GetByPaymentMethod(
+	ctx context.Context,
+	paymentMethodId string,
+) ([]*billing.PaymentRoutingRule, error) {
+	var result []*billing.PaymentRoutingRule
+	key := fmt.Sprintf(cacheKeyPaymentRoutingRulesByPaymentMethod, paymentMethodId)
+
+	if err := h.svc.cacher.Get(key, &result); err == nil {
+		return result, nil
+	}
+
+	oid, _ := primitive.ObjectIDFromHex(paymentMethodId)
+	result, err := h.find(ctx, bson.M{"payment_method_id": oid})
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = h.svc.cacher.Set(key, result, 0)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorCacheQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorCacheFieldCmd, "SET"),
+			zap.String(pkg.ErrorCacheFieldKey, key),
+			zap.Any(pkg.ErrorCacheFieldData, result),
+		)
+	}
+
+	return result, nil
+}
+
+func (h *PaymentRoutingRule) Upsert(ctx context.Context, rule *billing.PaymentRoutingRule) error {
+	oid, _ := primitive.ObjectIDFromHex(rule.Id)
+	filter := bson.M{"_id": oid}
+	opts := options.Replace().SetUpsert(true)
+	_, err := h.svc.db.Collection(collectionPaymentRoutingRules).ReplaceOne(ctx, filter, rule, opts)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionPaymentRoutingRules),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationUpsert),
+			zap.Any(pkg.ErrorDatabaseFieldDocument, rule),
+		)
+		return err
+	}
+
+	h.deleteCache(rule.PaymentMethodId)
+
+	return nil
+}
+
+func (h *PaymentRoutingRule) Delete(ctx context.Context, rule *billing.PaymentRoutingRule) error {
+	oid, _ := primitive.ObjectIDFromHex(rule.Id)
+	filter := bson.M{"_id": oid}
+	_, err := h.svc.db.Collection(collectionPaymentRoutingRules).DeleteOne(ctx, filter)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionPaymentRoutingRules),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationDelete),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, filter),
+		)
+		return err
+	}
+
+	h.deleteCache(rule.PaymentMethodId)
+
+	return nil
+}
+
+func (h *PaymentRoutingRule) find(ctx context.Context, query bson.M) ([]*billing.PaymentRoutingRule, error) {
+	cursor, err := h.svc.db.Collection(collectionPaymentRoutingRules).Find(ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionPaymentRoutingRules),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	var result []*billing.PaymentRoutingRule
+	err = cursor.All(ctx, &result)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionPaymentRoutingRules),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (h *PaymentRoutingRule) deleteCache(paymentMethodId string) {
+	key := fmt.Sprintf(cacheKeyPaymentRoutingRulesByPaymentMethod, paymentMethodId)
+
+	if err := h.svc.cacher.Delete(key); err != nil {
+		zap.L().Error(
+			pkg.ErrorCacheQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorCacheFieldCmd, "DELETE"),
+			zap.String(pkg.ErrorCacheFieldKey, key),
+		)
+	}
+}
+
+// getRoutePaymentMethod returns payment method of the same type which processes payments in the payment system of route.
+// Payment method selected by customer processes payments in its own payment system only.
+func (s *Service) getRoutePaymentMethod(
+	ctx context.Context,
+	pm *billing.PaymentMethod,
+	ps *billing.PaymentSystem,
+) (*billing.PaymentMethod, error) {
+	if pm.PaymentSystemId == ps.Id {
+		return pm, nil
+	}
+
+	methods, err := s.paymentMethod.GetAll(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range methods {
+		if v.IsActive && v.ExternalId == pm.ExternalId && v.PaymentSystemId == ps.Id {
+			return v, nil
+		}
+	}
+
+	return nil, orderErrorPaymentMethodNotFound
+}
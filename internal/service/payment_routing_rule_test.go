@@ -0,0 +1,497 @@
+package service
+
+import (
+	"context"
+	casbinMocks "github.com/paysuper/casbin-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/internal/config"
+	"github.com/paysuper/paysuper-billing-server/internal/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	reportingMocks "github.com/paysuper/paysuper-reporter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	mongodb "gopkg.in/paysuper/paysuper-database-mongo.v1"
+	"testing"
+)
+
+type PaymentRoutingRuleTestSuite struct {
+	suite.Suite
+	service *Service
+	cache   CacheInterface
+
+	paymentMethod  *billing.PaymentMethod
+	paymentMethod2 *billing.PaymentMethod
+	project        *billing.Project
+	paymentSystem1 *billing.PaymentSystem
+	paymentSystem2 *billing.PaymentSystem
+	paymentSystem3 *billing.PaymentSystem
+}
+
+func Test_PaymentRoutingRule(t *testing.T) {
+	suite.Run(t, new(PaymentRoutingRuleTestSuite))
+}
+
+func (suite *PaymentRoutingRuleTestSuite) SetupTest() {
+	cfg, err := config.NewConfig()
+	if err != nil {
+		suite.FailNow("Config load failed", "%v", err)
+	}
+
+	db, err := mongodb.NewDatabase()
+	if err != nil {
+		suite.FailNow("Database connection failed", "%v", err)
+	}
+
+	redisdb := mocks.NewTestRedis()
+	suite.cache, err = NewCacheRedis(redisdb, "cache")
+	suite.service = NewBillingService(
+		db,
+		cfg,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		suite.cache,
+		mocks.NewCurrencyServiceMockOk(),
+		mocks.NewDocumentSignerMockOk(),
+		&reportingMocks.ReporterService{},
+		mocks.NewFormatterOK(),
+		mocks.NewBrokerMockOk(),
+		&casbinMocks.CasbinService{},
+	)
+
+	if err := suite.service.Init(); err != nil {
+		suite.FailNow("Billing service initialization failed", "%v", err)
+	}
+
+	country := &billing.Country{
+		Id:                primitive.NewObjectID().Hex(),
+		IsoCodeA2:         "RU",
+		Region:            "Russia",
+		Currency:          "RUB",
+		PaymentsAllowed:   true,
+		ChangeAllowed:     true,
+		VatEnabled:        true,
+		VatCurrency:       "RUB",
+		PayerTariffRegion: pkg.TariffRegionRussiaAndCis,
+	}
+	err = suite.service.country.Insert(context.TODO(), country)
+
+	if err != nil {
+		suite.FailNow("Insert country test data failed", "%v", err)
+	}
+
+	suite.paymentSystem1 = &billing.PaymentSystem{
+		Id:                 primitive.NewObjectID().Hex(),
+		Name:               "Primary acquirer",
+		AccountingCurrency: "RUB",
+		AccountingPeriod:   "every-day",
+		IsActive:           true,
+		Handler:            "primary_acquirer",
+	}
+	suite.paymentSystem2 = &billing.PaymentSystem{
+		Id:                 primitive.NewObjectID().Hex(),
+		Name:               "Secondary acquirer",
+		AccountingCurrency: "RUB",
+		AccountingPeriod:   "every-day",
+		IsActive:           true,
+		Handler:            "secondary_acquirer",
+	}
+	suite.paymentSystem3 = &billing.PaymentSystem{
+		Id:                 primitive.NewObjectID().Hex(),
+		Name:               "Inactive acquirer",
+		AccountingCurrency: "RUB",
+		AccountingPeriod:   "every-day",
+		IsActive:           false,
+		Handler:            paymentSystemHandlerMockOk,
+	}
+	err = suite.service.paymentSystem.MultipleInsert(
+		context.TODO(),
+		[]*billing.PaymentSystem{suite.paymentSystem1, suite.paymentSystem2, suite.paymentSystem3},
+	)
+
+	if err != nil {
+		suite.FailNow("Insert payment system test data failed", "%v", err)
+	}
+
+	suite.paymentMethod = &billing.PaymentMethod{
+		Id:              primitive.NewObjectID().Hex(),
+		Name:            "Bank card",
+		Group:           "BANKCARD",
+		ExternalId:      "BANKCARD",
+		Type:            "bank_card",
+		IsActive:        true,
+		PaymentSystemId: suite.paymentSystem1.Id,
+		TestSettings: map[string]*billing.PaymentMethodParams{
+			pkg.GetPaymentMethodKey("RUB", pkg.MccCodeLowRisk, "", "VISA"): {
+				Currency:       "RUB",
+				TerminalId:     "primary_terminal",
+				Secret:         "primary_secret",
+				SecretCallback: "primary_secret_callback",
+			},
+		},
+	}
+	err = suite.service.paymentMethod.Insert(context.TODO(), suite.paymentMethod)
+
+	if err != nil {
+		suite.FailNow("Insert payment method test data failed", "%v", err)
+	}
+
+	suite.paymentMethod2 = &billing.PaymentMethod{
+		Id:              primitive.NewObjectID().Hex(),
+		Name:            "Bank card",
+		Group:           "BANKCARD",
+		ExternalId:      "BANKCARD",
+		Type:            "bank_card",
+		IsActive:        true,
+		PaymentSystemId: suite.paymentSystem2.Id,
+		TestSettings: map[string]*billing.PaymentMethodParams{
+			pkg.GetPaymentMethodKey("RUB", pkg.MccCodeLowRisk, "", "VISA"): {
+				Currency:       "RUB",
+				TerminalId:     "secondary_terminal",
+				Secret:         "secondary_secret",
+				SecretCallback: "secondary_secret_callback",
+			},
+		},
+	}
+	err = suite.service.paymentMethod.Insert(context.TODO(), suite.paymentMethod2)
+
+	if err != nil {
+		suite.FailNow("Insert payment method test data failed", "%v", err)
+	}
+
+	suite.project = &billing.Project{Id: primitive.NewObjectID().Hex(), Status: pkg.ProjectStatusDraft}
+}
+
+func (suite *PaymentRoutingRuleTestSuite) TearDownTest() {
+	err := suite.service.db.Drop()
+
+	if err != nil {
+		suite.FailNow("Database deletion failed", "%v", err)
+	}
+
+	err = suite.service.db.Close()
+
+	if err != nil {
+		suite.FailNow("Database close failed", "%v", err)
+	}
+}
+
+func (suite *PaymentRoutingRuleTestSuite) setRule(mccCode, country string, routes ...*billing.PaymentRoute) *billing.PaymentRoutingRule {
+	req := &billing.PaymentRoutingRule{
+		PaymentMethodId: suite.paymentMethod.Id,
+		MccCode:         mccCode,
+		Country:         country,
+		Routes:          routes,
+	}
+	rsp := &grpc.SetPaymentRoutingRuleResponse{}
+	err := suite.service.SetPaymentRoutingRule(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	return rsp.Item
+}
+
+func (suite *PaymentRoutingRuleTestSuite) TestPaymentRoutingRule_SetPaymentRoutingRule_Ok() {
+	rule := suite.setRule(
+		pkg.MccCodeLowRisk,
+		"RU",
+		&billing.PaymentRoute{PaymentSystemId: suite.paymentSystem1.Id, Weight: 80},
+		&billing.PaymentRoute{PaymentSystemId: suite.paymentSystem2.Id, Weight: 20},
+	)
+	assert.NotEmpty(suite.T(), rule.Id)
+	assert.NotNil(suite.T(), rule.CreatedAt)
+
+	rsp := &grpc.GetPaymentRoutingRulesResponse{}
+	err := suite.service.GetPaymentRoutingRules(
+		context.TODO(),
+		&grpc.GetPaymentRoutingRulesRequest{PaymentMethodId: suite.paymentMethod.Id},
+		rsp,
+	)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Len(suite.T(), rsp.Items, 1)
+	assert.Equal(suite.T(), rule.Id, rsp.Items[0].Id)
+	assert.Equal(suite.T(), pkg.MccCodeLowRisk, rsp.Items[0].MccCode)
+	assert.Equal(suite.T(), "RU", rsp.Items[0].Country)
+	assert.Len(suite.T(), rsp.Items[0].Routes, 2)
+	assert.Equal(suite.T(), suite.paymentSystem2.Id, rsp.Items[0].Routes[1].PaymentSystemId)
+	assert.EqualValues(suite.T(), 20, rsp.Items[0].Routes[1].Weight)
+
+	rule.Routes = []*billing.PaymentRoute{{PaymentSystemId: suite.paymentSystem2.Id}}
+	rsp1 := &grpc.SetPaymentRoutingRuleResponse{}
+	err = suite.service.SetPaymentRoutingRule(context.TODO(), rule, rsp1)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp1.Status)
+
+	rules, err := suite.service.paymentRoutingRule.GetByPaymentMethod(context.TODO(), suite.paymentMethod.Id)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), rules, 1)
+	assert.Len(suite.T(), rules[0].Routes, 1)
+	assert.Equal(suite.T(), suite.paymentSystem2.Id, rules[0].Routes[0].PaymentSystemId)
+}
+
+func (suite *PaymentRoutingRuleTestSuite) TestPaymentRoutingRule_SetPaymentRoutingRule_AlreadyExists_Error() {
+	suite.setRule("", "RU", &billing.PaymentRoute{PaymentSystemId: suite.paymentSystem1.Id})
+
+	req := &billing.PaymentRoutingRule{
+		PaymentMethodId: suite.paymentMethod.Id,
+		Country:         "RU",
+		Routes:          []*billing.PaymentRoute{{PaymentSystemId: suite.paymentSystem2.Id}},
+	}
+	rsp := &grpc.SetPaymentRoutingRuleResponse{}
+	err := suite.service.SetPaymentRoutingRule(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), errorPaymentRoutingRuleAlreadyExists, rsp.Message)
+}
+
+func (suite *PaymentRoutingRuleTestSuite) TestPaymentRoutingRule_SetPaymentRoutingRule_InvalidRoutes_Error() {
+	req := &billing.PaymentRoutingRule{PaymentMethodId: suite.paymentMethod.Id}
+	rsp := &grpc.SetPaymentRoutingRuleResponse{}
+	err := suite.service.SetPaymentRoutingRule(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), errorPaymentRoutingRuleRoutesEmpty, rsp.Message)
+
+	req.Routes = []*billing.PaymentRoute{
+		{PaymentSystemId: suite.paymentSystem1.Id},
+		{PaymentSystemId: suite.paymentSystem1.Id},
+	}
+	rsp = &grpc.SetPaymentRoutingRuleResponse{}
+	err = suite.service.SetPaymentRoutingRule(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), errorPaymentRoutingRuleRouteDuplicated, rsp.Message)
+
+	req.Routes = []*billing.PaymentRoute{{PaymentSystemId: primitive.NewObjectID().Hex()}}
+	rsp = &grpc.SetPaymentRoutingRuleResponse{}
+	err = suite.service.SetPaymentRoutingRule(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), errorPaymentRoutingRulePaymentSystemNotFound, rsp.Message)
+}
+
+func (suite *PaymentRoutingRuleTestSuite) TestPaymentRoutingRule_DeletePaymentRoutingRule_Ok() {
+	rule := suite.setRule("", "", &billing.PaymentRoute{PaymentSystemId: suite.paymentSystem2.Id})
+
+	rsp := &grpc.EmptyResponseWithStatus{}
+	err := suite.service.DeletePaymentRoutingRule(
+		context.TODO(),
+		&grpc.DeletePaymentRoutingRuleRequest{Id: rule.Id},
+		rsp,
+	)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	rules, err := suite.service.paymentRoutingRule.GetByPaymentMethod(context.TODO(), suite.paymentMethod.Id)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), rules)
+
+	err = suite.service.DeletePaymentRoutingRule(
+		context.TODO(),
+		&grpc.DeletePaymentRoutingRuleRequest{Id: rule.Id},
+		rsp,
+	)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusNotFound, rsp.Status)
+	assert.Equal(suite.T(), errorPaymentRoutingRuleNotFound, rsp.Message)
+}
+
+func (suite *PaymentRoutingRuleTestSuite) TestPaymentRoutingRule_GetPaymentRoutes_WithoutRules_Ok() {
+	routes, err := suite.service.getPaymentRoutes(context.TODO(), suite.paymentMethod, pkg.MccCodeLowRisk, "RU")
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), routes, 1)
+	assert.Equal(suite.T(), suite.paymentSystem1.Id, routes[0].Id)
+}
+
+func (suite *PaymentRoutingRuleTestSuite) TestPaymentRoutingRule_GetPaymentRoutes_MostSpecificRule_Ok() {
+	suite.setRule("", "", &billing.PaymentRoute{PaymentSystemId: suite.paymentSystem1.Id})
+	suite.setRule(
+		pkg.MccCodeHighRisk,
+		"",
+		&billing.PaymentRoute{PaymentSystemId: suite.paymentSystem3.Id},
+		&billing.PaymentRoute{PaymentSystemId: suite.paymentSystem2.Id},
+		&billing.PaymentRoute{PaymentSystemId: suite.paymentSystem1.Id},
+	)
+
+	routes, err := suite.service.getPaymentRoutes(context.TODO(), suite.paymentMethod, pkg.MccCodeHighRisk, "RU")
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), routes, 2)
+	assert.Equal(suite.T(), suite.paymentSystem2.Id, routes[0].Id)
+	assert.Equal(suite.T(), suite.paymentSystem1.Id, routes[1].Id)
+
+	routes, err = suite.service.getPaymentRoutes(context.TODO(), suite.paymentMethod, pkg.MccCodeLowRisk, "RU")
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), routes, 1)
+	assert.Equal(suite.T(), suite.paymentSystem1.Id, routes[0].Id)
+}
+
+func (suite *PaymentRoutingRuleTestSuite) TestPaymentRoutingRule_GetPaymentRoutes_AllInactive_Error() {
+	pm := &billing.PaymentMethod{Id: primitive.NewObjectID().Hex(), PaymentSystemId: suite.paymentSystem3.Id}
+
+	routes, err := suite.service.getPaymentRoutes(context.TODO(), pm, pkg.MccCodeLowRisk, "RU")
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), orderErrorPaymentSystemInactive, err)
+	assert.Nil(suite.T(), routes)
+}
+
+func (suite *PaymentRoutingRuleTestSuite) TestPaymentRoutingRule_SortPaymentRoutes_Ok() {
+	routes := []*billing.PaymentRoute{
+		{PaymentSystemId: suite.paymentSystem1.Id, Weight: 0},
+		{PaymentSystemId: suite.paymentSystem2.Id, Weight: 10},
+		{PaymentSystemId: suite.paymentSystem3.Id, Weight: 0},
+	}
+
+	sorted := sortPaymentRoutes(routes)
+	assert.Len(suite.T(), sorted, 3)
+	assert.Equal(suite.T(), suite.paymentSystem2.Id, sorted[0].PaymentSystemId)
+	assert.Equal(suite.T(), suite.paymentSystem1.Id, sorted[1].PaymentSystemId)
+	assert.Equal(suite.T(), suite.paymentSystem3.Id, sorted[2].PaymentSystemId)
+
+	routes[1].Weight = 0
+	assert.Equal(suite.T(), routes, sortPaymentRoutes(routes))
+}
+
+func (suite *PaymentRoutingRuleTestSuite) TestPaymentRoutingRule_CreatePaymentWithFailover_Ok() {
+	primary := &mocks.PaymentSystem{}
+	primary.On("CreatePayment", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return("", paymentSystemErrorCreateRequestFailed)
+
+	var secondaryParams *billing.PaymentMethodParams
+	secondary := &mocks.PaymentSystem{}
+	secondary.On("CreatePayment", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			secondaryParams = args.Get(0).(*billing.Order).PaymentMethod.Params
+		}).
+		Return("http://localhost/redirect", nil)
+
+	suite.registerProvider(suite.paymentSystem1.Handler, primary)
+	suite.registerProvider(suite.paymentSystem2.Handler, secondary)
+
+	order := suite.getFailoverOrder()
+	routes := []*billing.PaymentSystem{suite.paymentSystem1, suite.paymentSystem2}
+
+	url, err := suite.createPaymentWithFailover(order, routes)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "http://localhost/redirect", url)
+	assert.Equal(suite.T(), suite.paymentMethod2.Id, order.PaymentMethod.Id)
+	assert.Equal(suite.T(), suite.paymentSystem2.Id, order.PaymentMethod.PaymentSystemId)
+	assert.Equal(suite.T(), suite.paymentSystem2.Handler, order.PaymentMethod.Handler)
+	assert.Len(suite.T(), order.PaymentRoutes, 2)
+	assert.Equal(suite.T(), suite.paymentSystem1.Id, order.PaymentRoutes[0].PaymentSystemId)
+	assert.Equal(suite.T(), pkg.OrderPaymentRouteStatusFailed, order.PaymentRoutes[0].Status)
+	assert.Equal(suite.T(), paymentSystemErrorCreateRequestFailed.Code, order.PaymentRoutes[0].ErrorCode)
+	assert.Equal(suite.T(), suite.paymentSystem2.Id, order.PaymentRoutes[1].PaymentSystemId)
+	assert.Equal(suite.T(), pkg.OrderPaymentRouteStatusOk, order.PaymentRoutes[1].Status)
+	assert.Empty(suite.T(), order.PaymentRoutes[1].ErrorCode)
+
+	assert.NotNil(suite.T(), secondaryParams)
+	assert.Equal(suite.T(), "secondary_terminal", secondaryParams.TerminalId)
+	assert.Equal(suite.T(), "secondary_secret", secondaryParams.Secret)
+	assert.Equal(suite.T(), "secondary_secret_callback", secondaryParams.SecretCallback)
+}
+
+func (suite *PaymentRoutingRuleTestSuite) TestPaymentRoutingRule_CreatePaymentWithFailover_NotFailoverError() {
+	primary := &mocks.PaymentSystem{}
+	primary.On("CreatePayment", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return("", paymentSystemErrorEWalletIdentifierIsInvalid)
+	secondary := &mocks.PaymentSystem{}
+
+	suite.registerProvider(suite.paymentSystem1.Handler, primary)
+	suite.registerProvider(suite.paymentSystem2.Handler, secondary)
+
+	order := suite.getFailoverOrder()
+	routes := []*billing.PaymentSystem{suite.paymentSystem1, suite.paymentSystem2}
+
+	url, err := suite.createPaymentWithFailover(order, routes)
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), paymentSystemErrorEWalletIdentifierIsInvalid, err)
+	assert.Empty(suite.T(), url)
+	assert.Len(suite.T(), order.PaymentRoutes, 1)
+	assert.Equal(suite.T(), pkg.OrderPaymentRouteStatusFailed, order.PaymentRoutes[0].Status)
+	secondary.AssertNotCalled(suite.T(), "CreatePayment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *PaymentRoutingRuleTestSuite) TestPaymentRoutingRule_CreatePaymentWithFailover_AllFailed_Error() {
+	primary := &mocks.PaymentSystem{}
+	primary.On("CreatePayment", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return("", paymentSystemErrorCreateRequestFailed)
+	suite.registerProvider(suite.paymentSystem1.Handler, primary)
+
+	order := suite.getFailoverOrder()
+	routes := []*billing.PaymentSystem{suite.paymentSystem1, suite.paymentSystem2}
+
+	url, err := suite.createPaymentWithFailover(order, routes)
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), paymentSystemErrorHandlerNotFound, err)
+	assert.Empty(suite.T(), url)
+	assert.Len(suite.T(), order.PaymentRoutes, 2)
+	assert.Equal(suite.T(), paymentSystemErrorCreateRequestFailed.Code, order.PaymentRoutes[0].ErrorCode)
+	assert.Equal(suite.T(), paymentSystemErrorHandlerNotFound.Code, order.PaymentRoutes[1].ErrorCode)
+}
+
+func (suite *PaymentRoutingRuleTestSuite) TestPaymentRoutingRule_CreatePaymentWithFailover_RoutePaymentMethodNotFound_Error() {
+	primary := &mocks.PaymentSystem{}
+	primary.On("CreatePayment", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return("", paymentSystemErrorCreateRequestFailed)
+	secondary := &mocks.PaymentSystem{}
+
+	suite.registerProvider(suite.paymentSystem1.Handler, primary)
+	suite.registerProvider(suite.paymentSystem2.Handler, secondary)
+
+	suite.paymentMethod2.IsActive = false
+	err := suite.service.paymentMethod.Update(context.TODO(), suite.paymentMethod2)
+	assert.NoError(suite.T(), err)
+
+	order := suite.getFailoverOrder()
+	routes := []*billing.PaymentSystem{suite.paymentSystem1, suite.paymentSystem2}
+
+	url, err := suite.createPaymentWithFailover(order, routes)
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), orderErrorPaymentMethodNotFound, err)
+	assert.Empty(suite.T(), url)
+	assert.Len(suite.T(), order.PaymentRoutes, 2)
+	assert.Equal(suite.T(), orderErrorPaymentMethodNotFound.Code, order.PaymentRoutes[1].ErrorCode)
+	assert.Equal(suite.T(), "primary_terminal", order.PaymentMethod.Params.TerminalId)
+	secondary.AssertNotCalled(suite.T(), "CreatePayment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *PaymentRoutingRuleTestSuite) getFailoverOrder() *billing.Order {
+	return &billing.Order{
+		Uuid:           primitive.NewObjectID().Hex(),
+		ChargeCurrency: "RUB",
+		MccCode:        pkg.MccCodeLowRisk,
+		PaymentMethod:  &billing.PaymentMethodOrder{Id: suite.paymentMethod.Id},
+	}
+}
+
+func (suite *PaymentRoutingRuleTestSuite) createPaymentWithFailover(
+	order *billing.Order,
+	routes []*billing.PaymentSystem,
+) (string, error) {
+	return suite.service.createPaymentWithFailover(
+		context.TODO(),
+		order,
+		suite.paymentMethod,
+		routes,
+		suite.project,
+		"VISA",
+		map[string]string{},
+	)
+}
+
+func (suite *PaymentRoutingRuleTestSuite) registerProvider(handler string, ps PaymentSystem) {
+	err := suite.service.RegisterPaymentSystemProvider(&PaymentSystemProvider{
+		Handler: handler,
+		New:     func() PaymentSystem { return ps },
+	})
+
+	if err != nil {
+		suite.FailNow("Payment system provider registration failed", "%v", err)
+	}
+}
@@ -4,8 +4,6 @@ import (
 	"context"
 	"fmt"
 	"github.com/golang/protobuf/proto"
-	"github.com/paysuper/paysuper-billing-server/internal/config"
-	"github.com/paysuper/paysuper-billing-server/pkg"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -13,9 +11,10 @@ import (
 )
 
 const (
-	paymentSystemHandlerMockOk      = "mock_ok"
-	paymentSystemHandlerMockError   = "mock_error"
-	paymentSystemHandlerCardPayMock = "cardpay_mock"
+	paymentSystemHandlerMockOk             = "mock_ok"
+	paymentSystemHandlerMockError          = "mock_error"
+	paymentSystemHandlerCardPayMock        = "cardpay_mock"
+	paymentSystemHandlerCardPayPendingMock = "cardpay_pending_mock"
 
 	defaultHttpClientTimeout = 10
 
@@ -38,13 +37,12 @@ var (
 	paymentSystemErrorRefundRequestAmountOrCurrencyIsInvalid = newBillingServerErrorMsg("ph000012", "amount or currency from request not match with value in refund")
 	paymentSystemErrorRequestTemporarySkipped                = newBillingServerErrorMsg("ph000013", "notification skipped with temporary status")
 	paymentSystemErrorRecurringFailed                        = newBillingServerErrorMsg("ph000014", "recurring payment failed")
-
-	paymentSystemHandlers = map[string]func() PaymentSystem{
-		pkg.PaymentSystemHandlerCardPay: newCardPayHandler,
-		paymentSystemHandlerMockOk:      NewPaymentSystemMockOk,
-		paymentSystemHandlerMockError:   NewPaymentSystemMockError,
-		paymentSystemHandlerCardPayMock: NewCardPayMock,
-	}
+	paymentSystemErrorCaptureRequestFailed                   = newBillingServerErrorMsg("ph000015", "payment authorization can't be captured. try request later")
+	paymentSystemErrorCaptureRequestRejected                 = newBillingServerErrorMsg("ph000016", "payment authorization capture request rejected")
+	paymentSystemErrorVoidRequestFailed                      = newBillingServerErrorMsg("ph000017", "payment authorization can't be voided. try request later")
+	paymentSystemErrorVoidRequestRejected                    = newBillingServerErrorMsg("ph000018", "payment authorization void request rejected")
+	paymentSystemErrorPaymentStatusRequestFailed             = newBillingServerErrorMsg("ph000019", "payment status can't be received. try request later")
+	paymentSystemErrorPaymentNotFound                        = newBillingServerErrorMsg("ph000020", "payment not found in payment system")
 )
 
 type PaymentSystem interface {
@@ -54,26 +52,36 @@ type PaymentSystem interface {
 	GetRecurringId(request proto.Message) string
 	CreateRefund(order *billing.Order, refund *billing.Refund) error
 	ProcessRefund(order *billing.Order, refund *billing.Refund, message proto.Message, raw, signature string) error
+	CapturePayment(order *billing.Order, amount float64) error
+	VoidPayment(order *billing.Order) error
+	// GetPaymentStatus requests actual state of order payment from payment system and returns it
+	// as message in the same format as the payment callback message for ProcessPayment.
+	GetPaymentStatus(order *billing.Order) (proto.Message, error)
 }
 
-func (s *Service) NewPaymentSystem(
-	ctx context.Context,
-	cfg *config.PaymentSystemConfig,
-	order *billing.Order,
-) (PaymentSystem, error) {
-	ps, err := s.paymentSystem.GetById(ctx, order.PaymentMethod.PaymentSystemId)
+func (s *Service) NewPaymentSystem(ctx context.Context, order *billing.Order) (PaymentSystem, error) {
+	provider, err := s.getPaymentSystemProvider(ctx, order.PaymentMethod.PaymentSystemId)
 
 	if err != nil {
 		return nil, err
 	}
 
-	h, ok := paymentSystemHandlers[ps.Handler]
+	return provider.New(), nil
+}
+
+// RegisterPaymentSystemProvider adds an acquirer to the payment system provider registry of the service.
+func (s *Service) RegisterPaymentSystemProvider(provider *PaymentSystemProvider) error {
+	return s.paymentSystemProviders.Register(provider)
+}
+
+func (s *Service) getPaymentSystemProvider(ctx context.Context, paymentSystemId string) (*PaymentSystemProvider, error) {
+	ps, err := s.paymentSystem.GetById(ctx, paymentSystemId)
 
-	if !ok {
-		return nil, paymentSystemErrorHandlerNotFound
+	if err != nil {
+		return nil, err
 	}
 
-	return h(), nil
+	return s.paymentSystemProviders.Get(ps.Handler)
 }
 
 type PaymentSystemServiceInterface interface {
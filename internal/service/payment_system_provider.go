@@ -0,0 +1,141 @@
+package service
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/paysuper/paysuper-billing-server/internal/config"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"sync"
+)
+
+var (
+	paymentSystemProviderErrorHandlerRequired        = newBillingServerErrorMsg("ph000021", "payment system provider handler required")
+	paymentSystemProviderErrorFactoryRequired        = newBillingServerErrorMsg("ph000022", "payment system provider factory required")
+	paymentSystemProviderErrorAlreadyRegistered      = newBillingServerErrorMsg("ph000023", "payment system provider with specified handler already registered")
+	paymentSystemProviderErrorSignatureCheckRequired = newBillingServerErrorMsg("ph000024", "payment system provider processing callbacks must check callback signature")
+)
+
+// PaymentSystemProvider describes an acquirer which can process payments and refunds of orders.
+// Each acquirer registers itself in the provider registry together with its own config section
+// and its callbacks processing functions, so adding a new acquirer doesn't require changes in the
+// orders and refunds processing code.
+type PaymentSystemProvider struct {
+	// Handler is the value of the billing.PaymentSystem handler field served by the provider.
+	Handler string
+	// Config is the provider own config section.
+	Config config.PaymentSystemProviderConfig
+	// New creates the PaymentSystem implementation of the provider.
+	New func() PaymentSystem
+	// ParsePaymentCallback converts raw payment callback of the provider to a message for PaymentSystem.ProcessPayment.
+	ParsePaymentCallback func(raw []byte) (proto.Message, error)
+	// ParseRefundCallback converts raw refund callback of the provider to a message for PaymentSystem.ProcessRefund
+	// and returns identifier of the refund from the callback.
+	ParseRefundCallback func(raw []byte) (proto.Message, string, error)
+	// CheckCallbackSignature verifies signature of the raw payment or refund callback of the provider.
+	// It's required for providers which parse payment or refund callbacks.
+	CheckCallbackSignature func(order *billing.Order, raw, signature string) error
+}
+
+type PaymentSystemProviderRegistry struct {
+	mx        sync.RWMutex
+	providers map[string]*PaymentSystemProvider
+}
+
+func NewPaymentSystemProviderRegistry() *PaymentSystemProviderRegistry {
+	return &PaymentSystemProviderRegistry{providers: make(map[string]*PaymentSystemProvider)}
+}
+
+func newDefaultPaymentSystemProviderRegistry(cfg *config.Config) (*PaymentSystemProviderRegistry, error) {
+	r := NewPaymentSystemProviderRegistry()
+	providers := []*PaymentSystemProvider{
+		{
+			Handler:                pkg.PaymentSystemHandlerCardPay,
+			Config:                 cfg.CardPay,
+			New:                    newCardPayHandler,
+			ParsePaymentCallback:   parseCardPayPaymentCallback,
+			ParseRefundCallback:    parseCardPayRefundCallback,
+			CheckCallbackSignature: checkCardPayCallbackSignature,
+		},
+		{
+			Handler:                paymentSystemHandlerCardPayMock,
+			Config:                 cfg.CardPay,
+			New:                    NewCardPayMock,
+			ParsePaymentCallback:   parseCardPayPaymentCallback,
+			ParseRefundCallback:    parseCardPayRefundCallback,
+			CheckCallbackSignature: checkCardPayCallbackSignature,
+		},
+		{
+			Handler: paymentSystemHandlerCardPayPendingMock,
+			Config:  cfg.CardPay,
+			New:     NewCardPayPendingMock,
+		},
+		{
+			Handler: paymentSystemHandlerMockOk,
+			Config:  cfg.CardPay,
+			New:     NewPaymentSystemMockOk,
+		},
+		{
+			Handler: paymentSystemHandlerMockError,
+			Config:  cfg.CardPay,
+			New:     NewPaymentSystemMockError,
+		},
+	}
+
+	for _, p := range providers {
+		if err := r.Register(p); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// Register adds the payment system provider to the registry.
+func (r *PaymentSystemProviderRegistry) Register(provider *PaymentSystemProvider) error {
+	if provider.Handler == "" {
+		return paymentSystemProviderErrorHandlerRequired
+	}
+
+	if provider.New == nil {
+		return paymentSystemProviderErrorFactoryRequired
+	}
+
+	if (provider.ParsePaymentCallback != nil || provider.ParseRefundCallback != nil) &&
+		provider.CheckCallbackSignature == nil {
+		return paymentSystemProviderErrorSignatureCheckRequired
+	}
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if _, ok := r.providers[provider.Handler]; ok {
+		return paymentSystemProviderErrorAlreadyRegistered
+	}
+
+	r.providers[provider.Handler] = provider
+
+	return nil
+}
+
+// Get returns the payment system provider registered for the handler.
+func (r *PaymentSystemProviderRegistry) Get(handler string) (*PaymentSystemProvider, error) {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+
+	provider, ok := r.providers[handler]
+
+	if !ok {
+		return nil, paymentSystemErrorHandlerNotFound
+	}
+
+	return provider, nil
+}
+
+// GetApiUrl returns url of the provider API for production or sandbox mode.
+func (p *PaymentSystemProvider) GetApiUrl(isProduction bool) string {
+	if p.Config == nil {
+		return ""
+	}
+
+	return p.Config.GetApiUrl(isProduction)
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"github.com/paysuper/paysuper-billing-server/internal/config"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type PaymentSystemProviderTestSuite struct {
+	suite.Suite
+
+	cfg      *config.Config
+	registry *PaymentSystemProviderRegistry
+}
+
+func Test_PaymentSystemProvider(t *testing.T) {
+	suite.Run(t, new(PaymentSystemProviderTestSuite))
+}
+
+func (suite *PaymentSystemProviderTestSuite) SetupTest() {
+	cfg, err := config.NewConfig()
+
+	if err != nil {
+		suite.FailNow("Config load failed", "%v", err)
+	}
+
+	cfg.CardPay.ApiUrl = "https://cardpay.com"
+	cfg.CardPay.ApiSandboxUrl = "https://sandbox.cardpay.com"
+
+	suite.cfg = cfg
+	suite.registry, err = newDefaultPaymentSystemProviderRegistry(cfg)
+
+	if err != nil {
+		suite.FailNow("Payment system provider registry init failed", "%v", err)
+	}
+}
+
+func (suite *PaymentSystemProviderTestSuite) TearDownTest() {}
+
+func (suite *PaymentSystemProviderTestSuite) TestPaymentSystemProvider_Get_Ok() {
+	provider, err := suite.registry.Get(pkg.PaymentSystemHandlerCardPay)
+	assert.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), provider)
+	assert.Equal(suite.T(), pkg.PaymentSystemHandlerCardPay, provider.Handler)
+	assert.NotNil(suite.T(), provider.ParsePaymentCallback)
+	assert.NotNil(suite.T(), provider.ParseRefundCallback)
+	assert.NotNil(suite.T(), provider.CheckCallbackSignature)
+	assert.IsType(suite.T(), &cardPay{}, provider.New())
+	assert.Equal(suite.T(), suite.cfg.CardPay.ApiUrl, provider.GetApiUrl(true))
+	assert.Equal(suite.T(), suite.cfg.CardPay.ApiSandboxUrl, provider.GetApiUrl(false))
+}
+
+func (suite *PaymentSystemProviderTestSuite) TestPaymentSystemProvider_Get_HandlerNotFound_Error() {
+	provider, err := suite.registry.Get("unknown_handler")
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), paymentSystemErrorHandlerNotFound, err)
+	assert.Nil(suite.T(), provider)
+}
+
+func (suite *PaymentSystemProviderTestSuite) TestPaymentSystemProvider_Register_Ok() {
+	provider := &PaymentSystemProvider{
+		Handler: "second_acquirer",
+		New:     NewPaymentSystemMockOk,
+	}
+	err := suite.registry.Register(provider)
+	assert.NoError(suite.T(), err)
+
+	provider1, err := suite.registry.Get(provider.Handler)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), provider, provider1)
+	assert.Empty(suite.T(), provider1.GetApiUrl(true))
+}
+
+func (suite *PaymentSystemProviderTestSuite) TestPaymentSystemProvider_Register_AlreadyRegistered_Error() {
+	provider := &PaymentSystemProvider{
+		Handler: pkg.PaymentSystemHandlerCardPay,
+		New:     NewPaymentSystemMockOk,
+	}
+	err := suite.registry.Register(provider)
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), paymentSystemProviderErrorAlreadyRegistered, err)
+}
+
+func (suite *PaymentSystemProviderTestSuite) TestPaymentSystemProvider_Register_HandlerRequired_Error() {
+	err := suite.registry.Register(&PaymentSystemProvider{New: NewPaymentSystemMockOk})
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), paymentSystemProviderErrorHandlerRequired, err)
+}
+
+func (suite *PaymentSystemProviderTestSuite) TestPaymentSystemProvider_Register_FactoryRequired_Error() {
+	err := suite.registry.Register(&PaymentSystemProvider{Handler: "second_acquirer"})
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), paymentSystemProviderErrorFactoryRequired, err)
+}
+
+func (suite *PaymentSystemProviderTestSuite) TestPaymentSystemProvider_ParseCardPayRefundCallback_Error() {
+	provider, err := suite.registry.Get(pkg.PaymentSystemHandlerCardPay)
+	assert.NoError(suite.T(), err)
+
+	data, refundId, err := provider.ParseRefundCallback([]byte(`{"payment_method": "BANKCARD"}`))
+	assert.Error(suite.T(), err)
+	assert.Nil(suite.T(), data)
+	assert.Empty(suite.T(), refundId)
+}
+
+func (suite *PaymentSystemProviderTestSuite) TestPaymentSystemProvider_Register_SignatureCheckRequired_Error() {
+	provider := &PaymentSystemProvider{
+		Handler:              "second_acquirer",
+		New:                  NewPaymentSystemMockOk,
+		ParsePaymentCallback: parseCardPayPaymentCallback,
+	}
+	err := suite.registry.Register(provider)
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), paymentSystemProviderErrorSignatureCheckRequired, err)
+
+	_, err = suite.registry.Get(provider.Handler)
+	assert.Equal(suite.T(), paymentSystemErrorHandlerNotFound, err)
+}
+
+func (suite *PaymentSystemProviderTestSuite) TestPaymentSystemProvider_CardPayMock_ChecksSignature() {
+	provider, err := suite.registry.Get(paymentSystemHandlerCardPayMock)
+	assert.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), provider.CheckCallbackSignature)
+
+	order := &billing.Order{
+		PaymentMethod: &billing.PaymentMethodOrder{
+			Params: &billing.PaymentMethodParams{SecretCallback: "unit_test"},
+		},
+	}
+	err = provider.CheckCallbackSignature(order, `{"payment_method": "BANKCARD"}`, "invalid_signature")
+	assert.Error(suite.T(), err)
+}
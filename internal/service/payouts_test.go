@@ -72,7 +72,7 @@ func (suite *PayoutsTestSuite) SetupTest() {
 
 	assert.NoError(suite.T(), err, "Config load failed")
 
-	cfg.CardPayApiUrl = "https://sandbox.cardpay.com"
+	cfg.CardPay.ApiUrl = "https://sandbox.cardpay.com"
 
 	db, err := mongodb.NewDatabase()
 	assert.NoError(suite.T(), err, "Database connection failed")
@@ -388,6 +388,8 @@ func (s *Service) createProject(ctx context.Context, req *billing.Project) (*bil
 		ShortDescription:         req.ShortDescription,
 		Currencies:               req.Currencies,
 		VirtualCurrency:          req.VirtualCurrency,
+		OrderExpiryTimeout:       req.OrderExpiryTimeout,
+		PreAuthorizeKeyOrders:    req.PreAuthorizeKeyOrders,
 		CreatedAt:                ptypes.TimestampNow(),
 		UpdatedAt:                ptypes.TimestampNow(),
 	}
@@ -436,6 +438,8 @@ func (s *Service) updateProject(ctx context.Context, req *billing.Project, proje
 	project.Currencies = req.Currencies
 	project.VirtualCurrency = req.VirtualCurrency
 	project.Cover = req.Cover
+	project.OrderExpiryTimeout = req.OrderExpiryTimeout
+	project.PreAuthorizeKeyOrders = req.PreAuthorizeKeyOrders
 
 	if err := s.project.Update(ctx, project); err != nil {
 		return projectErrorUnknown
@@ -632,6 +636,37 @@ func (h Project) GetById(ctx context.Context, id string) (*billing.Project, erro
 	return &c, nil
 }
 
+// GetWithOrderExpiryTimeout returns projects which have own timeout of not paid orders expiry.
+func (h Project) GetWithOrderExpiryTimeout(ctx context.Context) ([]*billing.Project, error) {
+	query := bson.M{"order_expiry_timeout": bson.M{"$gt": 0}}
+	cursor, err := h.svc.db.Collection(collectionProject).Find(ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionProject),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	var projects []*billing.Project
+	err = cursor.All(ctx, &projects)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionProject),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	return projects, nil
+}
+
 func (s *Service) CheckSkuAndKeyProject(ctx context.Context, req *grpc.CheckSkuAndKeyProjectRequest, rsp *grpc.EmptyResponseWithStatus) error {
 	rsp.Status = pkg.ResponseStatusOk
 
@@ -2,9 +2,8 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	protobuf "github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/google/uuid"
 	"github.com/jinzhu/copier"
@@ -18,6 +17,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
+	"math"
 	"time"
 )
 
@@ -35,10 +35,15 @@ var (
 	refundErrorNotFound           = newBillingServerErrorMsg("rf000005", "refund with specified data not found")
 	refundErrorOrderNotFound      = newBillingServerErrorMsg("rf000006", "information about payment for refund with specified data not found")
 	refundErrorCostsRatesNotFound = newBillingServerErrorMsg("rf000007", "settings to calculate commissions for refund not found")
+	refundErrorAmountRequired     = newBillingServerErrorMsg("rf000008", "refund amount or order items to refund required")
+	refundErrorItemNotFound       = newBillingServerErrorMsg("rf000009", "order item to refund not found")
+	refundErrorItemQuantityExceed = newBillingServerErrorMsg("rf000010", "quantity of order item to refund exceeds not refunded quantity")
+	refundErrorItemKeysNotFound   = newBillingServerErrorMsg("rf000011", "keys of order item to refund not found")
 )
 
 type createRefundChecked struct {
 	order *billing.Order
+	items []*billing.RefundItem
 }
 
 type createRefundProcessor struct {
@@ -52,6 +57,27 @@ func (s *Service) CreateRefund(
 	ctx context.Context,
 	req *grpc.CreateRefundRequest,
 	rsp *grpc.CreateRefundResponse,
+) error {
+	return s.processIdempotentRequest(
+		idempotencyMethodRefundCreate,
+		req.OrderId,
+		req.IdempotencyKey,
+		req,
+		rsp,
+		func(message *grpc.ResponseErrorMessage) {
+			rsp.Status = pkg.ResponseStatusConflict
+			rsp.Message = message
+		},
+		func() error {
+			return s.createRefund(ctx, req, rsp)
+		},
+	)
+}
+
+func (s *Service) createRefund(
+	ctx context.Context,
+	req *grpc.CreateRefundRequest,
+	rsp *grpc.CreateRefundResponse,
 ) error {
 	processor := &createRefundProcessor{
 		service: s,
@@ -69,7 +95,23 @@ func (s *Service) CreateRefund(
 		return nil
 	}
 
-	h, err := s.NewPaymentSystem(ctx, s.cfg.PaymentSystemConfig, processor.checked.order)
+	if refund.Status == pkg.RefundStatusPendingApproval {
+		rsp.Status = pkg.ResponseStatusOk
+		rsp.Item = refund
+
+		return nil
+	}
+
+	return s.sendRefundToPaymentSystem(ctx, processor.checked.order, refund, rsp)
+}
+
+func (s *Service) sendRefundToPaymentSystem(
+	ctx context.Context,
+	order *billing.Order,
+	refund *billing.Refund,
+	rsp *grpc.CreateRefundResponse,
+) error {
+	h, err := s.NewPaymentSystem(ctx, order)
 
 	if err != nil {
 		zap.S().Errorw(pkg.MethodFinishedWithError, "err", err)
@@ -81,7 +123,7 @@ func (s *Service) CreateRefund(
 		return err
 	}
 
-	err = h.CreateRefund(processor.checked.order, refund)
+	err = h.CreateRefund(order, refund)
 
 	if err != nil {
 		rsp.Status = pkg.ResponseStatusBadData
@@ -90,6 +132,31 @@ func (s *Service) CreateRefund(
 		return nil
 	}
 
+	// refund completed by payment system at once is processed without callback of payment system
+	if refund.Status == pkg.RefundStatusCompleted {
+		notifyRsp := &grpc.PaymentNotifyResponse{}
+		err = s.processRefundNotification(ctx, order, refund, nil, notifyRsp)
+
+		if err != nil || notifyRsp.Status != pkg.ResponseStatusOk {
+			zap.L().Error(
+				"Processing of completed refund failed",
+				zap.Error(err),
+				zap.String("refund_id", refund.Id),
+				zap.String("error", notifyRsp.Error),
+			)
+
+			rsp.Status = pkg.ResponseStatusSystemError
+			rsp.Message = refundErrorUnknown
+
+			return nil
+		}
+
+		rsp.Status = pkg.ResponseStatusOk
+		rsp.Item = refund
+
+		return nil
+	}
+
 	oid, _ := primitive.ObjectIDFromHex(refund.Id)
 	filter := bson.M{"_id": oid}
 	_, err = s.db.Collection(collectionRefund).ReplaceOne(ctx, filter, refund)
@@ -225,35 +292,29 @@ func (s *Service) ProcessRefundCallback(
 	req *grpc.CallbackRequest,
 	rsp *grpc.PaymentNotifyResponse,
 ) error {
-	var data protobuf.Message
-	var refundId string
 	var refund *billing.Refund
 
-	switch req.Handler {
-	case pkg.PaymentSystemHandlerCardPay:
-		data = &billing.CardPayRefundCallback{}
-		err := json.Unmarshal(req.Body, &data)
+	provider, err := s.paymentSystemProviders.Get(req.Handler)
 
-		if err != nil ||
-			data.(*billing.CardPayRefundCallback).RefundData == nil {
-			rsp.Status = pkg.ResponseStatusBadData
-			rsp.Error = callbackRequestIncorrect
+	if err != nil || provider.ParseRefundCallback == nil {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Error = callbackHandlerIncorrect
 
-			return nil
-		}
+		return nil
+	}
+
+	data, refundId, err := provider.ParseRefundCallback(req.Body)
 
-		refundId = data.(*billing.CardPayRefundCallback).MerchantOrder.Id
-		break
-	default:
+	if err != nil {
 		rsp.Status = pkg.ResponseStatusBadData
-		rsp.Error = callbackHandlerIncorrect
+		rsp.Error = callbackRequestIncorrect
 
 		return nil
 	}
 
 	oid, _ := primitive.ObjectIDFromHex(refundId)
 	filter := bson.M{"_id": oid}
-	err := s.db.Collection(collectionRefund).FindOne(ctx, filter).Decode(&refund)
+	err = s.db.Collection(collectionRefund).FindOne(ctx, filter).Decode(&refund)
 
 	if err != nil || refund == nil {
 		if err != nil && err != mongo.ErrNoDocuments {
@@ -275,11 +336,11 @@ func (s *Service) ProcessRefundCallback(
 		return nil
 	}
 
-	h, err := s.NewPaymentSystem(ctx, s.cfg.PaymentSystemConfig, order)
+	orderProvider, err := s.getPaymentSystemProvider(ctx, order.PaymentMethod.PaymentSystemId)
 
 	if err != nil {
 		zap.L().Error(
-			"s.NewPaymentSystem method failed",
+			"s.getPaymentSystemProvider method failed",
 			zap.Error(err),
 			zap.Any("order", order),
 		)
@@ -289,7 +350,33 @@ func (s *Service) ProcessRefundCallback(
 		return nil
 	}
 
-	pErr := h.ProcessRefund(order, refund, data, string(req.Body), req.Signature)
+	if orderProvider.CheckCallbackSignature == nil {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Error = callbackHandlerIncorrect
+
+		return nil
+	}
+
+	pErr := orderProvider.CheckCallbackSignature(order, string(req.Body), req.Signature)
+
+	if pErr != nil {
+		refund.Status = pkg.RefundStatusRejected
+		pErr.(*grpc.ResponseError).Status = pkg.ResponseStatusBadData
+	} else {
+		pErr = orderProvider.New().ProcessRefund(order, refund, data, string(req.Body), req.Signature)
+	}
+
+	return s.processRefundNotification(ctx, order, refund, pErr, rsp)
+}
+
+func (s *Service) processRefundNotification(
+	ctx context.Context,
+	order *billing.Order,
+	refund *billing.Refund,
+	pErr error,
+	rsp *grpc.PaymentNotifyResponse,
+) error {
+	var err error
 
 	if pErr != nil {
 		rsp.Error = pErr.Error()
@@ -312,8 +399,8 @@ func (s *Service) ProcessRefundCallback(
 		}
 	}
 
-	oid, _ = primitive.ObjectIDFromHex(refundId)
-	filter = bson.M{"_id": oid}
+	oid, _ := primitive.ObjectIDFromHex(refund.Id)
+	filter := bson.M{"_id": oid}
 	_, err = s.db.Collection(collectionRefund).ReplaceOne(ctx, filter, refund)
 
 	if err != nil {
@@ -361,9 +448,14 @@ func (s *Service) ProcessRefundCallback(
 		s.sendMailWithReceipt(ctx, refundOrder)
 
 		processor := &createRefundProcessor{service: s, ctx: ctx}
-		refundedAmount, _ := processor.getRefundedAmount(order)
+		// refunds waiting for approval or in progress in payment system don't return money to customer yet
+		refundedAmount, _ := processor.getCompletedRefundsAmount(order)
+
+		if order.ProductType == billing.OrderType_key {
+			s.revokeRefundKeys(ctx, order, refund, isRefundAmountReached(order.ChargeAmount, refundedAmount))
+		}
 
-		if refundedAmount == order.ChargeAmount {
+		if isRefundAmountReached(order.ChargeAmount, refundedAmount) {
 			if refund.IsChargeback == true {
 				order.PrivateStatus = constant.OrderStatusChargeback
 				order.Status = constant.OrderPublicStatusChargeback
@@ -396,6 +488,77 @@ func (s *Service) ProcessRefundCallback(
 	return nil
 }
 
+// revokeRefundKeys marks keys of refunded items as returned, all keys of order are revoked on full refund
+func (s *Service) revokeRefundKeys(ctx context.Context, order *billing.Order, refund *billing.Refund, isFullRefund bool) {
+	var keyIds []string
+
+	for _, item := range refund.Items {
+		keyIds = append(keyIds, item.KeyIds...)
+	}
+
+	if isFullRefund {
+		keys, err := s.keyRepository.FindByOrderId(ctx, order.Id)
+
+		if err != nil {
+			zap.L().Error(
+				"find keys of refunded order failed",
+				zap.Error(err),
+				zap.String("order_id", order.Id),
+			)
+		}
+
+		for _, key := range keys {
+			if key.RefundId == "" && !contains(keyIds, key.Id) {
+				keyIds = append(keyIds, key.Id)
+			}
+		}
+	}
+
+	for _, keyId := range keyIds {
+		if _, err := s.keyRepository.RevokeById(ctx, keyId, refund.Id); err != nil {
+			zap.L().Error(
+				"revoke of refunded key failed",
+				zap.Error(err),
+				zap.String("key_id", keyId),
+				zap.String("refund_id", refund.Id),
+			)
+		}
+	}
+}
+
+// getRefundOrderItems returns refunded items for order created by refund, amounts of items are scaled
+// to amount of refund order to distribute refund between refunded products only
+func getRefundOrderItems(order *billing.Order, refund *billing.Refund, amount float64) []*billing.OrderItem {
+	var items []*billing.OrderItem
+	itemsAmount := float64(0)
+
+	for _, v := range refund.Items {
+		itemsAmount += v.Amount
+	}
+
+	for _, v := range refund.Items {
+		for _, orderItem := range order.Items {
+			if orderItem.Id != v.ItemId {
+				continue
+			}
+
+			for i := int32(0); i < v.Quantity; i++ {
+				item := proto.Clone(orderItem).(*billing.OrderItem)
+
+				if itemsAmount > 0 {
+					item.Amount = tools.FormatAmount(v.Amount / float64(v.Quantity) / itemsAmount * amount)
+				}
+
+				items = append(items, item)
+			}
+
+			break
+		}
+	}
+
+	return items
+}
+
 func (s *Service) createOrderByRefund(ctx context.Context, order *billing.Order, refund *billing.Refund) (string, error) {
 	refundOrder := new(billing.Order)
 	err := copier.Copy(&refundOrder, &order)
@@ -479,6 +642,10 @@ func (s *Service) createOrderByRefund(ctx context.Context, order *billing.Order,
 
 	refundOrder.Tax.Amount = tools.FormatAmount(tools.GetPercentPartFromAmount(refund.Amount, refundOrder.Tax.Rate))
 	refundOrder.OrderAmount = tools.FormatAmount(refundOrder.TotalPaymentAmount - refundOrder.Tax.Amount)
+
+	if len(refund.Items) > 0 {
+		refundOrder.Items = getRefundOrderItems(order, refund, refundOrder.OrderAmount)
+	}
 	refundOrder.ReceiptId = uuid.New().String()
 	refundOrder.ReceiptUrl = s.cfg.GetReceiptRefundUrl(refundOrder.Uuid, refundOrder.ReceiptId)
 
@@ -509,6 +676,18 @@ func (p *createRefundProcessor) processCreateRefund() (*billing.Refund, error) {
 		return nil, newBillingServerResponseError(pkg.ResponseStatusBadData, refundErrorCostsRatesNotFound)
 	}
 
+	if len(p.request.Items) > 0 && !p.request.IsChargeback {
+		err = p.processRefundItems()
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.request.Amount <= 0 && !p.request.IsChargeback {
+		return nil, newBillingServerResponseError(pkg.ResponseStatusBadData, refundErrorAmountRequired)
+	}
+
 	err = p.processRefundsByOrder()
 
 	if err != nil {
@@ -540,6 +719,7 @@ func (p *createRefundProcessor) processCreateRefund() (*billing.Refund, error) {
 			State:   order.GetState(),
 		},
 		IsChargeback: p.request.IsChargeback,
+		Items:        p.checked.items,
 	}
 
 	if refund.IsChargeback == true {
@@ -555,6 +735,18 @@ func (p *createRefundProcessor) processCreateRefund() (*billing.Refund, error) {
 		refund.Reason = p.request.Reason
 	}
 
+	if refund.IsChargeback == false {
+		refund.Approval, err = p.service.getRefundApproval(p.ctx, order, refund)
+
+		if err != nil {
+			return nil, newBillingServerResponseError(pkg.ResponseStatusSystemError, refundErrorUnknown)
+		}
+
+		if refund.Approval != nil {
+			refund.Status = pkg.RefundStatusPendingApproval
+		}
+	}
+
 	_, err = p.service.db.Collection(collectionRefund).InsertOne(p.ctx, refund)
 
 	if err != nil {
@@ -585,6 +777,141 @@ func (p *createRefundProcessor) processOrder() error {
 	return nil
 }
 
+// processRefundItems checks that requested items and quantities can be refunded, picks keys of key products
+// to revoke and calculates amount of refund as part of order charge amount proportional to prices of items.
+func (p *createRefundProcessor) processRefundItems() error {
+	order := p.checked.order
+	refundedQuantity, refundedKeys, err := p.getRefundedItems(order)
+
+	if err != nil {
+		return newBillingServerResponseError(pkg.ResponseStatusSystemError, refundErrorUnknown)
+	}
+
+	var keys []*billing.Key
+
+	if order.ProductType == billing.OrderType_key {
+		keys, err = p.service.keyRepository.FindByOrderId(p.ctx, order.Id)
+
+		if err != nil {
+			return newBillingServerResponseError(pkg.ResponseStatusSystemError, refundErrorUnknown)
+		}
+	}
+
+	itemsAmount := float64(0)
+
+	for _, v := range p.request.Items {
+		var orderItem *billing.OrderItem
+		quantity := int32(0)
+
+		for _, item := range order.Items {
+			if item.Id == v.ItemId {
+				orderItem = item
+				quantity++
+			}
+		}
+
+		if orderItem == nil {
+			return newBillingServerResponseError(pkg.ResponseStatusBadData, refundErrorItemNotFound)
+		}
+
+		if refundedQuantity[v.ItemId]+v.Quantity > quantity {
+			return newBillingServerResponseError(pkg.ResponseStatusBadData, refundErrorItemQuantityExceed)
+		}
+
+		refundedQuantity[v.ItemId] += v.Quantity
+		item := &billing.RefundItem{
+			ItemId:   v.ItemId,
+			Quantity: v.Quantity,
+			Amount:   tools.FormatAmount(orderItem.Amount * float64(v.Quantity)),
+			Currency: orderItem.Currency,
+		}
+
+		if order.ProductType == billing.OrderType_key {
+			for _, key := range keys {
+				if int32(len(item.KeyIds)) >= v.Quantity {
+					break
+				}
+
+				if key.KeyProductId != v.ItemId || key.RefundId != "" || refundedKeys[key.Id] {
+					continue
+				}
+
+				refundedKeys[key.Id] = true
+				item.KeyIds = append(item.KeyIds, key.Id)
+			}
+
+			if int32(len(item.KeyIds)) < v.Quantity {
+				return newBillingServerResponseError(pkg.ResponseStatusBadData, refundErrorItemKeysNotFound)
+			}
+		}
+
+		itemsAmount += item.Amount
+		p.checked.items = append(p.checked.items, item)
+	}
+
+	p.request.Amount = itemsAmount
+
+	if order.OrderAmount > 0 {
+		p.request.Amount = tools.FormatAmount(order.ChargeAmount * itemsAmount / order.OrderAmount)
+	}
+
+	if p.request.Amount > order.ChargeAmount {
+		p.request.Amount = order.ChargeAmount
+	}
+
+	return nil
+}
+
+// getRefundedItems returns refunded quantities of order items and keys of key products
+// picked by not rejected refunds of order
+func (p *createRefundProcessor) getRefundedItems(order *billing.Order) (map[string]int32, map[string]bool, error) {
+	oid, _ := primitive.ObjectIDFromHex(order.Id)
+	query := bson.M{
+		"status":            bson.M{"$nin": []int32{pkg.RefundStatusRejected}},
+		"original_order.id": oid,
+		"items.0":           bson.M{"$exists": true},
+	}
+	cursor, err := p.service.db.Collection(collectionRefund).Find(p.ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionRefund),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, nil, err
+	}
+
+	var refunds []*billing.Refund
+	err = cursor.All(p.ctx, &refunds)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionRefund),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, nil, err
+	}
+
+	quantity := make(map[string]int32)
+	keys := make(map[string]bool)
+
+	for _, refund := range refunds {
+		for _, item := range refund.Items {
+			quantity[item.ItemId] += item.Quantity
+
+			for _, keyId := range item.KeyIds {
+				keys[keyId] = true
+			}
+		}
+	}
+
+	return quantity, keys, nil
+}
+
 func (p *createRefundProcessor) processRefundsByOrder() error {
 	refundedAmount, err := p.getRefundedAmount(p.checked.order)
 
@@ -592,14 +919,35 @@ func (p *createRefundProcessor) processRefundsByOrder() error {
 		return newBillingServerResponseError(pkg.ResponseStatusBadData, refundErrorUnknown)
 	}
 
-	if p.checked.order.ChargeAmount < (refundedAmount + p.request.Amount) {
+	if getAmountInMinorUnits(p.checked.order.ChargeAmount) < getAmountInMinorUnits(refundedAmount+p.request.Amount) {
 		return newBillingServerResponseError(pkg.ResponseStatusBadData, refundErrorPaymentAmountLess)
 	}
 
 	return nil
 }
 
+// Amounts of refunds is sum of floats and can't be compared with order amount directly,
+// so both amounts compares in minor units of currency.
+func isRefundAmountReached(chargeAmount, refundedAmount float64) bool {
+	return getAmountInMinorUnits(refundedAmount) >= getAmountInMinorUnits(chargeAmount)
+}
+
+func getAmountInMinorUnits(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// getRefundedAmount returns amount of all not rejected refunds of order, including refunds in progress,
+// to prevent creating of refunds over amount of order
 func (p *createRefundProcessor) getRefundedAmount(order *billing.Order) (float64, error) {
+	return p.getRefundsAmount(order, bson.M{"$nin": []int32{pkg.RefundStatusRejected}})
+}
+
+// getCompletedRefundsAmount returns amount of refunds of order completed by payment system
+func (p *createRefundProcessor) getCompletedRefundsAmount(order *billing.Order) (float64, error) {
+	return p.getRefundsAmount(order, pkg.RefundStatusCompleted)
+}
+
+func (p *createRefundProcessor) getRefundsAmount(order *billing.Order, status interface{}) (float64, error) {
 	var res struct {
 		Id     primitive.ObjectID `bson:"_id"`
 		Amount float64            `bson:"amount"`
@@ -609,7 +957,7 @@ func (p *createRefundProcessor) getRefundedAmount(order *billing.Order) (float64
 	query := []bson.M{
 		{
 			"$match": bson.M{
-				"status":            bson.M{"$nin": []int32{pkg.RefundStatusRejected}},
+				"status":            status,
 				"original_order.id": oid,
 			},
 		},
@@ -0,0 +1,439 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	curPkg "github.com/paysuper/paysuper-currencies/pkg"
+	"github.com/paysuper/paysuper-currencies/pkg/proto/currencies"
+	"github.com/paysuper/paysuper-recurring-repository/tools"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"strings"
+)
+
+const (
+	collectionRefundApprovalThreshold = "refund_approval_threshold"
+)
+
+var (
+	errorRefundApprovalThresholdNotFound = newBillingServerErrorMsg("rfa00001", "refund approval threshold not found")
+	errorRefundApprovalAccessDenied      = newBillingServerErrorMsg("rfa00002", "refunds can be approved by merchant owner or financial manager only")
+	errorRefundApprovalNotPending        = newBillingServerErrorMsg("rfa00003", "refund is not waiting for approval")
+	errorRefundApprovalProjectNotFound   = newBillingServerErrorMsg("rfa00004", "project of refund approval threshold not found")
+)
+
+type RefundApprovalThresholdRepositoryInterface interface {
+	// Upsert add or update threshold of merchant or project
+	Upsert(ctx context.Context, threshold *billing.RefundApprovalThreshold) error
+
+	// GetById returns threshold by id
+	GetById(ctx context.Context, id string) (*billing.RefundApprovalThreshold, error)
+
+	// GetByMerchantId returns all thresholds of merchant
+	GetByMerchantId(ctx context.Context, merchantId string) ([]*billing.RefundApprovalThreshold, error)
+
+	// GetByScope returns threshold of project, if project id is empty returns threshold of merchant
+	GetByScope(ctx context.Context, merchantId, projectId string) (*billing.RefundApprovalThreshold, error)
+
+	// Delete removes threshold
+	Delete(ctx context.Context, threshold *billing.RefundApprovalThreshold) error
+}
+
+func newRefundApprovalThresholdRepository(svc *Service) RefundApprovalThresholdRepositoryInterface {
+	s := &RefundApprovalThreshold{svc: svc}
+	return s
+}
+
+func (s *Service) SetRefundApprovalThreshold(
+	ctx context.Context,
+	req *grpc.SetRefundApprovalThresholdRequest,
+	rsp *grpc.RefundApprovalThresholdResponse,
+) error {
+	if _, msg := s.checkRefundApprover(ctx, req.MerchantId, req.UserId); msg != nil {
+		rsp.Status = pkg.ResponseStatusForbidden
+		rsp.Message = msg
+		return nil
+	}
+
+	if req.ProjectId != "" {
+		project, err := s.project.GetById(ctx, req.ProjectId)
+
+		if err != nil || project.MerchantId != req.MerchantId {
+			rsp.Status = pkg.ResponseStatusNotFound
+			rsp.Message = errorRefundApprovalProjectNotFound
+			return nil
+		}
+	}
+
+	threshold, err := s.refundApprovalThreshold.GetByScope(ctx, req.MerchantId, req.ProjectId)
+
+	if err != nil {
+		if err != errorRefundApprovalThresholdNotFound {
+			return err
+		}
+
+		threshold = &billing.RefundApprovalThreshold{
+			Id:         primitive.NewObjectID().Hex(),
+			MerchantId: req.MerchantId,
+			ProjectId:  req.ProjectId,
+			CreatedAt:  ptypes.TimestampNow(),
+		}
+	}
+
+	threshold.Amount = req.Amount
+	threshold.Currency = strings.ToUpper(req.Currency)
+	threshold.UpdatedAt = ptypes.TimestampNow()
+
+	if err = s.refundApprovalThreshold.Upsert(ctx, threshold); err != nil {
+		return err
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = threshold
+
+	return nil
+}
+
+func (s *Service) GetRefundApprovalThresholds(
+	ctx context.Context,
+	req *grpc.GetRefundApprovalThresholdsRequest,
+	rsp *grpc.GetRefundApprovalThresholdsResponse,
+) (err error) {
+	rsp.Items, err = s.refundApprovalThreshold.GetByMerchantId(ctx, req.MerchantId)
+
+	if err != nil {
+		return err
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	return nil
+}
+
+func (s *Service) DeleteRefundApprovalThreshold(
+	ctx context.Context,
+	req *grpc.DeleteRefundApprovalThresholdRequest,
+	rsp *grpc.EmptyResponseWithStatus,
+) error {
+	if _, msg := s.checkRefundApprover(ctx, req.MerchantId, req.UserId); msg != nil {
+		rsp.Status = pkg.ResponseStatusForbidden
+		rsp.Message = msg
+		return nil
+	}
+
+	threshold, err := s.refundApprovalThreshold.GetById(ctx, req.Id)
+
+	if err != nil || threshold.MerchantId != req.MerchantId {
+		rsp.Status = pkg.ResponseStatusNotFound
+		rsp.Message = errorRefundApprovalThresholdNotFound
+		return nil
+	}
+
+	if err = s.refundApprovalThreshold.Delete(ctx, threshold); err != nil {
+		return err
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	return nil
+}
+
+func (s *Service) ApproveRefund(
+	ctx context.Context,
+	req *grpc.RefundDecisionRequest,
+	rsp *grpc.CreateRefundResponse,
+) error {
+	refund, order, ok := s.processRefundDecision(ctx, req, pkg.RefundApprovalStatusApproved, pkg.RefundStatusCreated, rsp)
+
+	if !ok {
+		return nil
+	}
+
+	return s.sendRefundToPaymentSystem(ctx, order, refund, rsp)
+}
+
+func (s *Service) RejectRefund(
+	ctx context.Context,
+	req *grpc.RefundDecisionRequest,
+	rsp *grpc.CreateRefundResponse,
+) error {
+	refund, _, ok := s.processRefundDecision(ctx, req, pkg.RefundApprovalStatusRejected, pkg.RefundStatusRejected, rsp)
+
+	if !ok {
+		return nil
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = refund
+
+	return nil
+}
+
+// processRefundDecision checks that user can make decision about refund waiting for approval, records decision
+// in refund approval and saves refund with new status. Decision is saved before any request to payment system,
+// refund which decision was already made by another request isn't changed.
+func (s *Service) processRefundDecision(
+	ctx context.Context,
+	req *grpc.RefundDecisionRequest,
+	decision string,
+	status int32,
+	rsp *grpc.CreateRefundResponse,
+) (*billing.Refund, *billing.Order, bool) {
+	role, msg := s.checkRefundApprover(ctx, req.MerchantId, req.UserId)
+
+	if msg != nil {
+		rsp.Status = pkg.ResponseStatusForbidden
+		rsp.Message = msg
+		return nil, nil, false
+	}
+
+	refund, err := s.getRefundById(ctx, req.RefundId)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusNotFound
+		rsp.Message = refundErrorNotFound
+		return nil, nil, false
+	}
+
+	order, err := s.getOrderById(ctx, refund.OriginalOrder.Id)
+
+	if err != nil || order.GetMerchantId() != req.MerchantId {
+		rsp.Status = pkg.ResponseStatusNotFound
+		rsp.Message = refundErrorNotFound
+		return nil, nil, false
+	}
+
+	if refund.Status != pkg.RefundStatusPendingApproval || refund.Approval == nil {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = errorRefundApprovalNotPending
+		return nil, nil, false
+	}
+
+	refund.Approval.Status = decision
+	refund.Approval.UserId = req.UserId
+	refund.Approval.UserRole = role
+	refund.Approval.Reason = req.Reason
+	refund.Approval.DecidedAt = ptypes.TimestampNow()
+	refund.Status = status
+	refund.UpdatedAt = ptypes.TimestampNow()
+
+	oid, _ := primitive.ObjectIDFromHex(refund.Id)
+	filter := bson.M{"_id": oid, "status": pkg.RefundStatusPendingApproval}
+	res, err := s.db.Collection(collectionRefund).ReplaceOne(ctx, filter, refund)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionRefund),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationUpdate),
+			zap.Any(pkg.ErrorDatabaseFieldDocument, refund),
+		)
+
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = refundErrorUnknown
+		return nil, nil, false
+	}
+
+	if res.MatchedCount <= 0 {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = errorRefundApprovalNotPending
+		return nil, nil, false
+	}
+
+	return refund, order, true
+}
+
+// checkRefundApprover returns role of user if user is owner of merchant or financial manager of system
+func (s *Service) checkRefundApprover(ctx context.Context, merchantId, userId string) (string, *grpc.ResponseErrorMessage) {
+	user, err := s.userRoleRepository.GetMerchantUserByUserId(ctx, merchantId, userId)
+
+	if err == nil && user.Role == pkg.RoleMerchantOwner {
+		return user.Role, nil
+	}
+
+	user, err = s.userRoleRepository.GetAdminUserByUserId(ctx, userId)
+
+	if err == nil && user.Role == pkg.RoleSystemFinancial {
+		return user.Role, nil
+	}
+
+	return "", errorRefundApprovalAccessDenied
+}
+
+// getRefundApproval returns pending approval if refund amount greater than threshold of project or merchant,
+// threshold of project has priority over threshold of merchant
+func (s *Service) getRefundApproval(
+	ctx context.Context,
+	order *billing.Order,
+	refund *billing.Refund,
+) (*billing.RefundApproval, error) {
+	threshold, err := s.refundApprovalThreshold.GetByScope(ctx, order.GetMerchantId(), order.GetProjectId())
+
+	if err == errorRefundApprovalThresholdNotFound {
+		threshold, err = s.refundApprovalThreshold.GetByScope(ctx, order.GetMerchantId(), "")
+	}
+
+	if err != nil {
+		if err == errorRefundApprovalThresholdNotFound {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	amount := refund.Amount
+
+	if refund.Currency != threshold.Currency {
+		req := &currencies.ExchangeCurrencyCurrentCommonRequest{
+			From:              refund.Currency,
+			To:                threshold.Currency,
+			RateType:          curPkg.RateTypePaysuper,
+			Amount:            refund.Amount,
+			ExchangeDirection: curPkg.ExchangeDirectionSell,
+		}
+		rsp, err := s.curService.ExchangeCurrencyCurrentCommon(ctx, req)
+
+		if err != nil {
+			zap.L().Error(
+				pkg.ErrorGrpcServiceCallFailed,
+				zap.Error(err),
+				zap.String(errorFieldService, "CurrencyRatesService"),
+				zap.String(errorFieldMethod, "ExchangeCurrencyCurrentCommon"),
+				zap.Any(errorFieldRequest, req),
+			)
+			return nil, err
+		}
+
+		amount = tools.FormatAmount(rsp.ExchangedAmount)
+	}
+
+	if amount <= threshold.Amount {
+		return nil, nil
+	}
+
+	approval := &billing.RefundApproval{
+		Status:            pkg.RefundApprovalStatusPending,
+		ThresholdAmount:   threshold.Amount,
+		ThresholdCurrency: threshold.Currency,
+	}
+
+	return approval, nil
+}
+
+func (h *RefundApprovalThreshold) Upsert(ctx context.Context, threshold *billing.RefundApprovalThreshold) error {
+	oid, _ := primitive.ObjectIDFromHex(threshold.Id)
+	filter := bson.M{"_id": oid}
+	opts := options.Replace().SetUpsert(true)
+	_, err := h.svc.db.Collection(collectionRefundApprovalThreshold).ReplaceOne(ctx, filter, threshold, opts)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionRefundApprovalThreshold),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationUpsert),
+			zap.Any(pkg.ErrorDatabaseFieldDocument, threshold),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func (h *RefundApprovalThreshold) GetById(ctx context.Context, id string) (*billing.RefundApprovalThreshold, error) {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	return h.findOne(ctx, bson.M{"_id": oid})
+}
+
+func (h *RefundApprovalThreshold) GetByScope(
+	ctx context.Context,
+	merchantId, projectId string,
+) (*billing.RefundApprovalThreshold, error) {
+	merchantOid, _ := primitive.ObjectIDFromHex(merchantId)
+	projectOid := primitive.NilObjectID
+
+	if projectId != "" {
+		projectOid, _ = primitive.ObjectIDFromHex(projectId)
+	}
+
+	return h.findOne(ctx, bson.M{"merchant_id": merchantOid, "project_id": projectOid})
+}
+
+func (h *RefundApprovalThreshold) GetByMerchantId(
+	ctx context.Context,
+	merchantId string,
+) ([]*billing.RefundApprovalThreshold, error) {
+	oid, _ := primitive.ObjectIDFromHex(merchantId)
+	query := bson.M{"merchant_id": oid}
+	cursor, err := h.svc.db.Collection(collectionRefundApprovalThreshold).Find(ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionRefundApprovalThreshold),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	var result []*billing.RefundApprovalThreshold
+	err = cursor.All(ctx, &result)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionRefundApprovalThreshold),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (h *RefundApprovalThreshold) Delete(ctx context.Context, threshold *billing.RefundApprovalThreshold) error {
+	oid, _ := primitive.ObjectIDFromHex(threshold.Id)
+	filter := bson.M{"_id": oid}
+	_, err := h.svc.db.Collection(collectionRefundApprovalThreshold).DeleteOne(ctx, filter)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionRefundApprovalThreshold),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationDelete),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, filter),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func (h *RefundApprovalThreshold) findOne(ctx context.Context, filter bson.M) (*billing.RefundApprovalThreshold, error) {
+	threshold := &billing.RefundApprovalThreshold{}
+	err := h.svc.db.Collection(collectionRefundApprovalThreshold).FindOne(ctx, filter).Decode(threshold)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errorRefundApprovalThresholdNotFound
+		}
+
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionRefundApprovalThreshold),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, filter),
+		)
+		return nil, err
+	}
+
+	return threshold, nil
+}
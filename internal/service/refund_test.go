@@ -14,6 +14,7 @@ import (
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
 	"github.com/paysuper/paysuper-recurring-repository/pkg/constant"
+	"github.com/paysuper/paysuper-recurring-repository/tools"
 	reportingMocks "github.com/paysuper/paysuper-reporter/pkg/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -22,6 +23,7 @@ import (
 	"go.uber.org/zap"
 	"gopkg.in/ProtocolONE/rabbitmq.v1/pkg"
 	mongodb "gopkg.in/paysuper/paysuper-database-mongo.v1"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -456,7 +458,7 @@ func (suite *RefundTestSuite) SetupTest() {
 		mocks.NewRepositoryServiceOk(),
 		mocks.NewTaxServiceOkMock(),
 		broker,
-		nil,
+		redisdb,
 		suite.cache,
 		mocks.NewCurrencyServiceMockOk(),
 		mocks.NewDocumentSignerMockOk(),
@@ -3081,3 +3083,481 @@ func (suite *RefundTestSuite) TestRefund_ProcessRefundCallback_OrderFullyRefunde
 		}
 	}
 }
+
+func (suite *RefundTestSuite) TestRefund_CreateRefund_AboveThreshold_PendingApproval() {
+	owner := suite.addRefundApprover(pkg.RoleMerchantOwner)
+	suite.setRefundApprovalThreshold(owner.UserId, "", 5)
+	orderUuid := suite.createPaidOrderForRefund()
+
+	req := &grpc.CreateRefundRequest{
+		OrderId:    orderUuid,
+		Amount:     10,
+		CreatorId:  primitive.NewObjectID().Hex(),
+		Reason:     "unit test",
+		MerchantId: suite.project.MerchantId,
+	}
+	rsp := &grpc.CreateRefundResponse{}
+	err := suite.service.CreateRefund(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Equal(suite.T(), pkg.RefundStatusPendingApproval, rsp.Item.Status)
+	assert.Empty(suite.T(), rsp.Item.ExternalId)
+	assert.NotNil(suite.T(), rsp.Item.Approval)
+	assert.Equal(suite.T(), pkg.RefundApprovalStatusPending, rsp.Item.Approval.Status)
+	assert.EqualValues(suite.T(), 5, rsp.Item.Approval.ThresholdAmount)
+
+	refund, err := suite.service.getRefundById(context.TODO(), rsp.Item.Id)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.RefundStatusPendingApproval, refund.Status)
+	assert.Equal(suite.T(), pkg.RefundApprovalStatusPending, refund.Approval.Status)
+
+	req.Amount = 3
+	req.IdempotencyKey = primitive.NewObjectID().Hex()
+	rsp = &grpc.CreateRefundResponse{}
+	err = suite.service.CreateRefund(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Equal(suite.T(), pkg.RefundStatusInProgress, rsp.Item.Status)
+	assert.Nil(suite.T(), rsp.Item.Approval)
+}
+
+func (suite *RefundTestSuite) TestRefund_CreateRefund_ProjectThresholdOverridesMerchant() {
+	owner := suite.addRefundApprover(pkg.RoleMerchantOwner)
+	suite.setRefundApprovalThreshold(owner.UserId, "", 5)
+	suite.setRefundApprovalThreshold(owner.UserId, suite.project.Id, 50)
+	orderUuid := suite.createPaidOrderForRefund()
+
+	req := &grpc.CreateRefundRequest{
+		OrderId:    orderUuid,
+		Amount:     10,
+		CreatorId:  primitive.NewObjectID().Hex(),
+		Reason:     "unit test",
+		MerchantId: suite.project.MerchantId,
+	}
+	rsp := &grpc.CreateRefundResponse{}
+	err := suite.service.CreateRefund(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Equal(suite.T(), pkg.RefundStatusInProgress, rsp.Item.Status)
+
+	rsp1 := &grpc.GetRefundApprovalThresholdsResponse{}
+	err = suite.service.GetRefundApprovalThresholds(
+		context.TODO(),
+		&grpc.GetRefundApprovalThresholdsRequest{MerchantId: suite.project.MerchantId},
+		rsp1,
+	)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp1.Status)
+	assert.Len(suite.T(), rsp1.Items, 2)
+}
+
+func (suite *RefundTestSuite) TestRefund_ApproveRefund_Ok() {
+	owner := suite.addRefundApprover(pkg.RoleMerchantOwner)
+	suite.setRefundApprovalThreshold(owner.UserId, "", 5)
+	refund := suite.createPendingRefund()
+
+	req := &grpc.RefundDecisionRequest{
+		UserId:     owner.UserId,
+		MerchantId: suite.project.MerchantId,
+		RefundId:   refund.Id,
+		Reason:     "customer confirmed by support",
+	}
+	rsp := &grpc.CreateRefundResponse{}
+	err := suite.service.ApproveRefund(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Equal(suite.T(), pkg.RefundStatusInProgress, rsp.Item.Status)
+	assert.NotEmpty(suite.T(), rsp.Item.ExternalId)
+
+	refund, err = suite.service.getRefundById(context.TODO(), refund.Id)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.RefundStatusInProgress, refund.Status)
+	assert.Equal(suite.T(), pkg.RefundApprovalStatusApproved, refund.Approval.Status)
+	assert.Equal(suite.T(), owner.UserId, refund.Approval.UserId)
+	assert.Equal(suite.T(), pkg.RoleMerchantOwner, refund.Approval.UserRole)
+	assert.Equal(suite.T(), req.Reason, refund.Approval.Reason)
+	assert.NotNil(suite.T(), refund.Approval.DecidedAt)
+
+	rsp = &grpc.CreateRefundResponse{}
+	err = suite.service.ApproveRefund(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), errorRefundApprovalNotPending, rsp.Message)
+}
+
+func (suite *RefundTestSuite) TestRefund_ApproveRefund_PaymentSystemError_DecisionSaved() {
+	owner := suite.addRefundApprover(pkg.RoleMerchantOwner)
+	suite.setRefundApprovalThreshold(owner.UserId, "", 5)
+	refund := suite.createPendingRefund()
+
+	suite.paySys.Handler = "mock_error"
+	err := suite.service.paymentSystem.Update(context.TODO(), suite.paySys)
+	assert.NoError(suite.T(), err)
+
+	req := &grpc.RefundDecisionRequest{
+		UserId:     owner.UserId,
+		MerchantId: suite.project.MerchantId,
+		RefundId:   refund.Id,
+		Reason:     "customer confirmed by support",
+	}
+	rsp := &grpc.CreateRefundResponse{}
+	err = suite.service.ApproveRefund(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+
+	refund, err = suite.service.getRefundById(context.TODO(), refund.Id)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.RefundStatusCreated, refund.Status)
+	assert.Equal(suite.T(), pkg.RefundApprovalStatusApproved, refund.Approval.Status)
+	assert.Equal(suite.T(), owner.UserId, refund.Approval.UserId)
+	assert.Equal(suite.T(), req.Reason, refund.Approval.Reason)
+	assert.NotNil(suite.T(), refund.Approval.DecidedAt)
+}
+
+func (suite *RefundTestSuite) TestRefund_RejectRefund_Ok() {
+	owner := suite.addRefundApprover(pkg.RoleMerchantOwner)
+	suite.setRefundApprovalThreshold(owner.UserId, "", 5)
+	refund := suite.createPendingRefund()
+	financial := suite.addRefundApprover(pkg.RoleSystemFinancial)
+
+	req := &grpc.RefundDecisionRequest{
+		UserId:     financial.UserId,
+		MerchantId: suite.project.MerchantId,
+		RefundId:   refund.Id,
+		Reason:     "suspicious refund",
+	}
+	rsp := &grpc.CreateRefundResponse{}
+	err := suite.service.RejectRefund(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Equal(suite.T(), pkg.RefundStatusRejected, rsp.Item.Status)
+
+	refund, err = suite.service.getRefundById(context.TODO(), refund.Id)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.RefundStatusRejected, refund.Status)
+	assert.Empty(suite.T(), refund.ExternalId)
+	assert.Equal(suite.T(), pkg.RefundApprovalStatusRejected, refund.Approval.Status)
+	assert.Equal(suite.T(), financial.UserId, refund.Approval.UserId)
+	assert.Equal(suite.T(), pkg.RoleSystemFinancial, refund.Approval.UserRole)
+	assert.Equal(suite.T(), req.Reason, refund.Approval.Reason)
+}
+
+func (suite *RefundTestSuite) TestRefund_ApproveRefund_AccessDenied_Error() {
+	owner := suite.addRefundApprover(pkg.RoleMerchantOwner)
+	suite.setRefundApprovalThreshold(owner.UserId, "", 5)
+	refund := suite.createPendingRefund()
+	developer := suite.addRefundApprover(pkg.RoleMerchantDeveloper)
+
+	req := &grpc.RefundDecisionRequest{
+		UserId:     developer.UserId,
+		MerchantId: suite.project.MerchantId,
+		RefundId:   refund.Id,
+		Reason:     "unit test",
+	}
+	rsp := &grpc.CreateRefundResponse{}
+	err := suite.service.ApproveRefund(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusForbidden, rsp.Status)
+	assert.Equal(suite.T(), errorRefundApprovalAccessDenied, rsp.Message)
+
+	refund, err = suite.service.getRefundById(context.TODO(), refund.Id)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.RefundStatusPendingApproval, refund.Status)
+}
+
+func (suite *RefundTestSuite) addRefundApprover(role string) *billing.UserRole {
+	user := &billing.UserRole{
+		Id:     primitive.NewObjectID().Hex(),
+		UserId: primitive.NewObjectID().Hex(),
+		Role:   role,
+	}
+	var err error
+
+	if role == pkg.RoleSystemFinancial {
+		err = suite.service.userRoleRepository.AddAdminUser(context.TODO(), user)
+	} else {
+		user.MerchantId = suite.project.MerchantId
+		err = suite.service.userRoleRepository.AddMerchantUser(context.TODO(), user)
+	}
+
+	if err != nil {
+		suite.FailNow("Insert user role test data failed", "%v", err)
+	}
+
+	return user
+}
+
+func (suite *RefundTestSuite) setRefundApprovalThreshold(userId, projectId string, amount float64) {
+	req := &grpc.SetRefundApprovalThresholdRequest{
+		UserId:     userId,
+		MerchantId: suite.project.MerchantId,
+		ProjectId:  projectId,
+		Amount:     amount,
+		Currency:   "RUB",
+	}
+	rsp := &grpc.RefundApprovalThresholdResponse{}
+	err := suite.service.SetRefundApprovalThreshold(context.TODO(), req, rsp)
+
+	if err != nil || rsp.Status != pkg.ResponseStatusOk {
+		suite.FailNow("Set refund approval threshold failed", "%v %v", err, rsp.Message)
+	}
+}
+
+func (suite *RefundTestSuite) createPendingRefund() *billing.Refund {
+	req := &grpc.CreateRefundRequest{
+		OrderId:    suite.createPaidOrderForRefund(),
+		Amount:     10,
+		CreatorId:  primitive.NewObjectID().Hex(),
+		Reason:     "unit test",
+		MerchantId: suite.project.MerchantId,
+	}
+	rsp := &grpc.CreateRefundResponse{}
+	err := suite.service.CreateRefund(context.TODO(), req, rsp)
+
+	if err != nil || rsp.Status != pkg.ResponseStatusOk || rsp.Item.Status != pkg.RefundStatusPendingApproval {
+		suite.FailNow("Create pending refund failed", "%v %v", err, rsp.Message)
+	}
+
+	return rsp.Item
+}
+
+func (suite *RefundTestSuite) createPaidOrderForRefund() string {
+	req := &billing.OrderCreateRequest{
+		Type:        billing.OrderType_simple,
+		ProjectId:   suite.project.Id,
+		Currency:    "RUB",
+		Amount:      100,
+		Account:     "unit test",
+		Description: "unit test",
+		OrderId:     primitive.NewObjectID().Hex(),
+		User: &billing.OrderUser{
+			Email: "some_email@unit.com",
+			Ip:    "127.0.0.1",
+			Phone: "123456789",
+		},
+	}
+
+	rsp0 := &grpc.OrderCreateProcessResponse{}
+	err := suite.service.OrderCreateProcess(context.TODO(), req, rsp0)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp0.Status)
+
+	createPaymentRequest := &grpc.PaymentCreateRequest{
+		Data: map[string]string{
+			pkg.PaymentCreateFieldOrderId:         rsp0.Item.Uuid,
+			pkg.PaymentCreateFieldPaymentMethodId: suite.pmBankCard.Id,
+			pkg.PaymentCreateFieldEmail:           "test@unit.unit",
+			pkg.PaymentCreateFieldPan:             "4000000000000002",
+			pkg.PaymentCreateFieldCvv:             "123",
+			pkg.PaymentCreateFieldMonth:           "02",
+			pkg.PaymentCreateFieldYear:            time.Now().AddDate(1, 0, 0).Format("2006"),
+			pkg.PaymentCreateFieldHolder:          "Mr. Card Holder",
+		},
+	}
+
+	rsp1 := &grpc.PaymentCreateResponse{}
+	err = suite.service.PaymentCreateProcess(context.TODO(), createPaymentRequest, rsp1)
+	assert.NoError(suite.T(), err)
+
+	order, err := suite.service.getOrderById(context.TODO(), rsp0.Item.Id)
+	assert.NoError(suite.T(), err)
+
+	order.PrivateStatus = constant.OrderStatusPaymentSystemComplete
+	order.Tax = &billing.OrderTax{
+		Type:     taxTypeVat,
+		Rate:     20,
+		Amount:   10,
+		Currency: "RUB",
+	}
+	err = suite.service.updateOrder(context.TODO(), order)
+	assert.NoError(suite.T(), err)
+
+	return order.Uuid
+}
+
+func (suite *RefundTestSuite) TestRefund_CreateRefund_Items_Ok() {
+	order, productIds, keys := suite.createKeyOrderForRefund()
+
+	req := &grpc.CreateRefundRequest{
+		OrderId:    order.Uuid,
+		CreatorId:  primitive.NewObjectID().Hex(),
+		Reason:     "unit test",
+		MerchantId: order.GetMerchantId(),
+		Items:      []*grpc.CreateRefundItem{{ItemId: productIds[0], Quantity: 1}},
+	}
+	rsp := &grpc.CreateRefundResponse{}
+	err := suite.service.CreateRefund(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Equal(suite.T(), tools.FormatAmount(order.ChargeAmount*0.25), rsp.Item.Amount)
+	assert.Len(suite.T(), rsp.Item.Items, 1)
+	assert.EqualValues(suite.T(), 1, rsp.Item.Items[0].Quantity)
+	assert.Equal(suite.T(), []string{keys[0].Id}, rsp.Item.Items[0].KeyIds)
+
+	refund := helperProcessRefundCallback(suite.Suite, suite.service, order, rsp.Item)
+
+	key, err := suite.service.keyRepository.GetById(context.TODO(), keys[0].Id)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), refund.Id, key.RefundId)
+	assert.NotNil(suite.T(), key.RevokedAt)
+
+	for _, v := range keys[1:] {
+		key, err = suite.service.keyRepository.GetById(context.TODO(), v.Id)
+		assert.NoError(suite.T(), err)
+		assert.Empty(suite.T(), key.RefundId)
+	}
+
+	refundOrder, err := suite.service.getOrderById(context.TODO(), refund.CreatedOrderId)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), refundOrder.Items, 1)
+	assert.Equal(suite.T(), productIds[0], refundOrder.Items[0].Id)
+	assert.Equal(suite.T(), refundOrder.OrderAmount, refundOrder.Items[0].Amount)
+}
+
+func (suite *RefundTestSuite) TestRefund_CreateRefund_ItemQuantityExceed_Error() {
+	order, productIds, _ := suite.createKeyOrderForRefund()
+
+	req := &grpc.CreateRefundRequest{
+		OrderId:    order.Uuid,
+		CreatorId:  primitive.NewObjectID().Hex(),
+		Reason:     "unit test",
+		MerchantId: order.GetMerchantId(),
+		Items:      []*grpc.CreateRefundItem{{ItemId: productIds[0], Quantity: 2}},
+	}
+	rsp := &grpc.CreateRefundResponse{}
+	err := suite.service.CreateRefund(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Len(suite.T(), rsp.Item.Items[0].KeyIds, 2)
+
+	req.Items = []*grpc.CreateRefundItem{{ItemId: productIds[0], Quantity: 1}}
+	err = suite.service.CreateRefund(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), refundErrorItemQuantityExceed, rsp.Message)
+
+	req.Items = []*grpc.CreateRefundItem{{ItemId: primitive.NewObjectID().Hex(), Quantity: 1}}
+	err = suite.service.CreateRefund(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), refundErrorItemNotFound, rsp.Message)
+}
+
+func (suite *RefundTestSuite) TestRefund_ProcessRefundCallback_FullRefund_RevokeAllKeys_Ok() {
+	order, _, keys := suite.createKeyOrderForRefund()
+	refund := helperMakeRefund(suite.Suite, suite.service, order, order.ChargeAmount, false)
+	assert.Empty(suite.T(), refund.Items)
+
+	for _, v := range keys {
+		key, err := suite.service.keyRepository.GetById(context.TODO(), v.Id)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), refund.Id, key.RefundId)
+	}
+}
+
+func (suite *RefundTestSuite) TestRefund_ProcessRefundCallback_PendingRefund_KeysNotRevoked() {
+	order, productIds, keys := suite.createKeyOrderForRefund()
+
+	req := &grpc.CreateRefundRequest{
+		OrderId:    order.Uuid,
+		CreatorId:  primitive.NewObjectID().Hex(),
+		Reason:     "unit test",
+		MerchantId: order.GetMerchantId(),
+		Items:      []*grpc.CreateRefundItem{{ItemId: productIds[1], Quantity: 1}},
+	}
+	rsp := &grpc.CreateRefundResponse{}
+	err := suite.service.CreateRefund(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.NotEqual(suite.T(), pkg.RefundStatusCompleted, rsp.Item.Status)
+
+	req.Items = []*grpc.CreateRefundItem{{ItemId: productIds[0], Quantity: 2}}
+	err = suite.service.CreateRefund(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	// together refunds cover whole order, but only the second one is completed
+	refund := helperProcessRefundCallback(suite.Suite, suite.service, order, rsp.Item)
+
+	for _, v := range keys[:2] {
+		key, err := suite.service.keyRepository.GetById(context.TODO(), v.Id)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), refund.Id, key.RefundId)
+	}
+
+	key, err := suite.service.keyRepository.GetById(context.TODO(), keys[2].Id)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), key.RefundId)
+	assert.Nil(suite.T(), key.RevokedAt)
+}
+
+func (suite *RefundTestSuite) TestRefund_isRefundAmountReached() {
+	assert.True(suite.T(), isRefundAmountReached(0.3, 0.1+0.2))
+	assert.True(suite.T(), isRefundAmountReached(100, 33.33+33.33+33.34))
+	assert.False(suite.T(), isRefundAmountReached(100, 99.99))
+}
+
+func (suite *RefundTestSuite) TestRefund_ProcessRefundCallback_PendingRefundNotCounted_Ok() {
+	order := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.pmBankCard)
+	half := tools.FormatAmount(order.ChargeAmount / 2)
+
+	req := &grpc.CreateRefundRequest{
+		OrderId:    order.Uuid,
+		Amount:     half,
+		CreatorId:  primitive.NewObjectID().Hex(),
+		Reason:     "unit test",
+		MerchantId: order.GetMerchantId(),
+	}
+	rsp := &grpc.CreateRefundResponse{}
+	err := suite.service.CreateRefund(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.NotEqual(suite.T(), pkg.RefundStatusCompleted, rsp.Item.Status)
+
+	helperMakeRefund(suite.Suite, suite.service, order, order.ChargeAmount-half, false)
+
+	order, err = suite.service.getOrderById(context.TODO(), order.Id)
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), order.Refunded)
+	assert.Equal(suite.T(), int32(constant.OrderStatusPaymentSystemComplete), order.PrivateStatus)
+}
+
+// createKeyOrderForRefund creates paid order of two key products, where first product bought twice
+func (suite *RefundTestSuite) createKeyOrderForRefund() (*billing.Order, []string, []*billing.Key) {
+	order := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.pmBankCard)
+	productIds := []string{primitive.NewObjectID().Hex(), primitive.NewObjectID().Hex()}
+	shares := []float64{0.25, 0.25, 0.5}
+	var keys []*billing.Key
+
+	order.ProductType = billing.OrderType_key
+	order.Items = []*billing.OrderItem{}
+	order.Keys = []string{}
+
+	for i, productId := range []string{productIds[0], productIds[0], productIds[1]} {
+		order.Items = append(order.Items, &billing.OrderItem{
+			Id:       productId,
+			Name:     "Key product " + productId,
+			Amount:   order.OrderAmount * shares[i],
+			Currency: order.Currency,
+		})
+
+		key := &billing.Key{
+			Id:           primitive.NewObjectID().Hex(),
+			Code:         "code-" + strconv.Itoa(i),
+			KeyProductId: productId,
+			PlatformId:   "steam",
+			OrderId:      order.Id,
+			RedeemedAt:   ptypes.TimestampNow(),
+		}
+		err := suite.service.keyRepository.Insert(context.TODO(), key)
+		assert.NoError(suite.T(), err)
+
+		keys = append(keys, key)
+		order.Keys = append(order.Keys, key.Id)
+	}
+
+	err := suite.service.updateOrder(context.TODO(), order)
+	assert.NoError(suite.T(), err)
+
+	return order, productIds, keys
+}
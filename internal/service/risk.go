@@ -0,0 +1,618 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/constant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+const (
+	collectionRiskRules = "risk_rules"
+
+	cacheKeyRiskRulesActive = "risk_rule:active"
+)
+
+var (
+	errorRiskRuleNotFound        = newBillingServerErrorMsg("rsk00001", "risk rule not found")
+	errorRiskRuleAccessDenied    = newBillingServerErrorMsg("rsk00002", "risk rules can be managed by risk manager or administrator only")
+	errorRiskRuleParamsInvalid   = newBillingServerErrorMsg("rsk00003", "risk rule params are invalid for the rule type")
+	errorRiskReviewOrderNotFound = newBillingServerErrorMsg("rsk00004", "order with specified identifier not found")
+	errorRiskReviewNotAllowed    = newBillingServerErrorMsg("rsk00005", "payment of order isn't waiting for risk review or isn't authorized yet")
+
+	riskRuleManagerRoles = []string{pkg.RoleSystemAdmin, pkg.RoleSystemRiskManager}
+
+	// severity of risk outcomes, the most severe outcome of triggered rules is the outcome of payment
+	riskOutcomeSeverity = map[string]int{
+		pkg.RiskOutcomeAllow:  0,
+		pkg.RiskOutcomeReview: 1,
+		pkg.RiskOutcomeDeny:   2,
+	}
+
+	// statuses of orders which amounts used to calculate average payment amount of project
+	riskAmountOutlierStatuses = []int32{
+		constant.OrderStatusPaymentSystemComplete,
+		constant.OrderStatusProjectComplete,
+	}
+)
+
+type RiskRuleServiceInterface interface {
+	GetById(ctx context.Context, id string) (*billing.RiskRule, error)
+	GetAll(ctx context.Context) ([]*billing.RiskRule, error)
+	GetActive(ctx context.Context) ([]*billing.RiskRule, error)
+	Upsert(ctx context.Context, rule *billing.RiskRule) error
+	Delete(ctx context.Context, rule *billing.RiskRule) error
+}
+
+func newRiskRuleService(svc *Service) RiskRuleServiceInterface {
+	s := &RiskRule{svc: svc}
+	return s
+}
+
+func (s *Service) GetRiskRules(
+	ctx context.Context,
+	req *grpc.GetRiskRulesRequest,
+	rsp *grpc.GetRiskRulesResponse,
+) (err error) {
+	if msg := s.checkRiskRuleManager(ctx, req.UserId); msg != nil {
+		rsp.Status = pkg.ResponseStatusForbidden
+		rsp.Message = msg
+		return nil
+	}
+
+	rsp.Items, err = s.riskRule.GetAll(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	return nil
+}
+
+func (s *Service) SetRiskRule(
+	ctx context.Context,
+	req *grpc.SetRiskRuleRequest,
+	rsp *grpc.SetRiskRuleResponse,
+) (err error) {
+	if msg := s.checkRiskRuleManager(ctx, req.UserId); msg != nil {
+		rsp.Status = pkg.ResponseStatusForbidden
+		rsp.Message = msg
+		return nil
+	}
+
+	if req.Rule == nil {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = errorRiskRuleParamsInvalid
+		return nil
+	}
+
+	rule := &billing.RiskRule{
+		Id:        primitive.NewObjectID().Hex(),
+		CreatedAt: ptypes.TimestampNow(),
+	}
+
+	if req.Rule.Id != "" {
+		rule, err = s.riskRule.GetById(ctx, req.Rule.Id)
+
+		if err != nil {
+			rsp.Status = pkg.ResponseStatusNotFound
+			rsp.Message = errorRiskRuleNotFound
+			return nil
+		}
+	}
+
+	if !isRiskRuleParamsValid(req.Rule) {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = errorRiskRuleParamsInvalid
+		return nil
+	}
+
+	rule.Name = req.Rule.Name
+	rule.Type = req.Rule.Type
+	rule.Score = req.Rule.Score
+	rule.Action = req.Rule.Action
+	rule.MaxCount = req.Rule.MaxCount
+	rule.Period = req.Rule.Period
+	rule.AmountMultiplier = req.Rule.AmountMultiplier
+	rule.Values = req.Rule.Values
+	rule.IsActive = req.Rule.IsActive
+	rule.UpdatedAt = ptypes.TimestampNow()
+
+	if err = s.riskRule.Upsert(ctx, rule); err != nil {
+		return err
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = rule
+
+	return nil
+}
+
+func (s *Service) DeleteRiskRule(
+	ctx context.Context,
+	req *grpc.DeleteRiskRuleRequest,
+	rsp *grpc.EmptyResponseWithStatus,
+) error {
+	if msg := s.checkRiskRuleManager(ctx, req.UserId); msg != nil {
+		rsp.Status = pkg.ResponseStatusForbidden
+		rsp.Message = msg
+		return nil
+	}
+
+	rule, err := s.riskRule.GetById(ctx, req.Id)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusNotFound
+		rsp.Message = errorRiskRuleNotFound
+		return nil
+	}
+
+	if err = s.riskRule.Delete(ctx, rule); err != nil {
+		return err
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	return nil
+}
+
+// ReviewOrderRisk saves decision of risk manager about payment held for risk review. Approved payment is
+// captured if it was authorized by risk engine and stays authorized for merchant otherwise, rejected payment is voided.
+func (s *Service) ReviewOrderRisk(
+	ctx context.Context,
+	req *grpc.ReviewOrderRiskRequest,
+	rsp *grpc.ReviewOrderRiskResponse,
+) error {
+	if msg := s.checkRiskRuleManager(ctx, req.UserId); msg != nil {
+		rsp.Status = pkg.ResponseStatusForbidden
+		rsp.Message = msg
+		return nil
+	}
+
+	order, err := s.getOrderByUuid(ctx, req.OrderId)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusNotFound
+		rsp.Message = errorRiskReviewOrderNotFound
+		return nil
+	}
+
+	if !order.IsRiskReviewPending() || !order.CanBeCaptured() {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = errorRiskReviewNotAllowed
+		return nil
+	}
+
+	order.Risk.ReviewStatus = pkg.RiskReviewStatusRejected
+
+	if req.Approve {
+		order.Risk.ReviewStatus = pkg.RiskReviewStatusApproved
+	}
+
+	order.Risk.ReviewedBy = req.UserId
+	order.Risk.ReviewReason = req.Reason
+	order.Risk.ReviewedAt = ptypes.TimestampNow()
+
+	// decision saved before request to payment system to not lose it if the request will fail
+	if err = s.updateOrder(ctx, order); err != nil {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = orderErrorUnknown
+		return nil
+	}
+
+	if req.Approve && order.Risk.IsPaymentHeld {
+		err = s.capturePayment(ctx, order, order.AuthorizedAmount)
+	} else if !req.Approve {
+		err = s.voidPayment(ctx, order)
+	}
+
+	if err != nil {
+		zap.L().Error(pkg.MethodFinishedWithError, zap.Error(err), zap.String("order_uuid", order.Uuid))
+
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = orderErrorUnknown
+
+		if e, ok := err.(*grpc.ResponseErrorMessage); ok {
+			rsp.Status = pkg.ResponseStatusBadData
+			rsp.Message = e
+		}
+
+		return nil
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = order
+
+	return nil
+}
+
+func (s *Service) checkRiskRuleManager(ctx context.Context, userId string) *grpc.ResponseErrorMessage {
+	user, err := s.userRoleRepository.GetAdminUserByUserId(ctx, userId)
+
+	if err != nil || !contains(riskRuleManagerRoles, user.Role) {
+		return errorRiskRuleAccessDenied
+	}
+
+	return nil
+}
+
+func isRiskRuleParamsValid(rule *billing.RiskRule) bool {
+	switch rule.Type {
+	case pkg.RiskRuleTypeVelocityCustomer, pkg.RiskRuleTypeVelocityCard, pkg.RiskRuleTypeVelocityIp:
+		return rule.MaxCount > 0 && rule.Period > 0
+	case pkg.RiskRuleTypeAmountOutlier:
+		return rule.AmountMultiplier > 0 && rule.Period > 0
+	case pkg.RiskRuleTypeDenyEmail, pkg.RiskRuleTypeDenyIp, pkg.RiskRuleTypeDenyBin:
+		return len(rule.Values) > 0
+	}
+
+	return true
+}
+
+// scoreOrderRisk checks payment of order by active risk rules and saves score, outcome and triggered rules
+// to the order. Outcome of payment is the most severe action of triggered rules, but it raised to review
+// or deny if total score of triggered rules reached the thresholds from configuration.
+func (s *Service) scoreOrderRisk(ctx context.Context, order *billing.Order, data map[string]string, ip string) error {
+	rules, err := s.riskRule.GetActive(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	risk := &billing.OrderRisk{
+		Outcome:   pkg.RiskOutcomeAllow,
+		Rules:     []*billing.OrderRiskRule{},
+		CardToken: s.getRiskCardToken(data),
+		Ip:        ip,
+		ScoredAt:  ptypes.TimestampNow(),
+	}
+
+	for _, rule := range rules {
+		triggered, err := s.isRiskRuleTriggered(ctx, rule, order, risk)
+
+		if err != nil {
+			return err
+		}
+
+		if !triggered {
+			continue
+		}
+
+		risk.Rules = append(risk.Rules, &billing.OrderRiskRule{
+			Id:     rule.Id,
+			Name:   rule.Name,
+			Type:   rule.Type,
+			Score:  rule.Score,
+			Action: rule.Action,
+		})
+		risk.Score += rule.Score
+
+		if riskOutcomeSeverity[rule.Action] > riskOutcomeSeverity[risk.Outcome] {
+			risk.Outcome = rule.Action
+		}
+	}
+
+	if s.cfg.RiskDenyScore > 0 && risk.Score >= s.cfg.RiskDenyScore {
+		risk.Outcome = pkg.RiskOutcomeDeny
+	} else if s.cfg.RiskReviewScore > 0 && risk.Score >= s.cfg.RiskReviewScore &&
+		risk.Outcome == pkg.RiskOutcomeAllow {
+		risk.Outcome = pkg.RiskOutcomeReview
+	}
+
+	order.Risk = risk
+
+	return nil
+}
+
+// holdOrderPaymentForRiskReview makes payment of order authorize only until decision of risk manager.
+// Payment which merchant requested as authorization stays authorized after approval of review.
+func holdOrderPaymentForRiskReview(order *billing.Order) {
+	order.Risk.ReviewStatus = pkg.RiskReviewStatusPending
+
+	if !order.IsPreAuthorization {
+		order.IsPreAuthorization = true
+		order.Risk.IsPaymentHeld = true
+	}
+}
+
+func (s *Service) isRiskRuleTriggered(
+	ctx context.Context,
+	rule *billing.RiskRule,
+	order *billing.Order,
+	risk *billing.OrderRisk,
+) (bool, error) {
+	switch rule.Type {
+	case pkg.RiskRuleTypeVelocityCustomer:
+		if order.User == nil || order.User.Id == "" {
+			return false, nil
+		}
+		return s.isRiskVelocityExceeded(ctx, rule, order, "user.id", order.User.Id)
+	case pkg.RiskRuleTypeVelocityCard:
+		if risk.CardToken == "" {
+			return false, nil
+		}
+		return s.isRiskVelocityExceeded(ctx, rule, order, "risk.card_token", risk.CardToken)
+	case pkg.RiskRuleTypeVelocityIp:
+		if risk.Ip == "" {
+			return false, nil
+		}
+		return s.isRiskVelocityExceeded(ctx, rule, order, "risk.ip", risk.Ip)
+	case pkg.RiskRuleTypeBinCountryMismatch:
+		return order.IsIpCountryMismatchBin, nil
+	case pkg.RiskRuleTypeAmountOutlier:
+		return s.isRiskAmountOutlier(ctx, rule, order)
+	case pkg.RiskRuleTypeDenyEmail:
+		if order.User == nil || order.User.Email == "" {
+			return false, nil
+		}
+		return isRiskValueDenied(rule, order.User.Email), nil
+	case pkg.RiskRuleTypeDenyIp:
+		return risk.Ip != "" && isRiskValueDenied(rule, risk.Ip), nil
+	case pkg.RiskRuleTypeDenyBin:
+		pan := order.PaymentRequisites[pkg.PaymentCreateFieldPan]
+
+		if len(pan) < 6 {
+			return false, nil
+		}
+		return isRiskValueDenied(rule, pan[:6]), nil
+	}
+
+	return false, nil
+}
+
+// isRiskVelocityExceeded checks that count of other payments scored during period of rule
+// with the same value of field reached maximal count of the rule.
+func (s *Service) isRiskVelocityExceeded(
+	ctx context.Context,
+	rule *billing.RiskRule,
+	order *billing.Order,
+	field, value string,
+) (bool, error) {
+	oid, _ := primitive.ObjectIDFromHex(order.Id)
+	query := bson.M{
+		"_id":            bson.M{"$ne": oid},
+		field:            value,
+		"risk.scored_at": bson.M{"$gte": time.Now().Add(-time.Duration(rule.Period) * time.Second)},
+	}
+	count, err := s.db.Collection(collectionOrder).CountDocuments(ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionOrder),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationCount),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return false, err
+	}
+
+	return count >= int64(rule.MaxCount), nil
+}
+
+// isRiskAmountOutlier checks that order amount greater than average amount of project payments
+// in the same currency during period of rule multiplied on amount multiplier of rule.
+func (s *Service) isRiskAmountOutlier(ctx context.Context, rule *billing.RiskRule, order *billing.Order) (bool, error) {
+	projectOid, _ := primitive.ObjectIDFromHex(order.GetProjectId())
+	query := []bson.M{
+		{
+			"$match": bson.M{
+				"project._id":    projectOid,
+				"currency":       order.Currency,
+				"private_status": bson.M{"$in": riskAmountOutlierStatuses},
+				"created_at":     bson.M{"$gte": time.Now().Add(-time.Duration(rule.Period) * time.Second)},
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id":    nil,
+				"amount": bson.M{"$avg": "$total_payment_amount"},
+			},
+		},
+	}
+	cursor, err := s.db.Collection(collectionOrder).Aggregate(ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionOrder),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return false, err
+	}
+
+	var result []struct {
+		Amount float64 `bson:"amount"`
+	}
+	err = cursor.All(ctx, &result)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionOrder),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return false, err
+	}
+
+	if len(result) <= 0 || result[0].Amount <= 0 {
+		return false, nil
+	}
+
+	return order.TotalPaymentAmount > result[0].Amount*rule.AmountMultiplier, nil
+}
+
+func isRiskValueDenied(rule *billing.RiskRule, value string) bool {
+	for _, v := range rule.Values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getRiskCardToken returns keyed hash of bank card number from payment form data or stored card identifier
+// if payment made by saved card. Bank card number itself must not be saved.
+func (s *Service) getRiskCardToken(data map[string]string) string {
+	if pan := data[pkg.PaymentCreateFieldPan]; pan != "" {
+		mac := hmac.New(sha256.New, []byte(s.cfg.RiskCardTokenSecret))
+		mac.Write([]byte(pan))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	return data[pkg.PaymentCreateFieldStoredCardId]
+}
+
+func (h *RiskRule) GetById(ctx context.Context, id string) (*billing.RiskRule, error) {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	filter := bson.M{"_id": oid}
+
+	rule := &billing.RiskRule{}
+	err := h.svc.db.Collection(collectionRiskRules).FindOne(ctx, filter).Decode(rule)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errorRiskRuleNotFound
+		}
+
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionRiskRules),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, filter),
+		)
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+func (h *RiskRule) GetAll(ctx context.Context) ([]*billing.RiskRule, error) {
+	return h.find(ctx, bson.M{})
+}
+
+func (h *RiskRule) GetActive(ctx context.Context) ([]*billing.RiskRule, error) {
+	var result []*billing.RiskRule
+
+	if err := h.svc.cacher.Get(cacheKeyRiskRulesActive, &result); err == nil {
+		return result, nil
+	}
+
+	result, err := h.find(ctx, bson.M{"is_active": true})
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = h.svc.cacher.Set(cacheKeyRiskRulesActive, result, 0)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorCacheQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorCacheFieldCmd, "SET"),
+			zap.String(pkg.ErrorCacheFieldKey, cacheKeyRiskRulesActive),
+			zap.Any(pkg.ErrorCacheFieldData, result),
+		)
+	}
+
+	return result, nil
+}
+
+func (h *RiskRule) Upsert(ctx context.Context, rule *billing.RiskRule) error {
+	oid, _ := primitive.ObjectIDFromHex(rule.Id)
+	filter := bson.M{"_id": oid}
+	opts := options.Replace().SetUpsert(true)
+	_, err := h.svc.db.Collection(collectionRiskRules).ReplaceOne(ctx, filter, rule, opts)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionRiskRules),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationUpsert),
+			zap.Any(pkg.ErrorDatabaseFieldDocument, rule),
+		)
+		return err
+	}
+
+	h.deleteCache()
+
+	return nil
+}
+
+func (h *RiskRule) Delete(ctx context.Context, rule *billing.RiskRule) error {
+	oid, _ := primitive.ObjectIDFromHex(rule.Id)
+	filter := bson.M{"_id": oid}
+	_, err := h.svc.db.Collection(collectionRiskRules).DeleteOne(ctx, filter)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionRiskRules),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationDelete),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, filter),
+		)
+		return err
+	}
+
+	h.deleteCache()
+
+	return nil
+}
+
+func (h *RiskRule) find(ctx context.Context, query bson.M) ([]*billing.RiskRule, error) {
+	cursor, err := h.svc.db.Collection(collectionRiskRules).Find(ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionRiskRules),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	var result []*billing.RiskRule
+	err = cursor.All(ctx, &result)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionRiskRules),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (h *RiskRule) deleteCache() {
+	if err := h.svc.cacher.Delete(cacheKeyRiskRulesActive); err != nil {
+		zap.L().Error(
+			pkg.ErrorCacheQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorCacheFieldCmd, "DELETE"),
+			zap.String(pkg.ErrorCacheFieldKey, cacheKeyRiskRulesActive),
+		)
+	}
+}
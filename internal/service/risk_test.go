@@ -0,0 +1,369 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/protobuf/ptypes"
+	casbinMocks "github.com/paysuper/casbin-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/internal/config"
+	"github.com/paysuper/paysuper-billing-server/internal/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/constant"
+	reportingMocks "github.com/paysuper/paysuper-reporter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	mongodb "gopkg.in/paysuper/paysuper-database-mongo.v1"
+	"testing"
+)
+
+type RiskTestSuite struct {
+	suite.Suite
+	service *Service
+	cache   CacheInterface
+
+	riskManager *billing.UserRole
+}
+
+func Test_Risk(t *testing.T) {
+	suite.Run(t, new(RiskTestSuite))
+}
+
+func (suite *RiskTestSuite) SetupTest() {
+	cfg, err := config.NewConfig()
+	if err != nil {
+		suite.FailNow("Config load failed", "%v", err)
+	}
+
+	db, err := mongodb.NewDatabase()
+	if err != nil {
+		suite.FailNow("Database connection failed", "%v", err)
+	}
+
+	redisdb := mocks.NewTestRedis()
+	suite.cache, err = NewCacheRedis(redisdb, "cache")
+	suite.service = NewBillingService(
+		db,
+		cfg,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		suite.cache,
+		mocks.NewCurrencyServiceMockOk(),
+		mocks.NewDocumentSignerMockOk(),
+		&reportingMocks.ReporterService{},
+		mocks.NewFormatterOK(),
+		mocks.NewBrokerMockOk(),
+		&casbinMocks.CasbinService{},
+	)
+
+	if err := suite.service.Init(); err != nil {
+		suite.FailNow("Billing service initialization failed", "%v", err)
+	}
+
+	suite.riskManager = &billing.UserRole{
+		Id:     primitive.NewObjectID().Hex(),
+		UserId: primitive.NewObjectID().Hex(),
+		Role:   pkg.RoleSystemRiskManager,
+	}
+	err = suite.service.userRoleRepository.AddAdminUser(context.TODO(), suite.riskManager)
+
+	if err != nil {
+		suite.FailNow("Insert risk manager test data failed", "%v", err)
+	}
+}
+
+func (suite *RiskTestSuite) TearDownTest() {
+	err := suite.service.db.Drop()
+
+	if err != nil {
+		suite.FailNow("Database deletion failed", "%v", err)
+	}
+}
+
+func (suite *RiskTestSuite) TestRisk_SetRiskRule_Ok() {
+	req := &grpc.SetRiskRuleRequest{
+		UserId: suite.riskManager.UserId,
+		Rule: &billing.RiskRule{
+			Name:     "Deny emails",
+			Type:     pkg.RiskRuleTypeDenyEmail,
+			Score:    100,
+			Action:   pkg.RiskOutcomeDeny,
+			Values:   []string{"fraud@unit.test"},
+			IsActive: true,
+		},
+	}
+	rsp := &grpc.SetRiskRuleResponse{}
+	err := suite.service.SetRiskRule(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.NotEmpty(suite.T(), rsp.Item.Id)
+
+	req.Rule.Id = rsp.Item.Id
+	req.Rule.IsActive = false
+	err = suite.service.SetRiskRule(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	rsp1 := &grpc.GetRiskRulesResponse{}
+	err = suite.service.GetRiskRules(context.TODO(), &grpc.GetRiskRulesRequest{UserId: suite.riskManager.UserId}, rsp1)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp1.Status)
+	assert.Len(suite.T(), rsp1.Items, 1)
+	assert.False(suite.T(), rsp1.Items[0].IsActive)
+
+	rules, err := suite.service.riskRule.GetActive(context.TODO())
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), rules)
+
+	rsp2 := &grpc.EmptyResponseWithStatus{}
+	req2 := &grpc.DeleteRiskRuleRequest{UserId: suite.riskManager.UserId, Id: rsp.Item.Id}
+	err = suite.service.DeleteRiskRule(context.TODO(), req2, rsp2)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp2.Status)
+
+	err = suite.service.DeleteRiskRule(context.TODO(), req2, rsp2)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusNotFound, rsp2.Status)
+	assert.Equal(suite.T(), errorRiskRuleNotFound, rsp2.Message)
+}
+
+func (suite *RiskTestSuite) TestRisk_SetRiskRule_AccessDenied_Error() {
+	req := &grpc.SetRiskRuleRequest{
+		UserId: primitive.NewObjectID().Hex(),
+		Rule: &billing.RiskRule{
+			Name:   "Deny emails",
+			Type:   pkg.RiskRuleTypeDenyEmail,
+			Action: pkg.RiskOutcomeDeny,
+			Values: []string{"fraud@unit.test"},
+		},
+	}
+	rsp := &grpc.SetRiskRuleResponse{}
+	err := suite.service.SetRiskRule(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusForbidden, rsp.Status)
+	assert.Equal(suite.T(), errorRiskRuleAccessDenied, rsp.Message)
+}
+
+func (suite *RiskTestSuite) TestRisk_SetRiskRule_ParamsInvalid_Error() {
+	req := &grpc.SetRiskRuleRequest{
+		UserId: suite.riskManager.UserId,
+		Rule: &billing.RiskRule{
+			Name:   "Card velocity",
+			Type:   pkg.RiskRuleTypeVelocityCard,
+			Action: pkg.RiskOutcomeReview,
+		},
+	}
+	rsp := &grpc.SetRiskRuleResponse{}
+	err := suite.service.SetRiskRule(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), errorRiskRuleParamsInvalid, rsp.Message)
+}
+
+func (suite *RiskTestSuite) TestRisk_SetRiskRule_RuleEmpty_Error() {
+	req := &grpc.SetRiskRuleRequest{UserId: suite.riskManager.UserId}
+	rsp := &grpc.SetRiskRuleResponse{}
+	err := suite.service.SetRiskRule(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), errorRiskRuleParamsInvalid, rsp.Message)
+}
+
+func (suite *RiskTestSuite) TestRisk_ScoreOrderRisk_DenyEmail() {
+	suite.insertRiskRule(&billing.RiskRule{
+		Name:   "Deny emails",
+		Type:   pkg.RiskRuleTypeDenyEmail,
+		Score:  10,
+		Action: pkg.RiskOutcomeDeny,
+		Values: []string{"fraud@unit.test"},
+	})
+
+	order := suite.getRiskOrder()
+	order.User.Email = "FRAUD@unit.test"
+	err := suite.service.scoreOrderRisk(context.TODO(), order, map[string]string{}, "127.0.0.1")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.RiskOutcomeDeny, order.Risk.Outcome)
+	assert.EqualValues(suite.T(), 10, order.Risk.Score)
+	assert.Len(suite.T(), order.Risk.Rules, 1)
+
+	order.User.Email = "customer@unit.test"
+	err = suite.service.scoreOrderRisk(context.TODO(), order, map[string]string{}, "127.0.0.1")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.RiskOutcomeAllow, order.Risk.Outcome)
+	assert.Empty(suite.T(), order.Risk.Rules)
+}
+
+func (suite *RiskTestSuite) TestRisk_ScoreOrderRisk_VelocityCard_Review() {
+	suite.insertRiskRule(&billing.RiskRule{
+		Name:     "Card velocity",
+		Type:     pkg.RiskRuleTypeVelocityCard,
+		Score:    suite.service.cfg.RiskReviewScore,
+		Action:   pkg.RiskOutcomeAllow,
+		MaxCount: 2,
+		Period:   3600,
+	})
+
+	data := map[string]string{pkg.PaymentCreateFieldPan: "4000000000000002"}
+
+	for i := 0; i < 3; i++ {
+		order := suite.getRiskOrder()
+		err := suite.service.scoreOrderRisk(context.TODO(), order, data, "127.0.0.1")
+		assert.NoError(suite.T(), err)
+		assert.NotEqual(suite.T(), data[pkg.PaymentCreateFieldPan], order.Risk.CardToken)
+
+		if i < 2 {
+			assert.Equal(suite.T(), pkg.RiskOutcomeAllow, order.Risk.Outcome)
+		} else {
+			assert.Equal(suite.T(), pkg.RiskOutcomeReview, order.Risk.Outcome)
+			assert.Len(suite.T(), order.Risk.Rules, 1)
+		}
+
+		_, err = suite.service.db.Collection(collectionOrder).InsertOne(context.TODO(), order)
+		assert.NoError(suite.T(), err)
+	}
+}
+
+func (suite *RiskTestSuite) insertRiskRule(rule *billing.RiskRule) {
+	rule.Id = primitive.NewObjectID().Hex()
+	rule.IsActive = true
+	rule.CreatedAt = ptypes.TimestampNow()
+	rule.UpdatedAt = ptypes.TimestampNow()
+
+	if err := suite.service.riskRule.Upsert(context.TODO(), rule); err != nil {
+		suite.FailNow("Insert risk rule test data failed", "%v", err)
+	}
+}
+
+func (suite *RiskTestSuite) getRiskOrder() *billing.Order {
+	return &billing.Order{
+		Id:   primitive.NewObjectID().Hex(),
+		Uuid: primitive.NewObjectID().Hex(),
+		Project: &billing.ProjectOrder{
+			Id:         primitive.NewObjectID().Hex(),
+			MerchantId: primitive.NewObjectID().Hex(),
+		},
+		User: &billing.OrderUser{
+			Id:    primitive.NewObjectID().Hex(),
+			Email: "customer@unit.test",
+		},
+		Currency:           "RUB",
+		TotalPaymentAmount: 100,
+		CreatedAt:          ptypes.TimestampNow(),
+		UpdatedAt:          ptypes.TimestampNow(),
+	}
+}
+
+func (suite *RiskTestSuite) TestRisk_ReviewOrderRisk_Approve_Ok() {
+	order := suite.getHeldRiskOrder()
+
+	req := &grpc.ReviewOrderRiskRequest{
+		UserId:  suite.riskManager.UserId,
+		OrderId: order.Uuid,
+		Approve: true,
+		Reason:  "customer confirmed payment",
+	}
+	rsp := &grpc.ReviewOrderRiskResponse{}
+	err := suite.service.ReviewOrderRisk(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	order, err = suite.service.getOrderByUuid(context.TODO(), order.Uuid)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.RiskReviewStatusApproved, order.Risk.ReviewStatus)
+	assert.Equal(suite.T(), req.UserId, order.Risk.ReviewedBy)
+	assert.Equal(suite.T(), req.Reason, order.Risk.ReviewReason)
+	assert.NotNil(suite.T(), order.Risk.ReviewedAt)
+	assert.EqualValues(suite.T(), order.AuthorizedAmount, order.CapturedAmount)
+	assert.Nil(suite.T(), order.VoidedAt)
+}
+
+func (suite *RiskTestSuite) TestRisk_ReviewOrderRisk_Reject_Ok() {
+	order := suite.getHeldRiskOrder()
+
+	req := &grpc.ReviewOrderRiskRequest{
+		UserId:  suite.riskManager.UserId,
+		OrderId: order.Uuid,
+		Reason:  "stolen card",
+	}
+	rsp := &grpc.ReviewOrderRiskResponse{}
+	err := suite.service.ReviewOrderRisk(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	order, err = suite.service.getOrderByUuid(context.TODO(), order.Uuid)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.RiskReviewStatusRejected, order.Risk.ReviewStatus)
+	assert.NotNil(suite.T(), order.VoidedAt)
+	assert.Zero(suite.T(), order.CapturedAmount)
+}
+
+func (suite *RiskTestSuite) TestRisk_ReviewOrderRisk_NotPending_Error() {
+	order := suite.getHeldRiskOrder()
+	order.Risk.ReviewStatus = pkg.RiskReviewStatusApproved
+	err := suite.service.updateOrder(context.TODO(), order)
+	assert.NoError(suite.T(), err)
+
+	req := &grpc.ReviewOrderRiskRequest{
+		UserId:  suite.riskManager.UserId,
+		OrderId: order.Uuid,
+		Reason:  "stolen card",
+	}
+	rsp := &grpc.ReviewOrderRiskResponse{}
+	err = suite.service.ReviewOrderRisk(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), errorRiskReviewNotAllowed, rsp.Message)
+}
+
+func (suite *RiskTestSuite) TestRisk_CapturePayment_ReviewPending_Error() {
+	order := suite.getHeldRiskOrder()
+
+	req := &grpc.CapturePaymentRequest{
+		OrderId:    order.Uuid,
+		MerchantId: order.GetMerchantId(),
+	}
+	rsp := &grpc.CapturePaymentResponse{}
+	err := suite.service.CapturePayment(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), paymentAuthorizationErrorRiskReview, rsp.Message)
+}
+
+// getHeldRiskOrder returns order which payment authorized by risk engine and waits for risk review
+func (suite *RiskTestSuite) getHeldRiskOrder() *billing.Order {
+	ps := &billing.PaymentSystem{
+		Id:                 primitive.NewObjectID().Hex(),
+		Name:               "MockOk",
+		AccountingCurrency: "RUB",
+		AccountingPeriod:   "every-day",
+		IsActive:           true,
+		Handler:            paymentSystemHandlerMockOk,
+	}
+
+	if err := suite.service.paymentSystem.Insert(context.TODO(), ps); err != nil {
+		suite.FailNow("Insert payment system test data failed", "%v", err)
+	}
+
+	order := suite.getRiskOrder()
+	order.PrivateStatus = pkg.OrderStatusPaymentSystemAuthorized
+	order.AuthorizedAmount = order.TotalPaymentAmount
+	order.PaymentMethod = &billing.PaymentMethodOrder{
+		Id:              primitive.NewObjectID().Hex(),
+		Name:            "Bank card",
+		PaymentSystemId: ps.Id,
+		Group:           constant.PaymentSystemGroupAliasBankCard,
+		Handler:         ps.Handler,
+	}
+	order.Risk = &billing.OrderRisk{Outcome: pkg.RiskOutcomeReview, ScoredAt: ptypes.TimestampNow()}
+	holdOrderPaymentForRiskReview(order)
+
+	if _, err := suite.service.db.Collection(collectionOrder).InsertOne(context.TODO(), order); err != nil {
+		suite.FailNow("Insert order test data failed", "%v", err)
+	}
+
+	return order
+}
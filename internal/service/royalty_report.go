@@ -42,17 +42,23 @@ const (
 var (
 	royaltyReportErrorNoTransactions = "no transactions for the period"
 
-	royaltyReportErrorReportNotFound           = newBillingServerErrorMsg("rr00001", "royalty report with specified identifier not found")
-	royaltyReportErrorReportStatusChangeDenied = newBillingServerErrorMsg("rr00002", "change royalty report to new status denied")
-	royaltyReportErrorCorrectionReasonRequired = newBillingServerErrorMsg("rr00003", "correction reason required")
-	royaltyReportEntryErrorUnknown             = newBillingServerErrorMsg("rr00004", "unknown error. try request later")
-	royaltyReportUpdateBalanceError            = newBillingServerErrorMsg("rr00005", "update balance failed")
-	royaltyReportErrorEndOfPeriodIsInFuture    = newBillingServerErrorMsg("rr00006", "end of royalty report period is in future")
-	royaltyReportErrorTimezoneIncorrect        = newBillingServerErrorMsg("rr00007", "incorrect time zone")
-	royaltyReportErrorAlreadyExists            = newBillingServerErrorMsg("rr00008", "report for this merchant and period already exists")
-	royaltyReportErrorCorrectionAmountRequired = newBillingServerErrorMsg("rr00009", "correction amount required and must be not zero")
-	royaltyReportErrorPayoutDocumentIdInvalid  = newBillingServerErrorMsg("rr00010", "payout document id is invalid")
-	royaltyReportErrorNotOwnedByMerchant       = newBillingServerErrorMsg("rr00011", "payout document is not owned by merchant")
+	royaltyReportErrorReportNotFound                = newBillingServerErrorMsg("rr00001", "royalty report with specified identifier not found")
+	royaltyReportErrorReportStatusChangeDenied      = newBillingServerErrorMsg("rr00002", "change royalty report to new status denied")
+	royaltyReportErrorCorrectionReasonRequired      = newBillingServerErrorMsg("rr00003", "correction reason required")
+	royaltyReportEntryErrorUnknown                  = newBillingServerErrorMsg("rr00004", "unknown error. try request later")
+	royaltyReportUpdateBalanceError                 = newBillingServerErrorMsg("rr00005", "update balance failed")
+	royaltyReportErrorEndOfPeriodIsInFuture         = newBillingServerErrorMsg("rr00006", "end of royalty report period is in future")
+	royaltyReportErrorTimezoneIncorrect             = newBillingServerErrorMsg("rr00007", "incorrect time zone")
+	royaltyReportErrorCorrectionAmountRequired      = newBillingServerErrorMsg("rr00009", "correction amount required and must be not zero")
+	royaltyReportErrorPayoutDocumentIdInvalid       = newBillingServerErrorMsg("rr00010", "payout document id is invalid")
+	royaltyReportErrorNotOwnedByMerchant            = newBillingServerErrorMsg("rr00011", "payout document is not owned by merchant")
+	royaltyReportErrorPeriodGap                     = newBillingServerErrorMsg("rr00012", "royalty report period is not adjacent to the previous report of merchant")
+	royaltyReportErrorPeriodOverlap                 = newBillingServerErrorMsg("rr00013", "royalty report period overlaps the previous report of merchant")
+	royaltyReportErrorDisputeItemInvalid            = newBillingServerErrorMsg("rr00014", "dispute item must refer to either an order or a product line of the report")
+	royaltyReportErrorDisputeItemNotFound           = newBillingServerErrorMsg("rr00015", "disputed order or product line not found in the report")
+	royaltyReportErrorDisputeNotOpen                = newBillingServerErrorMsg("rr00016", "royalty report dispute is not open")
+	royaltyReportErrorDisputeCorrectionItemNotFound = newBillingServerErrorMsg("rr00017", "dispute item for correction not found")
+	royaltyReportErrorDisputeItemAlreadyCorrected   = newBillingServerErrorMsg("rr00018", "dispute item already corrected")
 
 	orderStatusForRoyaltyReports = []string{
 		constant.OrderPublicStatusProcessed,
@@ -92,6 +98,7 @@ type RoyaltyReportServiceInterface interface {
 	GetByPayoutId(ctx context.Context, payoutId string) ([]*billing.RoyaltyReport, error)
 	GetBalanceAmount(ctx context.Context, merchantId, currency string) (float64, error)
 	CheckReportExists(ctx context.Context, merchantId, operatingCompanyId, currency string, from, to time.Time) (exists bool, err error)
+	GetLastByMerchantId(ctx context.Context, merchantId string) (*billing.RoyaltyReport, error)
 	SetPayoutDocumentId(ctx context.Context, reportIds []string, payoutDocumentId, ip, source string) (err error)
 	UnsetPayoutDocumentId(ctx context.Context, reportIds []string, ip, source string) (err error)
 	SetPaid(ctx context.Context, reportIds []string, payoutDocumentId, ip, source string) (err error)
@@ -110,19 +117,14 @@ func (s *Service) CreateRoyaltyReport(
 ) error {
 	zap.L().Info("start royalty reports processing")
 
-	loc, err := time.LoadLocation(s.cfg.RoyaltyReportTimeZone)
+	_, err := time.LoadLocation(s.cfg.RoyaltyReportTimeZone)
 
 	if err != nil {
 		zap.L().Error(royaltyReportErrorTimezoneIncorrect.Error(), zap.Error(err))
 		return royaltyReportErrorTimezoneIncorrect
 	}
 
-	to := now.Monday().In(loc).Add(time.Duration(s.cfg.RoyaltyReportPeriodEndHour) * time.Hour)
-	if to.After(time.Now().In(loc)) {
-		return royaltyReportErrorEndOfPeriodIsInFuture
-	}
-
-	from := to.Add(-time.Duration(s.cfg.RoyaltyReportPeriod) * time.Second).In(loc)
+	tNow := time.Now()
 
 	var merchants []*RoyaltyReportMerchant
 
@@ -137,7 +139,8 @@ func (s *Service) CreateRoyaltyReport(
 			merchants = append(merchants, &RoyaltyReportMerchant{Id: oid})
 		}
 	} else {
-		merchants = s.getRoyaltyReportMerchantsByPeriod(ctx, from, to)
+		from := tNow.AddDate(0, 0, -int(s.cfg.RoyaltyReportBackfillDays))
+		merchants = s.getRoyaltyReportMerchantsByPeriod(ctx, from, tNow)
 	}
 
 	if len(merchants) <= 0 {
@@ -145,29 +148,21 @@ func (s *Service) CreateRoyaltyReport(
 		return nil
 	}
 
-	var wg sync.WaitGroup
-	wg.Add(len(merchants))
+	var (
+		wg sync.WaitGroup
+		mx sync.Mutex
+	)
 
-	handler := &royaltyHandler{
-		Service: s,
-		from:    from,
-		to:      to,
-	}
+	wg.Add(len(merchants))
 
 	for _, v := range merchants {
 		go func(merchantId primitive.ObjectID) {
-			err := handler.createMerchantRoyaltyReport(ctx, merchantId)
+			count, err := s.createMerchantRoyaltyReports(ctx, merchantId, tNow)
 
-			if err == nil {
+			if err == nil && count > 0 {
+				mx.Lock()
 				rsp.Merchants = append(rsp.Merchants, merchantId.Hex())
-			} else {
-				zap.L().Error(
-					pkg.ErrorRoyaltyReportGenerationFailed,
-					zap.Error(err),
-					zap.String(pkg.ErrorRoyaltyReportFieldMerchantId, merchantId.Hex()),
-					zap.Any(pkg.ErrorRoyaltyReportFieldFrom, from),
-					zap.Any(pkg.ErrorRoyaltyReportFieldTo, to),
-				)
+				mx.Unlock()
 			}
 
 			wg.Done()
@@ -366,6 +361,14 @@ func (s *Service) MerchantReviewRoyaltyReport(
 		report.Status = pkg.RoyaltyReportStatusDispute
 		report.DisputeReason = req.DisputeReason
 		report.DisputeStartedAt = ptypes.TimestampNow()
+		report.Dispute = &billing.RoyaltyReportDispute{}
+
+		err = s.addRoyaltyReportDisputeMessage(report, pkg.RoyaltyReportChangeSourceMerchant, "", req.DisputeReason, nil)
+		if err != nil {
+			rsp.Status = pkg.ResponseStatusSystemError
+			rsp.Message = royaltyReportEntryErrorUnknown
+			return nil
+		}
 	}
 
 	report.UpdatedAt = ptypes.TimestampNow()
@@ -477,59 +480,15 @@ func (s *Service) ChangeRoyaltyReport(
 			return nil
 		}
 
-		from, err := ptypes.Timestamp(report.PeriodFrom)
-		if err != nil {
-			zap.L().Error("time conversion error", zap.Error(err))
-			rsp.Status = pkg.ResponseStatusSystemError
-			rsp.Message = royaltyReportEntryErrorUnknown
-			return nil
-		}
-		to, err := ptypes.Timestamp(report.PeriodTo)
+		_, err = s.createRoyaltyReportCorrection(ctx, report, req.Correction.Amount, req.Correction.Reason)
 		if err != nil {
-			zap.L().Error("time conversion error", zap.Error(err))
 			rsp.Status = pkg.ResponseStatusSystemError
 			rsp.Message = royaltyReportEntryErrorUnknown
 			return nil
 		}
 
-		reqAe := &grpc.CreateAccountingEntryRequest{
-			MerchantId: report.MerchantId,
-			Amount:     req.Correction.Amount,
-			Currency:   report.Currency,
-			Reason:     req.Correction.Reason,
-			Date:       to.Add(-1 * time.Second).Unix(),
-			Type:       pkg.AccountingEntryTypeMerchantRoyaltyCorrection,
-		}
-		resAe := &grpc.CreateAccountingEntryResponse{}
-		err = s.CreateAccountingEntry(ctx, reqAe, resAe)
-		if err != nil {
-			zap.L().Error("create correction accounting entry failed", zap.Error(err))
-			rsp.Status = pkg.ResponseStatusSystemError
-			rsp.Message = royaltyReportEntryErrorUnknown
-			return nil
-		}
-		if resAe.Status != pkg.ResponseStatusOk {
-			zap.L().Error("create correction accounting entry failed")
-			rsp.Status = pkg.ResponseStatusSystemError
-			rsp.Message = royaltyReportEntryErrorUnknown
-			return nil
-		}
-
-		if report.Totals == nil {
-			report.Totals = &billing.RoyaltyReportTotals{}
-		}
-		if report.Summary == nil {
-			report.Summary = &billing.RoyaltyReportSummary{}
-		}
-
-		handler := &royaltyHandler{
-			Service: s,
-			from:    from,
-			to:      to,
-		}
-		report.Summary.Corrections, report.Totals.CorrectionAmount, err = handler.getRoyaltyReportCorrections(ctx, report.MerchantId, report.OperatingCompanyId, report.Currency)
+		err = s.refreshRoyaltyReportCorrections(ctx, report)
 		if err != nil {
-			zap.L().Error("get royalty report corrections error", zap.Error(err))
 			rsp.Status = pkg.ResponseStatusSystemError
 			rsp.Message = royaltyReportEntryErrorUnknown
 			return nil
@@ -541,6 +500,10 @@ func (s *Service) ChangeRoyaltyReport(
 	if req.Status != "" && req.Status != report.Status {
 		if report.Status == pkg.RoyaltyReportStatusDispute {
 			report.DisputeClosedAt = ptypes.TimestampNow()
+
+			if report.Dispute != nil {
+				report.Dispute.Status = pkg.RoyaltyReportDisputeStatusClosed
+			}
 		}
 
 		if req.Status == pkg.RoyaltyReportStatusAccepted {
@@ -581,6 +544,72 @@ func (s *Service) ChangeRoyaltyReport(
 	return nil
 }
 
+// createRoyaltyReportCorrection creates merchant royalty correction accounting entry at the end of report period
+func (s *Service) createRoyaltyReportCorrection(
+	ctx context.Context,
+	report *billing.RoyaltyReport,
+	amount float64,
+	reason string,
+) (*billing.AccountingEntry, error) {
+	to, err := ptypes.Timestamp(report.PeriodTo)
+	if err != nil {
+		zap.L().Error("time conversion error", zap.Error(err))
+		return nil, err
+	}
+
+	reqAe := &grpc.CreateAccountingEntryRequest{
+		MerchantId: report.MerchantId,
+		Amount:     amount,
+		Currency:   report.Currency,
+		Reason:     reason,
+		Date:       to.Add(-1 * time.Second).Unix(),
+		Type:       pkg.AccountingEntryTypeMerchantRoyaltyCorrection,
+	}
+	resAe := &grpc.CreateAccountingEntryResponse{}
+	err = s.CreateAccountingEntry(ctx, reqAe, resAe)
+	if err != nil {
+		zap.L().Error("create correction accounting entry failed", zap.Error(err))
+		return nil, err
+	}
+	if resAe.Status != pkg.ResponseStatusOk {
+		zap.L().Error("create correction accounting entry failed")
+		return nil, resAe.Message
+	}
+
+	return resAe.Item, nil
+}
+
+// refreshRoyaltyReportCorrections recalculates corrections summary and total of the report
+func (s *Service) refreshRoyaltyReportCorrections(ctx context.Context, report *billing.RoyaltyReport) (err error) {
+	handler := &royaltyHandler{Service: s}
+
+	handler.from, err = ptypes.Timestamp(report.PeriodFrom)
+	if err != nil {
+		zap.L().Error("time conversion error", zap.Error(err))
+		return
+	}
+
+	handler.to, err = ptypes.Timestamp(report.PeriodTo)
+	if err != nil {
+		zap.L().Error("time conversion error", zap.Error(err))
+		return
+	}
+
+	if report.Totals == nil {
+		report.Totals = &billing.RoyaltyReportTotals{}
+	}
+	if report.Summary == nil {
+		report.Summary = &billing.RoyaltyReportSummary{}
+	}
+
+	report.Summary.Corrections, report.Totals.CorrectionAmount, err = handler.getRoyaltyReportCorrections(ctx, report.MerchantId, report.OperatingCompanyId, report.Currency)
+	if err != nil {
+		zap.L().Error("get royalty report corrections error", zap.Error(err))
+	}
+
+	return
+}
+
 func (s *Service) ListRoyaltyReportOrders(
 	ctx context.Context,
 	req *grpc.ListRoyaltyReportOrdersRequest,
@@ -725,41 +754,59 @@ func (h *royaltyHandler) getRoyaltyReportRollingReserves(
 	return
 }
 
-func (h *royaltyHandler) createMerchantRoyaltyReport(ctx context.Context, merchantId primitive.ObjectID) error {
-	zap.L().Info("generating royalty reports for merchant", zap.String("merchant_id", merchantId.Hex()))
+func (h *royaltyHandler) createMerchantRoyaltyReport(ctx context.Context, merchant *billing.Merchant) (int, error) {
+	zap.L().Info("generating royalty reports for merchant", zap.String("merchant_id", merchant.Id))
 
-	merchant, err := h.merchant.GetById(ctx, merchantId.Hex())
-	if err != nil {
-		return err
+	if h.to.After(time.Now()) {
+		return 0, royaltyReportErrorEndOfPeriodIsInFuture
 	}
 
 	ocIds, err := h.orderView.GetRoyaltyOperatingCompaniesIds(ctx, merchant.Id, merchant.GetPayoutCurrency(), h.from, h.to)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(ocIds) == 0 {
+		return 0, nil
+	}
+
+	err = h.checkRoyaltyReportPeriod(ctx, merchant.Id)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
 
 	for _, operatingCompanyId := range ocIds {
 
 		isExists, err := h.royaltyReport.CheckReportExists(ctx, merchant.Id, operatingCompanyId, merchant.GetPayoutCurrency(), h.from, h.to)
+		if err != nil {
+			return count, err
+		}
+
+		// report of operating company was created by previous run, which failed on other operating company
 		if isExists {
-			return royaltyReportErrorAlreadyExists
+			continue
 		}
 
 		summaryItems, summaryTotal, err := h.orderView.GetRoyaltySummary(ctx, merchant.Id, operatingCompanyId, merchant.GetPayoutCurrency(), h.from, h.to)
 		if err != nil {
-			return err
+			return count, err
 		}
 
 		corrections, correctionsTotal, err := h.getRoyaltyReportCorrections(ctx, merchant.Id, operatingCompanyId, merchant.GetPayoutCurrency())
 		if err != nil {
-			return err
+			return count, err
 		}
 
 		reserves, reservesTotal, err := h.getRoyaltyReportRollingReserves(ctx, merchant.Id, operatingCompanyId, merchant.GetPayoutCurrency())
 		if err != nil {
-			return err
+			return count, err
 		}
 
 		report := &billing.RoyaltyReport{
 			Id:                 primitive.NewObjectID().Hex(),
-			MerchantId:         merchantId.Hex(),
+			MerchantId:         merchant.Id,
 			OperatingCompanyId: operatingCompanyId,
 			Currency:           merchant.GetPayoutCurrency(),
 			Status:             pkg.RoyaltyReportStatusPending,
@@ -782,29 +829,71 @@ func (h *royaltyHandler) createMerchantRoyaltyReport(ctx context.Context, mercha
 
 		report.PeriodFrom, err = ptypes.TimestampProto(h.from)
 		if err != nil {
-			return err
+			return count, err
 		}
 
 		report.PeriodTo, err = ptypes.TimestampProto(h.to)
 		if err != nil {
-			return err
+			return count, err
 		}
 
 		report.AcceptExpireAt, err = ptypes.TimestampProto(time.Now().Add(time.Duration(h.cfg.RoyaltyReportAcceptTimeout) * time.Second))
 		if err != nil {
-			return err
+			return count, err
 		}
 
 		err = h.royaltyReport.Insert(ctx, report, "", pkg.RoyaltyReportChangeSourceAuto)
+		if err != nil {
+			return count, err
+		}
+
+		count++
 
 		err = h.Service.renderRoyaltyReport(ctx, report, merchant)
 		if err != nil {
-			return err
+			return count, err
 		}
 
 	}
 
-	zap.L().Info("generating royalty reports for merchant finished", zap.String("merchant_id", merchantId.Hex()))
+	zap.L().Info("generating royalty reports for merchant finished", zap.String("merchant_id", merchant.Id))
+
+	return count, nil
+}
+
+// checkRoyaltyReportPeriod rejects the handler period if it is not adjacent to the last report of merchant.
+// Reports of the same period for other operating companies are allowed.
+func (h *royaltyHandler) checkRoyaltyReportPeriod(ctx context.Context, merchantId string) error {
+	last, err := h.royaltyReport.GetLastByMerchantId(ctx, merchantId)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		return err
+	}
+
+	lastFrom, err := ptypes.Timestamp(last.PeriodFrom)
+	if err != nil {
+		return err
+	}
+
+	lastTo, err := ptypes.Timestamp(last.PeriodTo)
+	if err != nil {
+		return err
+	}
+
+	if lastFrom.Equal(h.from) && lastTo.Equal(h.to) {
+		return nil
+	}
+
+	if lastTo.Before(h.from) {
+		return royaltyReportErrorPeriodGap
+	}
+
+	if lastTo.After(h.from) {
+		return royaltyReportErrorPeriodOverlap
+	}
 
 	return nil
 }
@@ -1210,6 +1299,29 @@ func (r *RoyaltyReport) CheckReportExists(
 	return true, nil
 }
 
+func (r *RoyaltyReport) GetLastByMerchantId(ctx context.Context, merchantId string) (*billing.RoyaltyReport, error) {
+	oid, _ := primitive.ObjectIDFromHex(merchantId)
+	query := bson.M{"merchant_id": oid}
+	opts := options.FindOne().SetSort(bson.M{"period_to": -1})
+
+	report := new(billing.RoyaltyReport)
+	err := r.svc.db.Collection(collectionRoyaltyReport).FindOne(ctx, query, opts).Decode(report)
+
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			zap.L().Error(
+				pkg.ErrorDatabaseQueryFailed,
+				zap.Error(err),
+				zap.String(pkg.ErrorDatabaseFieldCollection, collectionRoyaltyReport),
+				zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+			)
+		}
+		return nil, err
+	}
+
+	return report, nil
+}
+
 func (r *RoyaltyReport) Insert(ctx context.Context, rr *billing.RoyaltyReport, ip, source string) (err error) {
 	_, err = r.svc.db.Collection(collectionRoyaltyReport).InsertOne(ctx, rr)
 	if err != nil {
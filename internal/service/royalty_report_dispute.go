@@ -0,0 +1,449 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	royaltyReportDisputeEscalatedMessage = "dispute escalated, answer was not given in time"
+
+	royaltyReportDisputeOpenedNotification    = "royalty report dispute opened by merchant"
+	royaltyReportDisputeMessageNotification   = "new message in royalty report dispute"
+	royaltyReportDisputeEscalatedNotification = "royalty report dispute escalated"
+)
+
+func (s *Service) OpenRoyaltyReportDispute(
+	ctx context.Context,
+	req *grpc.OpenRoyaltyReportDisputeRequest,
+	rsp *grpc.GetRoyaltyReportResponse,
+) error {
+	report, err := s.getRoyaltyReportForDispute(ctx, req.ReportId, req.MerchantId, rsp)
+
+	if err != nil || report == nil {
+		return err
+	}
+
+	if report.Status != pkg.RoyaltyReportStatusPending {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = royaltyReportErrorReportStatusChangeDenied
+		return nil
+	}
+
+	var items []*billing.RoyaltyReportDisputeItem
+
+	for _, v := range req.Items {
+		if (v.OrderId == "") == (v.Product == "") {
+			rsp.Status = pkg.ResponseStatusBadData
+			rsp.Message = royaltyReportErrorDisputeItemInvalid
+			return nil
+		}
+
+		exists, err := s.checkRoyaltyReportDisputeItem(ctx, report, v)
+
+		if err != nil {
+			rsp.Status = pkg.ResponseStatusSystemError
+			rsp.Message = royaltyReportEntryErrorUnknown
+			return nil
+		}
+
+		if !exists {
+			rsp.Status = pkg.ResponseStatusBadData
+			rsp.Message = royaltyReportErrorDisputeItemNotFound
+			return nil
+		}
+
+		items = append(items, &billing.RoyaltyReportDisputeItem{
+			Id:            primitive.NewObjectID().Hex(),
+			OrderId:       v.OrderId,
+			Product:       v.Product,
+			ClaimedAmount: v.ClaimedAmount,
+			Comment:       v.Comment,
+		})
+	}
+
+	report.Status = pkg.RoyaltyReportStatusDispute
+	report.DisputeReason = req.Comment
+	report.DisputeStartedAt = ptypes.TimestampNow()
+	report.Dispute = &billing.RoyaltyReportDispute{Items: items}
+
+	err = s.addRoyaltyReportDisputeMessage(report, pkg.RoyaltyReportChangeSourceMerchant, req.UserId, req.Comment, req.Attachments)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = royaltyReportEntryErrorUnknown
+		return nil
+	}
+
+	err = s.royaltyReport.Update(ctx, report, req.Ip, pkg.RoyaltyReportChangeSourceMerchant)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = royaltyReportEntryErrorUnknown
+		return nil
+	}
+
+	s.sendRoyaltyReportDisputeAdminNotification(ctx, report, royaltyReportDisputeOpenedNotification)
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = report
+
+	return nil
+}
+
+func (s *Service) AddRoyaltyReportDisputeMessage(
+	ctx context.Context,
+	req *grpc.AddRoyaltyReportDisputeMessageRequest,
+	rsp *grpc.GetRoyaltyReportResponse,
+) error {
+	report, err := s.getRoyaltyReportForDispute(ctx, req.ReportId, req.MerchantId, rsp)
+
+	if err != nil || report == nil {
+		return err
+	}
+
+	if report.Status != pkg.RoyaltyReportStatusDispute {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = royaltyReportErrorDisputeNotOpen
+		return nil
+	}
+
+	if report.Dispute == nil {
+		report.Dispute = &billing.RoyaltyReportDispute{}
+	}
+
+	err = s.addRoyaltyReportDisputeMessage(report, req.Source, req.UserId, req.Text, req.Attachments)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = royaltyReportEntryErrorUnknown
+		return nil
+	}
+
+	err = s.royaltyReport.Update(ctx, report, req.Ip, req.Source)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = royaltyReportEntryErrorUnknown
+		return nil
+	}
+
+	if req.Source == pkg.RoyaltyReportChangeSourceAdmin {
+		s.sendRoyaltyReportNotification(ctx, report)
+	} else {
+		s.sendRoyaltyReportDisputeAdminNotification(ctx, report, royaltyReportDisputeMessageNotification)
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = report
+
+	return nil
+}
+
+func (s *Service) ApplyRoyaltyReportDisputeCorrections(
+	ctx context.Context,
+	req *grpc.ApplyRoyaltyReportDisputeCorrectionsRequest,
+	rsp *grpc.GetRoyaltyReportResponse,
+) error {
+	report, err := s.getRoyaltyReportForDispute(ctx, req.ReportId, req.MerchantId, rsp)
+
+	if err != nil || report == nil {
+		return err
+	}
+
+	if report.Status != pkg.RoyaltyReportStatusDispute || report.Dispute == nil {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = royaltyReportErrorDisputeNotOpen
+		return nil
+	}
+
+	items := make(map[string]*billing.RoyaltyReportDisputeItem, len(report.Dispute.Items))
+
+	for _, v := range report.Dispute.Items {
+		items[v.Id] = v
+	}
+
+	// validate all corrections before any accounting entry is created
+	for _, v := range req.Corrections {
+		item, ok := items[v.ItemId]
+
+		if !ok {
+			rsp.Status = pkg.ResponseStatusBadData
+			rsp.Message = royaltyReportErrorDisputeCorrectionItemNotFound
+			return nil
+		}
+
+		if item.Correction != nil {
+			rsp.Status = pkg.ResponseStatusBadData
+			rsp.Message = royaltyReportErrorDisputeItemAlreadyCorrected
+			return nil
+		}
+
+		if v.Reason == "" {
+			rsp.Status = pkg.ResponseStatusBadData
+			rsp.Message = royaltyReportErrorCorrectionReasonRequired
+			return nil
+		}
+
+		if v.Amount == 0 {
+			rsp.Status = pkg.ResponseStatusBadData
+			rsp.Message = royaltyReportErrorCorrectionAmountRequired
+			return nil
+		}
+	}
+
+	for _, v := range req.Corrections {
+		entry, err := s.createRoyaltyReportCorrection(ctx, report, v.Amount, v.Reason)
+
+		if err != nil {
+			rsp.Status = pkg.ResponseStatusSystemError
+			rsp.Message = royaltyReportEntryErrorUnknown
+			return nil
+		}
+
+		items[v.ItemId].Correction = &billing.RoyaltyReportCorrectionItem{
+			AccountingEntryId: entry.Id,
+			Amount:            entry.Amount,
+			Currency:          entry.Currency,
+			Reason:            entry.Reason,
+			EntryDate:         entry.CreatedAt,
+		}
+
+		// correction is saved on the report as soon as its entry is created,
+		// so retry of failed request is rejected for already corrected items
+		report.UpdatedAt = ptypes.TimestampNow()
+		err = s.royaltyReport.Update(ctx, report, req.Ip, pkg.RoyaltyReportChangeSourceAdmin)
+
+		if err != nil {
+			rsp.Status = pkg.ResponseStatusSystemError
+			rsp.Message = royaltyReportEntryErrorUnknown
+			return nil
+		}
+	}
+
+	err = s.refreshRoyaltyReportCorrections(ctx, report)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = royaltyReportEntryErrorUnknown
+		return nil
+	}
+
+	report.UpdatedAt = ptypes.TimestampNow()
+	err = s.royaltyReport.Update(ctx, report, req.Ip, pkg.RoyaltyReportChangeSourceAdmin)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = royaltyReportEntryErrorUnknown
+		return nil
+	}
+
+	s.sendRoyaltyReportNotification(ctx, report)
+
+	_, err = s.updateMerchantBalance(ctx, report.MerchantId)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = royaltyReportUpdateBalanceError
+		return nil
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = report
+
+	return nil
+}
+
+func (s *Service) AutoEscalateRoyaltyReportDisputes(
+	ctx context.Context,
+	req *grpc.EmptyRequest,
+	rsp *grpc.EmptyResponse,
+) error {
+	query := bson.M{
+		"status":               pkg.RoyaltyReportStatusDispute,
+		"dispute.status":       pkg.RoyaltyReportDisputeStatusWaitingAdmin,
+		"dispute.is_escalated": false,
+		"dispute_expire_at":    bson.M{"$lte": time.Now()},
+	}
+
+	var reports []*billing.RoyaltyReport
+	cursor, err := s.db.Collection(collectionRoyaltyReport).Find(ctx, query)
+
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			zap.L().Error(
+				pkg.ErrorDatabaseQueryFailed,
+				zap.Error(err),
+				zap.String(pkg.ErrorDatabaseFieldCollection, collectionRoyaltyReport),
+				zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+			)
+		}
+		return err
+	}
+
+	err = cursor.All(ctx, &reports)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionRoyaltyReport),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return err
+	}
+
+	for _, report := range reports {
+		report.Dispute.IsEscalated = true
+		report.Dispute.EscalatedAt = ptypes.TimestampNow()
+		report.Dispute.Messages = append(report.Dispute.Messages, &billing.RoyaltyReportDisputeMessage{
+			Id:        primitive.NewObjectID().Hex(),
+			Source:    pkg.RoyaltyReportChangeSourceAuto,
+			Text:      royaltyReportDisputeEscalatedMessage,
+			CreatedAt: ptypes.TimestampNow(),
+		})
+		report.UpdatedAt = ptypes.TimestampNow()
+
+		err = s.royaltyReport.Update(ctx, report, "", pkg.RoyaltyReportChangeSourceAuto)
+
+		if err != nil {
+			return err
+		}
+
+		s.sendRoyaltyReportDisputeAdminNotification(ctx, report, royaltyReportDisputeEscalatedNotification)
+	}
+
+	return nil
+}
+
+// getRoyaltyReportForDispute returns report owned by merchant or fills response with error and returns nil report
+func (s *Service) getRoyaltyReportForDispute(
+	ctx context.Context,
+	reportId, merchantId string,
+	rsp *grpc.GetRoyaltyReportResponse,
+) (*billing.RoyaltyReport, error) {
+	report, err := s.royaltyReport.GetById(ctx, reportId)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			rsp.Status = pkg.ResponseStatusNotFound
+			rsp.Message = royaltyReportErrorReportNotFound
+			return nil, nil
+		}
+
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = royaltyReportEntryErrorUnknown
+		return nil, nil
+	}
+
+	if report.MerchantId != merchantId {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = royaltyReportErrorNotOwnedByMerchant
+		return nil, nil
+	}
+
+	return report, nil
+}
+
+// checkRoyaltyReportDisputeItem checks that disputed order or product line belongs to the report
+func (s *Service) checkRoyaltyReportDisputeItem(
+	ctx context.Context,
+	report *billing.RoyaltyReport,
+	item *grpc.RoyaltyReportDisputeItemRequest,
+) (bool, error) {
+	if item.Product != "" {
+		if report.Summary == nil {
+			return false, nil
+		}
+
+		for _, v := range report.Summary.ProductsItems {
+			if v.Product == item.Product {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+
+	from, err := ptypes.Timestamp(report.PeriodFrom)
+
+	if err != nil {
+		return false, err
+	}
+
+	to, err := ptypes.Timestamp(report.PeriodTo)
+
+	if err != nil {
+		return false, err
+	}
+
+	orderOid, _ := primitive.ObjectIDFromHex(item.OrderId)
+	merchantOid, _ := primitive.ObjectIDFromHex(report.MerchantId)
+	match := bson.M{
+		"_id":                  orderOid,
+		"merchant_id":          merchantOid,
+		"operating_company_id": report.OperatingCompanyId,
+		"pm_order_close_date":  bson.M{"$gte": from, "$lte": to},
+		"status":               bson.M{"$in": orderStatusForRoyaltyReports},
+	}
+
+	n, err := s.orderView.CountTransactions(ctx, match)
+
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// addRoyaltyReportDisputeMessage appends message to the dispute thread and passes the turn to the other side.
+// While the dispute waits for an answer of admin it must be given within RoyaltyReportAcceptTimeout.
+func (s *Service) addRoyaltyReportDisputeMessage(
+	report *billing.RoyaltyReport,
+	source, userId, text string,
+	attachments []*billing.RoyaltyReportDisputeAttachment,
+) (err error) {
+	report.Dispute.Messages = append(report.Dispute.Messages, &billing.RoyaltyReportDisputeMessage{
+		Id:          primitive.NewObjectID().Hex(),
+		Source:      source,
+		UserId:      userId,
+		Text:        text,
+		Attachments: attachments,
+		CreatedAt:   ptypes.TimestampNow(),
+	})
+
+	if source == pkg.RoyaltyReportChangeSourceAdmin {
+		report.Dispute.Status = pkg.RoyaltyReportDisputeStatusWaitingMerchant
+		report.Dispute.IsEscalated = false
+		report.DisputeExpireAt = nil
+	} else {
+		report.Dispute.Status = pkg.RoyaltyReportDisputeStatusWaitingAdmin
+		expireAt := time.Now().Add(time.Duration(s.cfg.RoyaltyReportAcceptTimeout) * time.Second)
+		report.DisputeExpireAt, err = ptypes.TimestampProto(expireAt)
+	}
+
+	report.UpdatedAt = ptypes.TimestampNow()
+
+	return
+}
+
+func (s *Service) sendRoyaltyReportDisputeAdminNotification(ctx context.Context, report *billing.RoyaltyReport, message string) {
+	msg := map[string]interface{}{"id": report.Id, "merchant_id": report.MerchantId, "message": message}
+	err := s.centrifugo.Publish(ctx, s.cfg.CentrifugoAdminChannel, msg)
+
+	if err != nil {
+		zap.L().Error(
+			"[Centrifugo] Send admin notification about royalty report dispute failed",
+			zap.Error(err),
+			zap.Any("msg", msg),
+		)
+	}
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/jinzhu/now"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+	"math"
+	"time"
+)
+
+// bi-weekly royalty report periods are counted from this monday
+var royaltyReportBiWeeklyAnchor = time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// createMerchantRoyaltyReports generates reports for every closed royalty report period of merchant
+// since the last report of the merchant. Periods without transactions are joined to the next period,
+// so reports of merchant always follow one another without gaps. The period of the last report is processed
+// again to create reports of operating companies which weren't created because of failure of previous run.
+func (s *Service) createMerchantRoyaltyReports(ctx context.Context, merchantId primitive.ObjectID, tNow time.Time) (int, error) {
+	merchant, err := s.merchant.GetById(ctx, merchantId.Hex())
+
+	if err != nil {
+		return 0, err
+	}
+
+	period, loc, err := s.getMerchantRoyaltyReportSchedule(merchant)
+
+	if err != nil {
+		zap.L().Error(
+			royaltyReportErrorTimezoneIncorrect.Error(),
+			zap.Error(err),
+			zap.String(pkg.ErrorRoyaltyReportFieldMerchantId, merchant.Id),
+		)
+		return 0, err
+	}
+
+	var from time.Time
+	hasPrevious := true
+	last, err := s.royaltyReport.GetLastByMerchantId(ctx, merchant.Id)
+
+	count := 0
+
+	if err == nil {
+		handler := &royaltyHandler{Service: s}
+		handler.from, err = ptypes.Timestamp(last.PeriodFrom)
+
+		if err != nil {
+			return 0, err
+		}
+
+		handler.to, err = ptypes.Timestamp(last.PeriodTo)
+
+		if err != nil {
+			return 0, err
+		}
+
+		handler.from = handler.from.In(loc)
+		handler.to = handler.to.In(loc)
+
+		if count, err = handler.createMerchantRoyaltyReport(ctx, merchant); err != nil {
+			zap.L().Error(
+				pkg.ErrorRoyaltyReportGenerationFailed,
+				zap.Error(err),
+				zap.String(pkg.ErrorRoyaltyReportFieldMerchantId, merchant.Id),
+				zap.Any(pkg.ErrorRoyaltyReportFieldFrom, handler.from),
+				zap.Any(pkg.ErrorRoyaltyReportFieldTo, handler.to),
+			)
+			return count, err
+		}
+
+		from = handler.to
+	} else {
+		if err != mongo.ErrNoDocuments {
+			return 0, err
+		}
+
+		end := s.getRoyaltyReportPeriodEnd(period, loc, tNow.AddDate(0, 0, -int(s.cfg.RoyaltyReportBackfillDays)))
+		from = getRoyaltyReportPeriodStart(period, end)
+		hasPrevious = false
+	}
+
+	for to := s.getRoyaltyReportPeriodEnd(period, loc, from); !to.After(tNow); to = s.getRoyaltyReportPeriodEnd(period, loc, to) {
+		handler := &royaltyHandler{
+			Service: s,
+			from:    from,
+			to:      to,
+		}
+		n, err := handler.createMerchantRoyaltyReport(ctx, merchant)
+
+		if err != nil {
+			zap.L().Error(
+				pkg.ErrorRoyaltyReportGenerationFailed,
+				zap.Error(err),
+				zap.String(pkg.ErrorRoyaltyReportFieldMerchantId, merchant.Id),
+				zap.Any(pkg.ErrorRoyaltyReportFieldFrom, from),
+				zap.Any(pkg.ErrorRoyaltyReportFieldTo, to),
+			)
+			return count, err
+		}
+
+		if n > 0 {
+			count += n
+			hasPrevious = true
+			from = to
+		} else if !hasPrevious {
+			from = to
+		}
+	}
+
+	return count, nil
+}
+
+func (s *Service) getMerchantRoyaltyReportSchedule(merchant *billing.Merchant) (string, *time.Location, error) {
+	period := merchant.RoyaltyReportPeriod
+
+	if period == "" {
+		period = pkg.RoyaltyReportPeriodWeekly
+	}
+
+	timezone := merchant.RoyaltyReportTimezone
+
+	if timezone == "" {
+		timezone = s.cfg.RoyaltyReportTimeZone
+	}
+
+	loc, err := time.LoadLocation(timezone)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	return period, loc, nil
+}
+
+// getRoyaltyReportPeriodEnd returns the end of royalty report period that follows the date.
+// Periods end at RoyaltyReportPeriodEndHour of monday for weekly and bi-weekly periods
+// and of the first day of month for monthly period in the merchant time zone.
+func (s *Service) getRoyaltyReportPeriodEnd(period string, loc *time.Location, date time.Time) time.Time {
+	endHour := time.Duration(s.cfg.RoyaltyReportPeriodEndHour) * time.Hour
+	localDate := now.New(date.In(loc))
+
+	switch period {
+	case pkg.RoyaltyReportPeriodMonthly:
+		month := localDate.BeginningOfMonth()
+
+		if end := month.Add(endHour); end.After(date) {
+			return end
+		}
+
+		return month.AddDate(0, 1, 0).Add(endHour)
+	case pkg.RoyaltyReportPeriodBiWeekly:
+		monday := localDate.Monday()
+		anchor := time.Date(
+			royaltyReportBiWeeklyAnchor.Year(),
+			royaltyReportBiWeeklyAnchor.Month(),
+			royaltyReportBiWeeklyAnchor.Day(),
+			0, 0, 0, 0, loc,
+		)
+
+		if weeks := int64(math.Round(monday.Sub(anchor).Hours() / 168)); weeks%2 != 0 {
+			monday = monday.AddDate(0, 0, -7)
+		}
+
+		if end := monday.Add(endHour); end.After(date) {
+			return end
+		}
+
+		return monday.AddDate(0, 0, 14).Add(endHour)
+	default:
+		monday := localDate.Monday()
+
+		if end := monday.Add(endHour); end.After(date) {
+			return end
+		}
+
+		return monday.AddDate(0, 0, 7).Add(endHour)
+	}
+}
+
+// getRoyaltyReportPeriodStart returns the beginning of royalty report period by its end
+func getRoyaltyReportPeriodStart(period string, end time.Time) time.Time {
+	switch period {
+	case pkg.RoyaltyReportPeriodMonthly:
+		return end.AddDate(0, -1, 0)
+	case pkg.RoyaltyReportPeriodBiWeekly:
+		return end.AddDate(0, 0, -14)
+	default:
+		return end.AddDate(0, 0, -7)
+	}
+}
@@ -64,7 +64,7 @@ func (suite *RoyaltyReportTestSuite) SetupTest() {
 		suite.FailNow("Config load failed", "%v", err)
 	}
 	cfg.RoyaltyReportPeriodEndHour = 0
-	cfg.CardPayApiUrl = "https://sandbox.cardpay.com"
+	cfg.CardPay.ApiUrl = "https://sandbox.cardpay.com"
 
 	m, err := migrate.New(
 		"file://../../migrations/tests",
@@ -208,8 +208,8 @@ func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_CreateRoyaltyReport_AllMe
 	loc, err := time.LoadLocation(suite.service.cfg.RoyaltyReportTimeZone)
 	assert.NoError(suite.T(), err)
 
-	to := now.Monday().In(loc).Add(time.Duration(suite.service.cfg.RoyaltyReportPeriodEndHour) * time.Hour)
-	from := to.Add(-time.Duration(suite.service.cfg.RoyaltyReportPeriod) * time.Second).In(loc)
+	to := now.New(time.Now().In(loc)).Monday().Add(time.Duration(suite.service.cfg.RoyaltyReportPeriodEndHour) * time.Hour)
+	from := getRoyaltyReportPeriodStart(pkg.RoyaltyReportPeriodWeekly, to).In(loc)
 
 	var reports []*billing.RoyaltyReport
 	cursor, err := suite.service.db.Collection(collectionRoyaltyReport).Find(context.TODO(), bson.M{"period_from": from, "period_to": to})
@@ -283,8 +283,8 @@ func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_CreateRoyaltyReport_Selec
 	loc, err := time.LoadLocation(suite.service.cfg.RoyaltyReportTimeZone)
 	assert.NoError(suite.T(), err)
 
-	to := now.Monday().In(loc).Add(time.Duration(suite.service.cfg.RoyaltyReportPeriodEndHour) * time.Hour)
-	from := to.Add(-time.Duration(suite.service.cfg.RoyaltyReportPeriod) * time.Second).In(loc)
+	to := now.New(time.Now().In(loc)).Monday().Add(time.Duration(suite.service.cfg.RoyaltyReportPeriodEndHour) * time.Hour)
+	from := getRoyaltyReportPeriodStart(pkg.RoyaltyReportPeriodWeekly, to).In(loc)
 
 	for _, v := range reports {
 		assert.NotZero(suite.T(), v.Id)
@@ -380,8 +380,8 @@ func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_ListRoyaltyReports_Ok() {
 	loc, err := time.LoadLocation(suite.service.cfg.RoyaltyReportTimeZone)
 	assert.NoError(suite.T(), err)
 
-	to := now.Monday().In(loc).Add(time.Duration(suite.service.cfg.RoyaltyReportPeriodEndHour) * time.Hour).Add(-time.Duration(168) * time.Hour)
-	from := to.Add(-time.Duration(suite.service.cfg.RoyaltyReportPeriod) * time.Second).In(loc)
+	to := now.New(time.Now().In(loc)).Monday().Add(time.Duration(suite.service.cfg.RoyaltyReportPeriodEndHour) * time.Hour).Add(-time.Duration(168) * time.Hour)
+	from := getRoyaltyReportPeriodStart(pkg.RoyaltyReportPeriodWeekly, to).In(loc)
 
 	oid, _ := primitive.ObjectIDFromHex(suite.project.GetMerchantId())
 	query := bson.M{"merchant_id": oid}
@@ -460,8 +460,8 @@ func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_ListRoyaltyReports_FindBy
 	loc, err := time.LoadLocation(suite.service.cfg.RoyaltyReportTimeZone)
 	assert.NoError(suite.T(), err)
 
-	to := now.Monday().In(loc).Add(time.Duration(suite.service.cfg.RoyaltyReportPeriodEndHour) * time.Hour).Add(-time.Duration(168) * time.Hour)
-	from := to.Add(-time.Duration(suite.service.cfg.RoyaltyReportPeriod) * time.Second).In(loc)
+	to := now.New(time.Now().In(loc)).Monday().Add(time.Duration(suite.service.cfg.RoyaltyReportPeriodEndHour) * time.Hour).Add(-time.Duration(168) * time.Hour)
+	from := getRoyaltyReportPeriodStart(pkg.RoyaltyReportPeriodWeekly, to).In(loc)
 
 	oid, _ := primitive.ObjectIDFromHex(suite.project.GetMerchantId())
 	query := bson.M{"merchant_id": oid}
@@ -1041,8 +1041,8 @@ func (suite *RoyaltyReportTestSuite) createOrder(project *billing.Project) *bill
 	if !assert.NoError(suite.T(), err) {
 		suite.FailNow("time.LoadLocation failed", "%v", err)
 	}
-	to := now.Monday().In(loc).Add(time.Duration(suite.service.cfg.RoyaltyReportPeriodEndHour) * time.Hour)
-	date := to.Add(-time.Duration(suite.service.cfg.RoyaltyReportPeriod/2) * time.Second).In(loc)
+	to := now.New(time.Now().In(loc)).Monday().Add(time.Duration(suite.service.cfg.RoyaltyReportPeriodEndHour) * time.Hour)
+	date := to.Add(-84 * time.Hour).In(loc)
 
 	order.PaymentMethodOrderClosedAt, _ = ptypes.TimestampProto(date)
 	err = suite.service.updateOrder(context.TODO(), order)
@@ -1062,7 +1062,7 @@ func (suite *RoyaltyReportTestSuite) createOrder(project *billing.Project) *bill
 	return order
 }
 
-func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_CreateRoyaltyReport_Fail_EndOfPeriodInFuture() {
+func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_CreateRoyaltyReport_EndOfPeriodInFuture_NotCreated() {
 
 	loc, err := time.LoadLocation(suite.service.cfg.RoyaltyReportTimeZone)
 	if !assert.NoError(suite.T(), err) {
@@ -1070,13 +1070,228 @@ func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_CreateRoyaltyReport_Fail_
 	}
 
 	currentTime := time.Now().In(loc)
-	monday := now.Monday().In(loc)
+	monday := now.New(time.Now().In(loc)).Monday()
 	suite.service.cfg.RoyaltyReportPeriodEndHour = int64(math.Ceil(currentTime.Sub(monday).Hours()))
 
+	suite.createOrder(suite.project)
+	err = suite.service.updateOrderView(context.TODO(), []string{})
+	assert.NoError(suite.T(), err)
+
 	req := &grpc.CreateRoyaltyReportRequest{}
 	rsp := &grpc.CreateRoyaltyReportRequest{}
 	err = suite.service.CreateRoyaltyReport(context.TODO(), req, rsp)
-	assert.Error(suite.T(), err)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), rsp.Merchants)
+
+	handler := &royaltyHandler{
+		Service: suite.service,
+		from:    monday,
+		to:      monday.Add(time.Duration(suite.service.cfg.RoyaltyReportPeriodEndHour) * time.Hour),
+	}
+	_, err = handler.createMerchantRoyaltyReport(context.TODO(), suite.merchant)
+	assert.Equal(suite.T(), royaltyReportErrorEndOfPeriodIsInFuture, err)
+}
+
+func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_CreateRoyaltyReport_BackfillMissedPeriods_Ok() {
+	suite.createOrder(suite.project)
+	err := suite.service.updateOrderView(context.TODO(), []string{})
+	assert.NoError(suite.T(), err)
+
+	req := &grpc.CreateRoyaltyReportRequest{Merchants: []string{suite.merchant.Id}}
+	rsp := &grpc.CreateRoyaltyReportRequest{}
+	err = suite.service.CreateRoyaltyReport(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{suite.merchant.Id}, rsp.Merchants)
+
+	loc, err := time.LoadLocation(suite.service.cfg.RoyaltyReportTimeZone)
+	assert.NoError(suite.T(), err)
+
+	to := now.New(time.Now().In(loc)).Monday().Add(time.Duration(suite.service.cfg.RoyaltyReportPeriodEndHour) * time.Hour)
+
+	// pretend the previous report was generated three weeks ago and the task missed the next periods
+	oid, _ := primitive.ObjectIDFromHex(suite.merchant.Id)
+	query := bson.M{"merchant_id": oid}
+	set := bson.M{"$set": bson.M{"period_from": to.AddDate(0, 0, -21), "period_to": to.AddDate(0, 0, -14)}}
+	_, err = suite.service.db.Collection(collectionRoyaltyReport).UpdateMany(context.TODO(), query, set)
+	assert.NoError(suite.T(), err)
+
+	rsp = &grpc.CreateRoyaltyReportRequest{}
+	err = suite.service.CreateRoyaltyReport(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{suite.merchant.Id}, rsp.Merchants)
+
+	report, err := suite.service.royaltyReport.GetLastByMerchantId(context.TODO(), suite.merchant.Id)
+	assert.NoError(suite.T(), err)
+
+	from, err := ptypes.Timestamp(report.PeriodFrom)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), to.AddDate(0, 0, -14).Unix(), from.Unix())
+
+	periodTo, err := ptypes.Timestamp(report.PeriodTo)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), to.Unix(), periodTo.Unix())
+}
+
+func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_CreateRoyaltyReport_OperatingCompanyFailed_CreatedByNextRun() {
+	suite.createOrder(suite.project)
+	err := suite.service.updateOrderView(context.TODO(), []string{})
+	assert.NoError(suite.T(), err)
+
+	req := &grpc.CreateRoyaltyReportRequest{Merchants: []string{suite.merchant.Id}}
+	rsp := &grpc.CreateRoyaltyReportRequest{}
+	err = suite.service.CreateRoyaltyReport(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{suite.merchant.Id}, rsp.Merchants)
+
+	// pretend only report of other operating company was created, and the run failed on the next one
+	oid, _ := primitive.ObjectIDFromHex(suite.merchant.Id)
+	query := bson.M{"merchant_id": oid}
+	set := bson.M{"$set": bson.M{"operating_company_id": primitive.NewObjectID().Hex()}}
+	_, err = suite.service.db.Collection(collectionRoyaltyReport).UpdateMany(context.TODO(), query, set)
+	assert.NoError(suite.T(), err)
+
+	rsp = &grpc.CreateRoyaltyReportRequest{}
+	err = suite.service.CreateRoyaltyReport(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{suite.merchant.Id}, rsp.Merchants)
+
+	var reports []*billing.RoyaltyReport
+	cursor, err := suite.service.db.Collection(collectionRoyaltyReport).Find(context.TODO(), query)
+	assert.NoError(suite.T(), err)
+	err = cursor.All(context.TODO(), &reports)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), reports, 2)
+	assert.Equal(suite.T(), reports[0].PeriodFrom.Seconds, reports[1].PeriodFrom.Seconds)
+	assert.Equal(suite.T(), reports[0].PeriodTo.Seconds, reports[1].PeriodTo.Seconds)
+	assert.NotEqual(suite.T(), reports[0].OperatingCompanyId, reports[1].OperatingCompanyId)
+
+	// nothing left to create by the next run
+	rsp = &grpc.CreateRoyaltyReportRequest{}
+	err = suite.service.CreateRoyaltyReport(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), rsp.Merchants)
+}
+
+func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_CreateRoyaltyReport_MonthlyMerchant_Ok() {
+	loc, err := time.LoadLocation(suite.service.cfg.RoyaltyReportTimeZone)
+	assert.NoError(suite.T(), err)
+
+	suite.merchant.RoyaltyReportPeriod = pkg.RoyaltyReportPeriodMonthly
+	suite.merchant.RoyaltyReportTimezone = suite.service.cfg.RoyaltyReportTimeZone
+	err = suite.service.merchant.Update(context.TODO(), suite.merchant)
+	assert.NoError(suite.T(), err)
+
+	order := suite.createOrder(suite.project)
+	to := now.New(time.Now().In(loc)).BeginningOfMonth()
+	order.PaymentMethodOrderClosedAt, _ = ptypes.TimestampProto(to.AddDate(0, 0, -10))
+	err = suite.service.updateOrder(context.TODO(), order)
+	assert.NoError(suite.T(), err)
+	err = suite.service.updateOrderView(context.TODO(), []string{})
+	assert.NoError(suite.T(), err)
+
+	req := &grpc.CreateRoyaltyReportRequest{Merchants: []string{suite.merchant.Id}}
+	rsp := &grpc.CreateRoyaltyReportRequest{}
+	err = suite.service.CreateRoyaltyReport(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{suite.merchant.Id}, rsp.Merchants)
+
+	report, err := suite.service.royaltyReport.GetLastByMerchantId(context.TODO(), suite.merchant.Id)
+	assert.NoError(suite.T(), err)
+
+	from, err := ptypes.Timestamp(report.PeriodFrom)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), to.AddDate(0, -1, 0).Unix(), from.Unix())
+
+	periodTo, err := ptypes.Timestamp(report.PeriodTo)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), to.Unix(), periodTo.Unix())
+}
+
+func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_checkRoyaltyReportPeriod_GapAndOverlap_Error() {
+	suite.createOrder(suite.project)
+	err := suite.service.updateOrderView(context.TODO(), []string{})
+	assert.NoError(suite.T(), err)
+
+	req := &grpc.CreateRoyaltyReportRequest{Merchants: []string{suite.merchant.Id}}
+	rsp := &grpc.CreateRoyaltyReportRequest{}
+	err = suite.service.CreateRoyaltyReport(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+
+	report, err := suite.service.royaltyReport.GetLastByMerchantId(context.TODO(), suite.merchant.Id)
+	assert.NoError(suite.T(), err)
+	from, err := ptypes.Timestamp(report.PeriodFrom)
+	assert.NoError(suite.T(), err)
+	to, err := ptypes.Timestamp(report.PeriodTo)
+	assert.NoError(suite.T(), err)
+
+	handler := &royaltyHandler{Service: suite.service, from: to, to: to.AddDate(0, 0, 7)}
+	assert.NoError(suite.T(), handler.checkRoyaltyReportPeriod(context.TODO(), suite.merchant.Id))
+
+	handler = &royaltyHandler{Service: suite.service, from: from, to: to}
+	assert.NoError(suite.T(), handler.checkRoyaltyReportPeriod(context.TODO(), suite.merchant.Id))
+
+	handler = &royaltyHandler{Service: suite.service, from: to.AddDate(0, 0, 1), to: to.AddDate(0, 0, 7)}
+	assert.Equal(suite.T(), royaltyReportErrorPeriodGap, handler.checkRoyaltyReportPeriod(context.TODO(), suite.merchant.Id))
+
+	handler = &royaltyHandler{Service: suite.service, from: to.AddDate(0, 0, -1), to: to.AddDate(0, 0, 7)}
+	assert.Equal(suite.T(), royaltyReportErrorPeriodOverlap, handler.checkRoyaltyReportPeriod(context.TODO(), suite.merchant.Id))
+}
+
+func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_getRoyaltyReportPeriodEnd() {
+	loc, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(suite.T(), err)
+	suite.service.cfg.RoyaltyReportPeriodEndHour = 18
+
+	// wednesday
+	date := time.Date(2020, time.January, 15, 12, 0, 0, 0, loc)
+
+	end := suite.service.getRoyaltyReportPeriodEnd(pkg.RoyaltyReportPeriodWeekly, loc, date)
+	assert.Equal(suite.T(), time.Date(2020, time.January, 20, 18, 0, 0, 0, loc), end)
+	assert.Equal(suite.T(), time.Date(2020, time.January, 13, 18, 0, 0, 0, loc), getRoyaltyReportPeriodStart(pkg.RoyaltyReportPeriodWeekly, end))
+
+	end = suite.service.getRoyaltyReportPeriodEnd(pkg.RoyaltyReportPeriodBiWeekly, loc, date)
+	assert.Equal(suite.T(), time.Date(2020, time.January, 27, 18, 0, 0, 0, loc), end)
+	assert.Equal(suite.T(), time.Date(2020, time.January, 13, 18, 0, 0, 0, loc), getRoyaltyReportPeriodStart(pkg.RoyaltyReportPeriodBiWeekly, end))
+
+	end = suite.service.getRoyaltyReportPeriodEnd(pkg.RoyaltyReportPeriodMonthly, loc, date)
+	assert.Equal(suite.T(), time.Date(2020, time.February, 1, 18, 0, 0, 0, loc), end)
+	assert.Equal(suite.T(), time.Date(2020, time.January, 1, 18, 0, 0, 0, loc), getRoyaltyReportPeriodStart(pkg.RoyaltyReportPeriodMonthly, end))
+
+	// end of period is exclusive for the date
+	end = suite.service.getRoyaltyReportPeriodEnd(pkg.RoyaltyReportPeriodWeekly, loc, time.Date(2020, time.January, 20, 18, 0, 0, 0, loc))
+	assert.Equal(suite.T(), time.Date(2020, time.January, 27, 18, 0, 0, 0, loc), end)
+}
+
+func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_ChangeMerchantRoyaltyReportPeriod_Ok() {
+	req := &grpc.ChangeMerchantRoyaltyReportPeriodRequest{
+		MerchantId: suite.merchant.Id,
+		Period:     pkg.RoyaltyReportPeriodBiWeekly,
+		Timezone:   "Asia/Tokyo",
+	}
+	rsp := &grpc.ChangeMerchantManualPayoutsResponse{}
+	err := suite.service.ChangeMerchantRoyaltyReportPeriod(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Equal(suite.T(), pkg.RoyaltyReportPeriodBiWeekly, rsp.Item.RoyaltyReportPeriod)
+	assert.Equal(suite.T(), "Asia/Tokyo", rsp.Item.RoyaltyReportTimezone)
+
+	merchant, err := suite.service.merchant.GetById(context.TODO(), suite.merchant.Id)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.RoyaltyReportPeriodBiWeekly, merchant.RoyaltyReportPeriod)
+	assert.Equal(suite.T(), "Asia/Tokyo", merchant.RoyaltyReportTimezone)
+}
+
+func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_ChangeMerchantRoyaltyReportPeriod_TimezoneIncorrect_Error() {
+	req := &grpc.ChangeMerchantRoyaltyReportPeriodRequest{
+		MerchantId: suite.merchant.Id,
+		Period:     pkg.RoyaltyReportPeriodMonthly,
+		Timezone:   "incorrect_timezone",
+	}
+	rsp := &grpc.ChangeMerchantManualPayoutsResponse{}
+	err := suite.service.ChangeMerchantRoyaltyReportPeriod(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), royaltyReportErrorTimezoneIncorrect, rsp.Message)
 }
 
 func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_CreateRoyaltyReport_Ok_MerchantWithCorrectionAndReserve() {
@@ -1095,7 +1310,7 @@ func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_CreateRoyaltyReport_Ok_Me
 		suite.FailNow("time.LoadLocation failed", "%v", err)
 	}
 
-	entryDate := now.Monday().In(loc).Add(time.Duration(suite.service.cfg.RoyaltyReportPeriodEndHour-1) * time.Hour)
+	entryDate := now.New(time.Now().In(loc)).Monday().Add(time.Duration(suite.service.cfg.RoyaltyReportPeriodEndHour-1) * time.Hour)
 
 	req := &grpc.CreateAccountingEntryRequest{
 		Type:       pkg.AccountingEntryTypeMerchantRoyaltyCorrection,
@@ -1182,3 +1397,288 @@ func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_GetRoyaltyReport_Ok() {
 	assert.NotEmpty(suite.T(), rsp1.Item)
 	assert.Equal(suite.T(), rsp1.Item, report)
 }
+
+func (suite *RoyaltyReportTestSuite) createPendingRoyaltyReport() (*billing.RoyaltyReport, *billing.Order) {
+	order := suite.createOrder(suite.project)
+	err := suite.service.updateOrderView(context.TODO(), []string{})
+	assert.NoError(suite.T(), err)
+
+	req := &grpc.CreateRoyaltyReportRequest{}
+	rsp := &grpc.CreateRoyaltyReportRequest{}
+	err = suite.service.CreateRoyaltyReport(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.NotEmpty(suite.T(), rsp.Merchants)
+
+	report := new(billing.RoyaltyReport)
+	err = suite.service.db.Collection(collectionRoyaltyReport).FindOne(context.TODO(), bson.M{}).Decode(&report)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.RoyaltyReportStatusPending, report.Status)
+
+	return report, order
+}
+
+func (suite *RoyaltyReportTestSuite) openRoyaltyReportDispute(report *billing.RoyaltyReport, order *billing.Order) *billing.RoyaltyReport {
+	req := &grpc.OpenRoyaltyReportDisputeRequest{
+		MerchantId: report.MerchantId,
+		ReportId:   report.Id,
+		Items: []*grpc.RoyaltyReportDisputeItemRequest{
+			{OrderId: order.Id, ClaimedAmount: 5, Comment: "unit-test order"},
+			{Product: report.Summary.ProductsItems[0].Product, Comment: "unit-test product"},
+		},
+		Comment: "unit-test",
+		Attachments: []*billing.RoyaltyReportDisputeAttachment{
+			{Name: "statement.pdf", Url: "https://example.com/statement.pdf"},
+		},
+		UserId: "unit-test-user",
+		Ip:     "127.0.0.1",
+	}
+	rsp := &grpc.GetRoyaltyReportResponse{}
+	err := suite.service.OpenRoyaltyReportDispute(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Empty(suite.T(), rsp.Message)
+
+	return rsp.Item
+}
+
+func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_OpenRoyaltyReportDispute_Ok() {
+	ci := &mocks.CentrifugoInterface{}
+	ci.On("Publish", mock2.Anything, mock2.Anything, mock2.Anything).Return(nil)
+	suite.service.centrifugo = ci
+
+	report, order := suite.createPendingRoyaltyReport()
+	suite.openRoyaltyReportDispute(report, order)
+
+	report, err := suite.service.royaltyReport.GetById(context.TODO(), report.Id)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.RoyaltyReportStatusDispute, report.Status)
+	assert.NotNil(suite.T(), report.Dispute)
+	assert.Equal(suite.T(), pkg.RoyaltyReportDisputeStatusWaitingAdmin, report.Dispute.Status)
+	assert.Len(suite.T(), report.Dispute.Items, 2)
+	assert.Equal(suite.T(), order.Id, report.Dispute.Items[0].OrderId)
+	assert.NotEmpty(suite.T(), report.Dispute.Items[1].Product)
+	assert.Len(suite.T(), report.Dispute.Messages, 1)
+	assert.Equal(suite.T(), pkg.RoyaltyReportChangeSourceMerchant, report.Dispute.Messages[0].Source)
+	assert.Len(suite.T(), report.Dispute.Messages[0].Attachments, 1)
+	assert.True(suite.T(), report.DisputeExpireAt.Seconds > time.Now().Unix())
+	ci.AssertCalled(suite.T(), "Publish", mock2.Anything, suite.service.cfg.CentrifugoAdminChannel, mock2.Anything)
+}
+
+func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_OpenRoyaltyReportDispute_ItemInvalid_Error() {
+	report, order := suite.createPendingRoyaltyReport()
+
+	req := &grpc.OpenRoyaltyReportDisputeRequest{
+		MerchantId: report.MerchantId,
+		ReportId:   report.Id,
+		Items: []*grpc.RoyaltyReportDisputeItemRequest{
+			{OrderId: order.Id, Product: report.Summary.ProductsItems[0].Product},
+		},
+		Comment: "unit-test",
+	}
+	rsp := &grpc.GetRoyaltyReportResponse{}
+	err := suite.service.OpenRoyaltyReportDispute(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), royaltyReportErrorDisputeItemInvalid, rsp.Message)
+}
+
+func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_OpenRoyaltyReportDispute_ItemNotFound_Error() {
+	report, _ := suite.createPendingRoyaltyReport()
+
+	req := &grpc.OpenRoyaltyReportDisputeRequest{
+		MerchantId: report.MerchantId,
+		ReportId:   report.Id,
+		Items: []*grpc.RoyaltyReportDisputeItemRequest{
+			{OrderId: primitive.NewObjectID().Hex()},
+		},
+		Comment: "unit-test",
+	}
+	rsp := &grpc.GetRoyaltyReportResponse{}
+	err := suite.service.OpenRoyaltyReportDispute(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), royaltyReportErrorDisputeItemNotFound, rsp.Message)
+
+	report, err = suite.service.royaltyReport.GetById(context.TODO(), report.Id)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.RoyaltyReportStatusPending, report.Status)
+}
+
+func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_AddRoyaltyReportDisputeMessage_Ok() {
+	ci := &mocks.CentrifugoInterface{}
+	ci.On("Publish", mock2.Anything, mock2.Anything, mock2.Anything).Return(nil)
+	suite.service.centrifugo = ci
+
+	report, order := suite.createPendingRoyaltyReport()
+	suite.openRoyaltyReportDispute(report, order)
+
+	req := &grpc.AddRoyaltyReportDisputeMessageRequest{
+		MerchantId: report.MerchantId,
+		ReportId:   report.Id,
+		Source:     pkg.RoyaltyReportChangeSourceAdmin,
+		Text:       "unit-test admin",
+		UserId:     "unit-test-admin",
+		Ip:         "127.0.0.1",
+	}
+	rsp := &grpc.GetRoyaltyReportResponse{}
+	err := suite.service.AddRoyaltyReportDisputeMessage(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	report, err = suite.service.royaltyReport.GetById(context.TODO(), report.Id)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.RoyaltyReportDisputeStatusWaitingMerchant, report.Dispute.Status)
+	assert.Len(suite.T(), report.Dispute.Messages, 2)
+	assert.True(suite.T(), report.DisputeExpireAt.Seconds < time.Now().Unix())
+
+	req.Source = pkg.RoyaltyReportChangeSourceMerchant
+	req.Text = "unit-test merchant"
+	err = suite.service.AddRoyaltyReportDisputeMessage(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	report, err = suite.service.royaltyReport.GetById(context.TODO(), report.Id)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.RoyaltyReportDisputeStatusWaitingAdmin, report.Dispute.Status)
+	assert.Len(suite.T(), report.Dispute.Messages, 3)
+	assert.Equal(suite.T(), req.Text, report.Dispute.Messages[2].Text)
+	assert.True(suite.T(), report.DisputeExpireAt.Seconds > time.Now().Unix())
+}
+
+func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_AddRoyaltyReportDisputeMessage_DisputeNotOpen_Error() {
+	report, _ := suite.createPendingRoyaltyReport()
+
+	req := &grpc.AddRoyaltyReportDisputeMessageRequest{
+		MerchantId: report.MerchantId,
+		ReportId:   report.Id,
+		Source:     pkg.RoyaltyReportChangeSourceMerchant,
+		Text:       "unit-test",
+	}
+	rsp := &grpc.GetRoyaltyReportResponse{}
+	err := suite.service.AddRoyaltyReportDisputeMessage(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), royaltyReportErrorDisputeNotOpen, rsp.Message)
+}
+
+func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_ApplyRoyaltyReportDisputeCorrections_Ok() {
+	ci := &mocks.CentrifugoInterface{}
+	ci.On("Publish", mock2.Anything, mock2.Anything, mock2.Anything).Return(nil)
+	suite.service.centrifugo = ci
+
+	report, order := suite.createPendingRoyaltyReport()
+	report = suite.openRoyaltyReportDispute(report, order)
+
+	req := &grpc.ApplyRoyaltyReportDisputeCorrectionsRequest{
+		MerchantId: report.MerchantId,
+		ReportId:   report.Id,
+		Corrections: []*grpc.RoyaltyReportDisputeCorrection{
+			{ItemId: report.Dispute.Items[0].Id, Amount: 5, Reason: "unit-test order"},
+			{ItemId: report.Dispute.Items[1].Id, Amount: -2, Reason: "unit-test product"},
+		},
+		UserId: "unit-test-admin",
+		Ip:     "127.0.0.1",
+	}
+	rsp := &grpc.GetRoyaltyReportResponse{}
+	err := suite.service.ApplyRoyaltyReportDisputeCorrections(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Empty(suite.T(), rsp.Message)
+
+	report, err = suite.service.royaltyReport.GetById(context.TODO(), report.Id)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), report.Summary.Corrections, 2)
+	assert.Equal(suite.T(), float64(3), report.Totals.CorrectionAmount)
+
+	for _, v := range report.Dispute.Items {
+		assert.NotNil(suite.T(), v.Correction)
+		assert.NotEmpty(suite.T(), v.Correction.AccountingEntryId)
+	}
+
+	oid, err := primitive.ObjectIDFromHex(report.Dispute.Items[0].Correction.AccountingEntryId)
+	assert.NoError(suite.T(), err)
+	ae := &billing.AccountingEntry{}
+	err = suite.service.db.Collection(collectionAccountingEntry).FindOne(context.TODO(), bson.M{"_id": oid}).Decode(ae)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.AccountingEntryTypeMerchantRoyaltyCorrection, ae.Type)
+	assert.Equal(suite.T(), float64(5), ae.Amount)
+
+	oid, _ = primitive.ObjectIDFromHex(report.Id)
+	query := bson.M{"_id": oid, "dispute.items.correction.entry_date": bson.M{"$type": "date"}}
+	count, err := suite.service.db.Collection(collectionRoyaltyReport).CountDocuments(context.TODO(), query)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, count)
+
+	req.Corrections = req.Corrections[:1]
+	rsp = &grpc.GetRoyaltyReportResponse{}
+	err = suite.service.ApplyRoyaltyReportDisputeCorrections(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), royaltyReportErrorDisputeItemAlreadyCorrected, rsp.Message)
+}
+
+func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_ApplyRoyaltyReportDisputeCorrections_ItemNotFound_Error() {
+	ci := &mocks.CentrifugoInterface{}
+	ci.On("Publish", mock2.Anything, mock2.Anything, mock2.Anything).Return(nil)
+	suite.service.centrifugo = ci
+
+	report, order := suite.createPendingRoyaltyReport()
+	report = suite.openRoyaltyReportDispute(report, order)
+
+	req := &grpc.ApplyRoyaltyReportDisputeCorrectionsRequest{
+		MerchantId: report.MerchantId,
+		ReportId:   report.Id,
+		Corrections: []*grpc.RoyaltyReportDisputeCorrection{
+			{ItemId: primitive.NewObjectID().Hex(), Amount: 5, Reason: "unit-test"},
+		},
+	}
+	rsp := &grpc.GetRoyaltyReportResponse{}
+	err := suite.service.ApplyRoyaltyReportDisputeCorrections(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), royaltyReportErrorDisputeCorrectionItemNotFound, rsp.Message)
+}
+
+func (suite *RoyaltyReportTestSuite) TestRoyaltyReport_AutoEscalateRoyaltyReportDisputes_Ok() {
+	ci := &mocks.CentrifugoInterface{}
+	ci.On("Publish", mock2.Anything, mock2.Anything, mock2.Anything).Return(nil)
+	suite.service.centrifugo = ci
+
+	report, order := suite.createPendingRoyaltyReport()
+	suite.openRoyaltyReportDispute(report, order)
+
+	oid, err := primitive.ObjectIDFromHex(report.Id)
+	assert.NoError(suite.T(), err)
+	_, err = suite.service.db.Collection(collectionRoyaltyReport).UpdateOne(
+		context.TODO(),
+		bson.M{"_id": oid},
+		bson.M{"$set": bson.M{"dispute_expire_at": time.Now().Add(-time.Hour)}},
+	)
+	assert.NoError(suite.T(), err)
+
+	ci = &mocks.CentrifugoInterface{}
+	ci.On("Publish", mock2.Anything, mock2.Anything, mock2.Anything).Return(nil)
+	suite.service.centrifugo = ci
+
+	err = suite.service.AutoEscalateRoyaltyReportDisputes(context.TODO(), &grpc.EmptyRequest{}, &grpc.EmptyResponse{})
+	assert.NoError(suite.T(), err)
+
+	report, err = suite.service.royaltyReport.GetById(context.TODO(), report.Id)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), report.Dispute.IsEscalated)
+	assert.Len(suite.T(), report.Dispute.Messages, 2)
+	assert.Equal(suite.T(), pkg.RoyaltyReportChangeSourceAuto, report.Dispute.Messages[1].Source)
+	assert.NotNil(suite.T(), report.Dispute.EscalatedAt)
+
+	query := bson.M{
+		"_id":                         oid,
+		"dispute.escalated_at":        bson.M{"$type": "date"},
+		"dispute.messages.created_at": bson.M{"$type": "date"},
+	}
+	count, err := suite.service.db.Collection(collectionRoyaltyReport).CountDocuments(context.TODO(), query)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, count)
+
+	ci.AssertNumberOfCalls(suite.T(), "Publish", 1)
+	ci.AssertCalled(suite.T(), "Publish", mock2.Anything, suite.service.cfg.CentrifugoAdminChannel, mock2.Anything)
+}
@@ -21,8 +21,10 @@ import (
 	"gopkg.in/ProtocolONE/rabbitmq.v1/pkg"
 	"gopkg.in/gomail.v2"
 	mongodb "gopkg.in/paysuper/paysuper-database-mongo.v1"
+	"net/http"
 	"strings"
 	"sync"
+	"time"
 )
 
 const (
@@ -74,6 +76,21 @@ type Service struct {
 	paymentMethod              PaymentMethodInterface
 	priceGroup                 PriceGroupServiceInterface
 	paymentSystem              PaymentSystemServiceInterface
+	paymentSystemProviders     *PaymentSystemProviderRegistry
+	paymentRoutingRule         PaymentRoutingRuleServiceInterface
+	webhookEvent               WebhookEventRepositoryInterface
+	webhookHttpClient          *http.Client
+	settlementReport           SettlementReportRepositoryInterface
+	riskRule                   RiskRuleServiceInterface
+	subscriptionPlan           SubscriptionPlanRepositoryInterface
+	subscription               SubscriptionRepositoryInterface
+	refundApprovalThreshold    RefundApprovalThresholdRepositoryInterface
+	dispute                    DisputeRepositoryInterface
+	bulkRefundJob              BulkRefundJobRepositoryInterface
+	coupon                     CouponRepositoryInterface
+	storeCredit                StoreCreditRepositoryInterface
+	ledger                     LedgerRepositoryInterface
+	checkAccountHttpClient     *http.Client
 	zipCode                    *ZipCode
 	paymentChannelCostSystem   *PaymentChannelCostSystem
 	paymentChannelCostMerchant *PaymentChannelCostMerchant
@@ -86,6 +103,7 @@ type Service struct {
 	documentSigner             documentSignerProto.DocumentSignerService
 	merchantTariffRates        MerchantTariffRatesInterface
 	keyRepository              KeyRepositoryInterface
+	keyReservationSchedule     KeyReservationScheduleInterface
 	dashboardRepository        DashboardRepositoryInterface
 	orderRepository            OrderRepositoryInterface
 	userRoleRepository         UserRoleServiceInterface
@@ -164,6 +182,32 @@ func (s *Service) Init() (err error) {
 	s.project = newProjectService(s)
 	s.priceGroup = newPriceGroupService(s)
 	s.paymentSystem = newPaymentSystemService(s)
+	s.paymentSystemProviders, err = newDefaultPaymentSystemProviderRegistry(s.cfg)
+
+	if err != nil {
+		return err
+	}
+
+	err = s.paymentSystemProviders.Register(newStoreCreditPaymentSystemProvider(s))
+
+	if err != nil {
+		return err
+	}
+
+	s.paymentRoutingRule = newPaymentRoutingRuleService(s)
+	s.webhookEvent = newWebhookEventRepository(s)
+	s.webhookHttpClient = &http.Client{Timeout: defaultHttpClientTimeout * time.Second}
+	s.settlementReport = newSettlementReportRepository(s)
+	s.riskRule = newRiskRuleService(s)
+	s.subscriptionPlan = newSubscriptionPlanRepository(s)
+	s.subscription = newSubscriptionRepository(s)
+	s.refundApprovalThreshold = newRefundApprovalThresholdRepository(s)
+	s.dispute = newDisputeRepository(s)
+	s.bulkRefundJob = newBulkRefundJobRepository(s)
+	s.coupon = newCouponRepository(s)
+	s.storeCredit = newStoreCreditRepository(s)
+	s.ledger = newLedgerRepository(s)
+	s.checkAccountHttpClient = &http.Client{Timeout: s.cfg.GetCheckAccountTimeout()}
 	s.zipCode = newZipCodeService(s)
 	s.paymentChannelCostSystem = newPaymentChannelCostSystemService(s)
 	s.paymentChannelCostMerchant = newPaymentChannelCostMerchantService(s)
@@ -175,6 +219,7 @@ func (s *Service) Init() (err error) {
 	s.turnover = newTurnoverService(s)
 	s.merchantTariffRates = newMerchantsTariffRatesRepository(s)
 	s.keyRepository = newKeyRepository(s)
+	s.keyReservationSchedule = newKeyReservationSchedule(s)
 	s.dashboardRepository = newDashboardRepository(s)
 	s.orderRepository = newOrderRepository(s)
 	s.userRoleRepository = newUserRoleRepository(s)
@@ -185,6 +230,10 @@ func (s *Service) Init() (err error) {
 	s.operatingCompany = newOperatingCompanyService(s)
 	s.paymentMinLimitSystem = newPaymentMinLimitSystem(s)
 
+	if s.redis == nil {
+		zap.L().Error("redis client not configured, requests with idempotency keys will be rejected")
+	}
+
 	sCurr, err := s.curService.GetSupportedCurrencies(context.TODO(), &currencies.EmptyRequest{})
 	if err != nil {
 		zap.S().Error(
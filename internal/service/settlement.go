@@ -0,0 +1,774 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	curPkg "github.com/paysuper/paysuper-currencies/pkg"
+	"github.com/paysuper/paysuper-currencies/pkg/proto/currencies"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/constant"
+	"github.com/paysuper/paysuper-recurring-repository/tools"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	collectionSettlementReport = "settlement_report"
+
+	settlementReportFileName        = "settlement_report_%s.csv"
+	settlementReportFileContentType = "text/csv"
+)
+
+var (
+	settlementErrorPaymentSystemNotFound = newBillingServerErrorMsg("st000001", "payment system with specified identifier not found")
+	settlementErrorFileInvalid           = newBillingServerErrorMsg("st000002", "settlement file is invalid")
+	settlementErrorReportNotFound        = newBillingServerErrorMsg("st000003", "settlement report with specified identifier not found")
+
+	// statuses of orders which were processed by payment system and must be present in settlement file
+	settlementOrderStatuses = []int32{
+		constant.OrderStatusPaymentSystemComplete,
+		constant.OrderStatusProjectComplete,
+		constant.OrderStatusRefund,
+		constant.OrderStatusChargeback,
+	}
+
+	// accounting entries with real fees of payment system
+	settlementPaymentFeeEntryTypes = []string{
+		pkg.AccountingEntryTypeRealMerchantMethodFixedFeeCostValue,
+	}
+	settlementRefundFeeEntryTypes = []string{
+		pkg.AccountingEntryTypeRealRefundFee,
+		pkg.AccountingEntryTypeRealRefundFixedFee,
+	}
+
+	settlementFileColumns = []string{"type", "transaction", "amount", "currency", "fee", "fee_currency"}
+)
+
+type SettlementReportRepositoryInterface interface {
+	Insert(ctx context.Context, report *billing.SettlementReport) error
+	GetById(ctx context.Context, id string) (*billing.SettlementReport, error)
+	Find(ctx context.Context, query bson.M, offset, limit int64) ([]*billing.SettlementReport, error)
+	Count(ctx context.Context, query bson.M) (int64, error)
+}
+
+func newSettlementReportRepository(svc *Service) SettlementReportRepositoryInterface {
+	s := &SettlementReport{svc: svc}
+	return s
+}
+
+type settlementLine struct {
+	Type        string  `json:"type"`
+	Transaction string  `json:"transaction"`
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+	Fee         float64 `json:"fee"`
+	FeeCurrency string  `json:"fee_currency"`
+}
+
+// ImportSettlementFile parses settlement file of payment system and reconciles lines of the file with orders and
+// refunds processed by the payment system in the period. Report with found discrepancies saved to database
+// and can be downloaded by GetSettlementReportFile.
+func (s *Service) ImportSettlementFile(
+	ctx context.Context,
+	req *grpc.ImportSettlementFileRequest,
+	rsp *grpc.ImportSettlementFileResponse,
+) error {
+	ps, err := s.paymentSystem.GetById(ctx, req.PaymentSystemId)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusNotFound
+		rsp.Message = settlementErrorPaymentSystemNotFound
+		return nil
+	}
+
+	lines, err := parseSettlementFile(req.FileType, req.File)
+
+	if err != nil {
+		zap.L().Error(
+			settlementErrorFileInvalid.Message,
+			zap.Error(err),
+			zap.String("payment_system_id", req.PaymentSystemId),
+			zap.String("file_type", req.FileType),
+		)
+
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = newBillingServerErrorMsg(settlementErrorFileInvalid.Code, settlementErrorFileInvalid.Message, err.Error())
+		return nil
+	}
+
+	report := &billing.SettlementReport{
+		Id:              primitive.NewObjectID().Hex(),
+		PaymentSystemId: ps.Id,
+		FileType:        req.FileType,
+		Totals:          &billing.SettlementReportTotals{LinesCount: int32(len(lines))},
+		Items:           []*billing.SettlementReportItem{},
+		CreatedAt:       ptypes.TimestampNow(),
+	}
+	report.PeriodFrom, _ = ptypes.TimestampProto(time.Unix(req.PeriodFrom, 0))
+	report.PeriodTo, _ = ptypes.TimestampProto(time.Unix(req.PeriodTo, 0))
+
+	err = s.reconcileSettlement(ctx, report, lines)
+
+	if err == nil {
+		err = s.settlementReport.Insert(ctx, report)
+	}
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = orderErrorUnknown
+		return nil
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = report
+
+	return nil
+}
+
+func (s *Service) ListSettlementReports(
+	ctx context.Context,
+	req *grpc.ListSettlementReportsRequest,
+	rsp *grpc.ListSettlementReportsResponse,
+) error {
+	query := bson.M{}
+
+	if req.PaymentSystemId != "" {
+		query["payment_system_id"], _ = primitive.ObjectIDFromHex(req.PaymentSystemId)
+	}
+
+	count, err := s.settlementReport.Count(ctx, query)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = orderErrorUnknown
+		return nil
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = &grpc.ListSettlementReportsResponseItem{Count: count}
+
+	if count <= 0 {
+		return nil
+	}
+
+	limit := req.Limit
+
+	if limit <= 0 {
+		limit = pkg.DatabaseRequestDefaultLimit
+	}
+
+	rsp.Item.Items, err = s.settlementReport.Find(ctx, query, req.Offset, limit)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = orderErrorUnknown
+		rsp.Item = nil
+	}
+
+	return nil
+}
+
+// GetSettlementReportFile returns discrepancies of settlement report as csv file.
+func (s *Service) GetSettlementReportFile(
+	ctx context.Context,
+	req *grpc.GetSettlementReportFileRequest,
+	rsp *grpc.GetSettlementReportFileResponse,
+) error {
+	report, err := s.settlementReport.GetById(ctx, req.Id)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			rsp.Status = pkg.ResponseStatusNotFound
+			rsp.Message = settlementErrorReportNotFound
+			return nil
+		}
+
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = orderErrorUnknown
+		return nil
+	}
+
+	file, err := getSettlementReportCsv(report)
+
+	if err != nil {
+		zap.L().Error("settlement report file generation failed", zap.Error(err), zap.String("id", req.Id))
+
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = orderErrorUnknown
+		return nil
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.FileName = fmt.Sprintf(settlementReportFileName, report.Id)
+	rsp.ContentType = settlementReportFileContentType
+	rsp.File = file
+
+	return nil
+}
+
+func (s *Service) reconcileSettlement(ctx context.Context, report *billing.SettlementReport, lines []*settlementLine) error {
+	payments := make(map[string]*settlementLine)
+	refunds := make(map[string]*settlementLine)
+
+	for _, line := range lines {
+		byTransaction := payments
+
+		if line.Type == pkg.SettlementTransactionTypeRefund {
+			byTransaction = refunds
+		}
+
+		if _, ok := byTransaction[line.Transaction]; ok {
+			addSettlementReportItem(report, newSettlementReportExtraItem(line))
+			continue
+		}
+
+		byTransaction[line.Transaction] = line
+	}
+
+	psOid, _ := primitive.ObjectIDFromHex(report.PaymentSystemId)
+	from, _ := ptypes.Timestamp(report.PeriodFrom)
+	to, _ := ptypes.Timestamp(report.PeriodTo)
+
+	orders, err := s.getSettlementOrders(ctx, psOid, from, to, getSettlementTransactions(payments))
+
+	if err != nil {
+		return err
+	}
+
+	for _, order := range orders {
+		item := &billing.SettlementReportItem{
+			Type:             pkg.SettlementTransactionTypePayment,
+			Transaction:      order.Transaction,
+			OrderId:          order.Id,
+			ExpectedAmount:   order.ChargeAmount,
+			ExpectedCurrency: order.ChargeCurrency,
+		}
+		line, ok := payments[order.Transaction]
+
+		if ok {
+			delete(payments, order.Transaction)
+		}
+
+		err = s.checkSettlementLine(ctx, report, item, line, order.Id, collectionOrder, settlementPaymentFeeEntryTypes)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	refundList, err := s.getSettlementRefunds(ctx, psOid, from, to, getSettlementTransactions(refunds))
+
+	if err != nil {
+		return err
+	}
+
+	for _, refund := range refundList {
+		item := &billing.SettlementReportItem{
+			Type:             pkg.SettlementTransactionTypeRefund,
+			Transaction:      refund.ExternalId,
+			OrderId:          refund.OriginalOrder.Id,
+			RefundId:         refund.Id,
+			ExpectedAmount:   refund.Amount,
+			ExpectedCurrency: refund.Currency,
+		}
+		line, ok := refunds[refund.ExternalId]
+
+		if ok {
+			delete(refunds, refund.ExternalId)
+		}
+
+		err = s.checkSettlementLine(ctx, report, item, line, refund.CreatedOrderId, collectionRefund, settlementRefundFeeEntryTypes)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, byTransaction := range []map[string]*settlementLine{payments, refunds} {
+		for _, line := range byTransaction {
+			addSettlementReportItem(report, newSettlementReportExtraItem(line))
+		}
+	}
+
+	return nil
+}
+
+// checkSettlementLine compares line of settlement file with expected amount of item and
+// with real fees from accounting entries of item source. Item added to report if line is missing
+// or it doesn't match the expected values.
+func (s *Service) checkSettlementLine(
+	ctx context.Context,
+	report *billing.SettlementReport,
+	item *billing.SettlementReportItem,
+	line *settlementLine,
+	sourceId, sourceType string,
+	feeEntryTypes []string,
+) error {
+	if line == nil {
+		item.Status = pkg.SettlementReportItemStatusMissing
+		addSettlementReportItem(report, item)
+		return nil
+	}
+
+	item.SettledAmount = line.Amount
+	item.SettledCurrency = line.Currency
+	item.SettledFee = line.Fee
+	item.SettledFeeCurrency = line.FeeCurrency
+
+	if tools.FormatAmount(item.ExpectedAmount) != tools.FormatAmount(line.Amount) ||
+		!strings.EqualFold(item.ExpectedCurrency, line.Currency) {
+		item.Status = pkg.SettlementReportItemStatusAmountMismatch
+		addSettlementReportItem(report, item)
+		return nil
+	}
+
+	sourceOid, _ := primitive.ObjectIDFromHex(sourceId)
+	query := bson.M{
+		"source.id":   sourceOid,
+		"source.type": sourceType,
+		"type":        bson.M{"$in": feeEntryTypes},
+	}
+	cursor, err := s.db.Collection(collectionAccountingEntry).Find(ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionAccountingEntry),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return err
+	}
+
+	var entries []*billing.AccountingEntry
+	err = cursor.All(ctx, &entries)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionAccountingEntry),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return err
+	}
+
+	item.ExpectedFeeCurrency = line.FeeCurrency
+
+	if item.ExpectedFeeCurrency == "" && len(entries) > 0 {
+		item.ExpectedFeeCurrency = entries[0].Currency
+	}
+
+	for _, entry := range entries {
+		amount, err := s.getSettlementFeeAmount(ctx, entry, item.ExpectedFeeCurrency)
+
+		if err != nil {
+			return err
+		}
+
+		item.ExpectedFee += amount
+	}
+
+	if tools.FormatAmount(item.ExpectedFee) != tools.FormatAmount(line.Fee) {
+		item.Status = pkg.SettlementReportItemStatusFeeMismatch
+		addSettlementReportItem(report, item)
+		return nil
+	}
+
+	report.Totals.MatchedCount++
+
+	return nil
+}
+
+// getSettlementFeeAmount returns amount of fee accounting entry in currency of settlement line fee.
+func (s *Service) getSettlementFeeAmount(ctx context.Context, entry *billing.AccountingEntry, currency string) (float64, error) {
+	if entry.Amount == 0 || strings.EqualFold(entry.Currency, currency) {
+		return entry.Amount, nil
+	}
+
+	req := &currencies.ExchangeCurrencyCurrentCommonRequest{
+		From:              entry.Currency,
+		To:                currency,
+		RateType:          curPkg.RateTypePaysuper,
+		ExchangeDirection: curPkg.ExchangeDirectionBuy,
+		Amount:            entry.Amount,
+	}
+	rsp, err := s.curService.ExchangeCurrencyCurrentCommon(ctx, req)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorGrpcServiceCallFailed,
+			zap.Error(err),
+			zap.String(errorFieldService, "CurrencyRatesService"),
+			zap.String(errorFieldMethod, "ExchangeCurrencyCurrentCommon"),
+			zap.Any(errorFieldRequest, req),
+		)
+		return 0, accountingEntryErrorExchangeFailed
+	}
+
+	return rsp.ExchangedAmount, nil
+}
+
+func (s *Service) getSettlementOrders(
+	ctx context.Context,
+	psOid primitive.ObjectID,
+	from, to time.Time,
+	transactions []string,
+) ([]*billing.Order, error) {
+	query := bson.M{
+		"payment_method.payment_system_id": psOid,
+		"private_status":                   bson.M{"$in": settlementOrderStatuses},
+		"type":                             bson.M{"$ne": pkg.OrderTypeRefund},
+		"$or": []bson.M{
+			{"pm_order_id": bson.M{"$in": transactions}},
+			{"pm_order_close_date": bson.M{"$gte": from, "$lt": to}},
+		},
+	}
+	cursor, err := s.db.Collection(collectionOrder).Find(ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionOrder),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	var orders []*billing.Order
+	err = cursor.All(ctx, &orders)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionOrder),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// getSettlementRefunds returns completed refunds of orders paid through the payment system
+// which were completed in the period or have transaction from settlement file.
+func (s *Service) getSettlementRefunds(
+	ctx context.Context,
+	psOid primitive.ObjectID,
+	from, to time.Time,
+	transactions []string,
+) ([]*billing.Refund, error) {
+	query := []bson.M{
+		{
+			"$match": bson.M{
+				"status": pkg.RefundStatusCompleted,
+				"$or": []bson.M{
+					{"external_id": bson.M{"$in": transactions}},
+					{"updated_at": bson.M{"$gte": from, "$lt": to}},
+				},
+			},
+		},
+		{
+			"$lookup": bson.M{
+				"from":         collectionOrder,
+				"localField":   "original_order.id",
+				"foreignField": "_id",
+				"as":           "original_order_data",
+			},
+		},
+		{"$match": bson.M{"original_order_data.payment_method.payment_system_id": psOid}},
+		{"$project": bson.M{"original_order_data": 0}},
+	}
+	cursor, err := s.db.Collection(collectionRefund).Aggregate(ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionRefund),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	var refunds []*billing.Refund
+	err = cursor.All(ctx, &refunds)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionRefund),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	return refunds, nil
+}
+
+func parseSettlementFile(fileType string, file []byte) ([]*settlementLine, error) {
+	var (
+		lines []*settlementLine
+		err   error
+	)
+
+	switch fileType {
+	case pkg.SettlementFileTypeCsv:
+		lines, err = parseSettlementCsv(file)
+	case pkg.SettlementFileTypeJson:
+		err = json.Unmarshal(file, &lines)
+	default:
+		err = fmt.Errorf("unsupported settlement file type %s", fileType)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, line := range lines {
+		if line == nil || line.Transaction == "" {
+			return nil, fmt.Errorf("transaction of line %d is empty", i+1)
+		}
+
+		if line.Type != pkg.SettlementTransactionTypePayment && line.Type != pkg.SettlementTransactionTypeRefund {
+			return nil, fmt.Errorf("type of line %d is incorrect", i+1)
+		}
+	}
+
+	return lines, nil
+}
+
+func parseSettlementCsv(file []byte) ([]*settlementLine, error) {
+	r := csv.NewReader(bytes.NewReader(file))
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+
+	for i, v := range header {
+		columns[strings.ToLower(strings.TrimSpace(v))] = i
+	}
+
+	for _, v := range settlementFileColumns {
+		if _, ok := columns[v]; !ok {
+			return nil, fmt.Errorf("column %s not found in settlement file", v)
+		}
+	}
+
+	var lines []*settlementLine
+
+	for {
+		record, err := r.Read()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		line := &settlementLine{
+			Type:        record[columns["type"]],
+			Transaction: record[columns["transaction"]],
+			Currency:    record[columns["currency"]],
+			FeeCurrency: record[columns["fee_currency"]],
+		}
+
+		if line.Amount, err = strconv.ParseFloat(record[columns["amount"]], 64); err != nil {
+			return nil, err
+		}
+
+		if line.Fee, err = strconv.ParseFloat(record[columns["fee"]], 64); err != nil {
+			return nil, err
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines, nil
+}
+
+func getSettlementReportCsv(report *billing.SettlementReport) ([]byte, error) {
+	b := new(bytes.Buffer)
+	w := csv.NewWriter(b)
+	header := []string{
+		"type", "status", "transaction", "order_id", "refund_id", "expected_amount", "expected_currency",
+		"settled_amount", "settled_currency", "expected_fee", "expected_fee_currency", "settled_fee",
+		"settled_fee_currency",
+	}
+
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	for _, item := range report.Items {
+		record := []string{
+			item.Type,
+			item.Status,
+			item.Transaction,
+			item.OrderId,
+			item.RefundId,
+			strconv.FormatFloat(item.ExpectedAmount, 'f', 2, 64),
+			item.ExpectedCurrency,
+			strconv.FormatFloat(item.SettledAmount, 'f', 2, 64),
+			item.SettledCurrency,
+			strconv.FormatFloat(item.ExpectedFee, 'f', 2, 64),
+			item.ExpectedFeeCurrency,
+			strconv.FormatFloat(item.SettledFee, 'f', 2, 64),
+			item.SettledFeeCurrency,
+		}
+
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+
+	return b.Bytes(), w.Error()
+}
+
+func getSettlementTransactions(byTransaction map[string]*settlementLine) []string {
+	transactions := make([]string, 0, len(byTransaction))
+
+	for transaction := range byTransaction {
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions
+}
+
+func newSettlementReportExtraItem(line *settlementLine) *billing.SettlementReportItem {
+	return &billing.SettlementReportItem{
+		Type:               line.Type,
+		Status:             pkg.SettlementReportItemStatusExtra,
+		Transaction:        line.Transaction,
+		SettledAmount:      line.Amount,
+		SettledCurrency:    line.Currency,
+		SettledFee:         line.Fee,
+		SettledFeeCurrency: line.FeeCurrency,
+	}
+}
+
+func addSettlementReportItem(report *billing.SettlementReport, item *billing.SettlementReportItem) {
+	switch item.Status {
+	case pkg.SettlementReportItemStatusMissing:
+		report.Totals.MissingCount++
+	case pkg.SettlementReportItemStatusExtra:
+		report.Totals.ExtraCount++
+	case pkg.SettlementReportItemStatusAmountMismatch:
+		report.Totals.AmountMismatchCount++
+	case pkg.SettlementReportItemStatusFeeMismatch:
+		report.Totals.FeeMismatchCount++
+	}
+
+	report.Items = append(report.Items, item)
+}
+
+func (h *SettlementReport) Insert(ctx context.Context, report *billing.SettlementReport) error {
+	_, err := h.svc.db.Collection(collectionSettlementReport).InsertOne(ctx, report)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionSettlementReport),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationInsert),
+			zap.Any(pkg.ErrorDatabaseFieldDocument, report),
+		)
+	}
+
+	return err
+}
+
+func (h *SettlementReport) GetById(ctx context.Context, id string) (*billing.SettlementReport, error) {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	query := bson.M{"_id": oid}
+	report := &billing.SettlementReport{}
+	err := h.svc.db.Collection(collectionSettlementReport).FindOne(ctx, query).Decode(report)
+
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			zap.L().Error(
+				pkg.ErrorDatabaseQueryFailed,
+				zap.Error(err),
+				zap.String(pkg.ErrorDatabaseFieldCollection, collectionSettlementReport),
+				zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+			)
+		}
+
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func (h *SettlementReport) Find(ctx context.Context, query bson.M, offset, limit int64) ([]*billing.SettlementReport, error) {
+	opts := options.Find().
+		SetSort(bson.M{"created_at": -1}).
+		SetSkip(offset).
+		SetLimit(limit)
+	cursor, err := h.svc.db.Collection(collectionSettlementReport).Find(ctx, query, opts)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionSettlementReport),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	var reports []*billing.SettlementReport
+	err = cursor.All(ctx, &reports)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionSettlementReport),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	return reports, nil
+}
+
+func (h *SettlementReport) Count(ctx context.Context, query bson.M) (int64, error) {
+	count, err := h.svc.db.Collection(collectionSettlementReport).CountDocuments(ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionSettlementReport),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationCount),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+	}
+
+	return count, err
+}
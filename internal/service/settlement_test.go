@@ -0,0 +1,334 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis"
+	"github.com/golang-migrate/migrate/v4"
+	casbinMocks "github.com/paysuper/casbin-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/internal/config"
+	"github.com/paysuper/paysuper-billing-server/internal/database"
+	"github.com/paysuper/paysuper-billing-server/internal/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	reportingMocks "github.com/paysuper/paysuper-reporter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	rabbitmq "gopkg.in/ProtocolONE/rabbitmq.v1/pkg"
+	mongodb "gopkg.in/paysuper/paysuper-database-mongo.v1"
+	"strings"
+	"testing"
+	"time"
+)
+
+type SettlementTestSuite struct {
+	suite.Suite
+	service *Service
+	cache   CacheInterface
+
+	project       *billing.Project
+	paymentMethod *billing.PaymentMethod
+	paymentSystem *billing.PaymentSystem
+}
+
+func Test_Settlement(t *testing.T) {
+	suite.Run(t, new(SettlementTestSuite))
+}
+
+func (suite *SettlementTestSuite) SetupTest() {
+	cfg, err := config.NewConfig()
+	if err != nil {
+		suite.FailNow("Config load failed", "%v", err)
+	}
+	cfg.CardPay.ApiUrl = "https://sandbox.cardpay.com"
+
+	m, err := migrate.New(
+		"file://../../migrations/tests",
+		cfg.MongoDsn)
+	assert.NoError(suite.T(), err, "Migrate init failed")
+
+	err = m.Up()
+	if err != nil && err.Error() != "no change" {
+		suite.FailNow("Migrations failed", "%v", err)
+	}
+
+	db, err := mongodb.NewDatabase()
+	if err != nil {
+		suite.FailNow("Database connection failed", "%v", err)
+	}
+
+	broker, err := rabbitmq.NewBroker(cfg.BrokerAddress)
+
+	if err != nil {
+		suite.FailNow("Creating RabbitMQ publisher failed", "%v", err)
+	}
+
+	redisClient := database.NewRedis(
+		&redis.Options{
+			Addr:     cfg.RedisHost,
+			Password: cfg.RedisPassword,
+		},
+	)
+
+	redisdb := mocks.NewTestRedis()
+	suite.cache, err = NewCacheRedis(redisdb, "cache")
+	suite.service = NewBillingService(
+		db,
+		cfg,
+		mocks.NewGeoIpServiceTestOk(),
+		mocks.NewRepositoryServiceOk(),
+		mocks.NewTaxServiceOkMock(),
+		broker,
+		redisClient,
+		suite.cache,
+		mocks.NewCurrencyServiceMockOk(),
+		mocks.NewDocumentSignerMockOk(),
+		&reportingMocks.ReporterService{},
+		mocks.NewFormatterOK(),
+		broker,
+		&casbinMocks.CasbinService{},
+	)
+
+	if err := suite.service.Init(); err != nil {
+		suite.FailNow("Billing service initialization failed", "%v", err)
+	}
+
+	_, suite.project, suite.paymentMethod, suite.paymentSystem = helperCreateEntitiesForTests(suite.Suite, suite.service)
+}
+
+func (suite *SettlementTestSuite) TearDownTest() {
+	if err := suite.service.db.Drop(); err != nil {
+		suite.FailNow("Database deletion failed", "%v", err)
+	}
+
+	if err := suite.service.db.Close(); err != nil {
+		suite.FailNow("Database close failed", "%v", err)
+	}
+}
+
+func (suite *SettlementTestSuite) getSettlementLine(
+	txType, transaction string,
+	amount float64,
+	currency, sourceId, sourceType string,
+	feeEntryTypes []string,
+) *settlementLine {
+	oid, _ := primitive.ObjectIDFromHex(sourceId)
+	query := bson.M{"source.id": oid, "source.type": sourceType, "type": bson.M{"$in": feeEntryTypes}}
+	cursor, err := suite.service.db.Collection(collectionAccountingEntry).Find(context.TODO(), query)
+	assert.NoError(suite.T(), err)
+
+	var entries []*billing.AccountingEntry
+	err = cursor.All(context.TODO(), &entries)
+	assert.NoError(suite.T(), err)
+	assert.NotEmpty(suite.T(), entries)
+
+	line := &settlementLine{
+		Type:        txType,
+		Transaction: transaction,
+		Amount:      amount,
+		Currency:    currency,
+	}
+
+	for _, entry := range entries {
+		line.Fee += entry.Amount
+		line.FeeCurrency = entry.Currency
+	}
+
+	return line
+}
+
+func (suite *SettlementTestSuite) importSettlementFile(fileType string, file []byte) *grpc.ImportSettlementFileResponse {
+	req := &grpc.ImportSettlementFileRequest{
+		PaymentSystemId: suite.paymentSystem.Id,
+		FileType:        fileType,
+		File:            file,
+		PeriodFrom:      time.Now().Add(-time.Hour).Unix(),
+		PeriodTo:        time.Now().Add(time.Hour).Unix(),
+	}
+	rsp := &grpc.ImportSettlementFileResponse{}
+	err := suite.service.ImportSettlementFile(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+
+	return rsp
+}
+
+func (suite *SettlementTestSuite) TestSettlement_ImportSettlementFile_Json_Ok() {
+	order := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+	missingOrder := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+	refund := helperMakeRefund(suite.Suite, suite.service, order, order.ChargeAmount, false)
+
+	lines := []*settlementLine{
+		suite.getSettlementLine(
+			pkg.SettlementTransactionTypePayment,
+			order.Transaction,
+			order.ChargeAmount,
+			order.ChargeCurrency,
+			order.Id,
+			collectionOrder,
+			settlementPaymentFeeEntryTypes,
+		),
+		suite.getSettlementLine(
+			pkg.SettlementTransactionTypeRefund,
+			refund.ExternalId,
+			refund.Amount,
+			refund.Currency,
+			refund.CreatedOrderId,
+			collectionRefund,
+			settlementRefundFeeEntryTypes,
+		),
+		{
+			Type:        pkg.SettlementTransactionTypePayment,
+			Transaction: primitive.NewObjectID().Hex(),
+			Amount:      10,
+			Currency:    "RUB",
+		},
+	}
+	file, err := json.Marshal(lines)
+	assert.NoError(suite.T(), err)
+
+	rsp := suite.importSettlementFile(pkg.SettlementFileTypeJson, file)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.NotNil(suite.T(), rsp.Item)
+	assert.EqualValues(suite.T(), 3, rsp.Item.Totals.LinesCount)
+	assert.EqualValues(suite.T(), 2, rsp.Item.Totals.MatchedCount)
+	assert.EqualValues(suite.T(), 1, rsp.Item.Totals.MissingCount)
+	assert.EqualValues(suite.T(), 1, rsp.Item.Totals.ExtraCount)
+	assert.Zero(suite.T(), rsp.Item.Totals.AmountMismatchCount)
+	assert.Zero(suite.T(), rsp.Item.Totals.FeeMismatchCount)
+	assert.Len(suite.T(), rsp.Item.Items, 2)
+
+	for _, item := range rsp.Item.Items {
+		switch item.Status {
+		case pkg.SettlementReportItemStatusMissing:
+			assert.Equal(suite.T(), missingOrder.Id, item.OrderId)
+			assert.Equal(suite.T(), missingOrder.Transaction, item.Transaction)
+		case pkg.SettlementReportItemStatusExtra:
+			assert.Equal(suite.T(), lines[2].Transaction, item.Transaction)
+			assert.Empty(suite.T(), item.OrderId)
+		default:
+			assert.Fail(suite.T(), "unexpected settlement report item status", item.Status)
+		}
+	}
+
+	report, err := suite.service.settlementReport.GetById(context.TODO(), rsp.Item.Id)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), rsp.Item.Totals.MatchedCount, report.Totals.MatchedCount)
+	assert.Len(suite.T(), report.Items, 2)
+}
+
+func (suite *SettlementTestSuite) TestSettlement_ImportSettlementFile_Csv_Mismatch() {
+	order1 := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+	order2 := helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+
+	line1 := suite.getSettlementLine(
+		pkg.SettlementTransactionTypePayment,
+		order1.Transaction,
+		order1.ChargeAmount,
+		order1.ChargeCurrency,
+		order1.Id,
+		collectionOrder,
+		settlementPaymentFeeEntryTypes,
+	)
+	line2 := suite.getSettlementLine(
+		pkg.SettlementTransactionTypePayment,
+		order2.Transaction,
+		order2.ChargeAmount,
+		order2.ChargeCurrency,
+		order2.Id,
+		collectionOrder,
+		settlementPaymentFeeEntryTypes,
+	)
+
+	file := "type,transaction,amount,currency,fee,fee_currency\n" +
+		fmt.Sprintf("payment,%s,%f,%s,%f,%s\n", line1.Transaction, line1.Amount+1, line1.Currency, line1.Fee, line1.FeeCurrency) +
+		fmt.Sprintf("payment,%s,%f,%s,%f,%s\n", line2.Transaction, line2.Amount, line2.Currency, line2.Fee+1, line2.FeeCurrency)
+
+	rsp := suite.importSettlementFile(pkg.SettlementFileTypeCsv, []byte(file))
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.EqualValues(suite.T(), 2, rsp.Item.Totals.LinesCount)
+	assert.Zero(suite.T(), rsp.Item.Totals.MatchedCount)
+	assert.EqualValues(suite.T(), 1, rsp.Item.Totals.AmountMismatchCount)
+	assert.EqualValues(suite.T(), 1, rsp.Item.Totals.FeeMismatchCount)
+
+	for _, item := range rsp.Item.Items {
+		if item.OrderId == order1.Id {
+			assert.Equal(suite.T(), pkg.SettlementReportItemStatusAmountMismatch, item.Status)
+			assert.Equal(suite.T(), order1.ChargeAmount, item.ExpectedAmount)
+		} else {
+			assert.Equal(suite.T(), order2.Id, item.OrderId)
+			assert.Equal(suite.T(), pkg.SettlementReportItemStatusFeeMismatch, item.Status)
+		}
+	}
+
+	req := &grpc.GetSettlementReportFileRequest{Id: rsp.Item.Id}
+	rsp1 := &grpc.GetSettlementReportFileResponse{}
+	err := suite.service.GetSettlementReportFile(context.TODO(), req, rsp1)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp1.Status)
+	assert.Equal(suite.T(), settlementReportFileContentType, rsp1.ContentType)
+	assert.Equal(suite.T(), fmt.Sprintf(settlementReportFileName, rsp.Item.Id), rsp1.FileName)
+
+	records := strings.Split(strings.TrimSpace(string(rsp1.File)), "\n")
+	assert.Len(suite.T(), records, 3)
+	assert.True(suite.T(), strings.HasPrefix(records[0], "type,status,transaction"))
+}
+
+func (suite *SettlementTestSuite) TestSettlement_ImportSettlementFile_FileInvalid_Error() {
+	file := "type,transaction,amount\npayment,123,10\n"
+	rsp := suite.importSettlementFile(pkg.SettlementFileTypeCsv, []byte(file))
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), settlementErrorFileInvalid.Code, rsp.Message.Code)
+	assert.NotEmpty(suite.T(), rsp.Message.Details)
+
+	rsp = suite.importSettlementFile(pkg.SettlementFileTypeJson, []byte(`[{"type":"unknown","transaction":"123"}]`))
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), settlementErrorFileInvalid.Code, rsp.Message.Code)
+}
+
+func (suite *SettlementTestSuite) TestSettlement_ImportSettlementFile_PaymentSystemNotFound_Error() {
+	req := &grpc.ImportSettlementFileRequest{
+		PaymentSystemId: primitive.NewObjectID().Hex(),
+		FileType:        pkg.SettlementFileTypeJson,
+		File:            []byte("[]"),
+		PeriodFrom:      time.Now().Add(-time.Hour).Unix(),
+		PeriodTo:        time.Now().Unix(),
+	}
+	rsp := &grpc.ImportSettlementFileResponse{}
+	err := suite.service.ImportSettlementFile(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusNotFound, rsp.Status)
+	assert.Equal(suite.T(), settlementErrorPaymentSystemNotFound, rsp.Message)
+}
+
+func (suite *SettlementTestSuite) TestSettlement_ListSettlementReports_Ok() {
+	rsp := suite.importSettlementFile(pkg.SettlementFileTypeJson, []byte("[]"))
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	req := &grpc.ListSettlementReportsRequest{PaymentSystemId: suite.paymentSystem.Id}
+	rsp1 := &grpc.ListSettlementReportsResponse{}
+	err := suite.service.ListSettlementReports(context.TODO(), req, rsp1)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp1.Status)
+	assert.EqualValues(suite.T(), 1, rsp1.Item.Count)
+	assert.Len(suite.T(), rsp1.Item.Items, 1)
+	assert.Equal(suite.T(), rsp.Item.Id, rsp1.Item.Items[0].Id)
+
+	req.PaymentSystemId = primitive.NewObjectID().Hex()
+	rsp1 = &grpc.ListSettlementReportsResponse{}
+	err = suite.service.ListSettlementReports(context.TODO(), req, rsp1)
+	assert.NoError(suite.T(), err)
+	assert.Zero(suite.T(), rsp1.Item.Count)
+}
+
+func (suite *SettlementTestSuite) TestSettlement_GetSettlementReportFile_NotFound() {
+	req := &grpc.GetSettlementReportFileRequest{Id: primitive.NewObjectID().Hex()}
+	rsp := &grpc.GetSettlementReportFileResponse{}
+	err := suite.service.GetSettlementReportFile(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusNotFound, rsp.Status)
+	assert.Equal(suite.T(), settlementErrorReportNotFound, rsp.Message)
+}
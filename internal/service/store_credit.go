@@ -0,0 +1,485 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/constant"
+	"github.com/paysuper/paysuper-recurring-repository/tools"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	collectionStoreCredit            = "store_credit"
+	collectionStoreCreditTransaction = "store_credit_transaction"
+)
+
+var (
+	errorStoreCreditCustomerNotFound     = newBillingServerErrorMsg("scr00001", "customer not found or isn't customer of merchant")
+	errorStoreCreditCurrencyInvalid      = newBillingServerErrorMsg("scr00002", "currency of store credit is not supported")
+	errorStoreCreditCustomerRequired     = newBillingServerErrorMsg("scr00003", "store credit can be used by identified customer only")
+	errorStoreCreditInsufficientBalance  = newBillingServerErrorMsg("scr00004", "balance of store credit is insufficient for payment of order")
+	errorStoreCreditOperationUnsupported = newBillingServerErrorMsg("scr00005", "operation isn't supported for payment with store credit")
+)
+
+type StoreCreditRepositoryInterface interface {
+	// Credit increases balance of store credit of customer in currency, store credit is created if it not exists
+	Credit(ctx context.Context, merchantId, customerId, currency string, amount float64) (*billing.StoreCredit, error)
+	// Debit decreases balance of store credit of customer in currency if the balance is enough for amount
+	Debit(ctx context.Context, merchantId, customerId, currency string, amount float64) (*billing.StoreCredit, error)
+	Find(ctx context.Context, merchantId, customerId string) ([]*billing.StoreCredit, error)
+	InsertTransaction(ctx context.Context, transaction *billing.StoreCreditTransaction) error
+	// WithTransaction executes fn in database transaction, changes made by fn with passed context are saved
+	// only if fn succeeded
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+func newStoreCreditRepository(svc *Service) StoreCreditRepositoryInterface {
+	s := &StoreCredit{svc: svc}
+	return s
+}
+
+// storeCreditPaymentSystem pays orders from store credit issued by merchant to customer. Payments and refunds
+// are completed inside billing at once, so the payment system doesn't have callbacks.
+type storeCreditPaymentSystem struct {
+	svc *Service
+}
+
+func newStoreCreditPaymentSystemProvider(svc *Service) *PaymentSystemProvider {
+	return &PaymentSystemProvider{
+		Handler: pkg.PaymentSystemHandlerStoreCredit,
+		New: func() PaymentSystem {
+			return &storeCreditPaymentSystem{svc: svc}
+		},
+	}
+}
+
+func (s *Service) IssueStoreCredit(
+	ctx context.Context,
+	req *grpc.IssueStoreCreditRequest,
+	rsp *grpc.StoreCreditResponse,
+) error {
+	customer, err := s.getCustomerById(ctx, req.CustomerId)
+
+	if err != nil || !isCustomerOfMerchant(customer, req.MerchantId) {
+		rsp.Status = pkg.ResponseStatusNotFound
+		rsp.Message = errorStoreCreditCustomerNotFound
+		return nil
+	}
+
+	if !contains(s.supportedCurrencies, req.Currency) {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = errorStoreCreditCurrencyInvalid
+		return nil
+	}
+
+	merchant, err := s.merchant.GetById(ctx, req.MerchantId)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusNotFound
+		rsp.Message = merchantErrorNotFound
+		return nil
+	}
+
+	credit, err := s.storeCredit.Credit(ctx, req.MerchantId, req.CustomerId, req.Currency, req.Amount)
+
+	if err != nil {
+		return err
+	}
+
+	transaction := &billing.StoreCreditTransaction{
+		Id:         primitive.NewObjectID().Hex(),
+		MerchantId: req.MerchantId,
+		CustomerId: req.CustomerId,
+		Type:       pkg.StoreCreditTransactionTypeIssue,
+		Amount:     req.Amount,
+		Currency:   req.Currency,
+		Reason:     req.Reason,
+		CreatedAt:  ptypes.TimestampNow(),
+	}
+
+	if err = s.storeCredit.InsertTransaction(ctx, transaction); err != nil {
+		return err
+	}
+
+	if err = s.onStoreCreditIssue(ctx, merchant, transaction); err != nil {
+		zap.L().Error(
+			pkg.MethodFinishedWithError,
+			zap.String("method", "onStoreCreditIssue"),
+			zap.Error(err),
+			zap.String("transaction_id", transaction.Id),
+		)
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = credit
+
+	return nil
+}
+
+func (s *Service) GetStoreCredits(
+	ctx context.Context,
+	req *grpc.GetStoreCreditsRequest,
+	rsp *grpc.GetStoreCreditsResponse,
+) (err error) {
+	rsp.Items, err = s.storeCredit.Find(ctx, req.MerchantId, req.CustomerId)
+
+	if err != nil {
+		return err
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	return nil
+}
+
+// completeStoreCreditPayment processes payment of order completed by store credit payment system the same way
+// as notification about payment from external payment system. Balance of store credit debited on creation
+// of payment is returned to customer if processing of payment failed.
+func (s *Service) completeStoreCreditPayment(ctx context.Context, order *billing.Order) error {
+	h, err := s.NewPaymentSystem(ctx, order)
+
+	if err == nil {
+		rsp := &grpc.PaymentNotifyResponse{}
+		err = s.processPaymentNotification(ctx, order, h, nil, nil, rsp)
+
+		if err == nil && rsp.Status != pkg.StatusOK {
+			zap.L().Error(
+				"Processing of payment with store credit failed",
+				zap.String("order_id", order.Id),
+				zap.String("error", rsp.Error),
+			)
+			err = orderErrorUnknown
+		}
+	}
+
+	if err != nil {
+		s.cancelStoreCreditPayment(ctx, order)
+	}
+
+	return err
+}
+
+// cancelStoreCreditPayment credits amount of payment back to store credit of customer and declines payment of order
+func (s *Service) cancelStoreCreditPayment(ctx context.Context, order *billing.Order) {
+	merchantId := order.GetMerchantId()
+	transaction := &billing.StoreCreditTransaction{
+		Id:         primitive.NewObjectID().Hex(),
+		MerchantId: merchantId,
+		CustomerId: order.User.Id,
+		Type:       pkg.StoreCreditTransactionTypePaymentCancel,
+		Amount:     order.ChargeAmount,
+		Currency:   order.ChargeCurrency,
+		OrderId:    order.Id,
+		CreatedAt:  ptypes.TimestampNow(),
+	}
+
+	err := s.storeCredit.WithTransaction(ctx, func(ctx context.Context) error {
+		_, err := s.storeCredit.Credit(ctx, merchantId, order.User.Id, order.ChargeCurrency, order.ChargeAmount)
+
+		if err != nil {
+			return err
+		}
+
+		return s.storeCredit.InsertTransaction(ctx, transaction)
+	})
+
+	if err != nil {
+		zap.L().Error(
+			"Return of store credit for not processed payment failed",
+			zap.Error(err),
+			zap.String("order_id", order.Id),
+			zap.String("transaction_id", order.Transaction),
+		)
+		return
+	}
+
+	order.PrivateStatus = constant.OrderStatusPaymentSystemDeclined
+
+	if err = s.updateOrder(ctx, order); err != nil {
+		zap.L().Error("s.updateOrder Method failed", zap.Error(err), zap.String("order_uuid", order.Uuid))
+	}
+}
+
+func isCustomerOfMerchant(customer *billing.Customer, merchantId string) bool {
+	for _, v := range customer.Identity {
+		if v.MerchantId == merchantId {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h *storeCreditPaymentSystem) CreatePayment(
+	order *billing.Order,
+	successUrl, failUrl string,
+	requisites map[string]string,
+) (string, error) {
+	if order.User == nil || order.User.Id == "" {
+		return "", errorStoreCreditCustomerRequired
+	}
+
+	ctx := context.TODO()
+	merchantId := order.GetMerchantId()
+	transaction := &billing.StoreCreditTransaction{
+		Id:         primitive.NewObjectID().Hex(),
+		MerchantId: merchantId,
+		CustomerId: order.User.Id,
+		Type:       pkg.StoreCreditTransactionTypePayment,
+		Amount:     -order.ChargeAmount,
+		Currency:   order.ChargeCurrency,
+		OrderId:    order.Id,
+		CreatedAt:  ptypes.TimestampNow(),
+	}
+
+	// balance is debited only together with saving of transaction of the payment
+	err := h.svc.storeCredit.WithTransaction(ctx, func(ctx context.Context) error {
+		_, err := h.svc.storeCredit.Debit(ctx, merchantId, order.User.Id, order.ChargeCurrency, order.ChargeAmount)
+
+		if err != nil {
+			return err
+		}
+
+		return h.svc.storeCredit.InsertTransaction(ctx, transaction)
+	})
+
+	if err != nil {
+		if err == errorStoreCreditInsufficientBalance {
+			return "", err
+		}
+
+		zap.L().Error(
+			"Store credit payment failed",
+			zap.Error(err),
+			zap.String("order_id", order.Id),
+		)
+		return "", paymentSystemErrorCreateRequestFailed
+	}
+
+	order.PrivateStatus = constant.OrderStatusPaymentSystemComplete
+	order.IsRefundAllowed = order.PaymentMethod.RefundAllowed
+	order.Transaction = transaction.Id
+	order.PaymentMethodOrderClosedAt = transaction.CreatedAt
+
+	return "", nil
+}
+
+func (h *storeCreditPaymentSystem) ProcessPayment(
+	order *billing.Order,
+	message proto.Message,
+	raw, signature string,
+) error {
+	return newBillingServerResponseError(pkg.StatusErrorValidation, errorStoreCreditOperationUnsupported)
+}
+
+func (h *storeCreditPaymentSystem) IsRecurringCallback(request proto.Message) bool {
+	return false
+}
+
+func (h *storeCreditPaymentSystem) GetRecurringId(request proto.Message) string {
+	return ""
+}
+
+func (h *storeCreditPaymentSystem) CreateRefund(order *billing.Order, refund *billing.Refund) error {
+	if order.User == nil || order.User.Id == "" {
+		refund.Status = pkg.RefundStatusRejected
+		return errorStoreCreditCustomerRequired
+	}
+
+	ctx := context.TODO()
+	merchantId := order.GetMerchantId()
+	_, err := h.svc.storeCredit.Credit(ctx, merchantId, order.User.Id, refund.Currency, refund.Amount)
+
+	if err != nil {
+		refund.Status = pkg.RefundStatusRejected
+		return err
+	}
+
+	transaction := &billing.StoreCreditTransaction{
+		Id:         primitive.NewObjectID().Hex(),
+		MerchantId: merchantId,
+		CustomerId: order.User.Id,
+		Type:       pkg.StoreCreditTransactionTypeRefund,
+		Amount:     refund.Amount,
+		Currency:   refund.Currency,
+		OrderId:    order.Id,
+		RefundId:   refund.Id,
+		Reason:     refund.Reason,
+		CreatedAt:  ptypes.TimestampNow(),
+	}
+
+	if err = h.svc.storeCredit.InsertTransaction(ctx, transaction); err != nil {
+		zap.L().Error(
+			"Store credit transaction of refund not saved",
+			zap.Error(err),
+			zap.String("refund_id", refund.Id),
+		)
+	}
+
+	refund.Status = pkg.RefundStatusCompleted
+	refund.ExternalId = transaction.Id
+
+	return nil
+}
+
+func (h *storeCreditPaymentSystem) ProcessRefund(
+	order *billing.Order,
+	refund *billing.Refund,
+	message proto.Message,
+	raw, signature string,
+) error {
+	return newBillingServerResponseError(pkg.StatusErrorValidation, errorStoreCreditOperationUnsupported)
+}
+
+func (h *storeCreditPaymentSystem) CapturePayment(order *billing.Order, amount float64) error {
+	return errorStoreCreditOperationUnsupported
+}
+
+func (h *storeCreditPaymentSystem) VoidPayment(order *billing.Order) error {
+	return errorStoreCreditOperationUnsupported
+}
+
+func (h *storeCreditPaymentSystem) GetPaymentStatus(order *billing.Order) (proto.Message, error) {
+	return nil, errorStoreCreditOperationUnsupported
+}
+
+func (h *StoreCredit) Credit(
+	ctx context.Context,
+	merchantId, customerId, currency string,
+	amount float64,
+) (*billing.StoreCredit, error) {
+	update := bson.M{
+		"$inc":         bson.M{"balance": amount},
+		"$set":         bson.M{"updated_at": time.Now()},
+		"$setOnInsert": bson.M{"created_at": time.Now()},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	return h.updateBalance(ctx, h.getFilter(merchantId, customerId, currency), update, opts)
+}
+
+func (h *StoreCredit) Debit(
+	ctx context.Context,
+	merchantId, customerId, currency string,
+	amount float64,
+) (*billing.StoreCredit, error) {
+	filter := h.getFilter(merchantId, customerId, currency)
+	filter["balance"] = bson.M{"$gte": tools.FormatAmount(amount)}
+	update := bson.M{
+		"$inc": bson.M{"balance": -amount},
+		"$set": bson.M{"updated_at": time.Now()},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	credit, err := h.updateBalance(ctx, filter, update, opts)
+
+	if err == mongo.ErrNoDocuments {
+		return nil, errorStoreCreditInsufficientBalance
+	}
+
+	return credit, err
+}
+
+func (h *StoreCredit) getFilter(merchantId, customerId, currency string) bson.M {
+	merchantOid, _ := primitive.ObjectIDFromHex(merchantId)
+	customerOid, _ := primitive.ObjectIDFromHex(customerId)
+
+	return bson.M{"merchant_id": merchantOid, "customer_id": customerOid, "currency": currency}
+}
+
+func (h *StoreCredit) updateBalance(
+	ctx context.Context,
+	filter, update bson.M,
+	opts *options.FindOneAndUpdateOptions,
+) (*billing.StoreCredit, error) {
+	credit := &billing.StoreCredit{}
+	err := h.svc.db.Collection(collectionStoreCredit).FindOneAndUpdate(ctx, filter, update, opts).Decode(credit)
+
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			zap.L().Error(
+				pkg.ErrorDatabaseQueryFailed,
+				zap.Error(err),
+				zap.String(pkg.ErrorDatabaseFieldCollection, collectionStoreCredit),
+				zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationUpdate),
+				zap.Any(pkg.ErrorDatabaseFieldQuery, filter),
+				zap.Any(pkg.ErrorDatabaseFieldDocument, update),
+			)
+		}
+		return nil, err
+	}
+
+	return credit, nil
+}
+
+func (h *StoreCredit) Find(ctx context.Context, merchantId, customerId string) ([]*billing.StoreCredit, error) {
+	oid, _ := primitive.ObjectIDFromHex(merchantId)
+	query := bson.M{"merchant_id": oid}
+
+	if customerId != "" {
+		query["customer_id"], _ = primitive.ObjectIDFromHex(customerId)
+	}
+
+	cursor, err := h.svc.db.Collection(collectionStoreCredit).Find(ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionStoreCredit),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	var credits []*billing.StoreCredit
+	err = cursor.All(ctx, &credits)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionStoreCredit),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	return credits, nil
+}
+
+func (h *StoreCredit) InsertTransaction(ctx context.Context, transaction *billing.StoreCreditTransaction) error {
+	_, err := h.svc.db.Collection(collectionStoreCreditTransaction).InsertOne(ctx, transaction)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionStoreCreditTransaction),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationInsert),
+			zap.Any(pkg.ErrorDatabaseFieldDocument, transaction),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func (h *StoreCredit) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	client := h.svc.db.Collection(collectionStoreCredit).Database().Client()
+
+	return client.UseSession(ctx, func(sctx mongo.SessionContext) error {
+		_, err := sctx.WithTransaction(sctx, func(sctx mongo.SessionContext) (interface{}, error) {
+			return nil, fn(sctx)
+		})
+
+		return err
+	})
+}
@@ -0,0 +1,388 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis"
+	"github.com/golang-migrate/migrate/v4"
+	casbinMocks "github.com/paysuper/casbin-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/internal/config"
+	"github.com/paysuper/paysuper-billing-server/internal/database"
+	"github.com/paysuper/paysuper-billing-server/internal/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/constant"
+	reportingMocks "github.com/paysuper/paysuper-reporter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	rabbitmq "gopkg.in/ProtocolONE/rabbitmq.v1/pkg"
+	mongodb "gopkg.in/paysuper/paysuper-database-mongo.v1"
+	"testing"
+	"time"
+)
+
+type StoreCreditTestSuite struct {
+	suite.Suite
+	service *Service
+	cache   CacheInterface
+
+	project       *billing.Project
+	paymentMethod *billing.PaymentMethod
+	customer      *billing.Customer
+}
+
+// storeCreditRepositoryInsertError fails saving of store credit transactions
+type storeCreditRepositoryInsertError struct {
+	StoreCreditRepositoryInterface
+}
+
+func (r *storeCreditRepositoryInsertError) InsertTransaction(
+	ctx context.Context,
+	transaction *billing.StoreCreditTransaction,
+) error {
+	return errors.New("store credit transaction insert failed")
+}
+
+func Test_StoreCredit(t *testing.T) {
+	suite.Run(t, new(StoreCreditTestSuite))
+}
+
+func (suite *StoreCreditTestSuite) SetupTest() {
+	cfg, err := config.NewConfig()
+	if err != nil {
+		suite.FailNow("Config load failed", "%v", err)
+	}
+
+	m, err := migrate.New(
+		"file://../../migrations/tests",
+		cfg.MongoDsn)
+	assert.NoError(suite.T(), err, "Migrate init failed")
+
+	err = m.Up()
+	if err != nil && err.Error() != "no change" {
+		suite.FailNow("Migrations failed", "%v", err)
+	}
+
+	db, err := mongodb.NewDatabase()
+	if err != nil {
+		suite.FailNow("Database connection failed", "%v", err)
+	}
+
+	broker, err := rabbitmq.NewBroker(cfg.BrokerAddress)
+
+	if err != nil {
+		suite.FailNow("Creating RabbitMQ publisher failed", "%v", err)
+	}
+
+	redisClient := database.NewRedis(
+		&redis.Options{
+			Addr:     cfg.RedisHost,
+			Password: cfg.RedisPassword,
+		},
+	)
+
+	redisdb := mocks.NewTestRedis()
+	suite.cache, err = NewCacheRedis(redisdb, "cache")
+	suite.service = NewBillingService(
+		db,
+		cfg,
+		mocks.NewGeoIpServiceTestOk(),
+		mocks.NewRepositoryServiceOk(),
+		mocks.NewTaxServiceOkMock(),
+		broker,
+		redisClient,
+		suite.cache,
+		mocks.NewCurrencyServiceMockOk(),
+		mocks.NewDocumentSignerMockOk(),
+		&reportingMocks.ReporterService{},
+		mocks.NewFormatterOK(),
+		broker,
+		&casbinMocks.CasbinService{},
+	)
+
+	if err := suite.service.Init(); err != nil {
+		suite.FailNow("Billing service initialization failed", "%v", err)
+	}
+
+	var paymentSystem *billing.PaymentSystem
+	_, suite.project, suite.paymentMethod, paymentSystem = helperCreateEntitiesForTests(suite.Suite, suite.service)
+
+	paymentSystem.Handler = pkg.PaymentSystemHandlerStoreCredit
+	err = suite.service.paymentSystem.Update(context.TODO(), paymentSystem)
+	assert.NoError(suite.T(), err)
+
+	req := &grpc.TokenRequest{
+		User: &billing.TokenUser{
+			Id:    primitive.NewObjectID().Hex(),
+			Email: &billing.TokenUserEmailValue{Value: "test@unit.test"},
+		},
+		Settings: &billing.TokenSettings{
+			ProjectId:   suite.project.Id,
+			Currency:    "RUB",
+			Amount:      100,
+			Description: "test payment",
+		},
+	}
+	suite.customer, err = suite.service.createCustomer(context.TODO(), req, suite.project)
+	assert.NoError(suite.T(), err)
+
+	centrifugoMock := &mocks.CentrifugoInterface{}
+	centrifugoMock.On("GetChannelToken", mock.Anything, mock.Anything).Return("token")
+	centrifugoMock.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.service.centrifugo = centrifugoMock
+}
+
+func (suite *StoreCreditTestSuite) TearDownTest() {
+	if err := suite.service.db.Drop(); err != nil {
+		suite.FailNow("Database deletion failed", "%v", err)
+	}
+
+	if err := suite.service.db.Close(); err != nil {
+		suite.FailNow("Database close failed", "%v", err)
+	}
+}
+
+func (suite *StoreCreditTestSuite) issueCredit(amount float64) *billing.StoreCredit {
+	req := &grpc.IssueStoreCreditRequest{
+		MerchantId: suite.project.MerchantId,
+		CustomerId: suite.customer.Id,
+		Currency:   "RUB",
+		Amount:     amount,
+		Reason:     "unit test",
+	}
+	rsp := &grpc.StoreCreditResponse{}
+	err := suite.service.IssueStoreCredit(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	return rsp.Item
+}
+
+func (suite *StoreCreditTestSuite) createOrder() *billing.Order {
+	req := &billing.OrderCreateRequest{
+		Type:        billing.OrderType_simple,
+		ProjectId:   suite.project.Id,
+		Currency:    "RUB",
+		Amount:      100,
+		Account:     "unit test",
+		Description: "unit test",
+		OrderId:     primitive.NewObjectID().Hex(),
+		User: &billing.OrderUser{
+			Email: "test@unit.unit",
+			Ip:    "127.0.0.1",
+		},
+	}
+	rsp := &grpc.OrderCreateProcessResponse{}
+	err := suite.service.OrderCreateProcess(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	order := rsp.Item
+	order.User.Id = suite.customer.Id
+	err = suite.service.updateOrder(context.TODO(), order)
+	assert.NoError(suite.T(), err)
+
+	return order
+}
+
+func (suite *StoreCreditTestSuite) payOrder(order *billing.Order) *grpc.PaymentCreateResponse {
+	req := &grpc.PaymentCreateRequest{
+		Data: map[string]string{
+			pkg.PaymentCreateFieldOrderId:         order.Uuid,
+			pkg.PaymentCreateFieldPaymentMethodId: suite.paymentMethod.Id,
+			pkg.PaymentCreateFieldEmail:           "test@unit.unit",
+			pkg.PaymentCreateFieldPan:             "4000000000000002",
+			pkg.PaymentCreateFieldCvv:             "123",
+			pkg.PaymentCreateFieldMonth:           "02",
+			pkg.PaymentCreateFieldYear:            time.Now().AddDate(1, 0, 0).Format("2006"),
+			pkg.PaymentCreateFieldHolder:          "MR. CARD HOLDER",
+		},
+		Ip: "127.0.0.1",
+	}
+	rsp := &grpc.PaymentCreateResponse{}
+	err := suite.service.PaymentCreateProcess(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+
+	return rsp
+}
+
+func (suite *StoreCreditTestSuite) TestStoreCredit_IssueStoreCredit_Ok() {
+	credit := suite.issueCredit(100)
+	assert.Equal(suite.T(), float64(100), credit.Balance)
+
+	credit = suite.issueCredit(50)
+	assert.Equal(suite.T(), float64(150), credit.Balance)
+
+	req := &grpc.GetStoreCreditsRequest{MerchantId: suite.project.MerchantId, CustomerId: suite.customer.Id}
+	rsp := &grpc.GetStoreCreditsResponse{}
+	err := suite.service.GetStoreCredits(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Len(suite.T(), rsp.Items, 1)
+	assert.Equal(suite.T(), float64(150), rsp.Items[0].Balance)
+
+	query := bson.M{"type": pkg.AccountingEntryTypeMerchantStoreCreditIssue}
+	count, err := suite.service.db.Collection(collectionAccountingEntry).CountDocuments(context.TODO(), query)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 2, count)
+
+	merchant, err := suite.service.merchant.GetById(context.TODO(), suite.project.MerchantId)
+	assert.NoError(suite.T(), err)
+
+	entry := &billing.AccountingEntry{}
+	err = suite.service.db.Collection(collectionAccountingEntry).FindOne(context.TODO(), query).Decode(entry)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), merchant.GetPayoutCurrency(), entry.Currency)
+	assert.Equal(suite.T(), "RUB", entry.OriginalCurrency)
+	assert.Equal(suite.T(), float64(100), entry.OriginalAmount)
+}
+
+func (suite *StoreCreditTestSuite) TestStoreCredit_IssueStoreCredit_Error_CustomerNotFound() {
+	req := &grpc.IssueStoreCreditRequest{
+		MerchantId: suite.project.MerchantId,
+		CustomerId: primitive.NewObjectID().Hex(),
+		Currency:   "RUB",
+		Amount:     100,
+		Reason:     "unit test",
+	}
+	rsp := &grpc.StoreCreditResponse{}
+	err := suite.service.IssueStoreCredit(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusNotFound, rsp.Status)
+	assert.Equal(suite.T(), errorStoreCreditCustomerNotFound, rsp.Message)
+}
+
+func (suite *StoreCreditTestSuite) TestStoreCredit_IssueStoreCredit_Error_CurrencyInvalid() {
+	req := &grpc.IssueStoreCreditRequest{
+		MerchantId: suite.project.MerchantId,
+		CustomerId: suite.customer.Id,
+		Currency:   "XXX",
+		Amount:     100,
+		Reason:     "unit test",
+	}
+	rsp := &grpc.StoreCreditResponse{}
+	err := suite.service.IssueStoreCredit(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), errorStoreCreditCurrencyInvalid, rsp.Message)
+}
+
+func (suite *StoreCreditTestSuite) TestStoreCredit_PaymentCreateProcess_Ok() {
+	suite.issueCredit(500)
+	order := suite.createOrder()
+
+	rsp := suite.payOrder(order)
+	assert.Equalf(suite.T(), pkg.ResponseStatusOk, rsp.Status, "%v", rsp.Message)
+	assert.False(suite.T(), rsp.NeedRedirect)
+
+	order, err := suite.service.getOrderById(context.TODO(), order.Id)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), constant.OrderPublicStatusProcessed, order.GetPublicStatus())
+	assert.True(suite.T(), order.IsPaidByStoreCredit())
+
+	credits, err := suite.service.storeCredit.Find(context.TODO(), suite.project.MerchantId, suite.customer.Id)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), credits, 1)
+	assert.Equal(suite.T(), 500-order.ChargeAmount, credits[0].Balance)
+
+	query := bson.M{"type": pkg.AccountingEntryTypeMerchantStoreCreditRedemption}
+	count, err := suite.service.db.Collection(collectionAccountingEntry).CountDocuments(context.TODO(), query)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, count)
+}
+
+func (suite *StoreCreditTestSuite) TestStoreCredit_PaymentCreateProcess_Error_InsufficientBalance() {
+	suite.issueCredit(10)
+	order := suite.createOrder()
+
+	rsp := suite.payOrder(order)
+	assert.NotEqual(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	credits, err := suite.service.storeCredit.Find(context.TODO(), suite.project.MerchantId, suite.customer.Id)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), credits, 1)
+	assert.Equal(suite.T(), float64(10), credits[0].Balance)
+}
+
+func (suite *StoreCreditTestSuite) TestStoreCredit_PaymentCreateProcess_Error_TransactionNotSaved() {
+	suite.issueCredit(500)
+	order := suite.createOrder()
+
+	repository := suite.service.storeCredit
+	suite.service.storeCredit = &storeCreditRepositoryInsertError{StoreCreditRepositoryInterface: repository}
+	rsp := suite.payOrder(order)
+	suite.service.storeCredit = repository
+	assert.NotEqual(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	// balance isn't debited without transaction of payment
+	credits, err := suite.service.storeCredit.Find(context.TODO(), suite.project.MerchantId, suite.customer.Id)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), credits, 1)
+	assert.Equal(suite.T(), float64(500), credits[0].Balance)
+}
+
+func (suite *StoreCreditTestSuite) TestStoreCredit_cancelStoreCreditPayment_BalanceReturned() {
+	suite.issueCredit(500)
+	order := suite.createOrder()
+
+	rsp := suite.payOrder(order)
+	assert.Equalf(suite.T(), pkg.ResponseStatusOk, rsp.Status, "%v", rsp.Message)
+
+	order, err := suite.service.getOrderById(context.TODO(), order.Id)
+	assert.NoError(suite.T(), err)
+
+	suite.service.cancelStoreCreditPayment(context.TODO(), order)
+
+	credits, err := suite.service.storeCredit.Find(context.TODO(), suite.project.MerchantId, suite.customer.Id)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), credits, 1)
+	assert.Equal(suite.T(), float64(500), credits[0].Balance)
+
+	order, err = suite.service.getOrderById(context.TODO(), order.Id)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), constant.OrderStatusPaymentSystemDeclined, order.PrivateStatus)
+
+	oid, _ := primitive.ObjectIDFromHex(order.Id)
+	query := bson.M{"order_id": oid, "type": pkg.StoreCreditTransactionTypePaymentCancel}
+	count, err := suite.service.db.Collection(collectionStoreCreditTransaction).CountDocuments(context.TODO(), query)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, count)
+}
+
+func (suite *StoreCreditTestSuite) TestStoreCredit_CreateRefund_Ok() {
+	suite.issueCredit(500)
+	order := suite.createOrder()
+
+	rsp := suite.payOrder(order)
+	assert.Equalf(suite.T(), pkg.ResponseStatusOk, rsp.Status, "%v", rsp.Message)
+
+	order, err := suite.service.getOrderById(context.TODO(), order.Id)
+	assert.NoError(suite.T(), err)
+
+	req1 := &grpc.CreateRefundRequest{
+		OrderId:    order.Uuid,
+		Amount:     order.ChargeAmount,
+		CreatorId:  primitive.NewObjectID().Hex(),
+		Reason:     "unit test",
+		MerchantId: order.GetMerchantId(),
+	}
+	rsp1 := &grpc.CreateRefundResponse{}
+	err = suite.service.CreateRefund(context.TODO(), req1, rsp1)
+	assert.NoError(suite.T(), err)
+	assert.Equalf(suite.T(), pkg.ResponseStatusOk, rsp1.Status, "%v", rsp1.Message)
+	assert.Equal(suite.T(), pkg.RefundStatusCompleted, rsp1.Item.Status)
+
+	credits, err := suite.service.storeCredit.Find(context.TODO(), suite.project.MerchantId, suite.customer.Id)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), credits, 1)
+	assert.Equal(suite.T(), float64(500), credits[0].Balance)
+
+	query := bson.M{"type": pkg.AccountingEntryTypeMerchantStoreCreditRefund}
+	count, err := suite.service.db.Collection(collectionAccountingEntry).CountDocuments(context.TODO(), query)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, count)
+}
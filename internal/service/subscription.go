@@ -0,0 +1,890 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/constant"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/proto/repository"
+	"github.com/paysuper/paysuper-recurring-repository/tools"
+	postmarkSdrPkg "github.com/paysuper/postmark-sender/pkg"
+	"github.com/streadway/amqp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	mongodb "gopkg.in/paysuper/paysuper-database-mongo.v1"
+	"time"
+)
+
+const (
+	collectionSubscriptionPlan = "subscription_plan"
+	collectionSubscription     = "subscription"
+)
+
+var (
+	errorSubscriptionPlanNotFound        = newBillingServerErrorMsg("sbs00001", "subscription plan not found")
+	errorSubscriptionPlanProjectInvalid  = newBillingServerErrorMsg("sbs00002", "project of subscription plan not found or belongs to another merchant")
+	errorSubscriptionPlanProductInvalid  = newBillingServerErrorMsg("sbs00003", "product of subscription plan not found or belongs to another project")
+	errorSubscriptionPlanCurrencyInvalid = newBillingServerErrorMsg("sbs00004", "currency of subscription plan is not supported")
+	errorSubscriptionPlanInactive        = newBillingServerErrorMsg("sbs00005", "subscription plan is inactive")
+	errorSubscriptionNotFound            = newBillingServerErrorMsg("sbs00006", "subscription not found")
+	errorSubscriptionOrderInvalid        = newBillingServerErrorMsg("sbs00007", "order of subscription not found or belongs to another project")
+	errorSubscriptionOrderNotPaid        = newBillingServerErrorMsg("sbs00008", "order of subscription without trial period must be paid in amount and currency of subscription plan")
+	errorSubscriptionStoredCardInvalid   = newBillingServerErrorMsg("sbs00009", "saved card not found or belongs to another customer")
+	errorSubscriptionCanceled            = newBillingServerErrorMsg("sbs00010", "subscription is canceled")
+	errorSubscriptionPlanChangeInvalid   = newBillingServerErrorMsg("sbs00011", "subscription can be moved only to plan of the same project and currency")
+	errorSubscriptionOrderAlreadyUsed    = newBillingServerErrorMsg("sbs00012", "order already used by another subscription")
+
+	// statuses of subscriptions for which scheduler charges renewals
+	subscriptionChargeableStatuses = []string{
+		pkg.SubscriptionStatusTrialing,
+		pkg.SubscriptionStatusActive,
+		pkg.SubscriptionStatusPastDue,
+	}
+
+	// statuses of renewal order which mean successful charge
+	subscriptionRenewalPaidStatuses = []int32{
+		constant.OrderStatusPaymentSystemComplete,
+		constant.OrderStatusProjectComplete,
+	}
+
+	// statuses of renewal order which mean failed charge
+	subscriptionRenewalFailedStatuses = []int32{
+		constant.OrderStatusPaymentSystemRejectOnCreate,
+		constant.OrderStatusPaymentSystemReject,
+		constant.OrderStatusPaymentSystemDeclined,
+		constant.OrderStatusPaymentSystemCanceled,
+		constant.OrderStatusProjectReject,
+	}
+)
+
+type SubscriptionPlanRepositoryInterface interface {
+	Upsert(ctx context.Context, plan *billing.SubscriptionPlan) error
+	GetById(ctx context.Context, id string) (*billing.SubscriptionPlan, error)
+	Find(ctx context.Context, merchantId, projectId string) ([]*billing.SubscriptionPlan, error)
+}
+
+type SubscriptionRepositoryInterface interface {
+	Insert(ctx context.Context, subscription *billing.Subscription) error
+	Update(ctx context.Context, subscription *billing.Subscription) error
+	GetById(ctx context.Context, id string) (*billing.Subscription, error)
+	GetBySourceOrderId(ctx context.Context, orderId string) (*billing.Subscription, error)
+	FindDue(ctx context.Context, date time.Time) ([]*billing.Subscription, error)
+}
+
+func newSubscriptionPlanRepository(svc *Service) SubscriptionPlanRepositoryInterface {
+	s := &SubscriptionPlan{svc: svc}
+	return s
+}
+
+func newSubscriptionRepository(svc *Service) SubscriptionRepositoryInterface {
+	s := &Subscription{svc: svc}
+	return s
+}
+
+func (s *Service) SetSubscriptionPlan(
+	ctx context.Context,
+	req *billing.SubscriptionPlan,
+	rsp *grpc.SubscriptionPlanResponse,
+) error {
+	plan := &billing.SubscriptionPlan{
+		Id:         primitive.NewObjectID().Hex(),
+		MerchantId: req.MerchantId,
+		ProjectId:  req.ProjectId,
+		ProductId:  req.ProductId,
+		Currency:   req.Currency,
+		CreatedAt:  ptypes.TimestampNow(),
+	}
+
+	if req.Id != "" {
+		var err error
+		plan, err = s.subscriptionPlan.GetById(ctx, req.Id)
+
+		if err != nil || plan.MerchantId != req.MerchantId {
+			rsp.Status = pkg.ResponseStatusNotFound
+			rsp.Message = errorSubscriptionPlanNotFound
+			return nil
+		}
+	} else {
+		if msg := s.checkSubscriptionPlanParams(ctx, plan); msg != nil {
+			rsp.Status = pkg.ResponseStatusBadData
+			rsp.Message = msg
+			return nil
+		}
+	}
+
+	// project, product and currency of plan can't be changed because subscriptions of plan
+	// are charged in currency of plan and their orders belong to project of plan
+	plan.Name = req.Name
+	plan.Amount = req.Amount
+	plan.Interval = req.Interval
+	plan.IntervalCount = req.IntervalCount
+	plan.TrialDays = req.TrialDays
+	plan.IsActive = req.IsActive
+	plan.UpdatedAt = ptypes.TimestampNow()
+
+	if err := s.subscriptionPlan.Upsert(ctx, plan); err != nil {
+		return err
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = plan
+
+	return nil
+}
+
+func (s *Service) GetSubscriptionPlans(
+	ctx context.Context,
+	req *grpc.GetSubscriptionPlansRequest,
+	rsp *grpc.GetSubscriptionPlansResponse,
+) (err error) {
+	rsp.Items, err = s.subscriptionPlan.Find(ctx, req.MerchantId, req.ProjectId)
+
+	if err != nil {
+		return err
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	return nil
+}
+
+func (s *Service) CreateSubscription(
+	ctx context.Context,
+	req *grpc.CreateSubscriptionRequest,
+	rsp *grpc.SubscriptionResponse,
+) error {
+	plan, err := s.subscriptionPlan.GetById(ctx, req.PlanId)
+
+	if err != nil || plan.MerchantId != req.MerchantId {
+		rsp.Status = pkg.ResponseStatusNotFound
+		rsp.Message = errorSubscriptionPlanNotFound
+		return nil
+	}
+
+	if !plan.IsActive {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = errorSubscriptionPlanInactive
+		return nil
+	}
+
+	order, err := s.getOrderById(ctx, req.OrderId)
+
+	if err != nil || order.GetProjectId() != plan.ProjectId || order.User == nil || order.PaymentMethod == nil {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = errorSubscriptionOrderInvalid
+		return nil
+	}
+
+	if _, err = s.subscription.GetBySourceOrderId(ctx, order.Id); err != errorSubscriptionNotFound {
+		if err != nil {
+			return err
+		}
+
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = errorSubscriptionOrderAlreadyUsed
+		return nil
+	}
+
+	card, err := s.rep.FindSavedCardById(ctx, &repository.FindByStringValue{Value: req.StoredCardId})
+
+	if err != nil || card == nil || card.Token != order.User.Id {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = errorSubscriptionStoredCardInvalid
+		return nil
+	}
+
+	now := time.Now()
+	subscription := &billing.Subscription{
+		Id:              primitive.NewObjectID().Hex(),
+		PlanId:          plan.Id,
+		MerchantId:      plan.MerchantId,
+		ProjectId:       plan.ProjectId,
+		User:            order.User,
+		PaymentMethodId: order.PaymentMethod.Id,
+		StoredCardId:    req.StoredCardId,
+		SourceOrderId:   order.Id,
+		Status:          pkg.SubscriptionStatusTrialing,
+		CreatedAt:       ptypes.TimestampNow(),
+		UpdatedAt:       ptypes.TimestampNow(),
+	}
+	periodEnd := now.AddDate(0, 0, int(plan.TrialDays))
+
+	if plan.TrialDays <= 0 {
+		// without trial period the order is the payment for the first billing period
+		if !containsInt32(subscriptionRenewalPaidStatuses, order.PrivateStatus) ||
+			order.Currency != plan.Currency || order.OrderAmount != plan.Amount {
+			rsp.Status = pkg.ResponseStatusBadData
+			rsp.Message = errorSubscriptionOrderNotPaid
+			return nil
+		}
+
+		subscription.Status = pkg.SubscriptionStatusActive
+		periodEnd = getSubscriptionPeriodEnd(now, plan)
+	}
+
+	subscription.CurrentPeriodStart, _ = ptypes.TimestampProto(now)
+	subscription.CurrentPeriodEnd, _ = ptypes.TimestampProto(periodEnd)
+	subscription.NextChargeAt = subscription.CurrentPeriodEnd
+
+	if err = s.subscription.Insert(ctx, subscription); err != nil {
+		// the same order is used by concurrent request
+		if mongodb.IsDuplicate(err) {
+			rsp.Status = pkg.ResponseStatusBadData
+			rsp.Message = errorSubscriptionOrderAlreadyUsed
+			return nil
+		}
+
+		return err
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = subscription
+
+	return nil
+}
+
+func (s *Service) GetSubscription(
+	ctx context.Context,
+	req *grpc.GetSubscriptionRequest,
+	rsp *grpc.SubscriptionResponse,
+) error {
+	subscription, err := s.subscription.GetById(ctx, req.SubscriptionId)
+
+	if err != nil || subscription.MerchantId != req.MerchantId {
+		rsp.Status = pkg.ResponseStatusNotFound
+		rsp.Message = errorSubscriptionNotFound
+		return nil
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = subscription
+
+	return nil
+}
+
+// ChangeSubscriptionPlan moves subscription to another plan. For active subscription the difference between
+// prices of new and current plans for unused part of current billing period is added to the next renewal.
+func (s *Service) ChangeSubscriptionPlan(
+	ctx context.Context,
+	req *grpc.ChangeSubscriptionPlanRequest,
+	rsp *grpc.SubscriptionResponse,
+) error {
+	subscription, err := s.subscription.GetById(ctx, req.SubscriptionId)
+
+	if err != nil || subscription.MerchantId != req.MerchantId {
+		rsp.Status = pkg.ResponseStatusNotFound
+		rsp.Message = errorSubscriptionNotFound
+		return nil
+	}
+
+	if subscription.Status == pkg.SubscriptionStatusCanceled {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = errorSubscriptionCanceled
+		return nil
+	}
+
+	plan, err := s.subscriptionPlan.GetById(ctx, subscription.PlanId)
+
+	if err != nil {
+		return err
+	}
+
+	newPlan, err := s.subscriptionPlan.GetById(ctx, req.PlanId)
+
+	if err != nil || newPlan.MerchantId != req.MerchantId {
+		rsp.Status = pkg.ResponseStatusNotFound
+		rsp.Message = errorSubscriptionPlanNotFound
+		return nil
+	}
+
+	if !newPlan.IsActive {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = errorSubscriptionPlanInactive
+		return nil
+	}
+
+	if newPlan.ProjectId != plan.ProjectId || newPlan.Currency != plan.Currency {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = errorSubscriptionPlanChangeInvalid
+		return nil
+	}
+
+	if subscription.Status != pkg.SubscriptionStatusTrialing {
+		subscription.ProrationAmount += getSubscriptionProration(subscription, plan, newPlan, time.Now())
+	}
+
+	subscription.PlanId = newPlan.Id
+	subscription.UpdatedAt = ptypes.TimestampNow()
+
+	if err = s.subscription.Update(ctx, subscription); err != nil {
+		return err
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = subscription
+
+	return nil
+}
+
+func (s *Service) CancelSubscription(
+	ctx context.Context,
+	req *grpc.CancelSubscriptionRequest,
+	rsp *grpc.SubscriptionResponse,
+) error {
+	subscription, err := s.subscription.GetById(ctx, req.SubscriptionId)
+
+	if err != nil || subscription.MerchantId != req.MerchantId {
+		rsp.Status = pkg.ResponseStatusNotFound
+		rsp.Message = errorSubscriptionNotFound
+		return nil
+	}
+
+	if subscription.Status == pkg.SubscriptionStatusCanceled {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = errorSubscriptionCanceled
+		return nil
+	}
+
+	if req.AtPeriodEnd {
+		subscription.CancelAtPeriodEnd = true
+	} else {
+		subscription.Status = pkg.SubscriptionStatusCanceled
+		subscription.CanceledAt = ptypes.TimestampNow()
+		subscription.NextChargeAt = nil
+	}
+
+	subscription.UpdatedAt = ptypes.TimestampNow()
+
+	if err = s.subscription.Update(ctx, subscription); err != nil {
+		return err
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = subscription
+
+	return nil
+}
+
+// ProcessSubscriptions charges renewals of subscriptions which billing period is ended by saved cards of customers.
+// Each renewal is a regular order of project, the result of renewal payment is checked on the next run and failed
+// charges are retried through retry interval from configuration until maximal count of attempts is reached.
+func (s *Service) ProcessSubscriptions(ctx context.Context) error {
+	subscriptions, err := s.subscription.FindDue(ctx, time.Now())
+
+	if err != nil {
+		return err
+	}
+
+	for _, subscription := range subscriptions {
+		if err = s.processSubscription(ctx, subscription); err != nil {
+			zap.L().Error(
+				"subscription processing failed",
+				zap.Error(err),
+				zap.String("subscription_id", subscription.Id),
+			)
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) processSubscription(ctx context.Context, subscription *billing.Subscription) error {
+	plan, err := s.subscriptionPlan.GetById(ctx, subscription.PlanId)
+
+	if err != nil {
+		return err
+	}
+
+	if subscription.RenewalOrderId != "" {
+		order, err := s.getOrderById(ctx, subscription.RenewalOrderId)
+
+		if err != nil {
+			return err
+		}
+
+		if containsInt32(subscriptionRenewalPaidStatuses, order.PrivateStatus) {
+			subscription.ProrationAmount = 0
+			return s.renewSubscription(ctx, subscription, plan)
+		}
+
+		if containsInt32(subscriptionRenewalFailedStatuses, order.PrivateStatus) {
+			return s.failSubscriptionCharge(ctx, subscription, plan)
+		}
+
+		createdAt, err := ptypes.Timestamp(order.CreatedAt)
+
+		if err != nil {
+			return err
+		}
+
+		// payment of renewal order is still in progress
+		if time.Since(createdAt) < s.cfg.GetSubscriptionRenewalTimeout() {
+			return nil
+		}
+
+		zap.L().Warn(
+			"subscription renewal payment timed out",
+			zap.String("subscription_id", subscription.Id),
+			zap.String("order_id", order.Id),
+			zap.Int32("order_status", order.PrivateStatus),
+		)
+		return s.failSubscriptionCharge(ctx, subscription, plan)
+	}
+
+	if subscription.CancelAtPeriodEnd {
+		subscription.Status = pkg.SubscriptionStatusCanceled
+		subscription.CanceledAt = ptypes.TimestampNow()
+		subscription.NextChargeAt = nil
+		subscription.UpdatedAt = ptypes.TimestampNow()
+
+		return s.subscription.Update(ctx, subscription)
+	}
+
+	amount := tools.FormatAmount(plan.Amount + subscription.ProrationAmount)
+
+	// credit of customer covers the whole renewal, rest of credit is left for next renewals
+	if amount <= 0 {
+		subscription.ProrationAmount = amount
+		return s.renewSubscription(ctx, subscription, plan)
+	}
+
+	order, err := s.createSubscriptionRenewalOrder(ctx, subscription, plan, amount)
+
+	if err != nil {
+		zap.L().Error(
+			"subscription renewal charge failed",
+			zap.Error(err),
+			zap.String("subscription_id", subscription.Id),
+		)
+		return s.failSubscriptionCharge(ctx, subscription, plan)
+	}
+
+	subscription.RenewalOrderId = order.Id
+	subscription.UpdatedAt = ptypes.TimestampNow()
+
+	return s.subscription.Update(ctx, subscription)
+}
+
+func (s *Service) createSubscriptionRenewalOrder(
+	ctx context.Context,
+	subscription *billing.Subscription,
+	plan *billing.SubscriptionPlan,
+	amount float64,
+) (*billing.Order, error) {
+	user := *subscription.User
+	oReq := &billing.OrderCreateRequest{
+		ProjectId:   subscription.ProjectId,
+		Amount:      amount,
+		Currency:    plan.Currency,
+		Description: plan.Name,
+		Type:        billing.OrderType_simple,
+		User:        &user,
+		PrivateMetadata: map[string]string{
+			"SubscriptionId":     subscription.Id,
+			"SubscriptionPlanId": plan.Id,
+		},
+		IssuerReferenceType: pkg.OrderIssuerReferenceTypeSubscription,
+		IssuerReference:     subscription.Id,
+	}
+	oRsp := &grpc.OrderCreateProcessResponse{}
+	err := s.OrderCreateProcess(ctx, oReq, oRsp)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if oRsp.Status != pkg.ResponseStatusOk {
+		return nil, oRsp.Message
+	}
+
+	// renewal is charged by saved card without customer, payment system must know it's merchant initiated payment
+	oRsp.Item.IsMerchantInitiated = true
+
+	if err = s.updateOrder(ctx, oRsp.Item); err != nil {
+		return nil, err
+	}
+
+	pReq := &grpc.PaymentCreateRequest{
+		Data: map[string]string{
+			pkg.PaymentCreateFieldOrderId:         oRsp.Item.Uuid,
+			pkg.PaymentCreateFieldPaymentMethodId: subscription.PaymentMethodId,
+			pkg.PaymentCreateFieldEmail:           subscription.User.Email,
+			pkg.PaymentCreateFieldStoredCardId:    subscription.StoredCardId,
+		},
+		Ip: subscription.User.Ip,
+	}
+	pRsp := &grpc.PaymentCreateResponse{}
+	err = s.PaymentCreateProcess(ctx, pReq, pRsp)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if pRsp.Status != pkg.ResponseStatusOk {
+		return nil, pRsp.Message
+	}
+
+	return oRsp.Item, nil
+}
+
+func (s *Service) renewSubscription(
+	ctx context.Context,
+	subscription *billing.Subscription,
+	plan *billing.SubscriptionPlan,
+) error {
+	start, err := ptypes.Timestamp(subscription.CurrentPeriodEnd)
+
+	if err != nil {
+		return err
+	}
+
+	subscription.CurrentPeriodStart = subscription.CurrentPeriodEnd
+	subscription.CurrentPeriodEnd, _ = ptypes.TimestampProto(getSubscriptionPeriodEnd(start, plan))
+	subscription.NextChargeAt = subscription.CurrentPeriodEnd
+	subscription.Status = pkg.SubscriptionStatusActive
+	subscription.FailedAttempts = 0
+	subscription.RenewalOrderId = ""
+	subscription.UpdatedAt = ptypes.TimestampNow()
+
+	return s.subscription.Update(ctx, subscription)
+}
+
+func (s *Service) failSubscriptionCharge(
+	ctx context.Context,
+	subscription *billing.Subscription,
+	plan *billing.SubscriptionPlan,
+) error {
+	subscription.FailedAttempts++
+	subscription.RenewalOrderId = ""
+	subscription.UpdatedAt = ptypes.TimestampNow()
+	template := s.cfg.EmailTemplates.SubscriptionPaymentFailed
+
+	if subscription.FailedAttempts >= s.cfg.SubscriptionMaxChargeAttempts {
+		subscription.Status = pkg.SubscriptionStatusCanceled
+		subscription.CanceledAt = ptypes.TimestampNow()
+		subscription.NextChargeAt = nil
+		template = s.cfg.EmailTemplates.SubscriptionCanceled
+	} else {
+		subscription.Status = pkg.SubscriptionStatusPastDue
+		subscription.NextChargeAt, _ = ptypes.TimestampProto(time.Now().Add(s.cfg.GetSubscriptionRetryInterval()))
+	}
+
+	if err := s.subscription.Update(ctx, subscription); err != nil {
+		return err
+	}
+
+	s.sendSubscriptionLetter(subscription, plan, template)
+
+	return nil
+}
+
+func (s *Service) sendSubscriptionLetter(subscription *billing.Subscription, plan *billing.SubscriptionPlan, template string) {
+	if subscription.User == nil || subscription.User.Email == "" {
+		return
+	}
+
+	amount, err := s.formatter.FormatCurrency(DefaultLanguage, plan.Amount, plan.Currency)
+
+	if err != nil {
+		zap.S().Errorw("Error during formatting currency", "price", plan.Amount, "locale", DefaultLanguage, "currency", plan.Currency)
+	}
+
+	payload := &postmarkSdrPkg.Payload{
+		TemplateAlias: template,
+		TemplateModel: map[string]string{
+			"subscription_id": subscription.Id,
+			"plan_name":       plan.Name,
+			"amount":          amount,
+		},
+		To: subscription.User.Email,
+	}
+
+	if subscription.NextChargeAt != nil {
+		date, err := s.formatter.FormatDateTime(DefaultLanguage, time.Unix(subscription.NextChargeAt.Seconds, 0))
+
+		if err != nil {
+			zap.S().Errorw("Error during formatting date", "date", subscription.NextChargeAt, "locale", DefaultLanguage)
+		}
+
+		payload.TemplateModel["next_charge_date"] = date
+	}
+
+	err = s.postmarkBroker.Publish(postmarkSdrPkg.PostmarkSenderTopicName, payload, amqp.Table{})
+
+	if err != nil {
+		zap.L().Error(
+			"Publication subscription letter to user email queue is failed",
+			zap.Error(err),
+			zap.String("subscription_id", subscription.Id),
+			zap.String("template", template),
+		)
+	}
+}
+
+func (s *Service) checkSubscriptionPlanParams(
+	ctx context.Context,
+	plan *billing.SubscriptionPlan,
+) *grpc.ResponseErrorMessage {
+	project, err := s.project.GetById(ctx, plan.ProjectId)
+
+	if err != nil || project.MerchantId != plan.MerchantId {
+		return errorSubscriptionPlanProjectInvalid
+	}
+
+	if plan.ProductId != "" {
+		product, err := s.productService.GetById(ctx, plan.ProductId)
+
+		if err != nil || product.ProjectId != plan.ProjectId {
+			return errorSubscriptionPlanProductInvalid
+		}
+	}
+
+	if !contains(s.supportedCurrencies, plan.Currency) {
+		return errorSubscriptionPlanCurrencyInvalid
+	}
+
+	return nil
+}
+
+func getSubscriptionPeriodEnd(start time.Time, plan *billing.SubscriptionPlan) time.Time {
+	count := int(plan.IntervalCount)
+
+	switch plan.Interval {
+	case pkg.SubscriptionIntervalWeek:
+		return start.AddDate(0, 0, 7*count)
+	case pkg.SubscriptionIntervalMonth:
+		return start.AddDate(0, count, 0)
+	case pkg.SubscriptionIntervalYear:
+		return start.AddDate(count, 0, 0)
+	}
+
+	return start.AddDate(0, 0, count)
+}
+
+// getSubscriptionProration returns difference between prices of new and current plans
+// for the part of current billing period which is left after date.
+func getSubscriptionProration(
+	subscription *billing.Subscription,
+	plan, newPlan *billing.SubscriptionPlan,
+	date time.Time,
+) float64 {
+	start, err := ptypes.Timestamp(subscription.CurrentPeriodStart)
+
+	if err != nil {
+		return 0
+	}
+
+	end, err := ptypes.Timestamp(subscription.CurrentPeriodEnd)
+
+	if err != nil || !end.After(date) || !end.After(start) {
+		return 0
+	}
+
+	unused := end.Sub(date).Seconds() / end.Sub(start).Seconds()
+
+	if unused > 1 {
+		unused = 1
+	}
+
+	return tools.FormatAmount((newPlan.Amount - plan.Amount) * unused)
+}
+
+func (h *SubscriptionPlan) Upsert(ctx context.Context, plan *billing.SubscriptionPlan) error {
+	oid, _ := primitive.ObjectIDFromHex(plan.Id)
+	filter := bson.M{"_id": oid}
+	opts := options.Replace().SetUpsert(true)
+	_, err := h.svc.db.Collection(collectionSubscriptionPlan).ReplaceOne(ctx, filter, plan, opts)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionSubscriptionPlan),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationUpsert),
+			zap.Any(pkg.ErrorDatabaseFieldDocument, plan),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func (h *SubscriptionPlan) GetById(ctx context.Context, id string) (*billing.SubscriptionPlan, error) {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	filter := bson.M{"_id": oid}
+
+	plan := &billing.SubscriptionPlan{}
+	err := h.svc.db.Collection(collectionSubscriptionPlan).FindOne(ctx, filter).Decode(plan)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errorSubscriptionPlanNotFound
+		}
+
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionSubscriptionPlan),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, filter),
+		)
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+func (h *SubscriptionPlan) Find(ctx context.Context, merchantId, projectId string) ([]*billing.SubscriptionPlan, error) {
+	oid, _ := primitive.ObjectIDFromHex(merchantId)
+	query := bson.M{"merchant_id": oid}
+
+	if projectId != "" {
+		query["project_id"], _ = primitive.ObjectIDFromHex(projectId)
+	}
+
+	cursor, err := h.svc.db.Collection(collectionSubscriptionPlan).Find(ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionSubscriptionPlan),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	var plans []*billing.SubscriptionPlan
+	err = cursor.All(ctx, &plans)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionSubscriptionPlan),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	return plans, nil
+}
+
+func (h *Subscription) Insert(ctx context.Context, subscription *billing.Subscription) error {
+	_, err := h.svc.db.Collection(collectionSubscription).InsertOne(ctx, subscription)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionSubscription),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationInsert),
+			zap.Any(pkg.ErrorDatabaseFieldDocument, subscription),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func (h *Subscription) Update(ctx context.Context, subscription *billing.Subscription) error {
+	oid, _ := primitive.ObjectIDFromHex(subscription.Id)
+	filter := bson.M{"_id": oid}
+	_, err := h.svc.db.Collection(collectionSubscription).ReplaceOne(ctx, filter, subscription)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionSubscription),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationUpdate),
+			zap.Any(pkg.ErrorDatabaseFieldDocument, subscription),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func (h *Subscription) GetById(ctx context.Context, id string) (*billing.Subscription, error) {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	filter := bson.M{"_id": oid}
+
+	subscription := &billing.Subscription{}
+	err := h.svc.db.Collection(collectionSubscription).FindOne(ctx, filter).Decode(subscription)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errorSubscriptionNotFound
+		}
+
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionSubscription),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, filter),
+		)
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+func (h *Subscription) GetBySourceOrderId(ctx context.Context, orderId string) (*billing.Subscription, error) {
+	filter := bson.M{"source_order_id": orderId}
+
+	subscription := &billing.Subscription{}
+	err := h.svc.db.Collection(collectionSubscription).FindOne(ctx, filter).Decode(subscription)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errorSubscriptionNotFound
+		}
+
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionSubscription),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, filter),
+		)
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+func (h *Subscription) FindDue(ctx context.Context, date time.Time) ([]*billing.Subscription, error) {
+	query := bson.M{
+		"status":         bson.M{"$in": subscriptionChargeableStatuses},
+		"next_charge_at": bson.M{"$lte": date},
+	}
+	cursor, err := h.svc.db.Collection(collectionSubscription).Find(ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionSubscription),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	var subscriptions []*billing.Subscription
+	err = cursor.All(ctx, &subscriptions)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionSubscription),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
@@ -0,0 +1,440 @@
+package service
+
+import (
+	"context"
+	"github.com/go-redis/redis"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/micro/go-micro/client"
+	casbinMocks "github.com/paysuper/casbin-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/internal/config"
+	"github.com/paysuper/paysuper-billing-server/internal/database"
+	"github.com/paysuper/paysuper-billing-server/internal/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/constant"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/proto/entity"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/proto/repository"
+	reportingMocks "github.com/paysuper/paysuper-reporter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	rabbitmq "gopkg.in/ProtocolONE/rabbitmq.v1/pkg"
+	mongodb "gopkg.in/paysuper/paysuper-database-mongo.v1"
+	"testing"
+	"time"
+)
+
+type SubscriptionTestSuite struct {
+	suite.Suite
+	service *Service
+	cache   CacheInterface
+	rep     *subscriptionRepositoryMock
+
+	project       *billing.Project
+	paymentMethod *billing.PaymentMethod
+	order         *billing.Order
+}
+
+// subscriptionRepositoryMock returns saved card which belongs to customer of test order
+type subscriptionRepositoryMock struct {
+	mocks.RepositoryServiceOk
+	token string
+}
+
+func (r *subscriptionRepositoryMock) FindSavedCardById(
+	ctx context.Context,
+	in *repository.FindByStringValue,
+	opts ...client.CallOption,
+) (*entity.SavedCard, error) {
+	return &entity.SavedCard{
+		Id:          in.Value,
+		Token:       r.token,
+		MaskedPan:   "400000******0002",
+		CardHolder:  "MR. CARD HOLDER",
+		RecurringId: "0987654321",
+		Expire:      &entity.CardExpire{Month: "12", Year: time.Now().AddDate(1, 0, 0).Format("2006")},
+		IsActive:    true,
+	}, nil
+}
+
+func Test_Subscription(t *testing.T) {
+	suite.Run(t, new(SubscriptionTestSuite))
+}
+
+func (suite *SubscriptionTestSuite) SetupTest() {
+	cfg, err := config.NewConfig()
+	if err != nil {
+		suite.FailNow("Config load failed", "%v", err)
+	}
+
+	m, err := migrate.New(
+		"file://../../migrations/tests",
+		cfg.MongoDsn)
+	assert.NoError(suite.T(), err, "Migrate init failed")
+
+	err = m.Up()
+	if err != nil && err.Error() != "no change" {
+		suite.FailNow("Migrations failed", "%v", err)
+	}
+
+	db, err := mongodb.NewDatabase()
+	if err != nil {
+		suite.FailNow("Database connection failed", "%v", err)
+	}
+
+	broker, err := rabbitmq.NewBroker(cfg.BrokerAddress)
+
+	if err != nil {
+		suite.FailNow("Creating RabbitMQ publisher failed", "%v", err)
+	}
+
+	redisClient := database.NewRedis(
+		&redis.Options{
+			Addr:     cfg.RedisHost,
+			Password: cfg.RedisPassword,
+		},
+	)
+
+	suite.rep = &subscriptionRepositoryMock{}
+	redisdb := mocks.NewTestRedis()
+	suite.cache, err = NewCacheRedis(redisdb, "cache")
+	suite.service = NewBillingService(
+		db,
+		cfg,
+		mocks.NewGeoIpServiceTestOk(),
+		suite.rep,
+		mocks.NewTaxServiceOkMock(),
+		broker,
+		redisClient,
+		suite.cache,
+		mocks.NewCurrencyServiceMockOk(),
+		mocks.NewDocumentSignerMockOk(),
+		&reportingMocks.ReporterService{},
+		mocks.NewFormatterOK(),
+		broker,
+		&casbinMocks.CasbinService{},
+	)
+
+	if err := suite.service.Init(); err != nil {
+		suite.FailNow("Billing service initialization failed", "%v", err)
+	}
+
+	_, suite.project, suite.paymentMethod, _ = helperCreateEntitiesForTests(suite.Suite, suite.service)
+	suite.order = helperCreateAndPayOrder(suite.Suite, suite.service, 100, "RUB", "RU", suite.project, suite.paymentMethod)
+	suite.rep.token = suite.order.User.Id
+}
+
+func (suite *SubscriptionTestSuite) TearDownTest() {
+	if err := suite.service.db.Drop(); err != nil {
+		suite.FailNow("Database deletion failed", "%v", err)
+	}
+
+	if err := suite.service.db.Close(); err != nil {
+		suite.FailNow("Database close failed", "%v", err)
+	}
+}
+
+func (suite *SubscriptionTestSuite) TestSubscription_SetSubscriptionPlan_Ok() {
+	plan := suite.createPlan(100, 0)
+
+	plan.Name = "Premium"
+	plan.IsActive = false
+	rsp := &grpc.SubscriptionPlanResponse{}
+	err := suite.service.SetSubscriptionPlan(context.TODO(), plan, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	req := &grpc.GetSubscriptionPlansRequest{MerchantId: suite.project.MerchantId, ProjectId: suite.project.Id}
+	rsp1 := &grpc.GetSubscriptionPlansResponse{}
+	err = suite.service.GetSubscriptionPlans(context.TODO(), req, rsp1)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp1.Status)
+	assert.Len(suite.T(), rsp1.Items, 1)
+	assert.Equal(suite.T(), "Premium", rsp1.Items[0].Name)
+	assert.False(suite.T(), rsp1.Items[0].IsActive)
+}
+
+func (suite *SubscriptionTestSuite) TestSubscription_SetSubscriptionPlan_ProjectInvalid_Error() {
+	req := &billing.SubscriptionPlan{
+		MerchantId:    primitive.NewObjectID().Hex(),
+		ProjectId:     suite.project.Id,
+		Name:          "Basic",
+		Amount:        100,
+		Currency:      "RUB",
+		Interval:      pkg.SubscriptionIntervalMonth,
+		IntervalCount: 1,
+		IsActive:      true,
+	}
+	rsp := &grpc.SubscriptionPlanResponse{}
+	err := suite.service.SetSubscriptionPlan(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), errorSubscriptionPlanProjectInvalid, rsp.Message)
+}
+
+func (suite *SubscriptionTestSuite) TestSubscription_CreateSubscription_Trial_Ok() {
+	plan := suite.createPlan(100, 7)
+	subscription := suite.createSubscription(plan)
+
+	assert.Equal(suite.T(), pkg.SubscriptionStatusTrialing, subscription.Status)
+	assert.Equal(suite.T(), suite.paymentMethod.Id, subscription.PaymentMethodId)
+
+	end, _ := ptypes.Timestamp(subscription.CurrentPeriodEnd)
+	assert.WithinDuration(suite.T(), time.Now().AddDate(0, 0, 7), end, time.Minute)
+	assert.Equal(suite.T(), subscription.CurrentPeriodEnd.Seconds, subscription.NextChargeAt.Seconds)
+}
+
+func (suite *SubscriptionTestSuite) TestSubscription_CreateSubscription_StoredCardInvalid_Error() {
+	plan := suite.createPlan(100, 7)
+	suite.rep.token = primitive.NewObjectID().Hex()
+
+	req := &grpc.CreateSubscriptionRequest{
+		MerchantId:   suite.project.MerchantId,
+		PlanId:       plan.Id,
+		OrderId:      suite.order.Id,
+		StoredCardId: primitive.NewObjectID().Hex(),
+	}
+	rsp := &grpc.SubscriptionResponse{}
+	err := suite.service.CreateSubscription(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), errorSubscriptionStoredCardInvalid, rsp.Message)
+}
+
+func (suite *SubscriptionTestSuite) TestSubscription_CreateSubscription_OrderNotPaid_Error() {
+	plan := suite.createPlan(150, 0)
+
+	req := &grpc.CreateSubscriptionRequest{
+		MerchantId:   suite.project.MerchantId,
+		PlanId:       plan.Id,
+		OrderId:      suite.order.Id,
+		StoredCardId: primitive.NewObjectID().Hex(),
+	}
+	rsp := &grpc.SubscriptionResponse{}
+	err := suite.service.CreateSubscription(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), errorSubscriptionOrderNotPaid, rsp.Message)
+}
+
+func (suite *SubscriptionTestSuite) TestSubscription_CreateSubscription_OrderAlreadyUsed_Error() {
+	plan := suite.createPlan(100, 7)
+	subscription := suite.createSubscription(plan)
+	assert.Equal(suite.T(), suite.order.Id, subscription.SourceOrderId)
+
+	req := &grpc.CreateSubscriptionRequest{
+		MerchantId:   suite.project.MerchantId,
+		PlanId:       plan.Id,
+		OrderId:      suite.order.Id,
+		StoredCardId: primitive.NewObjectID().Hex(),
+	}
+	rsp := &grpc.SubscriptionResponse{}
+	err := suite.service.CreateSubscription(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), errorSubscriptionOrderAlreadyUsed, rsp.Message)
+}
+
+func (suite *SubscriptionTestSuite) TestSubscription_ChangeSubscriptionPlan_Proration() {
+	plan := suite.createPlan(100, 0)
+	subscription := suite.createSubscription(plan)
+	assert.Equal(suite.T(), pkg.SubscriptionStatusActive, subscription.Status)
+
+	newPlan := suite.createPlan(200, 0)
+	req := &grpc.ChangeSubscriptionPlanRequest{
+		MerchantId:     suite.project.MerchantId,
+		SubscriptionId: subscription.Id,
+		PlanId:         newPlan.Id,
+	}
+	rsp := &grpc.SubscriptionResponse{}
+	err := suite.service.ChangeSubscriptionPlan(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Equal(suite.T(), newPlan.Id, rsp.Item.PlanId)
+	assert.InDelta(suite.T(), 100, rsp.Item.ProrationAmount, 0.01)
+}
+
+func (suite *SubscriptionTestSuite) TestSubscription_CancelSubscription_AtPeriodEnd() {
+	plan := suite.createPlan(100, 7)
+	subscription := suite.createSubscription(plan)
+
+	req := &grpc.CancelSubscriptionRequest{
+		MerchantId:     suite.project.MerchantId,
+		SubscriptionId: subscription.Id,
+		AtPeriodEnd:    true,
+	}
+	rsp := &grpc.SubscriptionResponse{}
+	err := suite.service.CancelSubscription(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.True(suite.T(), rsp.Item.CancelAtPeriodEnd)
+	assert.Equal(suite.T(), pkg.SubscriptionStatusTrialing, rsp.Item.Status)
+
+	suite.makeSubscriptionDue(rsp.Item)
+	err = suite.service.ProcessSubscriptions(context.TODO())
+	assert.NoError(suite.T(), err)
+
+	subscription, err = suite.service.subscription.GetById(context.TODO(), subscription.Id)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.SubscriptionStatusCanceled, subscription.Status)
+	assert.NotNil(suite.T(), subscription.CanceledAt)
+	assert.Empty(suite.T(), subscription.RenewalOrderId)
+}
+
+func (suite *SubscriptionTestSuite) TestSubscription_ProcessSubscriptions_Renewal_Ok() {
+	plan := suite.createPlan(100, 7)
+	subscription := suite.createSubscription(plan)
+	periodEnd := subscription.CurrentPeriodEnd
+	suite.makeSubscriptionDue(subscription)
+
+	err := suite.service.ProcessSubscriptions(context.TODO())
+	assert.NoError(suite.T(), err)
+
+	subscription, err = suite.service.subscription.GetById(context.TODO(), subscription.Id)
+	assert.NoError(suite.T(), err)
+	assert.NotEmpty(suite.T(), subscription.RenewalOrderId)
+
+	order, err := suite.service.getOrderById(context.TODO(), subscription.RenewalOrderId)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), subscription.Id, order.Issuer.Reference)
+	assert.Equal(suite.T(), pkg.OrderIssuerReferenceTypeSubscription, order.Issuer.ReferenceType)
+	assert.Equal(suite.T(), plan.Amount, order.OrderAmount)
+	assert.Equal(suite.T(), plan.Currency, order.Currency)
+	assert.True(suite.T(), order.IsMerchantInitiated)
+
+	suite.setOrderPrivateStatus(order, constant.OrderStatusProjectComplete)
+	err = suite.service.ProcessSubscriptions(context.TODO())
+	assert.NoError(suite.T(), err)
+
+	subscription, err = suite.service.subscription.GetById(context.TODO(), subscription.Id)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.SubscriptionStatusActive, subscription.Status)
+	assert.Empty(suite.T(), subscription.RenewalOrderId)
+	assert.Equal(suite.T(), periodEnd.Seconds, subscription.CurrentPeriodStart.Seconds)
+	assert.True(suite.T(), subscription.CurrentPeriodEnd.Seconds > periodEnd.Seconds)
+}
+
+func (suite *SubscriptionTestSuite) TestSubscription_ProcessSubscriptions_Dunning() {
+	suite.service.cfg.SubscriptionMaxChargeAttempts = 2
+
+	plan := suite.createPlan(100, 7)
+	subscription := suite.createSubscription(plan)
+
+	for i := 1; i <= 2; i++ {
+		suite.makeSubscriptionDue(subscription)
+		err := suite.service.ProcessSubscriptions(context.TODO())
+		assert.NoError(suite.T(), err)
+
+		subscription, err = suite.service.subscription.GetById(context.TODO(), subscription.Id)
+		assert.NoError(suite.T(), err)
+		assert.NotEmpty(suite.T(), subscription.RenewalOrderId)
+
+		order, err := suite.service.getOrderById(context.TODO(), subscription.RenewalOrderId)
+		assert.NoError(suite.T(), err)
+		suite.setOrderPrivateStatus(order, constant.OrderStatusPaymentSystemDeclined)
+
+		err = suite.service.ProcessSubscriptions(context.TODO())
+		assert.NoError(suite.T(), err)
+
+		subscription, err = suite.service.subscription.GetById(context.TODO(), subscription.Id)
+		assert.NoError(suite.T(), err)
+		assert.EqualValues(suite.T(), i, subscription.FailedAttempts)
+		assert.Empty(suite.T(), subscription.RenewalOrderId)
+	}
+
+	assert.Equal(suite.T(), pkg.SubscriptionStatusCanceled, subscription.Status)
+	assert.Nil(suite.T(), subscription.NextChargeAt)
+}
+
+func (suite *SubscriptionTestSuite) TestSubscription_ProcessSubscriptions_RenewalTimeout() {
+	plan := suite.createPlan(100, 7)
+	subscription := suite.createSubscription(plan)
+	suite.makeSubscriptionDue(subscription)
+
+	err := suite.service.ProcessSubscriptions(context.TODO())
+	assert.NoError(suite.T(), err)
+
+	subscription, err = suite.service.subscription.GetById(context.TODO(), subscription.Id)
+	assert.NoError(suite.T(), err)
+	assert.NotEmpty(suite.T(), subscription.RenewalOrderId)
+
+	// payment of renewal order is in progress and deadline isn't reached
+	err = suite.service.ProcessSubscriptions(context.TODO())
+	assert.NoError(suite.T(), err)
+
+	subscription, err = suite.service.subscription.GetById(context.TODO(), subscription.Id)
+	assert.NoError(suite.T(), err)
+	assert.NotEmpty(suite.T(), subscription.RenewalOrderId)
+	assert.EqualValues(suite.T(), 0, subscription.FailedAttempts)
+
+	oid, _ := primitive.ObjectIDFromHex(subscription.RenewalOrderId)
+	_, err = suite.service.db.Collection(collectionOrder).UpdateOne(
+		context.TODO(),
+		bson.M{"_id": oid},
+		bson.M{"$set": bson.M{"created_at": time.Now().Add(-suite.service.cfg.GetSubscriptionRenewalTimeout() - time.Minute)}},
+	)
+	assert.NoError(suite.T(), err)
+
+	err = suite.service.ProcessSubscriptions(context.TODO())
+	assert.NoError(suite.T(), err)
+
+	subscription, err = suite.service.subscription.GetById(context.TODO(), subscription.Id)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), subscription.RenewalOrderId)
+	assert.EqualValues(suite.T(), 1, subscription.FailedAttempts)
+	assert.Equal(suite.T(), pkg.SubscriptionStatusPastDue, subscription.Status)
+}
+
+func (suite *SubscriptionTestSuite) createPlan(amount float64, trialDays int32) *billing.SubscriptionPlan {
+	req := &billing.SubscriptionPlan{
+		MerchantId:    suite.project.MerchantId,
+		ProjectId:     suite.project.Id,
+		Name:          "Basic",
+		Amount:        amount,
+		Currency:      "RUB",
+		Interval:      pkg.SubscriptionIntervalMonth,
+		IntervalCount: 1,
+		TrialDays:     trialDays,
+		IsActive:      true,
+	}
+	rsp := &grpc.SubscriptionPlanResponse{}
+	err := suite.service.SetSubscriptionPlan(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	return rsp.Item
+}
+
+func (suite *SubscriptionTestSuite) createSubscription(plan *billing.SubscriptionPlan) *billing.Subscription {
+	req := &grpc.CreateSubscriptionRequest{
+		MerchantId:   suite.project.MerchantId,
+		PlanId:       plan.Id,
+		OrderId:      suite.order.Id,
+		StoredCardId: primitive.NewObjectID().Hex(),
+	}
+	rsp := &grpc.SubscriptionResponse{}
+	err := suite.service.CreateSubscription(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+
+	return rsp.Item
+}
+
+func (suite *SubscriptionTestSuite) makeSubscriptionDue(subscription *billing.Subscription) {
+	subscription.NextChargeAt, _ = ptypes.TimestampProto(time.Now().Add(-time.Minute))
+	err := suite.service.subscription.Update(context.TODO(), subscription)
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *SubscriptionTestSuite) setOrderPrivateStatus(order *billing.Order, status int32) {
+	oid, _ := primitive.ObjectIDFromHex(order.Id)
+	_, err := suite.service.db.Collection(collectionOrder).UpdateOne(
+		context.TODO(),
+		bson.M{"_id": oid},
+		bson.M{"$set": bson.M{"private_status": status}},
+	)
+	assert.NoError(suite.T(), err)
+}
@@ -967,20 +967,24 @@ func helperMakeRefund(suite suite.Suite, service *Service, order *billing.Order,
 	err = service.updateOrder(context.TODO(), order)
 	assert.NoError(suite.T(), err)
 
+	return helperProcessRefundCallback(suite, service, order, rsp2.Item)
+}
+
+func helperProcessRefundCallback(suite suite.Suite, service *Service, order *billing.Order, refund *billing.Refund) *billing.Refund {
 	refundReq := &billing.CardPayRefundCallback{
 		MerchantOrder: &billing.CardPayMerchantOrder{
-			Id: rsp2.Item.Id,
+			Id: refund.Id,
 		},
 		PaymentMethod: order.PaymentMethod.Group,
 		PaymentData: &billing.CardPayRefundCallbackPaymentData{
-			Id:              rsp2.Item.Id,
+			Id:              refund.Id,
 			RemainingAmount: 90,
 		},
 		RefundData: &billing.CardPayRefundCallbackRefundData{
 			Amount:   10,
 			Created:  time.Now().Format(cardPayDateFormat),
 			Id:       primitive.NewObjectID().Hex(),
-			Currency: rsp2.Item.Currency,
+			Currency: refund.Currency,
 			Status:   pkg.CardPayPaymentResponseStatusCompleted,
 			AuthCode: primitive.NewObjectID().Hex(),
 			Is_3D:    true,
@@ -1010,14 +1014,14 @@ func helperMakeRefund(suite suite.Suite, service *Service, order *billing.Order,
 	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp3.Status)
 	assert.Empty(suite.T(), rsp3.Error)
 
-	var refund *billing.Refund
-	oid, _ := primitive.ObjectIDFromHex(rsp2.Item.Id)
+	var result *billing.Refund
+	oid, _ := primitive.ObjectIDFromHex(refund.Id)
 	filter := bson.M{"_id": oid}
-	err = service.db.Collection(collectionRefund).FindOne(context.TODO(), filter).Decode(&refund)
-	assert.NotNil(suite.T(), refund)
-	assert.Equal(suite.T(), pkg.RefundStatusCompleted, refund.Status)
+	err = service.db.Collection(collectionRefund).FindOne(context.TODO(), filter).Decode(&result)
+	assert.NotNil(suite.T(), result)
+	assert.Equal(suite.T(), pkg.RefundStatusCompleted, result.Status)
 
-	return refund
+	return result
 }
 
 func createProductsForProject(
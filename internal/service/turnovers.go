@@ -21,6 +21,7 @@ import (
 const (
 	collectionAnnualTurnovers = "annual_turnovers"
 	cacheTurnoverKey          = "turnover:company:%s:country:%s:year:%d"
+	cacheAvgMonthRateKey      = "rate:avg_month:%s:%s:%s:%s:%s"
 
 	errorCannotCalculateTurnoverCountry = "can not calculate turnover for country"
 	errorCannotCalculateTurnoverWorld   = "can not calculate turnover for world"
@@ -144,6 +145,20 @@ func (s *Service) calcAnnualTurnover(ctx context.Context, countryCode, operating
 			}
 		}
 		break
+	case pkg.VatCurrencyRatesPolicyAvgMonth:
+		for from.Before(to) {
+			monthTo := now.New(from).EndOfMonth()
+			if monthTo.After(to) {
+				monthTo = to
+			}
+			amnt, err := s.getTurnover(ctx, from, monthTo, countryCode, targetCurrency, currencyPolicy, ratesType, ratesSource, operatingCompanyId)
+			if err != nil {
+				return err
+			}
+			amount += amnt
+			from = from.AddDate(0, 1, 0)
+		}
+		break
 	default:
 		err = errorTurnoversCurrencyRatesPolicyNotSupported
 		return err
@@ -199,7 +214,7 @@ func (s *Service) getTurnover(
 	case pkg.VatCurrencyRatesPolicyOnDay:
 		query = append(query, bson.M{"$group": bson.M{"_id": "$local_currency", "amount": bson.M{"$sum": "$local_amount"}}})
 		break
-	case pkg.VatCurrencyRatesPolicyLastDay:
+	case pkg.VatCurrencyRatesPolicyLastDay, pkg.VatCurrencyRatesPolicyAvgMonth:
 		query = append(query, bson.M{"$group": bson.M{"_id": "$original_currency", "amount": bson.M{"$sum": "$original_amount"}}})
 		break
 	default:
@@ -248,6 +263,17 @@ func (s *Service) getTurnover(
 			continue
 		}
 
+		if currencyPolicy == pkg.VatCurrencyRatesPolicyAvgMonth {
+			rate, err := s.getAvgMonthRate(ctx, v.Id, targetCurrency, ratesType, ratesSource, from)
+
+			if err != nil {
+				return 0, err
+			}
+
+			amount += v.Amount * rate
+			continue
+		}
+
 		req := &currencies.ExchangeCurrencyByDateCommonRequest{
 			From:              v.Id,
 			To:                targetCurrency,
@@ -278,6 +304,79 @@ func (s *Service) getTurnover(
 	return
 }
 
+// getAvgMonthRate returns the arithmetic mean of daily rates for the month containing date.
+// Averages for completed months are cached forever, the current month is averaged up to today
+// and cached until the end of day.
+func (s *Service) getAvgMonthRate(ctx context.Context, from, to, ratesType, ratesSource string, date time.Time) (float64, error) {
+	monthFrom := now.New(date).BeginningOfMonth()
+	monthTo := now.New(date).EndOfMonth()
+	period := monthFrom.Format("2006-01")
+	expire := time.Duration(0)
+
+	if !monthTo.Before(time.Now()) {
+		monthTo = now.EndOfDay()
+		period = monthTo.Format("2006-01-02")
+		expire = time.Until(monthTo)
+	}
+
+	key := fmt.Sprintf(cacheAvgMonthRateKey, from, to, ratesType, ratesSource, period)
+	rate := float64(0)
+
+	if err := s.cacher.Get(key, &rate); err == nil && rate > 0 {
+		return rate, nil
+	}
+
+	sum := float64(0)
+	count := 0
+
+	for day := monthFrom; day.Before(monthTo); day = day.AddDate(0, 0, 1) {
+		datetime, err := ptypes.TimestampProto(now.New(day).EndOfDay())
+
+		if err != nil {
+			return 0, errorTurnoversExchangeFailed
+		}
+
+		req := &currencies.GetRateByDateCommonRequest{
+			From:     from,
+			To:       to,
+			RateType: ratesType,
+			Source:   ratesSource,
+			Datetime: datetime,
+		}
+		rsp, err := s.curService.GetRateByDateCommon(ctx, req)
+
+		if err != nil {
+			zap.L().Error(
+				pkg.ErrorGrpcServiceCallFailed,
+				zap.Error(err),
+				zap.String(errorFieldService, "CurrencyRatesService"),
+				zap.String(errorFieldMethod, "GetRateByDateCommon"),
+				zap.Any(errorFieldRequest, req),
+			)
+			return 0, errorTurnoversExchangeFailed
+		}
+
+		if rsp.Rate <= 0 {
+			continue
+		}
+
+		sum += rsp.Rate
+		count++
+	}
+
+	if count == 0 {
+		return 0, errorTurnoversExchangeFailed
+	}
+
+	rate = sum / float64(count)
+
+	if err := s.cacher.Set(key, rate, expire); err != nil {
+		zap.S().Errorf("Unable to set cache", "err", err.Error(), "key", key, "data", rate)
+	}
+
+	return rate, nil
+}
+
 func newTurnoverService(svc *Service) *Turnover {
 	s := &Turnover{svc: svc}
 	return s
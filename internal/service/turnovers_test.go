@@ -131,7 +131,28 @@ func (suite *TurnoversTestSuite) SetupTest() {
 		VatCurrencyRatesSource: "cbtr",
 	}
 
-	countries := []*billing.Country{countryRu, countryTr}
+	countryDe := &billing.Country{
+		Id:              primitive.NewObjectID().Hex(),
+		IsoCodeA2:       "DE",
+		Region:          "EU",
+		Currency:        "EUR",
+		PaymentsAllowed: true,
+		ChangeAllowed:   true,
+		VatEnabled:      true,
+		PriceGroupId:    pg.Id,
+		VatCurrency:     "EUR",
+		VatThreshold: &billing.CountryVatThreshold{
+			Year:  0,
+			World: 0,
+		},
+		VatPeriodMonth:         1,
+		VatDeadlineDays:        10,
+		VatStoreYears:          10,
+		VatCurrencyRatesPolicy: "avg-month",
+		VatCurrencyRatesSource: "ecb",
+	}
+
+	countries := []*billing.Country{countryRu, countryTr, countryDe}
 	if err := suite.service.country.MultipleInsert(context.TODO(), countries); err != nil {
 		suite.FailNow("Insert country test data failed", "%v", err)
 	}
@@ -225,6 +246,47 @@ func (suite *TurnoversTestSuite) TestTurnovers_calcAnnualTurnover() {
 	assert.Equal(suite.T(), at.Amount, ref2)
 }
 
+func (suite *TurnoversTestSuite) TestTurnovers_calcAnnualTurnover_AvgMonth() {
+	countryCode := "DE"
+
+	suite.fillAccountingEntries(suite.operatingCompany.Id, countryCode, 10)
+
+	err := suite.service.calcAnnualTurnover(context.TODO(), countryCode, suite.operatingCompany.Id)
+	assert.NoError(suite.T(), err)
+
+	at, err := suite.service.turnover.Get(context.TODO(), suite.operatingCompany.Id, countryCode, time.Now().Year())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), at.Country, countryCode)
+	assert.Equal(suite.T(), at.Currency, "EUR")
+	ref := suite.getTurnoverReference(now.BeginningOfYear(), now.EndOfDay(), suite.operatingCompany.Id, countryCode, "EUR", "avg-month")
+	assert.InDelta(suite.T(), ref, at.Amount, 0.01)
+	assert.True(suite.T(), at.Amount > 0)
+}
+
+func (suite *TurnoversTestSuite) TestTurnovers_getAvgMonthRate() {
+	rate, err := suite.service.getAvgMonthRate(context.TODO(), "USD", "EUR", curPkg.RateTypeCentralbanks, "ecb", time.Now().AddDate(0, -1, 0))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), float64(3), rate)
+
+	rate, err = suite.service.getAvgMonthRate(context.TODO(), "USD", "EUR", curPkg.RateTypeCentralbanks, "ecb", time.Now())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), float64(3), rate)
+
+	// average of current month is cached until the end of day
+	suite.service.curService = mocks.NewCurrencyServiceMockError()
+
+	rate, err = suite.service.getAvgMonthRate(context.TODO(), "USD", "EUR", curPkg.RateTypeCentralbanks, "ecb", time.Now())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), float64(3), rate)
+}
+
+func (suite *TurnoversTestSuite) TestTurnovers_getAvgMonthRate_Error() {
+	suite.service.curService = mocks.NewCurrencyServiceMockError()
+
+	_, err := suite.service.getAvgMonthRate(context.TODO(), "USD", "EUR", curPkg.RateTypeCentralbanks, "ecb", time.Now())
+	assert.Equal(suite.T(), errorTurnoversExchangeFailed, err)
+}
+
 func (suite *TurnoversTestSuite) TestTurnovers_CalcAnnualTurnovers() {
 	countryCode := "RU"
 
@@ -332,7 +394,7 @@ func (suite *TurnoversTestSuite) getTurnoverReference(from, to time.Time, operat
 	case pkg.VatCurrencyRatesPolicyOnDay:
 		query = append(query, bson.M{"$group": bson.M{"_id": "$local_currency", "amount": bson.M{"$sum": "$local_amount"}}})
 		break
-	case pkg.VatCurrencyRatesPolicyLastDay:
+	case pkg.VatCurrencyRatesPolicyLastDay, pkg.VatCurrencyRatesPolicyAvgMonth:
 		query = append(query, bson.M{"$group": bson.M{"_id": "$original_currency", "amount": bson.M{"$sum": "$original_amount"}}})
 		break
 	default:
@@ -361,6 +423,12 @@ func (suite *TurnoversTestSuite) getTurnoverReference(from, to time.Time, operat
 			continue
 		}
 
+		if currencyPolicy == pkg.VatCurrencyRatesPolicyAvgMonth {
+			// currencies service mock returns the same rate for every day
+			amount += v.Amount * 3
+			continue
+		}
+
 		req := &currencies.ExchangeCurrencyByDateCommonRequest{
 			From:              v.Id,
 			To:                targetCurrency,
@@ -53,7 +53,7 @@ func (suite *UserProfileTestSuite) SetupTest() {
 		suite.FailNow("Config load failed", "%v", err)
 	}
 
-	cfg.CardPayApiUrl = "https://sandbox.cardpay.com"
+	cfg.CardPay.ApiUrl = "https://sandbox.cardpay.com"
 
 	db, err := mongodb.NewDatabase()
 
@@ -32,10 +32,9 @@ const (
 	VatPeriodEvery2Month = 2
 	VatPeriodEvery3Month = 3
 
-	errorMsgVatReportTaxServiceGetRateFailed   = "tax service get rate error"
-	errorMsgVatReportTurnoverNotFound          = "turnover not found"
-	errorMsgVatReportRatesPolicyNotImplemented = "selected currency rates policy not implemented yet"
-	errorMsgVatReportCantGetTimeForDate        = "cannot get vat report time for date"
+	errorMsgVatReportTaxServiceGetRateFailed = "tax service get rate error"
+	errorMsgVatReportTurnoverNotFound        = "turnover not found"
+	errorMsgVatReportCantGetTimeForDate      = "cannot get vat report time for date"
 )
 
 var (
@@ -747,7 +746,8 @@ func (h *vatReportProcessor) processVatReportForPeriod(ctx context.Context, coun
 			worldTurnover.Currency,
 			targetCurrency,
 			worldTurnover.Amount,
-			country.VatCurrencyRatesSource,
+			country,
+			h.date,
 		)
 
 		if err != nil {
@@ -894,15 +894,6 @@ func (h *vatReportProcessor) processAccountingEntriesForPeriod(ctx context.Conte
 		return nil
 	}
 
-	if country.VatCurrencyRatesPolicy == pkg.VatCurrencyRatesPolicyAvgMonth {
-		zap.S().Warnf(
-			errorMsgVatReportRatesPolicyNotImplemented,
-			"country", country.IsoCodeA2,
-			"policy", pkg.VatCurrencyRatesPolicyAvgMonth,
-		)
-		return nil
-	}
-
 	from, to, err := h.Service.getVatReportTimeForDate(country.VatPeriodMonth, h.date)
 	if err != nil {
 		zap.L().Error(
@@ -970,12 +961,19 @@ func (h *vatReportProcessor) processAccountingEntriesForPeriod(ctx context.Conte
 		}
 		amount := ae.LocalAmount
 		if ae.LocalCurrency != ae.OriginalCurrency {
+			createdAt, err := ptypes.Timestamp(ae.CreatedAt)
+
+			if err != nil {
+				return err
+			}
+
 			amount, err = h.exchangeAmount(
 				ctx,
 				ae.OriginalCurrency,
 				ae.LocalCurrency,
 				ae.OriginalAmount,
-				country.VatCurrencyRatesSource,
+				country,
+				createdAt,
 			)
 
 			if err != nil {
@@ -1026,14 +1024,25 @@ func (h *vatReportProcessor) exchangeAmount(
 	ctx context.Context,
 	from, to string,
 	amount float64,
-	source string,
+	country *billing.Country,
+	date time.Time,
 ) (float64, error) {
+	if country.VatCurrencyRatesPolicy == pkg.VatCurrencyRatesPolicyAvgMonth {
+		rate, err := h.Service.getAvgMonthRate(ctx, from, to, curPkg.RateTypeCentralbanks, country.VatCurrencyRatesSource, date)
+
+		if err != nil {
+			return 0, errorVatReportCurrencyExchangeFailed
+		}
+
+		return amount * rate, nil
+	}
+
 	req := &currencies.ExchangeCurrencyByDateCommonRequest{
 		From:              from,
 		To:                to,
 		RateType:          curPkg.RateTypeCentralbanks,
 		ExchangeDirection: curPkg.ExchangeDirectionBuy,
-		Source:            source,
+		Source:            country.VatCurrencyRatesSource,
 		Amount:            amount,
 		Datetime:          h.ts,
 	}
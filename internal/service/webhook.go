@@ -0,0 +1,601 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	mongodb "gopkg.in/paysuper/paysuper-database-mongo.v1"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const (
+	collectionWebhookEvents = "webhook_events"
+
+	// time during which webhook event locked by dispatcher for delivery
+	webhookLockTimeout = 5 * time.Minute
+	// maximal delay between two attempts of webhook delivery
+	webhookMaxRetryDelay = 24 * time.Hour
+	// maximal count of webhook events delivered by one run of dispatcher
+	webhookDispatchBatchSize = 100
+)
+
+var (
+	webhookErrorNotFound           = newBillingServerErrorMsg("wh000001", "webhook event not found")
+	webhookErrorRedeliveryNotAllow = newBillingServerErrorMsg("wh000002", "webhook event with specified status can't be redelivered")
+	webhookErrorUrlEmpty           = newBillingServerErrorMsg("wh000003", "project hasn't url for notification")
+
+	webhookRedeliveryAllowedStatuses = []string{
+		pkg.WebhookEventStatusFailed,
+		pkg.WebhookEventStatusDead,
+		pkg.WebhookEventStatusDelivered,
+	}
+)
+
+type WebhookEventRepositoryInterface interface {
+	Insert(ctx context.Context, event *billing.WebhookEvent) error
+	Update(ctx context.Context, event *billing.WebhookEvent) error
+	Find(ctx context.Context, query bson.M, offset, limit int64) ([]*billing.WebhookEvent, error)
+	Count(ctx context.Context, query bson.M) (int64, error)
+	// LockForDelivery finds webhook event by query, locks it for delivery on webhookLockTimeout
+	// with setting of additional fields and returns it. Returns nil if webhook event not found.
+	LockForDelivery(ctx context.Context, query bson.M, set bson.M) (*billing.WebhookEvent, error)
+}
+
+func newWebhookEventRepository(svc *Service) WebhookEventRepositoryInterface {
+	s := &WebhookEvent{svc: svc}
+	return s
+}
+
+type webhookEventBody struct {
+	Id        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Object    interface{} `json:"object"`
+}
+
+func (s *Service) ListFailedWebhooks(
+	ctx context.Context,
+	req *grpc.ListFailedWebhooksRequest,
+	rsp *grpc.ListFailedWebhooksResponse,
+) error {
+	merchantOid, _ := primitive.ObjectIDFromHex(req.MerchantId)
+	query := bson.M{"merchant_id": merchantOid}
+
+	if req.ProjectId != "" {
+		query["project_id"], _ = primitive.ObjectIDFromHex(req.ProjectId)
+	}
+
+	if req.OrderId != "" {
+		query["order_uuid"] = req.OrderId
+	}
+
+	if req.Status != "" {
+		query["status"] = req.Status
+	} else {
+		query["status"] = bson.M{"$in": []string{pkg.WebhookEventStatusFailed, pkg.WebhookEventStatusDead}}
+	}
+
+	count, err := s.webhookEvent.Count(ctx, query)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = orderErrorUnknown
+		return nil
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = &grpc.ListFailedWebhooksResponseItem{Count: count}
+
+	if count <= 0 {
+		return nil
+	}
+
+	limit := req.Limit
+
+	if limit <= 0 {
+		limit = pkg.DatabaseRequestDefaultLimit
+	}
+
+	rsp.Item.Items, err = s.webhookEvent.Find(ctx, query, req.Offset, limit)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = orderErrorUnknown
+		rsp.Item = nil
+	}
+
+	return nil
+}
+
+func (s *Service) RedeliverWebhook(
+	ctx context.Context,
+	req *grpc.RedeliverWebhookRequest,
+	rsp *grpc.RedeliverWebhookResponse,
+) error {
+	oid, _ := primitive.ObjectIDFromHex(req.Id)
+	merchantOid, _ := primitive.ObjectIDFromHex(req.MerchantId)
+	query := bson.M{"_id": oid, "merchant_id": merchantOid}
+	count, err := s.webhookEvent.Count(ctx, query)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = orderErrorUnknown
+		return nil
+	}
+
+	if count <= 0 {
+		rsp.Status = pkg.ResponseStatusNotFound
+		rsp.Message = webhookErrorNotFound
+		return nil
+	}
+
+	query["status"] = bson.M{"$in": webhookRedeliveryAllowedStatuses}
+	event, err := s.webhookEvent.LockForDelivery(ctx, query, bson.M{"attempts": 0})
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = orderErrorUnknown
+		return nil
+	}
+
+	if event == nil {
+		rsp.Status = pkg.ResponseStatusBadData
+		rsp.Message = webhookErrorRedeliveryNotAllow
+		return nil
+	}
+
+	err = s.deliverWebhookEvent(ctx, event)
+
+	if err != nil {
+		rsp.Status = pkg.ResponseStatusSystemError
+		rsp.Message = orderErrorUnknown
+		return nil
+	}
+
+	rsp.Status = pkg.ResponseStatusOk
+	rsp.Item = event
+
+	return nil
+}
+
+// ProcessWebhookEvents delivers to merchants webhook events which are ready for delivery
+// and returns count of processed webhook events.
+func (s *Service) ProcessWebhookEvents(ctx context.Context) (int, error) {
+	count := 0
+
+	if err := s.moveOrdersWebhookEvents(ctx); err != nil {
+		return count, err
+	}
+
+	for count < webhookDispatchBatchSize {
+		query := bson.M{
+			"status":          bson.M{"$in": []string{pkg.WebhookEventStatusPending, pkg.WebhookEventStatusFailed}},
+			"next_attempt_at": bson.M{"$lte": time.Now()},
+		}
+		event, err := s.webhookEvent.LockForDelivery(ctx, query, nil)
+
+		if err != nil {
+			return count, err
+		}
+
+		if event == nil {
+			break
+		}
+
+		if err = s.deliverWebhookEvent(ctx, event); err != nil {
+			return count, err
+		}
+
+		count++
+	}
+
+	return count, nil
+}
+
+func (s *Service) newOrderWebhookEvent(order *billing.Order) (*billing.WebhookEvent, error) {
+	now := time.Now()
+	event := &billing.WebhookEvent{
+		Id:         primitive.NewObjectID().Hex(),
+		MerchantId: order.GetMerchantId(),
+		ProjectId:  order.GetProjectId(),
+		OrderId:    order.Id,
+		OrderUuid:  order.Uuid,
+		Type:       order.GetPublicStatus(),
+		Status:     pkg.WebhookEventStatusPending,
+		CreatedAt:  ptypes.TimestampNow(),
+		UpdatedAt:  ptypes.TimestampNow(),
+	}
+	event.NextAttemptAt, _ = ptypes.TimestampProto(now)
+
+	b, err := json.Marshal(&webhookEventBody{
+		Id:        event.Id,
+		Type:      event.Type,
+		CreatedAt: now,
+		Object:    order,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	event.Payload = string(b)
+
+	return event, nil
+}
+
+// addOrderWebhookEvent adds to order webhook event about current public status of order. Webhook event is
+// saved together with order, so it can't be lost or sent about order changes which weren't saved, and moved to
+// outbox by moveOrderWebhookEvents after that. Webhook event isn't created for projects which don't use notifications.
+func (s *Service) addOrderWebhookEvent(order *billing.Order) (*billing.WebhookEvent, error) {
+	if order.Project.CallbackProtocol == pkg.ProjectCallbackProtocolEmpty {
+		return nil, nil
+	}
+
+	event, err := s.newOrderWebhookEvent(order)
+
+	if err != nil {
+		zap.L().Error(
+			"build of webhook event failed",
+			zap.Error(err),
+			zap.String("order_uuid", order.Uuid),
+		)
+		return nil, err
+	}
+
+	order.PendingWebhookEvents = append(order.PendingWebhookEvents, event)
+
+	return event, nil
+}
+
+// moveOrderWebhookEvents moves to outbox webhook events saved together with order. Webhook event keeps its
+// identifier in outbox, so event moved by interrupted call before isn't duplicated by the next call.
+func (s *Service) moveOrderWebhookEvents(ctx context.Context, order *billing.Order) error {
+	oid, _ := primitive.ObjectIDFromHex(order.Id)
+
+	for len(order.PendingWebhookEvents) > 0 {
+		event := order.PendingWebhookEvents[0]
+
+		if err := s.webhookEvent.Insert(ctx, event); err != nil && !mongodb.IsDuplicate(err) {
+			return err
+		}
+
+		eventOid, _ := primitive.ObjectIDFromHex(event.Id)
+		filter := bson.M{"_id": oid}
+		update := bson.M{"$pull": bson.M{"pending_webhook_events": bson.M{"_id": eventOid}}}
+		_, err := s.db.Collection(collectionOrder).UpdateOne(ctx, filter, update)
+
+		if err != nil {
+			zap.L().Error(
+				pkg.ErrorDatabaseQueryFailed,
+				zap.Error(err),
+				zap.String(pkg.ErrorDatabaseFieldCollection, collectionOrder),
+				zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationUpdate),
+				zap.Any(pkg.ErrorDatabaseFieldQuery, filter),
+				zap.Any(pkg.ErrorDatabaseFieldSet, update),
+			)
+			return err
+		}
+
+		order.PendingWebhookEvents = order.PendingWebhookEvents[1:]
+	}
+
+	return nil
+}
+
+// moveOrdersWebhookEvents moves to outbox webhook events of orders which weren't moved after order update,
+// e.g. because of failure of database or restart of service.
+func (s *Service) moveOrdersWebhookEvents(ctx context.Context) error {
+	query := bson.M{"pending_webhook_events._id": bson.M{"$exists": true}}
+	opts := options.Find().SetLimit(webhookDispatchBatchSize)
+	cursor, err := s.db.Collection(collectionOrder).Find(ctx, query, opts)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionOrder),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return err
+	}
+
+	var orders []*billing.Order
+	err = cursor.All(ctx, &orders)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionOrder),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return err
+	}
+
+	for _, order := range orders {
+		if err = s.moveOrderWebhookEvents(ctx, order); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addDisputeWebhookEvent writes to outbox webhook event about current status of dispute
+func (s *Service) addDisputeWebhookEvent(
+	ctx context.Context,
+	dispute *billing.Dispute,
+	order *billing.Order,
+) (*billing.WebhookEvent, error) {
+	if order.Project.CallbackProtocol == pkg.ProjectCallbackProtocolEmpty {
+		return nil, nil
+	}
+
+	now := time.Now()
+	event := &billing.WebhookEvent{
+		Id:         primitive.NewObjectID().Hex(),
+		MerchantId: dispute.MerchantId,
+		ProjectId:  dispute.ProjectId,
+		OrderId:    dispute.OrderId,
+		OrderUuid:  dispute.OrderUuid,
+		Type:       disputeWebhookEventTypes[dispute.Status],
+		Status:     pkg.WebhookEventStatusPending,
+		CreatedAt:  ptypes.TimestampNow(),
+		UpdatedAt:  ptypes.TimestampNow(),
+	}
+	event.NextAttemptAt, _ = ptypes.TimestampProto(now)
+
+	b, err := json.Marshal(&webhookEventBody{
+		Id:        event.Id,
+		Type:      event.Type,
+		CreatedAt: now,
+		Object:    dispute,
+	})
+
+	if err != nil {
+		zap.L().Error(
+			"build of webhook event failed",
+			zap.Error(err),
+			zap.String("dispute_id", dispute.Id),
+		)
+		return nil, err
+	}
+
+	event.Payload = string(b)
+
+	if err = s.webhookEvent.Insert(ctx, event); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func (s *Service) deliverWebhookEvent(ctx context.Context, event *billing.WebhookEvent) error {
+	event.Attempts++
+	httpStatus, err := s.sendWebhookEvent(ctx, event)
+	event.LastHttpStatus = int32(httpStatus)
+	event.UpdatedAt = ptypes.TimestampNow()
+
+	if err == nil {
+		event.Status = pkg.WebhookEventStatusDelivered
+		event.LastError = ""
+		event.NextAttemptAt = nil
+		event.DeliveredAt = ptypes.TimestampNow()
+	} else {
+		event.LastError = err.Error()
+
+		if event.Attempts >= s.cfg.WebhookMaxAttempts {
+			event.Status = pkg.WebhookEventStatusDead
+			event.NextAttemptAt = nil
+		} else {
+			event.Status = pkg.WebhookEventStatusFailed
+			event.NextAttemptAt, _ = ptypes.TimestampProto(time.Now().Add(s.getWebhookRetryDelay(event.Attempts)))
+		}
+
+		zap.L().Error(
+			"webhook event delivery failed",
+			zap.Error(err),
+			zap.String("event_id", event.Id),
+			zap.String("order_uuid", event.OrderUuid),
+			zap.Int32("attempts", event.Attempts),
+			zap.String("status", event.Status),
+		)
+	}
+
+	return s.webhookEvent.Update(ctx, event)
+}
+
+func (s *Service) sendWebhookEvent(ctx context.Context, event *billing.WebhookEvent) (int, error) {
+	project, err := s.project.GetById(ctx, event.ProjectId)
+
+	if err != nil {
+		return 0, err
+	}
+
+	event.Url = project.GetNotificationUrl(event.Type)
+
+	if event.Url == "" {
+		return 0, webhookErrorUrlEmpty
+	}
+
+	req, err := http.NewRequest(http.MethodPost, event.Url, bytes.NewBufferString(event.Payload))
+
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+	req.Header.Set(pkg.WebhookHeaderEventId, event.Id)
+	req.Header.Set(pkg.WebhookHeaderSignature, getWebhookSignature(event.Payload, project.SecretKey))
+
+	rsp, err := s.webhookHttpClient.Do(req.WithContext(ctx))
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer rsp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, rsp.Body)
+
+	if rsp.StatusCode < http.StatusOK || rsp.StatusCode >= http.StatusMultipleChoices {
+		return rsp.StatusCode, fmt.Errorf("webhook receiver returned unexpected http status %d", rsp.StatusCode)
+	}
+
+	return rsp.StatusCode, nil
+}
+
+// getWebhookRetryDelay returns delay before next attempt of webhook delivery,
+// delay is doubled on every failed attempt.
+func (s *Service) getWebhookRetryDelay(attempts int32) time.Duration {
+	delay := s.cfg.GetWebhookRetryDelay()
+
+	for i := int32(1); i < attempts; i++ {
+		delay *= 2
+
+		if delay >= webhookMaxRetryDelay {
+			return webhookMaxRetryDelay
+		}
+	}
+
+	return delay
+}
+
+func getWebhookSignature(payload, secret string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(payload))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (h *WebhookEvent) Insert(ctx context.Context, event *billing.WebhookEvent) error {
+	_, err := h.svc.db.Collection(collectionWebhookEvents).InsertOne(ctx, event)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionWebhookEvents),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationInsert),
+			zap.Any(pkg.ErrorDatabaseFieldDocument, event),
+		)
+	}
+
+	return err
+}
+
+func (h *WebhookEvent) Update(ctx context.Context, event *billing.WebhookEvent) error {
+	oid, _ := primitive.ObjectIDFromHex(event.Id)
+	filter := bson.M{"_id": oid}
+	_, err := h.svc.db.Collection(collectionWebhookEvents).ReplaceOne(ctx, filter, event)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionWebhookEvents),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationUpdate),
+			zap.Any(pkg.ErrorDatabaseFieldDocument, event),
+		)
+	}
+
+	return err
+}
+
+func (h *WebhookEvent) Find(ctx context.Context, query bson.M, offset, limit int64) ([]*billing.WebhookEvent, error) {
+	opts := options.Find().
+		SetSort(bson.M{"created_at": -1}).
+		SetSkip(offset).
+		SetLimit(limit)
+	cursor, err := h.svc.db.Collection(collectionWebhookEvents).Find(ctx, query, opts)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionWebhookEvents),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	var events []*billing.WebhookEvent
+	err = cursor.All(ctx, &events)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorQueryCursorExecutionFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionWebhookEvents),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (h *WebhookEvent) Count(ctx context.Context, query bson.M) (int64, error) {
+	count, err := h.svc.db.Collection(collectionWebhookEvents).CountDocuments(ctx, query)
+
+	if err != nil {
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionWebhookEvents),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationCount),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+		)
+	}
+
+	return count, err
+}
+
+func (h *WebhookEvent) LockForDelivery(ctx context.Context, query bson.M, set bson.M) (*billing.WebhookEvent, error) {
+	if set == nil {
+		set = bson.M{}
+	}
+
+	set["next_attempt_at"] = time.Now().Add(webhookLockTimeout)
+	set["updated_at"] = time.Now()
+	update := bson.M{"$set": set}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.M{"next_attempt_at": 1}).
+		SetReturnDocument(options.After)
+
+	event := &billing.WebhookEvent{}
+	err := h.svc.db.Collection(collectionWebhookEvents).FindOneAndUpdate(ctx, query, update, opts).Decode(event)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+
+		zap.L().Error(
+			pkg.ErrorDatabaseQueryFailed,
+			zap.Error(err),
+			zap.String(pkg.ErrorDatabaseFieldCollection, collectionWebhookEvents),
+			zap.String(pkg.ErrorDatabaseFieldOperation, pkg.ErrorDatabaseFieldOperationUpdate),
+			zap.Any(pkg.ErrorDatabaseFieldQuery, query),
+			zap.Any(pkg.ErrorDatabaseFieldSet, update),
+		)
+		return nil, err
+	}
+
+	return event, nil
+}
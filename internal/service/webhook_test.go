@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/uuid"
+	casbinMocks "github.com/paysuper/casbin-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/internal/config"
+	"github.com/paysuper/paysuper-billing-server/internal/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/constant"
+	reportingMocks "github.com/paysuper/paysuper-reporter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	mongodb "gopkg.in/paysuper/paysuper-database-mongo.v1"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type WebhookTestSuite struct {
+	suite.Suite
+	service *Service
+	cache   CacheInterface
+
+	project  *billing.Project
+	server   *httptest.Server
+	handler  http.HandlerFunc
+	requests []*http.Request
+	bodies   []string
+}
+
+func Test_Webhook(t *testing.T) {
+	suite.Run(t, new(WebhookTestSuite))
+}
+
+func (suite *WebhookTestSuite) SetupTest() {
+	cfg, err := config.NewConfig()
+	if err != nil {
+		suite.FailNow("Config load failed", "%v", err)
+	}
+
+	db, err := mongodb.NewDatabase()
+	if err != nil {
+		suite.FailNow("Database connection failed", "%v", err)
+	}
+
+	redisdb := mocks.NewTestRedis()
+	suite.cache, err = NewCacheRedis(redisdb, "cache")
+	suite.service = NewBillingService(
+		db,
+		cfg,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		suite.cache,
+		mocks.NewCurrencyServiceMockOk(),
+		mocks.NewDocumentSignerMockOk(),
+		&reportingMocks.ReporterService{},
+		mocks.NewFormatterOK(),
+		mocks.NewBrokerMockOk(),
+		&casbinMocks.CasbinService{},
+	)
+
+	if err := suite.service.Init(); err != nil {
+		suite.FailNow("Billing service initialization failed", "%v", err)
+	}
+
+	suite.requests = nil
+	suite.bodies = nil
+	suite.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		suite.requests = append(suite.requests, r)
+		suite.bodies = append(suite.bodies, string(b))
+		suite.handler(w, r)
+	}))
+	suite.service.webhookHttpClient = suite.server.Client()
+
+	suite.project = &billing.Project{
+		Id:                primitive.NewObjectID().Hex(),
+		MerchantId:        primitive.NewObjectID().Hex(),
+		CallbackCurrency:  "RUB",
+		CallbackProtocol:  pkg.ProjectCallbackProtocolDefault,
+		Name:              map[string]string{"en": "test project 1"},
+		SecretKey:         "test project 1 secret key",
+		Status:            pkg.ProjectStatusInProduction,
+		UrlProcessPayment: suite.server.URL + "/process",
+		UrlRefundPayment:  suite.server.URL + "/refund",
+	}
+
+	if err = suite.service.project.Insert(context.TODO(), suite.project); err != nil {
+		suite.FailNow("Insert project test data failed", "%v", err)
+	}
+}
+
+func (suite *WebhookTestSuite) TearDownTest() {
+	suite.server.Close()
+
+	if err := suite.service.db.Drop(); err != nil {
+		suite.FailNow("Database deletion failed", "%v", err)
+	}
+
+	if err := suite.service.db.Close(); err != nil {
+		suite.FailNow("Database close failed", "%v", err)
+	}
+}
+
+func (suite *WebhookTestSuite) getOrder(privateStatus int32) *billing.Order {
+	return &billing.Order{
+		Id:   primitive.NewObjectID().Hex(),
+		Uuid: uuid.New().String(),
+		Project: &billing.ProjectOrder{
+			Id:               suite.project.Id,
+			MerchantId:       suite.project.MerchantId,
+			CallbackProtocol: suite.project.CallbackProtocol,
+		},
+		PrivateStatus: privateStatus,
+		CreatedAt:     ptypes.TimestampNow(),
+	}
+}
+
+func (suite *WebhookTestSuite) addOrderWebhookEvent(order *billing.Order) (*billing.WebhookEvent, error) {
+	event, err := suite.service.addOrderWebhookEvent(order)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return event, suite.service.moveOrderWebhookEvents(context.TODO(), order)
+}
+
+func (suite *WebhookTestSuite) getEvent(id string) *billing.WebhookEvent {
+	oid, _ := primitive.ObjectIDFromHex(id)
+	events, err := suite.service.webhookEvent.Find(context.TODO(), bson.M{"_id": oid}, 0, 1)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), events, 1)
+
+	return events[0]
+}
+
+func (suite *WebhookTestSuite) TestWebhook_ProcessWebhookEvents_Ok() {
+	event, err := suite.addOrderWebhookEvent(suite.getOrder(constant.OrderStatusProjectComplete))
+	assert.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), event)
+	assert.Equal(suite.T(), constant.OrderPublicStatusProcessed, event.Type)
+	assert.Equal(suite.T(), pkg.WebhookEventStatusPending, event.Status)
+
+	count, err := suite.service.ProcessWebhookEvents(context.TODO())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, count)
+
+	assert.Len(suite.T(), suite.requests, 1)
+	assert.Equal(suite.T(), "/process", suite.requests[0].URL.Path)
+	assert.Equal(suite.T(), event.Id, suite.requests[0].Header.Get(pkg.WebhookHeaderEventId))
+	assert.Equal(
+		suite.T(),
+		getWebhookSignature(suite.bodies[0], suite.project.SecretKey),
+		suite.requests[0].Header.Get(pkg.WebhookHeaderSignature),
+	)
+	assert.Equal(suite.T(), event.Payload, suite.bodies[0])
+
+	event = suite.getEvent(event.Id)
+	assert.Equal(suite.T(), pkg.WebhookEventStatusDelivered, event.Status)
+	assert.EqualValues(suite.T(), 1, event.Attempts)
+	assert.EqualValues(suite.T(), http.StatusOK, event.LastHttpStatus)
+	assert.NotNil(suite.T(), event.DeliveredAt)
+
+	count, err = suite.service.ProcessWebhookEvents(context.TODO())
+	assert.NoError(suite.T(), err)
+	assert.Zero(suite.T(), count)
+}
+
+func (suite *WebhookTestSuite) TestWebhook_ProcessWebhookEvents_RefundUrl_Ok() {
+	_, err := suite.addOrderWebhookEvent(suite.getOrder(constant.OrderStatusRefund))
+	assert.NoError(suite.T(), err)
+
+	count, err := suite.service.ProcessWebhookEvents(context.TODO())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, count)
+	assert.Len(suite.T(), suite.requests, 1)
+	assert.Equal(suite.T(), "/refund", suite.requests[0].URL.Path)
+}
+
+func (suite *WebhookTestSuite) TestWebhook_ProcessWebhookEvents_ReceiverError_Retry() {
+	suite.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	event, err := suite.addOrderWebhookEvent(suite.getOrder(constant.OrderStatusProjectComplete))
+	assert.NoError(suite.T(), err)
+
+	count, err := suite.service.ProcessWebhookEvents(context.TODO())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, count)
+
+	event = suite.getEvent(event.Id)
+	assert.Equal(suite.T(), pkg.WebhookEventStatusFailed, event.Status)
+	assert.EqualValues(suite.T(), 1, event.Attempts)
+	assert.EqualValues(suite.T(), http.StatusInternalServerError, event.LastHttpStatus)
+	assert.NotEmpty(suite.T(), event.LastError)
+
+	nextAttemptAt, err := ptypes.Timestamp(event.NextAttemptAt)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), nextAttemptAt.After(time.Now().Add(suite.service.cfg.GetWebhookRetryDelay()-time.Minute)))
+
+	count, err = suite.service.ProcessWebhookEvents(context.TODO())
+	assert.NoError(suite.T(), err)
+	assert.Zero(suite.T(), count)
+}
+
+func (suite *WebhookTestSuite) TestWebhook_ProcessWebhookEvents_MaxAttempts_Dead() {
+	suite.service.cfg.WebhookMaxAttempts = 1
+	suite.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}
+
+	event, err := suite.addOrderWebhookEvent(suite.getOrder(constant.OrderStatusProjectComplete))
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.service.ProcessWebhookEvents(context.TODO())
+	assert.NoError(suite.T(), err)
+
+	event = suite.getEvent(event.Id)
+	assert.Equal(suite.T(), pkg.WebhookEventStatusDead, event.Status)
+	assert.Nil(suite.T(), event.NextAttemptAt)
+}
+
+func (suite *WebhookTestSuite) TestWebhook_getWebhookRetryDelay_Ok() {
+	suite.service.cfg.WebhookRetryDelay = 30
+
+	assert.Equal(suite.T(), 30*time.Second, suite.service.getWebhookRetryDelay(1))
+	assert.Equal(suite.T(), 60*time.Second, suite.service.getWebhookRetryDelay(2))
+	assert.Equal(suite.T(), 240*time.Second, suite.service.getWebhookRetryDelay(4))
+	assert.Equal(suite.T(), webhookMaxRetryDelay, suite.service.getWebhookRetryDelay(30))
+}
+
+func (suite *WebhookTestSuite) TestWebhook_addOrderWebhookEvent_CallbackProtocolEmpty_Ok() {
+	order := suite.getOrder(constant.OrderStatusProjectComplete)
+	order.Project.CallbackProtocol = pkg.ProjectCallbackProtocolEmpty
+
+	event, err := suite.service.addOrderWebhookEvent(order)
+	assert.NoError(suite.T(), err)
+	assert.Nil(suite.T(), event)
+	assert.Empty(suite.T(), order.PendingWebhookEvents)
+}
+
+func (suite *WebhookTestSuite) TestWebhook_ListFailedWebhooks_Ok() {
+	suite.service.cfg.WebhookMaxAttempts = 2
+	suite.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	order := suite.getOrder(constant.OrderStatusProjectComplete)
+	_, err := suite.addOrderWebhookEvent(order)
+	assert.NoError(suite.T(), err)
+	_, err = suite.addOrderWebhookEvent(suite.getOrder(constant.OrderStatusProjectComplete))
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.service.ProcessWebhookEvents(context.TODO())
+	assert.NoError(suite.T(), err)
+
+	req := &grpc.ListFailedWebhooksRequest{MerchantId: suite.project.MerchantId}
+	rsp := &grpc.ListFailedWebhooksResponse{}
+	err = suite.service.ListFailedWebhooks(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.EqualValues(suite.T(), 2, rsp.Item.Count)
+	assert.Len(suite.T(), rsp.Item.Items, 2)
+
+	req.OrderId = order.Uuid
+	err = suite.service.ListFailedWebhooks(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, rsp.Item.Count)
+	assert.Equal(suite.T(), order.Uuid, rsp.Item.Items[0].OrderUuid)
+
+	req.OrderId = ""
+	req.Status = pkg.WebhookEventStatusDead
+	err = suite.service.ListFailedWebhooks(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Zero(suite.T(), rsp.Item.Count)
+	assert.Empty(suite.T(), rsp.Item.Items)
+}
+
+func (suite *WebhookTestSuite) TestWebhook_RedeliverWebhook_Ok() {
+	suite.service.cfg.WebhookMaxAttempts = 1
+	suite.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	event, err := suite.addOrderWebhookEvent(suite.getOrder(constant.OrderStatusProjectComplete))
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.service.ProcessWebhookEvents(context.TODO())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.WebhookEventStatusDead, suite.getEvent(event.Id).Status)
+
+	suite.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	req := &grpc.RedeliverWebhookRequest{Id: event.Id, MerchantId: suite.project.MerchantId}
+	rsp := &grpc.RedeliverWebhookResponse{}
+	err = suite.service.RedeliverWebhook(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusOk, rsp.Status)
+	assert.Equal(suite.T(), pkg.WebhookEventStatusDelivered, rsp.Item.Status)
+	assert.EqualValues(suite.T(), 1, rsp.Item.Attempts)
+	assert.Len(suite.T(), suite.requests, 2)
+	assert.Equal(suite.T(), pkg.WebhookEventStatusDelivered, suite.getEvent(event.Id).Status)
+}
+
+func (suite *WebhookTestSuite) TestWebhook_RedeliverWebhook_NotFound_Error() {
+	event, err := suite.addOrderWebhookEvent(suite.getOrder(constant.OrderStatusProjectComplete))
+	assert.NoError(suite.T(), err)
+
+	req := &grpc.RedeliverWebhookRequest{Id: event.Id, MerchantId: primitive.NewObjectID().Hex()}
+	rsp := &grpc.RedeliverWebhookResponse{}
+	err = suite.service.RedeliverWebhook(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusNotFound, rsp.Status)
+	assert.Equal(suite.T(), webhookErrorNotFound, rsp.Message)
+}
+
+func (suite *WebhookTestSuite) TestWebhook_RedeliverWebhook_StatusPending_Error() {
+	event, err := suite.addOrderWebhookEvent(suite.getOrder(constant.OrderStatusProjectComplete))
+	assert.NoError(suite.T(), err)
+
+	req := &grpc.RedeliverWebhookRequest{Id: event.Id, MerchantId: suite.project.MerchantId}
+	rsp := &grpc.RedeliverWebhookResponse{}
+	err = suite.service.RedeliverWebhook(context.TODO(), req, rsp)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), pkg.ResponseStatusBadData, rsp.Status)
+	assert.Equal(suite.T(), webhookErrorRedeliveryNotAllow, rsp.Message)
+	assert.Empty(suite.T(), suite.requests)
+}
+
+func (suite *WebhookTestSuite) TestWebhook_moveOrderWebhookEvents_Ok() {
+	order := suite.getOrder(constant.OrderStatusProjectComplete)
+	event, err := suite.service.addOrderWebhookEvent(order)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), order.PendingWebhookEvents, 1)
+
+	_, err = suite.service.db.Collection(collectionOrder).InsertOne(context.TODO(), order)
+	assert.NoError(suite.T(), err)
+
+	err = suite.service.webhookEvent.Insert(context.TODO(), event)
+	assert.NoError(suite.T(), err)
+
+	err = suite.service.moveOrderWebhookEvents(context.TODO(), order)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), order.PendingWebhookEvents)
+
+	count, err := suite.service.webhookEvent.Count(context.TODO(), bson.M{"order_uuid": order.Uuid})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, count)
+
+	order, err = suite.service.getOrderById(context.TODO(), order.Id)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), order.PendingWebhookEvents)
+}
+
+func (suite *WebhookTestSuite) TestWebhook_ProcessWebhookEvents_OrderPendingEvent_Ok() {
+	order := suite.getOrder(constant.OrderStatusProjectComplete)
+	event, err := suite.service.addOrderWebhookEvent(order)
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.service.db.Collection(collectionOrder).InsertOne(context.TODO(), order)
+	assert.NoError(suite.T(), err)
+
+	count, err := suite.service.ProcessWebhookEvents(context.TODO())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, count)
+	assert.Len(suite.T(), suite.requests, 1)
+	assert.Equal(suite.T(), event.Id, suite.requests[0].Header.Get(pkg.WebhookHeaderEventId))
+	assert.Equal(suite.T(), pkg.WebhookEventStatusDelivered, suite.getEvent(event.Id).Status)
+
+	order, err = suite.service.getOrderById(context.TODO(), order.Id)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), order.PendingWebhookEvents)
+}
@@ -29,14 +29,49 @@ func main() {
 		case "royalty_reports_accept":
 			err = app.TaskAutoAcceptRoyaltyReports()
 
+		case "royalty_reports_dispute_escalate":
+			err = app.TaskAutoEscalateRoyaltyReportDisputes()
+
 		case "create_payouts":
 			err = app.TaskAutoCreatePayouts()
 
 		case "rebuild_order_view":
 			err = app.TaskRebuildOrderView()
 
+		case "ledger_backfill":
+			err = app.TaskBackfillLedger()
+
 		case "merchants_migrate":
 			err = app.TaskMerchantsMigrate()
+
+		case "void_expired_payment_authorizations":
+			err = app.TaskVoidExpiredPaymentAuthorizations()
+
+		case "expire_orders":
+			err = app.TaskExpireOrders()
+
+		case "reconcile_payment_statuses":
+			err = app.TaskReconcilePaymentStatuses()
+
+		case "import_settlement_file":
+			err = app.TaskImportSettlementFile(
+				app.CliArgs.Get("file").String(""),
+				app.CliArgs.Get("payment_system").String(""),
+				date,
+			)
+
+		case "process_subscriptions":
+			err = app.TaskProcessSubscriptions()
+
+		case "export_accounting_entries":
+			err = app.TaskExportAccountingEntries(
+				app.CliArgs.Get("file").String(""),
+				app.CliArgs.Get("format").String(""),
+				app.CliArgs.Get("operating_company").String(""),
+				app.CliArgs.Get("merchant").String(""),
+				date,
+				app.CliArgs.Get("date_to").String(""),
+			)
 		}
 
 		if err != nil {
@@ -51,6 +86,9 @@ func main() {
 	}
 
 	app.KeyDaemonStart()
+	app.WebhookDaemonStart()
+	app.BulkRefundDaemonStart()
+	app.GiftDaemonStart()
 
 	app.Run()
 }
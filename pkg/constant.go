@@ -20,6 +20,10 @@ const (
 	CardPayPaymentResponseStatusAuthorized = "AUTHORIZED"
 	CardPayPaymentResponseStatusCompleted  = "COMPLETED"
 	CardPayPaymentResponseStatusCancelled  = "CANCELLED"
+	CardPayPaymentResponseStatusVoided     = "VOIDED"
+
+	CardPayPaymentStatusToComplete = "COMPLETE"
+	CardPayPaymentStatusToReverse  = "REVERSE"
 
 	PaymentCreateFieldOrderId         = "order_id"
 	PaymentCreateFieldPaymentMethodId = "payment_method_id"
@@ -69,6 +73,7 @@ const (
 	ResponseStatusBadData     = int32(400)
 	ResponseStatusNotFound    = int32(404)
 	ResponseStatusForbidden   = int32(403)
+	ResponseStatusConflict    = int32(409)
 	ResponseStatusGone        = int32(410)
 	ResponseStatusSystemError = int32(500)
 	ResponseStatusTemporary   = int32(410)
@@ -79,11 +84,111 @@ const (
 	RefundStatusCompleted             = int32(3)
 	RefundStatusPaymentSystemDeclined = int32(4)
 	RefundStatusPaymentSystemCanceled = int32(5)
+	RefundStatusPendingApproval       = int32(6)
+
+	RefundApprovalStatusPending  = "pending"
+	RefundApprovalStatusApproved = "approved"
+	RefundApprovalStatusRejected = "rejected"
+
+	OrderStatusPaymentSystemAuthorized = int32(30)
 
 	PaymentSystemErrorCreateRefundFailed   = "refund can't be create. try request later"
 	PaymentSystemErrorCreateRefundRejected = "refund create request rejected"
 
-	PaymentSystemHandlerCardPay = "cardpay"
+	PaymentSystemHandlerCardPay     = "cardpay"
+	PaymentSystemHandlerStoreCredit = "storecredit"
+
+	OrderPaymentRouteStatusOk     = "ok"
+	OrderPaymentRouteStatusFailed = "failed"
+
+	PaymentStatusReconciliationResultChanged  = "changed"
+	PaymentStatusReconciliationResultNotFound = "not_found"
+	PaymentStatusReconciliationResultError    = "error"
+
+	WebhookEventStatusPending   = "pending"
+	WebhookEventStatusFailed    = "failed"
+	WebhookEventStatusDelivered = "delivered"
+	WebhookEventStatusDead      = "dead"
+
+	WebhookEventTypeDisputeInquiry       = "dispute.inquiry"
+	WebhookEventTypeDisputeChargeback    = "dispute.chargeback"
+	WebhookEventTypeDisputeRepresentment = "dispute.representment"
+	WebhookEventTypeDisputeWon           = "dispute.won"
+	WebhookEventTypeDisputeLost          = "dispute.lost"
+
+	WebhookHeaderEventId   = "X-PaySuper-Event-Id"
+	WebhookHeaderSignature = "X-PaySuper-Signature"
+
+	RiskRuleTypeVelocityCustomer   = "velocity_customer"
+	RiskRuleTypeVelocityCard       = "velocity_card"
+	RiskRuleTypeVelocityIp         = "velocity_ip"
+	RiskRuleTypeBinCountryMismatch = "bin_country_mismatch"
+	RiskRuleTypeAmountOutlier      = "amount_outlier"
+	RiskRuleTypeDenyEmail          = "deny_email"
+	RiskRuleTypeDenyIp             = "deny_ip"
+	RiskRuleTypeDenyBin            = "deny_bin"
+
+	RiskOutcomeAllow  = "allow"
+	RiskOutcomeReview = "review"
+	RiskOutcomeDeny   = "deny"
+
+	RiskReviewStatusPending  = "pending"
+	RiskReviewStatusApproved = "approved"
+	RiskReviewStatusRejected = "rejected"
+
+	SubscriptionStatusTrialing = "trialing"
+	SubscriptionStatusActive   = "active"
+	SubscriptionStatusPastDue  = "past_due"
+	SubscriptionStatusCanceled = "canceled"
+
+	SubscriptionIntervalDay   = "day"
+	SubscriptionIntervalWeek  = "week"
+	SubscriptionIntervalMonth = "month"
+	SubscriptionIntervalYear  = "year"
+
+	CouponDiscountTypePercent = "percent"
+	CouponDiscountTypeFixed   = "fixed"
+
+	StoreCreditTransactionTypeIssue         = "issue"
+	StoreCreditTransactionTypePayment       = "payment"
+	StoreCreditTransactionTypePaymentCancel = "payment_cancel"
+	StoreCreditTransactionTypeRefund        = "refund"
+
+	DisputeStatusInquiry       = "inquiry"
+	DisputeStatusChargeback    = "chargeback"
+	DisputeStatusRepresentment = "representment"
+	DisputeStatusWon           = "won"
+	DisputeStatusLost          = "lost"
+
+	DisputeEvidenceTypeReceipt       = "receipt"
+	DisputeEvidenceTypeKeyActivation = "key_activation"
+	DisputeEvidenceTypeIpHistory     = "ip_history"
+	DisputeEvidenceTypeDocument      = "document"
+
+	BulkRefundJobStatusPending    = "pending"
+	BulkRefundJobStatusProcessing = "processing"
+	BulkRefundJobStatusCompleted  = "completed"
+
+	BulkRefundJobItemStatusPending = "pending"
+	BulkRefundJobItemStatusCreated = "created"
+	BulkRefundJobItemStatusFailed  = "failed"
+
+	KeyRejectReasonEmpty           = "empty"
+	KeyRejectReasonInvalidFormat   = "invalid_format"
+	KeyRejectReasonDuplicateInFile = "duplicate_in_file"
+	KeyRejectReasonAlreadyExists   = "already_exists"
+	KeyRejectReasonInsertFailed    = "insert_failed"
+
+	SettlementFileTypeCsv  = "csv"
+	SettlementFileTypeJson = "json"
+
+	SettlementTransactionTypePayment = "payment"
+	SettlementTransactionTypeRefund  = "refund"
+
+	SettlementReportItemStatusMissing        = "missing"
+	SettlementReportItemStatusExtra          = "extra"
+	SettlementReportItemStatusAmountMismatch = "amount_mismatch"
+	SettlementReportItemStatusFeeMismatch    = "fee_mismatch"
 
 	MerchantAgreementTypeESign = 2
 
@@ -166,6 +271,29 @@ const (
 	AccountingEntryTypeMerchantRollingReserveCreate    = "merchant_rolling_reserve_create"
 	AccountingEntryTypeMerchantRollingReserveRelease   = "merchant_rolling_reserve_release"
 	AccountingEntryTypeMerchantRoyaltyCorrection       = "merchant_royalty_correction"
+	AccountingEntryTypeMerchantStoreCreditIssue        = "merchant_store_credit_issue"
+	AccountingEntryTypeMerchantStoreCreditRedemption   = "merchant_store_credit_redemption"
+	AccountingEntryTypeMerchantStoreCreditRefund       = "merchant_store_credit_refund"
+
+	LedgerAccountTypeAsset      = "asset"
+	LedgerAccountTypeLiability  = "liability"
+	LedgerAccountTypeIncome     = "income"
+	LedgerAccountTypeExpense    = "expense"
+	LedgerAccountTypeMemorandum = "memorandum"
+
+	LedgerAccountPaymentSystemReceivable = "payment_system_receivable"
+	LedgerAccountSalesClearing           = "sales_clearing"
+	LedgerAccountMerchantPayable         = "merchant_payable"
+	LedgerAccountRollingReserve          = "rolling_reserve"
+	LedgerAccountTaxPayable              = "tax_payable"
+	LedgerAccountStoreCreditLiability    = "store_credit_liability"
+	LedgerAccountFeeRevenue              = "fee_revenue"
+	LedgerAccountFxRevenue               = "fx_revenue"
+	LedgerAccountPaymentSystemExpense    = "payment_system_expense"
+	LedgerAccountMerchantAdjustment      = "merchant_adjustment"
+	LedgerAccountStoreCreditFunding      = "store_credit_funding"
+	LedgerAccountMemorandum              = "memorandum"
+	LedgerAccountMemorandumContra        = "memorandum_contra"
 
 	BalanceTransactionStatusAvailable = "available"
 
@@ -187,6 +315,7 @@ const (
 	ErrorDatabaseFieldOperationInsert = "insert"
 	ErrorDatabaseFieldOperationUpdate = "update"
 	ErrorDatabaseFieldOperationUpsert = "upsert"
+	ErrorDatabaseFieldOperationDelete = "delete"
 	ErrorDatabaseFieldDocument        = "document"
 
 	ErrorJsonMarshallingFailed = "json marshalling failed"
@@ -264,6 +393,14 @@ const (
 	RoyaltyReportChangeSourceMerchant = "merchant"
 	RoyaltyReportChangeSourceAdmin    = "admin"
 
+	RoyaltyReportDisputeStatusWaitingAdmin    = "waiting_admin"
+	RoyaltyReportDisputeStatusWaitingMerchant = "waiting_merchant"
+	RoyaltyReportDisputeStatusClosed          = "closed"
+
+	RoyaltyReportPeriodWeekly   = "weekly"
+	RoyaltyReportPeriodBiWeekly = "bi-weekly"
+	RoyaltyReportPeriodMonthly  = "monthly"
+
 	VatCurrencyRatesPolicyOnDay    = "on-day"
 	VatCurrencyRatesPolicyLastDay  = "last-day"
 	VatCurrencyRatesPolicyAvgMonth = "avg-month"
@@ -301,7 +438,8 @@ const (
 	PayoutDocumentStatusCanceled = "canceled"
 	PayoutDocumentStatusFailed   = "failed"
 
-	OrderIssuerReferenceTypePaylink = "paylink"
+	OrderIssuerReferenceTypePaylink      = "paylink"
+	OrderIssuerReferenceTypeSubscription = "subscription"
 
 	PaylinkUrlDefaultMask = "/paylink/%s"
 
@@ -315,6 +453,9 @@ const (
 	PaymentSystemActionCreatePayment    = "create_payment"
 	PaymentSystemActionRecurringPayment = "recurring_payment"
 	PaymentSystemActionRefund           = "refund"
+	PaymentSystemActionChangeStatus     = "change_status"
+	PaymentSystemActionPaymentStatus    = "payment_status"
+	PaymentSystemActionPaymentList      = "payment_list"
 
 	MccCodeLowRisk  = "5816"
 	MccCodeHighRisk = "5967"
@@ -447,5 +588,17 @@ var (
 			Path:   "/api/refunds",
 			Method: http.MethodPost,
 		},
+		PaymentSystemActionChangeStatus: {
+			Path:   "/api/payments/%s",
+			Method: http.MethodPut,
+		},
+		PaymentSystemActionPaymentStatus: {
+			Path:   "/api/payments/%s",
+			Method: http.MethodGet,
+		},
+		PaymentSystemActionPaymentList: {
+			Path:   "/api/payments",
+			Method: http.MethodGet,
+		},
 	}
 )
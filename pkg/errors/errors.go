@@ -21,4 +21,5 @@ var (
 	KeyErrorCanceled       = newBillingServerErrorMsg("ks000004", "unable to cancel key")
 	KeyErrorFinish         = newBillingServerErrorMsg("ks000005", "unable to finish key")
 	KeyErrorReserve        = newBillingServerErrorMsg("ks000006", "unable to reserve key")
+	KeyErrorDuplicates     = newBillingServerErrorMsg("ks000007", "unable to check duplicates of keys")
 )
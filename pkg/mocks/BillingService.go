@@ -50,6 +50,36 @@ func (_m *BillingService) AcceptInvite(ctx context.Context, in *grpc.AcceptInvit
 	return r0, r1
 }
 
+// AddDisputeEvidence provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) AddDisputeEvidence(ctx context.Context, in *grpc.AddDisputeEvidenceRequest, opts ...client.CallOption) (*grpc.DisputeResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.DisputeResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.AddDisputeEvidenceRequest, ...client.CallOption) *grpc.DisputeResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.DisputeResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.AddDisputeEvidenceRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // AddOperatingCompany provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) AddOperatingCompany(ctx context.Context, in *billing.OperatingCompany, opts ...client.CallOption) (*grpc.EmptyResponseWithStatus, error) {
 	_va := make([]interface{}, len(opts))
@@ -80,6 +110,126 @@ func (_m *BillingService) AddOperatingCompany(ctx context.Context, in *billing.O
 	return r0, r1
 }
 
+// AddRoyaltyReportDisputeMessage provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) AddRoyaltyReportDisputeMessage(ctx context.Context, in *grpc.AddRoyaltyReportDisputeMessageRequest, opts ...client.CallOption) (*grpc.GetRoyaltyReportResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.GetRoyaltyReportResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.AddRoyaltyReportDisputeMessageRequest, ...client.CallOption) *grpc.GetRoyaltyReportResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.GetRoyaltyReportResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.AddRoyaltyReportDisputeMessageRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ApplyOrderCoupon provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) ApplyOrderCoupon(ctx context.Context, in *grpc.ApplyOrderCouponRequest, opts ...client.CallOption) (*grpc.ApplyOrderCouponResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.ApplyOrderCouponResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.ApplyOrderCouponRequest, ...client.CallOption) *grpc.ApplyOrderCouponResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.ApplyOrderCouponResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.ApplyOrderCouponRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ApplyRoyaltyReportDisputeCorrections provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) ApplyRoyaltyReportDisputeCorrections(ctx context.Context, in *grpc.ApplyRoyaltyReportDisputeCorrectionsRequest, opts ...client.CallOption) (*grpc.GetRoyaltyReportResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.GetRoyaltyReportResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.ApplyRoyaltyReportDisputeCorrectionsRequest, ...client.CallOption) *grpc.GetRoyaltyReportResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.GetRoyaltyReportResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.ApplyRoyaltyReportDisputeCorrectionsRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ApproveRefund provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) ApproveRefund(ctx context.Context, in *grpc.RefundDecisionRequest, opts ...client.CallOption) (*grpc.CreateRefundResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.CreateRefundResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.RefundDecisionRequest, ...client.CallOption) *grpc.CreateRefundResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.CreateRefundResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.RefundDecisionRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // AutoAcceptRoyaltyReports provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) AutoAcceptRoyaltyReports(ctx context.Context, in *grpc.EmptyRequest, opts ...client.CallOption) (*grpc.EmptyResponse, error) {
 	_va := make([]interface{}, len(opts))
@@ -140,6 +290,36 @@ func (_m *BillingService) AutoCreatePayoutDocuments(ctx context.Context, in *grp
 	return r0, r1
 }
 
+// AutoEscalateRoyaltyReportDisputes provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) AutoEscalateRoyaltyReportDisputes(ctx context.Context, in *grpc.EmptyRequest, opts ...client.CallOption) (*grpc.EmptyResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.EmptyResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.EmptyRequest, ...client.CallOption) *grpc.EmptyResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.EmptyResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.EmptyRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CalcAnnualTurnovers provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) CalcAnnualTurnovers(ctx context.Context, in *grpc.EmptyRequest, opts ...client.CallOption) (*grpc.EmptyResponse, error) {
 	_va := make([]interface{}, len(opts))
@@ -200,6 +380,66 @@ func (_m *BillingService) CancelRedeemKeyForOrder(ctx context.Context, in *grpc.
 	return r0, r1
 }
 
+// CancelSubscription provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) CancelSubscription(ctx context.Context, in *grpc.CancelSubscriptionRequest, opts ...client.CallOption) (*grpc.SubscriptionResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.SubscriptionResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.CancelSubscriptionRequest, ...client.CallOption) *grpc.SubscriptionResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.SubscriptionResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.CancelSubscriptionRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CapturePayment provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) CapturePayment(ctx context.Context, in *grpc.CapturePaymentRequest, opts ...client.CallOption) (*grpc.CapturePaymentResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.CapturePaymentResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.CapturePaymentRequest, ...client.CallOption) *grpc.CapturePaymentResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.CapturePaymentResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.CapturePaymentRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ChangeCodeInOrder provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) ChangeCodeInOrder(ctx context.Context, in *grpc.ChangeCodeInOrderRequest, opts ...client.CallOption) (*grpc.ChangeCodeInOrderResponse, error) {
 	_va := make([]interface{}, len(opts))
@@ -230,6 +470,36 @@ func (_m *BillingService) ChangeCodeInOrder(ctx context.Context, in *grpc.Change
 	return r0, r1
 }
 
+// ChangeDisputeStatus provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) ChangeDisputeStatus(ctx context.Context, in *grpc.ChangeDisputeStatusRequest, opts ...client.CallOption) (*grpc.DisputeResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.DisputeResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.ChangeDisputeStatusRequest, ...client.CallOption) *grpc.DisputeResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.DisputeResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.ChangeDisputeStatusRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ChangeMerchant provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) ChangeMerchant(ctx context.Context, in *grpc.OnboardingRequest, opts ...client.CallOption) (*grpc.ChangeMerchantResponse, error) {
 	_va := make([]interface{}, len(opts))
@@ -350,6 +620,36 @@ func (_m *BillingService) ChangeMerchantPaymentMethod(ctx context.Context, in *g
 	return r0, r1
 }
 
+// ChangeMerchantRoyaltyReportPeriod provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) ChangeMerchantRoyaltyReportPeriod(ctx context.Context, in *grpc.ChangeMerchantRoyaltyReportPeriodRequest, opts ...client.CallOption) (*grpc.ChangeMerchantManualPayoutsResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.ChangeMerchantManualPayoutsResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.ChangeMerchantRoyaltyReportPeriodRequest, ...client.CallOption) *grpc.ChangeMerchantManualPayoutsResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.ChangeMerchantManualPayoutsResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.ChangeMerchantRoyaltyReportPeriodRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ChangeMerchantStatus provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) ChangeMerchantStatus(ctx context.Context, in *grpc.MerchantChangeStatusRequest, opts ...client.CallOption) (*grpc.ChangeMerchantStatusResponse, error) {
 	_va := make([]interface{}, len(opts))
@@ -500,6 +800,36 @@ func (_m *BillingService) ChangeRoyaltyReport(ctx context.Context, in *grpc.Chan
 	return r0, r1
 }
 
+// ChangeSubscriptionPlan provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) ChangeSubscriptionPlan(ctx context.Context, in *grpc.ChangeSubscriptionPlanRequest, opts ...client.CallOption) (*grpc.SubscriptionResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.SubscriptionResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.ChangeSubscriptionPlanRequest, ...client.CallOption) *grpc.SubscriptionResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.SubscriptionResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.ChangeSubscriptionPlanRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CheckInviteToken provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) CheckInviteToken(ctx context.Context, in *grpc.CheckInviteTokenRequest, opts ...client.CallOption) (*grpc.CheckInviteTokenResponse, error) {
 	_va := make([]interface{}, len(opts))
@@ -530,6 +860,36 @@ func (_m *BillingService) CheckInviteToken(ctx context.Context, in *grpc.CheckIn
 	return r0, r1
 }
 
+// CheckLedgerBalance provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) CheckLedgerBalance(ctx context.Context, in *grpc.CheckLedgerBalanceRequest, opts ...client.CallOption) (*grpc.CheckLedgerBalanceResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.CheckLedgerBalanceResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.CheckLedgerBalanceRequest, ...client.CallOption) *grpc.CheckLedgerBalanceResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.CheckLedgerBalanceResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.CheckLedgerBalanceRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CheckProjectRequestSignature provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) CheckProjectRequestSignature(ctx context.Context, in *grpc.CheckProjectRequestSignatureRequest, opts ...client.CallOption) (*grpc.CheckProjectRequestSignatureResponse, error) {
 	_va := make([]interface{}, len(opts))
@@ -650,6 +1010,36 @@ func (_m *BillingService) CreateAccountingEntry(ctx context.Context, in *grpc.Cr
 	return r0, r1
 }
 
+// CreateBulkRefund provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) CreateBulkRefund(ctx context.Context, in *grpc.CreateBulkRefundRequest, opts ...client.CallOption) (*grpc.BulkRefundJobResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.BulkRefundJobResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.CreateBulkRefundRequest, ...client.CallOption) *grpc.BulkRefundJobResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.BulkRefundJobResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.CreateBulkRefundRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CreateNotification provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) CreateNotification(ctx context.Context, in *grpc.NotificationRequest, opts ...client.CallOption) (*grpc.CreateNotificationResponse, error) {
 	_va := make([]interface{}, len(opts))
@@ -1010,6 +1400,36 @@ func (_m *BillingService) CreateRoyaltyReport(ctx context.Context, in *grpc.Crea
 	return r0, r1
 }
 
+// CreateSubscription provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) CreateSubscription(ctx context.Context, in *grpc.CreateSubscriptionRequest, opts ...client.CallOption) (*grpc.SubscriptionResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.SubscriptionResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.CreateSubscriptionRequest, ...client.CallOption) *grpc.SubscriptionResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.SubscriptionResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.CreateSubscriptionRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CreateToken provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) CreateToken(ctx context.Context, in *grpc.TokenRequest, opts ...client.CallOption) (*grpc.TokenResponse, error) {
 	_va := make([]interface{}, len(opts))
@@ -1340,6 +1760,36 @@ func (_m *BillingService) DeletePaymentMethodTestSettings(ctx context.Context, i
 	return r0, r1
 }
 
+// DeletePaymentRoutingRule provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) DeletePaymentRoutingRule(ctx context.Context, in *grpc.DeletePaymentRoutingRuleRequest, opts ...client.CallOption) (*grpc.EmptyResponseWithStatus, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.EmptyResponseWithStatus
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.DeletePaymentRoutingRuleRequest, ...client.CallOption) *grpc.EmptyResponseWithStatus); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.EmptyResponseWithStatus)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.DeletePaymentRoutingRuleRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // DeleteProduct provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) DeleteProduct(ctx context.Context, in *grpc.RequestProduct, opts ...client.CallOption) (*grpc.EmptyResponse, error) {
 	_va := make([]interface{}, len(opts))
@@ -1400,6 +1850,66 @@ func (_m *BillingService) DeleteProject(ctx context.Context, in *grpc.GetProject
 	return r0, r1
 }
 
+// DeleteRefundApprovalThreshold provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) DeleteRefundApprovalThreshold(ctx context.Context, in *grpc.DeleteRefundApprovalThresholdRequest, opts ...client.CallOption) (*grpc.EmptyResponseWithStatus, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.EmptyResponseWithStatus
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.DeleteRefundApprovalThresholdRequest, ...client.CallOption) *grpc.EmptyResponseWithStatus); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.EmptyResponseWithStatus)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.DeleteRefundApprovalThresholdRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteRiskRule provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) DeleteRiskRule(ctx context.Context, in *grpc.DeleteRiskRuleRequest, opts ...client.CallOption) (*grpc.EmptyResponseWithStatus, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.EmptyResponseWithStatus
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.DeleteRiskRuleRequest, ...client.CallOption) *grpc.EmptyResponseWithStatus); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.EmptyResponseWithStatus)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.DeleteRiskRuleRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // DeleteSavedCard provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) DeleteSavedCard(ctx context.Context, in *grpc.DeleteSavedCardRequest, opts ...client.CallOption) (*grpc.EmptyResponseWithStatus, error) {
 	_va := make([]interface{}, len(opts))
@@ -1430,6 +1940,36 @@ func (_m *BillingService) DeleteSavedCard(ctx context.Context, in *grpc.DeleteSa
 	return r0, r1
 }
 
+// ExportAccountingEntries provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) ExportAccountingEntries(ctx context.Context, in *grpc.ExportAccountingEntriesRequest, opts ...client.CallOption) (*grpc.ExportAccountingEntriesResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.ExportAccountingEntriesResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.ExportAccountingEntriesRequest, ...client.CallOption) *grpc.ExportAccountingEntriesResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.ExportAccountingEntriesResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.ExportAccountingEntriesRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // FindAllOrders provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) FindAllOrders(ctx context.Context, in *grpc.ListOrdersRequest, opts ...client.CallOption) (*grpc.ListOrdersResponse, error) {
 	_va := make([]interface{}, len(opts))
@@ -1790,6 +2330,36 @@ func (_m *BillingService) GetAvailableKeysCount(ctx context.Context, in *grpc.Ge
 	return r0, r1
 }
 
+// GetBulkRefundJob provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) GetBulkRefundJob(ctx context.Context, in *grpc.GetBulkRefundJobRequest, opts ...client.CallOption) (*grpc.BulkRefundJobResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.BulkRefundJobResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.GetBulkRefundJobRequest, ...client.CallOption) *grpc.BulkRefundJobResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.BulkRefundJobResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.GetBulkRefundJobRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetCommonUserProfile provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) GetCommonUserProfile(ctx context.Context, in *grpc.CommonUserProfileRequest, opts ...client.CallOption) (*grpc.CommonUserProfileResponse, error) {
 	_va := make([]interface{}, len(opts))
@@ -1891,17 +2461,47 @@ func (_m *BillingService) GetCountry(ctx context.Context, in *billing.GetCountry
 	_ca = append(_ca, _va...)
 	ret := _m.Called(_ca...)
 
-	var r0 *billing.Country
-	if rf, ok := ret.Get(0).(func(context.Context, *billing.GetCountryRequest, ...client.CallOption) *billing.Country); ok {
+	var r0 *billing.Country
+	if rf, ok := ret.Get(0).(func(context.Context, *billing.GetCountryRequest, ...client.CallOption) *billing.Country); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*billing.Country)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *billing.GetCountryRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCoupons provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) GetCoupons(ctx context.Context, in *grpc.GetCouponsRequest, opts ...client.CallOption) (*grpc.GetCouponsResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.GetCouponsResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.GetCouponsRequest, ...client.CallOption) *grpc.GetCouponsResponse); ok {
 		r0 = rf(ctx, in, opts...)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*billing.Country)
+			r0 = ret.Get(0).(*grpc.GetCouponsResponse)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, *billing.GetCountryRequest, ...client.CallOption) error); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.GetCouponsRequest, ...client.CallOption) error); ok {
 		r1 = rf(ctx, in, opts...)
 	} else {
 		r1 = ret.Error(1)
@@ -2000,6 +2600,36 @@ func (_m *BillingService) GetDashboardRevenueDynamicsReport(ctx context.Context,
 	return r0, r1
 }
 
+// GetDispute provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) GetDispute(ctx context.Context, in *grpc.GetDisputeRequest, opts ...client.CallOption) (*grpc.DisputeResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.DisputeResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.GetDisputeRequest, ...client.CallOption) *grpc.DisputeResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.DisputeResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.GetDisputeRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetKeyByID provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) GetKeyByID(ctx context.Context, in *grpc.KeyForOrderRequest, opts ...client.CallOption) (*grpc.GetKeyForOrderRequestResponse, error) {
 	_va := make([]interface{}, len(opts))
@@ -2150,6 +2780,36 @@ func (_m *BillingService) GetKeyProductsForOrder(ctx context.Context, in *grpc.G
 	return r0, r1
 }
 
+// GetLedgerTrialBalance provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) GetLedgerTrialBalance(ctx context.Context, in *grpc.GetLedgerTrialBalanceRequest, opts ...client.CallOption) (*grpc.GetLedgerTrialBalanceResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.GetLedgerTrialBalanceResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.GetLedgerTrialBalanceRequest, ...client.CallOption) *grpc.GetLedgerTrialBalanceResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.GetLedgerTrialBalanceResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.GetLedgerTrialBalanceRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetMerchantBalance provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) GetMerchantBalance(ctx context.Context, in *grpc.GetMerchantBalanceRequest, opts ...client.CallOption) (*grpc.GetMerchantBalanceResponse, error) {
 	_va := make([]interface{}, len(opts))
@@ -2990,6 +3650,36 @@ func (_m *BillingService) GetPaymentMinLimitsSystem(ctx context.Context, in *grp
 	return r0, r1
 }
 
+// GetPaymentRoutingRules provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) GetPaymentRoutingRules(ctx context.Context, in *grpc.GetPaymentRoutingRulesRequest, opts ...client.CallOption) (*grpc.GetPaymentRoutingRulesResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.GetPaymentRoutingRulesResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.GetPaymentRoutingRulesRequest, ...client.CallOption) *grpc.GetPaymentRoutingRulesResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.GetPaymentRoutingRulesResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.GetPaymentRoutingRulesRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetPayoutDocument provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) GetPayoutDocument(ctx context.Context, in *grpc.GetPayoutDocumentRequest, opts ...client.CallOption) (*grpc.PayoutDocumentResponse, error) {
 	_va := make([]interface{}, len(opts))
@@ -3276,12 +3966,192 @@ func (_m *BillingService) GetProduct(ctx context.Context, in *grpc.RequestProduc
 		r0 = rf(ctx, in, opts...)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*grpc.GetProductResponse)
+			r0 = ret.Get(0).(*grpc.GetProductResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.RequestProduct, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetProductPrices provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) GetProductPrices(ctx context.Context, in *grpc.RequestProduct, opts ...client.CallOption) (*grpc.ProductPricesResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.ProductPricesResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.RequestProduct, ...client.CallOption) *grpc.ProductPricesResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.ProductPricesResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.RequestProduct, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetProductsForOrder provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) GetProductsForOrder(ctx context.Context, in *grpc.GetProductsForOrderRequest, opts ...client.CallOption) (*grpc.ListProductsResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.ListProductsResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.GetProductsForOrderRequest, ...client.CallOption) *grpc.ListProductsResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.ListProductsResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.GetProductsForOrderRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetProject provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) GetProject(ctx context.Context, in *grpc.GetProjectRequest, opts ...client.CallOption) (*grpc.ChangeProjectResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.ChangeProjectResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.GetProjectRequest, ...client.CallOption) *grpc.ChangeProjectResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.ChangeProjectResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.GetProjectRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRecommendedPriceByConversion provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) GetRecommendedPriceByConversion(ctx context.Context, in *grpc.RecommendedPriceRequest, opts ...client.CallOption) (*grpc.RecommendedPriceResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.RecommendedPriceResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.RecommendedPriceRequest, ...client.CallOption) *grpc.RecommendedPriceResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.RecommendedPriceResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.RecommendedPriceRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRecommendedPriceByPriceGroup provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) GetRecommendedPriceByPriceGroup(ctx context.Context, in *grpc.RecommendedPriceRequest, opts ...client.CallOption) (*grpc.RecommendedPriceResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.RecommendedPriceResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.RecommendedPriceRequest, ...client.CallOption) *grpc.RecommendedPriceResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.RecommendedPriceResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.RecommendedPriceRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRecommendedPriceTable provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) GetRecommendedPriceTable(ctx context.Context, in *grpc.RecommendedPriceTableRequest, opts ...client.CallOption) (*grpc.RecommendedPriceTableResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.RecommendedPriceTableResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.RecommendedPriceTableRequest, ...client.CallOption) *grpc.RecommendedPriceTableResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.RecommendedPriceTableResponse)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, *grpc.RequestProduct, ...client.CallOption) error); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.RecommendedPriceTableRequest, ...client.CallOption) error); ok {
 		r1 = rf(ctx, in, opts...)
 	} else {
 		r1 = ret.Error(1)
@@ -3290,8 +4160,8 @@ func (_m *BillingService) GetProduct(ctx context.Context, in *grpc.RequestProduc
 	return r0, r1
 }
 
-// GetProductPrices provides a mock function with given fields: ctx, in, opts
-func (_m *BillingService) GetProductPrices(ctx context.Context, in *grpc.RequestProduct, opts ...client.CallOption) (*grpc.ProductPricesResponse, error) {
+// GetRefund provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) GetRefund(ctx context.Context, in *grpc.GetRefundRequest, opts ...client.CallOption) (*grpc.CreateRefundResponse, error) {
 	_va := make([]interface{}, len(opts))
 	for _i := range opts {
 		_va[_i] = opts[_i]
@@ -3301,17 +4171,17 @@ func (_m *BillingService) GetProductPrices(ctx context.Context, in *grpc.Request
 	_ca = append(_ca, _va...)
 	ret := _m.Called(_ca...)
 
-	var r0 *grpc.ProductPricesResponse
-	if rf, ok := ret.Get(0).(func(context.Context, *grpc.RequestProduct, ...client.CallOption) *grpc.ProductPricesResponse); ok {
+	var r0 *grpc.CreateRefundResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.GetRefundRequest, ...client.CallOption) *grpc.CreateRefundResponse); ok {
 		r0 = rf(ctx, in, opts...)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*grpc.ProductPricesResponse)
+			r0 = ret.Get(0).(*grpc.CreateRefundResponse)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, *grpc.RequestProduct, ...client.CallOption) error); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.GetRefundRequest, ...client.CallOption) error); ok {
 		r1 = rf(ctx, in, opts...)
 	} else {
 		r1 = ret.Error(1)
@@ -3320,8 +4190,8 @@ func (_m *BillingService) GetProductPrices(ctx context.Context, in *grpc.Request
 	return r0, r1
 }
 
-// GetProductsForOrder provides a mock function with given fields: ctx, in, opts
-func (_m *BillingService) GetProductsForOrder(ctx context.Context, in *grpc.GetProductsForOrderRequest, opts ...client.CallOption) (*grpc.ListProductsResponse, error) {
+// GetRefundApprovalThresholds provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) GetRefundApprovalThresholds(ctx context.Context, in *grpc.GetRefundApprovalThresholdsRequest, opts ...client.CallOption) (*grpc.GetRefundApprovalThresholdsResponse, error) {
 	_va := make([]interface{}, len(opts))
 	for _i := range opts {
 		_va[_i] = opts[_i]
@@ -3331,17 +4201,17 @@ func (_m *BillingService) GetProductsForOrder(ctx context.Context, in *grpc.GetP
 	_ca = append(_ca, _va...)
 	ret := _m.Called(_ca...)
 
-	var r0 *grpc.ListProductsResponse
-	if rf, ok := ret.Get(0).(func(context.Context, *grpc.GetProductsForOrderRequest, ...client.CallOption) *grpc.ListProductsResponse); ok {
+	var r0 *grpc.GetRefundApprovalThresholdsResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.GetRefundApprovalThresholdsRequest, ...client.CallOption) *grpc.GetRefundApprovalThresholdsResponse); ok {
 		r0 = rf(ctx, in, opts...)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*grpc.ListProductsResponse)
+			r0 = ret.Get(0).(*grpc.GetRefundApprovalThresholdsResponse)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, *grpc.GetProductsForOrderRequest, ...client.CallOption) error); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.GetRefundApprovalThresholdsRequest, ...client.CallOption) error); ok {
 		r1 = rf(ctx, in, opts...)
 	} else {
 		r1 = ret.Error(1)
@@ -3350,8 +4220,8 @@ func (_m *BillingService) GetProductsForOrder(ctx context.Context, in *grpc.GetP
 	return r0, r1
 }
 
-// GetProject provides a mock function with given fields: ctx, in, opts
-func (_m *BillingService) GetProject(ctx context.Context, in *grpc.GetProjectRequest, opts ...client.CallOption) (*grpc.ChangeProjectResponse, error) {
+// GetRiskRules provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) GetRiskRules(ctx context.Context, in *grpc.GetRiskRulesRequest, opts ...client.CallOption) (*grpc.GetRiskRulesResponse, error) {
 	_va := make([]interface{}, len(opts))
 	for _i := range opts {
 		_va[_i] = opts[_i]
@@ -3361,17 +4231,17 @@ func (_m *BillingService) GetProject(ctx context.Context, in *grpc.GetProjectReq
 	_ca = append(_ca, _va...)
 	ret := _m.Called(_ca...)
 
-	var r0 *grpc.ChangeProjectResponse
-	if rf, ok := ret.Get(0).(func(context.Context, *grpc.GetProjectRequest, ...client.CallOption) *grpc.ChangeProjectResponse); ok {
+	var r0 *grpc.GetRiskRulesResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.GetRiskRulesRequest, ...client.CallOption) *grpc.GetRiskRulesResponse); ok {
 		r0 = rf(ctx, in, opts...)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*grpc.ChangeProjectResponse)
+			r0 = ret.Get(0).(*grpc.GetRiskRulesResponse)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, *grpc.GetProjectRequest, ...client.CallOption) error); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.GetRiskRulesRequest, ...client.CallOption) error); ok {
 		r1 = rf(ctx, in, opts...)
 	} else {
 		r1 = ret.Error(1)
@@ -3380,8 +4250,8 @@ func (_m *BillingService) GetProject(ctx context.Context, in *grpc.GetProjectReq
 	return r0, r1
 }
 
-// GetRecommendedPriceByConversion provides a mock function with given fields: ctx, in, opts
-func (_m *BillingService) GetRecommendedPriceByConversion(ctx context.Context, in *grpc.RecommendedPriceRequest, opts ...client.CallOption) (*grpc.RecommendedPriceResponse, error) {
+// GetRoleList provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) GetRoleList(ctx context.Context, in *grpc.GetRoleListRequest, opts ...client.CallOption) (*grpc.GetRoleListResponse, error) {
 	_va := make([]interface{}, len(opts))
 	for _i := range opts {
 		_va[_i] = opts[_i]
@@ -3391,17 +4261,17 @@ func (_m *BillingService) GetRecommendedPriceByConversion(ctx context.Context, i
 	_ca = append(_ca, _va...)
 	ret := _m.Called(_ca...)
 
-	var r0 *grpc.RecommendedPriceResponse
-	if rf, ok := ret.Get(0).(func(context.Context, *grpc.RecommendedPriceRequest, ...client.CallOption) *grpc.RecommendedPriceResponse); ok {
+	var r0 *grpc.GetRoleListResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.GetRoleListRequest, ...client.CallOption) *grpc.GetRoleListResponse); ok {
 		r0 = rf(ctx, in, opts...)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*grpc.RecommendedPriceResponse)
+			r0 = ret.Get(0).(*grpc.GetRoleListResponse)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, *grpc.RecommendedPriceRequest, ...client.CallOption) error); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.GetRoleListRequest, ...client.CallOption) error); ok {
 		r1 = rf(ctx, in, opts...)
 	} else {
 		r1 = ret.Error(1)
@@ -3410,8 +4280,8 @@ func (_m *BillingService) GetRecommendedPriceByConversion(ctx context.Context, i
 	return r0, r1
 }
 
-// GetRecommendedPriceByPriceGroup provides a mock function with given fields: ctx, in, opts
-func (_m *BillingService) GetRecommendedPriceByPriceGroup(ctx context.Context, in *grpc.RecommendedPriceRequest, opts ...client.CallOption) (*grpc.RecommendedPriceResponse, error) {
+// GetRoyaltyReport provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) GetRoyaltyReport(ctx context.Context, in *grpc.GetRoyaltyReportRequest, opts ...client.CallOption) (*grpc.GetRoyaltyReportResponse, error) {
 	_va := make([]interface{}, len(opts))
 	for _i := range opts {
 		_va[_i] = opts[_i]
@@ -3421,17 +4291,17 @@ func (_m *BillingService) GetRecommendedPriceByPriceGroup(ctx context.Context, i
 	_ca = append(_ca, _va...)
 	ret := _m.Called(_ca...)
 
-	var r0 *grpc.RecommendedPriceResponse
-	if rf, ok := ret.Get(0).(func(context.Context, *grpc.RecommendedPriceRequest, ...client.CallOption) *grpc.RecommendedPriceResponse); ok {
+	var r0 *grpc.GetRoyaltyReportResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.GetRoyaltyReportRequest, ...client.CallOption) *grpc.GetRoyaltyReportResponse); ok {
 		r0 = rf(ctx, in, opts...)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*grpc.RecommendedPriceResponse)
+			r0 = ret.Get(0).(*grpc.GetRoyaltyReportResponse)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, *grpc.RecommendedPriceRequest, ...client.CallOption) error); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.GetRoyaltyReportRequest, ...client.CallOption) error); ok {
 		r1 = rf(ctx, in, opts...)
 	} else {
 		r1 = ret.Error(1)
@@ -3440,8 +4310,8 @@ func (_m *BillingService) GetRecommendedPriceByPriceGroup(ctx context.Context, i
 	return r0, r1
 }
 
-// GetRecommendedPriceTable provides a mock function with given fields: ctx, in, opts
-func (_m *BillingService) GetRecommendedPriceTable(ctx context.Context, in *grpc.RecommendedPriceTableRequest, opts ...client.CallOption) (*grpc.RecommendedPriceTableResponse, error) {
+// GetSettlementReportFile provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) GetSettlementReportFile(ctx context.Context, in *grpc.GetSettlementReportFileRequest, opts ...client.CallOption) (*grpc.GetSettlementReportFileResponse, error) {
 	_va := make([]interface{}, len(opts))
 	for _i := range opts {
 		_va[_i] = opts[_i]
@@ -3451,17 +4321,17 @@ func (_m *BillingService) GetRecommendedPriceTable(ctx context.Context, in *grpc
 	_ca = append(_ca, _va...)
 	ret := _m.Called(_ca...)
 
-	var r0 *grpc.RecommendedPriceTableResponse
-	if rf, ok := ret.Get(0).(func(context.Context, *grpc.RecommendedPriceTableRequest, ...client.CallOption) *grpc.RecommendedPriceTableResponse); ok {
+	var r0 *grpc.GetSettlementReportFileResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.GetSettlementReportFileRequest, ...client.CallOption) *grpc.GetSettlementReportFileResponse); ok {
 		r0 = rf(ctx, in, opts...)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*grpc.RecommendedPriceTableResponse)
+			r0 = ret.Get(0).(*grpc.GetSettlementReportFileResponse)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, *grpc.RecommendedPriceTableRequest, ...client.CallOption) error); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.GetSettlementReportFileRequest, ...client.CallOption) error); ok {
 		r1 = rf(ctx, in, opts...)
 	} else {
 		r1 = ret.Error(1)
@@ -3470,8 +4340,8 @@ func (_m *BillingService) GetRecommendedPriceTable(ctx context.Context, in *grpc
 	return r0, r1
 }
 
-// GetRefund provides a mock function with given fields: ctx, in, opts
-func (_m *BillingService) GetRefund(ctx context.Context, in *grpc.GetRefundRequest, opts ...client.CallOption) (*grpc.CreateRefundResponse, error) {
+// GetStoreCredits provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) GetStoreCredits(ctx context.Context, in *grpc.GetStoreCreditsRequest, opts ...client.CallOption) (*grpc.GetStoreCreditsResponse, error) {
 	_va := make([]interface{}, len(opts))
 	for _i := range opts {
 		_va[_i] = opts[_i]
@@ -3481,17 +4351,17 @@ func (_m *BillingService) GetRefund(ctx context.Context, in *grpc.GetRefundReque
 	_ca = append(_ca, _va...)
 	ret := _m.Called(_ca...)
 
-	var r0 *grpc.CreateRefundResponse
-	if rf, ok := ret.Get(0).(func(context.Context, *grpc.GetRefundRequest, ...client.CallOption) *grpc.CreateRefundResponse); ok {
+	var r0 *grpc.GetStoreCreditsResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.GetStoreCreditsRequest, ...client.CallOption) *grpc.GetStoreCreditsResponse); ok {
 		r0 = rf(ctx, in, opts...)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*grpc.CreateRefundResponse)
+			r0 = ret.Get(0).(*grpc.GetStoreCreditsResponse)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, *grpc.GetRefundRequest, ...client.CallOption) error); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.GetStoreCreditsRequest, ...client.CallOption) error); ok {
 		r1 = rf(ctx, in, opts...)
 	} else {
 		r1 = ret.Error(1)
@@ -3500,8 +4370,8 @@ func (_m *BillingService) GetRefund(ctx context.Context, in *grpc.GetRefundReque
 	return r0, r1
 }
 
-// GetRoleList provides a mock function with given fields: ctx, in, opts
-func (_m *BillingService) GetRoleList(ctx context.Context, in *grpc.GetRoleListRequest, opts ...client.CallOption) (*grpc.GetRoleListResponse, error) {
+// GetSubscription provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) GetSubscription(ctx context.Context, in *grpc.GetSubscriptionRequest, opts ...client.CallOption) (*grpc.SubscriptionResponse, error) {
 	_va := make([]interface{}, len(opts))
 	for _i := range opts {
 		_va[_i] = opts[_i]
@@ -3511,17 +4381,17 @@ func (_m *BillingService) GetRoleList(ctx context.Context, in *grpc.GetRoleListR
 	_ca = append(_ca, _va...)
 	ret := _m.Called(_ca...)
 
-	var r0 *grpc.GetRoleListResponse
-	if rf, ok := ret.Get(0).(func(context.Context, *grpc.GetRoleListRequest, ...client.CallOption) *grpc.GetRoleListResponse); ok {
+	var r0 *grpc.SubscriptionResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.GetSubscriptionRequest, ...client.CallOption) *grpc.SubscriptionResponse); ok {
 		r0 = rf(ctx, in, opts...)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*grpc.GetRoleListResponse)
+			r0 = ret.Get(0).(*grpc.SubscriptionResponse)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, *grpc.GetRoleListRequest, ...client.CallOption) error); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.GetSubscriptionRequest, ...client.CallOption) error); ok {
 		r1 = rf(ctx, in, opts...)
 	} else {
 		r1 = ret.Error(1)
@@ -3530,8 +4400,8 @@ func (_m *BillingService) GetRoleList(ctx context.Context, in *grpc.GetRoleListR
 	return r0, r1
 }
 
-// GetRoyaltyReport provides a mock function with given fields: ctx, in, opts
-func (_m *BillingService) GetRoyaltyReport(ctx context.Context, in *grpc.GetRoyaltyReportRequest, opts ...client.CallOption) (*grpc.GetRoyaltyReportResponse, error) {
+// GetSubscriptionPlans provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) GetSubscriptionPlans(ctx context.Context, in *grpc.GetSubscriptionPlansRequest, opts ...client.CallOption) (*grpc.GetSubscriptionPlansResponse, error) {
 	_va := make([]interface{}, len(opts))
 	for _i := range opts {
 		_va[_i] = opts[_i]
@@ -3541,17 +4411,17 @@ func (_m *BillingService) GetRoyaltyReport(ctx context.Context, in *grpc.GetRoya
 	_ca = append(_ca, _va...)
 	ret := _m.Called(_ca...)
 
-	var r0 *grpc.GetRoyaltyReportResponse
-	if rf, ok := ret.Get(0).(func(context.Context, *grpc.GetRoyaltyReportRequest, ...client.CallOption) *grpc.GetRoyaltyReportResponse); ok {
+	var r0 *grpc.GetSubscriptionPlansResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.GetSubscriptionPlansRequest, ...client.CallOption) *grpc.GetSubscriptionPlansResponse); ok {
 		r0 = rf(ctx, in, opts...)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*grpc.GetRoyaltyReportResponse)
+			r0 = ret.Get(0).(*grpc.GetSubscriptionPlansResponse)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, *grpc.GetRoyaltyReportRequest, ...client.CallOption) error); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.GetSubscriptionPlansRequest, ...client.CallOption) error); ok {
 		r1 = rf(ctx, in, opts...)
 	} else {
 		r1 = ret.Error(1)
@@ -3680,6 +4550,36 @@ func (_m *BillingService) GetVatReportsForCountry(ctx context.Context, in *grpc.
 	return r0, r1
 }
 
+// ImportSettlementFile provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) ImportSettlementFile(ctx context.Context, in *grpc.ImportSettlementFileRequest, opts ...client.CallOption) (*grpc.ImportSettlementFileResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.ImportSettlementFileResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.ImportSettlementFileRequest, ...client.CallOption) *grpc.ImportSettlementFileResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.ImportSettlementFileResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.ImportSettlementFileRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // IncrPaylinkVisits provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) IncrPaylinkVisits(ctx context.Context, in *grpc.PaylinkRequestById, opts ...client.CallOption) (*grpc.EmptyResponse, error) {
 	_va := make([]interface{}, len(opts))
@@ -3800,6 +4700,96 @@ func (_m *BillingService) IsOrderCanBePaying(ctx context.Context, in *grpc.IsOrd
 	return r0, r1
 }
 
+// IssueStoreCredit provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) IssueStoreCredit(ctx context.Context, in *grpc.IssueStoreCreditRequest, opts ...client.CallOption) (*grpc.StoreCreditResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.StoreCreditResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.IssueStoreCreditRequest, ...client.CallOption) *grpc.StoreCreditResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.StoreCreditResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.IssueStoreCreditRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListDisputes provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) ListDisputes(ctx context.Context, in *grpc.ListDisputesRequest, opts ...client.CallOption) (*grpc.ListDisputesResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.ListDisputesResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.ListDisputesRequest, ...client.CallOption) *grpc.ListDisputesResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.ListDisputesResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.ListDisputesRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListFailedWebhooks provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) ListFailedWebhooks(ctx context.Context, in *grpc.ListFailedWebhooksRequest, opts ...client.CallOption) (*grpc.ListFailedWebhooksResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.ListFailedWebhooksResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.ListFailedWebhooksRequest, ...client.CallOption) *grpc.ListFailedWebhooksResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.ListFailedWebhooksResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.ListFailedWebhooksRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ListMerchantPaymentMethods provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) ListMerchantPaymentMethods(ctx context.Context, in *grpc.ListMerchantPaymentMethodsRequest, opts ...client.CallOption) (*grpc.ListingMerchantPaymentMethod, error) {
 	_va := make([]interface{}, len(opts))
@@ -4040,8 +5030,98 @@ func (_m *BillingService) ListRoyaltyReports(ctx context.Context, in *grpc.ListR
 	return r0, r1
 }
 
-// MarkNotificationAsRead provides a mock function with given fields: ctx, in, opts
-func (_m *BillingService) MarkNotificationAsRead(ctx context.Context, in *grpc.GetNotificationRequest, opts ...client.CallOption) (*billing.Notification, error) {
+// ListSettlementReports provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) ListSettlementReports(ctx context.Context, in *grpc.ListSettlementReportsRequest, opts ...client.CallOption) (*grpc.ListSettlementReportsResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.ListSettlementReportsResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.ListSettlementReportsRequest, ...client.CallOption) *grpc.ListSettlementReportsResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.ListSettlementReportsResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.ListSettlementReportsRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MarkNotificationAsRead provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) MarkNotificationAsRead(ctx context.Context, in *grpc.GetNotificationRequest, opts ...client.CallOption) (*billing.Notification, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *billing.Notification
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.GetNotificationRequest, ...client.CallOption) *billing.Notification); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*billing.Notification)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.GetNotificationRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MerchantReviewRoyaltyReport provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) MerchantReviewRoyaltyReport(ctx context.Context, in *grpc.MerchantReviewRoyaltyReportRequest, opts ...client.CallOption) (*grpc.ResponseError, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.ResponseError
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.MerchantReviewRoyaltyReportRequest, ...client.CallOption) *grpc.ResponseError); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.ResponseError)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.MerchantReviewRoyaltyReportRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// OpenDispute provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) OpenDispute(ctx context.Context, in *grpc.OpenDisputeRequest, opts ...client.CallOption) (*grpc.DisputeResponse, error) {
 	_va := make([]interface{}, len(opts))
 	for _i := range opts {
 		_va[_i] = opts[_i]
@@ -4051,17 +5131,17 @@ func (_m *BillingService) MarkNotificationAsRead(ctx context.Context, in *grpc.G
 	_ca = append(_ca, _va...)
 	ret := _m.Called(_ca...)
 
-	var r0 *billing.Notification
-	if rf, ok := ret.Get(0).(func(context.Context, *grpc.GetNotificationRequest, ...client.CallOption) *billing.Notification); ok {
+	var r0 *grpc.DisputeResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.OpenDisputeRequest, ...client.CallOption) *grpc.DisputeResponse); ok {
 		r0 = rf(ctx, in, opts...)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*billing.Notification)
+			r0 = ret.Get(0).(*grpc.DisputeResponse)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, *grpc.GetNotificationRequest, ...client.CallOption) error); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.OpenDisputeRequest, ...client.CallOption) error); ok {
 		r1 = rf(ctx, in, opts...)
 	} else {
 		r1 = ret.Error(1)
@@ -4070,8 +5150,8 @@ func (_m *BillingService) MarkNotificationAsRead(ctx context.Context, in *grpc.G
 	return r0, r1
 }
 
-// MerchantReviewRoyaltyReport provides a mock function with given fields: ctx, in, opts
-func (_m *BillingService) MerchantReviewRoyaltyReport(ctx context.Context, in *grpc.MerchantReviewRoyaltyReportRequest, opts ...client.CallOption) (*grpc.ResponseError, error) {
+// OpenRoyaltyReportDispute provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) OpenRoyaltyReportDispute(ctx context.Context, in *grpc.OpenRoyaltyReportDisputeRequest, opts ...client.CallOption) (*grpc.GetRoyaltyReportResponse, error) {
 	_va := make([]interface{}, len(opts))
 	for _i := range opts {
 		_va[_i] = opts[_i]
@@ -4081,17 +5161,17 @@ func (_m *BillingService) MerchantReviewRoyaltyReport(ctx context.Context, in *g
 	_ca = append(_ca, _va...)
 	ret := _m.Called(_ca...)
 
-	var r0 *grpc.ResponseError
-	if rf, ok := ret.Get(0).(func(context.Context, *grpc.MerchantReviewRoyaltyReportRequest, ...client.CallOption) *grpc.ResponseError); ok {
+	var r0 *grpc.GetRoyaltyReportResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.OpenRoyaltyReportDisputeRequest, ...client.CallOption) *grpc.GetRoyaltyReportResponse); ok {
 		r0 = rf(ctx, in, opts...)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*grpc.ResponseError)
+			r0 = ret.Get(0).(*grpc.GetRoyaltyReportResponse)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, *grpc.MerchantReviewRoyaltyReportRequest, ...client.CallOption) error); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.OpenRoyaltyReportDisputeRequest, ...client.CallOption) error); ok {
 		r1 = rf(ctx, in, opts...)
 	} else {
 		r1 = ret.Error(1)
@@ -4550,6 +5630,66 @@ func (_m *BillingService) PublishKeyProduct(ctx context.Context, in *grpc.Publis
 	return r0, r1
 }
 
+// RedeliverWebhook provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) RedeliverWebhook(ctx context.Context, in *grpc.RedeliverWebhookRequest, opts ...client.CallOption) (*grpc.RedeliverWebhookResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.RedeliverWebhookResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.RedeliverWebhookRequest, ...client.CallOption) *grpc.RedeliverWebhookResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.RedeliverWebhookResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.RedeliverWebhookRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RejectRefund provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) RejectRefund(ctx context.Context, in *grpc.RefundDecisionRequest, opts ...client.CallOption) (*grpc.CreateRefundResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.CreateRefundResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.RefundDecisionRequest, ...client.CallOption) *grpc.CreateRefundResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.CreateRefundResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.RefundDecisionRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ResendInviteAdmin provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) ResendInviteAdmin(ctx context.Context, in *grpc.ResendInviteAdminRequest, opts ...client.CallOption) (*grpc.EmptyResponseWithStatus, error) {
 	_va := make([]interface{}, len(opts))
@@ -4640,6 +5780,36 @@ func (_m *BillingService) ReserveKeyForOrder(ctx context.Context, in *grpc.Platf
 	return r0, r1
 }
 
+// ReviewOrderRisk provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) ReviewOrderRisk(ctx context.Context, in *grpc.ReviewOrderRiskRequest, opts ...client.CallOption) (*grpc.ReviewOrderRiskResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.ReviewOrderRiskResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.ReviewOrderRiskRequest, ...client.CallOption) *grpc.ReviewOrderRiskResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.ReviewOrderRiskResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.ReviewOrderRiskRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // RoyaltyReportPdfUploaded provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) RoyaltyReportPdfUploaded(ctx context.Context, in *grpc.RoyaltyReportPdfUploadedRequest, opts ...client.CallOption) (*grpc.RoyaltyReportPdfUploadedResponse, error) {
 	_va := make([]interface{}, len(opts))
@@ -4670,6 +5840,36 @@ func (_m *BillingService) RoyaltyReportPdfUploaded(ctx context.Context, in *grpc
 	return r0, r1
 }
 
+// SetCoupon provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) SetCoupon(ctx context.Context, in *billing.Coupon, opts ...client.CallOption) (*grpc.CouponResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.CouponResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *billing.Coupon, ...client.CallOption) *grpc.CouponResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.CouponResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *billing.Coupon, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // SetMerchantOperatingCompany provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) SetMerchantOperatingCompany(ctx context.Context, in *grpc.SetMerchantOperatingCompanyRequest, opts ...client.CallOption) (*grpc.SetMerchantOperatingCompanyResponse, error) {
 	_va := make([]interface{}, len(opts))
@@ -4820,6 +6020,36 @@ func (_m *BillingService) SetMoneyBackCostSystem(ctx context.Context, in *billin
 	return r0, r1
 }
 
+// SetOrderGift provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) SetOrderGift(ctx context.Context, in *grpc.SetOrderGiftRequest, opts ...client.CallOption) (*grpc.SetOrderGiftResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.SetOrderGiftResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.SetOrderGiftRequest, ...client.CallOption) *grpc.SetOrderGiftResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.SetOrderGiftResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.SetOrderGiftRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // SetPaymentChannelCostMerchant provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) SetPaymentChannelCostMerchant(ctx context.Context, in *billing.PaymentChannelCostMerchant, opts ...client.CallOption) (*grpc.PaymentChannelCostMerchantResponse, error) {
 	_va := make([]interface{}, len(opts))
@@ -4910,6 +6140,126 @@ func (_m *BillingService) SetPaymentMinLimitSystem(ctx context.Context, in *bill
 	return r0, r1
 }
 
+// SetPaymentRoutingRule provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) SetPaymentRoutingRule(ctx context.Context, in *billing.PaymentRoutingRule, opts ...client.CallOption) (*grpc.SetPaymentRoutingRuleResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.SetPaymentRoutingRuleResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *billing.PaymentRoutingRule, ...client.CallOption) *grpc.SetPaymentRoutingRuleResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.SetPaymentRoutingRuleResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *billing.PaymentRoutingRule, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetRefundApprovalThreshold provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) SetRefundApprovalThreshold(ctx context.Context, in *grpc.SetRefundApprovalThresholdRequest, opts ...client.CallOption) (*grpc.RefundApprovalThresholdResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.RefundApprovalThresholdResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.SetRefundApprovalThresholdRequest, ...client.CallOption) *grpc.RefundApprovalThresholdResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.RefundApprovalThresholdResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.SetRefundApprovalThresholdRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetRiskRule provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) SetRiskRule(ctx context.Context, in *grpc.SetRiskRuleRequest, opts ...client.CallOption) (*grpc.SetRiskRuleResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.SetRiskRuleResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.SetRiskRuleRequest, ...client.CallOption) *grpc.SetRiskRuleResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.SetRiskRuleResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.SetRiskRuleRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetSubscriptionPlan provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) SetSubscriptionPlan(ctx context.Context, in *billing.SubscriptionPlan, opts ...client.CallOption) (*grpc.SubscriptionPlanResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.SubscriptionPlanResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *billing.SubscriptionPlan, ...client.CallOption) *grpc.SubscriptionPlanResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.SubscriptionPlanResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *billing.SubscriptionPlan, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // SetUserNotifyNewRegion provides a mock function with given fields: ctx, in, opts
 func (_m *BillingService) SetUserNotifyNewRegion(ctx context.Context, in *grpc.SetUserNotifyRequest, opts ...client.CallOption) (*grpc.EmptyResponse, error) {
 	_va := make([]interface{}, len(opts))
@@ -5239,3 +6589,33 @@ func (_m *BillingService) UploadKeysFile(ctx context.Context, in *grpc.PlatformK
 
 	return r0, r1
 }
+
+// VoidPayment provides a mock function with given fields: ctx, in, opts
+func (_m *BillingService) VoidPayment(ctx context.Context, in *grpc.VoidPaymentRequest, opts ...client.CallOption) (*grpc.VoidPaymentResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *grpc.VoidPaymentResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *grpc.VoidPaymentRequest, ...client.CallOption) *grpc.VoidPaymentResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*grpc.VoidPaymentResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *grpc.VoidPaymentRequest, ...client.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
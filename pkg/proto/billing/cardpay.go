@@ -8,6 +8,11 @@ var (
 		pkg.CardPayPaymentResponseStatusDeclined:   true,
 		pkg.CardPayPaymentResponseStatusCancelled:  true,
 		pkg.CardPayPaymentResponseStatusAuthorized: true,
+		pkg.CardPayPaymentResponseStatusVoided:     true,
+	}
+	cardPayPaymentCallbackPendingStatuses = map[string]bool{
+		pkg.CardPayPaymentResponseStatusInProgress: true,
+		pkg.CardPayPaymentResponseStatusPending:    true,
 	}
 )
 
@@ -17,6 +22,14 @@ func (m *CardPayPaymentCallback) IsPaymentAllowedStatus() bool {
 	return ok && v == true
 }
 
+// IsPaymentPendingStatus checks that payment isn't finished in payment system yet
+// and its final status will be known later.
+func (m *CardPayPaymentCallback) IsPaymentPendingStatus() bool {
+	v, ok := cardPayPaymentCallbackPendingStatuses[m.GetStatus()]
+
+	return ok && v == true
+}
+
 func (m *CardPayRefundCallback) IsRefundAllowedStatus() bool {
 	v, ok := cardPayPaymentCallbackAllowedStatuses[m.RefundData.Status]
 
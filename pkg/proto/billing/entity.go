@@ -30,6 +30,7 @@ var (
 		constant.OrderStatusRefund:                      constant.OrderPublicStatusRefunded,
 		constant.OrderStatusChargeback:                  constant.OrderPublicStatusChargeback,
 		constant.OrderStatusItemReplaced:                constant.OrderPublicStatusProcessed,
+		pkg.OrderStatusPaymentSystemAuthorized:          constant.OrderPublicStatusPending,
 	}
 )
 
@@ -75,6 +76,28 @@ func (m *Project) IsDeleted() bool {
 	return m.Status == pkg.ProjectStatusDeleted
 }
 
+// GetNotificationUrl returns url of project for notification about order with specified public status.
+// If project hasn't url for specified status then url of processed payment notification will be returned.
+func (m *Project) GetNotificationUrl(status string) string {
+	var url string
+
+	switch status {
+	case constant.OrderPublicStatusRefunded:
+		url = m.UrlRefundPayment
+	case constant.OrderPublicStatusChargeback, pkg.WebhookEventTypeDisputeInquiry, pkg.WebhookEventTypeDisputeChargeback,
+		pkg.WebhookEventTypeDisputeRepresentment, pkg.WebhookEventTypeDisputeWon, pkg.WebhookEventTypeDisputeLost:
+		url = m.UrlChargebackPayment
+	case constant.OrderPublicStatusCanceled, constant.OrderPublicStatusRejected:
+		url = m.UrlCancelPayment
+	}
+
+	if url == "" {
+		url = m.UrlProcessPayment
+	}
+
+	return url
+}
+
 func (m *Project) NeedChangeStatusToDraft(req *Project) bool {
 	if m.Status != pkg.ProjectStatusTestCompleted &&
 		m.Status != pkg.ProjectStatusInProduction {
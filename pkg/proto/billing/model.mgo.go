@@ -61,6 +61,9 @@ type MgoProject struct {
 	ShortDescription map[string]string       `bson:"short_description"`
 	Currencies       []*HasCurrencyItem      `bson:"currencies"`
 	VirtualCurrency  *ProjectVirtualCurrency `bson:"virtual_currency"`
+
+	OrderExpiryTimeout    int64 `bson:"order_expiry_timeout"`
+	PreAuthorizeKeyOrders bool  `bson:"pre_authorize_key_orders"`
 }
 
 type MgoMerchantLastPayout struct {
@@ -145,6 +148,8 @@ type MgoMerchant struct {
 	MccCode                                       string                               `bson:"mcc_code"`
 	OperatingCompanyId                            string                               `bson:"operating_company_id"`
 	MerchantOperationsType                        string                               `bson:"merchant_operations_type"`
+	RoyaltyReportPeriod                           string                               `bson:"royalty_report_period"`
+	RoyaltyReportTimezone                         string                               `bson:"royalty_report_timezone"`
 }
 
 type MgoMerchantCommon struct {
@@ -291,6 +296,42 @@ type MgoOrder struct {
 	IsIpCountryMismatchBin      bool                           `bson:"is_ip_country_mismatch_bin"`
 	BillingCountryChangedByUser bool                           `bson:"billing_country_changed_by_user"`
 	IsRefundAllowed             bool                           `bson:"is_refund_allowed"`
+	IsPreAuthorization          bool                           `bson:"is_pre_authorization"`
+	AuthorizedAmount            float64                        `bson:"authorized_amount"`
+	CapturedAmount              float64                        `bson:"captured_amount"`
+	AuthorizationExpireAt       time.Time                      `bson:"authorization_expire_at"`
+	CapturedAt                  time.Time                      `bson:"captured_at"`
+	VoidedAt                    time.Time                      `bson:"voided_at"`
+	PaymentRoutes               []*OrderPaymentRoute           `bson:"payment_routes"`
+	Risk                        *MgoOrderRisk                  `bson:"risk"`
+	IsPreOrder                  bool                           `bson:"is_pre_order"`
+	Coupon                      *OrderCoupon                   `bson:"coupon"`
+	Gift                        *MgoOrderGift                  `bson:"gift"`
+	PendingWebhookEvents        []*MgoWebhookEvent             `bson:"pending_webhook_events"`
+	IsMerchantInitiated         bool                           `bson:"is_merchant_initiated"`
+}
+
+type MgoOrderGift struct {
+	RecipientEmail string    `bson:"recipient_email"`
+	Message        string    `bson:"message"`
+	SenderName     string    `bson:"sender_name"`
+	DeliverAt      time.Time `bson:"deliver_at"`
+	DeliveredAt    time.Time `bson:"delivered_at"`
+	LockedUntil    time.Time `bson:"locked_until"`
+}
+
+type MgoOrderRisk struct {
+	Score         int32            `bson:"score"`
+	Outcome       string           `bson:"outcome"`
+	Rules         []*OrderRiskRule `bson:"rules"`
+	CardToken     string           `bson:"card_token"`
+	Ip            string           `bson:"ip"`
+	ScoredAt      time.Time        `bson:"scored_at"`
+	ReviewStatus  string           `bson:"review_status"`
+	IsPaymentHeld bool             `bson:"is_payment_held"`
+	ReviewedBy    string           `bson:"reviewed_by"`
+	ReviewReason  string           `bson:"review_reason"`
+	ReviewedAt    time.Time        `bson:"reviewed_at"`
 }
 
 type MgoOrderItem struct {
@@ -384,6 +425,91 @@ type MgoRefund struct {
 	IsChargeback   bool               `bson:"is_chargeback"`
 	CreatedOrderId primitive.ObjectID `bson:"created_order_id,omitempty"`
 	Reason         string             `bson:"reason"`
+	Approval       *MgoRefundApproval `bson:"approval"`
+	Items          []*RefundItem      `bson:"items"`
+}
+
+type MgoRefundApproval struct {
+	Status            string    `bson:"status"`
+	ThresholdAmount   float64   `bson:"threshold_amount"`
+	ThresholdCurrency string    `bson:"threshold_currency"`
+	UserId            string    `bson:"user_id"`
+	UserRole          string    `bson:"user_role"`
+	Reason            string    `bson:"reason"`
+	DecidedAt         time.Time `bson:"decided_at"`
+}
+
+type MgoDispute struct {
+	Id         primitive.ObjectID        `bson:"_id"`
+	OrderId    primitive.ObjectID        `bson:"order_id"`
+	OrderUuid  string                    `bson:"order_uuid"`
+	MerchantId primitive.ObjectID        `bson:"merchant_id"`
+	ProjectId  primitive.ObjectID        `bson:"project_id"`
+	RefundId   string                    `bson:"refund_id"`
+	ExternalId string                    `bson:"external_id"`
+	ReasonCode string                    `bson:"reason_code"`
+	Amount     float64                   `bson:"amount"`
+	Currency   string                    `bson:"currency"`
+	Status     string                    `bson:"status"`
+	DueAt      time.Time                 `bson:"due_at"`
+	Evidence   []*MgoDisputeEvidence     `bson:"evidence"`
+	History    []*MgoDisputeStatusChange `bson:"history"`
+	ClosedAt   time.Time                 `bson:"closed_at"`
+	CreatedAt  time.Time                 `bson:"created_at"`
+	UpdatedAt  time.Time                 `bson:"updated_at"`
+}
+
+type MgoDisputeEvidence struct {
+	Id          string    `bson:"id"`
+	Type        string    `bson:"type"`
+	Description string    `bson:"description"`
+	Content     string    `bson:"content"`
+	UserId      string    `bson:"user_id"`
+	CreatedAt   time.Time `bson:"created_at"`
+}
+
+type MgoDisputeStatusChange struct {
+	Status    string    `bson:"status"`
+	UserId    string    `bson:"user_id"`
+	Comment   string    `bson:"comment"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+type MgoRefundApprovalThreshold struct {
+	Id         primitive.ObjectID `bson:"_id"`
+	MerchantId primitive.ObjectID `bson:"merchant_id"`
+	ProjectId  primitive.ObjectID `bson:"project_id"`
+	Amount     float64            `bson:"amount"`
+	Currency   string             `bson:"currency"`
+	CreatedAt  time.Time          `bson:"created_at"`
+	UpdatedAt  time.Time          `bson:"updated_at"`
+}
+
+type MgoBulkRefundJob struct {
+	Id          primitive.ObjectID      `bson:"_id"`
+	MerchantId  primitive.ObjectID      `bson:"merchant_id"`
+	CreatorId   string                  `bson:"creator_id"`
+	Reason      string                  `bson:"reason"`
+	Status      string                  `bson:"status"`
+	Items       []*MgoBulkRefundJobItem `bson:"items"`
+	Total       int32                   `bson:"total"`
+	Processed   int32                   `bson:"processed"`
+	Succeeded   int32                   `bson:"succeeded"`
+	Failed      int32                   `bson:"failed"`
+	LockedUntil time.Time               `bson:"locked_until"`
+	CompletedAt time.Time               `bson:"completed_at"`
+	CreatedAt   time.Time               `bson:"created_at"`
+	UpdatedAt   time.Time               `bson:"updated_at"`
+}
+
+type MgoBulkRefundJobItem struct {
+	OrderId     string    `bson:"order_id"`
+	Status      string    `bson:"status"`
+	RefundId    string    `bson:"refund_id"`
+	Amount      float64   `bson:"amount"`
+	Currency    string    `bson:"currency"`
+	Error       string    `bson:"error"`
+	ProcessedAt time.Time `bson:"processed_at"`
 }
 
 type MgoMerchantPaymentMethodHistory struct {
@@ -632,6 +758,8 @@ type MgoRoyaltyReport struct {
 	IsAutoAccepted     bool                  `bson:"is_auto_accepted"`
 	PayoutDocumentId   string                `bson:"payout_document_id"`
 	OperatingCompanyId string                `bson:"operating_company_id"`
+	Dispute            *RoyaltyReportDispute `bson:"dispute"`
+	DisputeExpireAt    time.Time             `bson:"dispute_expire_at"`
 }
 
 type MgoRoyaltyReportCorrectionItem struct {
@@ -642,6 +770,23 @@ type MgoRoyaltyReportCorrectionItem struct {
 	EntryDate         time.Time          `bson:"entry_date"`
 }
 
+type MgoRoyaltyReportDispute struct {
+	Status      string                            `bson:"status"`
+	Items       []*RoyaltyReportDisputeItem       `bson:"items"`
+	Messages    []*MgoRoyaltyReportDisputeMessage `bson:"messages"`
+	IsEscalated bool                              `bson:"is_escalated"`
+	EscalatedAt time.Time                         `bson:"escalated_at"`
+}
+
+type MgoRoyaltyReportDisputeMessage struct {
+	Id          primitive.ObjectID                `bson:"id"`
+	Source      string                            `bson:"source"`
+	UserId      string                            `bson:"user_id"`
+	Text        string                            `bson:"text"`
+	Attachments []*RoyaltyReportDisputeAttachment `bson:"attachments"`
+	CreatedAt   time.Time                         `bson:"created_at"`
+}
+
 type MgoRoyaltyReportChanges struct {
 	Id              primitive.ObjectID `bson:"_id"`
 	RoyaltyReportId primitive.ObjectID `bson:"royalty_report_id"`
@@ -826,6 +971,9 @@ type MgoKey struct {
 	CreatedAt    time.Time           `bson:"created_at"`
 	ReservedTo   time.Time           `bson:"reserved_to"`
 	RedeemedAt   time.Time           `bson:"redeemed_at"`
+	RevokedAt    *time.Time          `bson:"revoked_at,omitempty"`
+	RefundId     string              `bson:"refund_id"`
+	CodeHash     string              `bson:"code_hash,omitempty"`
 }
 
 type MgoPayoutDocument struct {
@@ -899,6 +1047,118 @@ type MgoPaymentMinLimitSystem struct {
 	UpdatedAt time.Time          `bson:"updated_at"`
 }
 
+type MgoPaymentRoutingRule struct {
+	Id              primitive.ObjectID `bson:"_id"`
+	PaymentMethodId primitive.ObjectID `bson:"payment_method_id"`
+	MccCode         string             `bson:"mcc_code"`
+	Country         string             `bson:"country"`
+	Routes          []*MgoPaymentRoute `bson:"routes"`
+	CreatedAt       time.Time          `bson:"created_at"`
+	UpdatedAt       time.Time          `bson:"updated_at"`
+}
+
+type MgoPaymentRoute struct {
+	PaymentSystemId primitive.ObjectID `bson:"payment_system_id"`
+	Weight          int32              `bson:"weight"`
+}
+
+type MgoRiskRule struct {
+	Id               primitive.ObjectID `bson:"_id"`
+	Name             string             `bson:"name"`
+	Type             string             `bson:"type"`
+	Score            int32              `bson:"score"`
+	Action           string             `bson:"action"`
+	MaxCount         int32              `bson:"max_count"`
+	Period           int64              `bson:"period"`
+	AmountMultiplier float64            `bson:"amount_multiplier"`
+	Values           []string           `bson:"values"`
+	IsActive         bool               `bson:"is_active"`
+	CreatedAt        time.Time          `bson:"created_at"`
+	UpdatedAt        time.Time          `bson:"updated_at"`
+}
+
+type MgoSubscriptionPlan struct {
+	Id            primitive.ObjectID `bson:"_id"`
+	MerchantId    primitive.ObjectID `bson:"merchant_id"`
+	ProjectId     primitive.ObjectID `bson:"project_id"`
+	ProductId     primitive.ObjectID `bson:"product_id"`
+	Name          string             `bson:"name"`
+	Amount        float64            `bson:"amount"`
+	Currency      string             `bson:"currency"`
+	Interval      string             `bson:"interval"`
+	IntervalCount int32              `bson:"interval_count"`
+	TrialDays     int32              `bson:"trial_days"`
+	IsActive      bool               `bson:"is_active"`
+	CreatedAt     time.Time          `bson:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at"`
+}
+
+type MgoSubscription struct {
+	Id                 primitive.ObjectID `bson:"_id"`
+	PlanId             primitive.ObjectID `bson:"plan_id"`
+	MerchantId         primitive.ObjectID `bson:"merchant_id"`
+	ProjectId          primitive.ObjectID `bson:"project_id"`
+	User               *OrderUser         `bson:"user"`
+	PaymentMethodId    string             `bson:"payment_method_id"`
+	StoredCardId       string             `bson:"stored_card_id"`
+	Status             string             `bson:"status"`
+	CurrentPeriodStart time.Time          `bson:"current_period_start"`
+	CurrentPeriodEnd   time.Time          `bson:"current_period_end"`
+	CancelAtPeriodEnd  bool               `bson:"cancel_at_period_end"`
+	CanceledAt         time.Time          `bson:"canceled_at"`
+	ProrationAmount    float64            `bson:"proration_amount"`
+	RenewalOrderId     string             `bson:"renewal_order_id"`
+	FailedAttempts     int32              `bson:"failed_attempts"`
+	NextChargeAt       time.Time          `bson:"next_charge_at"`
+	CreatedAt          time.Time          `bson:"created_at"`
+	UpdatedAt          time.Time          `bson:"updated_at"`
+	SourceOrderId      string             `bson:"source_order_id"`
+}
+
+type MgoWebhookEvent struct {
+	Id             primitive.ObjectID `bson:"_id"`
+	MerchantId     primitive.ObjectID `bson:"merchant_id"`
+	ProjectId      primitive.ObjectID `bson:"project_id"`
+	OrderId        primitive.ObjectID `bson:"order_id"`
+	OrderUuid      string             `bson:"order_uuid"`
+	Type           string             `bson:"type"`
+	Payload        string             `bson:"payload"`
+	Status         string             `bson:"status"`
+	Attempts       int32              `bson:"attempts"`
+	Url            string             `bson:"url"`
+	LastError      string             `bson:"last_error"`
+	LastHttpStatus int32              `bson:"last_http_status"`
+	NextAttemptAt  time.Time          `bson:"next_attempt_at"`
+	DeliveredAt    time.Time          `bson:"delivered_at"`
+	CreatedAt      time.Time          `bson:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at"`
+}
+
+type MgoPaymentStatusDiscrepancy struct {
+	Id                    primitive.ObjectID `bson:"_id"`
+	OrderId               primitive.ObjectID `bson:"order_id"`
+	OrderUuid             string             `bson:"order_uuid"`
+	PaymentSystemId       primitive.ObjectID `bson:"payment_system_id"`
+	Transaction           string             `bson:"transaction"`
+	Result                string             `bson:"result"`
+	PreviousPrivateStatus int32              `bson:"previous_private_status"`
+	PrivateStatus         int32              `bson:"private_status"`
+	ProviderResponse      string             `bson:"provider_response"`
+	Error                 string             `bson:"error"`
+	CreatedAt             time.Time          `bson:"created_at"`
+}
+
+type MgoSettlementReport struct {
+	Id              primitive.ObjectID      `bson:"_id"`
+	PaymentSystemId primitive.ObjectID      `bson:"payment_system_id"`
+	FileType        string                  `bson:"file_type"`
+	PeriodFrom      time.Time               `bson:"period_from"`
+	PeriodTo        time.Time               `bson:"period_to"`
+	Totals          *SettlementReportTotals `bson:"totals"`
+	Items           []*SettlementReportItem `bson:"items"`
+	CreatedAt       time.Time               `bson:"created_at"`
+}
+
 type MgoUserRole struct {
 	Id         primitive.ObjectID  `bson:"_id"`
 	MerchantId *primitive.ObjectID `bson:"merchant_id"`
@@ -912,6 +1172,64 @@ type MgoUserRole struct {
 	UpdatedAt  time.Time           `bson:"updated_at"`
 }
 
+type MgoCoupon struct {
+	Id                 primitive.ObjectID   `bson:"_id"`
+	MerchantId         primitive.ObjectID   `bson:"merchant_id"`
+	ProjectId          primitive.ObjectID   `bson:"project_id"`
+	Code               string               `bson:"code"`
+	DiscountType       string               `bson:"discount_type"`
+	Percent            float64              `bson:"percent"`
+	Amounts            []*CouponAmount      `bson:"amounts"`
+	MinOrderAmounts    []*CouponAmount      `bson:"min_order_amounts"`
+	Currencies         []string             `bson:"currencies"`
+	ProductIds         []primitive.ObjectID `bson:"product_ids"`
+	MaxUses            int32                `bson:"max_uses"`
+	MaxUsesPerCustomer int32                `bson:"max_uses_per_customer"`
+	UsedCount          int32                `bson:"used_count"`
+	StartsAt           time.Time            `bson:"starts_at"`
+	ExpiresAt          time.Time            `bson:"expires_at"`
+	IsActive           bool                 `bson:"is_active"`
+	CreatedAt          time.Time            `bson:"created_at"`
+	UpdatedAt          time.Time            `bson:"updated_at"`
+}
+
+type MgoStoreCredit struct {
+	Id         primitive.ObjectID `bson:"_id"`
+	MerchantId primitive.ObjectID `bson:"merchant_id"`
+	CustomerId primitive.ObjectID `bson:"customer_id"`
+	Currency   string             `bson:"currency"`
+	Balance    float64            `bson:"balance"`
+	CreatedAt  time.Time          `bson:"created_at"`
+	UpdatedAt  time.Time          `bson:"updated_at"`
+}
+
+type MgoStoreCreditTransaction struct {
+	Id         primitive.ObjectID `bson:"_id"`
+	MerchantId primitive.ObjectID `bson:"merchant_id"`
+	CustomerId primitive.ObjectID `bson:"customer_id"`
+	Type       string             `bson:"type"`
+	Amount     float64            `bson:"amount"`
+	Currency   string             `bson:"currency"`
+	OrderId    primitive.ObjectID `bson:"order_id,omitempty"`
+	RefundId   primitive.ObjectID `bson:"refund_id,omitempty"`
+	Reason     string             `bson:"reason"`
+	CreatedAt  time.Time          `bson:"created_at"`
+}
+
+type MgoLedgerPosting struct {
+	Id                 primitive.ObjectID        `bson:"_id"`
+	EntryId            primitive.ObjectID        `bson:"entry_id"`
+	EntryType          string                    `bson:"entry_type"`
+	Source             *MgoAccountingEntrySource `bson:"source"`
+	MerchantId         primitive.ObjectID        `bson:"merchant_id"`
+	OperatingCompanyId string                    `bson:"operating_company_id"`
+	Account            string                    `bson:"account"`
+	Debit              float64                   `bson:"debit"`
+	Credit             float64                   `bson:"credit"`
+	Currency           string                    `bson:"currency"`
+	CreatedAt          time.Time                 `bson:"created_at"`
+}
+
 func (m *PayoutDocument) MarshalBSON() ([]byte, error) {
 	st := &MgoPayoutDocument{
 		SourceId:                m.SourceId,
@@ -1417,6 +1735,8 @@ func (m *Project) MarshalBSON() ([]byte, error) {
 		ShortDescription:         m.ShortDescription,
 		Currencies:               m.Currencies,
 		VirtualCurrency:          m.VirtualCurrency,
+		OrderExpiryTimeout:       m.OrderExpiryTimeout,
+		PreAuthorizeKeyOrders:    m.PreAuthorizeKeyOrders,
 	}
 
 	if len(m.Name) > 0 {
@@ -1504,6 +1824,8 @@ func (m *Project) UnmarshalBSON(raw []byte) error {
 	m.ShortDescription = decoded.ShortDescription
 	m.Currencies = decoded.Currencies
 	m.VirtualCurrency = decoded.VirtualCurrency
+	m.OrderExpiryTimeout = decoded.OrderExpiryTimeout
+	m.PreAuthorizeKeyOrders = decoded.PreAuthorizeKeyOrders
 
 	nameLen := len(decoded.Name)
 
@@ -1723,6 +2045,13 @@ func (m *Order) MarshalBSON() ([]byte, error) {
 		IsIpCountryMismatchBin:      m.IsIpCountryMismatchBin,
 		BillingCountryChangedByUser: m.BillingCountryChangedByUser,
 		IsRefundAllowed:             m.IsRefundAllowed,
+		IsPreAuthorization:          m.IsPreAuthorization,
+		AuthorizedAmount:            m.AuthorizedAmount,
+		CapturedAmount:              m.CapturedAmount,
+		PaymentRoutes:               m.PaymentRoutes,
+		IsPreOrder:                  m.IsPreOrder,
+		IsMerchantInitiated:         m.IsMerchantInitiated,
+		Coupon:                      m.Coupon,
 	}
 
 	if m.Refund != nil {
@@ -1907,6 +2236,106 @@ func (m *Order) MarshalBSON() ([]byte, error) {
 		st.ParentPaymentAt = t
 	}
 
+	if m.AuthorizationExpireAt != nil {
+		t, err := ptypes.Timestamp(m.AuthorizationExpireAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		st.AuthorizationExpireAt = t
+	}
+
+	if m.CapturedAt != nil {
+		t, err := ptypes.Timestamp(m.CapturedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		st.CapturedAt = t
+	}
+
+	if m.VoidedAt != nil {
+		t, err := ptypes.Timestamp(m.VoidedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		st.VoidedAt = t
+	}
+
+	if m.Risk != nil {
+		st.Risk = &MgoOrderRisk{
+			Score:         m.Risk.Score,
+			Outcome:       m.Risk.Outcome,
+			Rules:         m.Risk.Rules,
+			CardToken:     m.Risk.CardToken,
+			Ip:            m.Risk.Ip,
+			ReviewStatus:  m.Risk.ReviewStatus,
+			IsPaymentHeld: m.Risk.IsPaymentHeld,
+			ReviewedBy:    m.Risk.ReviewedBy,
+			ReviewReason:  m.Risk.ReviewReason,
+		}
+
+		if m.Risk.ScoredAt != nil {
+			t, err := ptypes.Timestamp(m.Risk.ScoredAt)
+
+			if err != nil {
+				return nil, err
+			}
+
+			st.Risk.ScoredAt = t
+		}
+
+		if m.Risk.ReviewedAt != nil {
+			t, err := ptypes.Timestamp(m.Risk.ReviewedAt)
+
+			if err != nil {
+				return nil, err
+			}
+
+			st.Risk.ReviewedAt = t
+		}
+	}
+
+	if m.Gift != nil {
+		st.Gift = &MgoOrderGift{
+			RecipientEmail: m.Gift.RecipientEmail,
+			Message:        m.Gift.Message,
+			SenderName:     m.Gift.SenderName,
+		}
+
+		if m.Gift.DeliverAt != nil {
+			if st.Gift.DeliverAt, err = ptypes.Timestamp(m.Gift.DeliverAt); err != nil {
+				return nil, err
+			}
+		}
+
+		if m.Gift.DeliveredAt != nil {
+			if st.Gift.DeliveredAt, err = ptypes.Timestamp(m.Gift.DeliveredAt); err != nil {
+				return nil, err
+			}
+		}
+
+		if m.Gift.LockedUntil != nil {
+			if st.Gift.LockedUntil, err = ptypes.Timestamp(m.Gift.LockedUntil); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, v := range m.PendingWebhookEvents {
+		event, err := getMgoWebhookEvent(v)
+
+		if err != nil {
+			return nil, err
+		}
+
+		st.PendingWebhookEvents = append(st.PendingWebhookEvents, event)
+	}
+
 	return bson.Marshal(st)
 }
 
@@ -1959,6 +2388,13 @@ func (m *Order) UnmarshalBSON(raw []byte) error {
 	m.IsIpCountryMismatchBin = decoded.IsIpCountryMismatchBin
 	m.BillingCountryChangedByUser = decoded.BillingCountryChangedByUser
 	m.IsRefundAllowed = decoded.IsRefundAllowed
+	m.IsPreAuthorization = decoded.IsPreAuthorization
+	m.AuthorizedAmount = decoded.AuthorizedAmount
+	m.CapturedAmount = decoded.CapturedAmount
+	m.PaymentRoutes = decoded.PaymentRoutes
+	m.IsPreOrder = decoded.IsPreOrder
+	m.IsMerchantInitiated = decoded.IsMerchantInitiated
+	m.Coupon = decoded.Coupon
 
 	if decoded.Refund != nil {
 		m.Refund = &OrderNotificationRefund{
@@ -2062,6 +2498,89 @@ func (m *Order) UnmarshalBSON(raw []byte) error {
 		return err
 	}
 
+	// dates of payment authorization processing must stay empty until the events happened
+	if !decoded.AuthorizationExpireAt.IsZero() {
+		m.AuthorizationExpireAt, err = ptypes.TimestampProto(decoded.AuthorizationExpireAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !decoded.CapturedAt.IsZero() {
+		m.CapturedAt, err = ptypes.TimestampProto(decoded.CapturedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !decoded.VoidedAt.IsZero() {
+		m.VoidedAt, err = ptypes.TimestampProto(decoded.VoidedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	if decoded.Risk != nil {
+		m.Risk = &OrderRisk{
+			Score:         decoded.Risk.Score,
+			Outcome:       decoded.Risk.Outcome,
+			Rules:         decoded.Risk.Rules,
+			CardToken:     decoded.Risk.CardToken,
+			Ip:            decoded.Risk.Ip,
+			ReviewStatus:  decoded.Risk.ReviewStatus,
+			IsPaymentHeld: decoded.Risk.IsPaymentHeld,
+			ReviewedBy:    decoded.Risk.ReviewedBy,
+			ReviewReason:  decoded.Risk.ReviewReason,
+		}
+
+		m.Risk.ScoredAt, err = ptypes.TimestampProto(decoded.Risk.ScoredAt)
+		if err != nil {
+			return err
+		}
+
+		if !decoded.Risk.ReviewedAt.IsZero() {
+			if m.Risk.ReviewedAt, err = ptypes.TimestampProto(decoded.Risk.ReviewedAt); err != nil {
+				return err
+			}
+		}
+	}
+
+	if decoded.Gift != nil {
+		m.Gift = &OrderGift{
+			RecipientEmail: decoded.Gift.RecipientEmail,
+			Message:        decoded.Gift.Message,
+			SenderName:     decoded.Gift.SenderName,
+		}
+
+		if !decoded.Gift.DeliverAt.IsZero() {
+			if m.Gift.DeliverAt, err = ptypes.TimestampProto(decoded.Gift.DeliverAt); err != nil {
+				return err
+			}
+		}
+
+		if !decoded.Gift.DeliveredAt.IsZero() {
+			if m.Gift.DeliveredAt, err = ptypes.TimestampProto(decoded.Gift.DeliveredAt); err != nil {
+				return err
+			}
+		}
+
+		if !decoded.Gift.LockedUntil.IsZero() {
+			if m.Gift.LockedUntil, err = ptypes.TimestampProto(decoded.Gift.LockedUntil); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, v := range decoded.PendingWebhookEvents {
+		event := &WebhookEvent{}
+
+		if err = setWebhookEvent(event, v); err != nil {
+			return err
+		}
+
+		m.PendingWebhookEvents = append(m.PendingWebhookEvents, event)
+	}
+
 	return nil
 }
 
@@ -2379,6 +2898,8 @@ func (m *Merchant) MarshalBSON() ([]byte, error) {
 		MccCode:                m.MccCode,
 		OperatingCompanyId:     m.OperatingCompanyId,
 		MerchantOperationsType: m.MerchantOperationsType,
+		RoyaltyReportPeriod:    m.RoyaltyReportPeriod,
+		RoyaltyReportTimezone:  m.RoyaltyReportTimezone,
 	}
 
 	if len(m.Id) <= 0 {
@@ -2583,6 +3104,8 @@ func (m *Merchant) UnmarshalBSON(raw []byte) error {
 	m.MccCode = decoded.MccCode
 	m.OperatingCompanyId = decoded.OperatingCompanyId
 	m.MerchantOperationsType = decoded.MerchantOperationsType
+	m.RoyaltyReportPeriod = decoded.RoyaltyReportPeriod
+	m.RoyaltyReportTimezone = decoded.RoyaltyReportTimezone
 
 	if decoded.User != nil {
 		m.User = &MerchantUser{
@@ -2844,6 +3367,7 @@ func (m *Refund) MarshalBSON() ([]byte, error) {
 		SalesTax:     m.SalesTax,
 		IsChargeback: m.IsChargeback,
 		Reason:       m.Reason,
+		Items:        m.Items,
 	}
 
 	if len(m.Id) <= 0 {
@@ -2868,6 +3392,23 @@ func (m *Refund) MarshalBSON() ([]byte, error) {
 		st.CreatedOrderId = oid
 	}
 
+	if m.Approval != nil {
+		st.Approval = &MgoRefundApproval{
+			Status:            m.Approval.Status,
+			ThresholdAmount:   m.Approval.ThresholdAmount,
+			ThresholdCurrency: m.Approval.ThresholdCurrency,
+			UserId:            m.Approval.UserId,
+			UserRole:          m.Approval.UserRole,
+			Reason:            m.Approval.Reason,
+		}
+
+		if m.Approval.DecidedAt != nil {
+			if st.Approval.DecidedAt, err = ptypes.Timestamp(m.Approval.DecidedAt); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	if m.CreatedAt != nil {
 		t, err := ptypes.Timestamp(m.CreatedAt)
 
@@ -2917,11 +3458,29 @@ func (m *Refund) UnmarshalBSON(raw []byte) error {
 	m.SalesTax = decoded.SalesTax
 	m.IsChargeback = decoded.IsChargeback
 	m.Reason = decoded.Reason
+	m.Items = decoded.Items
 
 	if !decoded.CreatedOrderId.IsZero() {
 		m.CreatedOrderId = decoded.CreatedOrderId.Hex()
 	}
 
+	if decoded.Approval != nil {
+		m.Approval = &RefundApproval{
+			Status:            decoded.Approval.Status,
+			ThresholdAmount:   decoded.Approval.ThresholdAmount,
+			ThresholdCurrency: decoded.Approval.ThresholdCurrency,
+			UserId:            decoded.Approval.UserId,
+			UserRole:          decoded.Approval.UserRole,
+			Reason:            decoded.Approval.Reason,
+		}
+
+		if !decoded.Approval.DecidedAt.IsZero() {
+			if m.Approval.DecidedAt, err = ptypes.TimestampProto(decoded.Approval.DecidedAt); err != nil {
+				return err
+			}
+		}
+	}
+
 	m.CreatedAt, err = ptypes.TimestampProto(decoded.CreatedAt)
 
 	if err != nil {
@@ -3998,6 +4557,7 @@ func (m *RoyaltyReport) MarshalBSON() ([]byte, error) {
 		IsAutoAccepted:     m.IsAutoAccepted,
 		PayoutDocumentId:   m.PayoutDocumentId,
 		OperatingCompanyId: m.OperatingCompanyId,
+		Dispute:            m.Dispute,
 	}
 
 	if m.PayoutDate != nil {
@@ -4086,6 +4646,16 @@ func (m *RoyaltyReport) MarshalBSON() ([]byte, error) {
 		st.DisputeClosedAt = t
 	}
 
+	if m.DisputeExpireAt != nil {
+		t, err := ptypes.Timestamp(m.DisputeExpireAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		st.DisputeExpireAt = t
+	}
+
 	return bson.Marshal(st)
 }
 
@@ -4107,6 +4677,7 @@ func (m *RoyaltyReport) UnmarshalBSON(raw []byte) error {
 	m.IsAutoAccepted = decoded.IsAutoAccepted
 	m.PayoutDocumentId = decoded.PayoutDocumentId
 	m.OperatingCompanyId = decoded.OperatingCompanyId
+	m.Dispute = decoded.Dispute
 
 	m.PayoutDate, err = ptypes.TimestampProto(decoded.PayoutDate)
 	if err != nil {
@@ -4153,6 +4724,11 @@ func (m *RoyaltyReport) UnmarshalBSON(raw []byte) error {
 		return err
 	}
 
+	m.DisputeExpireAt, err = ptypes.TimestampProto(decoded.DisputeExpireAt)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -4261,6 +4837,95 @@ func (m *RoyaltyReportCorrectionItem) UnmarshalBSON(raw []byte) error {
 	return nil
 }
 
+func (m *RoyaltyReportDispute) MarshalBSON() ([]byte, error) {
+	st := &MgoRoyaltyReportDispute{
+		Status:      m.Status,
+		Items:       m.Items,
+		IsEscalated: m.IsEscalated,
+	}
+
+	for _, v := range m.Messages {
+		oid, err := primitive.ObjectIDFromHex(v.Id)
+
+		if err != nil {
+			return nil, errors.New(errorInvalidObjectId)
+		}
+
+		message := &MgoRoyaltyReportDisputeMessage{
+			Id:          oid,
+			Source:      v.Source,
+			UserId:      v.UserId,
+			Text:        v.Text,
+			Attachments: v.Attachments,
+		}
+
+		if v.CreatedAt != nil {
+			t, err := ptypes.Timestamp(v.CreatedAt)
+
+			if err != nil {
+				return nil, err
+			}
+
+			message.CreatedAt = t
+		} else {
+			message.CreatedAt = time.Now()
+		}
+
+		st.Messages = append(st.Messages, message)
+	}
+
+	if m.EscalatedAt != nil {
+		t, err := ptypes.Timestamp(m.EscalatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		st.EscalatedAt = t
+	}
+
+	return bson.Marshal(st)
+}
+
+func (m *RoyaltyReportDispute) UnmarshalBSON(raw []byte) error {
+	decoded := new(MgoRoyaltyReportDispute)
+	err := bson.Unmarshal(raw, decoded)
+
+	if err != nil {
+		return err
+	}
+
+	m.Status = decoded.Status
+	m.Items = decoded.Items
+	m.IsEscalated = decoded.IsEscalated
+
+	for _, v := range decoded.Messages {
+		message := &RoyaltyReportDisputeMessage{
+			Id:          v.Id.Hex(),
+			Source:      v.Source,
+			UserId:      v.UserId,
+			Text:        v.Text,
+			Attachments: v.Attachments,
+		}
+
+		message.CreatedAt, err = ptypes.TimestampProto(v.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		m.Messages = append(m.Messages, message)
+	}
+
+	if !decoded.EscalatedAt.IsZero() {
+		m.EscalatedAt, err = ptypes.TimestampProto(decoded.EscalatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (m *OrderViewPrivate) UnmarshalBSON(raw []byte) error {
 	decoded := new(MgoOrderViewPrivate)
 	err := bson.Unmarshal(raw, decoded)
@@ -4460,6 +5125,7 @@ func getPaymentMethodOrder(in *MgoOrderPaymentMethod) *PaymentMethodOrder {
 		Params:          in.Params,
 		PaymentSystemId: in.PaymentSystemId.Hex(),
 		Group:           in.Group,
+		Handler:         in.Handler,
 		Saved:           in.Saved,
 		RefundAllowed:   in.RefundAllowed,
 	}
@@ -4603,11 +5269,19 @@ func (k *Key) UnmarshalBSON(raw []byte) error {
 	k.Code = decoded.Code
 	k.KeyProductId = decoded.KeyProductId.Hex()
 	k.PlatformId = decoded.PlatformId
+	k.RefundId = decoded.RefundId
+	k.CodeHash = decoded.CodeHash
 
 	if decoded.OrderId != nil {
 		k.OrderId = decoded.OrderId.Hex()
 	}
 
+	if decoded.RevokedAt != nil {
+		if k.RevokedAt, err = ptypes.TimestampProto(*decoded.RevokedAt); err != nil {
+			return err
+		}
+	}
+
 	if k.CreatedAt, err = ptypes.TimestampProto(decoded.CreatedAt); err != nil {
 		return err
 	}
@@ -4641,6 +5315,8 @@ func (m *Key) MarshalBSON() ([]byte, error) {
 		PlatformId:   m.PlatformId,
 		KeyProductId: keyProductOid,
 		Code:         m.Code,
+		RefundId:     m.RefundId,
+		CodeHash:     m.CodeHash,
 	}
 
 	if m.OrderId != "" {
@@ -4670,8 +5346,18 @@ func (m *Key) MarshalBSON() ([]byte, error) {
 		st.ReservedTo = time.Time{}
 	}
 
-	if m.CreatedAt != nil {
-		if st.CreatedAt, err = ptypes.Timestamp(m.CreatedAt); err != nil {
+	if m.RevokedAt != nil {
+		revokedAt, err := ptypes.Timestamp(m.RevokedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		st.RevokedAt = &revokedAt
+	}
+
+	if m.CreatedAt != nil {
+		if st.CreatedAt, err = ptypes.Timestamp(m.CreatedAt); err != nil {
 			return nil, err
 		}
 	} else {
@@ -4828,36 +5514,119 @@ func (m *PaymentMinLimitSystem) UnmarshalBSON(raw []byte) error {
 	return nil
 }
 
-func (m *UserRole) MarshalBSON() ([]byte, error) {
-	oid, err := primitive.ObjectIDFromHex(m.Id)
+func (m *PaymentRoutingRule) MarshalBSON() ([]byte, error) {
+	st := &MgoPaymentRoutingRule{
+		MccCode: m.MccCode,
+		Country: m.Country,
+		Routes:  []*MgoPaymentRoute{},
+	}
+
+	if len(m.Id) <= 0 {
+		st.Id = primitive.NewObjectID()
+	} else {
+		oid, err := primitive.ObjectIDFromHex(m.Id)
+
+		if err != nil {
+			return nil, errors.New(errorInvalidObjectId)
+		}
+
+		st.Id = oid
+	}
+
+	oid, err := primitive.ObjectIDFromHex(m.PaymentMethodId)
 
 	if err != nil {
-		return nil, err
+		return nil, errors.New(errorInvalidObjectId)
 	}
 
-	st := &MgoUserRole{
-		Id:        oid,
-		Role:      m.Role,
-		Status:    m.Status,
-		Email:     m.Email,
-		FirstName: m.FirstName,
-		LastName:  m.LastName,
+	st.PaymentMethodId = oid
+
+	for _, v := range m.Routes {
+		oid, err := primitive.ObjectIDFromHex(v.PaymentSystemId)
+
+		if err != nil {
+			return nil, errors.New(errorInvalidObjectId)
+		}
+
+		st.Routes = append(st.Routes, &MgoPaymentRoute{PaymentSystemId: oid, Weight: v.Weight})
 	}
 
-	oid, err = primitive.ObjectIDFromHex(m.MerchantId)
+	if m.CreatedAt != nil {
+		if st.CreatedAt, err = ptypes.Timestamp(m.CreatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.CreatedAt = time.Now()
+	}
 
-	if err == nil {
-		hex := oid
-		st.MerchantId = &hex
+	if m.UpdatedAt != nil {
+		if st.UpdatedAt, err = ptypes.Timestamp(m.UpdatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.UpdatedAt = time.Now()
 	}
 
-	oid, err = primitive.ObjectIDFromHex(m.UserId)
+	return bson.Marshal(st)
+}
 
-	if err == nil {
-		hex := oid
-		st.UserId = &hex
+func (m *PaymentRoutingRule) UnmarshalBSON(raw []byte) error {
+	decoded := new(MgoPaymentRoutingRule)
+	err := bson.Unmarshal(raw, decoded)
+
+	if err != nil {
+		return err
+	}
+
+	m.Id = decoded.Id.Hex()
+	m.PaymentMethodId = decoded.PaymentMethodId.Hex()
+	m.MccCode = decoded.MccCode
+	m.Country = decoded.Country
+
+	for _, v := range decoded.Routes {
+		m.Routes = append(m.Routes, &PaymentRoute{PaymentSystemId: v.PaymentSystemId.Hex(), Weight: v.Weight})
+	}
+
+	m.CreatedAt, err = ptypes.TimestampProto(decoded.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	m.UpdatedAt, err = ptypes.TimestampProto(decoded.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *RiskRule) MarshalBSON() ([]byte, error) {
+	st := &MgoRiskRule{
+		Name:             m.Name,
+		Type:             m.Type,
+		Score:            m.Score,
+		Action:           m.Action,
+		MaxCount:         m.MaxCount,
+		Period:           m.Period,
+		AmountMultiplier: m.AmountMultiplier,
+		Values:           m.Values,
+		IsActive:         m.IsActive,
+	}
+
+	if len(m.Id) <= 0 {
+		st.Id = primitive.NewObjectID()
+	} else {
+		oid, err := primitive.ObjectIDFromHex(m.Id)
+
+		if err != nil {
+			return nil, errors.New(errorInvalidObjectId)
+		}
+
+		st.Id = oid
 	}
 
+	var err error
+
 	if m.CreatedAt != nil {
 		if st.CreatedAt, err = ptypes.Timestamp(m.CreatedAt); err != nil {
 			return nil, err
@@ -4877,45 +5646,1487 @@ func (m *UserRole) MarshalBSON() ([]byte, error) {
 	return bson.Marshal(st)
 }
 
-func (k *UserRole) UnmarshalBSON(raw []byte) error {
-	decoded := new(MgoUserRole)
+func (m *RiskRule) UnmarshalBSON(raw []byte) error {
+	decoded := new(MgoRiskRule)
 	err := bson.Unmarshal(raw, decoded)
 
 	if err != nil {
 		return err
 	}
 
-	k.Id = decoded.Id.Hex()
-	k.Role = decoded.Role
-	k.Status = decoded.Status
-	k.Email = decoded.Email
-	k.FirstName = decoded.FirstName
-	k.LastName = decoded.LastName
+	m.Id = decoded.Id.Hex()
+	m.Name = decoded.Name
+	m.Type = decoded.Type
+	m.Score = decoded.Score
+	m.Action = decoded.Action
+	m.MaxCount = decoded.MaxCount
+	m.Period = decoded.Period
+	m.AmountMultiplier = decoded.AmountMultiplier
+	m.Values = decoded.Values
+	m.IsActive = decoded.IsActive
 
-	if decoded.MerchantId != nil {
-		k.MerchantId = decoded.MerchantId.Hex()
+	m.CreatedAt, err = ptypes.TimestampProto(decoded.CreatedAt)
+	if err != nil {
+		return err
 	}
 
-	if decoded.UserId != nil {
-		k.UserId = decoded.UserId.Hex()
+	m.UpdatedAt, err = ptypes.TimestampProto(decoded.UpdatedAt)
+	if err != nil {
+		return err
 	}
 
-	if k.CreatedAt, err = ptypes.TimestampProto(decoded.CreatedAt); err != nil {
+	return nil
+}
+
+func (m *WebhookEvent) MarshalBSON() ([]byte, error) {
+	st, err := getMgoWebhookEvent(m)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return bson.Marshal(st)
+}
+
+func (m *WebhookEvent) UnmarshalBSON(raw []byte) error {
+	decoded := new(MgoWebhookEvent)
+	err := bson.Unmarshal(raw, decoded)
+
+	if err != nil {
 		return err
 	}
 
-	if k.UpdatedAt, err = ptypes.TimestampProto(decoded.UpdatedAt); err != nil {
+	return setWebhookEvent(m, decoded)
+}
+
+func getMgoWebhookEvent(m *WebhookEvent) (*MgoWebhookEvent, error) {
+	st := &MgoWebhookEvent{
+		OrderUuid:      m.OrderUuid,
+		Type:           m.Type,
+		Payload:        m.Payload,
+		Status:         m.Status,
+		Attempts:       m.Attempts,
+		Url:            m.Url,
+		LastError:      m.LastError,
+		LastHttpStatus: m.LastHttpStatus,
+	}
+
+	if len(m.Id) <= 0 {
+		st.Id = primitive.NewObjectID()
+	} else {
+		oid, err := primitive.ObjectIDFromHex(m.Id)
+
+		if err != nil {
+			return nil, errors.New(errorInvalidObjectId)
+		}
+
+		st.Id = oid
+	}
+
+	oid, err := primitive.ObjectIDFromHex(m.MerchantId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.MerchantId = oid
+	oid, err = primitive.ObjectIDFromHex(m.ProjectId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.ProjectId = oid
+	oid, err = primitive.ObjectIDFromHex(m.OrderId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.OrderId = oid
+
+	if m.NextAttemptAt != nil {
+		if st.NextAttemptAt, err = ptypes.Timestamp(m.NextAttemptAt); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.DeliveredAt != nil {
+		if st.DeliveredAt, err = ptypes.Timestamp(m.DeliveredAt); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.CreatedAt != nil {
+		if st.CreatedAt, err = ptypes.Timestamp(m.CreatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.CreatedAt = time.Now()
+	}
+
+	if m.UpdatedAt != nil {
+		if st.UpdatedAt, err = ptypes.Timestamp(m.UpdatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.UpdatedAt = time.Now()
+	}
+
+	return st, nil
+}
+
+func setWebhookEvent(m *WebhookEvent, decoded *MgoWebhookEvent) error {
+	var err error
+
+	m.Id = decoded.Id.Hex()
+	m.MerchantId = decoded.MerchantId.Hex()
+	m.ProjectId = decoded.ProjectId.Hex()
+	m.OrderId = decoded.OrderId.Hex()
+	m.OrderUuid = decoded.OrderUuid
+	m.Type = decoded.Type
+	m.Payload = decoded.Payload
+	m.Status = decoded.Status
+	m.Attempts = decoded.Attempts
+	m.Url = decoded.Url
+	m.LastError = decoded.LastError
+	m.LastHttpStatus = decoded.LastHttpStatus
+
+	if !decoded.NextAttemptAt.IsZero() {
+		if m.NextAttemptAt, err = ptypes.TimestampProto(decoded.NextAttemptAt); err != nil {
+			return err
+		}
+	}
+
+	if !decoded.DeliveredAt.IsZero() {
+		if m.DeliveredAt, err = ptypes.TimestampProto(decoded.DeliveredAt); err != nil {
+			return err
+		}
+	}
+
+	m.CreatedAt, err = ptypes.TimestampProto(decoded.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	m.UpdatedAt, err = ptypes.TimestampProto(decoded.UpdatedAt)
+	if err != nil {
 		return err
 	}
 
 	return nil
 }
 
-type CountryAndRegionItem struct {
-	Country string `bson:"iso_code_a2"`
-	Region  string `bson:"region"`
-}
+func (m *PaymentStatusDiscrepancy) MarshalBSON() ([]byte, error) {
+	st := &MgoPaymentStatusDiscrepancy{
+		OrderUuid:             m.OrderUuid,
+		Transaction:           m.Transaction,
+		Result:                m.Result,
+		PreviousPrivateStatus: m.PreviousPrivateStatus,
+		PrivateStatus:         m.PrivateStatus,
+		ProviderResponse:      m.ProviderResponse,
+		Error:                 m.Error,
+	}
 
-type CountryAndRegionItems struct {
-	Items []*CountryAndRegionItem `json:"items"`
+	if len(m.Id) <= 0 {
+		st.Id = primitive.NewObjectID()
+	} else {
+		oid, err := primitive.ObjectIDFromHex(m.Id)
+
+		if err != nil {
+			return nil, errors.New(errorInvalidObjectId)
+		}
+
+		st.Id = oid
+	}
+
+	oid, err := primitive.ObjectIDFromHex(m.OrderId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.OrderId = oid
+	oid, err = primitive.ObjectIDFromHex(m.PaymentSystemId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.PaymentSystemId = oid
+
+	if m.CreatedAt != nil {
+		if st.CreatedAt, err = ptypes.Timestamp(m.CreatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.CreatedAt = time.Now()
+	}
+
+	return bson.Marshal(st)
+}
+
+func (m *PaymentStatusDiscrepancy) UnmarshalBSON(raw []byte) error {
+	decoded := new(MgoPaymentStatusDiscrepancy)
+	err := bson.Unmarshal(raw, decoded)
+
+	if err != nil {
+		return err
+	}
+
+	m.Id = decoded.Id.Hex()
+	m.OrderId = decoded.OrderId.Hex()
+	m.OrderUuid = decoded.OrderUuid
+	m.PaymentSystemId = decoded.PaymentSystemId.Hex()
+	m.Transaction = decoded.Transaction
+	m.Result = decoded.Result
+	m.PreviousPrivateStatus = decoded.PreviousPrivateStatus
+	m.PrivateStatus = decoded.PrivateStatus
+	m.ProviderResponse = decoded.ProviderResponse
+	m.Error = decoded.Error
+
+	m.CreatedAt, err = ptypes.TimestampProto(decoded.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *SettlementReport) MarshalBSON() ([]byte, error) {
+	st := &MgoSettlementReport{
+		FileType: m.FileType,
+		Totals:   m.Totals,
+		Items:    m.Items,
+	}
+
+	if len(m.Id) <= 0 {
+		st.Id = primitive.NewObjectID()
+	} else {
+		oid, err := primitive.ObjectIDFromHex(m.Id)
+
+		if err != nil {
+			return nil, errors.New(errorInvalidObjectId)
+		}
+
+		st.Id = oid
+	}
+
+	oid, err := primitive.ObjectIDFromHex(m.PaymentSystemId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.PaymentSystemId = oid
+
+	if m.PeriodFrom != nil {
+		if st.PeriodFrom, err = ptypes.Timestamp(m.PeriodFrom); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.PeriodTo != nil {
+		if st.PeriodTo, err = ptypes.Timestamp(m.PeriodTo); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.CreatedAt != nil {
+		if st.CreatedAt, err = ptypes.Timestamp(m.CreatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.CreatedAt = time.Now()
+	}
+
+	return bson.Marshal(st)
+}
+
+func (m *SettlementReport) UnmarshalBSON(raw []byte) error {
+	decoded := new(MgoSettlementReport)
+	err := bson.Unmarshal(raw, decoded)
+
+	if err != nil {
+		return err
+	}
+
+	m.Id = decoded.Id.Hex()
+	m.PaymentSystemId = decoded.PaymentSystemId.Hex()
+	m.FileType = decoded.FileType
+	m.Totals = decoded.Totals
+	m.Items = decoded.Items
+
+	m.PeriodFrom, err = ptypes.TimestampProto(decoded.PeriodFrom)
+	if err != nil {
+		return err
+	}
+
+	m.PeriodTo, err = ptypes.TimestampProto(decoded.PeriodTo)
+	if err != nil {
+		return err
+	}
+
+	m.CreatedAt, err = ptypes.TimestampProto(decoded.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *UserRole) MarshalBSON() ([]byte, error) {
+	oid, err := primitive.ObjectIDFromHex(m.Id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	st := &MgoUserRole{
+		Id:        oid,
+		Role:      m.Role,
+		Status:    m.Status,
+		Email:     m.Email,
+		FirstName: m.FirstName,
+		LastName:  m.LastName,
+	}
+
+	oid, err = primitive.ObjectIDFromHex(m.MerchantId)
+
+	if err == nil {
+		hex := oid
+		st.MerchantId = &hex
+	}
+
+	oid, err = primitive.ObjectIDFromHex(m.UserId)
+
+	if err == nil {
+		hex := oid
+		st.UserId = &hex
+	}
+
+	if m.CreatedAt != nil {
+		if st.CreatedAt, err = ptypes.Timestamp(m.CreatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.CreatedAt = time.Now()
+	}
+
+	if m.UpdatedAt != nil {
+		if st.UpdatedAt, err = ptypes.Timestamp(m.UpdatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.UpdatedAt = time.Now()
+	}
+
+	return bson.Marshal(st)
+}
+
+func (k *UserRole) UnmarshalBSON(raw []byte) error {
+	decoded := new(MgoUserRole)
+	err := bson.Unmarshal(raw, decoded)
+
+	if err != nil {
+		return err
+	}
+
+	k.Id = decoded.Id.Hex()
+	k.Role = decoded.Role
+	k.Status = decoded.Status
+	k.Email = decoded.Email
+	k.FirstName = decoded.FirstName
+	k.LastName = decoded.LastName
+
+	if decoded.MerchantId != nil {
+		k.MerchantId = decoded.MerchantId.Hex()
+	}
+
+	if decoded.UserId != nil {
+		k.UserId = decoded.UserId.Hex()
+	}
+
+	if k.CreatedAt, err = ptypes.TimestampProto(decoded.CreatedAt); err != nil {
+		return err
+	}
+
+	if k.UpdatedAt, err = ptypes.TimestampProto(decoded.UpdatedAt); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type CountryAndRegionItem struct {
+	Country string `bson:"iso_code_a2"`
+	Region  string `bson:"region"`
+}
+
+type CountryAndRegionItems struct {
+	Items []*CountryAndRegionItem `json:"items"`
+}
+
+func (m *SubscriptionPlan) MarshalBSON() ([]byte, error) {
+	st := &MgoSubscriptionPlan{
+		Name:          m.Name,
+		Amount:        m.Amount,
+		Currency:      m.Currency,
+		Interval:      m.Interval,
+		IntervalCount: m.IntervalCount,
+		TrialDays:     m.TrialDays,
+		IsActive:      m.IsActive,
+	}
+
+	if len(m.Id) <= 0 {
+		st.Id = primitive.NewObjectID()
+	} else {
+		oid, err := primitive.ObjectIDFromHex(m.Id)
+
+		if err != nil {
+			return nil, errors.New(errorInvalidObjectId)
+		}
+
+		st.Id = oid
+	}
+
+	oid, err := primitive.ObjectIDFromHex(m.MerchantId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.MerchantId = oid
+	oid, err = primitive.ObjectIDFromHex(m.ProjectId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.ProjectId = oid
+
+	if m.ProductId != "" {
+		oid, err = primitive.ObjectIDFromHex(m.ProductId)
+
+		if err != nil {
+			return nil, errors.New(errorInvalidObjectId)
+		}
+
+		st.ProductId = oid
+	}
+
+	if m.CreatedAt != nil {
+		if st.CreatedAt, err = ptypes.Timestamp(m.CreatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.CreatedAt = time.Now()
+	}
+
+	if m.UpdatedAt != nil {
+		if st.UpdatedAt, err = ptypes.Timestamp(m.UpdatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.UpdatedAt = time.Now()
+	}
+
+	return bson.Marshal(st)
+}
+
+func (m *SubscriptionPlan) UnmarshalBSON(raw []byte) error {
+	decoded := new(MgoSubscriptionPlan)
+	err := bson.Unmarshal(raw, decoded)
+
+	if err != nil {
+		return err
+	}
+
+	m.Id = decoded.Id.Hex()
+	m.MerchantId = decoded.MerchantId.Hex()
+	m.ProjectId = decoded.ProjectId.Hex()
+	m.Name = decoded.Name
+	m.Amount = decoded.Amount
+	m.Currency = decoded.Currency
+	m.Interval = decoded.Interval
+	m.IntervalCount = decoded.IntervalCount
+	m.TrialDays = decoded.TrialDays
+	m.IsActive = decoded.IsActive
+
+	if !decoded.ProductId.IsZero() {
+		m.ProductId = decoded.ProductId.Hex()
+	}
+
+	m.CreatedAt, err = ptypes.TimestampProto(decoded.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	m.UpdatedAt, err = ptypes.TimestampProto(decoded.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *Dispute) MarshalBSON() ([]byte, error) {
+	st := &MgoDispute{
+		OrderUuid:  m.OrderUuid,
+		RefundId:   m.RefundId,
+		ExternalId: m.ExternalId,
+		ReasonCode: m.ReasonCode,
+		Amount:     m.Amount,
+		Currency:   m.Currency,
+		Status:     m.Status,
+		Evidence:   []*MgoDisputeEvidence{},
+		History:    []*MgoDisputeStatusChange{},
+	}
+
+	if len(m.Id) <= 0 {
+		st.Id = primitive.NewObjectID()
+	} else {
+		oid, err := primitive.ObjectIDFromHex(m.Id)
+
+		if err != nil {
+			return nil, errors.New(errorInvalidObjectId)
+		}
+
+		st.Id = oid
+	}
+
+	oid, err := primitive.ObjectIDFromHex(m.OrderId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.OrderId = oid
+	oid, err = primitive.ObjectIDFromHex(m.MerchantId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.MerchantId = oid
+	oid, err = primitive.ObjectIDFromHex(m.ProjectId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.ProjectId = oid
+
+	for _, v := range m.Evidence {
+		evidence := &MgoDisputeEvidence{
+			Id:          v.Id,
+			Type:        v.Type,
+			Description: v.Description,
+			Content:     v.Content,
+			UserId:      v.UserId,
+		}
+
+		if v.CreatedAt != nil {
+			if evidence.CreatedAt, err = ptypes.Timestamp(v.CreatedAt); err != nil {
+				return nil, err
+			}
+		}
+
+		st.Evidence = append(st.Evidence, evidence)
+	}
+
+	for _, v := range m.History {
+		change := &MgoDisputeStatusChange{
+			Status:  v.Status,
+			UserId:  v.UserId,
+			Comment: v.Comment,
+		}
+
+		if v.CreatedAt != nil {
+			if change.CreatedAt, err = ptypes.Timestamp(v.CreatedAt); err != nil {
+				return nil, err
+			}
+		}
+
+		st.History = append(st.History, change)
+	}
+
+	if m.DueAt != nil {
+		if st.DueAt, err = ptypes.Timestamp(m.DueAt); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.ClosedAt != nil {
+		if st.ClosedAt, err = ptypes.Timestamp(m.ClosedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.CreatedAt != nil {
+		if st.CreatedAt, err = ptypes.Timestamp(m.CreatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.CreatedAt = time.Now()
+	}
+
+	if m.UpdatedAt != nil {
+		if st.UpdatedAt, err = ptypes.Timestamp(m.UpdatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.UpdatedAt = time.Now()
+	}
+
+	return bson.Marshal(st)
+}
+
+func (m *Dispute) UnmarshalBSON(raw []byte) error {
+	decoded := new(MgoDispute)
+	err := bson.Unmarshal(raw, decoded)
+
+	if err != nil {
+		return err
+	}
+
+	m.Id = decoded.Id.Hex()
+	m.OrderId = decoded.OrderId.Hex()
+	m.OrderUuid = decoded.OrderUuid
+	m.MerchantId = decoded.MerchantId.Hex()
+	m.ProjectId = decoded.ProjectId.Hex()
+	m.RefundId = decoded.RefundId
+	m.ExternalId = decoded.ExternalId
+	m.ReasonCode = decoded.ReasonCode
+	m.Amount = decoded.Amount
+	m.Currency = decoded.Currency
+	m.Status = decoded.Status
+
+	for _, v := range decoded.Evidence {
+		evidence := &DisputeEvidence{
+			Id:          v.Id,
+			Type:        v.Type,
+			Description: v.Description,
+			Content:     v.Content,
+			UserId:      v.UserId,
+		}
+
+		if evidence.CreatedAt, err = ptypes.TimestampProto(v.CreatedAt); err != nil {
+			return err
+		}
+
+		m.Evidence = append(m.Evidence, evidence)
+	}
+
+	for _, v := range decoded.History {
+		change := &DisputeStatusChange{
+			Status:  v.Status,
+			UserId:  v.UserId,
+			Comment: v.Comment,
+		}
+
+		if change.CreatedAt, err = ptypes.TimestampProto(v.CreatedAt); err != nil {
+			return err
+		}
+
+		m.History = append(m.History, change)
+	}
+
+	if !decoded.DueAt.IsZero() {
+		if m.DueAt, err = ptypes.TimestampProto(decoded.DueAt); err != nil {
+			return err
+		}
+	}
+
+	if !decoded.ClosedAt.IsZero() {
+		if m.ClosedAt, err = ptypes.TimestampProto(decoded.ClosedAt); err != nil {
+			return err
+		}
+	}
+
+	m.CreatedAt, err = ptypes.TimestampProto(decoded.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	m.UpdatedAt, err = ptypes.TimestampProto(decoded.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *RefundApprovalThreshold) MarshalBSON() ([]byte, error) {
+	st := &MgoRefundApprovalThreshold{
+		Amount:   m.Amount,
+		Currency: m.Currency,
+	}
+
+	if len(m.Id) <= 0 {
+		st.Id = primitive.NewObjectID()
+	} else {
+		oid, err := primitive.ObjectIDFromHex(m.Id)
+
+		if err != nil {
+			return nil, errors.New(errorInvalidObjectId)
+		}
+
+		st.Id = oid
+	}
+
+	oid, err := primitive.ObjectIDFromHex(m.MerchantId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.MerchantId = oid
+
+	if m.ProjectId != "" {
+		oid, err = primitive.ObjectIDFromHex(m.ProjectId)
+
+		if err != nil {
+			return nil, errors.New(errorInvalidObjectId)
+		}
+
+		st.ProjectId = oid
+	}
+
+	if m.CreatedAt != nil {
+		if st.CreatedAt, err = ptypes.Timestamp(m.CreatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.CreatedAt = time.Now()
+	}
+
+	if m.UpdatedAt != nil {
+		if st.UpdatedAt, err = ptypes.Timestamp(m.UpdatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.UpdatedAt = time.Now()
+	}
+
+	return bson.Marshal(st)
+}
+
+func (m *RefundApprovalThreshold) UnmarshalBSON(raw []byte) error {
+	decoded := new(MgoRefundApprovalThreshold)
+	err := bson.Unmarshal(raw, decoded)
+
+	if err != nil {
+		return err
+	}
+
+	m.Id = decoded.Id.Hex()
+	m.MerchantId = decoded.MerchantId.Hex()
+	m.Amount = decoded.Amount
+	m.Currency = decoded.Currency
+
+	if !decoded.ProjectId.IsZero() {
+		m.ProjectId = decoded.ProjectId.Hex()
+	}
+
+	m.CreatedAt, err = ptypes.TimestampProto(decoded.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	m.UpdatedAt, err = ptypes.TimestampProto(decoded.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *Subscription) MarshalBSON() ([]byte, error) {
+	st := &MgoSubscription{
+		User:              m.User,
+		PaymentMethodId:   m.PaymentMethodId,
+		StoredCardId:      m.StoredCardId,
+		Status:            m.Status,
+		CancelAtPeriodEnd: m.CancelAtPeriodEnd,
+		ProrationAmount:   m.ProrationAmount,
+		RenewalOrderId:    m.RenewalOrderId,
+		FailedAttempts:    m.FailedAttempts,
+		SourceOrderId:     m.SourceOrderId,
+	}
+
+	if len(m.Id) <= 0 {
+		st.Id = primitive.NewObjectID()
+	} else {
+		oid, err := primitive.ObjectIDFromHex(m.Id)
+
+		if err != nil {
+			return nil, errors.New(errorInvalidObjectId)
+		}
+
+		st.Id = oid
+	}
+
+	oid, err := primitive.ObjectIDFromHex(m.PlanId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.PlanId = oid
+	oid, err = primitive.ObjectIDFromHex(m.MerchantId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.MerchantId = oid
+	oid, err = primitive.ObjectIDFromHex(m.ProjectId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.ProjectId = oid
+
+	if m.CurrentPeriodStart != nil {
+		if st.CurrentPeriodStart, err = ptypes.Timestamp(m.CurrentPeriodStart); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.CurrentPeriodEnd != nil {
+		if st.CurrentPeriodEnd, err = ptypes.Timestamp(m.CurrentPeriodEnd); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.CanceledAt != nil {
+		if st.CanceledAt, err = ptypes.Timestamp(m.CanceledAt); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.NextChargeAt != nil {
+		if st.NextChargeAt, err = ptypes.Timestamp(m.NextChargeAt); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.CreatedAt != nil {
+		if st.CreatedAt, err = ptypes.Timestamp(m.CreatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.CreatedAt = time.Now()
+	}
+
+	if m.UpdatedAt != nil {
+		if st.UpdatedAt, err = ptypes.Timestamp(m.UpdatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.UpdatedAt = time.Now()
+	}
+
+	return bson.Marshal(st)
+}
+
+func (m *Subscription) UnmarshalBSON(raw []byte) error {
+	decoded := new(MgoSubscription)
+	err := bson.Unmarshal(raw, decoded)
+
+	if err != nil {
+		return err
+	}
+
+	m.Id = decoded.Id.Hex()
+	m.PlanId = decoded.PlanId.Hex()
+	m.MerchantId = decoded.MerchantId.Hex()
+	m.ProjectId = decoded.ProjectId.Hex()
+	m.User = decoded.User
+	m.PaymentMethodId = decoded.PaymentMethodId
+	m.StoredCardId = decoded.StoredCardId
+	m.Status = decoded.Status
+	m.CancelAtPeriodEnd = decoded.CancelAtPeriodEnd
+	m.ProrationAmount = decoded.ProrationAmount
+	m.RenewalOrderId = decoded.RenewalOrderId
+	m.FailedAttempts = decoded.FailedAttempts
+	m.SourceOrderId = decoded.SourceOrderId
+
+	if !decoded.CurrentPeriodStart.IsZero() {
+		if m.CurrentPeriodStart, err = ptypes.TimestampProto(decoded.CurrentPeriodStart); err != nil {
+			return err
+		}
+	}
+
+	if !decoded.CurrentPeriodEnd.IsZero() {
+		if m.CurrentPeriodEnd, err = ptypes.TimestampProto(decoded.CurrentPeriodEnd); err != nil {
+			return err
+		}
+	}
+
+	if !decoded.CanceledAt.IsZero() {
+		if m.CanceledAt, err = ptypes.TimestampProto(decoded.CanceledAt); err != nil {
+			return err
+		}
+	}
+
+	if !decoded.NextChargeAt.IsZero() {
+		if m.NextChargeAt, err = ptypes.TimestampProto(decoded.NextChargeAt); err != nil {
+			return err
+		}
+	}
+
+	m.CreatedAt, err = ptypes.TimestampProto(decoded.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	m.UpdatedAt, err = ptypes.TimestampProto(decoded.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *BulkRefundJob) MarshalBSON() ([]byte, error) {
+	st := &MgoBulkRefundJob{
+		CreatorId: m.CreatorId,
+		Reason:    m.Reason,
+		Status:    m.Status,
+		Items:     []*MgoBulkRefundJobItem{},
+		Total:     m.Total,
+		Processed: m.Processed,
+		Succeeded: m.Succeeded,
+		Failed:    m.Failed,
+	}
+
+	if len(m.Id) <= 0 {
+		st.Id = primitive.NewObjectID()
+	} else {
+		oid, err := primitive.ObjectIDFromHex(m.Id)
+
+		if err != nil {
+			return nil, errors.New(errorInvalidObjectId)
+		}
+
+		st.Id = oid
+	}
+
+	oid, err := primitive.ObjectIDFromHex(m.MerchantId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.MerchantId = oid
+
+	for _, v := range m.Items {
+		item := &MgoBulkRefundJobItem{
+			OrderId:  v.OrderId,
+			Status:   v.Status,
+			RefundId: v.RefundId,
+			Amount:   v.Amount,
+			Currency: v.Currency,
+			Error:    v.Error,
+		}
+
+		if v.ProcessedAt != nil {
+			if item.ProcessedAt, err = ptypes.Timestamp(v.ProcessedAt); err != nil {
+				return nil, err
+			}
+		}
+
+		st.Items = append(st.Items, item)
+	}
+
+	if m.LockedUntil != nil {
+		if st.LockedUntil, err = ptypes.Timestamp(m.LockedUntil); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.CompletedAt != nil {
+		if st.CompletedAt, err = ptypes.Timestamp(m.CompletedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.CreatedAt != nil {
+		if st.CreatedAt, err = ptypes.Timestamp(m.CreatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.CreatedAt = time.Now()
+	}
+
+	if m.UpdatedAt != nil {
+		if st.UpdatedAt, err = ptypes.Timestamp(m.UpdatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.UpdatedAt = time.Now()
+	}
+
+	return bson.Marshal(st)
+}
+
+func (m *BulkRefundJob) UnmarshalBSON(raw []byte) error {
+	decoded := new(MgoBulkRefundJob)
+	err := bson.Unmarshal(raw, decoded)
+
+	if err != nil {
+		return err
+	}
+
+	m.Id = decoded.Id.Hex()
+	m.MerchantId = decoded.MerchantId.Hex()
+	m.CreatorId = decoded.CreatorId
+	m.Reason = decoded.Reason
+	m.Status = decoded.Status
+	m.Total = decoded.Total
+	m.Processed = decoded.Processed
+	m.Succeeded = decoded.Succeeded
+	m.Failed = decoded.Failed
+
+	for _, v := range decoded.Items {
+		item := &BulkRefundJobItem{
+			OrderId:  v.OrderId,
+			Status:   v.Status,
+			RefundId: v.RefundId,
+			Amount:   v.Amount,
+			Currency: v.Currency,
+			Error:    v.Error,
+		}
+
+		if !v.ProcessedAt.IsZero() {
+			if item.ProcessedAt, err = ptypes.TimestampProto(v.ProcessedAt); err != nil {
+				return err
+			}
+		}
+
+		m.Items = append(m.Items, item)
+	}
+
+	if !decoded.LockedUntil.IsZero() {
+		if m.LockedUntil, err = ptypes.TimestampProto(decoded.LockedUntil); err != nil {
+			return err
+		}
+	}
+
+	if !decoded.CompletedAt.IsZero() {
+		if m.CompletedAt, err = ptypes.TimestampProto(decoded.CompletedAt); err != nil {
+			return err
+		}
+	}
+
+	m.CreatedAt, err = ptypes.TimestampProto(decoded.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	m.UpdatedAt, err = ptypes.TimestampProto(decoded.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *Coupon) MarshalBSON() ([]byte, error) {
+	st := &MgoCoupon{
+		Code:               m.Code,
+		DiscountType:       m.DiscountType,
+		Percent:            m.Percent,
+		Amounts:            m.Amounts,
+		MinOrderAmounts:    m.MinOrderAmounts,
+		Currencies:         m.Currencies,
+		MaxUses:            m.MaxUses,
+		MaxUsesPerCustomer: m.MaxUsesPerCustomer,
+		UsedCount:          m.UsedCount,
+		IsActive:           m.IsActive,
+	}
+
+	if len(m.Id) <= 0 {
+		st.Id = primitive.NewObjectID()
+	} else {
+		oid, err := primitive.ObjectIDFromHex(m.Id)
+
+		if err != nil {
+			return nil, errors.New(errorInvalidObjectId)
+		}
+
+		st.Id = oid
+	}
+
+	oid, err := primitive.ObjectIDFromHex(m.MerchantId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.MerchantId = oid
+	oid, err = primitive.ObjectIDFromHex(m.ProjectId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.ProjectId = oid
+
+	for _, v := range m.ProductIds {
+		oid, err = primitive.ObjectIDFromHex(v)
+
+		if err != nil {
+			return nil, errors.New(errorInvalidObjectId)
+		}
+
+		st.ProductIds = append(st.ProductIds, oid)
+	}
+
+	if m.StartsAt != nil {
+		if st.StartsAt, err = ptypes.Timestamp(m.StartsAt); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.ExpiresAt != nil {
+		if st.ExpiresAt, err = ptypes.Timestamp(m.ExpiresAt); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.CreatedAt != nil {
+		if st.CreatedAt, err = ptypes.Timestamp(m.CreatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.CreatedAt = time.Now()
+	}
+
+	if m.UpdatedAt != nil {
+		if st.UpdatedAt, err = ptypes.Timestamp(m.UpdatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.UpdatedAt = time.Now()
+	}
+
+	return bson.Marshal(st)
+}
+
+func (m *Coupon) UnmarshalBSON(raw []byte) error {
+	decoded := new(MgoCoupon)
+	err := bson.Unmarshal(raw, decoded)
+
+	if err != nil {
+		return err
+	}
+
+	m.Id = decoded.Id.Hex()
+	m.MerchantId = decoded.MerchantId.Hex()
+	m.ProjectId = decoded.ProjectId.Hex()
+	m.Code = decoded.Code
+	m.DiscountType = decoded.DiscountType
+	m.Percent = decoded.Percent
+	m.Amounts = decoded.Amounts
+	m.MinOrderAmounts = decoded.MinOrderAmounts
+	m.Currencies = decoded.Currencies
+	m.MaxUses = decoded.MaxUses
+	m.MaxUsesPerCustomer = decoded.MaxUsesPerCustomer
+	m.UsedCount = decoded.UsedCount
+	m.IsActive = decoded.IsActive
+
+	for _, v := range decoded.ProductIds {
+		m.ProductIds = append(m.ProductIds, v.Hex())
+	}
+
+	if !decoded.StartsAt.IsZero() {
+		if m.StartsAt, err = ptypes.TimestampProto(decoded.StartsAt); err != nil {
+			return err
+		}
+	}
+
+	if !decoded.ExpiresAt.IsZero() {
+		if m.ExpiresAt, err = ptypes.TimestampProto(decoded.ExpiresAt); err != nil {
+			return err
+		}
+	}
+
+	m.CreatedAt, err = ptypes.TimestampProto(decoded.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	m.UpdatedAt, err = ptypes.TimestampProto(decoded.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *StoreCredit) MarshalBSON() ([]byte, error) {
+	st := &MgoStoreCredit{
+		Currency: m.Currency,
+		Balance:  m.Balance,
+	}
+
+	if len(m.Id) <= 0 {
+		st.Id = primitive.NewObjectID()
+	} else {
+		oid, err := primitive.ObjectIDFromHex(m.Id)
+
+		if err != nil {
+			return nil, errors.New(errorInvalidObjectId)
+		}
+
+		st.Id = oid
+	}
+
+	oid, err := primitive.ObjectIDFromHex(m.MerchantId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.MerchantId = oid
+	oid, err = primitive.ObjectIDFromHex(m.CustomerId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.CustomerId = oid
+
+	if m.CreatedAt != nil {
+		if st.CreatedAt, err = ptypes.Timestamp(m.CreatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.CreatedAt = time.Now()
+	}
+
+	if m.UpdatedAt != nil {
+		if st.UpdatedAt, err = ptypes.Timestamp(m.UpdatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.UpdatedAt = time.Now()
+	}
+
+	return bson.Marshal(st)
+}
+
+func (m *StoreCredit) UnmarshalBSON(raw []byte) error {
+	decoded := new(MgoStoreCredit)
+	err := bson.Unmarshal(raw, decoded)
+
+	if err != nil {
+		return err
+	}
+
+	m.Id = decoded.Id.Hex()
+	m.MerchantId = decoded.MerchantId.Hex()
+	m.CustomerId = decoded.CustomerId.Hex()
+	m.Currency = decoded.Currency
+	m.Balance = decoded.Balance
+
+	m.CreatedAt, err = ptypes.TimestampProto(decoded.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	m.UpdatedAt, err = ptypes.TimestampProto(decoded.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *StoreCreditTransaction) MarshalBSON() ([]byte, error) {
+	st := &MgoStoreCreditTransaction{
+		Type:     m.Type,
+		Amount:   m.Amount,
+		Currency: m.Currency,
+		Reason:   m.Reason,
+	}
+
+	if len(m.Id) <= 0 {
+		st.Id = primitive.NewObjectID()
+	} else {
+		oid, err := primitive.ObjectIDFromHex(m.Id)
+
+		if err != nil {
+			return nil, errors.New(errorInvalidObjectId)
+		}
+
+		st.Id = oid
+	}
+
+	oid, err := primitive.ObjectIDFromHex(m.MerchantId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.MerchantId = oid
+	oid, err = primitive.ObjectIDFromHex(m.CustomerId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.CustomerId = oid
+
+	if m.OrderId != "" {
+		if st.OrderId, err = primitive.ObjectIDFromHex(m.OrderId); err != nil {
+			return nil, errors.New(errorInvalidObjectId)
+		}
+	}
+
+	if m.RefundId != "" {
+		if st.RefundId, err = primitive.ObjectIDFromHex(m.RefundId); err != nil {
+			return nil, errors.New(errorInvalidObjectId)
+		}
+	}
+
+	if m.CreatedAt != nil {
+		if st.CreatedAt, err = ptypes.Timestamp(m.CreatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.CreatedAt = time.Now()
+	}
+
+	return bson.Marshal(st)
+}
+
+func (m *StoreCreditTransaction) UnmarshalBSON(raw []byte) error {
+	decoded := new(MgoStoreCreditTransaction)
+	err := bson.Unmarshal(raw, decoded)
+
+	if err != nil {
+		return err
+	}
+
+	m.Id = decoded.Id.Hex()
+	m.MerchantId = decoded.MerchantId.Hex()
+	m.CustomerId = decoded.CustomerId.Hex()
+	m.Type = decoded.Type
+	m.Amount = decoded.Amount
+	m.Currency = decoded.Currency
+	m.Reason = decoded.Reason
+
+	if !decoded.OrderId.IsZero() {
+		m.OrderId = decoded.OrderId.Hex()
+	}
+
+	if !decoded.RefundId.IsZero() {
+		m.RefundId = decoded.RefundId.Hex()
+	}
+
+	m.CreatedAt, err = ptypes.TimestampProto(decoded.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *LedgerPosting) MarshalBSON() ([]byte, error) {
+	st := &MgoLedgerPosting{
+		EntryType:          m.EntryType,
+		OperatingCompanyId: m.OperatingCompanyId,
+		Account:            m.Account,
+		Debit:              m.Debit,
+		Credit:             m.Credit,
+		Currency:           m.Currency,
+	}
+
+	if len(m.Id) <= 0 {
+		st.Id = primitive.NewObjectID()
+	} else {
+		oid, err := primitive.ObjectIDFromHex(m.Id)
+
+		if err != nil {
+			return nil, errors.New(errorInvalidObjectId)
+		}
+
+		st.Id = oid
+	}
+
+	oid, err := primitive.ObjectIDFromHex(m.EntryId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.EntryId = oid
+	oid, err = primitive.ObjectIDFromHex(m.MerchantId)
+
+	if err != nil {
+		return nil, errors.New(errorInvalidObjectId)
+	}
+
+	st.MerchantId = oid
+
+	if m.Source != nil {
+		oid, err = primitive.ObjectIDFromHex(m.Source.Id)
+
+		if err != nil {
+			return nil, errors.New(errorInvalidObjectId)
+		}
+
+		st.Source = &MgoAccountingEntrySource{
+			Id:   oid,
+			Type: m.Source.Type,
+		}
+	}
+
+	if m.CreatedAt != nil {
+		if st.CreatedAt, err = ptypes.Timestamp(m.CreatedAt); err != nil {
+			return nil, err
+		}
+	} else {
+		st.CreatedAt = time.Now()
+	}
+
+	return bson.Marshal(st)
+}
+
+func (m *LedgerPosting) UnmarshalBSON(raw []byte) error {
+	decoded := new(MgoLedgerPosting)
+	err := bson.Unmarshal(raw, decoded)
+
+	if err != nil {
+		return err
+	}
+
+	m.Id = decoded.Id.Hex()
+	m.EntryId = decoded.EntryId.Hex()
+	m.EntryType = decoded.EntryType
+	m.MerchantId = decoded.MerchantId.Hex()
+	m.OperatingCompanyId = decoded.OperatingCompanyId
+	m.Account = decoded.Account
+	m.Debit = decoded.Debit
+	m.Credit = decoded.Credit
+	m.Currency = decoded.Currency
+
+	if decoded.Source != nil {
+		m.Source = &AccountingEntrySource{
+			Id:   decoded.Source.Id.Hex(),
+			Type: decoded.Source.Type,
+		}
+	}
+
+	m.CreatedAt, err = ptypes.TimestampProto(decoded.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
 }
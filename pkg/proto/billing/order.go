@@ -130,6 +130,61 @@ func (m *Order) RefundAllowed() bool {
 	return ok && v == true
 }
 
+// IsPaymentAuthorizeOnly checks that payment of order must be only authorized in payment system
+// and captured or voided later. Authorization is supported for bank cards only.
+func (m *Order) IsPaymentAuthorizeOnly() bool {
+	return m.IsPreAuthorization && m.PaymentMethod != nil && m.PaymentMethod.IsBankCard()
+}
+
+// IsRiskReviewPending checks that payment of order is held until decision of risk manager.
+func (m *Order) IsRiskReviewPending() bool {
+	return m.Risk != nil && m.Risk.ReviewStatus == pkg.RiskReviewStatusPending
+}
+
+func (m *Order) IsPaymentAuthorized() bool {
+	return m.PrivateStatus == pkg.OrderStatusPaymentSystemAuthorized
+}
+
+// IsPaidByStoreCredit returns true if order is paid with store credit of customer instead of external payment system
+func (m *Order) IsPaidByStoreCredit() bool {
+	return m.PaymentMethod != nil && m.PaymentMethod.Handler == pkg.PaymentSystemHandlerStoreCredit
+}
+
+func (m *Order) CanBeCaptured() bool {
+	return m.IsPaymentAuthorized() && m.CapturedAmount <= 0 && m.VoidedAt == nil
+}
+
+func (m *Order) CanBeVoided() bool {
+	return m.CanBeCaptured()
+}
+
+// GetPaymentNotificationAmount returns amount which payment system must send in notification about payment.
+// For captured payment authorization it's the captured amount and charge amount of order otherwise.
+func (m *Order) GetPaymentNotificationAmount() float64 {
+	if m.CapturedAmount > 0 {
+		return m.CapturedAmount
+	}
+
+	return m.ChargeAmount
+}
+
+// ApplyCapturedAmount changes amounts of order proportionally to the partially captured payment authorization.
+func (m *Order) ApplyCapturedAmount() {
+	if m.CapturedAmount <= 0 || m.CapturedAmount >= m.ChargeAmount {
+		return
+	}
+
+	ratio := m.CapturedAmount / m.ChargeAmount
+
+	m.OrderAmount = tools.FormatAmount(m.OrderAmount * ratio)
+	m.TotalPaymentAmount = tools.FormatAmount(m.TotalPaymentAmount * ratio)
+	m.ChargeAmount = m.CapturedAmount
+
+	if m.Tax != nil {
+		m.Tax.Amount = tools.FormatAmount(m.Tax.Amount * ratio)
+	}
+}
+
 func (m *Order) FormInputTimeIsEnded() bool {
 	t, err := ptypes.Timestamp(m.ExpireDateToFormInput)
 
@@ -197,6 +197,15 @@ func (p *KeyProduct) GetLocalizedLongDescription(lang string) (string, error) {
 	return v, nil
 }
 
+func (p *KeyProduct) IsLowStockNotified(platformId string) bool {
+	for _, v := range p.LowStockNotifiedPlatforms {
+		if v == platformId {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *KeyProduct) GetPriceInCurrencyAndPlatform(group *billing.PriceGroup, platformId string) (float64, error) {
 	// todo: add support for virtual currencies here?
 	// note: virtual currency has IsVirtualCurrency=true && Currency=""
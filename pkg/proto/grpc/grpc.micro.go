@@ -56,6 +56,7 @@ type BillingService interface {
 	GetMerchantTariffRates(ctx context.Context, in *GetMerchantTariffRatesRequest, opts ...client.CallOption) (*GetMerchantTariffRatesResponse, error)
 	SetMerchantTariffRates(ctx context.Context, in *SetMerchantTariffRatesRequest, opts ...client.CallOption) (*CheckProjectRequestSignatureResponse, error)
 	ChangeMerchantManualPayouts(ctx context.Context, in *ChangeMerchantManualPayoutsRequest, opts ...client.CallOption) (*ChangeMerchantManualPayoutsResponse, error)
+	ChangeMerchantRoyaltyReportPeriod(ctx context.Context, in *ChangeMerchantRoyaltyReportPeriodRequest, opts ...client.CallOption) (*ChangeMerchantManualPayoutsResponse, error)
 	CreateNotification(ctx context.Context, in *NotificationRequest, opts ...client.CallOption) (*CreateNotificationResponse, error)
 	GetNotification(ctx context.Context, in *GetNotificationRequest, opts ...client.CallOption) (*billing.Notification, error)
 	ListNotifications(ctx context.Context, in *ListingNotificationRequest, opts ...client.CallOption) (*Notifications, error)
@@ -67,9 +68,13 @@ type BillingService interface {
 	ListRefunds(ctx context.Context, in *ListRefundsRequest, opts ...client.CallOption) (*ListRefundsResponse, error)
 	GetRefund(ctx context.Context, in *GetRefundRequest, opts ...client.CallOption) (*CreateRefundResponse, error)
 	ProcessRefundCallback(ctx context.Context, in *CallbackRequest, opts ...client.CallOption) (*PaymentNotifyResponse, error)
+	CapturePayment(ctx context.Context, in *CapturePaymentRequest, opts ...client.CallOption) (*CapturePaymentResponse, error)
+	VoidPayment(ctx context.Context, in *VoidPaymentRequest, opts ...client.CallOption) (*VoidPaymentResponse, error)
 	PaymentFormLanguageChanged(ctx context.Context, in *PaymentFormUserChangeLangRequest, opts ...client.CallOption) (*PaymentFormDataChangeResponse, error)
 	PaymentFormPaymentAccountChanged(ctx context.Context, in *PaymentFormUserChangePaymentAccountRequest, opts ...client.CallOption) (*PaymentFormDataChangeResponse, error)
 	ProcessBillingAddress(ctx context.Context, in *ProcessBillingAddressRequest, opts ...client.CallOption) (*ProcessBillingAddressResponse, error)
+	ApplyOrderCoupon(ctx context.Context, in *ApplyOrderCouponRequest, opts ...client.CallOption) (*ApplyOrderCouponResponse, error)
+	SetOrderGift(ctx context.Context, in *SetOrderGiftRequest, opts ...client.CallOption) (*SetOrderGiftResponse, error)
 	CreateOrUpdateProduct(ctx context.Context, in *Product, opts ...client.CallOption) (*Product, error)
 	ListProducts(ctx context.Context, in *ListProductsRequest, opts ...client.CallOption) (*ListProductsResponse, error)
 	GetProduct(ctx context.Context, in *RequestProduct, opts ...client.CallOption) (*GetProductResponse, error)
@@ -140,6 +145,10 @@ type BillingService interface {
 	ListRoyaltyReportOrders(ctx context.Context, in *ListRoyaltyReportOrdersRequest, opts ...client.CallOption) (*TransactionsResponse, error)
 	MerchantReviewRoyaltyReport(ctx context.Context, in *MerchantReviewRoyaltyReportRequest, opts ...client.CallOption) (*ResponseError, error)
 	AutoAcceptRoyaltyReports(ctx context.Context, in *EmptyRequest, opts ...client.CallOption) (*EmptyResponse, error)
+	OpenRoyaltyReportDispute(ctx context.Context, in *OpenRoyaltyReportDisputeRequest, opts ...client.CallOption) (*GetRoyaltyReportResponse, error)
+	AddRoyaltyReportDisputeMessage(ctx context.Context, in *AddRoyaltyReportDisputeMessageRequest, opts ...client.CallOption) (*GetRoyaltyReportResponse, error)
+	ApplyRoyaltyReportDisputeCorrections(ctx context.Context, in *ApplyRoyaltyReportDisputeCorrectionsRequest, opts ...client.CallOption) (*GetRoyaltyReportResponse, error)
+	AutoEscalateRoyaltyReportDisputes(ctx context.Context, in *EmptyRequest, opts ...client.CallOption) (*EmptyResponse, error)
 	RoyaltyReportPdfUploaded(ctx context.Context, in *RoyaltyReportPdfUploadedRequest, opts ...client.CallOption) (*RoyaltyReportPdfUploadedResponse, error)
 	GetVatReportsDashboard(ctx context.Context, in *EmptyRequest, opts ...client.CallOption) (*VatReportsResponse, error)
 	GetVatReportsForCountry(ctx context.Context, in *VatReportsRequest, opts ...client.CallOption) (*VatReportsResponse, error)
@@ -194,6 +203,43 @@ type BillingService interface {
 	GetOperatingCompany(ctx context.Context, in *GetOperatingCompanyRequest, opts ...client.CallOption) (*GetOperatingCompanyResponse, error)
 	GetPaymentMinLimitsSystem(ctx context.Context, in *EmptyRequest, opts ...client.CallOption) (*GetPaymentMinLimitsSystemResponse, error)
 	SetPaymentMinLimitSystem(ctx context.Context, in *billing.PaymentMinLimitSystem, opts ...client.CallOption) (*EmptyResponseWithStatus, error)
+	GetPaymentRoutingRules(ctx context.Context, in *GetPaymentRoutingRulesRequest, opts ...client.CallOption) (*GetPaymentRoutingRulesResponse, error)
+	SetPaymentRoutingRule(ctx context.Context, in *billing.PaymentRoutingRule, opts ...client.CallOption) (*SetPaymentRoutingRuleResponse, error)
+	DeletePaymentRoutingRule(ctx context.Context, in *DeletePaymentRoutingRuleRequest, opts ...client.CallOption) (*EmptyResponseWithStatus, error)
+	GetRiskRules(ctx context.Context, in *GetRiskRulesRequest, opts ...client.CallOption) (*GetRiskRulesResponse, error)
+	SetRiskRule(ctx context.Context, in *SetRiskRuleRequest, opts ...client.CallOption) (*SetRiskRuleResponse, error)
+	DeleteRiskRule(ctx context.Context, in *DeleteRiskRuleRequest, opts ...client.CallOption) (*EmptyResponseWithStatus, error)
+	ReviewOrderRisk(ctx context.Context, in *ReviewOrderRiskRequest, opts ...client.CallOption) (*ReviewOrderRiskResponse, error)
+	SetSubscriptionPlan(ctx context.Context, in *billing.SubscriptionPlan, opts ...client.CallOption) (*SubscriptionPlanResponse, error)
+	GetSubscriptionPlans(ctx context.Context, in *GetSubscriptionPlansRequest, opts ...client.CallOption) (*GetSubscriptionPlansResponse, error)
+	CreateSubscription(ctx context.Context, in *CreateSubscriptionRequest, opts ...client.CallOption) (*SubscriptionResponse, error)
+	GetSubscription(ctx context.Context, in *GetSubscriptionRequest, opts ...client.CallOption) (*SubscriptionResponse, error)
+	ChangeSubscriptionPlan(ctx context.Context, in *ChangeSubscriptionPlanRequest, opts ...client.CallOption) (*SubscriptionResponse, error)
+	CancelSubscription(ctx context.Context, in *CancelSubscriptionRequest, opts ...client.CallOption) (*SubscriptionResponse, error)
+	SetRefundApprovalThreshold(ctx context.Context, in *SetRefundApprovalThresholdRequest, opts ...client.CallOption) (*RefundApprovalThresholdResponse, error)
+	GetRefundApprovalThresholds(ctx context.Context, in *GetRefundApprovalThresholdsRequest, opts ...client.CallOption) (*GetRefundApprovalThresholdsResponse, error)
+	DeleteRefundApprovalThreshold(ctx context.Context, in *DeleteRefundApprovalThresholdRequest, opts ...client.CallOption) (*EmptyResponseWithStatus, error)
+	ApproveRefund(ctx context.Context, in *RefundDecisionRequest, opts ...client.CallOption) (*CreateRefundResponse, error)
+	RejectRefund(ctx context.Context, in *RefundDecisionRequest, opts ...client.CallOption) (*CreateRefundResponse, error)
+	OpenDispute(ctx context.Context, in *OpenDisputeRequest, opts ...client.CallOption) (*DisputeResponse, error)
+	ChangeDisputeStatus(ctx context.Context, in *ChangeDisputeStatusRequest, opts ...client.CallOption) (*DisputeResponse, error)
+	AddDisputeEvidence(ctx context.Context, in *AddDisputeEvidenceRequest, opts ...client.CallOption) (*DisputeResponse, error)
+	GetDispute(ctx context.Context, in *GetDisputeRequest, opts ...client.CallOption) (*DisputeResponse, error)
+	ListDisputes(ctx context.Context, in *ListDisputesRequest, opts ...client.CallOption) (*ListDisputesResponse, error)
+	CreateBulkRefund(ctx context.Context, in *CreateBulkRefundRequest, opts ...client.CallOption) (*BulkRefundJobResponse, error)
+	GetBulkRefundJob(ctx context.Context, in *GetBulkRefundJobRequest, opts ...client.CallOption) (*BulkRefundJobResponse, error)
+	SetCoupon(ctx context.Context, in *billing.Coupon, opts ...client.CallOption) (*CouponResponse, error)
+	GetCoupons(ctx context.Context, in *GetCouponsRequest, opts ...client.CallOption) (*GetCouponsResponse, error)
+	IssueStoreCredit(ctx context.Context, in *IssueStoreCreditRequest, opts ...client.CallOption) (*StoreCreditResponse, error)
+	GetStoreCredits(ctx context.Context, in *GetStoreCreditsRequest, opts ...client.CallOption) (*GetStoreCreditsResponse, error)
+	GetLedgerTrialBalance(ctx context.Context, in *GetLedgerTrialBalanceRequest, opts ...client.CallOption) (*GetLedgerTrialBalanceResponse, error)
+	CheckLedgerBalance(ctx context.Context, in *CheckLedgerBalanceRequest, opts ...client.CallOption) (*CheckLedgerBalanceResponse, error)
+	ExportAccountingEntries(ctx context.Context, in *ExportAccountingEntriesRequest, opts ...client.CallOption) (*ExportAccountingEntriesResponse, error)
+	ListFailedWebhooks(ctx context.Context, in *ListFailedWebhooksRequest, opts ...client.CallOption) (*ListFailedWebhooksResponse, error)
+	RedeliverWebhook(ctx context.Context, in *RedeliverWebhookRequest, opts ...client.CallOption) (*RedeliverWebhookResponse, error)
+	ImportSettlementFile(ctx context.Context, in *ImportSettlementFileRequest, opts ...client.CallOption) (*ImportSettlementFileResponse, error)
+	ListSettlementReports(ctx context.Context, in *ListSettlementReportsRequest, opts ...client.CallOption) (*ListSettlementReportsResponse, error)
+	GetSettlementReportFile(ctx context.Context, in *GetSettlementReportFileRequest, opts ...client.CallOption) (*GetSettlementReportFileResponse, error)
 	GetMerchantUsers(ctx context.Context, in *GetMerchantUsersRequest, opts ...client.CallOption) (*GetMerchantUsersResponse, error)
 	GetAdminUsers(ctx context.Context, in *EmptyRequest, opts ...client.CallOption) (*GetAdminUsersResponse, error)
 	InviteUserMerchant(ctx context.Context, in *InviteUserMerchantRequest, opts ...client.CallOption) (*InviteUserMerchantResponse, error)
@@ -421,6 +467,16 @@ func (c *billingService) ChangeMerchantManualPayouts(ctx context.Context, in *Ch
 	return out, nil
 }
 
+func (c *billingService) ChangeMerchantRoyaltyReportPeriod(ctx context.Context, in *ChangeMerchantRoyaltyReportPeriodRequest, opts ...client.CallOption) (*ChangeMerchantManualPayoutsResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.ChangeMerchantRoyaltyReportPeriod", in)
+	out := new(ChangeMerchantManualPayoutsResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *billingService) CreateNotification(ctx context.Context, in *NotificationRequest, opts ...client.CallOption) (*CreateNotificationResponse, error) {
 	req := c.c.NewRequest(c.name, "BillingService.CreateNotification", in)
 	out := new(CreateNotificationResponse)
@@ -531,6 +587,26 @@ func (c *billingService) ProcessRefundCallback(ctx context.Context, in *Callback
 	return out, nil
 }
 
+func (c *billingService) CapturePayment(ctx context.Context, in *CapturePaymentRequest, opts ...client.CallOption) (*CapturePaymentResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.CapturePayment", in)
+	out := new(CapturePaymentResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) VoidPayment(ctx context.Context, in *VoidPaymentRequest, opts ...client.CallOption) (*VoidPaymentResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.VoidPayment", in)
+	out := new(VoidPaymentResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *billingService) PaymentFormLanguageChanged(ctx context.Context, in *PaymentFormUserChangeLangRequest, opts ...client.CallOption) (*PaymentFormDataChangeResponse, error) {
 	req := c.c.NewRequest(c.name, "BillingService.PaymentFormLanguageChanged", in)
 	out := new(PaymentFormDataChangeResponse)
@@ -561,6 +637,26 @@ func (c *billingService) ProcessBillingAddress(ctx context.Context, in *ProcessB
 	return out, nil
 }
 
+func (c *billingService) ApplyOrderCoupon(ctx context.Context, in *ApplyOrderCouponRequest, opts ...client.CallOption) (*ApplyOrderCouponResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.ApplyOrderCoupon", in)
+	out := new(ApplyOrderCouponResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) SetOrderGift(ctx context.Context, in *SetOrderGiftRequest, opts ...client.CallOption) (*SetOrderGiftResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.SetOrderGift", in)
+	out := new(SetOrderGiftResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *billingService) CreateOrUpdateProduct(ctx context.Context, in *Product, opts ...client.CallOption) (*Product, error) {
 	req := c.c.NewRequest(c.name, "BillingService.CreateOrUpdateProduct", in)
 	out := new(Product)
@@ -1261,6 +1357,46 @@ func (c *billingService) AutoAcceptRoyaltyReports(ctx context.Context, in *Empty
 	return out, nil
 }
 
+func (c *billingService) OpenRoyaltyReportDispute(ctx context.Context, in *OpenRoyaltyReportDisputeRequest, opts ...client.CallOption) (*GetRoyaltyReportResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.OpenRoyaltyReportDispute", in)
+	out := new(GetRoyaltyReportResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) AddRoyaltyReportDisputeMessage(ctx context.Context, in *AddRoyaltyReportDisputeMessageRequest, opts ...client.CallOption) (*GetRoyaltyReportResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.AddRoyaltyReportDisputeMessage", in)
+	out := new(GetRoyaltyReportResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) ApplyRoyaltyReportDisputeCorrections(ctx context.Context, in *ApplyRoyaltyReportDisputeCorrectionsRequest, opts ...client.CallOption) (*GetRoyaltyReportResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.ApplyRoyaltyReportDisputeCorrections", in)
+	out := new(GetRoyaltyReportResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) AutoEscalateRoyaltyReportDisputes(ctx context.Context, in *EmptyRequest, opts ...client.CallOption) (*EmptyResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.AutoEscalateRoyaltyReportDisputes", in)
+	out := new(EmptyResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *billingService) RoyaltyReportPdfUploaded(ctx context.Context, in *RoyaltyReportPdfUploadedRequest, opts ...client.CallOption) (*RoyaltyReportPdfUploadedResponse, error) {
 	req := c.c.NewRequest(c.name, "BillingService.RoyaltyReportPdfUploaded", in)
 	out := new(RoyaltyReportPdfUploadedResponse)
@@ -1801,6 +1937,376 @@ func (c *billingService) SetPaymentMinLimitSystem(ctx context.Context, in *billi
 	return out, nil
 }
 
+func (c *billingService) GetPaymentRoutingRules(ctx context.Context, in *GetPaymentRoutingRulesRequest, opts ...client.CallOption) (*GetPaymentRoutingRulesResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.GetPaymentRoutingRules", in)
+	out := new(GetPaymentRoutingRulesResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) SetPaymentRoutingRule(ctx context.Context, in *billing.PaymentRoutingRule, opts ...client.CallOption) (*SetPaymentRoutingRuleResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.SetPaymentRoutingRule", in)
+	out := new(SetPaymentRoutingRuleResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) DeletePaymentRoutingRule(ctx context.Context, in *DeletePaymentRoutingRuleRequest, opts ...client.CallOption) (*EmptyResponseWithStatus, error) {
+	req := c.c.NewRequest(c.name, "BillingService.DeletePaymentRoutingRule", in)
+	out := new(EmptyResponseWithStatus)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) GetRiskRules(ctx context.Context, in *GetRiskRulesRequest, opts ...client.CallOption) (*GetRiskRulesResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.GetRiskRules", in)
+	out := new(GetRiskRulesResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) SetRiskRule(ctx context.Context, in *SetRiskRuleRequest, opts ...client.CallOption) (*SetRiskRuleResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.SetRiskRule", in)
+	out := new(SetRiskRuleResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) DeleteRiskRule(ctx context.Context, in *DeleteRiskRuleRequest, opts ...client.CallOption) (*EmptyResponseWithStatus, error) {
+	req := c.c.NewRequest(c.name, "BillingService.DeleteRiskRule", in)
+	out := new(EmptyResponseWithStatus)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) ReviewOrderRisk(ctx context.Context, in *ReviewOrderRiskRequest, opts ...client.CallOption) (*ReviewOrderRiskResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.ReviewOrderRisk", in)
+	out := new(ReviewOrderRiskResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) SetSubscriptionPlan(ctx context.Context, in *billing.SubscriptionPlan, opts ...client.CallOption) (*SubscriptionPlanResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.SetSubscriptionPlan", in)
+	out := new(SubscriptionPlanResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) GetSubscriptionPlans(ctx context.Context, in *GetSubscriptionPlansRequest, opts ...client.CallOption) (*GetSubscriptionPlansResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.GetSubscriptionPlans", in)
+	out := new(GetSubscriptionPlansResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) CreateSubscription(ctx context.Context, in *CreateSubscriptionRequest, opts ...client.CallOption) (*SubscriptionResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.CreateSubscription", in)
+	out := new(SubscriptionResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) GetSubscription(ctx context.Context, in *GetSubscriptionRequest, opts ...client.CallOption) (*SubscriptionResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.GetSubscription", in)
+	out := new(SubscriptionResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) ChangeSubscriptionPlan(ctx context.Context, in *ChangeSubscriptionPlanRequest, opts ...client.CallOption) (*SubscriptionResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.ChangeSubscriptionPlan", in)
+	out := new(SubscriptionResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) CancelSubscription(ctx context.Context, in *CancelSubscriptionRequest, opts ...client.CallOption) (*SubscriptionResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.CancelSubscription", in)
+	out := new(SubscriptionResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) SetRefundApprovalThreshold(ctx context.Context, in *SetRefundApprovalThresholdRequest, opts ...client.CallOption) (*RefundApprovalThresholdResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.SetRefundApprovalThreshold", in)
+	out := new(RefundApprovalThresholdResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) GetRefundApprovalThresholds(ctx context.Context, in *GetRefundApprovalThresholdsRequest, opts ...client.CallOption) (*GetRefundApprovalThresholdsResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.GetRefundApprovalThresholds", in)
+	out := new(GetRefundApprovalThresholdsResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) DeleteRefundApprovalThreshold(ctx context.Context, in *DeleteRefundApprovalThresholdRequest, opts ...client.CallOption) (*EmptyResponseWithStatus, error) {
+	req := c.c.NewRequest(c.name, "BillingService.DeleteRefundApprovalThreshold", in)
+	out := new(EmptyResponseWithStatus)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) ApproveRefund(ctx context.Context, in *RefundDecisionRequest, opts ...client.CallOption) (*CreateRefundResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.ApproveRefund", in)
+	out := new(CreateRefundResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) RejectRefund(ctx context.Context, in *RefundDecisionRequest, opts ...client.CallOption) (*CreateRefundResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.RejectRefund", in)
+	out := new(CreateRefundResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) OpenDispute(ctx context.Context, in *OpenDisputeRequest, opts ...client.CallOption) (*DisputeResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.OpenDispute", in)
+	out := new(DisputeResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) ChangeDisputeStatus(ctx context.Context, in *ChangeDisputeStatusRequest, opts ...client.CallOption) (*DisputeResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.ChangeDisputeStatus", in)
+	out := new(DisputeResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) AddDisputeEvidence(ctx context.Context, in *AddDisputeEvidenceRequest, opts ...client.CallOption) (*DisputeResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.AddDisputeEvidence", in)
+	out := new(DisputeResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) GetDispute(ctx context.Context, in *GetDisputeRequest, opts ...client.CallOption) (*DisputeResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.GetDispute", in)
+	out := new(DisputeResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) ListDisputes(ctx context.Context, in *ListDisputesRequest, opts ...client.CallOption) (*ListDisputesResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.ListDisputes", in)
+	out := new(ListDisputesResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) CreateBulkRefund(ctx context.Context, in *CreateBulkRefundRequest, opts ...client.CallOption) (*BulkRefundJobResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.CreateBulkRefund", in)
+	out := new(BulkRefundJobResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) GetBulkRefundJob(ctx context.Context, in *GetBulkRefundJobRequest, opts ...client.CallOption) (*BulkRefundJobResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.GetBulkRefundJob", in)
+	out := new(BulkRefundJobResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) SetCoupon(ctx context.Context, in *billing.Coupon, opts ...client.CallOption) (*CouponResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.SetCoupon", in)
+	out := new(CouponResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) GetCoupons(ctx context.Context, in *GetCouponsRequest, opts ...client.CallOption) (*GetCouponsResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.GetCoupons", in)
+	out := new(GetCouponsResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) IssueStoreCredit(ctx context.Context, in *IssueStoreCreditRequest, opts ...client.CallOption) (*StoreCreditResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.IssueStoreCredit", in)
+	out := new(StoreCreditResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) GetStoreCredits(ctx context.Context, in *GetStoreCreditsRequest, opts ...client.CallOption) (*GetStoreCreditsResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.GetStoreCredits", in)
+	out := new(GetStoreCreditsResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) GetLedgerTrialBalance(ctx context.Context, in *GetLedgerTrialBalanceRequest, opts ...client.CallOption) (*GetLedgerTrialBalanceResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.GetLedgerTrialBalance", in)
+	out := new(GetLedgerTrialBalanceResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) CheckLedgerBalance(ctx context.Context, in *CheckLedgerBalanceRequest, opts ...client.CallOption) (*CheckLedgerBalanceResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.CheckLedgerBalance", in)
+	out := new(CheckLedgerBalanceResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) ExportAccountingEntries(ctx context.Context, in *ExportAccountingEntriesRequest, opts ...client.CallOption) (*ExportAccountingEntriesResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.ExportAccountingEntries", in)
+	out := new(ExportAccountingEntriesResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) ListFailedWebhooks(ctx context.Context, in *ListFailedWebhooksRequest, opts ...client.CallOption) (*ListFailedWebhooksResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.ListFailedWebhooks", in)
+	out := new(ListFailedWebhooksResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) RedeliverWebhook(ctx context.Context, in *RedeliverWebhookRequest, opts ...client.CallOption) (*RedeliverWebhookResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.RedeliverWebhook", in)
+	out := new(RedeliverWebhookResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) ImportSettlementFile(ctx context.Context, in *ImportSettlementFileRequest, opts ...client.CallOption) (*ImportSettlementFileResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.ImportSettlementFile", in)
+	out := new(ImportSettlementFileResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) ListSettlementReports(ctx context.Context, in *ListSettlementReportsRequest, opts ...client.CallOption) (*ListSettlementReportsResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.ListSettlementReports", in)
+	out := new(ListSettlementReportsResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingService) GetSettlementReportFile(ctx context.Context, in *GetSettlementReportFileRequest, opts ...client.CallOption) (*GetSettlementReportFileResponse, error) {
+	req := c.c.NewRequest(c.name, "BillingService.GetSettlementReportFile", in)
+	out := new(GetSettlementReportFileResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *billingService) GetMerchantUsers(ctx context.Context, in *GetMerchantUsersRequest, opts ...client.CallOption) (*GetMerchantUsersResponse, error) {
 	req := c.c.NewRequest(c.name, "BillingService.GetMerchantUsers", in)
 	out := new(GetMerchantUsersResponse)
@@ -1993,6 +2499,7 @@ type BillingServiceHandler interface {
 	GetMerchantTariffRates(context.Context, *GetMerchantTariffRatesRequest, *GetMerchantTariffRatesResponse) error
 	SetMerchantTariffRates(context.Context, *SetMerchantTariffRatesRequest, *CheckProjectRequestSignatureResponse) error
 	ChangeMerchantManualPayouts(context.Context, *ChangeMerchantManualPayoutsRequest, *ChangeMerchantManualPayoutsResponse) error
+	ChangeMerchantRoyaltyReportPeriod(context.Context, *ChangeMerchantRoyaltyReportPeriodRequest, *ChangeMerchantManualPayoutsResponse) error
 	CreateNotification(context.Context, *NotificationRequest, *CreateNotificationResponse) error
 	GetNotification(context.Context, *GetNotificationRequest, *billing.Notification) error
 	ListNotifications(context.Context, *ListingNotificationRequest, *Notifications) error
@@ -2004,9 +2511,13 @@ type BillingServiceHandler interface {
 	ListRefunds(context.Context, *ListRefundsRequest, *ListRefundsResponse) error
 	GetRefund(context.Context, *GetRefundRequest, *CreateRefundResponse) error
 	ProcessRefundCallback(context.Context, *CallbackRequest, *PaymentNotifyResponse) error
+	CapturePayment(context.Context, *CapturePaymentRequest, *CapturePaymentResponse) error
+	VoidPayment(context.Context, *VoidPaymentRequest, *VoidPaymentResponse) error
 	PaymentFormLanguageChanged(context.Context, *PaymentFormUserChangeLangRequest, *PaymentFormDataChangeResponse) error
 	PaymentFormPaymentAccountChanged(context.Context, *PaymentFormUserChangePaymentAccountRequest, *PaymentFormDataChangeResponse) error
 	ProcessBillingAddress(context.Context, *ProcessBillingAddressRequest, *ProcessBillingAddressResponse) error
+	ApplyOrderCoupon(context.Context, *ApplyOrderCouponRequest, *ApplyOrderCouponResponse) error
+	SetOrderGift(context.Context, *SetOrderGiftRequest, *SetOrderGiftResponse) error
 	CreateOrUpdateProduct(context.Context, *Product, *Product) error
 	ListProducts(context.Context, *ListProductsRequest, *ListProductsResponse) error
 	GetProduct(context.Context, *RequestProduct, *GetProductResponse) error
@@ -2077,6 +2588,10 @@ type BillingServiceHandler interface {
 	ListRoyaltyReportOrders(context.Context, *ListRoyaltyReportOrdersRequest, *TransactionsResponse) error
 	MerchantReviewRoyaltyReport(context.Context, *MerchantReviewRoyaltyReportRequest, *ResponseError) error
 	AutoAcceptRoyaltyReports(context.Context, *EmptyRequest, *EmptyResponse) error
+	OpenRoyaltyReportDispute(context.Context, *OpenRoyaltyReportDisputeRequest, *GetRoyaltyReportResponse) error
+	AddRoyaltyReportDisputeMessage(context.Context, *AddRoyaltyReportDisputeMessageRequest, *GetRoyaltyReportResponse) error
+	ApplyRoyaltyReportDisputeCorrections(context.Context, *ApplyRoyaltyReportDisputeCorrectionsRequest, *GetRoyaltyReportResponse) error
+	AutoEscalateRoyaltyReportDisputes(context.Context, *EmptyRequest, *EmptyResponse) error
 	RoyaltyReportPdfUploaded(context.Context, *RoyaltyReportPdfUploadedRequest, *RoyaltyReportPdfUploadedResponse) error
 	GetVatReportsDashboard(context.Context, *EmptyRequest, *VatReportsResponse) error
 	GetVatReportsForCountry(context.Context, *VatReportsRequest, *VatReportsResponse) error
@@ -2131,6 +2646,43 @@ type BillingServiceHandler interface {
 	GetOperatingCompany(context.Context, *GetOperatingCompanyRequest, *GetOperatingCompanyResponse) error
 	GetPaymentMinLimitsSystem(context.Context, *EmptyRequest, *GetPaymentMinLimitsSystemResponse) error
 	SetPaymentMinLimitSystem(context.Context, *billing.PaymentMinLimitSystem, *EmptyResponseWithStatus) error
+	GetPaymentRoutingRules(context.Context, *GetPaymentRoutingRulesRequest, *GetPaymentRoutingRulesResponse) error
+	SetPaymentRoutingRule(context.Context, *billing.PaymentRoutingRule, *SetPaymentRoutingRuleResponse) error
+	DeletePaymentRoutingRule(context.Context, *DeletePaymentRoutingRuleRequest, *EmptyResponseWithStatus) error
+	GetRiskRules(context.Context, *GetRiskRulesRequest, *GetRiskRulesResponse) error
+	SetRiskRule(context.Context, *SetRiskRuleRequest, *SetRiskRuleResponse) error
+	DeleteRiskRule(context.Context, *DeleteRiskRuleRequest, *EmptyResponseWithStatus) error
+	ReviewOrderRisk(context.Context, *ReviewOrderRiskRequest, *ReviewOrderRiskResponse) error
+	SetSubscriptionPlan(context.Context, *billing.SubscriptionPlan, *SubscriptionPlanResponse) error
+	GetSubscriptionPlans(context.Context, *GetSubscriptionPlansRequest, *GetSubscriptionPlansResponse) error
+	CreateSubscription(context.Context, *CreateSubscriptionRequest, *SubscriptionResponse) error
+	GetSubscription(context.Context, *GetSubscriptionRequest, *SubscriptionResponse) error
+	ChangeSubscriptionPlan(context.Context, *ChangeSubscriptionPlanRequest, *SubscriptionResponse) error
+	CancelSubscription(context.Context, *CancelSubscriptionRequest, *SubscriptionResponse) error
+	SetRefundApprovalThreshold(context.Context, *SetRefundApprovalThresholdRequest, *RefundApprovalThresholdResponse) error
+	GetRefundApprovalThresholds(context.Context, *GetRefundApprovalThresholdsRequest, *GetRefundApprovalThresholdsResponse) error
+	DeleteRefundApprovalThreshold(context.Context, *DeleteRefundApprovalThresholdRequest, *EmptyResponseWithStatus) error
+	ApproveRefund(context.Context, *RefundDecisionRequest, *CreateRefundResponse) error
+	RejectRefund(context.Context, *RefundDecisionRequest, *CreateRefundResponse) error
+	OpenDispute(context.Context, *OpenDisputeRequest, *DisputeResponse) error
+	ChangeDisputeStatus(context.Context, *ChangeDisputeStatusRequest, *DisputeResponse) error
+	AddDisputeEvidence(context.Context, *AddDisputeEvidenceRequest, *DisputeResponse) error
+	GetDispute(context.Context, *GetDisputeRequest, *DisputeResponse) error
+	ListDisputes(context.Context, *ListDisputesRequest, *ListDisputesResponse) error
+	CreateBulkRefund(context.Context, *CreateBulkRefundRequest, *BulkRefundJobResponse) error
+	GetBulkRefundJob(context.Context, *GetBulkRefundJobRequest, *BulkRefundJobResponse) error
+	SetCoupon(context.Context, *billing.Coupon, *CouponResponse) error
+	GetCoupons(context.Context, *GetCouponsRequest, *GetCouponsResponse) error
+	IssueStoreCredit(context.Context, *IssueStoreCreditRequest, *StoreCreditResponse) error
+	GetStoreCredits(context.Context, *GetStoreCreditsRequest, *GetStoreCreditsResponse) error
+	GetLedgerTrialBalance(context.Context, *GetLedgerTrialBalanceRequest, *GetLedgerTrialBalanceResponse) error
+	CheckLedgerBalance(context.Context, *CheckLedgerBalanceRequest, *CheckLedgerBalanceResponse) error
+	ExportAccountingEntries(context.Context, *ExportAccountingEntriesRequest, *ExportAccountingEntriesResponse) error
+	ListFailedWebhooks(context.Context, *ListFailedWebhooksRequest, *ListFailedWebhooksResponse) error
+	RedeliverWebhook(context.Context, *RedeliverWebhookRequest, *RedeliverWebhookResponse) error
+	ImportSettlementFile(context.Context, *ImportSettlementFileRequest, *ImportSettlementFileResponse) error
+	ListSettlementReports(context.Context, *ListSettlementReportsRequest, *ListSettlementReportsResponse) error
+	GetSettlementReportFile(context.Context, *GetSettlementReportFileRequest, *GetSettlementReportFileResponse) error
 	GetMerchantUsers(context.Context, *GetMerchantUsersRequest, *GetMerchantUsersResponse) error
 	GetAdminUsers(context.Context, *EmptyRequest, *GetAdminUsersResponse) error
 	InviteUserMerchant(context.Context, *InviteUserMerchantRequest, *InviteUserMerchantResponse) error
@@ -2171,6 +2723,7 @@ func RegisterBillingServiceHandler(s server.Server, hdlr BillingServiceHandler,
 		GetMerchantTariffRates(ctx context.Context, in *GetMerchantTariffRatesRequest, out *GetMerchantTariffRatesResponse) error
 		SetMerchantTariffRates(ctx context.Context, in *SetMerchantTariffRatesRequest, out *CheckProjectRequestSignatureResponse) error
 		ChangeMerchantManualPayouts(ctx context.Context, in *ChangeMerchantManualPayoutsRequest, out *ChangeMerchantManualPayoutsResponse) error
+		ChangeMerchantRoyaltyReportPeriod(ctx context.Context, in *ChangeMerchantRoyaltyReportPeriodRequest, out *ChangeMerchantManualPayoutsResponse) error
 		CreateNotification(ctx context.Context, in *NotificationRequest, out *CreateNotificationResponse) error
 		GetNotification(ctx context.Context, in *GetNotificationRequest, out *billing.Notification) error
 		ListNotifications(ctx context.Context, in *ListingNotificationRequest, out *Notifications) error
@@ -2182,9 +2735,13 @@ func RegisterBillingServiceHandler(s server.Server, hdlr BillingServiceHandler,
 		ListRefunds(ctx context.Context, in *ListRefundsRequest, out *ListRefundsResponse) error
 		GetRefund(ctx context.Context, in *GetRefundRequest, out *CreateRefundResponse) error
 		ProcessRefundCallback(ctx context.Context, in *CallbackRequest, out *PaymentNotifyResponse) error
+		CapturePayment(ctx context.Context, in *CapturePaymentRequest, out *CapturePaymentResponse) error
+		VoidPayment(ctx context.Context, in *VoidPaymentRequest, out *VoidPaymentResponse) error
 		PaymentFormLanguageChanged(ctx context.Context, in *PaymentFormUserChangeLangRequest, out *PaymentFormDataChangeResponse) error
 		PaymentFormPaymentAccountChanged(ctx context.Context, in *PaymentFormUserChangePaymentAccountRequest, out *PaymentFormDataChangeResponse) error
 		ProcessBillingAddress(ctx context.Context, in *ProcessBillingAddressRequest, out *ProcessBillingAddressResponse) error
+		ApplyOrderCoupon(ctx context.Context, in *ApplyOrderCouponRequest, out *ApplyOrderCouponResponse) error
+		SetOrderGift(ctx context.Context, in *SetOrderGiftRequest, out *SetOrderGiftResponse) error
 		CreateOrUpdateProduct(ctx context.Context, in *Product, out *Product) error
 		ListProducts(ctx context.Context, in *ListProductsRequest, out *ListProductsResponse) error
 		GetProduct(ctx context.Context, in *RequestProduct, out *GetProductResponse) error
@@ -2255,6 +2812,10 @@ func RegisterBillingServiceHandler(s server.Server, hdlr BillingServiceHandler,
 		ListRoyaltyReportOrders(ctx context.Context, in *ListRoyaltyReportOrdersRequest, out *TransactionsResponse) error
 		MerchantReviewRoyaltyReport(ctx context.Context, in *MerchantReviewRoyaltyReportRequest, out *ResponseError) error
 		AutoAcceptRoyaltyReports(ctx context.Context, in *EmptyRequest, out *EmptyResponse) error
+		OpenRoyaltyReportDispute(ctx context.Context, in *OpenRoyaltyReportDisputeRequest, out *GetRoyaltyReportResponse) error
+		AddRoyaltyReportDisputeMessage(ctx context.Context, in *AddRoyaltyReportDisputeMessageRequest, out *GetRoyaltyReportResponse) error
+		ApplyRoyaltyReportDisputeCorrections(ctx context.Context, in *ApplyRoyaltyReportDisputeCorrectionsRequest, out *GetRoyaltyReportResponse) error
+		AutoEscalateRoyaltyReportDisputes(ctx context.Context, in *EmptyRequest, out *EmptyResponse) error
 		RoyaltyReportPdfUploaded(ctx context.Context, in *RoyaltyReportPdfUploadedRequest, out *RoyaltyReportPdfUploadedResponse) error
 		GetVatReportsDashboard(ctx context.Context, in *EmptyRequest, out *VatReportsResponse) error
 		GetVatReportsForCountry(ctx context.Context, in *VatReportsRequest, out *VatReportsResponse) error
@@ -2309,6 +2870,43 @@ func RegisterBillingServiceHandler(s server.Server, hdlr BillingServiceHandler,
 		GetOperatingCompany(ctx context.Context, in *GetOperatingCompanyRequest, out *GetOperatingCompanyResponse) error
 		GetPaymentMinLimitsSystem(ctx context.Context, in *EmptyRequest, out *GetPaymentMinLimitsSystemResponse) error
 		SetPaymentMinLimitSystem(ctx context.Context, in *billing.PaymentMinLimitSystem, out *EmptyResponseWithStatus) error
+		GetPaymentRoutingRules(ctx context.Context, in *GetPaymentRoutingRulesRequest, out *GetPaymentRoutingRulesResponse) error
+		SetPaymentRoutingRule(ctx context.Context, in *billing.PaymentRoutingRule, out *SetPaymentRoutingRuleResponse) error
+		DeletePaymentRoutingRule(ctx context.Context, in *DeletePaymentRoutingRuleRequest, out *EmptyResponseWithStatus) error
+		GetRiskRules(ctx context.Context, in *GetRiskRulesRequest, out *GetRiskRulesResponse) error
+		SetRiskRule(ctx context.Context, in *SetRiskRuleRequest, out *SetRiskRuleResponse) error
+		DeleteRiskRule(ctx context.Context, in *DeleteRiskRuleRequest, out *EmptyResponseWithStatus) error
+		ReviewOrderRisk(ctx context.Context, in *ReviewOrderRiskRequest, out *ReviewOrderRiskResponse) error
+		SetSubscriptionPlan(ctx context.Context, in *billing.SubscriptionPlan, out *SubscriptionPlanResponse) error
+		GetSubscriptionPlans(ctx context.Context, in *GetSubscriptionPlansRequest, out *GetSubscriptionPlansResponse) error
+		CreateSubscription(ctx context.Context, in *CreateSubscriptionRequest, out *SubscriptionResponse) error
+		GetSubscription(ctx context.Context, in *GetSubscriptionRequest, out *SubscriptionResponse) error
+		ChangeSubscriptionPlan(ctx context.Context, in *ChangeSubscriptionPlanRequest, out *SubscriptionResponse) error
+		CancelSubscription(ctx context.Context, in *CancelSubscriptionRequest, out *SubscriptionResponse) error
+		SetRefundApprovalThreshold(ctx context.Context, in *SetRefundApprovalThresholdRequest, out *RefundApprovalThresholdResponse) error
+		GetRefundApprovalThresholds(ctx context.Context, in *GetRefundApprovalThresholdsRequest, out *GetRefundApprovalThresholdsResponse) error
+		DeleteRefundApprovalThreshold(ctx context.Context, in *DeleteRefundApprovalThresholdRequest, out *EmptyResponseWithStatus) error
+		ApproveRefund(ctx context.Context, in *RefundDecisionRequest, out *CreateRefundResponse) error
+		RejectRefund(ctx context.Context, in *RefundDecisionRequest, out *CreateRefundResponse) error
+		OpenDispute(ctx context.Context, in *OpenDisputeRequest, out *DisputeResponse) error
+		ChangeDisputeStatus(ctx context.Context, in *ChangeDisputeStatusRequest, out *DisputeResponse) error
+		AddDisputeEvidence(ctx context.Context, in *AddDisputeEvidenceRequest, out *DisputeResponse) error
+		GetDispute(ctx context.Context, in *GetDisputeRequest, out *DisputeResponse) error
+		ListDisputes(ctx context.Context, in *ListDisputesRequest, out *ListDisputesResponse) error
+		CreateBulkRefund(ctx context.Context, in *CreateBulkRefundRequest, out *BulkRefundJobResponse) error
+		GetBulkRefundJob(ctx context.Context, in *GetBulkRefundJobRequest, out *BulkRefundJobResponse) error
+		SetCoupon(ctx context.Context, in *billing.Coupon, out *CouponResponse) error
+		GetCoupons(ctx context.Context, in *GetCouponsRequest, out *GetCouponsResponse) error
+		IssueStoreCredit(ctx context.Context, in *IssueStoreCreditRequest, out *StoreCreditResponse) error
+		GetStoreCredits(ctx context.Context, in *GetStoreCreditsRequest, out *GetStoreCreditsResponse) error
+		GetLedgerTrialBalance(ctx context.Context, in *GetLedgerTrialBalanceRequest, out *GetLedgerTrialBalanceResponse) error
+		CheckLedgerBalance(ctx context.Context, in *CheckLedgerBalanceRequest, out *CheckLedgerBalanceResponse) error
+		ExportAccountingEntries(ctx context.Context, in *ExportAccountingEntriesRequest, out *ExportAccountingEntriesResponse) error
+		ListFailedWebhooks(ctx context.Context, in *ListFailedWebhooksRequest, out *ListFailedWebhooksResponse) error
+		RedeliverWebhook(ctx context.Context, in *RedeliverWebhookRequest, out *RedeliverWebhookResponse) error
+		ImportSettlementFile(ctx context.Context, in *ImportSettlementFileRequest, out *ImportSettlementFileResponse) error
+		ListSettlementReports(ctx context.Context, in *ListSettlementReportsRequest, out *ListSettlementReportsResponse) error
+		GetSettlementReportFile(ctx context.Context, in *GetSettlementReportFileRequest, out *GetSettlementReportFileResponse) error
 		GetMerchantUsers(ctx context.Context, in *GetMerchantUsersRequest, out *GetMerchantUsersResponse) error
 		GetAdminUsers(ctx context.Context, in *EmptyRequest, out *GetAdminUsersResponse) error
 		InviteUserMerchant(ctx context.Context, in *InviteUserMerchantRequest, out *InviteUserMerchantResponse) error
@@ -2414,6 +3012,10 @@ func (h *billingServiceHandler) ChangeMerchantManualPayouts(ctx context.Context,
 	return h.BillingServiceHandler.ChangeMerchantManualPayouts(ctx, in, out)
 }
 
+func (h *billingServiceHandler) ChangeMerchantRoyaltyReportPeriod(ctx context.Context, in *ChangeMerchantRoyaltyReportPeriodRequest, out *ChangeMerchantManualPayoutsResponse) error {
+	return h.BillingServiceHandler.ChangeMerchantRoyaltyReportPeriod(ctx, in, out)
+}
+
 func (h *billingServiceHandler) CreateNotification(ctx context.Context, in *NotificationRequest, out *CreateNotificationResponse) error {
 	return h.BillingServiceHandler.CreateNotification(ctx, in, out)
 }
@@ -2458,6 +3060,14 @@ func (h *billingServiceHandler) ProcessRefundCallback(ctx context.Context, in *C
 	return h.BillingServiceHandler.ProcessRefundCallback(ctx, in, out)
 }
 
+func (h *billingServiceHandler) CapturePayment(ctx context.Context, in *CapturePaymentRequest, out *CapturePaymentResponse) error {
+	return h.BillingServiceHandler.CapturePayment(ctx, in, out)
+}
+
+func (h *billingServiceHandler) VoidPayment(ctx context.Context, in *VoidPaymentRequest, out *VoidPaymentResponse) error {
+	return h.BillingServiceHandler.VoidPayment(ctx, in, out)
+}
+
 func (h *billingServiceHandler) PaymentFormLanguageChanged(ctx context.Context, in *PaymentFormUserChangeLangRequest, out *PaymentFormDataChangeResponse) error {
 	return h.BillingServiceHandler.PaymentFormLanguageChanged(ctx, in, out)
 }
@@ -2470,6 +3080,14 @@ func (h *billingServiceHandler) ProcessBillingAddress(ctx context.Context, in *P
 	return h.BillingServiceHandler.ProcessBillingAddress(ctx, in, out)
 }
 
+func (h *billingServiceHandler) ApplyOrderCoupon(ctx context.Context, in *ApplyOrderCouponRequest, out *ApplyOrderCouponResponse) error {
+	return h.BillingServiceHandler.ApplyOrderCoupon(ctx, in, out)
+}
+
+func (h *billingServiceHandler) SetOrderGift(ctx context.Context, in *SetOrderGiftRequest, out *SetOrderGiftResponse) error {
+	return h.BillingServiceHandler.SetOrderGift(ctx, in, out)
+}
+
 func (h *billingServiceHandler) CreateOrUpdateProduct(ctx context.Context, in *Product, out *Product) error {
 	return h.BillingServiceHandler.CreateOrUpdateProduct(ctx, in, out)
 }
@@ -2750,6 +3368,22 @@ func (h *billingServiceHandler) AutoAcceptRoyaltyReports(ctx context.Context, in
 	return h.BillingServiceHandler.AutoAcceptRoyaltyReports(ctx, in, out)
 }
 
+func (h *billingServiceHandler) OpenRoyaltyReportDispute(ctx context.Context, in *OpenRoyaltyReportDisputeRequest, out *GetRoyaltyReportResponse) error {
+	return h.BillingServiceHandler.OpenRoyaltyReportDispute(ctx, in, out)
+}
+
+func (h *billingServiceHandler) AddRoyaltyReportDisputeMessage(ctx context.Context, in *AddRoyaltyReportDisputeMessageRequest, out *GetRoyaltyReportResponse) error {
+	return h.BillingServiceHandler.AddRoyaltyReportDisputeMessage(ctx, in, out)
+}
+
+func (h *billingServiceHandler) ApplyRoyaltyReportDisputeCorrections(ctx context.Context, in *ApplyRoyaltyReportDisputeCorrectionsRequest, out *GetRoyaltyReportResponse) error {
+	return h.BillingServiceHandler.ApplyRoyaltyReportDisputeCorrections(ctx, in, out)
+}
+
+func (h *billingServiceHandler) AutoEscalateRoyaltyReportDisputes(ctx context.Context, in *EmptyRequest, out *EmptyResponse) error {
+	return h.BillingServiceHandler.AutoEscalateRoyaltyReportDisputes(ctx, in, out)
+}
+
 func (h *billingServiceHandler) RoyaltyReportPdfUploaded(ctx context.Context, in *RoyaltyReportPdfUploadedRequest, out *RoyaltyReportPdfUploadedResponse) error {
 	return h.BillingServiceHandler.RoyaltyReportPdfUploaded(ctx, in, out)
 }
@@ -2966,6 +3600,154 @@ func (h *billingServiceHandler) SetPaymentMinLimitSystem(ctx context.Context, in
 	return h.BillingServiceHandler.SetPaymentMinLimitSystem(ctx, in, out)
 }
 
+func (h *billingServiceHandler) GetPaymentRoutingRules(ctx context.Context, in *GetPaymentRoutingRulesRequest, out *GetPaymentRoutingRulesResponse) error {
+	return h.BillingServiceHandler.GetPaymentRoutingRules(ctx, in, out)
+}
+
+func (h *billingServiceHandler) SetPaymentRoutingRule(ctx context.Context, in *billing.PaymentRoutingRule, out *SetPaymentRoutingRuleResponse) error {
+	return h.BillingServiceHandler.SetPaymentRoutingRule(ctx, in, out)
+}
+
+func (h *billingServiceHandler) DeletePaymentRoutingRule(ctx context.Context, in *DeletePaymentRoutingRuleRequest, out *EmptyResponseWithStatus) error {
+	return h.BillingServiceHandler.DeletePaymentRoutingRule(ctx, in, out)
+}
+
+func (h *billingServiceHandler) GetRiskRules(ctx context.Context, in *GetRiskRulesRequest, out *GetRiskRulesResponse) error {
+	return h.BillingServiceHandler.GetRiskRules(ctx, in, out)
+}
+
+func (h *billingServiceHandler) SetRiskRule(ctx context.Context, in *SetRiskRuleRequest, out *SetRiskRuleResponse) error {
+	return h.BillingServiceHandler.SetRiskRule(ctx, in, out)
+}
+
+func (h *billingServiceHandler) DeleteRiskRule(ctx context.Context, in *DeleteRiskRuleRequest, out *EmptyResponseWithStatus) error {
+	return h.BillingServiceHandler.DeleteRiskRule(ctx, in, out)
+}
+
+func (h *billingServiceHandler) ReviewOrderRisk(ctx context.Context, in *ReviewOrderRiskRequest, out *ReviewOrderRiskResponse) error {
+	return h.BillingServiceHandler.ReviewOrderRisk(ctx, in, out)
+}
+
+func (h *billingServiceHandler) SetSubscriptionPlan(ctx context.Context, in *billing.SubscriptionPlan, out *SubscriptionPlanResponse) error {
+	return h.BillingServiceHandler.SetSubscriptionPlan(ctx, in, out)
+}
+
+func (h *billingServiceHandler) GetSubscriptionPlans(ctx context.Context, in *GetSubscriptionPlansRequest, out *GetSubscriptionPlansResponse) error {
+	return h.BillingServiceHandler.GetSubscriptionPlans(ctx, in, out)
+}
+
+func (h *billingServiceHandler) CreateSubscription(ctx context.Context, in *CreateSubscriptionRequest, out *SubscriptionResponse) error {
+	return h.BillingServiceHandler.CreateSubscription(ctx, in, out)
+}
+
+func (h *billingServiceHandler) GetSubscription(ctx context.Context, in *GetSubscriptionRequest, out *SubscriptionResponse) error {
+	return h.BillingServiceHandler.GetSubscription(ctx, in, out)
+}
+
+func (h *billingServiceHandler) ChangeSubscriptionPlan(ctx context.Context, in *ChangeSubscriptionPlanRequest, out *SubscriptionResponse) error {
+	return h.BillingServiceHandler.ChangeSubscriptionPlan(ctx, in, out)
+}
+
+func (h *billingServiceHandler) CancelSubscription(ctx context.Context, in *CancelSubscriptionRequest, out *SubscriptionResponse) error {
+	return h.BillingServiceHandler.CancelSubscription(ctx, in, out)
+}
+
+func (h *billingServiceHandler) SetRefundApprovalThreshold(ctx context.Context, in *SetRefundApprovalThresholdRequest, out *RefundApprovalThresholdResponse) error {
+	return h.BillingServiceHandler.SetRefundApprovalThreshold(ctx, in, out)
+}
+
+func (h *billingServiceHandler) GetRefundApprovalThresholds(ctx context.Context, in *GetRefundApprovalThresholdsRequest, out *GetRefundApprovalThresholdsResponse) error {
+	return h.BillingServiceHandler.GetRefundApprovalThresholds(ctx, in, out)
+}
+
+func (h *billingServiceHandler) DeleteRefundApprovalThreshold(ctx context.Context, in *DeleteRefundApprovalThresholdRequest, out *EmptyResponseWithStatus) error {
+	return h.BillingServiceHandler.DeleteRefundApprovalThreshold(ctx, in, out)
+}
+
+func (h *billingServiceHandler) ApproveRefund(ctx context.Context, in *RefundDecisionRequest, out *CreateRefundResponse) error {
+	return h.BillingServiceHandler.ApproveRefund(ctx, in, out)
+}
+
+func (h *billingServiceHandler) RejectRefund(ctx context.Context, in *RefundDecisionRequest, out *CreateRefundResponse) error {
+	return h.BillingServiceHandler.RejectRefund(ctx, in, out)
+}
+
+func (h *billingServiceHandler) OpenDispute(ctx context.Context, in *OpenDisputeRequest, out *DisputeResponse) error {
+	return h.BillingServiceHandler.OpenDispute(ctx, in, out)
+}
+
+func (h *billingServiceHandler) ChangeDisputeStatus(ctx context.Context, in *ChangeDisputeStatusRequest, out *DisputeResponse) error {
+	return h.BillingServiceHandler.ChangeDisputeStatus(ctx, in, out)
+}
+
+func (h *billingServiceHandler) AddDisputeEvidence(ctx context.Context, in *AddDisputeEvidenceRequest, out *DisputeResponse) error {
+	return h.BillingServiceHandler.AddDisputeEvidence(ctx, in, out)
+}
+
+func (h *billingServiceHandler) GetDispute(ctx context.Context, in *GetDisputeRequest, out *DisputeResponse) error {
+	return h.BillingServiceHandler.GetDispute(ctx, in, out)
+}
+
+func (h *billingServiceHandler) ListDisputes(ctx context.Context, in *ListDisputesRequest, out *ListDisputesResponse) error {
+	return h.BillingServiceHandler.ListDisputes(ctx, in, out)
+}
+
+func (h *billingServiceHandler) CreateBulkRefund(ctx context.Context, in *CreateBulkRefundRequest, out *BulkRefundJobResponse) error {
+	return h.BillingServiceHandler.CreateBulkRefund(ctx, in, out)
+}
+
+func (h *billingServiceHandler) GetBulkRefundJob(ctx context.Context, in *GetBulkRefundJobRequest, out *BulkRefundJobResponse) error {
+	return h.BillingServiceHandler.GetBulkRefundJob(ctx, in, out)
+}
+
+func (h *billingServiceHandler) SetCoupon(ctx context.Context, in *billing.Coupon, out *CouponResponse) error {
+	return h.BillingServiceHandler.SetCoupon(ctx, in, out)
+}
+
+func (h *billingServiceHandler) GetCoupons(ctx context.Context, in *GetCouponsRequest, out *GetCouponsResponse) error {
+	return h.BillingServiceHandler.GetCoupons(ctx, in, out)
+}
+
+func (h *billingServiceHandler) IssueStoreCredit(ctx context.Context, in *IssueStoreCreditRequest, out *StoreCreditResponse) error {
+	return h.BillingServiceHandler.IssueStoreCredit(ctx, in, out)
+}
+
+func (h *billingServiceHandler) GetStoreCredits(ctx context.Context, in *GetStoreCreditsRequest, out *GetStoreCreditsResponse) error {
+	return h.BillingServiceHandler.GetStoreCredits(ctx, in, out)
+}
+
+func (h *billingServiceHandler) GetLedgerTrialBalance(ctx context.Context, in *GetLedgerTrialBalanceRequest, out *GetLedgerTrialBalanceResponse) error {
+	return h.BillingServiceHandler.GetLedgerTrialBalance(ctx, in, out)
+}
+
+func (h *billingServiceHandler) CheckLedgerBalance(ctx context.Context, in *CheckLedgerBalanceRequest, out *CheckLedgerBalanceResponse) error {
+	return h.BillingServiceHandler.CheckLedgerBalance(ctx, in, out)
+}
+
+func (h *billingServiceHandler) ExportAccountingEntries(ctx context.Context, in *ExportAccountingEntriesRequest, out *ExportAccountingEntriesResponse) error {
+	return h.BillingServiceHandler.ExportAccountingEntries(ctx, in, out)
+}
+
+func (h *billingServiceHandler) ListFailedWebhooks(ctx context.Context, in *ListFailedWebhooksRequest, out *ListFailedWebhooksResponse) error {
+	return h.BillingServiceHandler.ListFailedWebhooks(ctx, in, out)
+}
+
+func (h *billingServiceHandler) RedeliverWebhook(ctx context.Context, in *RedeliverWebhookRequest, out *RedeliverWebhookResponse) error {
+	return h.BillingServiceHandler.RedeliverWebhook(ctx, in, out)
+}
+
+func (h *billingServiceHandler) ImportSettlementFile(ctx context.Context, in *ImportSettlementFileRequest, out *ImportSettlementFileResponse) error {
+	return h.BillingServiceHandler.ImportSettlementFile(ctx, in, out)
+}
+
+func (h *billingServiceHandler) ListSettlementReports(ctx context.Context, in *ListSettlementReportsRequest, out *ListSettlementReportsResponse) error {
+	return h.BillingServiceHandler.ListSettlementReports(ctx, in, out)
+}
+
+func (h *billingServiceHandler) GetSettlementReportFile(ctx context.Context, in *GetSettlementReportFileRequest, out *GetSettlementReportFileResponse) error {
+	return h.BillingServiceHandler.GetSettlementReportFile(ctx, in, out)
+}
+
 func (h *billingServiceHandler) GetMerchantUsers(ctx context.Context, in *GetMerchantUsersRequest, out *GetMerchantUsersResponse) error {
 	return h.BillingServiceHandler.GetMerchantUsers(ctx, in, out)
 }
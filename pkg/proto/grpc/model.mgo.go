@@ -15,25 +15,28 @@ const (
 )
 
 type MgoKeyProduct struct {
-	Id              primitive.ObjectID       `bson:"_id" json:"id"`
-	Object          string                   `bson:"object" json:"object"`
-	Sku             string                   `bson:"sku" json:"sku"`
-	Name            []*I18NTextSearchable    `bson:"name" json:"name"`
-	DefaultCurrency string                   `bson:"default_currency" json:"default_currency"`
-	Enabled         bool                     `bson:"enabled" json:"enabled"`
-	Platforms       []*MgoPlatformPrice      `bson:"platforms" json:"platforms"`
-	Description     map[string]string        `bson:"description" json:"description"`
-	LongDescription map[string]string        `bson:"long_description,omitempty" json:"long_description"`
-	CreatedAt       time.Time                `bson:"created_at" json:"created_at"`
-	UpdatedAt       time.Time                `bson:"updated_at" json:"updated_at"`
-	PublishedAt     *time.Time               `bson:"published_at" json:"published_at"`
-	Cover           *billing.ImageCollection `bson:"cover" json:"cover"`
-	Url             string                   `bson:"url,omitempty" json:"url"`
-	Metadata        map[string]string        `bson:"metadata,omitempty" json:"metadata"`
-	Deleted         bool                     `bson:"deleted" json:"deleted"`
-	MerchantId      primitive.ObjectID       `bson:"merchant_id" json:"-"`
-	ProjectId       primitive.ObjectID       `bson:"project_id" json:"project_id"`
-	Pricing         string                   `bson:"pricing" json:"pricing"`
+	Id                        primitive.ObjectID       `bson:"_id" json:"id"`
+	Object                    string                   `bson:"object" json:"object"`
+	Sku                       string                   `bson:"sku" json:"sku"`
+	Name                      []*I18NTextSearchable    `bson:"name" json:"name"`
+	DefaultCurrency           string                   `bson:"default_currency" json:"default_currency"`
+	Enabled                   bool                     `bson:"enabled" json:"enabled"`
+	Platforms                 []*MgoPlatformPrice      `bson:"platforms" json:"platforms"`
+	Description               map[string]string        `bson:"description" json:"description"`
+	LongDescription           map[string]string        `bson:"long_description,omitempty" json:"long_description"`
+	CreatedAt                 time.Time                `bson:"created_at" json:"created_at"`
+	UpdatedAt                 time.Time                `bson:"updated_at" json:"updated_at"`
+	PublishedAt               *time.Time               `bson:"published_at" json:"published_at"`
+	Cover                     *billing.ImageCollection `bson:"cover" json:"cover"`
+	Url                       string                   `bson:"url,omitempty" json:"url"`
+	Metadata                  map[string]string        `bson:"metadata,omitempty" json:"metadata"`
+	Deleted                   bool                     `bson:"deleted" json:"deleted"`
+	MerchantId                primitive.ObjectID       `bson:"merchant_id" json:"-"`
+	ProjectId                 primitive.ObjectID       `bson:"project_id" json:"project_id"`
+	Pricing                   string                   `bson:"pricing" json:"pricing"`
+	LowStockThreshold         int32                    `bson:"low_stock_threshold" json:"low_stock_threshold"`
+	PreOrderEnabled           bool                     `bson:"pre_order_enabled" json:"pre_order_enabled"`
+	LowStockNotifiedPlatforms []string                 `bson:"low_stock_notified_platforms" json:"-"`
 }
 
 type MgoPlatformPrice struct {
@@ -448,6 +451,9 @@ func (p *KeyProduct) UnmarshalBSON(raw []byte) error {
 	p.MerchantId = decoded.MerchantId.Hex()
 	p.ProjectId = decoded.ProjectId.Hex()
 	p.Pricing = decoded.Pricing
+	p.LowStockThreshold = decoded.LowStockThreshold
+	p.PreOrderEnabled = decoded.PreOrderEnabled
+	p.LowStockNotifiedPlatforms = decoded.LowStockNotifiedPlatforms
 
 	platforms := make([]*PlatformPrice, len(decoded.Platforms))
 	for i, pl := range decoded.Platforms {
@@ -501,6 +507,10 @@ func (p *KeyProduct) MarshalBSON() ([]byte, error) {
 		Metadata:        p.Metadata,
 		Deleted:         p.Deleted,
 		Pricing:         p.Pricing,
+
+		LowStockThreshold:         p.LowStockThreshold,
+		PreOrderEnabled:           p.PreOrderEnabled,
+		LowStockNotifiedPlatforms: p.LowStockNotifiedPlatforms,
 	}
 
 	if len(p.Id) <= 0 {